@@ -52,12 +52,76 @@ type AutoscalingListenerSpec struct {
 	// Required
 	Image string `json:"image,omitempty"`
 
+	// ImagePullPolicy is the pull policy of the listener image. Defaults to IfNotPresent.
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
 	// Required
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// HoldJobsAboveMaxRunners leaves the jobs above MaxRunners available to the overflow target of the scale set.
+	// +optional
+	HoldJobsAboveMaxRunners bool `json:"holdJobsAboveMaxRunners,omitempty"`
+
+	// OverflowSourceLabels are the labels of the scale sets overflowing into this one.
+	// Their jobs are only acquired once their own scale set had time to, and while below MaxRunners.
+	// +optional
+	OverflowSourceLabels []string `json:"overflowSourceLabels,omitempty"`
+
+	// TemplateVariantLabels are the labels selecting each template variant of the runners.
+	// +optional
+	TemplateVariantLabels map[string][]string `json:"templateVariantLabels,omitempty"`
+
+	// +optional
+	JobConcurrencyLimits *JobConcurrencyLimits `json:"jobConcurrencyLimits,omitempty"`
+
+	// +optional
+	JobAcquisitionBatchSize *int `json:"jobAcquisitionBatchSize,omitempty"`
+
+	// +optional
+	ScalingBufferPercent *int `json:"scalingBufferPercent,omitempty"`
+
+	// +optional
+	ScalingSmoothingPercent *int `json:"scalingSmoothingPercent,omitempty"`
+
+	// +optional
+	MaxPendingRunners *int `json:"maxPendingRunners,omitempty"`
+
+	// Template is merged into the generated listener pod.
+	// +optional
+	Template *corev1.PodTemplateSpec `json:"template,omitempty"`
 }
 
 // AutoscalingListenerStatus defines the observed state of AutoscalingListener
 type AutoscalingListenerStatus struct {
+	// CircuitBreaker is the circuit breaker guarding the listener calls to the Actions service,
+	// as last reported by the listener.
+	// +optional
+	CircuitBreaker *ListenerCircuitBreakerStatus `json:"circuitBreaker,omitempty"`
+}
+
+// ListenerCircuitBreakerState is the state of the listener circuit breaker.
+// +kubebuilder:validation:Enum=Closed;Open;HalfOpen
+type ListenerCircuitBreakerState string
+
+const (
+	// ListenerCircuitBreakerClosed means the listener calls the Actions service normally.
+	ListenerCircuitBreakerClosed ListenerCircuitBreakerState = "Closed"
+	// ListenerCircuitBreakerOpen means the listener stopped calling the Actions service after repeated failures.
+	ListenerCircuitBreakerOpen ListenerCircuitBreakerState = "Open"
+	// ListenerCircuitBreakerHalfOpen means the listener is probing whether the Actions service recovered.
+	ListenerCircuitBreakerHalfOpen ListenerCircuitBreakerState = "HalfOpen"
+)
+
+type ListenerCircuitBreakerStatus struct {
+	State ListenerCircuitBreakerState `json:"state"`
+
+	// ConsecutiveFailures is the number of transient failures since the last successful call.
+	// +optional
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -65,6 +129,7 @@ type AutoscalingListenerStatus struct {
 //+kubebuilder:printcolumn:JSONPath=".spec.githubConfigUrl",name=GitHub Configure URL,type=string
 //+kubebuilder:printcolumn:JSONPath=".spec.autoscalingRunnerSetNamespace",name=AutoscalingRunnerSet Namespace,type=string
 //+kubebuilder:printcolumn:JSONPath=".spec.autoscalingRunnerSetName",name=AutoscalingRunnerSet Name,type=string
+//+kubebuilder:printcolumn:JSONPath=".status.circuitBreaker.state",name=Circuit Breaker,type=string
 
 // AutoscalingListener is the Schema for the autoscalinglisteners API
 type AutoscalingListener struct {
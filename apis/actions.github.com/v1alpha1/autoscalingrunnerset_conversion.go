@@ -0,0 +1,32 @@
+/*
+Copyright 2023 The actions-runner-controller authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Hub marks v1alpha1 as the version the other versions of AutoscalingRunnerSet are converted to and from.
+// It is the storage version and the version the controllers work with.
+func (*AutoscalingRunnerSet) Hub() {}
+
+// SetupWebhookWithManager registers the conversion webhook of AutoscalingRunnerSet.
+func (ars *AutoscalingRunnerSet) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(ars).
+		Complete()
+}
@@ -17,19 +17,30 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
+
 	"github.com/actions/actions-runner-controller/hash"
+	"golang.org/x/net/http/httpproxy"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
 //+kubebuilder:printcolumn:JSONPath=".spec.minRunners",name=Minimum Runners,type=number
 //+kubebuilder:printcolumn:JSONPath=".spec.maxRunners",name=Maximum Runners,type=number
 //+kubebuilder:printcolumn:JSONPath=".status.currentRunners",name=Current Runners,type=number
 //+kubebuilder:printcolumn:JSONPath=".status.state",name=State,type=string
+//+kubebuilder:printcolumn:JSONPath=".status.runnerPhases.pendingPod",name=Pending Runners,type=number,priority=1
+//+kubebuilder:printcolumn:JSONPath=".status.runnerPhases.idle",name=Idle Runners,type=number,priority=1
+//+kubebuilder:printcolumn:JSONPath=".status.runnerPhases.runningJob",name=Running Jobs,type=number,priority=1
 
 // AutoscalingRunnerSet is the Schema for the autoscalingrunnersets API
 type AutoscalingRunnerSet struct {
@@ -48,30 +59,1084 @@ type AutoscalingRunnerSetSpec struct {
 	// Required
 	GitHubConfigSecret string `json:"githubConfigSecret,omitempty"`
 
+	// RunnerScaleSetName is the name of the runner scale set registered on GitHub,
+	// which jobs target with runs-on. Defaults to the name of the AutoscalingRunnerSet.
+	// Changing it renames the runner scale set.
+	// +optional
+	RunnerScaleSetName string `json:"runnerScaleSetName,omitempty"`
+
 	// +optional
 	RunnerGroup string `json:"runnerGroup,omitempty"`
 
+	// CreateRunnerGroupIfMissing creates the runner group referenced by RunnerGroup
+	// when it does not exist yet instead of failing the reconciliation.
+	// +optional
+	CreateRunnerGroupIfMissing bool `json:"createRunnerGroupIfMissing,omitempty"`
+
+	// Deprecated: RegistrationTokenFallback is ignored. A runner registered with a registration token isn't
+	// a member of the runner scale set, so the jobs acquired for the scale set can't be assigned to it.
+	// Runners whose JIT runner config can't be generated have the RegistrationBlocked condition instead,
+	// and their registration is retried.
+	// +optional
+	RegistrationTokenFallback bool `json:"registrationTokenFallback,omitempty"`
+
+	// Deprecated: PersistentRunners can't be enabled. Persistent runners would be registered outside of the runner
+	// scale set, so the jobs acquired for it couldn't be assigned to them. Enabling them sets the InvalidSpec
+	// condition and stops the reconciliation.
+	// +optional
+	PersistentRunners *PersistentRunners `json:"persistentRunners,omitempty"`
+
 	// +optional
 	Proxy *ProxyConfig `json:"proxy,omitempty"`
 
 	// +optional
 	GitHubServerTLS *GitHubServerTLSConfig `json:"githubServerTLS,omitempty"`
 
+	// +optional
+	FailurePolicy *FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// RetainFailedPods keeps failed runner pods for a while instead of deleting them right away,
+	// to inspect them with kubectl logs or kubectl exec.
+	// +optional
+	RetainFailedPods *RetainFailedPods `json:"retainFailedPods,omitempty"`
+
+	// Hooks run scripts of config maps in the runner container before and after every job.
+	// +optional
+	Hooks *RunnerHooks `json:"hooks,omitempty"`
+
+	// +optional
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// JobCompletionTimeout is how long a runner pod that is running a job keeps running it once it
+	// is deleted, drained from its node or evicted. The runner is then signaled to cancel the job.
+	// It applies to the runners created after it is set, without a rollout.
+	// Defaults to waiting until the service releases the runner when the runner is deleted.
+	// +optional
+	JobCompletionTimeout *metav1.Duration `json:"jobCompletionTimeout,omitempty"`
+
+	// DeletionPolicy controls whether deleting the AutoscalingRunnerSet deletes the runner scale set
+	// registered on GitHub. Retain keeps it, e.g. to move the scale set to another cluster. Defaults to Delete.
+	// +optional
+	// +kubebuilder:validation:Enum=Delete;Retain
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ImagePullSecrets are added to the listener pod and to the runner pods, including their dind sidecar,
+	// on top of the image pull secrets of their templates. The listener runs in the namespace of the controller,
+	// so these secrets are copied there for it.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
 	// Required
 	Template corev1.PodTemplateSpec `json:"template,omitempty"`
 
+	// InitContainers run before the init containers of the template, in order, e.g. to fetch secrets
+	// or warm caches before the runner is set up. The init containers of the runner pods run in this order:
+	// these init containers, the init containers of the template, including the ones of the container mode
+	// generated by the chart, then the containers.
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// ContainerMode is how the runners run the containers of the jobs. In the kubernetes container mode,
+	// the controller creates the service account of the runner pods, with the role the container hooks need
+	// to create the pods of the jobs, unless the template sets a service account.
+	// +optional
+	ContainerMode *ContainerMode `json:"containerMode,omitempty"`
+
+	// WorkVolumeClaimTemplate creates a persistent volume claim per runner for the work directory
+	// of the runner, e.g. for workspaces that don't fit in the ephemeral storage of the nodes.
+	// The claim is deleted with the runner.
+	// +optional
+	WorkVolumeClaimTemplate *corev1.PersistentVolumeClaimTemplate `json:"workVolumeClaimTemplate,omitempty"`
+
+	// ToolCache provisions a persistent volume claim shared by the runners of the scale set for their tool cache,
+	// so that the tools downloaded by the setup actions are reused across runners.
+	// +optional
+	ToolCache *RunnerToolCache `json:"toolCache,omitempty"`
+
+	// DockerLayerCache keeps the Docker data of the dind sidecars on the nodes, so that the runners landing
+	// on the same node reuse the images and build layers pulled and built by the previous runners.
+	// Only use it for trusted workflows: a job can retag images or tamper with layers of the cache, which the
+	// next jobs of the node run. Untrusted workflows should use a pull-through registry mirror instead.
+	// +optional
+	DockerLayerCache *DockerLayerCache `json:"dockerLayerCache,omitempty"`
+
+	// NetworkPolicy generates a NetworkPolicy restricting the egress of the runner pods to DNS, the GitHub server
+	// of the scale set, its proxies and the extra CIDRs, owned by the AutoscalingRunnerSet.
+	// +optional
+	NetworkPolicy *RunnerNetworkPolicy `json:"networkPolicy,omitempty"`
+
+	// RunnerServiceAccount makes the controller create the service account of the runner pods, owned by the
+	// AutoscalingRunnerSet, annotated for the workload identity of a cloud provider so that jobs get cloud
+	// credentials without secrets. A service account set by the template is used as it is.
+	// +optional
+	RunnerServiceAccount *RunnerServiceAccount `json:"runnerServiceAccount,omitempty"`
+
+	// TemplatePatches are applied in order to the runner pods generated from the templates,
+	// to change what the controller adds to them, such as the resources of the dind sidecar.
+	// Patches that cannot be applied are reported with the InvalidSpec condition, and no runners
+	// are created for the spec.
+	// +optional
+	TemplatePatches []PodPatch `json:"templatePatches,omitempty"`
+
+	// TemplateVariants are alternative runner pod templates used for jobs requesting
+	// the labels of the variant. Template is used for all other jobs.
+	// +optional
+	TemplateVariants []TemplateVariant `json:"templateVariants,omitempty"`
+
+	// NodePlacements schedule the runners of jobs requesting the labels of a placement
+	// with its node selector, tolerations, affinity, priority class and images, on top of Template.
+	// +optional
+	NodePlacements []NodePlacement `json:"nodePlacements,omitempty"`
+
+	// Placement spreads or packs the runner pods with topology spread constraints and pod affinities
+	// added to the pod templates, on top of the ones they set.
+	// +optional
+	Placement *RunnerPlacement `json:"placement,omitempty"`
+
+	// ImagePrePull pulls the images of the runner pod template on the nodes the runners
+	// are scheduled on ahead of the runners, so that jobs don't wait for image pulls on new nodes.
+	// +optional
+	ImagePrePull *ImagePrePull `json:"imagePrePull,omitempty"`
+
+	// RunnerVersionTracking compares the runner version of the runner image with the latest
+	// runner release of the GitHub instance, and reports outdated runners in the status.
+	// +optional
+	RunnerVersionTracking *RunnerVersionTracking `json:"runnerVersionTracking,omitempty"`
+
+	// RunnerImageDigestPinning resolves the tag of the runner image to a digest and pins the runner pods to it,
+	// so that all the runners of a runner set run the same image. A new digest rolls out a new runner set.
+	// +optional
+	RunnerImageDigestPinning *RunnerImageDigestPinning `json:"runnerImageDigestPinning,omitempty"`
+
+	// ImageSignatureVerification verifies the cosign signatures of the runner and dind images before rolling out
+	// a new runner set. Runner sets of unsigned images are not created, which the ImageSignatureUnverified
+	// condition reports.
+	// +optional
+	ImageSignatureVerification *ImageSignatureVerification `json:"imageSignatureVerification,omitempty"`
+
 	// +optional
 	// +kubebuilder:validation:Minimum:=0
 	MaxRunners *int `json:"maxRunners,omitempty"`
 
+	// WarmPool keeps idle runners registered ahead of the jobs, so that queued jobs start in seconds.
+	//
+	// Deprecated: use MinIdleRunners, which takes precedence when set.
+	// +optional
+	WarmPool *WarmPool `json:"warmPool,omitempty"`
+
+	// FairShareWeight is the weight of the scale set when the global runner budget of the
+	// controller is distributed across scale sets whose demand exceeds it. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	FairShareWeight *int `json:"fairShareWeight,omitempty"`
+
+	// JobConcurrencyLimits caps the jobs of a single repository or workflow that the scale set
+	// acquires at the same time, so that one repository can't take all the runners.
+	// +optional
+	JobConcurrencyLimits *JobConcurrencyLimits `json:"jobConcurrencyLimits,omitempty"`
+
+	// JobAcquisitionBatchSize is the maximum number of jobs the listener acquires in a single call
+	// to the Actions service. Defaults to 100.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	JobAcquisitionBatchSize *int `json:"jobAcquisitionBatchSize,omitempty"`
+
+	// ListenerTemplate is merged into the generated listener pod. The container named "autoscaler"
+	// customizes the listener container, the other containers are added as they are.
+	// The configuration the controller generates for the listener takes precedence.
+	// +optional
+	ListenerTemplate *corev1.PodTemplateSpec `json:"listenerTemplate,omitempty"`
+
+	// ListenerImage is the image of the listener of this scale set, e.g. a copy of the listener image
+	// in a private registry. Defaults to the listener image of the controller.
+	// +optional
+	ListenerImage string `json:"listenerImage,omitempty"`
+
+	// ListenerImagePullPolicy is the pull policy of the listener image. Defaults to IfNotPresent.
+	// +optional
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	ListenerImagePullPolicy corev1.PullPolicy `json:"listenerImagePullPolicy,omitempty"`
+
 	// +optional
 	// +kubebuilder:validation:Minimum:=0
 	MinRunners *int `json:"minRunners,omitempty"`
+
+	// MinIdleRunners is the number of idle runners kept at all times on top of the runners busy with a job,
+	// scaling above the demand as needed, within MaxRunners. It replaces the deprecated WarmPool.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MinIdleRunners *int `json:"minIdleRunners,omitempty"`
+
+	// ScalingBufferPercent adds a headroom of runners on top of the assigned jobs, as a percentage
+	// of the assigned jobs rounded up, so that a burst of jobs doesn't wait for the next scaling decision.
+	// The runners stay within MaxRunners.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	// +kubebuilder:validation:Maximum:=1000
+	ScalingBufferPercent *int `json:"scalingBufferPercent,omitempty"`
+
+	// ScalingSmoothingPercent smooths scaling down the desired runners computed by the listener with an
+	// exponentially weighted moving average, in which the latest desired runners weigh this percentage, so that
+	// oscillating assigned jobs don't churn the runners. Scaling up isn't smoothed. 100 disables the smoothing.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=100
+	ScalingSmoothingPercent *int `json:"scalingSmoothingPercent,omitempty"`
+
+	// MaxPendingRunners pauses the acquisition of new jobs while more runners than this are waiting for their pod
+	// to run, e.g. because it can't be scheduled, so that the jobs stay queued for other runners meanwhile.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	MaxPendingRunners *int `json:"maxPendingRunners,omitempty"`
+
+	// ScaleDownPolicy selects which idle runners are removed first when scaling down.
+	// Runners running a job are never removed. Defaults to oldest.
+	// +optional
+	// +kubebuilder:validation:Enum=oldest;newest;random
+	ScaleDownPolicy ScaleDownPolicy `json:"scaleDownPolicy,omitempty"`
+
+	// IdleRunnerTimeout scales down the runners that have been idle for longer than the timeout,
+	// even when the desired number of runners would keep them, down to MinRunners.
+	// +optional
+	IdleRunnerTimeout *metav1.Duration `json:"idleRunnerTimeout,omitempty"`
+
+	// CapacityReservations temporarily raise MinRunners by their replicas until they expire,
+	// e.g. to pre-warm runners ahead of a known burst of jobs. Expired reservations are ignored.
+	// +optional
+	CapacityReservations []CapacityReservation `json:"capacityReservations,omitempty"`
+
+	// PredictiveScaling learns when the jobs of the scale set arrive over the week, and keeps the runners
+	// of the predicted demand ahead of it, on top of MinRunners and within MaxRunners.
+	// +optional
+	PredictiveScaling *PredictiveScaling `json:"predictiveScaling,omitempty"`
+
+	// MaintenanceWindows are recurring periods during which the listener is stopped, so that the scale set
+	// doesn't acquire new jobs, e.g. for a planned cluster maintenance. The scale set resumes once they end.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// ClusterCapacity caps the runners to the capacity left on the nodes they can be scheduled on,
+	// so that no runner pods are created that the cluster can't run, e.g. when the node autoscaler reached its limits.
+	// +optional
+	ClusterCapacity *ClusterCapacity `json:"clusterCapacity,omitempty"`
+
+	// OverflowTarget is the name of another AutoscalingRunnerSet in the same namespace that
+	// runs the jobs of this scale set above MaxRunners. The name of this scale set is added to
+	// the labels of the target's runner scale set, and the jobs above MaxRunners are left for it to acquire,
+	// so that they run on runners registered with the target's scale set and within its MaxRunners.
+	// +optional
+	OverflowTarget string `json:"overflowTarget,omitempty"`
+}
+
+// RunnerPlacementSpread is the topology the runner pods are spread across.
+type RunnerPlacementSpread string
+
+const (
+	// RunnerPlacementSpreadZone spreads the runner pods evenly across the zones of the nodes.
+	RunnerPlacementSpreadZone RunnerPlacementSpread = "zone"
+	// RunnerPlacementSpreadNode spreads the runner pods evenly across the nodes.
+	RunnerPlacementSpreadNode RunnerPlacementSpread = "node"
+	// RunnerPlacementSpreadNone doesn't spread the runner pods.
+	RunnerPlacementSpreadNone RunnerPlacementSpread = "none"
+)
+
+// RunnerPlacementPacking is how the runner pods are packed on the nodes.
+type RunnerPlacementPacking string
+
+const (
+	// RunnerPlacementPackingBinpack prefers the nodes already running runner pods of the scale set,
+	// so that the cluster autoscaler can remove the nodes left empty.
+	RunnerPlacementPackingBinpack RunnerPlacementPacking = "binpack"
+	// RunnerPlacementPackingSpread prefers the nodes not running runner pods of the scale set,
+	// so that busy jobs don't compete for the resources of a node.
+	RunnerPlacementPackingSpread RunnerPlacementPacking = "spread"
+)
+
+// RunnerPlacement is a preset of the topology spread constraints and pod affinities of the runner pods.
+// Both are preferences: runner pods are still scheduled when they can't be satisfied.
+type RunnerPlacement struct {
+	// Spread is the topology the runner pods are spread across. Defaults to none.
+	// +optional
+	// +kubebuilder:validation:Enum=zone;node;none
+	Spread RunnerPlacementSpread `json:"spread,omitempty"`
+
+	// Packing is how the runner pods are packed on the nodes. Defaults to the scheduler default.
+	// +optional
+	// +kubebuilder:validation:Enum=binpack;spread
+	Packing RunnerPlacementPacking `json:"packing,omitempty"`
+}
+
+// ImagePrePull is a DaemonSet pulling the images of the runner pod template and of its variants,
+// scheduled with the node selector, tolerations and affinity of the template. The variants scheduled
+// on other nodes get their own DaemonSet. The images are pulled by running a command copied from the
+// listener image, so that images without a shell are pulled too. The DaemonSets are updated whenever
+// the images of the templates change.
+type ImagePrePull struct {
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// PauseImage is the image of the container keeping the pods of the DaemonSet running
+	// once the images are pulled. Defaults to registry.k8s.io/pause:3.9.
+	// +optional
+	PauseImage string `json:"pauseImage,omitempty"`
+}
+
+// RunnerVersionTracking reports runners older than the latest runner release of the GitHub instance
+// with the RunnerVersionOutdated condition. The runner version is read from the tag of the runner image.
+type RunnerVersionTracking struct {
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// RefreshIdleRunners replaces the idle runners once a newer runner release is available,
+	// so that runner images with a mutable tag, such as latest, pick it up. Busy runners finish their job first.
+	// Runner images tagged with a runner version are not refreshed, as their tag has to be updated instead.
+	// +optional
+	RefreshIdleRunners bool `json:"refreshIdleRunners,omitempty"`
+
+	// MinimumVersion is the oldest runner version the GitHub instance accepts jobs from.
+	// The Actions service does not publish it, so runners too old to accept jobs are only reported when it is set.
+	// +optional
+	MinimumVersion string `json:"minimumVersion,omitempty"`
+}
+
+// RunnerImageDigestPinning pins the runner pods to the digest the tag of the runner image points to.
+// The tag is resolved with the image pull secrets of the runner pod template.
+type RunnerImageDigestPinning struct {
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// RefreshInterval is how often the tag is resolved again. Defaults to 1h.
+	// +optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// ImageSignatureVerification is what the cosign signatures of the runner and dind images have to satisfy.
+// Exactly one of PublicKey and Keyless is set. The images are read with the image pull secrets of the runner
+// pod template, and the runner pods run the verified digests of the images.
+type ImageSignatureVerification struct {
+	// PublicKey is the PEM encoded public key the images are signed with, as by cosign sign --key.
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// RekorPublicKey is the PEM encoded public key of the Rekor transparency log the signatures made with PublicKey
+	// are logged to. Required with PublicKey: signatures without a bundle of the log are not trusted.
+	// +optional
+	RekorPublicKey string `json:"rekorPublicKey,omitempty"`
+
+	// Keyless verifies the signatures made with the short-lived certificates of Fulcio, as by cosign keyless signing.
+	// +optional
+	Keyless *KeylessSignatureVerification `json:"keyless,omitempty"`
+}
+
+// KeylessSignatureVerification is the identity signing the images with cosign keyless signing, and the Fulcio
+// and Rekor instances trusted to vouch for it.
+type KeylessSignatureVerification struct {
+	// Issuer is the OIDC issuer of the identity, e.g. https://token.actions.githubusercontent.com.
+	Issuer string `json:"issuer"`
+
+	// Subject is the email or URI of the identity, e.g. the workflow signing the images.
+	// +optional
+	Subject string `json:"subject,omitempty"`
+
+	// SubjectRegExp matches the whole email or URI of the identity, in place of Subject.
+	// +optional
+	SubjectRegExp string `json:"subjectRegExp,omitempty"`
+
+	// FulcioCertificates are the PEM encoded root and intermediate certificates of Fulcio.
+	FulcioCertificates string `json:"fulcioCertificates"`
+
+	// RekorPublicKey is the PEM encoded public key of the Rekor transparency log the signatures are logged to.
+	RekorPublicKey string `json:"rekorPublicKey"`
+}
+
+// WarmPool is a pool of idle runners kept on top of the runners busy with a job. Deprecated in favor of MinIdleRunners.
+// Its runners are registered with their JIT config as any other runner, and the pool
+// is replenished as soon as jobs are assigned to them.
+type WarmPool struct {
+	// Size is the number of idle runners in the pool. Runners of the pool count towards MaxRunners.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	Size int `json:"size,omitempty"`
+}
+
+// CapacityReservation is a number of runners kept on top of MinRunners until the expiration time.
+type CapacityReservation struct {
+	// Name identifies the reservation, e.g. the system that made it.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Replicas is the number of runners reserved.
+	// +kubebuilder:validation:Minimum:=1
+	Replicas int `json:"replicas"`
+
+	// ExpirationTime is the time the reservation ends.
+	ExpirationTime metav1.Time `json:"expirationTime"`
+}
+
+// PredictiveScalingMode is whether the predicted runners are kept or only recommended.
+type PredictiveScalingMode string
+
+const (
+	// PredictiveScalingModeEnabled keeps the predicted runners.
+	PredictiveScalingModeEnabled PredictiveScalingMode = "Enabled"
+
+	// PredictiveScalingModeRecommendOnly reports the predicted runners in the status without keeping them,
+	// to evaluate the predictions before enabling them.
+	PredictiveScalingModeRecommendOnly PredictiveScalingMode = "RecommendOnly"
+)
+
+// PredictiveScaling learns the peak of assigned jobs of every hour of the week, and scales ahead of it.
+// The history is kept in a config map next to the autoscaling runner set.
+type PredictiveScaling struct {
+	// Mode is Enabled or RecommendOnly. Defaults to Enabled.
+	// +optional
+	// +kubebuilder:validation:Enum=Enabled;RecommendOnly
+	Mode PredictiveScalingMode `json:"mode,omitempty"`
+
+	// LeadTime is how long ahead of the predicted demand the runners are kept, which should cover
+	// the time the runners take to start. Defaults to 10m.
+	// +optional
+	LeadTime *metav1.Duration `json:"leadTime,omitempty"`
+}
+
+// ClusterCapacity is the cap of the runners to the schedulable capacity of the cluster.
+// The capacity is the allocatable resources of the nodes matching the node selector and tolerations of the
+// runner pod template, minus the requests of the pods running on them. Runners that already exist are never removed.
+type ClusterCapacity struct {
+	// Enabled caps the runners to the schedulable capacity.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// UnschedulableRunners is the number of runners created above the schedulable capacity,
+	// so that a node autoscaler sees pending runner pods and adds nodes. Defaults to 0.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	UnschedulableRunners *int `json:"unschedulableRunners,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period during which the scale set doesn't acquire new jobs.
+type MaintenanceWindow struct {
+	// Schedule is when the window starts, as a cron expression with five fields:
+	// minute, hour, day of month, month and day of week, e.g. "0 2 * * 6" for every Saturday at 02:00.
+	// +kubebuilder:validation:MinLength:=1
+	Schedule string `json:"schedule"`
+
+	// TimeZone is the IANA name of the time zone of the schedule, e.g. "Europe/Berlin". Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Duration is how long the window lasts.
+	Duration metav1.Duration `json:"duration"`
+
+	// DrainRunners scales the runners down to zero during the window.
+	// Runners already running a job finish it.
+	// +optional
+	DrainRunners bool `json:"drainRunners,omitempty"`
+}
+
+// JobConcurrencyLimits are the limits the listener enforces when acquiring jobs.
+// Jobs above a limit wait in the queue until jobs of the same repository or workflow complete.
+type JobConcurrencyLimits struct {
+	// MaxJobsPerRepository is the maximum number of jobs of a repository acquired at the same time.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	MaxJobsPerRepository *int `json:"maxJobsPerRepository,omitempty"`
+
+	// MaxJobsPerWorkflow is the maximum number of jobs of a workflow file acquired at the same time,
+	// regardless of the ref the workflow runs on.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	MaxJobsPerWorkflow *int `json:"maxJobsPerWorkflow,omitempty"`
 }
 
 type GitHubServerTLSConfig struct {
 	// Required
 	RootCAsConfigMapRef string `json:"certConfigMapRef,omitempty"`
+
+	// Key of the ConfigMap entry holding the PEM encoded CA bundle that is mounted
+	// into runner pods. Defaults to "ca.crt".
+	// +optional
+	RootCAsConfigMapKey string `json:"certConfigMapKey,omitempty"`
+}
+
+// RunnerToolCache is the tool cache shared by the runners of a scale set.
+type RunnerToolCache struct {
+	// Spec of the persistent volume claim of the tool cache. Its access modes default to ReadWriteMany.
+	// Increasing its storage request expands the claim.
+	// Required
+	Spec corev1.PersistentVolumeClaimSpec `json:"spec,omitempty"`
+
+	// ReadOnly mounts the tool cache read-only, for tool caches populated ahead of the runners.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// SubPathExpr mounts a subdirectory of the tool cache, expanded with the environment variables
+	// of the runner container, e.g. to give each node its own subdirectory.
+	// +optional
+	SubPathExpr string `json:"subPathExpr,omitempty"`
+}
+
+// RunnerNetworkPolicy is the egress allowed to the runner pods of a scale set. The addresses of the GitHub server
+// are resolved by the controller: the ranges of the meta API for GitHub.com, and the addresses of the host for
+// GitHub Enterprise Server. They are resolved again every hour.
+type RunnerNetworkPolicy struct {
+	// Enabled generates the NetworkPolicy, and labels the runner pods it selects.
+	Enabled bool `json:"enabled"`
+
+	// ExtraEgressCIDRs are allowed on every port, e.g. the package registries and services the jobs use.
+	// +optional
+	ExtraEgressCIDRs []string `json:"extraEgressCIDRs,omitempty"`
+}
+
+// RunnerServiceAccount is the service account the controller creates for the runner pods of a scale set,
+// named after the AutoscalingRunnerSet with a -runner suffix. With the kubernetes container mode, the
+// service account of the container hooks is annotated instead.
+type RunnerServiceAccount struct {
+	// Annotations are added to the service account, e.g. for a workload identity the fields below don't cover.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// AWS annotates the service account with the IAM role the jobs assume through IAM roles for service accounts.
+	// +optional
+	AWS *AWSWorkloadIdentity `json:"aws,omitempty"`
+
+	// GCP annotates the service account with the Google service account the jobs impersonate through
+	// GKE Workload Identity.
+	// +optional
+	GCP *GCPWorkloadIdentity `json:"gcp,omitempty"`
+
+	// Azure annotates the service account with the managed identity the jobs federate with through
+	// Azure AD Workload Identity, and labels the runner pods for its webhook.
+	// +optional
+	Azure *AzureWorkloadIdentity `json:"azure,omitempty"`
+}
+
+// AWSWorkloadIdentity is the IAM role of the runner service account.
+type AWSWorkloadIdentity struct {
+	// RoleARN is the ARN of the IAM role, set as the eks.amazonaws.com/role-arn annotation.
+	RoleARN string `json:"roleARN"`
+}
+
+// GCPWorkloadIdentity is the Google service account of the runner service account.
+type GCPWorkloadIdentity struct {
+	// ServiceAccount is the email of the Google service account, set as the iam.gke.io/gcp-service-account annotation.
+	ServiceAccount string `json:"serviceAccount"`
+}
+
+// AzureWorkloadIdentity is the managed identity of the runner service account.
+type AzureWorkloadIdentity struct {
+	// ClientID is the client ID of the managed identity or application, set as the
+	// azure.workload.identity/client-id annotation.
+	ClientID string `json:"clientID"`
+
+	// TenantID is the tenant of the identity, set as the azure.workload.identity/tenant-id annotation.
+	// Defaults to the tenant the webhook is configured with.
+	// +optional
+	TenantID string `json:"tenantID,omitempty"`
+}
+
+// DockerLayerCache is the node-local cache of the Docker data of the dind sidecars of a scale set.
+// A Docker daemon needs exclusive use of its data, so each node holds a few cache slots that the dind
+// sidecars lock for the lifetime of their pod. Sidecars finding no free slot start with an empty cache.
+type DockerLayerCache struct {
+	// HostPath is the directory of the nodes holding the caches, in a subdirectory per scale set.
+	// Defaults to /var/lib/actions-runner-controller/docker-layer-cache.
+	// +optional
+	HostPath string `json:"hostPath,omitempty"`
+
+	// MaxCachesPerNode is the number of cache slots of each node, bounding the disk used on a node
+	// and the number of runners of the node that start with a warm cache. Defaults to 4.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxCachesPerNode *int `json:"maxCachesPerNode,omitempty"`
+
+	// SizeLimit wipes a cache slot before it is used once it grows beyond the limit.
+	// +optional
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+// RunnerHooks are the scripts the runners run before and after every job, e.g. to set up
+// or clean up credentials and caches.
+type RunnerHooks struct {
+	// JobStarted runs before every job, as ACTIONS_RUNNER_HOOK_JOB_STARTED.
+	// +optional
+	JobStarted *RunnerHookScript `json:"jobStarted,omitempty"`
+
+	// JobCompleted runs after every job, as ACTIONS_RUNNER_HOOK_JOB_COMPLETED.
+	// +optional
+	JobCompleted *RunnerHookScript `json:"jobCompleted,omitempty"`
+}
+
+// RunnerHookScript is a script stored in a config map in the namespace of the runners.
+type RunnerHookScript struct {
+	// Required
+	ConfigMapRef string `json:"configMapRef,omitempty"`
+
+	// Key of the ConfigMap entry holding the script. The runner runs .sh scripts with bash
+	// and .ps1 scripts with PowerShell.
+	// Required
+	ConfigMapKey string `json:"configMapKey,omitempty"`
+}
+
+// PersistentRunners are runners that stay registered across jobs. They aren't supported by runner scale sets:
+// they would be registered with a registration token, like self-hosted runners outside of scale sets.
+type PersistentRunners struct {
+	// Enabled registers the runners as persistent runners.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// MaxLifetime is how long a persistent runner runs jobs before it is recycled. Defaults to 24 hours.
+	// +optional
+	MaxLifetime *metav1.Duration `json:"maxLifetime,omitempty"`
+
+	// RecycleSchedule recycles the persistent runners at the starts of the schedule, as a cron expression
+	// with five fields, e.g. "0 3 * * *" for every day at 03:00.
+	// +optional
+	RecycleSchedule string `json:"recycleSchedule,omitempty"`
+
+	// TimeZone is the IANA name of the time zone of the recycle schedule, e.g. "Europe/Berlin". Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ContainerModeType is how the runners run the containers of the jobs.
+// +kubebuilder:validation:Enum=dind;kubernetes;podman
+type ContainerModeType string
+
+const (
+	// ContainerModeTypeDind runs the containers in a Docker in Docker sidecar.
+	ContainerModeTypeDind ContainerModeType = "dind"
+	// ContainerModeTypeKubernetes runs the containers in pods of their own, created by the container hooks.
+	ContainerModeTypeKubernetes ContainerModeType = "kubernetes"
+	// ContainerModeTypePodman runs the containers in a rootless Podman sidecar.
+	ContainerModeTypePodman ContainerModeType = "podman"
+)
+
+// ContainerMode is the container mode of the runners.
+type ContainerMode struct {
+	Type ContainerModeType `json:"type"`
+
+	// HookTemplate is the hook extension template of the kubernetes container mode, merged by the container
+	// hooks into the pods of the jobs, e.g. to govern their security context, resources and volumes centrally.
+	// The controller mounts it into the runner container, as ACTIONS_RUNNER_CONTAINER_HOOK_TEMPLATE.
+	// +optional
+	HookTemplate *ContainerHookTemplate `json:"hookTemplate,omitempty"`
+
+	// JobResources are the resources of the job containers of the kubernetes container mode. The requests are
+	// the defaults of the job containers, used unless the hook template requests less. The limits are the most
+	// the job containers can use, and cap the requests of the hook template. The controller generates the hook
+	// template of the runners from the hook template and these resources.
+	// +optional
+	JobResources *corev1.ResourceRequirements `json:"jobResources,omitempty"`
+}
+
+// ContainerHookTemplate is a hook extension template stored in a config map in the namespace of the runners.
+type ContainerHookTemplate struct {
+	// Required
+	ConfigMapRef string `json:"configMapRef,omitempty"`
+
+	// Key of the ConfigMap entry holding the template, a pod spec in YAML. Defaults to template.yaml.
+	// +optional
+	ConfigMapKey string `json:"configMapKey,omitempty"`
+}
+
+// RetainFailedPods is how failed runner pods are kept for debugging. The runner of a retained pod is retried
+// once the pod is deleted.
+type RetainFailedPods struct {
+	// Duration is how long a failed runner pod is kept. Defaults to 1 hour.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Count is how many failed runner pods of the runner set are kept at the same time. The pods failing
+	// while as many are kept are deleted right away. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	Count *int `json:"count,omitempty"`
+}
+
+// FailurePolicy controls how runner pods that fail to start are retried.
+type FailurePolicy struct {
+	// MaxFailures is the number of pod failures tolerated. The runner is marked as failed
+	// when its pod fails once more. Changes apply to the existing runners without a rollout.
+	// Defaults to 5.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxFailures *int `json:"maxFailures,omitempty"`
+
+	// BackoffDuration is the delay before re-creating the pod after a failure.
+	// It is doubled on every consecutive failure, up to 10 minutes.
+	// Defaults to no delay.
+	// +optional
+	BackoffDuration *metav1.Duration `json:"backoffDuration,omitempty"`
+
+	// ResetWindow is the time without failures after which the failure count is reset.
+	// Defaults to never resetting the failure count.
+	// +optional
+	ResetWindow *metav1.Duration `json:"resetWindow,omitempty"`
+
+	// PendingTimeout is how long a runner pod may stay Pending, e.g. because no node can fit it.
+	// The pod is then deleted as failed with the reason of the scheduler, and re-created.
+	// Defaults to no timeout.
+	// +optional
+	PendingTimeout *metav1.Duration `json:"pendingTimeout,omitempty"`
+
+	// RelaxSchedulingOnPendingTimeout re-creates the pods that timed out pending without their
+	// preferred and pod affinities, and with topology spread constraints that don't block scheduling.
+	// +optional
+	RelaxSchedulingOnPendingTimeout bool `json:"relaxSchedulingOnPendingTimeout,omitempty"`
+}
+
+// TemplateVariant is a runner pod template selected by the labels a job requests.
+//
+// The labels of all variants are added to the runner scale set so that jobs requesting
+// them are routed to it. Runners are created from a variant based on the labels of the
+// jobs assigned to the scale set, but the service may still hand a job to any idle
+// runner of the scale set.
+type TemplateVariant struct {
+	// Required
+	Name string `json:"name,omitempty"`
+
+	// Labels a job has to request for the variant to be used. When several variants
+	// match a job, the one with the most labels wins.
+	// Required
+	Labels []string `json:"labels,omitempty"`
+
+	// Required
+	Template corev1.PodTemplateSpec `json:"template,omitempty"`
+}
+
+// NodePlacement is the node placement of the runners serving jobs that request its labels.
+//
+// Each placement is turned into a template variant based on the default pod template,
+// so the labels and naming rules of TemplateVariant apply, and its name must not be
+// used by any template variant.
+type NodePlacement struct {
+	// Required
+	Name string `json:"name,omitempty"`
+
+	// Labels a job has to request for the placement to be used.
+	// Required
+	Labels []string `json:"labels,omitempty"`
+
+	// NodeSelector is merged into the node selector of the pod template.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are added to the tolerations of the pod template.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity replaces the affinity of the pod template.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName replaces the priority class of the pod template, so that the
+	// scheduler preempts the runners of less important jobs first under pressure.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Images replace the images of the containers and init containers of the pod template,
+	// by container name, e.g. to run the images of the architecture the placement selects
+	// with the kubernetes.io/arch node label.
+	// +optional
+	Images map[string]string `json:"images,omitempty"`
+}
+
+// apply returns a copy of the pod template scheduled according to the placement.
+func (p *NodePlacement) apply(template *corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	placed := template.DeepCopy()
+
+	if len(p.NodeSelector) > 0 {
+		if placed.Spec.NodeSelector == nil {
+			placed.Spec.NodeSelector = make(map[string]string, len(p.NodeSelector))
+		}
+		for k, v := range p.NodeSelector {
+			placed.Spec.NodeSelector[k] = v
+		}
+	}
+
+	for _, toleration := range p.Tolerations {
+		placed.Spec.Tolerations = append(placed.Spec.Tolerations, *toleration.DeepCopy())
+	}
+
+	if p.Affinity != nil {
+		placed.Spec.Affinity = p.Affinity.DeepCopy()
+	}
+
+	if p.PriorityClassName != "" {
+		placed.Spec.PriorityClassName = p.PriorityClassName
+		// The priority is resolved from the class on admission.
+		placed.Spec.Priority = nil
+	}
+
+	if len(p.Images) > 0 {
+		for i := range placed.Spec.InitContainers {
+			if image, ok := p.Images[placed.Spec.InitContainers[i].Name]; ok {
+				placed.Spec.InitContainers[i].Image = image
+			}
+		}
+		for i := range placed.Spec.Containers {
+			if image, ok := p.Images[placed.Spec.Containers[i].Name]; ok {
+				placed.Spec.Containers[i].Image = image
+			}
+		}
+	}
+
+	return *placed
+}
+
+// RunnerScaleSetName returns the name of the runner scale set registered on GitHub.
+func (ars *AutoscalingRunnerSet) RunnerScaleSetName() string {
+	if ars.Spec.RunnerScaleSetName != "" {
+		return ars.Spec.RunnerScaleSetName
+	}
+	return ars.Name
+}
+
+// RunnerTemplateVariants returns the template variants of the runners, including
+// the ones of the node placements.
+func (ars *AutoscalingRunnerSet) RunnerTemplateVariants() []TemplateVariant {
+	if len(ars.Spec.NodePlacements) == 0 {
+		return ars.Spec.TemplateVariants
+	}
+
+	variants := make([]TemplateVariant, 0, len(ars.Spec.TemplateVariants)+len(ars.Spec.NodePlacements))
+	variants = append(variants, ars.Spec.TemplateVariants...)
+	for i := range ars.Spec.NodePlacements {
+		placement := &ars.Spec.NodePlacements[i]
+		variants = append(variants, TemplateVariant{
+			Name:     placement.Name,
+			Labels:   placement.Labels,
+			Template: placement.apply(&ars.Spec.Template),
+		})
+	}
+	return variants
+}
+
+// TemplateVariantLabels returns the labels of each template variant keyed by the variant name.
+func (ars *AutoscalingRunnerSet) TemplateVariantLabels() map[string][]string {
+	variants := ars.RunnerTemplateVariants()
+	if len(variants) == 0 {
+		return nil
+	}
+	labels := make(map[string][]string, len(variants))
+	for _, variant := range variants {
+		labels[variant.Name] = variant.Labels
+	}
+	return labels
+}
+
+// DeletionPolicy controls what happens to the runner scale set registered on GitHub
+// when the AutoscalingRunnerSet is deleted.
+type DeletionPolicy string
+
+const (
+	// DeleteDeletionPolicy deletes the runner scale set along with the AutoscalingRunnerSet.
+	DeleteDeletionPolicy DeletionPolicy = "Delete"
+
+	// RetainDeletionPolicy leaves the runner scale set registered, so that another
+	// AutoscalingRunnerSet with the same runner scale set name picks it up.
+	RetainDeletionPolicy DeletionPolicy = "Retain"
+)
+
+// ScaleDownPolicy selects the idle runners removed first when the number of runners is scaled down.
+type ScaleDownPolicy string
+
+const (
+	// ScaleDownPolicyOldest removes the idle runners created first.
+	ScaleDownPolicyOldest ScaleDownPolicy = "oldest"
+
+	// ScaleDownPolicyNewest removes the idle runners created last.
+	ScaleDownPolicyNewest ScaleDownPolicy = "newest"
+
+	// ScaleDownPolicyRandom removes idle runners in random order.
+	ScaleDownPolicyRandom ScaleDownPolicy = "random"
+)
+
+type UpdateStrategyType string
+
+const (
+	// RecreateUpdateStrategyType deletes the old EphemeralRunnerSet as soon as
+	// the runner spec changes.
+	RecreateUpdateStrategyType UpdateStrategyType = "Recreate"
+
+	// RollingUpdateStrategyType gradually replaces runners of the old
+	// EphemeralRunnerSet with runners of the new one.
+	RollingUpdateStrategyType UpdateStrategyType = "RollingUpdate"
+
+	// CanaryUpdateStrategyType moves a share of the runners to the new EphemeralRunnerSet,
+	// promotes it once its runners complete jobs successfully, and rolls it back on failures.
+	CanaryUpdateStrategyType UpdateStrategyType = "Canary"
+
+	// BlueGreenUpdateStrategyType keeps the old EphemeralRunnerSet serving jobs until the new one
+	// has enough running runners, then replaces it at once.
+	BlueGreenUpdateStrategyType UpdateStrategyType = "BlueGreen"
+)
+
+// UpdateStrategy controls how runners are replaced when the runner spec changes.
+type UpdateStrategy struct {
+	// Type of the update. Defaults to Recreate.
+	// +optional
+	// +kubebuilder:validation:Enum=Recreate;RollingUpdate;Canary;BlueGreen
+	Type UpdateStrategyType `json:"type,omitempty"`
+
+	// +optional
+	RollingUpdate *RollingUpdateStrategy `json:"rollingUpdate,omitempty"`
+
+	// +optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+
+	// +optional
+	BlueGreen *BlueGreenStrategy `json:"blueGreen,omitempty"`
+
+	// ProgressDeadlineSeconds is how long the runners of a new EphemeralRunnerSet may take to get running.
+	// Once it passes without a running runner, the new EphemeralRunnerSet is deleted and the previous one
+	// serves the jobs again, until the runner spec changes. It needs an update strategy that keeps the
+	// previous EphemeralRunnerSet during the rollout, so it is ignored by Recreate.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int `json:"progressDeadlineSeconds,omitempty"`
+}
+
+type BlueGreenStrategy struct {
+	// MinReadyRunners is the number or percentage of desired runners that have to be running
+	// in the new EphemeralRunnerSet before the old one is replaced. Defaults to 100%.
+	// +optional
+	MinReadyRunners *intstr.IntOrString `json:"minReadyRunners,omitempty"`
+}
+
+type CanaryStrategy struct {
+	// Weight is the number or percentage of desired runners moved to the new EphemeralRunnerSet
+	// while it is evaluated, rounded up to at least one runner. Defaults to 10%.
+	// +optional
+	Weight *intstr.IntOrString `json:"weight,omitempty"`
+
+	// SuccessfulJobs is the number of jobs the runners of the new EphemeralRunnerSet have to complete
+	// successfully before it gets all the runners. Defaults to 3.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SuccessfulJobs *int `json:"successfulJobs,omitempty"`
+
+	// FailureThreshold is the number of failed runners and failed jobs of the new EphemeralRunnerSet
+	// that roll it back. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int `json:"failureThreshold,omitempty"`
+}
+
+type RollingUpdateStrategy struct {
+	// MaxSurge is the number or percentage of desired runners that can be
+	// created above the desired count during the update. Defaults to 25%.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// MaxUnavailable is the number or percentage of desired runners that can be
+	// unavailable during the update. Defaults to 25%.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// MaxScaleEvents is the number of scale decisions kept in the status of an AutoscalingRunnerSet.
+const MaxScaleEvents = 20
+
+// ScaleEvent is a change of the number of runners an EphemeralRunnerSet scales to.
+type ScaleEvent struct {
+	// Time is when the EphemeralRunnerSet scaled.
+	Time metav1.Time `json:"time"`
+
+	// EphemeralRunnerSetName is the name of the EphemeralRunnerSet that scaled.
+	EphemeralRunnerSetName string `json:"ephemeralRunnerSetName"`
+
+	// PreviousRunners is the number of runners the EphemeralRunnerSet scaled to before.
+	// +optional
+	PreviousRunners int `json:"previousRunners"`
+
+	// DesiredRunners is the number of runners the EphemeralRunnerSet scaled to.
+	// +optional
+	DesiredRunners int `json:"desiredRunners"`
+
+	// Trigger is what decided the number of runners.
+	Trigger ScaleTrigger `json:"trigger"`
+}
+
+// ScaleTrigger is what decided the number of runners of an EphemeralRunnerSet.
+type ScaleTrigger string
+
+const (
+	// ScaleTriggerJobsAssigned is the listener scaling for the jobs assigned to the scale set.
+	ScaleTriggerJobsAssigned ScaleTrigger = "JobsAssigned"
+
+	// ScaleTriggerMinRunners is the listener keeping the min runners of the scale set.
+	ScaleTriggerMinRunners ScaleTrigger = "MinRunners"
+
+	// ScaleTriggerMaxRunners is the listener capping the runners for the assigned jobs to the max runners.
+	ScaleTriggerMaxRunners ScaleTrigger = "MaxRunners"
+
+	// ScaleTriggerWorkflowJobWebhook is a queued workflow job delivered to the workflow job webhook.
+	ScaleTriggerWorkflowJobWebhook ScaleTrigger = "WorkflowJobWebhook"
+
+	// ScaleTriggerIdleTimeout is the scale down of the runners idle for longer than the idle runner timeout.
+	ScaleTriggerIdleTimeout ScaleTrigger = "IdleTimeout"
+
+	// ScaleTriggerMaintenanceWindow is the drain of the runners for a maintenance window.
+	ScaleTriggerMaintenanceWindow ScaleTrigger = "MaintenanceWindow"
+
+	// ScaleTriggerReservedRunners is a capacity reservation or the predicted demand.
+	ScaleTriggerReservedRunners ScaleTrigger = "ReservedRunners"
+
+	// ScaleTriggerWarmPool is the warm pool kept idle on top of the busy runners.
+	ScaleTriggerWarmPool ScaleTrigger = "WarmPool"
+
+	// ScaleTriggerRollout is the limit of the runners of an EphemeralRunnerSet during a rollout.
+	ScaleTriggerRollout ScaleTrigger = "Rollout"
+
+	// ScaleTriggerBudget is the share of the runner budget of the EphemeralRunnerSet.
+	ScaleTriggerBudget ScaleTrigger = "Budget"
+
+	// ScaleTriggerClusterCapacity is the capacity left on the nodes of the cluster.
+	ScaleTriggerClusterCapacity ScaleTrigger = "ClusterCapacity"
+
+	// ScaleTriggerUnknown is a change of the replicas of the EphemeralRunnerSet by something else, e.g. kubectl.
+	ScaleTriggerUnknown ScaleTrigger = "Unknown"
+)
+
+// CanaryPhase is the phase of a canary rollout.
+type CanaryPhase string
+
+const (
+	// CanaryPhaseProgressing means that the new EphemeralRunnerSet gets a share of the runners until it is evaluated.
+	CanaryPhaseProgressing CanaryPhase = "Progressing"
+
+	// CanaryPhasePromoted means that the runners of the new EphemeralRunnerSet completed enough jobs,
+	// and that it replaced the old EphemeralRunnerSets.
+	CanaryPhasePromoted CanaryPhase = "Promoted"
+
+	// CanaryPhaseRolledBack means that the runners of the new EphemeralRunnerSet failed, and that it was deleted.
+	// The runner spec is not rolled out again until it changes.
+	CanaryPhaseRolledBack CanaryPhase = "RolledBack"
+)
+
+// CanaryStatus is the state of a canary rollout.
+type CanaryStatus struct {
+	// SpecHash is the runner spec hash of the new EphemeralRunnerSet.
+	SpecHash string `json:"specHash,omitempty"`
+
+	// EphemeralRunnerSetName is the name of the new EphemeralRunnerSet.
+	// +optional
+	EphemeralRunnerSetName string `json:"ephemeralRunnerSetName,omitempty"`
+
+	Phase CanaryPhase `json:"phase,omitempty"`
+
+	// SucceededJobs is the number of jobs the runners of the new EphemeralRunnerSet completed successfully.
+	// +optional
+	SucceededJobs int `json:"succeededJobs,omitempty"`
+
+	// Failures is the number of failed runners and failed jobs of the new EphemeralRunnerSet.
+	// +optional
+	Failures int `json:"failures,omitempty"`
+
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 type ProxyConfig struct {
@@ -93,6 +1158,52 @@ type ProxyServerConfig struct {
 	NoProxy []string `json:"noProxy,omitempty"`
 }
 
+// ToHTTPProxyConfig converts the proxy config into the config used by HTTP clients.
+// secretFetcher returns the secret referenced by CredentialSecretRef, whose
+// username and password keys are added to the proxy URL.
+func (c *ProxyConfig) ToHTTPProxyConfig(secretFetcher func(string) (*corev1.Secret, error)) (*httpproxy.Config, error) {
+	config := &httpproxy.Config{}
+	var noProxy []string
+
+	if c.HTTP != nil {
+		u, err := c.HTTP.proxyURL(secretFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build http proxy url: %v", err)
+		}
+		config.HTTPProxy = u
+		noProxy = append(noProxy, c.HTTP.NoProxy...)
+	}
+
+	if c.HTTPS != nil {
+		u, err := c.HTTPS.proxyURL(secretFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build https proxy url: %v", err)
+		}
+		config.HTTPSProxy = u
+		noProxy = append(noProxy, c.HTTPS.NoProxy...)
+	}
+
+	config.NoProxy = strings.Join(noProxy, ",")
+	return config, nil
+}
+
+func (c *ProxyServerConfig) proxyURL(secretFetcher func(string) (*corev1.Secret, error)) (string, error) {
+	u, err := url.Parse(c.Url)
+	if err != nil {
+		return "", err
+	}
+
+	if c.CredentialSecretRef != "" {
+		secret, err := secretFetcher(c.CredentialSecretRef)
+		if err != nil {
+			return "", fmt.Errorf("failed to get proxy credentials secret %q: %v", c.CredentialSecretRef, err)
+		}
+		u.User = url.UserPassword(string(secret.Data["username"]), string(secret.Data["password"]))
+	}
+
+	return u.String(), nil
+}
+
 // AutoscalingRunnerSetStatus defines the observed state of AutoscalingRunnerSet
 type AutoscalingRunnerSetStatus struct {
 	// +optional
@@ -100,33 +1211,267 @@ type AutoscalingRunnerSetStatus struct {
 
 	// +optional
 	State string `json:"state,omitempty"`
+
+	// RunnerVersion is the runner version of the runner image, when its tag is a runner version.
+	// +optional
+	RunnerVersion string `json:"runnerVersion,omitempty"`
+
+	// LatestRunnerVersion is the latest runner version of the GitHub instance, when runner version tracking is enabled.
+	// +optional
+	LatestRunnerVersion string `json:"latestRunnerVersion,omitempty"`
+
+	// ResolvedRunnerImage is the runner image whose tag was resolved to RunnerImageDigest.
+	// +optional
+	ResolvedRunnerImage string `json:"resolvedRunnerImage,omitempty"`
+
+	// RunnerImageDigest is the digest the runner pods are pinned to, when runner image digest pinning is enabled.
+	// +optional
+	RunnerImageDigest string `json:"runnerImageDigest,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Canary is the state of the canary rollout of the latest runner spec, with the Canary update strategy.
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// RolledBackRunnerSpecHash is the runner spec hash whose rollout was rolled back after it missed the
+	// progress deadline. It is not rolled out again until the runner spec changes.
+	// +optional
+	RolledBackRunnerSpecHash string `json:"rolledBackRunnerSpecHash,omitempty"`
+
+	// PredictedRunners is the number of runners predicted for the demand within the lead time,
+	// when predictive scaling is enabled.
+	// +optional
+	PredictedRunners int `json:"predictedRunners,omitempty"`
+
+	// MaintenanceWindowEnd is the end of the maintenance window the scale set is in, if any.
+	// +optional
+	MaintenanceWindowEnd *metav1.Time `json:"maintenanceWindowEnd,omitempty"`
+
+	// CapacityClampedRunners is the number of runners wanted above the schedulable capacity of the cluster,
+	// which are not created, when the cluster capacity cap is enabled.
+	// +optional
+	CapacityClampedRunners int `json:"capacityClampedRunners,omitempty"`
+
+	// RegistrationMethod is how the runners of the latest runner set are registered with the Actions service.
+	// +optional
+	RegistrationMethod RunnerRegistrationMethod `json:"registrationMethod,omitempty"`
+
+	// RunnerPhases is the number of runners in each phase, across the runner sets.
+	// CurrentRunners only counts the runners of the latest runner set, whether they can take a job or not.
+	// +optional
+	RunnerPhases RunnerPhaseCounts `json:"runnerPhases,omitempty"`
+
+	// ScaleEvents are the latest scale decisions of the runner sets, oldest first.
+	// Only the last MaxScaleEvents decisions are kept.
+	// +optional
+	ScaleEvents []ScaleEvent `json:"scaleEvents,omitempty"`
 }
 
+// Annotations the controller sets on the AutoscalingRunnerSet once it created the runner scale set.
+const (
+	// AnnotationKeyRunnerScaleSetId is the ID of the runner scale set.
+	AnnotationKeyRunnerScaleSetId = "runner-scale-set-id"
+
+	// AnnotationKeyRunnerScaleSetRunnerGroupName is the name of the runner group of the runner scale set.
+	AnnotationKeyRunnerScaleSetRunnerGroupName = "runner-scale-set-runner-group-name"
+
+	// AnnotationKeyRunnerScaleSetName is the name the runner scale set is registered with.
+	AnnotationKeyRunnerScaleSetName = "runner-scale-set-name"
+
+	// AnnotationKeyRunnerScaleSetLabels is the comma separated labels the runner scale set is registered with.
+	AnnotationKeyRunnerScaleSetLabels = "runner-scale-set-labels"
+)
+
+// AnnotationKeyForceDelete set to "true" on an AutoscalingRunnerSet deletes it, its EphemeralRunnerSets
+// and EphemeralRunners without removing the runner scale set and the runners from the Actions service,
+// e.g. while the GitHub Enterprise Server is unreachable. The controller sets it as well once the
+// cleanup of the service keeps failing for longer than its remote cleanup timeout.
+const AnnotationKeyForceDelete = "actions.github.com/force-delete"
+
+// AutoscalingRunnerSetStateUnsupportedServerVersion is the state of an AutoscalingRunnerSet
+// whose GitHub Enterprise Server does not support runner scale sets.
+const AutoscalingRunnerSetStateUnsupportedServerVersion = "UnsupportedServerVersion"
+
+// AutoscalingRunnerSetConditionRunnerVersionOutdated is the condition of an AutoscalingRunnerSet
+// whose runners run an older runner version than the latest runner release of the GitHub instance.
+const AutoscalingRunnerSetConditionRunnerVersionOutdated = "RunnerVersionOutdated"
+
+// Reasons of the RunnerVersionOutdated condition.
+const (
+	RunnerVersionReasonUpToDate              = "RunnerVersionUpToDate"
+	RunnerVersionReasonNewerVersionAvailable = "NewerRunnerVersionAvailable"
+	RunnerVersionReasonTooOld                = "RunnerVersionTooOld"
+	RunnerVersionReasonUnknown               = "RunnerVersionUnknown"
+)
+
+// AutoscalingRunnerSetConditionRolledBack is the condition of an AutoscalingRunnerSet
+// whose latest runner spec was rolled back to the previous EphemeralRunnerSet.
+const AutoscalingRunnerSetConditionRolledBack = "RolledBack"
+
+// RolledBackReasonProgressDeadlineExceeded is the reason of the RolledBack condition when the runners
+// of the new EphemeralRunnerSet did not get running within the progress deadline.
+const RolledBackReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// AutoscalingRunnerSetConditionPodSecurityViolation is the condition of an AutoscalingRunnerSet whose runner pods
+// would be rejected by the Pod Security admission of its namespace. No EphemeralRunnerSet is created for its runner spec.
+const AutoscalingRunnerSetConditionPodSecurityViolation = "PodSecurityViolation"
+
+// PodSecurityViolationReasonEnforceLevel is the reason of the PodSecurityViolation condition when the runner pods
+// violate the Pod Security level enforced on the namespace.
+const PodSecurityViolationReasonEnforceLevel = "ViolatesEnforcedLevel"
+
+// AutoscalingRunnerSetConditionInvalidSpec is the condition of an AutoscalingRunnerSet whose spec can't be applied.
+// No EphemeralRunnerSet is created for its runner spec until the spec is fixed.
+const AutoscalingRunnerSetConditionInvalidSpec = "InvalidSpec"
+
+// InvalidSpecReasonTemplatePatches is the reason of the InvalidSpec condition when the template patches
+// can't be applied to the runner pods.
+const InvalidSpecReasonTemplatePatches = "InvalidTemplatePatches"
+
+// InvalidSpecReasonListenerTemplate is the reason of the InvalidSpec condition when the listener pod template
+// sets fields that aren't allowed while the namespaces are isolated.
+const InvalidSpecReasonListenerTemplate = "InvalidListenerTemplate"
+
+// InvalidSpecReasonInitContainers is the reason of the InvalidSpec condition when the init containers
+// have no name or a name taken by another container of the runner pods.
+const InvalidSpecReasonInitContainers = "InvalidInitContainers"
+
+// InvalidSpecReasonPersistentRunners is the reason of the InvalidSpec condition when persistent runners
+// are enabled, which runner scale sets don't support.
+const InvalidSpecReasonPersistentRunners = "PersistentRunnersUnsupported"
+
+// AutoscalingRunnerSetConditionImageSignatureUnverified is the condition of an AutoscalingRunnerSet whose runner or
+// dind images have no cosign signature satisfying its image signature verification. No EphemeralRunnerSet is
+// created for its runner spec.
+const AutoscalingRunnerSetConditionImageSignatureUnverified = "ImageSignatureUnverified"
+
+// Reasons of the ImageSignatureUnverified condition.
+const (
+	ImageSignatureReasonUnsigned           = "NoVerifiedSignature"
+	ImageSignatureReasonVerificationFailed = "VerificationFailed"
+	ImageSignatureReasonInvalidPolicy      = "InvalidPolicy"
+)
+
+// ListenerSpecHash is the hash of the fields the listener pod is built from. The scaling settings the
+// listener reads from its EphemeralRunnerSet are left out, so that changing them doesn't recreate the listener.
 func (ars *AutoscalingRunnerSet) ListenerSpecHash() string {
-	type listenerSpec = AutoscalingRunnerSetSpec
-	arsSpec := ars.Spec.DeepCopy()
-	spec := arsSpec
+	type listenerSpec struct {
+		RunnerScaleSetId        string
+		GitHubConfigUrl         string
+		GitHubConfigSecret      string
+		GitHubServerTLS         *GitHubServerTLSConfig
+		ImagePullSecrets        []corev1.LocalObjectReference
+		TemplateVariantLabels   map[string][]string
+		JobConcurrencyLimits    *JobConcurrencyLimits
+		ListenerTemplate        *corev1.PodTemplateSpec
+		ListenerImagePullPolicy corev1.PullPolicy
+	}
+	spec := &listenerSpec{
+		RunnerScaleSetId:        ars.Annotations[AnnotationKeyRunnerScaleSetId],
+		GitHubConfigUrl:         ars.Spec.GitHubConfigUrl,
+		GitHubConfigSecret:      ars.Spec.GitHubConfigSecret,
+		GitHubServerTLS:         ars.Spec.GitHubServerTLS,
+		ImagePullSecrets:        ars.Spec.ImagePullSecrets,
+		TemplateVariantLabels:   ars.TemplateVariantLabels(),
+		JobConcurrencyLimits:    ars.Spec.JobConcurrencyLimits,
+		ListenerTemplate:        ars.Spec.ListenerTemplate,
+		ListenerImagePullPolicy: ars.Spec.ListenerImagePullPolicy,
+	}
 	return hash.ComputeTemplateHash(&spec)
 }
 
 func (ars *AutoscalingRunnerSet) RunnerSetSpecHash() string {
 	type runnerSetSpec struct {
-		GitHubConfigUrl    string
-		GitHubConfigSecret string
-		RunnerGroup        string
-		Proxy              *ProxyConfig
-		GitHubServerTLS    *GitHubServerTLSConfig
-		Template           corev1.PodTemplateSpec
+		GitHubConfigUrl         string
+		GitHubConfigSecret      string
+		RunnerGroup             string
+		Proxy                   *ProxyConfig
+		GitHubServerTLS         *GitHubServerTLSConfig
+		Hooks                   *RunnerHooks
+		RetainFailedPods        *RetainFailedPods
+		RegistrationFallback    bool
+		PersistentRunners       *PersistentRunners
+		ImagePullSecrets        []corev1.LocalObjectReference
+		Template                corev1.PodTemplateSpec
+		InitContainers          []corev1.Container
+		ContainerMode           *ContainerMode
+		WorkVolumeClaimTemplate *corev1.PersistentVolumeClaimTemplate
+		ToolCache               *RunnerToolCache
+		DockerLayerCache        *DockerLayerCache
+		RunnerNetworkPolicy     bool
+		RunnerServiceAccount    bool
+		AzureWorkloadIdentity   bool
+		TemplatePatches         []PodPatch
+		TemplateVariants        []TemplateVariant
+		NodePlacements          []NodePlacement
+		Placement               *RunnerPlacement
 	}
 	spec := &runnerSetSpec{
-		GitHubConfigUrl:    ars.Spec.GitHubConfigUrl,
-		GitHubConfigSecret: ars.Spec.GitHubConfigSecret,
-		RunnerGroup:        ars.Spec.RunnerGroup,
-		Proxy:              ars.Spec.Proxy,
-		GitHubServerTLS:    ars.Spec.GitHubServerTLS,
-		Template:           ars.Spec.Template,
+		GitHubConfigUrl:         ars.Spec.GitHubConfigUrl,
+		GitHubConfigSecret:      ars.Spec.GitHubConfigSecret,
+		RunnerGroup:             ars.Spec.RunnerGroup,
+		Proxy:                   ars.Spec.Proxy,
+		GitHubServerTLS:         ars.Spec.GitHubServerTLS,
+		Hooks:                   ars.Spec.Hooks,
+		RetainFailedPods:        ars.Spec.RetainFailedPods,
+		RegistrationFallback:    ars.Spec.RegistrationTokenFallback,
+		PersistentRunners:       ars.Spec.PersistentRunners,
+		ImagePullSecrets:        ars.Spec.ImagePullSecrets,
+		Template:                ars.Spec.Template,
+		InitContainers:          ars.Spec.InitContainers,
+		ContainerMode:           ars.Spec.ContainerMode,
+		WorkVolumeClaimTemplate: ars.Spec.WorkVolumeClaimTemplate,
+		ToolCache:               ars.Spec.ToolCache,
+		DockerLayerCache:        ars.Spec.DockerLayerCache,
+		RunnerNetworkPolicy:     ars.Spec.NetworkPolicy != nil && ars.Spec.NetworkPolicy.Enabled,
+		RunnerServiceAccount:    ars.Spec.RunnerServiceAccount != nil,
+		AzureWorkloadIdentity:   ars.Spec.RunnerServiceAccount != nil && ars.Spec.RunnerServiceAccount.Azure != nil,
+		TemplatePatches:         ars.Spec.TemplatePatches,
+		TemplateVariants:        ars.Spec.TemplateVariants,
+		NodePlacements:          ars.Spec.NodePlacements,
+		Placement:               ars.Spec.Placement,
 	}
-	return hash.ComputeTemplateHash(&spec)
+	specHash := hash.ComputeTemplateHash(&spec)
+
+	// A new digest of the runner image rolls out a new EphemeralRunnerSet, as a spec change does.
+	if image := ars.PinnedRunnerImage(); image != "" {
+		pinned := struct {
+			SpecHash    string
+			RunnerImage string
+		}{specHash, image}
+		specHash = hash.ComputeTemplateHash(&pinned)
+	}
+	return specHash
+}
+
+// PinnedRunnerImage returns the runner image pinned to the digest of the status, or an empty string
+// when digest pinning is disabled or the current runner image is not resolved yet.
+func (ars *AutoscalingRunnerSet) PinnedRunnerImage() string {
+	pinning := ars.Spec.RunnerImageDigestPinning
+	if pinning == nil || !pinning.Enabled || ars.Status.RunnerImageDigest == "" {
+		return ""
+	}
+	if image := ars.RunnerImage(); image == "" || image != ars.Status.ResolvedRunnerImage {
+		return ""
+	}
+	return ars.Status.ResolvedRunnerImage + "@" + ars.Status.RunnerImageDigest
+}
+
+// RunnerContainerName is the name of the container running the self-hosted runner image in the pod templates.
+const RunnerContainerName = "runner"
+
+// RunnerImage returns the image of the runner container of the pod template.
+func (ars *AutoscalingRunnerSet) RunnerImage() string {
+	for _, c := range ars.Spec.Template.Spec.Containers {
+		if c.Name == RunnerContainerName {
+			return c.Image
+		}
+	}
+	return ""
 }
 
 //+kubebuilder:object:root=true
@@ -31,6 +31,7 @@ import (
 // +kubebuilder:printcolumn:JSONPath=".status.workflowRunId",name=WorkflowRunId,type=number
 // +kubebuilder:printcolumn:JSONPath=".status.jobDisplayName",name=JobDisplayName,type=string
 // +kubebuilder:printcolumn:JSONPath=".status.message",name=Message,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.failureReason",name=FailureReason,type=string,priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // EphemeralRunner is the Schema for the ephemeralrunners API
@@ -62,16 +63,100 @@ type EphemeralRunnerSpec struct {
 	// +optional
 	GitHubServerTLS *GitHubServerTLSConfig `json:"githubServerTLS,omitempty"`
 
+	// +optional
+	FailurePolicy *FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// +optional
+	RetainFailedPods *RetainFailedPods `json:"retainFailedPods,omitempty"`
+
+	// +optional
+	JobCompletionTimeout *metav1.Duration `json:"jobCompletionTimeout,omitempty"`
+
+	// +optional
+	Hooks *RunnerHooks `json:"hooks,omitempty"`
+
+	// +optional
+	WorkVolumeClaimTemplate *corev1.PersistentVolumeClaimTemplate `json:"workVolumeClaimTemplate,omitempty"`
+
+	// PodPatches are applied in order to the runner pod generated by the controller.
+	// +optional
+	PodPatches []PodPatch `json:"podPatches,omitempty"`
+
+	// InitContainers run before the init containers of the pod template.
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// ContainerHookTemplate is the hook extension template of the container hooks mounted into the runner container.
+	// +optional
+	ContainerHookTemplate *ContainerHookTemplate `json:"containerHookTemplate,omitempty"`
+
+	// RegistrationTokenFallback registers the runner with a registration token. Only the persistent runners
+	// created before persistent runners were rejected have it.
+	// +optional
+	RegistrationTokenFallback *RegistrationTokenFallback `json:"registrationTokenFallback,omitempty"`
+
+	// Persistent registers the runner as a non-ephemeral runner, recycled as the PersistentRunners say.
+	// Only the runners created before persistent runners were rejected have it.
+	// +optional
+	Persistent *PersistentRunners `json:"persistent,omitempty"`
+
 	// +required
 	corev1.PodTemplateSpec `json:",inline"`
 }
 
+// PodPatchType is the format of a PodPatch.
+// +kubebuilder:validation:Enum=StrategicMerge;JSON6902
+type PodPatchType string
+
+const (
+	PodPatchTypeStrategicMerge PodPatchType = "StrategicMerge"
+	PodPatchTypeJSON6902       PodPatchType = "JSON6902"
+)
+
+// PodPatch is a patch of the runner pod, applied after the controller added its own
+// containers, volumes and environment variables to the pod template.
+type PodPatch struct {
+	// Type is the format of the patch. Defaults to StrategicMerge.
+	// +optional
+	// +kubebuilder:default:=StrategicMerge
+	Type PodPatchType `json:"type,omitempty"`
+
+	// Patch is the strategic merge patch or the JSON6902 patch of the pod, in YAML or JSON.
+	// Paths of JSON6902 patches are relative to the pod, e.g. /spec/containers/1/resources.
+	// +required
+	Patch string `json:"patch"`
+}
+
+// RegistrationTokenFallback is how a runner registered with a registration token is configured.
+type RegistrationTokenFallback struct {
+	// RunnerGroup is the runner group the runner registers in. Defaults to the default runner group.
+	// +optional
+	RunnerGroup string `json:"runnerGroup,omitempty"`
+
+	// Labels are the labels the runner registers with, which jobs target with runs-on.
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+}
+
+// RunnerRegistrationMethod is how a runner is registered with the Actions service.
+// +kubebuilder:validation:Enum=JIT;RegistrationToken
+type RunnerRegistrationMethod string
+
+const (
+	// RunnerRegistrationMethodJIT registers the runner with a JIT runner config generated by the controller.
+	RunnerRegistrationMethodJIT RunnerRegistrationMethod = "JIT"
+
+	// RunnerRegistrationMethodRegistrationToken registers the runner with a registration token
+	// the runner configures itself with.
+	RunnerRegistrationMethodRegistrationToken RunnerRegistrationMethod = "RegistrationToken"
+)
+
 // EphemeralRunnerStatus defines the observed state of EphemeralRunner
 type EphemeralRunnerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Turns true only if the runner is online.
+	// Turns true only if the runner is online, i.e. once its pod is ready.
 	// +optional
 	Ready bool `json:"ready"`
 	// Phase describes phases where EphemeralRunner can be in.
@@ -90,6 +175,17 @@ type EphemeralRunnerStatus struct {
 	// +optional
 	Message string `json:"message,omitempty"`
 
+	// FailureReason is the machine-readable reason of the last failure of the runner,
+	// telling failures of the infrastructure apart from failures of the job.
+	// +optional
+	FailureReason EphemeralRunnerFailureReason `json:"failureReason,omitempty"`
+	// ExitCode is the exit code of the runner container of the last failed pod.
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+	// TerminationMessage is the termination message of the runner container of the last failed pod.
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
 	// +optional
 	RunnerId int `json:"runnerId,omitempty"`
 	// +optional
@@ -97,9 +193,17 @@ type EphemeralRunnerStatus struct {
 	// +optional
 	RunnerJITConfig string `json:"runnerJITConfig,omitempty"`
 
+	// RegistrationMethod is how the runner is registered with the Actions service.
+	// +optional
+	RegistrationMethod RunnerRegistrationMethod `json:"registrationMethod,omitempty"`
+
 	// +optional
 	Failures map[string]bool `json:"failures,omitempty"`
 
+	// LastFailureTime is the time the last pod failure was recorded.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
 	// +optional
 	JobRequestId int64 `json:"jobRequestId,omitempty"`
 
@@ -114,8 +218,75 @@ type EphemeralRunnerStatus struct {
 
 	// +optional
 	JobDisplayName string `json:"jobDisplayName,omitempty"`
+
+	// JobRequestLabels are the labels of the runners the job requested in runs-on.
+	// +optional
+	JobRequestLabels []string `json:"jobRequestLabels,omitempty"`
+
+	// JobWorkflowName is the name of the workflow of the job, reported by the workflow_job webhook events.
+	// +optional
+	JobWorkflowName string `json:"jobWorkflowName,omitempty"`
+
+	// JobRequester is the login of the user who triggered the workflow run of the job, reported by the
+	// workflow_job webhook events.
+	// +optional
+	JobRequester string `json:"jobRequester,omitempty"`
+
+	// JobResult is the result of the last job of the runner reported by the Actions service,
+	// e.g. succeeded, failed or canceled.
+	// +optional
+	JobResult string `json:"jobResult,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// Results of the jobs reported by the Actions service.
+const (
+	JobResultSucceeded = "succeeded"
+	JobResultFailed    = "failed"
+)
+
+// EphemeralRunnerConditionRegistrationBlocked is the condition of an EphemeralRunner that can't be
+// registered with the Actions service for a reason retrying won't fix. The registration is retried
+// rarely until the condition is resolved.
+const EphemeralRunnerConditionRegistrationBlocked = "RegistrationBlocked"
+
+// Reasons of the RegistrationBlocked condition.
+const (
+	RegistrationBlockedReasonBadCredentials         = "BadCredentials"
+	RegistrationBlockedReasonRunnerScaleSetNotFound = "RunnerScaleSetNotFound"
+	RegistrationBlockedReasonRunnerGroupNotFound    = "RunnerGroupNotFound"
+	RegistrationBlockedReasonRunnerNameConflict     = "RunnerNameConflict"
+	RegistrationBlockedReasonJITConfigUnavailable   = "JITConfigUnavailable"
+)
+
+// EphemeralRunnerFailureReason is the machine-readable reason of a failure of an EphemeralRunner.
+// +kubebuilder:validation:Enum=ImagePullBackOff;RegistrationFailed;JobTimeout;Evicted;OOMKilled;RunnerFailed;PendingTimeout
+type EphemeralRunnerFailureReason string
+
+const (
+	// EphemeralRunnerFailureReasonImagePullBackOff means that an image of the runner pod can't be pulled.
+	EphemeralRunnerFailureReasonImagePullBackOff EphemeralRunnerFailureReason = "ImagePullBackOff"
+	// EphemeralRunnerFailureReasonRegistrationFailed means that the runner can't be registered with the
+	// Actions service, or that it exited before taking a job while still registered.
+	EphemeralRunnerFailureReasonRegistrationFailed EphemeralRunnerFailureReason = "RegistrationFailed"
+	// EphemeralRunnerFailureReasonJobTimeout means that the job of a deleted runner didn't complete
+	// within the job completion timeout.
+	EphemeralRunnerFailureReasonJobTimeout EphemeralRunnerFailureReason = "JobTimeout"
+	// EphemeralRunnerFailureReasonEvicted means that the runner pod was evicted from its node.
+	EphemeralRunnerFailureReasonEvicted EphemeralRunnerFailureReason = "Evicted"
+	// EphemeralRunnerFailureReasonOOMKilled means that the runner container ran out of memory.
+	EphemeralRunnerFailureReasonOOMKilled EphemeralRunnerFailureReason = "OOMKilled"
+	// EphemeralRunnerFailureReasonRunnerFailed means that the runner container exited with a non-zero exit code.
+	EphemeralRunnerFailureReasonRunnerFailed EphemeralRunnerFailureReason = "RunnerFailed"
+	// EphemeralRunnerFailureReasonPendingTimeout means that the runner pod stayed Pending for longer than
+	// the pending timeout of the failure policy.
+	EphemeralRunnerFailureReasonPendingTimeout EphemeralRunnerFailureReason = "PendingTimeout"
+)
+
 //+kubebuilder:object:root=true
 
 // EphemeralRunnerList contains a list of EphemeralRunner
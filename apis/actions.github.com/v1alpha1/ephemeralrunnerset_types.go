@@ -25,13 +25,195 @@ type EphemeralRunnerSetSpec struct {
 	// Replicas is the number of desired EphemeralRunner resources in the k8s namespace.
 	Replicas int `json:"replicas,omitempty"`
 
+	// MaxReplicas caps the number of EphemeralRunner resources regardless of Replicas.
+	// It is managed by the AutoscalingRunnerSet controller to throttle rolling updates.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxReplicas *int `json:"maxReplicas,omitempty"`
+
+	// BudgetReplicas caps the number of EphemeralRunner resources to the share of the global
+	// runner budget allocated to this EphemeralRunnerSet. It is managed by the controller when
+	// the demand of all runner sets exceeds the budget.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	BudgetReplicas *int `json:"budgetReplicas,omitempty"`
+
+	// CapacityReplicas caps the number of EphemeralRunner resources to the runners the cluster has
+	// capacity for. It is managed by the AutoscalingRunnerSet controller when the cluster capacity cap is enabled.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	CapacityReplicas *int `json:"capacityReplicas,omitempty"`
+
+	// WarmReplicas is the number of idle EphemeralRunner resources kept on top of the ones
+	// running a job, regardless of Replicas.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	WarmReplicas int `json:"warmReplicas,omitempty"`
+
+	// WarmReplicasLimit caps the number of EphemeralRunner resources created to keep
+	// WarmReplicas idle, busy ones included.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	WarmReplicasLimit *int `json:"warmReplicasLimit,omitempty"`
+
+	// ReservedReplicas is the number of EphemeralRunner resources kept regardless of Replicas
+	// for the capacity reservations of the AutoscalingRunnerSet.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	ReservedReplicas int `json:"reservedReplicas,omitempty"`
+
+	// ScaleDownPolicy selects the idle EphemeralRunner resources deleted first when scaling down.
+	// Defaults to oldest.
+	// +optional
+	// +kubebuilder:validation:Enum=oldest;newest;random
+	ScaleDownPolicy ScaleDownPolicy `json:"scaleDownPolicy,omitempty"`
+
+	// IdleReplicasTimeout lowers Replicas by the registered EphemeralRunner resources that have been
+	// idle for longer than the timeout, down to MinReplicas.
+	// +optional
+	IdleReplicasTimeout *metav1.Duration `json:"idleReplicasTimeout,omitempty"`
+
+	// MinReplicas is the number of replicas IdleReplicasTimeout doesn't scale below.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MinReplicas int `json:"minReplicas,omitempty"`
+
 	EphemeralRunnerSpec EphemeralRunnerSpec `json:"ephemeralRunnerSpec,omitempty"`
+
+	// TemplateVariants are alternative pod templates for the EphemeralRunner resources.
+	// +optional
+	TemplateVariants []TemplateVariant `json:"templateVariants,omitempty"`
+
+	// VariantReplicas is the number of desired EphemeralRunner resources per template variant.
+	// These are part of Replicas, the remaining ones use the pod template of EphemeralRunnerSpec.
+	// +optional
+	VariantReplicas map[string]int `json:"variantReplicas,omitempty"`
+
+	// QueuedJobHints are the jobs the workflow_job webhook saw queued for the runner set ahead of the listener.
+	// Each of them adds an EphemeralRunner resource on top of Replicas, which the listener sets from the jobs
+	// it knows of, until the listener sees a job of the same workflow run assigned or the hint expires.
+	// +optional
+	QueuedJobHints []QueuedJobHint `json:"queuedJobHints,omitempty"`
+
+	// ListenerScaleSettings are the scaling settings of the AutoscalingRunnerSet the listener applies
+	// on each message. They are managed by the AutoscalingRunnerSet controller, so that changing them
+	// doesn't recreate the listener.
+	// +optional
+	ListenerScaleSettings *ListenerScaleSettings `json:"listenerScaleSettings,omitempty"`
+}
+
+// ListenerScaleSettings are the settings of an AutoscalingRunnerSet the listener scales its runners with.
+type ListenerScaleSettings struct {
+	MinRunners int `json:"minRunners"`
+
+	MaxRunners int `json:"maxRunners"`
+
+	// +optional
+	JobAcquisitionBatchSize *int `json:"jobAcquisitionBatchSize,omitempty"`
+
+	// +optional
+	ScalingBufferPercent *int `json:"scalingBufferPercent,omitempty"`
+
+	// +optional
+	ScalingSmoothingPercent *int `json:"scalingSmoothingPercent,omitempty"`
+
+	// +optional
+	MaxPendingRunners *int `json:"maxPendingRunners,omitempty"`
+}
+
+// QueuedJobHint is a job the workflow_job webhook saw queued.
+type QueuedJobHint struct {
+	// WorkflowRunId is the workflow run of the job, matched with the jobs of the listener messages.
+	WorkflowRunId int64 `json:"workflowRunId"`
+
+	// QueuedAt is when the webhook event was received.
+	QueuedAt metav1.Time `json:"queuedAt"`
 }
 
+// Annotations the listener sets on the EphemeralRunnerSet it scales.
+const (
+	// AnnotationKeyLastListenerMessageId is the ID of the last message processed by the listener.
+	AnnotationKeyLastListenerMessageId = "actions.github.com/last-listener-message-id"
+
+	// AnnotationKeyLastListenerMessageTime is when the listener processed its last message, in RFC 3339 format.
+	AnnotationKeyLastListenerMessageTime = "actions.github.com/last-listener-message-time"
+
+	// AnnotationKeyJobStatistics is the JSON encoded job statistics of the last message processed by the listener.
+	AnnotationKeyJobStatistics = "actions.github.com/job-statistics"
+
+	// AnnotationKeyListenerCircuitBreaker is the JSON encoded ListenerCircuitBreakerStatus of the listener.
+	AnnotationKeyListenerCircuitBreaker = "actions.github.com/listener-circuit-breaker"
+)
+
+// AnnotationKeyScaleTrigger is the ScaleTrigger of the last change of the replicas of an EphemeralRunnerSet.
+// It is set along with the replicas by the listener and the controller.
+const AnnotationKeyScaleTrigger = "actions.github.com/scale-trigger"
+
+// AnnotationKeyScaleTriggerReplicas is the replicas of the EphemeralRunnerSet the ScaleTrigger was set for.
+// The trigger is stale once something else changed the replicas.
+const AnnotationKeyScaleTriggerReplicas = "actions.github.com/scale-trigger-replicas"
+
 // EphemeralRunnerSetStatus defines the observed state of EphemeralRunnerSet
 type EphemeralRunnerSetStatus struct {
 	// CurrentReplicas is the number of currently running EphemeralRunner resources being managed by this EphemeralRunnerSet.
 	CurrentReplicas int `json:"currentReplicas,omitempty"`
+
+	// RunningReplicas is the number of EphemeralRunner resources whose pod is running.
+	// +optional
+	RunningReplicas int `json:"runningReplicas,omitempty"`
+
+	// FailedReplicas is the number of EphemeralRunner resources that failed.
+	// +optional
+	FailedReplicas int `json:"failedReplicas,omitempty"`
+
+	// SucceededJobs is the number of jobs the EphemeralRunner resources completed successfully.
+	// +optional
+	SucceededJobs int `json:"succeededJobs,omitempty"`
+
+	// FailedJobs is the number of jobs of the EphemeralRunner resources that failed.
+	// +optional
+	FailedJobs int `json:"failedJobs,omitempty"`
+
+	// RegistrationTokenReplicas is the number of EphemeralRunner resources registered with a registration token.
+	// +optional
+	RegistrationTokenReplicas int `json:"registrationTokenReplicas,omitempty"`
+
+	// DesiredReplicas is the number of EphemeralRunner resources the EphemeralRunnerSet last scaled to,
+	// with the warm pool, the reserved replicas and the limits applied to Replicas.
+	// +optional
+	DesiredReplicas int `json:"desiredReplicas,omitempty"`
+
+	// RunnerPhases is the number of EphemeralRunner resources in each phase.
+	// +optional
+	RunnerPhases RunnerPhaseCounts `json:"runnerPhases,omitempty"`
+}
+
+// RunnerPhaseCounts is the number of runners in each phase of their life.
+type RunnerPhaseCounts struct {
+	// PendingPod is the number of runners whose pod isn't running yet.
+	// +optional
+	PendingPod int `json:"pendingPod,omitempty"`
+
+	// Registering is the number of runners whose pod is running, but isn't ready yet. A readiness probe of
+	// the runner container checking that the runner listens for jobs makes it the runners that aren't online yet.
+	// +optional
+	Registering int `json:"registering,omitempty"`
+
+	// Idle is the number of registered runners waiting for a job.
+	// +optional
+	Idle int `json:"idle,omitempty"`
+
+	// RunningJob is the number of runners running a job.
+	// +optional
+	RunningJob int `json:"runningJob,omitempty"`
+
+	// Finishing is the number of runners that completed their job and are being removed.
+	// +optional
+	Finishing int `json:"finishing,omitempty"`
+
+	// Failed is the number of runners that failed.
+	// +optional
+	Failed int `json:"failed,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -23,16 +23,33 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSWorkloadIdentity) DeepCopyInto(out *AWSWorkloadIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSWorkloadIdentity.
+func (in *AWSWorkloadIdentity) DeepCopy() *AWSWorkloadIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSWorkloadIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoscalingListener) DeepCopyInto(out *AutoscalingListener) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingListener.
@@ -93,6 +110,56 @@ func (in *AutoscalingListenerSpec) DeepCopyInto(out *AutoscalingListenerSpec) {
 		*out = make([]v1.LocalObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.OverflowSourceLabels != nil {
+		in, out := &in.OverflowSourceLabels, &out.OverflowSourceLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateVariantLabels != nil {
+		in, out := &in.TemplateVariantLabels, &out.TemplateVariantLabels
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.JobConcurrencyLimits != nil {
+		in, out := &in.JobConcurrencyLimits, &out.JobConcurrencyLimits
+		*out = new(JobConcurrencyLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobAcquisitionBatchSize != nil {
+		in, out := &in.JobAcquisitionBatchSize, &out.JobAcquisitionBatchSize
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScalingBufferPercent != nil {
+		in, out := &in.ScalingBufferPercent, &out.ScalingBufferPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScalingSmoothingPercent != nil {
+		in, out := &in.ScalingSmoothingPercent, &out.ScalingSmoothingPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxPendingRunners != nil {
+		in, out := &in.MaxPendingRunners, &out.MaxPendingRunners
+		*out = new(int)
+		**out = **in
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(v1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingListenerSpec.
@@ -108,6 +175,11 @@ func (in *AutoscalingListenerSpec) DeepCopy() *AutoscalingListenerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoscalingListenerStatus) DeepCopyInto(out *AutoscalingListenerStatus) {
 	*out = *in
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(ListenerCircuitBreakerStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingListenerStatus.
@@ -126,7 +198,7 @@ func (in *AutoscalingRunnerSet) DeepCopyInto(out *AutoscalingRunnerSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSet.
@@ -182,6 +254,11 @@ func (in *AutoscalingRunnerSetList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoscalingRunnerSetSpec) DeepCopyInto(out *AutoscalingRunnerSetSpec) {
 	*out = *in
+	if in.PersistentRunners != nil {
+		in, out := &in.PersistentRunners, &out.PersistentRunners
+		*out = new(PersistentRunners)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Proxy != nil {
 		in, out := &in.Proxy, &out.Proxy
 		*out = new(ProxyConfig)
@@ -192,17 +269,200 @@ func (in *AutoscalingRunnerSetSpec) DeepCopyInto(out *AutoscalingRunnerSetSpec)
 		*out = new(GitHubServerTLSConfig)
 		**out = **in
 	}
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(FailurePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetainFailedPods != nil {
+		in, out := &in.RetainFailedPods, &out.RetainFailedPods
+		*out = new(RetainFailedPods)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(RunnerHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(UpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobCompletionTimeout != nil {
+		in, out := &in.JobCompletionTimeout, &out.JobCompletionTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
 	in.Template.DeepCopyInto(&out.Template)
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ContainerMode != nil {
+		in, out := &in.ContainerMode, &out.ContainerMode
+		*out = new(ContainerMode)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkVolumeClaimTemplate != nil {
+		in, out := &in.WorkVolumeClaimTemplate, &out.WorkVolumeClaimTemplate
+		*out = new(v1.PersistentVolumeClaimTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ToolCache != nil {
+		in, out := &in.ToolCache, &out.ToolCache
+		*out = new(RunnerToolCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DockerLayerCache != nil {
+		in, out := &in.DockerLayerCache, &out.DockerLayerCache
+		*out = new(DockerLayerCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(RunnerNetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RunnerServiceAccount != nil {
+		in, out := &in.RunnerServiceAccount, &out.RunnerServiceAccount
+		*out = new(RunnerServiceAccount)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TemplatePatches != nil {
+		in, out := &in.TemplatePatches, &out.TemplatePatches
+		*out = make([]PodPatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateVariants != nil {
+		in, out := &in.TemplateVariants, &out.TemplateVariants
+		*out = make([]TemplateVariant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodePlacements != nil {
+		in, out := &in.NodePlacements, &out.NodePlacements
+		*out = make([]NodePlacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(RunnerPlacement)
+		**out = **in
+	}
+	if in.ImagePrePull != nil {
+		in, out := &in.ImagePrePull, &out.ImagePrePull
+		*out = new(ImagePrePull)
+		**out = **in
+	}
+	if in.RunnerVersionTracking != nil {
+		in, out := &in.RunnerVersionTracking, &out.RunnerVersionTracking
+		*out = new(RunnerVersionTracking)
+		**out = **in
+	}
+	if in.RunnerImageDigestPinning != nil {
+		in, out := &in.RunnerImageDigestPinning, &out.RunnerImageDigestPinning
+		*out = new(RunnerImageDigestPinning)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageSignatureVerification != nil {
+		in, out := &in.ImageSignatureVerification, &out.ImageSignatureVerification
+		*out = new(ImageSignatureVerification)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.MaxRunners != nil {
 		in, out := &in.MaxRunners, &out.MaxRunners
 		*out = new(int)
 		**out = **in
 	}
+	if in.WarmPool != nil {
+		in, out := &in.WarmPool, &out.WarmPool
+		*out = new(WarmPool)
+		**out = **in
+	}
+	if in.FairShareWeight != nil {
+		in, out := &in.FairShareWeight, &out.FairShareWeight
+		*out = new(int)
+		**out = **in
+	}
+	if in.JobConcurrencyLimits != nil {
+		in, out := &in.JobConcurrencyLimits, &out.JobConcurrencyLimits
+		*out = new(JobConcurrencyLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobAcquisitionBatchSize != nil {
+		in, out := &in.JobAcquisitionBatchSize, &out.JobAcquisitionBatchSize
+		*out = new(int)
+		**out = **in
+	}
+	if in.ListenerTemplate != nil {
+		in, out := &in.ListenerTemplate, &out.ListenerTemplate
+		*out = new(v1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.MinRunners != nil {
 		in, out := &in.MinRunners, &out.MinRunners
 		*out = new(int)
 		**out = **in
 	}
+	if in.MinIdleRunners != nil {
+		in, out := &in.MinIdleRunners, &out.MinIdleRunners
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScalingBufferPercent != nil {
+		in, out := &in.ScalingBufferPercent, &out.ScalingBufferPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScalingSmoothingPercent != nil {
+		in, out := &in.ScalingSmoothingPercent, &out.ScalingSmoothingPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxPendingRunners != nil {
+		in, out := &in.MaxPendingRunners, &out.MaxPendingRunners
+		*out = new(int)
+		**out = **in
+	}
+	if in.IdleRunnerTimeout != nil {
+		in, out := &in.IdleRunnerTimeout, &out.IdleRunnerTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.CapacityReservations != nil {
+		in, out := &in.CapacityReservations, &out.CapacityReservations
+		*out = make([]CapacityReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PredictiveScaling != nil {
+		in, out := &in.PredictiveScaling, &out.PredictiveScaling
+		*out = new(PredictiveScaling)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClusterCapacity != nil {
+		in, out := &in.ClusterCapacity, &out.ClusterCapacity
+		*out = new(ClusterCapacity)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSetSpec.
@@ -218,6 +478,30 @@ func (in *AutoscalingRunnerSetSpec) DeepCopy() *AutoscalingRunnerSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutoscalingRunnerSetStatus) DeepCopyInto(out *AutoscalingRunnerSetStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaintenanceWindowEnd != nil {
+		in, out := &in.MaintenanceWindowEnd, &out.MaintenanceWindowEnd
+		*out = (*in).DeepCopy()
+	}
+	out.RunnerPhases = in.RunnerPhases
+	if in.ScaleEvents != nil {
+		in, out := &in.ScaleEvents, &out.ScaleEvents
+		*out = make([]ScaleEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSetStatus.
@@ -231,213 +515,816 @@ func (in *AutoscalingRunnerSetStatus) DeepCopy() *AutoscalingRunnerSetStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunner) DeepCopyInto(out *EphemeralRunner) {
+func (in *AzureWorkloadIdentity) DeepCopyInto(out *AzureWorkloadIdentity) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunner.
-func (in *EphemeralRunner) DeepCopy() *EphemeralRunner {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureWorkloadIdentity.
+func (in *AzureWorkloadIdentity) DeepCopy() *AzureWorkloadIdentity {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunner)
+	out := new(AzureWorkloadIdentity)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EphemeralRunner) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerList) DeepCopyInto(out *EphemeralRunnerList) {
+func (in *BlueGreenStrategy) DeepCopyInto(out *BlueGreenStrategy) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]EphemeralRunner, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.MinReadyRunners != nil {
+		in, out := &in.MinReadyRunners, &out.MinReadyRunners
+		*out = new(intstr.IntOrString)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerList.
-func (in *EphemeralRunnerList) DeepCopy() *EphemeralRunnerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueGreenStrategy.
+func (in *BlueGreenStrategy) DeepCopy() *BlueGreenStrategy {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerList)
+	out := new(BlueGreenStrategy)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EphemeralRunnerList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerSet) DeepCopyInto(out *EphemeralRunnerSet) {
+func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSet.
-func (in *EphemeralRunnerSet) DeepCopy() *EphemeralRunnerSet {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStatus.
+func (in *CanaryStatus) DeepCopy() *CanaryStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerSet)
+	out := new(CanaryStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EphemeralRunnerSet) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerSetList) DeepCopyInto(out *EphemeralRunnerSetList) {
+func (in *CanaryStrategy) DeepCopyInto(out *CanaryStrategy) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]EphemeralRunnerSet, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.SuccessfulJobs != nil {
+		in, out := &in.SuccessfulJobs, &out.SuccessfulJobs
+		*out = new(int)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetList.
-func (in *EphemeralRunnerSetList) DeepCopy() *EphemeralRunnerSetList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStrategy.
+func (in *CanaryStrategy) DeepCopy() *CanaryStrategy {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerSetList)
+	out := new(CanaryStrategy)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EphemeralRunnerSetList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityReservation) DeepCopyInto(out *CapacityReservation) {
+	*out = *in
+	in.ExpirationTime.DeepCopyInto(&out.ExpirationTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityReservation.
+func (in *CapacityReservation) DeepCopy() *CapacityReservation {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(CapacityReservation)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerSetSpec) DeepCopyInto(out *EphemeralRunnerSetSpec) {
+func (in *ClusterCapacity) DeepCopyInto(out *ClusterCapacity) {
 	*out = *in
-	in.EphemeralRunnerSpec.DeepCopyInto(&out.EphemeralRunnerSpec)
+	if in.UnschedulableRunners != nil {
+		in, out := &in.UnschedulableRunners, &out.UnschedulableRunners
+		*out = new(int)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetSpec.
-func (in *EphemeralRunnerSetSpec) DeepCopy() *EphemeralRunnerSetSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCapacity.
+func (in *ClusterCapacity) DeepCopy() *ClusterCapacity {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerSetSpec)
+	out := new(ClusterCapacity)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerSetStatus) DeepCopyInto(out *EphemeralRunnerSetStatus) {
+func (in *ContainerHookTemplate) DeepCopyInto(out *ContainerHookTemplate) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetStatus.
-func (in *EphemeralRunnerSetStatus) DeepCopy() *EphemeralRunnerSetStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerHookTemplate.
+func (in *ContainerHookTemplate) DeepCopy() *ContainerHookTemplate {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerSetStatus)
+	out := new(ContainerHookTemplate)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerSpec) DeepCopyInto(out *EphemeralRunnerSpec) {
+func (in *ContainerMode) DeepCopyInto(out *ContainerMode) {
 	*out = *in
-	if in.Proxy != nil {
-		in, out := &in.Proxy, &out.Proxy
-		*out = new(ProxyConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.GitHubServerTLS != nil {
-		in, out := &in.GitHubServerTLS, &out.GitHubServerTLS
-		*out = new(GitHubServerTLSConfig)
+	if in.HookTemplate != nil {
+		in, out := &in.HookTemplate, &out.HookTemplate
+		*out = new(ContainerHookTemplate)
 		**out = **in
 	}
-	in.PodTemplateSpec.DeepCopyInto(&out.PodTemplateSpec)
+	if in.JobResources != nil {
+		in, out := &in.JobResources, &out.JobResources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSpec.
-func (in *EphemeralRunnerSpec) DeepCopy() *EphemeralRunnerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerMode.
+func (in *ContainerMode) DeepCopy() *ContainerMode {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerSpec)
+	out := new(ContainerMode)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EphemeralRunnerStatus) DeepCopyInto(out *EphemeralRunnerStatus) {
+func (in *DockerLayerCache) DeepCopyInto(out *DockerLayerCache) {
 	*out = *in
-	if in.Failures != nil {
-		in, out := &in.Failures, &out.Failures
-		*out = make(map[string]bool, len(*in))
+	if in.MaxCachesPerNode != nil {
+		in, out := &in.MaxCachesPerNode, &out.MaxCachesPerNode
+		*out = new(int)
+		**out = **in
+	}
+	if in.SizeLimit != nil {
+		in, out := &in.SizeLimit, &out.SizeLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerLayerCache.
+func (in *DockerLayerCache) DeepCopy() *DockerLayerCache {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerLayerCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunner) DeepCopyInto(out *EphemeralRunner) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunner.
+func (in *EphemeralRunner) DeepCopy() *EphemeralRunner {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EphemeralRunner) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerList) DeepCopyInto(out *EphemeralRunnerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EphemeralRunner, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerList.
+func (in *EphemeralRunnerList) DeepCopy() *EphemeralRunnerList {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EphemeralRunnerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerSet) DeepCopyInto(out *EphemeralRunnerSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSet.
+func (in *EphemeralRunnerSet) DeepCopy() *EphemeralRunnerSet {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EphemeralRunnerSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerSetList) DeepCopyInto(out *EphemeralRunnerSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EphemeralRunnerSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetList.
+func (in *EphemeralRunnerSetList) DeepCopy() *EphemeralRunnerSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EphemeralRunnerSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerSetSpec) DeepCopyInto(out *EphemeralRunnerSetSpec) {
+	*out = *in
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int)
+		**out = **in
+	}
+	if in.BudgetReplicas != nil {
+		in, out := &in.BudgetReplicas, &out.BudgetReplicas
+		*out = new(int)
+		**out = **in
+	}
+	if in.CapacityReplicas != nil {
+		in, out := &in.CapacityReplicas, &out.CapacityReplicas
+		*out = new(int)
+		**out = **in
+	}
+	if in.WarmReplicasLimit != nil {
+		in, out := &in.WarmReplicasLimit, &out.WarmReplicasLimit
+		*out = new(int)
+		**out = **in
+	}
+	if in.IdleReplicasTimeout != nil {
+		in, out := &in.IdleReplicasTimeout, &out.IdleReplicasTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	in.EphemeralRunnerSpec.DeepCopyInto(&out.EphemeralRunnerSpec)
+	if in.TemplateVariants != nil {
+		in, out := &in.TemplateVariants, &out.TemplateVariants
+		*out = make([]TemplateVariant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VariantReplicas != nil {
+		in, out := &in.VariantReplicas, &out.VariantReplicas
+		*out = make(map[string]int, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val
 		}
 	}
+	if in.QueuedJobHints != nil {
+		in, out := &in.QueuedJobHints, &out.QueuedJobHints
+		*out = make([]QueuedJobHint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ListenerScaleSettings != nil {
+		in, out := &in.ListenerScaleSettings, &out.ListenerScaleSettings
+		*out = new(ListenerScaleSettings)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetSpec.
+func (in *EphemeralRunnerSetSpec) DeepCopy() *EphemeralRunnerSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerSetStatus) DeepCopyInto(out *EphemeralRunnerSetStatus) {
+	*out = *in
+	out.RunnerPhases = in.RunnerPhases
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetStatus.
+func (in *EphemeralRunnerSetStatus) DeepCopy() *EphemeralRunnerSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerSpec) DeepCopyInto(out *EphemeralRunnerSpec) {
+	*out = *in
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(ProxyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitHubServerTLS != nil {
+		in, out := &in.GitHubServerTLS, &out.GitHubServerTLS
+		*out = new(GitHubServerTLSConfig)
+		**out = **in
+	}
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(FailurePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetainFailedPods != nil {
+		in, out := &in.RetainFailedPods, &out.RetainFailedPods
+		*out = new(RetainFailedPods)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobCompletionTimeout != nil {
+		in, out := &in.JobCompletionTimeout, &out.JobCompletionTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(RunnerHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkVolumeClaimTemplate != nil {
+		in, out := &in.WorkVolumeClaimTemplate, &out.WorkVolumeClaimTemplate
+		*out = new(v1.PersistentVolumeClaimTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodPatches != nil {
+		in, out := &in.PodPatches, &out.PodPatches
+		*out = make([]PodPatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ContainerHookTemplate != nil {
+		in, out := &in.ContainerHookTemplate, &out.ContainerHookTemplate
+		*out = new(ContainerHookTemplate)
+		**out = **in
+	}
+	if in.RegistrationTokenFallback != nil {
+		in, out := &in.RegistrationTokenFallback, &out.RegistrationTokenFallback
+		*out = new(RegistrationTokenFallback)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Persistent != nil {
+		in, out := &in.Persistent, &out.Persistent
+		*out = new(PersistentRunners)
+		(*in).DeepCopyInto(*out)
+	}
+	in.PodTemplateSpec.DeepCopyInto(&out.PodTemplateSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSpec.
+func (in *EphemeralRunnerSpec) DeepCopy() *EphemeralRunnerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralRunnerStatus) DeepCopyInto(out *EphemeralRunnerStatus) {
+	*out = *in
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.JobRequestLabels != nil {
+		in, out := &in.JobRequestLabels, &out.JobRequestLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerStatus.
+func (in *EphemeralRunnerStatus) DeepCopy() *EphemeralRunnerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralRunnerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePolicy) DeepCopyInto(out *FailurePolicy) {
+	*out = *in
+	if in.MaxFailures != nil {
+		in, out := &in.MaxFailures, &out.MaxFailures
+		*out = new(int)
+		**out = **in
+	}
+	if in.BackoffDuration != nil {
+		in, out := &in.BackoffDuration, &out.BackoffDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ResetWindow != nil {
+		in, out := &in.ResetWindow, &out.ResetWindow
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PendingTimeout != nil {
+		in, out := &in.PendingTimeout, &out.PendingTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePolicy.
+func (in *FailurePolicy) DeepCopy() *FailurePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPWorkloadIdentity) DeepCopyInto(out *GCPWorkloadIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPWorkloadIdentity.
+func (in *GCPWorkloadIdentity) DeepCopy() *GCPWorkloadIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPWorkloadIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubServerTLSConfig) DeepCopyInto(out *GitHubServerTLSConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubServerTLSConfig.
+func (in *GitHubServerTLSConfig) DeepCopy() *GitHubServerTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubServerTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePrePull) DeepCopyInto(out *ImagePrePull) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePrePull.
+func (in *ImagePrePull) DeepCopy() *ImagePrePull {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePrePull)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSignatureVerification) DeepCopyInto(out *ImageSignatureVerification) {
+	*out = *in
+	if in.Keyless != nil {
+		in, out := &in.Keyless, &out.Keyless
+		*out = new(KeylessSignatureVerification)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignatureVerification.
+func (in *ImageSignatureVerification) DeepCopy() *ImageSignatureVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSignatureVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobConcurrencyLimits) DeepCopyInto(out *JobConcurrencyLimits) {
+	*out = *in
+	if in.MaxJobsPerRepository != nil {
+		in, out := &in.MaxJobsPerRepository, &out.MaxJobsPerRepository
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxJobsPerWorkflow != nil {
+		in, out := &in.MaxJobsPerWorkflow, &out.MaxJobsPerWorkflow
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobConcurrencyLimits.
+func (in *JobConcurrencyLimits) DeepCopy() *JobConcurrencyLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(JobConcurrencyLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeylessSignatureVerification) DeepCopyInto(out *KeylessSignatureVerification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeylessSignatureVerification.
+func (in *KeylessSignatureVerification) DeepCopy() *KeylessSignatureVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(KeylessSignatureVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListenerCircuitBreakerStatus) DeepCopyInto(out *ListenerCircuitBreakerStatus) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenerCircuitBreakerStatus.
+func (in *ListenerCircuitBreakerStatus) DeepCopy() *ListenerCircuitBreakerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ListenerCircuitBreakerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListenerScaleSettings) DeepCopyInto(out *ListenerScaleSettings) {
+	*out = *in
+	if in.JobAcquisitionBatchSize != nil {
+		in, out := &in.JobAcquisitionBatchSize, &out.JobAcquisitionBatchSize
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScalingBufferPercent != nil {
+		in, out := &in.ScalingBufferPercent, &out.ScalingBufferPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScalingSmoothingPercent != nil {
+		in, out := &in.ScalingSmoothingPercent, &out.ScalingSmoothingPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxPendingRunners != nil {
+		in, out := &in.MaxPendingRunners, &out.MaxPendingRunners
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenerScaleSettings.
+func (in *ListenerScaleSettings) DeepCopy() *ListenerScaleSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(ListenerScaleSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePlacement) DeepCopyInto(out *NodePlacement) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePlacement.
+func (in *NodePlacement) DeepCopy() *NodePlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PersistentRunners) DeepCopyInto(out *PersistentRunners) {
+	*out = *in
+	if in.MaxLifetime != nil {
+		in, out := &in.MaxLifetime, &out.MaxLifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerStatus.
-func (in *EphemeralRunnerStatus) DeepCopy() *EphemeralRunnerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistentRunners.
+func (in *PersistentRunners) DeepCopy() *PersistentRunners {
 	if in == nil {
 		return nil
 	}
-	out := new(EphemeralRunnerStatus)
+	out := new(PersistentRunners)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GitHubServerTLSConfig) DeepCopyInto(out *GitHubServerTLSConfig) {
+func (in *PodPatch) DeepCopyInto(out *PodPatch) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubServerTLSConfig.
-func (in *GitHubServerTLSConfig) DeepCopy() *GitHubServerTLSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodPatch.
+func (in *PodPatch) DeepCopy() *PodPatch {
 	if in == nil {
 		return nil
 	}
-	out := new(GitHubServerTLSConfig)
+	out := new(PodPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PredictiveScaling) DeepCopyInto(out *PredictiveScaling) {
+	*out = *in
+	if in.LeadTime != nil {
+		in, out := &in.LeadTime, &out.LeadTime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PredictiveScaling.
+func (in *PredictiveScaling) DeepCopy() *PredictiveScaling {
+	if in == nil {
+		return nil
+	}
+	out := new(PredictiveScaling)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -486,3 +1373,354 @@ func (in *ProxyServerConfig) DeepCopy() *ProxyServerConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueuedJobHint) DeepCopyInto(out *QueuedJobHint) {
+	*out = *in
+	in.QueuedAt.DeepCopyInto(&out.QueuedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueuedJobHint.
+func (in *QueuedJobHint) DeepCopy() *QueuedJobHint {
+	if in == nil {
+		return nil
+	}
+	out := new(QueuedJobHint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistrationTokenFallback) DeepCopyInto(out *RegistrationTokenFallback) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrationTokenFallback.
+func (in *RegistrationTokenFallback) DeepCopy() *RegistrationTokenFallback {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistrationTokenFallback)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetainFailedPods) DeepCopyInto(out *RetainFailedPods) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetainFailedPods.
+func (in *RetainFailedPods) DeepCopy() *RetainFailedPods {
+	if in == nil {
+		return nil
+	}
+	out := new(RetainFailedPods)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingUpdateStrategy) DeepCopyInto(out *RollingUpdateStrategy) {
+	*out = *in
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingUpdateStrategy.
+func (in *RollingUpdateStrategy) DeepCopy() *RollingUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerHookScript) DeepCopyInto(out *RunnerHookScript) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerHookScript.
+func (in *RunnerHookScript) DeepCopy() *RunnerHookScript {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerHookScript)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerHooks) DeepCopyInto(out *RunnerHooks) {
+	*out = *in
+	if in.JobStarted != nil {
+		in, out := &in.JobStarted, &out.JobStarted
+		*out = new(RunnerHookScript)
+		**out = **in
+	}
+	if in.JobCompleted != nil {
+		in, out := &in.JobCompleted, &out.JobCompleted
+		*out = new(RunnerHookScript)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerHooks.
+func (in *RunnerHooks) DeepCopy() *RunnerHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerImageDigestPinning) DeepCopyInto(out *RunnerImageDigestPinning) {
+	*out = *in
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerImageDigestPinning.
+func (in *RunnerImageDigestPinning) DeepCopy() *RunnerImageDigestPinning {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerImageDigestPinning)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerNetworkPolicy) DeepCopyInto(out *RunnerNetworkPolicy) {
+	*out = *in
+	if in.ExtraEgressCIDRs != nil {
+		in, out := &in.ExtraEgressCIDRs, &out.ExtraEgressCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerNetworkPolicy.
+func (in *RunnerNetworkPolicy) DeepCopy() *RunnerNetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerNetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerPhaseCounts) DeepCopyInto(out *RunnerPhaseCounts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerPhaseCounts.
+func (in *RunnerPhaseCounts) DeepCopy() *RunnerPhaseCounts {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerPhaseCounts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerPlacement) DeepCopyInto(out *RunnerPlacement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerPlacement.
+func (in *RunnerPlacement) DeepCopy() *RunnerPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerServiceAccount) DeepCopyInto(out *RunnerServiceAccount) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AWS != nil {
+		in, out := &in.AWS, &out.AWS
+		*out = new(AWSWorkloadIdentity)
+		**out = **in
+	}
+	if in.GCP != nil {
+		in, out := &in.GCP, &out.GCP
+		*out = new(GCPWorkloadIdentity)
+		**out = **in
+	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		*out = new(AzureWorkloadIdentity)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerServiceAccount.
+func (in *RunnerServiceAccount) DeepCopy() *RunnerServiceAccount {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerServiceAccount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerToolCache) DeepCopyInto(out *RunnerToolCache) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerToolCache.
+func (in *RunnerToolCache) DeepCopy() *RunnerToolCache {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerToolCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerVersionTracking) DeepCopyInto(out *RunnerVersionTracking) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerVersionTracking.
+func (in *RunnerVersionTracking) DeepCopy() *RunnerVersionTracking {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerVersionTracking)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleEvent) DeepCopyInto(out *ScaleEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleEvent.
+func (in *ScaleEvent) DeepCopy() *ScaleEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateVariant) DeepCopyInto(out *TemplateVariant) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateVariant.
+func (in *TemplateVariant) DeepCopy() *TemplateVariant {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateVariant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateStrategy) DeepCopyInto(out *UpdateStrategy) {
+	*out = *in
+	if in.RollingUpdate != nil {
+		in, out := &in.RollingUpdate, &out.RollingUpdate
+		*out = new(RollingUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlueGreen != nil {
+		in, out := &in.BlueGreen, &out.BlueGreen
+		*out = new(BlueGreenStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateStrategy.
+func (in *UpdateStrategy) DeepCopy() *UpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WarmPool) DeepCopyInto(out *WarmPool) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WarmPool.
+func (in *WarmPool) DeepCopy() *WarmPool {
+	if in == nil {
+		return nil
+	}
+	out := new(WarmPool)
+	in.DeepCopyInto(out)
+	return out
+}
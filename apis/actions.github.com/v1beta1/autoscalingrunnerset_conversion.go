@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The actions-runner-controller authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+var _ conversion.Convertible = &AutoscalingRunnerSet{}
+
+// ConvertTo converts this AutoscalingRunnerSet to the Hub version (v1alpha1).
+func (src *AutoscalingRunnerSet) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.AutoscalingRunnerSet)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	if err := convertSpec(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+
+	dst.Status.CurrentRunners = src.Status.CurrentRunners
+	dst.Status.State = src.Status.State
+	dst.Status.RunnerVersion = src.Status.RunnerVersion
+	dst.Status.LatestRunnerVersion = src.Status.LatestRunnerVersion
+	dst.Status.ResolvedRunnerImage = src.Status.ResolvedRunnerImage
+	dst.Status.RunnerImageDigest = src.Status.RunnerImageDigest
+	dst.Status.Conditions = append([]metav1.Condition(nil), src.Status.Conditions...)
+	dst.Status.RolledBackRunnerSpecHash = src.Status.RolledBackRunnerSpecHash
+	dst.Status.PredictedRunners = src.Status.PredictedRunners
+	dst.Status.MaintenanceWindowEnd = src.Status.MaintenanceWindowEnd
+	dst.Status.CapacityClampedRunners = src.Status.CapacityClampedRunners
+	dst.Status.RegistrationMethod = v1alpha1.RunnerRegistrationMethod(src.Status.RegistrationMethod)
+	dst.Status.RunnerPhases = v1alpha1.RunnerPhaseCounts(src.Status.RunnerPhases)
+	for _, e := range src.Status.ScaleEvents {
+		dst.Status.ScaleEvents = append(dst.Status.ScaleEvents, v1alpha1.ScaleEvent{
+			Time:                   e.Time,
+			EphemeralRunnerSetName: e.EphemeralRunnerSetName,
+			PreviousRunners:        e.PreviousRunners,
+			DesiredRunners:         e.DesiredRunners,
+			Trigger:                v1alpha1.ScaleTrigger(e.Trigger),
+		})
+	}
+	if src.Status.Canary != nil {
+		dst.Status.Canary = &v1alpha1.CanaryStatus{
+			SpecHash:               src.Status.Canary.SpecHash,
+			EphemeralRunnerSetName: src.Status.Canary.EphemeralRunnerSetName,
+			Phase:                  v1alpha1.CanaryPhase(src.Status.Canary.Phase),
+			SucceededJobs:          src.Status.Canary.SucceededJobs,
+			Failures:               src.Status.Canary.Failures,
+			LastTransitionTime:     src.Status.Canary.LastTransitionTime.DeepCopy(),
+		}
+	}
+
+	// The annotations win over the status, as the controller only maintains the annotations.
+	if src.Status.RunnerScaleSetId > 0 {
+		setAnnotationIfMissing(&dst.ObjectMeta.Annotations, v1alpha1.AnnotationKeyRunnerScaleSetId, strconv.Itoa(src.Status.RunnerScaleSetId))
+	}
+	if src.Status.RunnerGroupName != "" {
+		setAnnotationIfMissing(&dst.ObjectMeta.Annotations, v1alpha1.AnnotationKeyRunnerScaleSetRunnerGroupName, src.Status.RunnerGroupName)
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *AutoscalingRunnerSet) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.AutoscalingRunnerSet)
+
+	// The annotations are kept, so that converting the object back to v1alpha1 is lossless.
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	if err := convertSpec(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+
+	dst.Status.CurrentRunners = src.Status.CurrentRunners
+	dst.Status.State = src.Status.State
+	dst.Status.RunnerVersion = src.Status.RunnerVersion
+	dst.Status.LatestRunnerVersion = src.Status.LatestRunnerVersion
+	dst.Status.ResolvedRunnerImage = src.Status.ResolvedRunnerImage
+	dst.Status.RunnerImageDigest = src.Status.RunnerImageDigest
+	dst.Status.Conditions = append([]metav1.Condition(nil), src.Status.Conditions...)
+	dst.Status.RolledBackRunnerSpecHash = src.Status.RolledBackRunnerSpecHash
+	dst.Status.PredictedRunners = src.Status.PredictedRunners
+	dst.Status.MaintenanceWindowEnd = src.Status.MaintenanceWindowEnd
+	dst.Status.CapacityClampedRunners = src.Status.CapacityClampedRunners
+	dst.Status.RegistrationMethod = RunnerRegistrationMethod(src.Status.RegistrationMethod)
+	dst.Status.RunnerPhases = RunnerPhaseCounts(src.Status.RunnerPhases)
+	for _, e := range src.Status.ScaleEvents {
+		dst.Status.ScaleEvents = append(dst.Status.ScaleEvents, ScaleEvent{
+			Time:                   e.Time,
+			EphemeralRunnerSetName: e.EphemeralRunnerSetName,
+			PreviousRunners:        e.PreviousRunners,
+			DesiredRunners:         e.DesiredRunners,
+			Trigger:                ScaleTrigger(e.Trigger),
+		})
+	}
+	if src.Status.Canary != nil {
+		dst.Status.Canary = &CanaryStatus{
+			SpecHash:               src.Status.Canary.SpecHash,
+			EphemeralRunnerSetName: src.Status.Canary.EphemeralRunnerSetName,
+			Phase:                  CanaryPhase(src.Status.Canary.Phase),
+			SucceededJobs:          src.Status.Canary.SucceededJobs,
+			Failures:               src.Status.Canary.Failures,
+			LastTransitionTime:     src.Status.Canary.LastTransitionTime.DeepCopy(),
+		}
+	}
+
+	if id, err := strconv.Atoi(src.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetId]); err == nil {
+		dst.Status.RunnerScaleSetId = id
+	}
+	dst.Status.RunnerGroupName = src.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetRunnerGroupName]
+
+	return nil
+}
+
+// convertSpec converts the spec between versions. The specs of v1alpha1 and v1beta1 share their fields,
+// so they are converted through their JSON representation.
+// Fields renamed or restructured in v1beta1 have to be converted explicitly by the callers.
+func convertSpec(src, dst interface{}) error {
+	raw, err := json.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("failed to marshal autoscaling runner set spec: %v", err)
+	}
+
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return fmt.Errorf("failed to unmarshal autoscaling runner set spec: %v", err)
+	}
+
+	return nil
+}
+
+func setAnnotationIfMissing(annotations *map[string]string, key, value string) {
+	if *annotations == nil {
+		*annotations = make(map[string]string)
+	}
+	if _, ok := (*annotations)[key]; !ok {
+		(*annotations)[key] = value
+	}
+}
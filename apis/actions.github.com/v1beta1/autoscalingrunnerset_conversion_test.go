@@ -0,0 +1,100 @@
+package v1beta1
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	fuzz "github.com/google/gofuzz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// newConversionFuzzer fills every field of the objects, so that the round trips cover the fields
+// added to the spec and the status without listing them.
+func newConversionFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.NewWithSeed(seed).NilChance(0).NumElements(1, 2).Funcs(
+		// Times are serialized with a precision of a second.
+		func(t *metav1.Time, c fuzz.Continue) {
+			*t = metav1.NewTime(time.Unix(c.Int63n(1<<32), 0))
+		},
+		func(f *metav1.FieldsV1, c fuzz.Continue) {
+			f.Raw = []byte(`{"f:metadata":{}}`)
+		},
+		// Quantities cache their serialized form once unmarshaled.
+		func(q *resource.Quantity, c fuzz.Continue) {
+			if err := q.UnmarshalJSON([]byte(strconv.FormatInt(c.Int63n(1000), 10))); err != nil {
+				panic(err)
+			}
+		},
+		func(v *intstr.IntOrString, c fuzz.Continue) {
+			if c.RandBool() {
+				*v = intstr.FromInt(int(c.Int31()))
+			} else {
+				*v = intstr.FromString(c.RandString())
+			}
+		},
+	)
+}
+
+func TestAutoscalingRunnerSetConversion_RoundTrip(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		f := newConversionFuzzer(seed)
+
+		t.Run("v1alpha1 "+strconv.FormatInt(seed, 10), func(t *testing.T) {
+			var original v1alpha1.AutoscalingRunnerSet
+			f.Fuzz(&original)
+			original.TypeMeta = metav1.TypeMeta{}
+			original.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetId] = "42"
+
+			var converted AutoscalingRunnerSet
+			require.NoError(t, converted.ConvertFrom(original.DeepCopy()))
+			assert.Equal(t, 42, converted.Status.RunnerScaleSetId)
+			assert.Equal(t, original.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetRunnerGroupName], converted.Status.RunnerGroupName)
+
+			var roundTripped v1alpha1.AutoscalingRunnerSet
+			require.NoError(t, converted.ConvertTo(&roundTripped))
+			assert.Equal(t, original, roundTripped)
+		})
+
+		t.Run("v1beta1 "+strconv.FormatInt(seed, 10), func(t *testing.T) {
+			var original AutoscalingRunnerSet
+			f.Fuzz(&original)
+			original.TypeMeta = metav1.TypeMeta{}
+			// The status fields are only kept in the annotations of v1alpha1.
+			delete(original.Annotations, v1alpha1.AnnotationKeyRunnerScaleSetId)
+			delete(original.Annotations, v1alpha1.AnnotationKeyRunnerScaleSetRunnerGroupName)
+			original.Status.RunnerScaleSetId = 42
+			original.Status.RunnerGroupName = "default"
+
+			var hub v1alpha1.AutoscalingRunnerSet
+			require.NoError(t, original.DeepCopy().ConvertTo(&hub))
+			assert.Equal(t, "42", hub.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetId])
+
+			var roundTripped AutoscalingRunnerSet
+			require.NoError(t, roundTripped.ConvertFrom(&hub))
+
+			expected := original.DeepCopy()
+			expected.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetId] = "42"
+			expected.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetRunnerGroupName] = "default"
+			assert.Equal(t, *expected, roundTripped)
+		})
+	}
+}
+
+func TestAutoscalingRunnerSetConversion_AnnotationsWin(t *testing.T) {
+	src := &AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{v1alpha1.AnnotationKeyRunnerScaleSetId: "1"},
+		},
+		Status: AutoscalingRunnerSetStatus{RunnerScaleSetId: 2},
+	}
+
+	var dst v1alpha1.AutoscalingRunnerSet
+	require.NoError(t, src.ConvertTo(&dst))
+	assert.Equal(t, "1", dst.Annotations[v1alpha1.AnnotationKeyRunnerScaleSetId])
+}
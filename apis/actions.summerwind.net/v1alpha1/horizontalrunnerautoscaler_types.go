@@ -124,7 +124,7 @@ type CapacityReservation struct {
 type ScaleTargetRef struct {
 	// Kind is the type of resource being referenced
 	// +optional
-	// +kubebuilder:validation:Enum=RunnerDeployment;RunnerSet
+	// +kubebuilder:validation:Enum=RunnerDeployment;RunnerSet;AutoscalingRunnerSet
 	Kind string `json:"kind,omitempty"`
 
 	// Name is the name of resource being referenced
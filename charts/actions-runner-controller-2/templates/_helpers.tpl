@@ -88,6 +88,18 @@ Create the name of the service account to use
 {{- include "actions-runner-controller-2.fullname" . }}-leader-election-rolebinding
 {{- end }}
 
+{{- define "actions-runner-controller-2.controllerConfigMapName" -}}
+{{- include "actions-runner-controller-2.fullname" . }}-config
+{{- end }}
+
+{{- define "actions-runner-controller-2.controllerConfigRoleName" -}}
+{{- include "actions-runner-controller-2.fullname" . }}-config-reader
+{{- end }}
+
+{{- define "actions-runner-controller-2.externalMetricsName" -}}
+{{- include "actions-runner-controller-2.fullname" . }}-external-metrics
+{{- end }}
+
 {{- define "actions-runner-controller-2.imagePullSecretsNames" -}}
 {{- $names := list }}
 {{- range $k, $v := . }}
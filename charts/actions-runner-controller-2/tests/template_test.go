@@ -162,7 +162,7 @@ func TestTemplate_CreateManagerRole(t *testing.T) {
 
 	assert.Empty(t, managerRole.Namespace, "ClusterRole should not have a namespace")
 	assert.Equal(t, "test-arc-actions-runner-controller-2-manager-role", managerRole.Name)
-	assert.Equal(t, 17, len(managerRole.Rules))
+	assert.Equal(t, 23, len(managerRole.Rules))
 }
 
 func TestTemplate_ManagerRoleBinding(t *testing.T) {
@@ -477,6 +477,109 @@ func TestTemplate_EnableLeaderElection(t *testing.T) {
 	assert.Equal(t, "--leader-election-id=test-arc-actions-runner-controller-2", deployment.Spec.Template.Spec.Containers[0].Args[2])
 }
 
+func TestTemplate_EnableLeaderElection_Timing(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"replicaCount":                 "2",
+			"leaderElection.leaseDuration": "30s",
+			"leaderElection.renewDeadline": "20s",
+			"leaderElection.retryPeriod":   "5s",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	assert.Len(t, deployment.Spec.Template.Spec.Containers[0].Args, 6)
+	assert.Equal(t, "--enable-leader-election", deployment.Spec.Template.Spec.Containers[0].Args[1])
+	assert.Equal(t, "--leader-election-lease-duration=30s", deployment.Spec.Template.Spec.Containers[0].Args[3])
+	assert.Equal(t, "--leader-election-renew-deadline=20s", deployment.Spec.Template.Spec.Containers[0].Args[4])
+	assert.Equal(t, "--leader-election-retry-period=5s", deployment.Spec.Template.Spec.Containers[0].Args[5])
+}
+
+func TestTemplate_ControllerDeployment_Logging(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"logging.format": "json",
+			"logging.controllers.AutoscalingRunnerSet": "info",
+			"logging.controllers.EphemeralRunner":      "debug",
+			"logging.listener.level":                   "warn",
+			"logging.listener.format":                  "json",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	assert.Equal(t, []string{
+		"--auto-scaling-runner-set-only",
+		"--log-format=json",
+		"--controller-log-levels=AutoscalingRunnerSet=info,EphemeralRunner=debug",
+		"--listener-log-level=warn",
+		"--listener-log-format=json",
+	}, deployment.Spec.Template.Spec.Containers[0].Args)
+}
+
+func TestTemplate_ControllerConfigMap(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"controllerConfig.runnerVersionCheckInterval": "30m",
+			"controllerConfig.globalMaxRunners":           "100",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/controller_config_map.yaml"})
+
+	var configMap corev1.ConfigMap
+	helm.UnmarshalK8SYaml(t, output, &configMap)
+
+	assert.Equal(t, namespaceName, configMap.Namespace)
+	assert.Equal(t, "test-arc-actions-runner-controller-2-config", configMap.Name)
+	assert.Equal(t, map[string]string{"runnerVersionCheckInterval": "30m", "globalMaxRunners": "100"}, configMap.Data)
+
+	output = helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	assert.Len(t, deployment.Spec.Template.Spec.Containers[0].Args, 2)
+	assert.Equal(t, "--controller-config-map=test-arc-actions-runner-controller-2-config", deployment.Spec.Template.Spec.Containers[0].Args[1])
+}
+
 func TestTemplate_ControllerDeployment_ForwardImagePullSecrets(t *testing.T) {
 	t.Parallel()
 
@@ -506,3 +609,146 @@ func TestTemplate_ControllerDeployment_ForwardImagePullSecrets(t *testing.T) {
 	assert.Equal(t, "--auto-scaling-runner-set-only", deployment.Spec.Template.Spec.Containers[0].Args[0])
 	assert.Equal(t, "--auto-scaler-image-pull-secrets=dockerhub,ghcr", deployment.Spec.Template.Spec.Containers[0].Args[1])
 }
+
+func TestTemplate_ControllerDeployment_RunnerDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"runnerDiagnostics.url":                  "s3://arc-diagnostics/runners?region=us-east-1",
+			"runnerDiagnostics.timeout":              "2m",
+			"runnerDiagnostics.credentialsSecretRef": "arc-diagnostics-credentials",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{
+		"--auto-scaling-runner-set-only",
+		"--runner-diagnostics-url=s3://arc-diagnostics/runners?region=us-east-1",
+		"--runner-diagnostics-timeout=2m",
+	}, container.Args)
+	require.Len(t, container.EnvFrom, 1)
+	assert.Equal(t, "arc-diagnostics-credentials", container.EnvFrom[0].SecretRef.Name)
+	assert.Len(t, container.Env, 2)
+}
+
+func TestTemplate_ControllerDeployment_DindNativeSidecar(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"dindNativeSidecar": "disabled",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	assert.Equal(t, []string{
+		"--auto-scaling-runner-set-only",
+		"--dind-native-sidecar=disabled",
+	}, deployment.Spec.Template.Spec.Containers[0].Args)
+}
+
+func TestTemplate_ControllerDeployment_DefaultSecurityContext(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"defaultSecurityContext.pod.runAsNonRoot":                                 "true",
+			"defaultSecurityContext.container.capabilities.drop[0]":                   "ALL",
+			"controllerConfig.defaultContainerSecurityContext.readOnlyRootFilesystem": "true",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	assert.Equal(t, []string{
+		"--auto-scaling-runner-set-only",
+		`--default-pod-security-context={"runAsNonRoot":true}`,
+		`--default-container-security-context={"capabilities":{"drop":["ALL"]}}`,
+		"--controller-config-map=test-arc-actions-runner-controller-2-config",
+	}, deployment.Spec.Template.Spec.Containers[0].Args)
+
+	output = helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/controller_config_map.yaml"})
+
+	var configMap corev1.ConfigMap
+	helm.UnmarshalK8SYaml(t, output, &configMap)
+
+	assert.Equal(t, map[string]string{"defaultContainerSecurityContext": `{"readOnlyRootFilesystem":true}`}, configMap.Data)
+}
+
+func TestTemplate_ControllerDeployment_ExternalMetrics(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../actions-runner-controller-2")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"externalMetrics.enabled": "true",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{
+		"--auto-scaling-runner-set-only",
+		"--external-metrics-addr=:6443",
+		"--external-metrics-cert-dir=/etc/external-metrics/certs",
+	}, container.Args)
+	require.Len(t, container.Ports, 1)
+	assert.Equal(t, int32(6443), container.Ports[0].ContainerPort)
+	assert.Equal(t, "/etc/external-metrics/certs", container.VolumeMounts[1].MountPath)
+	assert.Equal(t, "test-arc-actions-runner-controller-2-external-metrics-certs", deployment.Spec.Template.Spec.Volumes[1].Secret.SecretName)
+
+	output = helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/external_metrics.yaml"})
+	assert.Contains(t, output, "kind: APIService")
+	assert.Contains(t, output, "name: v1beta1.external.metrics.k8s.io")
+	assert.Contains(t, output, "name: system:auth-delegator")
+	assert.Contains(t, output, "name: extension-apiserver-authentication-reader")
+	assert.Contains(t, output, "name: horizontal-pod-autoscaler")
+}
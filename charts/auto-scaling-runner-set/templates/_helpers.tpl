@@ -327,4 +327,120 @@ volumeMounts:
     {{- end }}
   {{- end }}
 {{- end }}
-{{- end }}
\ No newline at end of file
+{{- end }}
+{{- define "auto-scaling-runner-set.podman-init-container" -}}
+{{- range $i, $val := .Values.template.spec.containers -}}
+{{- if eq $val.name "runner" -}}
+image: {{ $val.image }}
+command: ["cp"]
+args: ["-r", "-v", "/actions-runner/externals/.", "/actions-runner/tmpDir/"]
+volumeMounts:
+  - name: podman-externals
+    mountPath: /actions-runner/tmpDir
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "auto-scaling-runner-set.podman-container" -}}
+image: {{ default "quay.io/podman/stable" .Values.containerMode.podman.image }}
+command: ["podman"]
+args: ["--storage-driver=vfs", "system", "service", "--time=0", "unix:///run/podman/podman.sock"]
+securityContext:
+  ## The podman user of the image, and the docker group of the runner image, so that the runner can use the socket.
+  runAsUser: 1000
+  runAsGroup: 123
+  capabilities:
+    add: ["SETUID", "SETGID"]
+volumeMounts:
+  - name: work
+    mountPath: /actions-runner/_work
+  - name: podman-socket
+    mountPath: /run/podman
+  - name: podman-externals
+    mountPath: /actions-runner/externals
+{{- end }}
+
+{{- define "auto-scaling-runner-set.podman-volume" -}}
+{{- if .Values.containerMode.podman.hostSocketPath }}
+- name: podman-socket
+  hostPath:
+    path: {{ .Values.containerMode.podman.hostSocketPath }}
+    type: Socket
+{{- else }}
+- name: podman-socket
+  emptyDir: {}
+- name: podman-externals
+  emptyDir: {}
+{{- end }}
+{{- end }}
+
+{{- define "auto-scaling-runner-set.podman-runner-container" -}}
+{{- $hostSocket := .Values.containerMode.podman.hostSocketPath }}
+{{- range $i, $container := .Values.template.spec.containers -}}
+  {{- if eq $container.name "runner" -}}
+    {{- range $key, $val := $container }}
+      {{- if and (ne $key "env") (ne $key "volumeMounts") (ne $key "name") }}
+{{ $key }}: {{ $val }}
+      {{- end }}
+    {{- end }}
+    {{- $setDockerHost := 1 }}
+    {{- $setRunnerWaitDocker := 1 }}
+env:
+    {{- with $container.env }}
+      {{- range $i, $env := . }}
+        {{- if eq $env.name "DOCKER_HOST" }}
+          {{- $setDockerHost = 0 -}}
+        {{- end }}
+        {{- if eq $env.name "RUNNER_WAIT_FOR_DOCKER_IN_SECONDS" }}
+          {{- $setRunnerWaitDocker = 0 -}}
+        {{- end }}
+  - name: {{ $env.name }}
+        {{- range $envKey, $envVal := $env }}
+          {{- if ne $envKey "name" }}
+    {{ $envKey }}: {{ $envVal | toYaml | nindent 8 }}
+          {{- end }}
+        {{- end }}
+      {{- end }}
+    {{- end }}
+    {{- if $setDockerHost }}
+  - name: DOCKER_HOST
+    value: unix:///run/podman/podman.sock
+    {{- end }}
+    {{- if $setRunnerWaitDocker }}
+  - name: RUNNER_WAIT_FOR_DOCKER_IN_SECONDS
+    value: "120"
+    {{- end }}
+    {{- $mountWork := 1 }}
+    {{- $mountPodmanSocket := 1 }}
+volumeMounts:
+    {{- with $container.volumeMounts }}
+      {{- range $i, $volMount := . }}
+        {{- if eq $volMount.name "work" }}
+          {{- $mountWork = 0 -}}
+        {{- end }}
+        {{- if eq $volMount.name "podman-socket" }}
+          {{- $mountPodmanSocket = 0 -}}
+        {{- end }}
+  - name: {{ $volMount.name }}
+        {{- range $mountKey, $mountVal := $volMount }}
+          {{- if ne $mountKey "name" }}
+    {{ $mountKey }}: {{ $mountVal | toYaml | nindent 8 }}
+          {{- end }}
+        {{- end }}
+      {{- end }}
+    {{- end }}
+    {{- if $mountWork }}
+  - name: work
+    mountPath: /actions-runner/_work
+    {{- end }}
+    {{- if $mountPodmanSocket }}
+  - name: podman-socket
+    {{- if $hostSocket }}
+    mountPath: /run/podman/podman.sock
+    {{- else }}
+    mountPath: /run/podman
+    {{- end }}
+    {{- end }}
+  {{- end }}
+{{- end }}
+{{- end }}
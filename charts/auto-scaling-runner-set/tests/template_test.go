@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	v1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/gruntwork-io/terratest/modules/helm"
@@ -121,7 +122,7 @@ func TestTemplateRenderedGitHubSecretErrorWithMissingAppInput(t *testing.T) {
 	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/githubsecret.yaml"})
 	require.Error(t, err)
 
-	assert.ErrorContains(t, err, "provide .Values.githubConfigSecret.github_app_installation_id and .Values.githubConfigSecret.github_app_private_key")
+	assert.ErrorContains(t, err, "provide .Values.githubConfigSecret.github_app_private_key")
 }
 
 func TestTemplateNotRenderedGitHubSecretWithPredefinedSecret(t *testing.T) {
@@ -310,6 +311,36 @@ func TestTemplateRenderedAutoScalingRunnerSet(t *testing.T) {
 	assert.Equal(t, "ghcr.io/actions/actions-runner:latest", ars.Spec.Template.Spec.Containers[0].Image)
 }
 
+func TestTemplateRenderedAutoScalingRunnerSet_RunnerScaleSetNameAndDeletionPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                 "https://github.com/actions",
+			"githubConfigSecret.github_token": "gh_token12345",
+			"runnerScaleSetName":              "linux-x64",
+			"deletionPolicy":                  "Retain",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	assert.Equal(t, "test-runners", ars.Name)
+	assert.Equal(t, "linux-x64", ars.Spec.RunnerScaleSetName)
+	assert.Equal(t, v1alpha1.RetainDeletionPolicy, ars.Spec.DeletionPolicy)
+}
+
 func TestTemplateRenderedAutoScalingRunnerSet_ProvideMetadata(t *testing.T) {
 	t.Parallel()
 
@@ -489,6 +520,99 @@ func TestTemplateRenderedAutoScalingRunnerSet_MinMaxRunnersValidation_OnlyMin(t
 	assert.Nil(t, ars.Spec.MaxRunners, "MaxRunners should be nil")
 }
 
+func TestTemplateRenderedAutoScalingRunnerSet_IdleRunnerTimeoutAndScaleDownPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                 "https://github.com/actions",
+			"githubConfigSecret.github_token": "gh_token12345",
+			"minRunners":                      "1",
+			"idleRunnerTimeout":               "30m",
+			"scaleDownPolicy":                 "newest",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	assert.Equal(t, 1, *ars.Spec.MinRunners)
+	require.NotNil(t, ars.Spec.IdleRunnerTimeout)
+	assert.Equal(t, 30*time.Minute, ars.Spec.IdleRunnerTimeout.Duration)
+	assert.Equal(t, v1alpha1.ScaleDownPolicyNewest, ars.Spec.ScaleDownPolicy)
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_MinIdleRunners(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                 "https://github.com/actions",
+			"githubConfigSecret.github_token": "gh_token12345",
+			"minIdleRunners":                  "2",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.MinIdleRunners)
+	assert.Equal(t, 2, *ars.Spec.MinIdleRunners)
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_FairShareWeight(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                 "https://github.com/actions",
+			"githubConfigSecret.github_token": "gh_token12345",
+			"fairShareWeight":                 "3",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.FairShareWeight)
+	assert.Equal(t, 3, *ars.Spec.FairShareWeight)
+
+	options.SetValues["fairShareWeight"] = "0"
+	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "fairShareWeight has to be greater or equal to 1")
+}
+
 func TestTemplateRenderedAutoScalingRunnerSet_MinMaxRunnersValidation_OnlyMax(t *testing.T) {
 	t.Parallel()
 
@@ -682,6 +806,65 @@ func TestTemplateRenderedAutoScalingRunnerSet_EnableKubernetesMode(t *testing.T)
 	assert.NotNil(t, ars.Spec.Template.Spec.Volumes[0].Ephemeral, "Template.Spec should have 1 ephemeral volume")
 }
 
+func TestTemplateRenderedAutoScalingRunnerSet_EnablePodmanMode(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                 "https://github.com/actions",
+			"githubConfigSecret.github_token": "gh_token12345",
+			"containerMode.type":              "podman",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	assert.Len(t, ars.Spec.Template.Spec.InitContainers, 1, "Template.Spec should have 1 init container")
+	assert.Equal(t, "init-podman-externals", ars.Spec.Template.Spec.InitContainers[0].Name)
+
+	assert.Len(t, ars.Spec.Template.Spec.Containers, 2, "Template.Spec should have 2 containers")
+	runner := ars.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "runner", runner.Name)
+	assert.Len(t, runner.Env, 2, "The runner container should have 2 env vars, DOCKER_HOST and RUNNER_WAIT_FOR_DOCKER_IN_SECONDS")
+	assert.Equal(t, "DOCKER_HOST", runner.Env[0].Name)
+	assert.Equal(t, "unix:///run/podman/podman.sock", runner.Env[0].Value)
+	assert.Len(t, runner.VolumeMounts, 2, "The runner container should have 2 volume mounts, work and podman-socket")
+	assert.Equal(t, "podman-socket", runner.VolumeMounts[1].Name)
+	assert.Equal(t, "/run/podman", runner.VolumeMounts[1].MountPath)
+
+	podman := ars.Spec.Template.Spec.Containers[1]
+	assert.Equal(t, "podman", podman.Name)
+	assert.Equal(t, "quay.io/podman/stable", podman.Image)
+	assert.Nil(t, podman.SecurityContext.Privileged, "The podman container is not privileged")
+	assert.Equal(t, int64(1000), *podman.SecurityContext.RunAsUser)
+
+	assert.Len(t, ars.Spec.Template.Spec.Volumes, 3, "Template.Spec should have 3 volumes, podman-socket, podman-externals and work")
+
+	// The Docker API compatible socket of the nodes replaces the sidecar.
+	options.SetValues["containerMode.podman.hostSocketPath"] = "/run/user/1000/podman/podman.sock"
+	output = helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	ars = v1alpha1.AutoscalingRunnerSet{}
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	assert.Empty(t, ars.Spec.Template.Spec.InitContainers)
+	assert.Len(t, ars.Spec.Template.Spec.Containers, 1, "Template.Spec should have 1 container")
+	assert.Equal(t, "/run/podman/podman.sock", ars.Spec.Template.Spec.Containers[0].VolumeMounts[1].MountPath)
+	require.Len(t, ars.Spec.Template.Spec.Volumes, 2, "Template.Spec should have 2 volumes, podman-socket and work")
+	assert.Equal(t, "/run/user/1000/podman/podman.sock", ars.Spec.Template.Spec.Volumes[0].HostPath.Path)
+}
+
 func TestTemplateRenderedAutoScalingRunnerSet_UsePredefinedSecret(t *testing.T) {
 	t.Parallel()
 
@@ -714,6 +897,260 @@ func TestTemplateRenderedAutoScalingRunnerSet_UsePredefinedSecret(t *testing.T)
 	assert.Equal(t, "pre-defined-secrets", ars.Spec.GitHubConfigSecret)
 }
 
+func TestTemplateRenderedAutoScalingRunnerSet_ImagePullSecrets(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":          "https://github.com/actions",
+			"githubConfigSecret":       "pre-defined-secrets",
+			"imagePullSecrets[0].name": "my-registry",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.Len(t, ars.Spec.ImagePullSecrets, 1)
+	assert.Equal(t, "my-registry", ars.Spec.ImagePullSecrets[0].Name)
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_Hooks(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                 "https://github.com/actions",
+			"githubConfigSecret":              "pre-defined-secrets",
+			"hooks.jobStarted.configMapRef":   "runner-hooks",
+			"hooks.jobStarted.configMapKey":   "job-started.sh",
+			"hooks.jobCompleted.configMapRef": "runner-hooks",
+			"hooks.jobCompleted.configMapKey": "job-completed.sh",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.Hooks)
+	assert.Equal(t, &v1alpha1.RunnerHookScript{ConfigMapRef: "runner-hooks", ConfigMapKey: "job-started.sh"}, ars.Spec.Hooks.JobStarted)
+	assert.Equal(t, &v1alpha1.RunnerHookScript{ConfigMapRef: "runner-hooks", ConfigMapKey: "job-completed.sh"}, ars.Spec.Hooks.JobCompleted)
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_WorkVolumeClaimTemplate(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                                         "https://github.com/actions",
+			"githubConfigSecret":                                      "pre-defined-secrets",
+			"workVolumeClaimTemplate.spec.accessModes[0]":             "ReadWriteOnce",
+			"workVolumeClaimTemplate.spec.storageClassName":           "standard",
+			"workVolumeClaimTemplate.spec.resources.requests.storage": "100Gi",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.WorkVolumeClaimTemplate)
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, ars.Spec.WorkVolumeClaimTemplate.Spec.AccessModes)
+	assert.Equal(t, "standard", *ars.Spec.WorkVolumeClaimTemplate.Spec.StorageClassName)
+	assert.Equal(t, "100Gi", ars.Spec.WorkVolumeClaimTemplate.Spec.Resources.Requests.Storage().String())
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_ToolCache(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                           "https://github.com/actions",
+			"githubConfigSecret":                        "pre-defined-secrets",
+			"toolCache.spec.storageClassName":           "nfs",
+			"toolCache.spec.resources.requests.storage": "50Gi",
+			"toolCache.readOnly":                        "true",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.ToolCache)
+	assert.Equal(t, "nfs", *ars.Spec.ToolCache.Spec.StorageClassName)
+	assert.Equal(t, "50Gi", ars.Spec.ToolCache.Spec.Resources.Requests.Storage().String())
+	assert.True(t, ars.Spec.ToolCache.ReadOnly)
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_DockerLayerCache(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                   "https://github.com/actions",
+			"githubConfigSecret":                "pre-defined-secrets",
+			"containerMode.type":                "dind",
+			"dockerLayerCache.hostPath":         "/mnt/disks/ssd0/docker",
+			"dockerLayerCache.maxCachesPerNode": "2",
+			"dockerLayerCache.sizeLimit":        "50Gi",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.DockerLayerCache)
+	assert.Equal(t, "/mnt/disks/ssd0/docker", ars.Spec.DockerLayerCache.HostPath)
+	assert.Equal(t, 2, *ars.Spec.DockerLayerCache.MaxCachesPerNode)
+	assert.Equal(t, "50Gi", ars.Spec.DockerLayerCache.SizeLimit.String())
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_NetworkPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                   "https://github.com/actions",
+			"githubConfigSecret":                "pre-defined-secrets",
+			"networkPolicy.enabled":             "true",
+			"networkPolicy.extraEgressCIDRs[0]": "10.0.0.0/8",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.NetworkPolicy)
+	assert.True(t, ars.Spec.NetworkPolicy.Enabled)
+	assert.Equal(t, []string{"10.0.0.0/8"}, ars.Spec.NetworkPolicy.ExtraEgressCIDRs)
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_RunnerServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":                     "https://github.com/actions",
+			"githubConfigSecret":                  "pre-defined-secrets",
+			"runnerServiceAccount.aws.roleARN":    "arn:aws:iam::111122223333:role/runners",
+			"runnerServiceAccount.azure.clientID": "00000000-0000-0000-0000-000000000000",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	require.NotNil(t, ars.Spec.RunnerServiceAccount)
+	assert.Equal(t, "arn:aws:iam::111122223333:role/runners", ars.Spec.RunnerServiceAccount.AWS.RoleARN)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", ars.Spec.RunnerServiceAccount.Azure.ClientID)
+	assert.Empty(t, ars.Spec.Template.Spec.ServiceAccountName, "The controller sets the service account it creates")
+
+	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/no_permission_serviceaccount.yaml"})
+	assert.ErrorContains(t, err, "could not find template templates/no_permission_serviceaccount.yaml in chart")
+
+	options.SetValues["containerMode.type"] = "kubernetes"
+	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+	assert.ErrorContains(t, err, "runnerServiceAccount is not supported with containerMode.type kubernetes")
+}
+
+func TestTemplateRenderedAutoScalingRunnerSet_ListenerImage(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		SetValues: map[string]string{
+			"githubConfigUrl":         "https://github.com/actions",
+			"githubConfigSecret":      "pre-defined-secrets",
+			"listenerImage":           "registry.example.com/listener:0.4.0",
+			"listenerImagePullPolicy": "Always",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+
+	var ars v1alpha1.AutoscalingRunnerSet
+	helm.UnmarshalK8SYaml(t, output, &ars)
+
+	assert.Equal(t, "registry.example.com/listener:0.4.0", ars.Spec.ListenerImage)
+	assert.Equal(t, corev1.PullAlways, ars.Spec.ListenerImagePullPolicy)
+}
+
 func TestTemplateRenderedAutoScalingRunnerSet_ErrorOnEmptyPredefinedSecret(t *testing.T) {
 	t.Parallel()
 
@@ -737,3 +1174,31 @@ func TestTemplateRenderedAutoScalingRunnerSet_ErrorOnEmptyPredefinedSecret(t *te
 
 	assert.ErrorContains(t, err, "Values.githubConfigSecret is required for setting auth with GitHub server")
 }
+
+func TestTemplateRenderedAutoScalingRunnerSet_WindowsContainerModeValidationError(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../auto-scaling-runner-set")
+	require.NoError(t, err)
+
+	releaseName := "test-runners"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	for _, containerMode := range []string{"dind", "kubernetes"} {
+		options := &helm.Options{
+			SetValues: map[string]string{
+				"githubConfigUrl":                               "https://github.com/actions",
+				"githubConfigSecret.github_token":               "gh_token12345",
+				"containerMode.type":                            containerMode,
+				"template.spec.nodeSelector.kubernetes\\.io/os": "windows",
+			},
+			KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+		}
+
+		_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/autoscalingrunnerset.yaml"})
+		require.Error(t, err)
+
+		assert.ErrorContains(t, err, "containerMode.type dind and kubernetes are not supported on Windows runners")
+	}
+}
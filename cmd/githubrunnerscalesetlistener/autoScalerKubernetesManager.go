@@ -4,13 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 )
 
 type AutoScalerKubernetesManager struct {
@@ -37,7 +42,7 @@ func NewKubernetesManager(logger *logr.Logger) (*AutoScalerKubernetesManager, er
 	return manager, nil
 }
 
-func (k *AutoScalerKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Context, namespace, resourceName string, runnerCount int) error {
+func (k *AutoScalerKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Context, namespace, resourceName string, runnerCount int, trigger v1alpha1.ScaleTrigger) error {
 	original := &v1alpha1.EphemeralRunnerSet{
 		Spec: v1alpha1.EphemeralRunnerSetSpec{
 			Replicas: -1,
@@ -49,6 +54,12 @@ func (k *AutoScalerKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Contex
 	}
 
 	patch := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				v1alpha1.AnnotationKeyScaleTrigger:         string(trigger),
+				v1alpha1.AnnotationKeyScaleTriggerReplicas: strconv.Itoa(runnerCount),
+			},
+		},
 		Spec: v1alpha1.EphemeralRunnerSetSpec{
 			Replicas: runnerCount,
 		},
@@ -82,7 +93,241 @@ func (k *AutoScalerKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Contex
 	return nil
 }
 
-func (k *AutoScalerKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx context.Context, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName string, workflowRunId, jobRequestId int64) error {
+func (k *AutoScalerKubernetesManager) ScaleEphemeralRunnerSetVariants(ctx context.Context, namespace, resourceName string, variantReplicas map[string]int) error {
+	originalJson, err := json.Marshal(&v1alpha1.EphemeralRunnerSet{})
+	if err != nil {
+		return fmt.Errorf("could not marshal empty ephemeral runner set, error: %w", err)
+	}
+
+	patch := &v1alpha1.EphemeralRunnerSet{
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			VariantReplicas: variantReplicas,
+		},
+	}
+	patchJson, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not marshal patch ephemeral runner set, error: %w", err)
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(originalJson, patchJson)
+	if err != nil {
+		return fmt.Errorf("could not create merge patch json for ephemeral runner set, error: %w", err)
+	}
+
+	k.logger.Info("Created merge patch json for EphemeralRunnerSet variant update", "json", string(mergePatch))
+
+	patchedEphemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
+	err = k.RESTClient().
+		Patch(types.MergePatchType).
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunnerSets").
+		Name(resourceName).
+		Body([]byte(mergePatch)).
+		Do(ctx).
+		Into(patchedEphemeralRunnerSet)
+	if err != nil {
+		return fmt.Errorf("could not patch ephemeral runner set , patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+
+	k.logger.Info("Ephemeral runner set variants scaled.", "namespace", namespace, "name", resourceName, "variantReplicas", patchedEphemeralRunnerSet.Spec.VariantReplicas)
+	return nil
+}
+
+// GetEphemeralRunnerSetPendingRunners returns the number of runners of the ephemeral runner set whose pod isn't running yet.
+func (k *AutoScalerKubernetesManager) GetEphemeralRunnerSetPendingRunners(ctx context.Context, namespace, resourceName string) (int, error) {
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
+	err := k.RESTClient().
+		Get().
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunnerSets").
+		Name(resourceName).
+		Do(ctx).
+		Into(ephemeralRunnerSet)
+	if err != nil {
+		return 0, fmt.Errorf("could not get ephemeral runner set, error: %w", err)
+	}
+
+	status := ephemeralRunnerSet.Status
+	pending := status.CurrentReplicas - status.RunningReplicas - status.FailedReplicas
+	if pending < 0 {
+		pending = 0
+	}
+	return pending, nil
+}
+
+// GetEphemeralRunnerSetListenerScaleSettings returns the scale settings the controller set on the ephemeral runner set,
+// or nil if it didn't set any.
+func (k *AutoScalerKubernetesManager) GetEphemeralRunnerSetListenerScaleSettings(ctx context.Context, namespace, resourceName string) (*v1alpha1.ListenerScaleSettings, error) {
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
+	err := k.RESTClient().
+		Get().
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunnerSets").
+		Name(resourceName).
+		Do(ctx).
+		Into(ephemeralRunnerSet)
+	if err != nil {
+		return nil, fmt.Errorf("could not get ephemeral runner set, error: %w", err)
+	}
+
+	return ephemeralRunnerSet.Spec.ListenerScaleSettings, nil
+}
+
+func (k *AutoScalerKubernetesManager) RecordEphemeralRunnerSetLastMessage(ctx context.Context, namespace, resourceName string, messageId int64, processedAt time.Time, statistics *actions.RunnerScaleSetStatistic) error {
+	patch := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				v1alpha1.AnnotationKeyLastListenerMessageId:   strconv.FormatInt(messageId, 10),
+				v1alpha1.AnnotationKeyLastListenerMessageTime: processedAt.UTC().Format(time.RFC3339),
+			},
+		},
+	}
+	if statistics != nil {
+		statisticsJson, err := json.Marshal(statistics)
+		if err != nil {
+			return fmt.Errorf("could not marshal job statistics, error: %w", err)
+		}
+		patch.Annotations[v1alpha1.AnnotationKeyJobStatistics] = string(statisticsJson)
+	}
+	originalJson, err := json.Marshal(&v1alpha1.EphemeralRunnerSet{})
+	if err != nil {
+		return fmt.Errorf("could not marshal empty ephemeral runner set, error: %w", err)
+	}
+
+	patchJson, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not marshal patch ephemeral runner set, error: %w", err)
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(originalJson, patchJson)
+	if err != nil {
+		return fmt.Errorf("could not create merge patch json for ephemeral runner set, error: %w", err)
+	}
+
+	err = k.RESTClient().
+		Patch(types.MergePatchType).
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunnerSets").
+		Name(resourceName).
+		Body(mergePatch).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("could not patch ephemeral runner set , patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+
+	return nil
+}
+
+// RemoveEphemeralRunnerSetQueuedJobHints removes a queued job hint of the workflow run of each job, once the listener
+// counts the job itself. The controller adds hints concurrently, so they are removed with an optimistic lock.
+func (k *AutoScalerKubernetesManager) RemoveEphemeralRunnerSetQueuedJobHints(ctx context.Context, namespace, resourceName string, workflowRunIds []int64) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
+		err := k.RESTClient().
+			Get().
+			Prefix("apis", "actions.github.com", "v1alpha1").
+			Namespace(namespace).
+			Resource("EphemeralRunnerSets").
+			Name(resourceName).
+			Do(ctx).
+			Into(ephemeralRunnerSet)
+		if err != nil {
+			return fmt.Errorf("could not get ephemeral runner set, error: %w", err)
+		}
+
+		hints := removeQueuedJobHints(ephemeralRunnerSet.Spec.QueuedJobHints, workflowRunIds)
+		if len(hints) == len(ephemeralRunnerSet.Spec.QueuedJobHints) {
+			return nil
+		}
+
+		patch := map[string]interface{}{
+			"metadata": map[string]interface{}{"resourceVersion": ephemeralRunnerSet.ResourceVersion},
+			"spec":     map[string]interface{}{"queuedJobHints": hints},
+		}
+		mergePatch, err := json.Marshal(patch)
+		if err != nil {
+			return fmt.Errorf("could not marshal patch ephemeral runner set, error: %w", err)
+		}
+
+		k.logger.Info("Removing queued job hints of the ephemeral runner set.", "namespace", namespace, "name", resourceName, "removed", len(ephemeralRunnerSet.Spec.QueuedJobHints)-len(hints))
+		return k.RESTClient().
+			Patch(types.MergePatchType).
+			Prefix("apis", "actions.github.com", "v1alpha1").
+			Namespace(namespace).
+			Resource("EphemeralRunnerSets").
+			Name(resourceName).
+			Body(mergePatch).
+			Do(ctx).
+			Error()
+	})
+}
+
+// removeQueuedJobHints removes the oldest hint of the workflow run of each job.
+func removeQueuedJobHints(hints []v1alpha1.QueuedJobHint, workflowRunIds []int64) []v1alpha1.QueuedJobHint {
+	remaining := append([]v1alpha1.QueuedJobHint{}, hints...)
+	for _, id := range workflowRunIds {
+		for i, hint := range remaining {
+			if hint.WorkflowRunId == id {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return remaining
+}
+
+// RecordEphemeralRunnerSetCircuitBreaker records the circuit breaker of the listener on the ephemeral runner set,
+// where the controller picks it up into the status of the AutoscalingListener.
+func (k *AutoScalerKubernetesManager) RecordEphemeralRunnerSetCircuitBreaker(ctx context.Context, namespace, resourceName string, status *v1alpha1.ListenerCircuitBreakerStatus) error {
+	statusJson, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("could not marshal circuit breaker status, error: %w", err)
+	}
+
+	patch := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				v1alpha1.AnnotationKeyListenerCircuitBreaker: string(statusJson),
+			},
+		},
+	}
+	originalJson, err := json.Marshal(&v1alpha1.EphemeralRunnerSet{})
+	if err != nil {
+		return fmt.Errorf("could not marshal empty ephemeral runner set, error: %w", err)
+	}
+
+	patchJson, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not marshal patch ephemeral runner set, error: %w", err)
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(originalJson, patchJson)
+	if err != nil {
+		return fmt.Errorf("could not create merge patch json for ephemeral runner set, error: %w", err)
+	}
+
+	err = k.RESTClient().
+		Patch(types.MergePatchType).
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunnerSets").
+		Name(resourceName).
+		Body(mergePatch).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("could not patch ephemeral runner set , patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+
+	return nil
+}
+
+func (k *AutoScalerKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx context.Context, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName string, workflowRunId, jobRequestId int64, requestLabels []string) error {
 	original := &v1alpha1.EphemeralRunner{}
 	originalJson, err := json.Marshal(original)
 	if err != nil {
@@ -96,6 +341,7 @@ func (k *AutoScalerKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx conte
 			WorkflowRunId:     workflowRunId,
 			JobWorkflowRef:    jobWorkflowRef,
 			JobDisplayName:    jobDisplayName,
+			JobRequestLabels:  requestLabels,
 		},
 	}
 	patchedJson, err := json.Marshal(patch)
@@ -127,3 +373,42 @@ func (k *AutoScalerKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx conte
 
 	return nil
 }
+
+func (k *AutoScalerKubernetesManager) UpdateEphemeralRunnerWithJobResult(ctx context.Context, namespace, resourceName, result string) error {
+	original := &v1alpha1.EphemeralRunner{}
+	originalJson, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("could not marshal empty ephemeral runner, error: %w", err)
+	}
+
+	patch := &v1alpha1.EphemeralRunner{
+		Status: v1alpha1.EphemeralRunnerStatus{
+			JobResult: result,
+		},
+	}
+	patchedJson, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not marshal patched ephemeral runner, error: %w", err)
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(originalJson, patchedJson)
+	if err != nil {
+		return fmt.Errorf("could not create merge patch json for ephemeral runner, error: %w", err)
+	}
+
+	err = k.RESTClient().
+		Patch(types.MergePatchType).
+		Prefix("apis", "actions.github.com", "v1alpha1").
+		Namespace(namespace).
+		Resource("EphemeralRunners").
+		Name(resourceName).
+		SubResource("status").
+		Body(mergePatch).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("could not patch ephemeral runner status, patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+
+	return nil
+}
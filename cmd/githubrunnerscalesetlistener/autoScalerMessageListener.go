@@ -17,6 +17,9 @@ import (
 
 const (
 	sessionCreationMaxRetryCount = 10
+
+	// messageDeletionTimeout bounds the deletion of the last processed message when the listener stops.
+	messageDeletionTimeout = 10 * time.Second
 )
 
 type devContextKey bool
@@ -29,6 +32,9 @@ type AutoScalerClient struct {
 
 	lastMessageId  int64
 	initialMessage *actions.RunnerScaleSetMessage
+
+	// health is told about every successful poll of the message queue, if set.
+	health *healthProbes
 }
 
 func NewAutoScalerClient(
@@ -138,6 +144,9 @@ func (m *AutoScalerClient) GetRunnerScaleSetMessage(ctx context.Context, handler
 		if err != nil {
 			return fmt.Errorf("get message failed from refreshing client. %w", err)
 		}
+		if m.health != nil {
+			m.health.polled()
+		}
 
 		if message == nil {
 			continue
@@ -150,6 +159,14 @@ func (m *AutoScalerClient) GetRunnerScaleSetMessage(ctx context.Context, handler
 
 		m.lastMessageId = message.MessageId
 
+		if ctx.Err() != nil {
+			// The listener is stopping, the processed message still has to be deleted
+			// so that the next listener doesn't process it again.
+			deleteCtx, cancel := context.WithTimeout(context.Background(), messageDeletionTimeout)
+			defer cancel()
+			return m.deleteMessage(deleteCtx, message.MessageId)
+		}
+
 		return m.deleteMessage(ctx, message.MessageId)
 	}
 }
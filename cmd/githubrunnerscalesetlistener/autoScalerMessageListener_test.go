@@ -340,6 +340,49 @@ func TestGetRunnerScaleSetMessage(t *testing.T) {
 	assert.True(t, mockSessionClient.AssertExpectations(t), "All expectations should be met")
 }
 
+func TestGetRunnerScaleSetMessage_DeleteMessageWhenStopping(t *testing.T) {
+	mockActionsClient := &actions.MockActionsService{}
+	mockSessionClient := &actions.MockSessionService{}
+	logger, err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sessionId := uuid.New()
+	session := &actions.RunnerScaleSetSession{
+		SessionId:               &sessionId,
+		OwnerName:               "owner",
+		MessageQueueUrl:         "https://github.com",
+		MessageQueueAccessToken: "token",
+		RunnerScaleSet: &actions.RunnerScaleSet{
+			Id: 1,
+		},
+		Statistics: &actions.RunnerScaleSetStatistic{},
+	}
+	mockActionsClient.On("CreateMessageSession", ctx, 1, mock.Anything).Return(session, nil)
+	mockSessionClient.On("GetMessage", ctx, int64(0)).Return(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "test",
+		Body:        "test",
+	}, nil)
+	mockSessionClient.On("DeleteMessage", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), int64(1)).Return(nil)
+
+	asClient, err := NewAutoScalerClient(ctx, mockActionsClient, &logger, 1, func(asc *AutoScalerClient) {
+		asc.client = mockSessionClient
+	})
+	require.NoError(t, err, "Error creating autoscaler client")
+
+	err = asClient.GetRunnerScaleSetMessage(ctx, func(msg *actions.RunnerScaleSetMessage) error {
+		// The listener is asked to stop while the message is processed.
+		cancel()
+		return nil
+	})
+
+	assert.NoError(t, err, "Error getting message")
+	assert.True(t, mockSessionClient.AssertExpectations(t), "Processed message should be deleted")
+}
+
 func TestGetRunnerScaleSetMessage_HandleFailed(t *testing.T) {
 	mockActionsClient := &actions.MockActionsService{}
 	mockSessionClient := &actions.MockSessionService{}
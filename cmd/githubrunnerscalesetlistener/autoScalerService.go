@@ -5,10 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ScaleSettings struct {
@@ -16,15 +22,89 @@ type ScaleSettings struct {
 	ResourceName string
 	MinRunners   int
 	MaxRunners   int
+
+	// TemplateVariantLabels are the labels selecting each runner template variant.
+	TemplateVariantLabels map[string][]string
+
+	// HoldJobsAboveMaxRunners leaves the jobs that would take the scale set above MaxRunners available,
+	// so that the scale set it overflows into acquires them.
+	HoldJobsAboveMaxRunners bool
+
+	// OverflowSourceLabels are the labels of the scale sets overflowing into this one. Their jobs are
+	// only acquired once they waited overflowJobDelay, and while the scale set is below MaxRunners.
+	OverflowSourceLabels []string
+
+	// MaxJobsPerRepository and MaxJobsPerWorkflow cap the jobs acquired at the same time
+	// for a single repository or workflow. Zero means unlimited.
+	MaxJobsPerRepository int
+	MaxJobsPerWorkflow   int
+
+	// JobAcquisitionBatchSize is the maximum number of jobs acquired in a single call.
+	// Zero means defaultJobAcquisitionBatchSize.
+	JobAcquisitionBatchSize int
+
+	// ScalingBufferPercent adds runners on top of the assigned jobs, as a percentage of them rounded up.
+	ScalingBufferPercent int
+
+	// ScalingSmoothingPercent is the weight of the latest desired runners in their exponentially weighted
+	// moving average, which scales down instead. Scaling up isn't smoothed. Zero and 100 disable the smoothing.
+	ScalingSmoothingPercent int
+
+	// MaxPendingRunners pauses the acquisition of jobs while more runners than this wait for their pod to run.
+	// Zero means unlimited.
+	MaxPendingRunners int
+
+	// ScaleSetName is the autoscaling runner set the metrics are labeled with.
+	ScaleSetName string
 }
 
+// defaultJobAcquisitionBatchSize bounds the jobs acquired in a single call when the scale set doesn't set a batch size.
+const defaultJobAcquisitionBatchSize = 100
+
+// lastMessageReportInterval limits how often the last processed message is
+// recorded on the ephemeral runner set while the job statistics don't change.
+const lastMessageReportInterval = time.Minute
+
+// jobQueuedRetention bounds how long the queued time of a job is kept
+// when the listener never sees it started, e.g. when it is canceled or taken by another scale set.
+const jobQueuedRetention = 24 * time.Hour
+
+// scalingSmoothingInterval is how often the smoothed runners are scaled down again towards the assigned jobs
+// when no message arrives.
+const scalingSmoothingInterval = 30 * time.Second
+
+// jobAssignedRetention bounds how long the assigned time of a job is kept
+// when the listener never sees it completed, e.g. when the listener missed the message.
+const jobAssignedRetention = 7 * 24 * time.Hour
+
 type Service struct {
-	ctx                context.Context
-	logger             logr.Logger
-	rsClient           RunnerScaleSetClient
-	kubeManager        KubernetesManager
-	settings           *ScaleSettings
-	currentRunnerCount int
+	// ctx is done when the service is asked to stop. workCtx outlives it while the in-flight message
+	// is processed, so that the decisions about it are still applied.
+	ctx                    context.Context
+	workCtx                context.Context
+	logger                 logr.Logger
+	rsClient               RunnerScaleSetClient
+	kubeManager            KubernetesManager
+	settings               *ScaleSettings
+	currentRunnerCount     int
+	smoothedRunnerCount    *float64
+	assignedJobCount       int
+	jobTemplateVariants    map[int64]string
+	currentVariantReplicas map[string]int
+	lastMessageReportedAt  time.Time
+	lastReportedStatistics *actions.RunnerScaleSetStatistic
+	jobLimiter             *jobConcurrencyLimiter
+	heldJobs               []int64
+	jobsAboveMaxRunners    *overflowJobs
+	overflowSourceJobs     *overflowJobs
+	jobQueuedAt            map[int64]time.Time
+	jobAssignedAt          map[int64]time.Time
+	circuitBreaker         *circuitBreaker
+	now                    func() time.Time
+	after                  func(time.Duration) <-chan time.Time
+
+	// mu serializes the scaling decisions of the messages and of the smoothing timer.
+	mu sync.Mutex
 }
 
 func NewService(
@@ -36,12 +116,23 @@ func NewService(
 ) *Service {
 	s := &Service{
 		ctx:                ctx,
+		workCtx:            ctx,
 		rsClient:           rsClient,
 		kubeManager:        manager,
 		settings:           settings,
 		currentRunnerCount: 0,
 		logger:             logr.FromContextOrDiscard(ctx),
+
+		jobTemplateVariants: make(map[int64]string),
+		jobLimiter:          newJobConcurrencyLimiter(settings.MaxJobsPerRepository, settings.MaxJobsPerWorkflow),
+		jobQueuedAt:         make(map[int64]time.Time),
+		jobAssignedAt:       make(map[int64]time.Time),
+		now:                 time.Now,
+		after:               time.After,
 	}
+	s.circuitBreaker = newCircuitBreaker(s.recordCircuitBreakerState)
+	s.jobsAboveMaxRunners = newOverflowJobs(func() time.Time { return s.now() })
+	s.overflowSourceJobs = newOverflowJobs(func() time.Time { return s.now() })
 
 	for _, option := range options {
 		option(s)
@@ -59,6 +150,8 @@ func (s *Service) Start() error {
 		}
 	}
 
+	go s.runScalingSmoothing()
+
 	for {
 		s.logger.Info("waiting for message...")
 		select {
@@ -66,15 +159,106 @@ func (s *Service) Start() error {
 			s.logger.Info("service is stopped.")
 			return nil
 		default:
-			err := s.rsClient.GetRunnerScaleSetMessage(s.ctx, s.processMessage)
-			if err != nil {
+			s.circuitBreaker.allow()
+			err := s.rsClient.GetRunnerScaleSetMessage(s.ctx, s.handleMessage)
+			if err == nil {
+				s.circuitBreaker.success()
+				continue
+			}
+
+			if s.ctx.Err() != nil {
+				// The long poll is interrupted when the service is asked to stop.
+				s.logger.Info("service is stopped.", "error", err.Error())
+				return nil
+			}
+
+			if !isTransientError(err) {
 				return fmt.Errorf("could not get and process message. %w", err)
 			}
+
+			delay := s.circuitBreaker.failure()
+			s.logger.Info("could not get and process message, backing off.", "error", err.Error(), "delay", delay.String(), "circuitBreaker", s.circuitBreaker.state, "failures", s.circuitBreaker.failures)
+			select {
+			case <-s.ctx.Done():
+				s.logger.Info("service is stopped.")
+				return nil
+			case <-s.after(delay):
+			}
+		}
+	}
+}
+
+// handleMessage processes the message, marking its errors as errors of the listener rather than of the
+// Actions client, so that they are not retried as transient errors of the Actions service.
+func (s *Service) handleMessage(message *actions.RunnerScaleSetMessage) error {
+	if err := s.processMessage(message); err != nil {
+		return &messageHandlerError{err: err}
+	}
+	return nil
+}
+
+// runScalingSmoothing scales down the smoothed runners again until they reach the assigned jobs, since the
+// scaling is otherwise only decided on the next message, which may not come for a long time.
+func (s *Service) runScalingSmoothing() {
+	ticker := time.NewTicker(scalingSmoothingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.rescaleSmoothedRunnerCount(); err != nil {
+				s.logger.Error(err, "could not scale the smoothed runners.")
+			}
 		}
 	}
 }
 
+// rescaleSmoothedRunnerCount scales for the last assigned jobs again while the smoothed runners are above them.
+func (s *Service) rescaleSmoothedRunnerCount() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.smoothingConverged() {
+		return nil
+	}
+	return s.scaleForAssignedJobCount(s.assignedJobCount)
+}
+
+// smoothingConverged returns whether the smoothed runners reached the runners desired for the assigned jobs.
+func (s *Service) smoothingConverged() bool {
+	return s.smoothedRunnerCount == nil || int(math.Round(*s.smoothedRunnerCount)) <= s.bufferedRunnerCount(s.assignedJobCount)
+}
+
+// recordCircuitBreakerState exports the circuit breaker state and records it on the ephemeral runner set.
+// Failures are only logged since the Kubernetes API may be just as unreachable as the Actions service.
+func (s *Service) recordCircuitBreakerState(state v1alpha1.ListenerCircuitBreakerState, failures int) {
+	s.logger.Info("circuit breaker state changed.", "state", state, "failures", failures)
+	namespace, name := s.metricLabels()
+	metricCircuitBreakerState.WithLabelValues(namespace, name).Set(circuitBreakerStateValues[state])
+
+	status := &v1alpha1.ListenerCircuitBreakerStatus{
+		State:               state,
+		ConsecutiveFailures: failures,
+		LastTransitionTime:  metav1.NewTime(s.now()),
+	}
+	if err := s.kubeManager.RecordEphemeralRunnerSetCircuitBreaker(s.workCtx, s.settings.Namespace, s.settings.ResourceName, status); err != nil {
+		s.logger.Error(err, "could not record circuit breaker on ephemeral runner set")
+	}
+}
+
+// metricLabels returns the namespace and name of the autoscaling runner set the metrics are labeled with.
+func (s *Service) metricLabels() (namespace, name string) {
+	name = s.settings.ScaleSetName
+	if name == "" {
+		name = s.settings.ResourceName
+	}
+	return s.settings.Namespace, name
+}
+
 func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.logger.Info("process message.", "messageId", message.MessageId, "messageType", message.MessageType)
 	if message.Statistics == nil {
 		return fmt.Errorf("can't process message with empty statistics")
@@ -94,6 +278,8 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 		return nil
 	}
 
+	s.refreshScaleSettings()
+
 	var batchedMessages []json.RawMessage
 	if err := json.NewDecoder(strings.NewReader(message.Body)).Decode(&batchedMessages); err != nil {
 		return fmt.Errorf("could not decode job messages. %w", err)
@@ -101,7 +287,7 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 
 	s.logger.Info("process batched runner scale set job messages.", "messageId", message.MessageId, "batchSize", len(batchedMessages))
 
-	var availableJobs []int64
+	var availableJobs, assignedWorkflowRuns []int64
 	for _, message := range batchedMessages {
 		var messageType actions.JobMessageType
 		if err := json.Unmarshal(message, &messageType); err != nil {
@@ -115,6 +301,17 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 				return fmt.Errorf("could not decode job available message. %w", err)
 			}
 			s.logger.Info("job available message received.", "RequestId", jobAvailable.RunnerRequestId)
+			s.recordJobQueued(jobAvailable.RunnerRequestId)
+			if s.isOverflowSourceJob(jobAvailable.RequestLabels) {
+				s.logger.Info("job of an overflow source held.", "RequestId", jobAvailable.RunnerRequestId, "requestLabels", jobAvailable.RequestLabels)
+				s.overflowSourceJobs.hold(jobAvailable.RunnerRequestId)
+				continue
+			}
+			if !s.jobLimiter.admit(jobAvailable.JobMessageBase) {
+				s.logger.Info("job deferred by the concurrency limits.", "RequestId", jobAvailable.RunnerRequestId, "repository", jobRepository(jobAvailable.JobMessageBase), "workflow", jobWorkflow(jobAvailable.JobMessageBase))
+				s.jobLimiter.deferJob(jobAvailable.JobMessageBase)
+				continue
+			}
 			availableJobs = append(availableJobs, jobAvailable.RunnerRequestId)
 		case "JobAssigned":
 			var jobAssigned actions.JobAssigned
@@ -122,12 +319,21 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 				return fmt.Errorf("could not decode job assigned message. %w", err)
 			}
 			s.logger.Info("job assigned message received.", "RequestId", jobAssigned.RunnerRequestId)
+			s.jobLimiter.assigned(jobAssigned.JobMessageBase)
+			s.jobsAboveMaxRunners.remove(jobAssigned.RunnerRequestId)
+			s.overflowSourceJobs.remove(jobAssigned.RunnerRequestId)
+			s.recordJobAssigned(jobAssigned.RunnerRequestId)
+			assignedWorkflowRuns = append(assignedWorkflowRuns, jobAssigned.WorkflowRunId)
+			if variant := templateVariantFor(s.settings.TemplateVariantLabels, jobAssigned.RequestLabels); variant != "" {
+				s.jobTemplateVariants[jobAssigned.RunnerRequestId] = variant
+			}
 		case "JobStarted":
 			var jobStarted actions.JobStarted
 			if err := json.Unmarshal(message, &jobStarted); err != nil {
 				return fmt.Errorf("could not decode job started message. %w", err)
 			}
 			s.logger.Info("job started message received.", "RequestId", jobStarted.RunnerRequestId, "RunnerId", jobStarted.RunnerId)
+			s.recordJobStarted(jobStarted.RunnerRequestId)
 			s.updateJobInfoForRunner(jobStarted)
 		case "JobCompleted":
 			var jobCompleted actions.JobCompleted
@@ -135,29 +341,298 @@ func (s *Service) processMessage(message *actions.RunnerScaleSetMessage) error {
 				return fmt.Errorf("could not decode job completed message. %w", err)
 			}
 			s.logger.Info("job completed message received.", "RequestId", jobCompleted.RunnerRequestId, "Result", jobCompleted.Result, "RunnerId", jobCompleted.RunnerId, "RunnerName", jobCompleted.RunnerName)
+			delete(s.jobTemplateVariants, jobCompleted.RunnerRequestId)
+			delete(s.jobQueuedAt, jobCompleted.RunnerRequestId)
+			s.recordJobCompleted(jobCompleted.JobMessageBase)
+			s.jobLimiter.completed(jobCompleted.RunnerRequestId)
+			if jobCompleted.RunnerName != "" {
+				s.updateJobResultForRunner(jobCompleted)
+			}
 		default:
 			s.logger.Info("unknown job message type.", "messageType", messageType.MessageType)
 		}
 	}
 
-	err := s.rsClient.AcquireJobsForRunnerScaleSet(s.ctx, availableJobs)
-	if err != nil {
+	availableJobs = append(availableJobs, s.jobLimiter.admitDeferred()...)
+	availableJobs = s.holdJobsWhilePending(availableJobs)
+	availableJobs = s.coordinateOverflow(availableJobs, message.Statistics.TotalAssignedJobs)
+
+	if err := s.acquireJobs(availableJobs); err != nil {
 		return fmt.Errorf("could not acquire jobs. %w", err)
 	}
 
-	return s.scaleForAssignedJobCount(message.Statistics.TotalAssignedJobs)
+	if err := s.scaleForAssignedJobCount(message.Statistics.TotalAssignedJobs); err != nil {
+		return err
+	}
+
+	// The assigned jobs are counted in the replicas now, so the queued job hints of the webhook for them
+	// stop adding runners. They expire otherwise, so failing to remove them doesn't fail the message.
+	if len(assignedWorkflowRuns) > 0 {
+		if err := s.kubeManager.RemoveEphemeralRunnerSetQueuedJobHints(s.workCtx, s.settings.Namespace, s.settings.ResourceName, assignedWorkflowRuns); err != nil {
+			s.logger.Error(err, "could not remove queued job hints of the assigned jobs.")
+		}
+	}
+
+	s.reportLastMessage(message.MessageId, message.Statistics)
+	return nil
+}
+
+// refreshScaleSettings applies the scale settings the controller set on the ephemeral runner set since the listener
+// started. The current settings are kept when they can't be read.
+func (s *Service) refreshScaleSettings() {
+	settings, err := s.kubeManager.GetEphemeralRunnerSetListenerScaleSettings(s.workCtx, s.settings.Namespace, s.settings.ResourceName)
+	if err != nil {
+		s.logger.Error(err, "could not get the scale settings of the ephemeral runner set, keeping the current ones.")
+		return
+	}
+	if settings == nil {
+		return
+	}
+
+	s.settings.MinRunners = settings.MinRunners
+	s.settings.MaxRunners = settings.MaxRunners
+	s.settings.JobAcquisitionBatchSize = intValue(settings.JobAcquisitionBatchSize)
+	s.settings.ScalingBufferPercent = intValue(settings.ScalingBufferPercent)
+	s.settings.ScalingSmoothingPercent = intValue(settings.ScalingSmoothingPercent)
+	s.settings.MaxPendingRunners = intValue(settings.MaxPendingRunners)
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// holdJobsWhilePending holds the available jobs instead of acquiring them while more than MaxPendingRunners
+// runners wait for their pod to run, so that the jobs stay queued for other runners meanwhile.
+// It returns the jobs to acquire, with the ones held so far once the runners catch up.
+func (s *Service) holdJobsWhilePending(requestIds []int64) []int64 {
+	if s.settings.MaxPendingRunners <= 0 {
+		return requestIds
+	}
+
+	pending, err := s.kubeManager.GetEphemeralRunnerSetPendingRunners(s.workCtx, s.settings.Namespace, s.settings.ResourceName)
+	if err != nil {
+		// Failing open keeps the jobs flowing, the pods just start late.
+		s.logger.Error(err, "could not get pending runners, acquiring jobs anyway")
+		pending = 0
+	}
+
+	if pending > s.settings.MaxPendingRunners {
+		for _, id := range requestIds {
+			if !containsJob(s.heldJobs, id) {
+				s.heldJobs = append(s.heldJobs, id)
+			}
+		}
+		if len(requestIds) > 0 {
+			s.logger.Info("job acquisition paused while runners are pending.", "pendingRunners", pending, "maxPendingRunners", s.settings.MaxPendingRunners, "heldJobs", len(s.heldJobs))
+		}
+		return nil
+	}
+
+	if len(s.heldJobs) == 0 {
+		return requestIds
+	}
+	s.logger.Info("job acquisition resumed.", "pendingRunners", pending, "heldJobs", len(s.heldJobs))
+	jobs := s.heldJobs
+	for _, id := range requestIds {
+		if !containsJob(jobs, id) {
+			jobs = append(jobs, id)
+		}
+	}
+	s.heldJobs = nil
+	return jobs
+}
+
+// coordinateOverflow keeps the acquired jobs within MaxRunners on both sides of an overflow. With an overflow target,
+// the jobs above MaxRunners are held and left for the target, and acquired on the next messages if the scale set has
+// room by then. The jobs of the scale sets overflowing into this one are acquired after their own scale set had time
+// to, and only into the room left below MaxRunners.
+func (s *Service) coordinateOverflow(requestIds []int64, assigned int) []int64 {
+	if !s.settings.HoldJobsAboveMaxRunners && len(s.settings.OverflowSourceLabels) == 0 {
+		return requestIds
+	}
+
+	room := s.settings.MaxRunners - assigned
+	if room < 0 {
+		room = 0
+	}
+
+	if s.settings.HoldJobsAboveMaxRunners {
+		for _, id := range requestIds {
+			s.jobsAboveMaxRunners.hold(id)
+		}
+		requestIds = s.jobsAboveMaxRunners.release(room, 0)
+		if held := s.jobsAboveMaxRunners.count(); held > 0 {
+			s.logger.Info("jobs above max runners left to the overflow target.", "assignedJobs", assigned, "maxRunners", s.settings.MaxRunners, "heldJobs", held)
+		}
+	}
+
+	if len(s.settings.OverflowSourceLabels) > 0 && len(requestIds) < room {
+		overflowJobs := s.overflowSourceJobs.release(room-len(requestIds), overflowJobDelay)
+		if len(overflowJobs) > 0 {
+			s.logger.Info("acquiring jobs of overflow sources.", "count", len(overflowJobs), "assignedJobs", assigned, "maxRunners", s.settings.MaxRunners)
+			requestIds = append(requestIds, overflowJobs...)
+		}
+	}
+
+	return requestIds
+}
+
+// isOverflowSourceJob reports whether the job requests the label of a scale set overflowing into this one.
+func (s *Service) isOverflowSourceJob(requestLabels []string) bool {
+	for _, label := range requestLabels {
+		for _, source := range s.settings.OverflowSourceLabels {
+			if strings.EqualFold(label, source) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsJob(requestIds []int64, id int64) bool {
+	for _, requestId := range requestIds {
+		if requestId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// acquireJobs acquires the jobs in batches of at most JobAcquisitionBatchSize jobs, so that a spike of
+// available jobs takes a few calls and no call is too large for the Actions service.
+func (s *Service) acquireJobs(requestIds []int64) error {
+	batchSize := s.settings.JobAcquisitionBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultJobAcquisitionBatchSize
+	}
+
+	for {
+		batch := requestIds
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		if err := s.rsClient.AcquireJobsForRunnerScaleSet(s.workCtx, batch); err != nil {
+			return err
+		}
+
+		requestIds = requestIds[len(batch):]
+		if len(requestIds) == 0 {
+			return nil
+		}
+	}
+}
+
+// reportLastMessage records the processed message and its job statistics on the ephemeral runner set,
+// so that the controller can tell when the listener last heard from the service and expose the job queue.
+// Failures are only logged since they don't affect scaling.
+func (s *Service) reportLastMessage(messageId int64, statistics *actions.RunnerScaleSetStatistic) {
+	now := time.Now()
+	statisticsChanged := statistics != nil && (s.lastReportedStatistics == nil || *statistics != *s.lastReportedStatistics)
+	if !statisticsChanged && now.Sub(s.lastMessageReportedAt) < lastMessageReportInterval {
+		return
+	}
+
+	if err := s.kubeManager.RecordEphemeralRunnerSetLastMessage(s.workCtx, s.settings.Namespace, s.settings.ResourceName, messageId, now, statistics); err != nil {
+		s.logger.Error(err, "could not record last message on ephemeral runner set")
+		return
+	}
+
+	s.lastMessageReportedAt = now
+	if statistics != nil {
+		reported := *statistics
+		s.lastReportedStatistics = &reported
+	}
+}
+
+// recordJobQueued remembers when the listener first saw the job queued.
+// Jobs offered again keep their first time.
+func (s *Service) recordJobQueued(requestId int64) {
+	now := s.now()
+	for id, queuedAt := range s.jobQueuedAt {
+		if now.Sub(queuedAt) > jobQueuedRetention {
+			delete(s.jobQueuedAt, id)
+		}
+	}
+
+	if _, ok := s.jobQueuedAt[requestId]; !ok {
+		s.jobQueuedAt[requestId] = now
+	}
+}
+
+// recordJobStarted observes the time the job waited from being queued to being started by a runner.
+// Jobs queued before the listener started are not observed.
+func (s *Service) recordJobStarted(requestId int64) {
+	queuedAt, ok := s.jobQueuedAt[requestId]
+	if !ok {
+		return
+	}
+	delete(s.jobQueuedAt, requestId)
+
+	namespace, name := s.metricLabels()
+	metricJobQueueToRunningSeconds.WithLabelValues(namespace, name).Observe(s.now().Sub(queuedAt).Seconds())
+}
+
+// recordJobAssigned remembers when the job was assigned to a runner of the scale set.
+func (s *Service) recordJobAssigned(requestId int64) {
+	now := s.now()
+	for id, assignedAt := range s.jobAssignedAt {
+		if now.Sub(assignedAt) > jobAssignedRetention {
+			delete(s.jobAssignedAt, id)
+		}
+	}
+
+	if _, ok := s.jobAssignedAt[requestId]; !ok {
+		s.jobAssignedAt[requestId] = now
+	}
+}
+
+// recordJobCompleted observes the duration of the job from being assigned to being completed.
+// Jobs assigned before the listener started are not observed.
+func (s *Service) recordJobCompleted(job actions.JobMessageBase) {
+	assignedAt, ok := s.jobAssignedAt[job.RunnerRequestId]
+	if !ok {
+		return
+	}
+	delete(s.jobAssignedAt, job.RunnerRequestId)
+
+	namespace, name := s.metricLabels()
+	metricJobDurationSeconds.WithLabelValues(namespace, name, jobRepository(job)).Observe(s.now().Sub(assignedAt).Seconds())
+}
+
+// bufferedRunnerCount returns the runners for the assigned jobs with the scaling buffer.
+func (s *Service) bufferedRunnerCount(count int) int {
+	return count + int(math.Ceil(float64(count)*float64(s.settings.ScalingBufferPercent)/100))
 }
 
 func (s *Service) scaleForAssignedJobCount(count int) error {
-	targetRunnerCount := int(math.Max(math.Min(float64(s.settings.MaxRunners), float64(count)), float64(s.settings.MinRunners)))
+	s.assignedJobCount = count
+	buffer := s.bufferedRunnerCount(count) - count
+	// The assigned jobs wait for their runners, so the smoothing never goes below them.
+	desired := s.smoothRunnerCount(count + buffer)
+	if desired < count {
+		desired = count
+	}
+	targetRunnerCount := int(math.Max(math.Min(float64(s.settings.MaxRunners), float64(desired)), float64(s.settings.MinRunners)))
 	if targetRunnerCount != s.currentRunnerCount {
+		trigger := v1alpha1.ScaleTriggerJobsAssigned
+		switch {
+		case targetRunnerCount > desired:
+			trigger = v1alpha1.ScaleTriggerMinRunners
+		case targetRunnerCount < desired:
+			trigger = v1alpha1.ScaleTriggerMaxRunners
+		}
 		s.logger.Info("try scale runner request up/down base on assigned job count",
 			"assigned job", count,
+			"buffer", buffer,
 			"decision", targetRunnerCount,
+			"trigger", trigger,
 			"min", s.settings.MinRunners,
 			"max", s.settings.MaxRunners,
 			"currentRunnerCount", s.currentRunnerCount)
-		err := s.kubeManager.ScaleEphemeralRunnerSet(s.ctx, s.settings.Namespace, s.settings.ResourceName, targetRunnerCount)
+		err := s.kubeManager.ScaleEphemeralRunnerSet(s.workCtx, s.settings.Namespace, s.settings.ResourceName, targetRunnerCount, trigger)
 		if err != nil {
 			return fmt.Errorf("could not scale ephemeral runner set (%s/%s). %w", s.settings.Namespace, s.settings.ResourceName, err)
 		}
@@ -165,9 +640,104 @@ func (s *Service) scaleForAssignedJobCount(count int) error {
 		s.currentRunnerCount = targetRunnerCount
 	}
 
+	if len(s.settings.TemplateVariantLabels) == 0 {
+		return nil
+	}
+
+	variantReplicas := s.variantReplicas(targetRunnerCount)
+	if reflect.DeepEqual(variantReplicas, s.currentVariantReplicas) {
+		return nil
+	}
+
+	s.logger.Info("try scale runner template variants base on assigned jobs", "variantReplicas", variantReplicas)
+	if err := s.kubeManager.ScaleEphemeralRunnerSetVariants(s.workCtx, s.settings.Namespace, s.settings.ResourceName, variantReplicas); err != nil {
+		return fmt.Errorf("could not scale template variants of ephemeral runner set (%s/%s). %w", s.settings.Namespace, s.settings.ResourceName, err)
+	}
+	s.currentVariantReplicas = variantReplicas
+
 	return nil
 }
 
+// smoothRunnerCount passes fewer desired runners through their exponentially weighted moving average,
+// rounded to the nearest runner count so that it settles on the desired runners. More desired runners
+// are applied right away.
+func (s *Service) smoothRunnerCount(desired int) int {
+	weight := float64(s.settings.ScalingSmoothingPercent) / 100
+	if weight <= 0 || weight >= 1 {
+		s.smoothedRunnerCount = nil
+		return desired
+	}
+
+	smoothed := float64(desired)
+	if s.smoothedRunnerCount != nil && *s.smoothedRunnerCount > smoothed {
+		smoothed = weight*float64(desired) + (1-weight)*(*s.smoothedRunnerCount)
+	}
+	s.smoothedRunnerCount = &smoothed
+	return int(math.Round(smoothed))
+}
+
+// variantReplicas counts the assigned jobs of each template variant,
+// so that their sum doesn't exceed the target runner count.
+func (s *Service) variantReplicas(targetRunnerCount int) map[string]int {
+	counts := make(map[string]int, len(s.settings.TemplateVariantLabels))
+	for variant := range s.settings.TemplateVariantLabels {
+		counts[variant] = 0
+	}
+
+	requestIds := make([]int64, 0, len(s.jobTemplateVariants))
+	for requestId := range s.jobTemplateVariants {
+		requestIds = append(requestIds, requestId)
+	}
+	// Oldest job requests first.
+	sort.Slice(requestIds, func(i, j int) bool { return requestIds[i] < requestIds[j] })
+
+	total := 0
+	for _, requestId := range requestIds {
+		if total == targetRunnerCount {
+			break
+		}
+		counts[s.jobTemplateVariants[requestId]]++
+		total++
+	}
+
+	return counts
+}
+
+// templateVariantFor returns the template variant selected by the labels requested by a job:
+// the variant with the most labels, all of which are requested. Ties are broken by name.
+func templateVariantFor(variantLabels map[string][]string, requestLabels []string) string {
+	requested := make(map[string]bool, len(requestLabels))
+	for _, label := range requestLabels {
+		requested[strings.ToLower(label)] = true
+	}
+
+	selected := ""
+	selectedLabels := 0
+	for variant, labels := range variantLabels {
+		if len(labels) == 0 || len(labels) < selectedLabels {
+			continue
+		}
+
+		matches := true
+		for _, label := range labels {
+			if !requested[strings.ToLower(label)] {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		if len(labels) > selectedLabels || variant < selected {
+			selected = variant
+			selectedLabels = len(labels)
+		}
+	}
+
+	return selected
+}
+
 // updateJobInfoForRunner updates the ephemeral runner with the job info and this is best effort since the info is only for better telemetry
 func (s *Service) updateJobInfoForRunner(jobInfo actions.JobStarted) {
 	s.logger.Info("update job info for runner",
@@ -178,8 +748,17 @@ func (s *Service) updateJobInfoForRunner(jobInfo actions.JobStarted) {
 		"workflowRunId", jobInfo.WorkflowRunId,
 		"jobDisplayName", jobInfo.JobDisplayName,
 		"requestId", jobInfo.RunnerRequestId)
-	err := s.kubeManager.UpdateEphemeralRunnerWithJobInfo(s.ctx, s.settings.Namespace, jobInfo.RunnerName, jobInfo.OwnerName, jobInfo.RepositoryName, jobInfo.JobWorkflowRef, jobInfo.JobDisplayName, jobInfo.WorkflowRunId, jobInfo.RunnerRequestId)
+	err := s.kubeManager.UpdateEphemeralRunnerWithJobInfo(s.workCtx, s.settings.Namespace, jobInfo.RunnerName, jobInfo.OwnerName, jobInfo.RepositoryName, jobInfo.JobWorkflowRef, jobInfo.JobDisplayName, jobInfo.WorkflowRunId, jobInfo.RunnerRequestId, jobInfo.RequestLabels)
 	if err != nil {
 		s.logger.Error(err, "could not update ephemeral runner with job info", "runnerName", jobInfo.RunnerName, "requestId", jobInfo.RunnerRequestId)
 	}
 }
+
+// updateJobResultForRunner records the result of the job on the ephemeral runner, so that failures of the job
+// can be told apart from failures of the runner. It's best effort like updateJobInfoForRunner.
+func (s *Service) updateJobResultForRunner(jobCompleted actions.JobCompleted) {
+	err := s.kubeManager.UpdateEphemeralRunnerWithJobResult(s.workCtx, s.settings.Namespace, jobCompleted.RunnerName, jobCompleted.Result)
+	if err != nil {
+		s.logger.Error(err, "could not update ephemeral runner with job result", "runnerName", jobCompleted.RunnerName, "requestId", jobCompleted.RunnerRequestId)
+	}
+}
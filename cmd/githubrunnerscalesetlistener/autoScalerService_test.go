@@ -3,13 +3,19 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestNewService(t *testing.T) {
@@ -92,7 +98,7 @@ func TestStart_ScaleToMinRunners(t *testing.T) {
 			s.logger = logger
 		},
 	)
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5).Run(func(args mock.Arguments) { cancel() }).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5, mock.Anything).Run(func(args mock.Arguments) { cancel() }).Return(nil).Once()
 
 	err := service.Start()
 
@@ -124,7 +130,7 @@ func TestStart_ScaleToMinRunnersFailed(t *testing.T) {
 			s.logger = logger
 		},
 	)
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5).Return(fmt.Errorf("error")).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5, mock.Anything).Return(fmt.Errorf("error")).Once()
 
 	err := service.Start()
 
@@ -198,6 +204,47 @@ func TestStart_ErrorOnMessage(t *testing.T) {
 	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
 }
 
+func TestStart_ErrorProcessingMessage(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+			s.after = func(d time.Duration) <-chan time.Time {
+				t.Fatal("Errors processing a message should not be retried")
+				return nil
+			}
+		},
+	)
+	mockRsClient.On("GetRunnerScaleSetMessage", service.ctx, mock.Anything).Return(func(ctx context.Context, handler func(*actions.RunnerScaleSetMessage) error) error {
+		if err := handler(&actions.RunnerScaleSetMessage{MessageId: 1}); err != nil {
+			return fmt.Errorf("handle message failed. %w", err)
+		}
+		return nil
+	}).Once()
+
+	err := service.Start()
+
+	assert.EqualError(t, err, "could not get and process message. handle message failed. can't process message with empty statistics")
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
 func TestProcessMessage_NoStatistic(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
@@ -274,6 +321,7 @@ func TestProcessMessage_IgnoreUnknownMessageType(t *testing.T) {
 func TestProcessMessage_InvalidBatchMessageJson(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
 	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
 	logger = logger.WithName(t.Name())
 	require.NoError(t, log_err, "Error creating logger")
@@ -312,6 +360,7 @@ func TestProcessMessage_InvalidBatchMessageJson(t *testing.T) {
 func TestProcessMessage_InvalidJobMessageJson(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
 	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
 	logger = logger.WithName(t.Name())
 	require.NoError(t, log_err, "Error creating logger")
@@ -350,6 +399,8 @@ func TestProcessMessage_InvalidJobMessageJson(t *testing.T) {
 func TestProcessMessage_MultipleMessages(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
 	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
 	logger = logger.WithName(t.Name())
 	require.NoError(t, log_err, "Error creating logger")
@@ -371,7 +422,8 @@ func TestProcessMessage_MultipleMessages(t *testing.T) {
 		},
 	)
 	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.MatchedBy(func(ids []int64) bool { return ids[0] == 3 && ids[1] == 4 })).Return(nil).Once()
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2).Run(func(args mock.Arguments) { cancel() }).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2, mock.Anything).Run(func(args mock.Arguments) { cancel() }).Return(nil).Once()
+	mockKubeManager.On("RemoveEphemeralRunnerSetQueuedJobHints", ctx, service.settings.Namespace, service.settings.ResourceName, []int64{7}).Return(nil).Once()
 
 	err := service.processMessage(&actions.RunnerScaleSetMessage{
 		MessageId:   1,
@@ -380,7 +432,7 @@ func TestProcessMessage_MultipleMessages(t *testing.T) {
 			TotalAssignedJobs:  2,
 			TotalAvailableJobs: 2,
 		},
-		Body: "[{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 3},{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 4},{\"messageType\":\"JobAssigned\", \"runnerRequestId\": 2}, {\"messageType\":\"JobCompleted\", \"runnerRequestId\": 1, \"result\":\"succeed\"},{\"messageType\":\"unknown\"}]",
+		Body: "[{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 3},{\"messageType\":\"JobAvailable\", \"runnerRequestId\": 4},{\"messageType\":\"JobAssigned\", \"runnerRequestId\": 2, \"workflowRunId\": 7}, {\"messageType\":\"JobCompleted\", \"runnerRequestId\": 1, \"result\":\"succeed\"},{\"messageType\":\"unknown\"}]",
 	})
 
 	assert.NoError(t, err, "Unexpected error")
@@ -391,6 +443,7 @@ func TestProcessMessage_MultipleMessages(t *testing.T) {
 func TestProcessMessage_AcquireJobsFailed(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
 	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
 	logger = logger.WithName(t.Name())
 	require.NoError(t, log_err, "Error creating logger")
@@ -451,7 +504,7 @@ func TestScaleForAssignedJobCount_DeDupScale(t *testing.T) {
 			s.logger = logger
 		},
 	)
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2, mock.Anything).Return(nil).Once()
 
 	err := service.scaleForAssignedJobCount(2)
 	require.NoError(t, err, "Unexpected error")
@@ -490,11 +543,11 @@ func TestScaleForAssignedJobCount_ScaleWithinMinMax(t *testing.T) {
 			s.logger = logger
 		},
 	)
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 1).Return(nil).Once()
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 3).Return(nil).Once()
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5).Return(nil).Once()
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 1).Return(nil).Once()
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 1, v1alpha1.ScaleTriggerMinRunners).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 3, v1alpha1.ScaleTriggerJobsAssigned).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5, v1alpha1.ScaleTriggerJobsAssigned).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 1, v1alpha1.ScaleTriggerJobsAssigned).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 5, v1alpha1.ScaleTriggerMaxRunners).Return(nil).Once()
 
 	err := service.scaleForAssignedJobCount(0)
 	require.NoError(t, err, "Unexpected error")
@@ -512,6 +565,90 @@ func TestScaleForAssignedJobCount_ScaleWithinMinMax(t *testing.T) {
 	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
 }
 
+func TestScaleForAssignedJobCount_ScalingBuffer(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:            "namespace",
+			ResourceName:         "resource",
+			MinRunners:           1,
+			MaxRunners:           12,
+			ScalingBufferPercent: 25,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 1, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 3, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 12, mock.Anything).Return(nil).Once()
+
+	require.NoError(t, service.scaleForAssignedJobCount(0))
+	assert.Equal(t, 1, service.currentRunnerCount, "No assigned jobs need no buffer")
+
+	require.NoError(t, service.scaleForAssignedJobCount(2))
+	assert.Equal(t, 3, service.currentRunnerCount, "The buffer is rounded up")
+
+	require.NoError(t, service.scaleForAssignedJobCount(10))
+	assert.Equal(t, 12, service.currentRunnerCount, "The buffer is capped at max runners")
+
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestScaleForAssignedJobCount_ScalingSmoothing(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:               "namespace",
+			ResourceName:            "resource",
+			MinRunners:              0,
+			MaxRunners:              20,
+			ScalingSmoothingPercent: 50,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, mock.Anything, mock.Anything).Return(nil)
+
+	var decisions []int
+	for _, count := range []int{8, 0, 8, 0, 8, 6, 6, 6} {
+		require.NoError(t, service.scaleForAssignedJobCount(count))
+		decisions = append(decisions, service.currentRunnerCount)
+	}
+
+	assert.Equal(t, []int{8, 4, 8, 4, 8, 7, 7, 6}, decisions, "Scaling down is smoothed, scaling up isn't, and the runners never go below the assigned jobs")
+
+	// Without messages, the smoothed runners are scaled down on the timer until they reach the assigned jobs.
+	require.NoError(t, service.scaleForAssignedJobCount(0))
+	decisions = []int{service.currentRunnerCount}
+	for !service.smoothingConverged() {
+		require.NoError(t, service.rescaleSmoothedRunnerCount())
+		decisions = append(decisions, service.currentRunnerCount)
+	}
+	assert.Equal(t, []int{3, 2, 1, 0}, decisions)
+}
+
 func TestScaleForAssignedJobCount_ScaleFailed(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
@@ -535,7 +672,7 @@ func TestScaleForAssignedJobCount_ScaleFailed(t *testing.T) {
 			s.logger = logger
 		},
 	)
-	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2).Return(fmt.Errorf("error"))
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2, mock.Anything).Return(fmt.Errorf("error"))
 
 	err := service.scaleForAssignedJobCount(2)
 
@@ -547,6 +684,8 @@ func TestScaleForAssignedJobCount_ScaleFailed(t *testing.T) {
 func TestProcessMessage_JobStartedMessage(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
 	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
 	logger = logger.WithName(t.Name())
 	require.NoError(t, log_err, "Error creating logger")
@@ -569,7 +708,7 @@ func TestProcessMessage_JobStartedMessage(t *testing.T) {
 	)
 	service.currentRunnerCount = 1
 
-	mockKubeManager.On("UpdateEphemeralRunnerWithJobInfo", ctx, service.settings.Namespace, "runner1", "owner1", "repo1", ".github/workflows/ci.yaml", "job1", int64(100), int64(3)).Run(func(args mock.Arguments) { cancel() }).Return(nil).Once()
+	mockKubeManager.On("UpdateEphemeralRunnerWithJobInfo", ctx, service.settings.Namespace, "runner1", "owner1", "repo1", ".github/workflows/ci.yaml", "job1", int64(100), int64(3), []string{"linux"}).Run(func(args mock.Arguments) { cancel() }).Return(nil).Once()
 	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.MatchedBy(func(ids []int64) bool { return len(ids) == 0 })).Return(nil).Once()
 
 	err := service.processMessage(&actions.RunnerScaleSetMessage{
@@ -579,7 +718,7 @@ func TestProcessMessage_JobStartedMessage(t *testing.T) {
 			TotalAssignedJobs:  1,
 			TotalAvailableJobs: 0,
 		},
-		Body: "[{\"messageType\":\"JobStarted\", \"runnerRequestId\": 3, \"runnerId\": 1, \"runnerName\": \"runner1\", \"ownerName\": \"owner1\", \"repositoryName\": \"repo1\", \"jobWorkflowRef\": \".github/workflows/ci.yaml\", \"jobDisplayName\": \"job1\", \"workflowRunId\": 100 }]",
+		Body: "[{\"messageType\":\"JobStarted\", \"runnerRequestId\": 3, \"runnerId\": 1, \"runnerName\": \"runner1\", \"ownerName\": \"owner1\", \"repositoryName\": \"repo1\", \"jobWorkflowRef\": \".github/workflows/ci.yaml\", \"jobDisplayName\": \"job1\", \"workflowRunId\": 100, \"requestLabels\": [\"linux\"] }]",
 	})
 
 	assert.NoError(t, err, "Unexpected error")
@@ -590,6 +729,53 @@ func TestProcessMessage_JobStartedMessage(t *testing.T) {
 func TestProcessMessage_JobStartedMessageIgnoreRunnerUpdateError(t *testing.T) {
 	mockRsClient := &MockRunnerScaleSetClient{}
 	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   1,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	service.currentRunnerCount = 1
+
+	mockKubeManager.On("UpdateEphemeralRunnerWithJobInfo", ctx, service.settings.Namespace, "runner1", "owner1", "repo1", ".github/workflows/ci.yaml", "job1", int64(100), int64(3), []string{"linux"}).Run(func(args mock.Arguments) { cancel() }).Return(fmt.Errorf("error")).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.MatchedBy(func(ids []int64) bool { return len(ids) == 0 })).Return(nil).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics: &actions.RunnerScaleSetStatistic{
+			TotalAssignedJobs:  0,
+			TotalAvailableJobs: 0,
+		},
+		Body: "[{\"messageType\":\"JobStarted\", \"runnerRequestId\": 3, \"runnerId\": 1, \"runnerName\": \"runner1\", \"ownerName\": \"owner1\", \"repositoryName\": \"repo1\", \"jobWorkflowRef\": \".github/workflows/ci.yaml\", \"jobDisplayName\": \"job1\", \"workflowRunId\": 100, \"requestLabels\": [\"linux\"] }]",
+	})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestProcessMessage_JobCompletedMessageRecordsJobResult(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
 	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
 	logger = logger.WithName(t.Name())
 	require.NoError(t, log_err, "Error creating logger")
@@ -612,7 +798,7 @@ func TestProcessMessage_JobStartedMessageIgnoreRunnerUpdateError(t *testing.T) {
 	)
 	service.currentRunnerCount = 1
 
-	mockKubeManager.On("UpdateEphemeralRunnerWithJobInfo", ctx, service.settings.Namespace, "runner1", "owner1", "repo1", ".github/workflows/ci.yaml", "job1", int64(100), int64(3)).Run(func(args mock.Arguments) { cancel() }).Return(fmt.Errorf("error")).Once()
+	mockKubeManager.On("UpdateEphemeralRunnerWithJobResult", ctx, service.settings.Namespace, "runner1", "failed").Return(fmt.Errorf("error")).Once()
 	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.MatchedBy(func(ids []int64) bool { return len(ids) == 0 })).Return(nil).Once()
 
 	err := service.processMessage(&actions.RunnerScaleSetMessage{
@@ -622,10 +808,797 @@ func TestProcessMessage_JobStartedMessageIgnoreRunnerUpdateError(t *testing.T) {
 			TotalAssignedJobs:  0,
 			TotalAvailableJobs: 0,
 		},
-		Body: "[{\"messageType\":\"JobStarted\", \"runnerRequestId\": 3, \"runnerId\": 1, \"runnerName\": \"runner1\", \"ownerName\": \"owner1\", \"repositoryName\": \"repo1\", \"jobWorkflowRef\": \".github/workflows/ci.yaml\", \"jobDisplayName\": \"job1\", \"workflowRunId\": 100 }]",
+		Body: "[{\"messageType\":\"JobCompleted\", \"runnerRequestId\": 3, \"runnerId\": 1, \"runnerName\": \"runner1\", \"result\": \"failed\"}]",
+	})
+
+	assert.NoError(t, err, "Failing to record the job result should not fail the message processing")
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestProcessMessage_TemplateVariants(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+			TemplateVariantLabels: map[string][]string{
+				"gpu":   {"gpu"},
+				"large": {"large"},
+			},
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 3, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSetVariants", ctx, service.settings.Namespace, service.settings.ResourceName, map[string]int{"gpu": 2, "large": 0}).Return(nil).Once()
+	mockKubeManager.On("RemoveEphemeralRunnerSetQueuedJobHints", ctx, service.settings.Namespace, service.settings.ResourceName, mock.Anything).Return(fmt.Errorf("error")).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics: &actions.RunnerScaleSetStatistic{
+			TotalAssignedJobs: 3,
+		},
+		Body: "[{\"messageType\":\"JobAssigned\", \"runnerRequestId\": 1, \"requestLabels\": [\"scale-set\", \"GPU\"]},{\"messageType\":\"JobAssigned\", \"runnerRequestId\": 2, \"requestLabels\": [\"scale-set\", \"gpu\"]},{\"messageType\":\"JobAssigned\", \"runnerRequestId\": 3, \"requestLabels\": [\"scale-set\"]}]",
+	})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSetVariants", ctx, service.settings.Namespace, service.settings.ResourceName, map[string]int{"gpu": 1, "large": 0}).Return(nil).Once()
+
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   2,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics: &actions.RunnerScaleSetStatistic{
+			TotalAssignedJobs: 2,
+		},
+		Body: "[{\"messageType\":\"JobCompleted\", \"runnerRequestId\": 1, \"result\":\"succeed\"}]",
 	})
 
 	assert.NoError(t, err, "Unexpected error")
 	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
 	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
 }
+
+func TestTemplateVariantFor(t *testing.T) {
+	variantLabels := map[string][]string{
+		"gpu":       {"gpu"},
+		"large":     {"large"},
+		"large-gpu": {"large", "gpu"},
+		"empty":     {},
+	}
+
+	assert.Equal(t, "", templateVariantFor(variantLabels, []string{"scale-set"}))
+	assert.Equal(t, "gpu", templateVariantFor(variantLabels, []string{"scale-set", "gpu"}))
+	assert.Equal(t, "large-gpu", templateVariantFor(variantLabels, []string{"scale-set", "GPU", "large"}))
+	assert.Equal(t, "gpu", templateVariantFor(map[string][]string{"gpu": {"gpu"}, "other": {"other"}}, []string{"gpu", "other"}))
+	assert.Equal(t, "", templateVariantFor(nil, []string{"gpu"}))
+}
+
+func TestProcessMessage_HoldsJobsAboveMaxRunnersForOverflowTarget(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:               "namespace",
+			ResourceName:            "resource",
+			MinRunners:              0,
+			MaxRunners:              5,
+			HoldJobsAboveMaxRunners: true,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{1}).Return(nil).Once()
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 4},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":1},{"messageType":"JobAvailable","runnerRequestId":2},{"messageType":"JobAvailable","runnerRequestId":3}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Only the jobs within max runners should be acquired")
+	assert.Equal(t, 2, service.jobsAboveMaxRunners.count())
+
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{2, 3}).Return(nil).Once()
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   2,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 2},
+		Body:        `[]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Held jobs should be acquired once the scale set has room")
+	assert.Equal(t, 0, service.jobsAboveMaxRunners.count())
+}
+
+func TestProcessMessage_DelaysJobsOfOverflowSources(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:            "namespace",
+			ResourceName:         "resource",
+			MinRunners:           0,
+			MaxRunners:           5,
+			OverflowSourceLabels: []string{"primary"},
+		},
+		func(s *Service) {
+			s.logger = logger
+			s.now = func() time.Time { return now }
+		},
+	)
+
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{1}).Return(nil).Once()
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 3},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":1,"requestLabels":["spot"]},{"messageType":"JobAvailable","runnerRequestId":2,"requestLabels":["primary"]},{"messageType":"JobAvailable","runnerRequestId":3,"requestLabels":["primary"]}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Jobs of the overflow sources should be left to their scale set first")
+
+	now = now.Add(overflowJobDelay)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{2}).Return(nil).Once()
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   2,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 4},
+		Body:        `[]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Jobs of the overflow sources should only fill the room below max runners")
+	assert.Equal(t, 1, service.overflowSourceJobs.count())
+
+	now = now.Add(overflowJobRetention)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64(nil)).Return(nil).Once()
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   3,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 0},
+		Body:        `[]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Expired jobs of the overflow sources should be dropped")
+	assert.Equal(t, 0, service.overflowSourceJobs.count())
+}
+
+func TestProcessMessage_AppliesListenerScaleSettings(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	bufferPercent := 50
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", ctx, service.settings.Namespace, service.settings.ResourceName).Return(&v1alpha1.ListenerScaleSettings{
+		MinRunners:           2,
+		MaxRunners:           10,
+		ScalingBufferPercent: &bufferPercent,
+	}, nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.Anything).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", ctx, service.settings.Namespace, service.settings.ResourceName, 2, v1alpha1.ScaleTriggerMinRunners).Return(nil).Once()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", ctx, service.settings.Namespace, service.settings.ResourceName, int64(1), mock.Anything, mock.Anything).Return(nil).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        "[]",
+	})
+	require.NoError(t, err, "Unexpected error")
+
+	assert.Equal(t, 2, service.settings.MinRunners)
+	assert.Equal(t, 10, service.settings.MaxRunners)
+	assert.Equal(t, 50, service.settings.ScalingBufferPercent)
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "The runner set should be scaled with the refreshed settings")
+}
+
+func TestProcessMessage_RecordsLastMessage(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.Anything).Return(nil).Twice()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", ctx, service.settings.Namespace, service.settings.ResourceName, int64(1), mock.Anything, &actions.RunnerScaleSetStatistic{}).Return(nil).Once()
+
+	for _, messageId := range []int64{1, 2} {
+		err := service.processMessage(&actions.RunnerScaleSetMessage{
+			MessageId:   messageId,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{},
+			Body:        "[]",
+		})
+		assert.NoError(t, err, "Unexpected error")
+	}
+
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "Only the first message should be recorded within the report interval")
+}
+
+func TestProcessMessage_RecordsChangedJobStatistics(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, mock.Anything).Return(nil).Times(3)
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", ctx, service.settings.Namespace, service.settings.ResourceName, int64(1), mock.Anything, &actions.RunnerScaleSetStatistic{}).Return(nil).Once()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", ctx, service.settings.Namespace, service.settings.ResourceName, int64(3), mock.Anything, &actions.RunnerScaleSetStatistic{TotalAvailableJobs: 2}).Return(nil).Once()
+
+	for messageId, statistics := range []*actions.RunnerScaleSetStatistic{{}, {}, {TotalAvailableJobs: 2}} {
+		err := service.processMessage(&actions.RunnerScaleSetMessage{
+			MessageId:   int64(messageId + 1),
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  statistics,
+			Body:        "[]",
+		})
+		assert.NoError(t, err, "Unexpected error")
+	}
+
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "Changed job statistics should be recorded within the report interval")
+}
+
+func TestProcessMessage_JobConcurrencyLimits(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:            "namespace",
+			ResourceName:         "resource",
+			MinRunners:           0,
+			MaxRunners:           5,
+			MaxJobsPerRepository: 1,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{1, 3}).Return(nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{2}).Return(nil).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":1,"ownerName":"org","repositoryName":"noisy"},{"messageType":"JobAvailable","runnerRequestId":2,"ownerName":"org","repositoryName":"noisy"},{"messageType":"JobAvailable","runnerRequestId":3,"ownerName":"org","repositoryName":"quiet"}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   2,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        `[{"messageType":"JobCompleted","runnerRequestId":1,"ownerName":"org","repositoryName":"noisy","result":"succeeded"}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+
+	assert.True(t, mockRsClient.AssertExpectations(t), "Deferred job should be acquired once the repository has capacity")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestProcessMessage_JobAcquisitionBatches(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:               "namespace",
+			ResourceName:            "resource",
+			MinRunners:              0,
+			MaxRunners:              5,
+			JobAcquisitionBatchSize: 2,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{1, 2}).Return(nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{3, 4}).Return(nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{5}).Return(nil).Once()
+
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":1},{"messageType":"JobAvailable","runnerRequestId":2},{"messageType":"JobAvailable","runnerRequestId":3},{"messageType":"JobAvailable","runnerRequestId":4},{"messageType":"JobAvailable","runnerRequestId":5}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Jobs should be acquired in batches of the batch size")
+
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{6}).Return(fmt.Errorf("error")).Once()
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   2,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":6}]`,
+	})
+	assert.ErrorContains(t, err, "could not acquire jobs")
+}
+
+func TestProcessMessage_HoldsJobsWhileRunnersArePending(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:         "namespace",
+			ResourceName:      "resource",
+			MinRunners:        0,
+			MaxRunners:        5,
+			MaxPendingRunners: 2,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+
+	mockKubeManager.On("GetEphemeralRunnerSetPendingRunners", ctx, "namespace", "resource").Return(3, nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64(nil)).Return(nil).Once()
+	err := service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":1},{"messageType":"JobAvailable","runnerRequestId":2}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Jobs should not be acquired while too many runners are pending")
+	assert.Equal(t, []int64{1, 2}, service.heldJobs)
+
+	mockKubeManager.On("GetEphemeralRunnerSetPendingRunners", ctx, "namespace", "resource").Return(2, nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", ctx, []int64{1, 2, 3}).Return(nil).Once()
+	err = service.processMessage(&actions.RunnerScaleSetMessage{
+		MessageId:   2,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        `[{"messageType":"JobAvailable","runnerRequestId":2},{"messageType":"JobAvailable","runnerRequestId":3}]`,
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "Held jobs should be acquired once the pending runners catch up")
+	assert.Empty(t, service.heldJobs)
+	assert.True(t, mockKubeManager.AssertExpectations(t))
+}
+
+func TestProcessMessage_JobQueueToRunningLatency(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockKubeManager.On("UpdateEphemeralRunnerWithJobInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", mock.Anything, mock.Anything).Return(nil)
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "latency-test",
+			ResourceName: "arc-runners-abcde",
+			MinRunners:   0,
+			MaxRunners:   5,
+			ScaleSetName: "arc-runners",
+		},
+		func(s *Service) {
+			s.logger = logger
+			s.now = func() time.Time { return now }
+		},
+	)
+
+	process := func(body string) {
+		err := service.processMessage(&actions.RunnerScaleSetMessage{
+			MessageId:   1,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{},
+			Body:        body,
+		})
+		require.NoError(t, err)
+	}
+
+	process(`[{"messageType":"JobAvailable","runnerRequestId":1},{"messageType":"JobAvailable","runnerRequestId":2}]`)
+	now = now.Add(30 * time.Second)
+	// Offering the job again keeps the time it was first queued.
+	process(`[{"messageType":"JobAvailable","runnerRequestId":1}]`)
+	now = now.Add(15 * time.Second)
+	process(`[{"messageType":"JobStarted","runnerRequestId":1,"runnerName":"runner1"},{"messageType":"JobCompleted","runnerRequestId":2}]`)
+	// Jobs queued before the listener started are not observed.
+	process(`[{"messageType":"JobStarted","runnerRequestId":2,"runnerName":"runner2"},{"messageType":"JobStarted","runnerRequestId":3,"runnerName":"runner3"}]`)
+
+	expected := `
+# HELP github_runner_scale_set_job_queue_to_running_seconds The number of seconds from when the listener saw a job queued to when a runner of the scale set started it
+# TYPE github_runner_scale_set_job_queue_to_running_seconds histogram
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="1"} 0
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="2"} 0
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="5"} 0
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="10"} 0
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="20"} 0
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="30"} 0
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="45"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="60"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="90"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="120"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="180"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="300"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="600"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="1200"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="1800"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="3600"} 1
+github_runner_scale_set_job_queue_to_running_seconds_bucket{name="arc-runners",namespace="latency-test",le="+Inf"} 1
+github_runner_scale_set_job_queue_to_running_seconds_sum{name="arc-runners",namespace="latency-test"} 45
+github_runner_scale_set_job_queue_to_running_seconds_count{name="arc-runners",namespace="latency-test"} 1
+`
+	err := testutil.CollectAndCompare(metricJobQueueToRunningSeconds, strings.NewReader(expected))
+	assert.NoError(t, err)
+	assert.Empty(t, service.jobQueuedAt)
+}
+
+func TestProcessMessage_JobDuration(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockKubeManager.On("RemoveEphemeralRunnerSetQueuedJobHints", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", mock.Anything, mock.Anything).Return(nil)
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "duration-test",
+			ResourceName: "arc-runners-abcde",
+			MinRunners:   0,
+			MaxRunners:   5,
+			ScaleSetName: "arc-runners",
+		},
+		func(s *Service) {
+			s.logger = logger
+			s.now = func() time.Time { return now }
+		},
+	)
+
+	process := func(body string) {
+		err := service.processMessage(&actions.RunnerScaleSetMessage{
+			MessageId:   1,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{},
+			Body:        body,
+		})
+		require.NoError(t, err)
+	}
+
+	// Other tests complete jobs too.
+	metricJobDurationSeconds.Reset()
+
+	process(`[{"messageType":"JobAssigned","runnerRequestId":1,"ownerName":"owner","repositoryName":"repo"}]`)
+	now = now.Add(5 * time.Minute)
+	process(`[{"messageType":"JobCompleted","runnerRequestId":1,"ownerName":"owner","repositoryName":"repo","result":"succeeded"}]`)
+	// Jobs assigned before the listener started are not observed.
+	process(`[{"messageType":"JobCompleted","runnerRequestId":2,"ownerName":"owner","repositoryName":"repo","result":"succeeded"}]`)
+
+	expected := `
+# HELP github_runner_scale_set_job_duration_seconds The number of seconds from when a job was assigned to a runner of the scale set to when it completed
+# TYPE github_runner_scale_set_job_duration_seconds histogram
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="10"} 0
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="30"} 0
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="60"} 0
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="120"} 0
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="300"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="600"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="900"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="1200"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="1800"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="2700"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="3600"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="5400"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="7200"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="10800"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="14400"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="21600"} 1
+github_runner_scale_set_job_duration_seconds_bucket{name="arc-runners",namespace="duration-test",repository="owner/repo",le="+Inf"} 1
+github_runner_scale_set_job_duration_seconds_sum{name="arc-runners",namespace="duration-test",repository="owner/repo"} 300
+github_runner_scale_set_job_duration_seconds_count{name="arc-runners",namespace="duration-test",repository="owner/repo"} 1
+`
+	err := testutil.CollectAndCompare(metricJobDurationSeconds, strings.NewReader(expected))
+	assert.NoError(t, err)
+	assert.Empty(t, service.jobAssignedAt)
+}
+
+func TestStart_BackOffOnTransientErrors(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var delays []time.Duration
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+			s.circuitBreaker.jitter = func(d time.Duration) time.Duration { return d }
+			s.circuitBreaker.now = func() time.Time { return time.Now().Add(circuitBreakerOpenDuration * time.Duration(len(delays))) }
+			s.after = func(d time.Duration) <-chan time.Time {
+				delays = append(delays, d)
+				c := make(chan time.Time, 1)
+				c <- time.Time{}
+				return c
+			}
+		},
+	)
+	serverError := fmt.Errorf("get message failed. %w", &actions.ActionsError{StatusCode: http.StatusServiceUnavailable})
+	mockRsClient.On("GetRunnerScaleSetMessage", service.ctx, mock.Anything).Return(serverError).Times(circuitBreakerThreshold)
+	mockRsClient.On("GetRunnerScaleSetMessage", service.ctx, mock.Anything).Return(nil).Once()
+	mockRsClient.On("GetRunnerScaleSetMessage", service.ctx, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(nil).Once()
+	mockKubeManager.On("RecordEphemeralRunnerSetCircuitBreaker", service.ctx, "namespace", "resource", mock.MatchedBy(func(status *v1alpha1.ListenerCircuitBreakerStatus) bool {
+		return status.State == v1alpha1.ListenerCircuitBreakerOpen && status.ConsecutiveFailures == circuitBreakerThreshold
+	})).Return(nil).Once()
+	mockKubeManager.On("RecordEphemeralRunnerSetCircuitBreaker", service.ctx, "namespace", "resource", mock.MatchedBy(func(status *v1alpha1.ListenerCircuitBreakerStatus) bool {
+		return status.State == v1alpha1.ListenerCircuitBreakerHalfOpen
+	})).Return(nil).Once()
+	mockKubeManager.On("RecordEphemeralRunnerSetCircuitBreaker", service.ctx, "namespace", "resource", mock.MatchedBy(func(status *v1alpha1.ListenerCircuitBreakerStatus) bool {
+		return status.State == v1alpha1.ListenerCircuitBreakerClosed && status.ConsecutiveFailures == 0
+	})).Return(nil).Once()
+
+	err := service.Start()
+
+	assert.NoError(t, err, "Transient errors should not stop the service")
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, circuitBreakerOpenDuration}, delays)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metricCircuitBreakerState.WithLabelValues("namespace", "resource")))
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestStart_ProcessMessageInFlightWhenStopping(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	mockKubeManager.On("GetEphemeralRunnerSetListenerScaleSettings", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockKubeManager.On("RecordEphemeralRunnerSetLastMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+			s.workCtx = workCtx
+		},
+	)
+
+	mockRsClient.On("GetRunnerScaleSetMessage", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		// SIGTERM arrives while the message is processed.
+		cancel()
+		handler := args.Get(1).(func(*actions.RunnerScaleSetMessage) error)
+		err := handler(&actions.RunnerScaleSetMessage{
+			MessageId:   1,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 1},
+			Body:        `[{"messageType":"JobAvailable","runnerRequestId":1}]`,
+		})
+		assert.NoError(t, err)
+	}).Return(nil).Once()
+	mockRsClient.On("AcquireJobsForRunnerScaleSet", workCtx, []int64{1}).Return(nil).Once()
+	mockKubeManager.On("ScaleEphemeralRunnerSet", workCtx, "namespace", "resource", 1, mock.Anything).Return(nil).Once()
+
+	err := service.Start()
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+	assert.True(t, mockKubeManager.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestStart_StopDuringPoll(t *testing.T) {
+	mockRsClient := &MockRunnerScaleSetClient{}
+	mockKubeManager := &MockKubernetesManager{}
+	logger, log_err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
+	logger = logger.WithName(t.Name())
+	require.NoError(t, log_err, "Error creating logger")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewService(
+		ctx,
+		mockRsClient,
+		mockKubeManager,
+		&ScaleSettings{
+			Namespace:    "namespace",
+			ResourceName: "resource",
+			MinRunners:   0,
+			MaxRunners:   5,
+		},
+		func(s *Service) {
+			s.logger = logger
+		},
+	)
+	mockRsClient.On("GetRunnerScaleSetMessage", ctx, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(context.Canceled).Once()
+
+	err := service.Start()
+
+	assert.NoError(t, err, "Interrupted poll should stop the service gracefully")
+	assert.True(t, mockRsClient.AssertExpectations(t), "All expectations should be met")
+}
+
+func TestRemoveQueuedJobHints(t *testing.T) {
+	queuedAt := metav1.Now()
+	hints := []v1alpha1.QueuedJobHint{
+		{WorkflowRunId: 1, QueuedAt: queuedAt},
+		{WorkflowRunId: 2, QueuedAt: queuedAt},
+		{WorkflowRunId: 1, QueuedAt: queuedAt},
+	}
+
+	remaining := removeQueuedJobHints(hints, []int64{1, 3})
+	assert.Equal(t, []v1alpha1.QueuedJobHint{{WorkflowRunId: 2, QueuedAt: queuedAt}, {WorkflowRunId: 1, QueuedAt: queuedAt}}, remaining, "A hint is removed for each assigned job of the run")
+	assert.Len(t, hints, 3, "The hints are not modified")
+
+	assert.Empty(t, removeQueuedJobHints(hints, []int64{1, 1, 2, 2}))
+}
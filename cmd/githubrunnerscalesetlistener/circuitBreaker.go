@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+const (
+	// circuitBreakerThreshold is the number of consecutive transient failures that opens the circuit breaker.
+	circuitBreakerThreshold = 5
+	// circuitBreakerOpenDuration is how long the circuit breaker stays open before a call probes the service again.
+	circuitBreakerOpenDuration = 2 * time.Minute
+
+	// retryBaseDelay is the delay after the first failure, doubled with each consecutive failure.
+	retryBaseDelay = 2 * time.Second
+)
+
+// circuitBreaker backs off the calls to the Actions service while it fails with transient errors.
+// The breaker opens after circuitBreakerThreshold consecutive failures, lets a single call probe the
+// service after circuitBreakerOpenDuration and closes again on the first success.
+type circuitBreaker struct {
+	state    v1alpha1.ListenerCircuitBreakerState
+	failures int
+	openedAt time.Time
+
+	now func() time.Time
+	// jitter randomizes a delay to spread the retries of the listeners.
+	jitter func(time.Duration) time.Duration
+	// onStateChange is called after each state transition.
+	onStateChange func(state v1alpha1.ListenerCircuitBreakerState, failures int)
+}
+
+func newCircuitBreaker(onStateChange func(state v1alpha1.ListenerCircuitBreakerState, failures int)) *circuitBreaker {
+	return &circuitBreaker{
+		state:         v1alpha1.ListenerCircuitBreakerClosed,
+		now:           time.Now,
+		jitter:        equalJitter,
+		onStateChange: onStateChange,
+	}
+}
+
+// allow moves an open breaker to half-open once it has been open long enough,
+// so that the next call probes the service.
+func (b *circuitBreaker) allow() {
+	if b.state == v1alpha1.ListenerCircuitBreakerOpen && b.now().Sub(b.openedAt) >= circuitBreakerOpenDuration {
+		b.setState(v1alpha1.ListenerCircuitBreakerHalfOpen)
+	}
+}
+
+// success closes the breaker.
+func (b *circuitBreaker) success() {
+	b.failures = 0
+	if b.state != v1alpha1.ListenerCircuitBreakerClosed {
+		b.setState(v1alpha1.ListenerCircuitBreakerClosed)
+	}
+}
+
+// failure records a transient failure and returns how long to wait before the next call.
+func (b *circuitBreaker) failure() time.Duration {
+	b.failures++
+
+	if b.state == v1alpha1.ListenerCircuitBreakerHalfOpen || b.failures >= circuitBreakerThreshold {
+		b.openedAt = b.now()
+		if b.state != v1alpha1.ListenerCircuitBreakerOpen {
+			b.setState(v1alpha1.ListenerCircuitBreakerOpen)
+		}
+		return b.jitter(circuitBreakerOpenDuration)
+	}
+
+	return b.jitter(retryBaseDelay << (b.failures - 1))
+}
+
+func (b *circuitBreaker) setState(state v1alpha1.ListenerCircuitBreakerState) {
+	b.state = state
+	if b.onStateChange != nil {
+		b.onStateChange(state, b.failures)
+	}
+}
+
+// equalJitter returns a random duration between half of d and d.
+func equalJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// messageHandlerError is an error of processing a message, e.g. an error of the Kubernetes API,
+// as opposed to an error of the Actions client getting or deleting the message.
+type messageHandlerError struct {
+	err error
+}
+
+func (e *messageHandlerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *messageHandlerError) Unwrap() error {
+	return e.err
+}
+
+// isTransientError reports whether the error of the Actions client is worth retrying:
+// a server side error of the Actions service or a broken connection to it.
+// Errors of processing a message are never transient, so they are returned as they are.
+func isTransientError(err error) bool {
+	var handlerError *messageHandlerError
+	if errors.As(err, &handlerError) {
+		return false
+	}
+
+	actionsError := &actions.ActionsError{}
+	if errors.As(err, &actionsError) {
+		return actionsError.StatusCode >= 500
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	// The http client wraps all of its errors, the rate limits of the actions client included,
+	// in a url.Error implementing net.Error, so only the errors of the connection are matched.
+	var opError *net.OpError
+	if errors.As(err, &opError) {
+		return true
+	}
+
+	var netError net.Error
+	return errors.As(err, &netError) && netError.Timeout()
+}
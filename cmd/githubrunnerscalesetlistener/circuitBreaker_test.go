@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	var states []v1alpha1.ListenerCircuitBreakerState
+	b := newCircuitBreaker(func(state v1alpha1.ListenerCircuitBreakerState, failures int) {
+		states = append(states, state)
+	})
+	b.now = func() time.Time { return now }
+	b.jitter = func(d time.Duration) time.Duration { return d }
+
+	// Exponential backoff until the threshold is reached.
+	for _, expected := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second} {
+		b.allow()
+		assert.Equal(t, expected, b.failure())
+	}
+	assert.Equal(t, v1alpha1.ListenerCircuitBreakerClosed, b.state)
+
+	b.allow()
+	assert.Equal(t, circuitBreakerOpenDuration, b.failure())
+	assert.Equal(t, v1alpha1.ListenerCircuitBreakerOpen, b.state)
+
+	// The breaker stays open until the open duration passed.
+	now = now.Add(time.Minute)
+	b.allow()
+	assert.Equal(t, v1alpha1.ListenerCircuitBreakerOpen, b.state)
+
+	// A failed probe opens the breaker again.
+	now = now.Add(time.Minute)
+	b.allow()
+	assert.Equal(t, v1alpha1.ListenerCircuitBreakerHalfOpen, b.state)
+	assert.Equal(t, circuitBreakerOpenDuration, b.failure())
+	assert.Equal(t, v1alpha1.ListenerCircuitBreakerOpen, b.state)
+
+	// A successful probe closes the breaker.
+	now = now.Add(circuitBreakerOpenDuration)
+	b.allow()
+	b.success()
+	assert.Equal(t, v1alpha1.ListenerCircuitBreakerClosed, b.state)
+	assert.Equal(t, 0, b.failures)
+	b.success()
+
+	assert.Equal(t, []v1alpha1.ListenerCircuitBreakerState{
+		v1alpha1.ListenerCircuitBreakerOpen,
+		v1alpha1.ListenerCircuitBreakerHalfOpen,
+		v1alpha1.ListenerCircuitBreakerOpen,
+		v1alpha1.ListenerCircuitBreakerHalfOpen,
+		v1alpha1.ListenerCircuitBreakerClosed,
+	}, states)
+}
+
+func TestEqualJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := equalJitter(10 * time.Second)
+		assert.GreaterOrEqual(t, d, 5*time.Second)
+		assert.Less(t, d, 10*time.Second)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, isTransientError(fmt.Errorf("get message failed. %w", &actions.ActionsError{StatusCode: http.StatusBadGateway})))
+	assert.True(t, isTransientError(fmt.Errorf("get message failed. %w", syscall.ECONNRESET)))
+	assert.False(t, isTransientError(fmt.Errorf("get message failed. %w", &actions.ActionsError{StatusCode: http.StatusNotFound})))
+	assert.False(t, isTransientError(fmt.Errorf("error")))
+
+	// The Kubernetes API unreachable while processing a message is not an error of the Actions service.
+	kubernetesError := fmt.Errorf("could not scale ephemeral runner set. %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+	assert.False(t, isTransientError(fmt.Errorf("handle message failed. %w", &messageHandlerError{err: kubernetesError})))
+	assert.False(t, isTransientError(&messageHandlerError{err: &actions.ActionsError{StatusCode: http.StatusBadGateway}}), "Errors of the Actions client while processing a message are returned as they are")
+}
+
+func TestIsTransientError_ActionsClient(t *testing.T) {
+	getMessage := func(t *testing.T, handler http.HandlerFunc, closed bool) error {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		if closed {
+			server.Close()
+		}
+
+		client, err := actions.NewClient(
+			"https://github.com/owner/repo",
+			&actions.ActionsAuth{Token: "token"},
+			actions.WithRetryMax(1),
+			actions.WithRetryWaitMax(time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		_, err = client.GetMessage(context.Background(), server.URL, "token", 0)
+		require.Error(t, err)
+		return err
+	}
+
+	t.Run("server errors once the retries are exhausted", func(t *testing.T) {
+		err := getMessage(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, "<html>Bad Gateway</html>")
+		}, false)
+		assert.True(t, isTransientError(fmt.Errorf("get message failed. %w", err)), "got: %v", err)
+	})
+
+	t.Run("connection errors once the retries are exhausted", func(t *testing.T) {
+		err := getMessage(t, nil, true)
+		assert.True(t, isTransientError(fmt.Errorf("get message failed. %w", err)), "got: %v", err)
+	})
+
+	t.Run("client errors", func(t *testing.T) {
+		err := getMessage(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}, false)
+		assert.False(t, isTransientError(fmt.Errorf("get message failed. %w", err)), "got: %v", err)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultLivenessPollTimeout is how long the listener may go without a successful poll of the
+// message queue before it is reported unhealthy. Polls are long polls returning within a minute.
+const defaultLivenessPollTimeout = 10 * time.Minute
+
+// healthProbes reports the health of the listener to the liveness and readiness probes of its pod.
+// The listener is ready once its message session is created, and live as long as it keeps polling
+// the message queue successfully. It is live while it creates the session, which is retried for minutes.
+type healthProbes struct {
+	mu             sync.Mutex
+	sessionCreated bool
+	lastPolledAt   time.Time
+
+	pollTimeout time.Duration
+	now         func() time.Time
+}
+
+func newHealthProbes(pollTimeout time.Duration) *healthProbes {
+	if pollTimeout <= 0 {
+		pollTimeout = defaultLivenessPollTimeout
+	}
+	return &healthProbes{
+		pollTimeout: pollTimeout,
+		now:         time.Now,
+	}
+}
+
+// sessionReady records that the message session is created.
+func (h *healthProbes) sessionReady() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessionCreated = true
+	h.lastPolledAt = h.now()
+}
+
+// polled records a successful poll of the message queue.
+func (h *healthProbes) polled() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPolledAt = h.now()
+}
+
+func (h *healthProbes) live() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.sessionCreated {
+		return nil
+	}
+	if since := h.now().Sub(h.lastPolledAt); since > h.pollTimeout {
+		return fmt.Errorf("no successful poll of the message queue for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+func (h *healthProbes) ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.sessionCreated {
+		return fmt.Errorf("message session is not created yet")
+	}
+	return nil
+}
+
+// handler serves the liveness probe on /healthz and the readiness probe on /readyz.
+func (h *healthProbes) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", probeHandler(h.live))
+	mux.Handle("/readyz", probeHandler(h.ready))
+	return mux
+}
+
+func probeHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
+}
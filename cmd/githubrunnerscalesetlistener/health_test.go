@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthProbes(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := newHealthProbes(5 * time.Minute)
+	h.now = func() time.Time { return now }
+	handler := h.handler()
+
+	probe := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// Live but not ready while the session is created.
+	now = now.Add(time.Hour)
+	assert.Equal(t, http.StatusOK, probe("/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+
+	h.sessionReady()
+	assert.Equal(t, http.StatusOK, probe("/healthz"))
+	assert.Equal(t, http.StatusOK, probe("/readyz"))
+
+	now = now.Add(4 * time.Minute)
+	h.polled()
+	now = now.Add(4 * time.Minute)
+	assert.Equal(t, http.StatusOK, probe("/healthz"))
+
+	// Wedged without a successful poll for longer than the timeout.
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/healthz"))
+	assert.Equal(t, http.StatusOK, probe("/readyz"))
+}
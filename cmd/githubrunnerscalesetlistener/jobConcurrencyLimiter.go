@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// unassignedJobTimeout is how long an acquired job counts against the limits without
+// being assigned to the scale set. The acquisition may have been lost to another scale
+// set, or the job cancelled, in which case no other message about the job is received.
+const unassignedJobTimeout = 5 * time.Minute
+
+// deferredJobRetention is how long a deferred job is kept. The job may have been acquired by another
+// scale set, or cancelled, in which case no other message about the job is received.
+const deferredJobRetention = time.Hour
+
+// maxDeferredJobs caps the deferred jobs, dropping the oldest ones first, so that a burst of jobs of
+// a noisy repository doesn't grow the listener's memory without bounds. Dropped jobs stay queued.
+const maxDeferredJobs = 1000
+
+type limitedJob struct {
+	repository string
+	workflow   string
+	acquiredAt time.Time
+	assigned   bool
+}
+
+type deferredJob struct {
+	job        actions.JobMessageBase
+	deferredAt time.Time
+}
+
+// jobConcurrencyLimiter caps the number of jobs acquired at the same time per repository and per workflow.
+// Jobs above the limits are not acquired but deferred until jobs of the same repository or workflow complete.
+type jobConcurrencyLimiter struct {
+	maxPerRepository int
+	maxPerWorkflow   int
+
+	active   map[int64]*limitedJob
+	deferred []deferredJob
+	now      func() time.Time
+}
+
+func newJobConcurrencyLimiter(maxPerRepository, maxPerWorkflow int) *jobConcurrencyLimiter {
+	return &jobConcurrencyLimiter{
+		maxPerRepository: maxPerRepository,
+		maxPerWorkflow:   maxPerWorkflow,
+		active:           make(map[int64]*limitedJob),
+		now:              time.Now,
+	}
+}
+
+func (l *jobConcurrencyLimiter) enabled() bool {
+	return l.maxPerRepository > 0 || l.maxPerWorkflow > 0
+}
+
+// admit reports whether the job can be acquired within the limits, counting it as active if so.
+func (l *jobConcurrencyLimiter) admit(job actions.JobMessageBase) bool {
+	if !l.enabled() {
+		return true
+	}
+	if _, ok := l.active[job.RunnerRequestId]; ok {
+		return true
+	}
+
+	l.pruneUnassigned()
+
+	repository, workflow := jobRepository(job), jobWorkflow(job)
+	var repositoryCount, workflowCount int
+	for _, active := range l.active {
+		if active.repository == repository {
+			repositoryCount++
+		}
+		if active.workflow == workflow {
+			workflowCount++
+		}
+	}
+
+	if l.maxPerRepository > 0 && repositoryCount >= l.maxPerRepository {
+		return false
+	}
+	if l.maxPerWorkflow > 0 && workflowCount >= l.maxPerWorkflow {
+		return false
+	}
+
+	l.active[job.RunnerRequestId] = &limitedJob{
+		repository: repository,
+		workflow:   workflow,
+		acquiredAt: l.now(),
+	}
+	return true
+}
+
+// deferJob keeps a job that could not be admitted to acquire it once the limits allow it.
+func (l *jobConcurrencyLimiter) deferJob(job actions.JobMessageBase) {
+	for _, deferred := range l.deferred {
+		if deferred.job.RunnerRequestId == job.RunnerRequestId {
+			return
+		}
+	}
+	l.deferred = append(l.deferred, deferredJob{job: job, deferredAt: l.now()})
+	if len(l.deferred) > maxDeferredJobs {
+		l.deferred = append(l.deferred[:0], l.deferred[len(l.deferred)-maxDeferredJobs:]...)
+	}
+}
+
+// admitDeferred returns the request IDs of the deferred jobs admitted within the limits, oldest first.
+// Expired deferred jobs are dropped.
+func (l *jobConcurrencyLimiter) admitDeferred() []int64 {
+	now := l.now()
+	var admitted []int64
+	remaining := l.deferred[:0]
+	for _, deferred := range l.deferred {
+		if now.Sub(deferred.deferredAt) > deferredJobRetention {
+			continue
+		}
+		if l.admit(deferred.job) {
+			admitted = append(admitted, deferred.job.RunnerRequestId)
+			continue
+		}
+		remaining = append(remaining, deferred)
+	}
+	l.deferred = remaining
+	return admitted
+}
+
+// assigned counts the job as active for good, including jobs acquired before the listener started.
+func (l *jobConcurrencyLimiter) assigned(job actions.JobMessageBase) {
+	if !l.enabled() {
+		return
+	}
+	active, ok := l.active[job.RunnerRequestId]
+	if !ok {
+		active = &limitedJob{
+			repository: jobRepository(job),
+			workflow:   jobWorkflow(job),
+			acquiredAt: l.now(),
+		}
+		l.active[job.RunnerRequestId] = active
+	}
+	active.assigned = true
+}
+
+func (l *jobConcurrencyLimiter) completed(requestId int64) {
+	delete(l.active, requestId)
+	for i, deferred := range l.deferred {
+		if deferred.job.RunnerRequestId == requestId {
+			l.deferred = append(l.deferred[:i], l.deferred[i+1:]...)
+			break
+		}
+	}
+}
+
+func (l *jobConcurrencyLimiter) pruneUnassigned() {
+	now := l.now()
+	for requestId, active := range l.active {
+		if !active.assigned && now.Sub(active.acquiredAt) > unassignedJobTimeout {
+			delete(l.active, requestId)
+		}
+	}
+}
+
+func jobRepository(job actions.JobMessageBase) string {
+	return job.OwnerName + "/" + job.RepositoryName
+}
+
+// jobWorkflow returns the workflow file of the job, regardless of the ref it runs on.
+func jobWorkflow(job actions.JobMessageBase) string {
+	workflow, _, _ := strings.Cut(job.JobWorkflowRef, "@")
+	return workflow
+}
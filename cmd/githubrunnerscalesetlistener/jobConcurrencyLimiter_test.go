@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobConcurrencyLimiter(t *testing.T) {
+	job := func(requestId int64, repository, workflowRef string) actions.JobMessageBase {
+		return actions.JobMessageBase{
+			RunnerRequestId: requestId,
+			OwnerName:       "org",
+			RepositoryName:  repository,
+			JobWorkflowRef:  workflowRef,
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		l := newJobConcurrencyLimiter(0, 0)
+		for i := int64(0); i < 10; i++ {
+			assert.True(t, l.admit(job(i, "repo", "org/repo/.github/workflows/ci.yml@refs/heads/main")))
+		}
+		assert.Empty(t, l.active)
+	})
+
+	t.Run("per workflow regardless of ref", func(t *testing.T) {
+		l := newJobConcurrencyLimiter(0, 2)
+		assert.True(t, l.admit(job(1, "repo", "org/repo/.github/workflows/ci.yml@refs/heads/main")))
+		assert.True(t, l.admit(job(2, "repo", "org/repo/.github/workflows/ci.yml@refs/heads/feature")))
+		assert.False(t, l.admit(job(3, "repo", "org/repo/.github/workflows/ci.yml@refs/heads/main")))
+		assert.True(t, l.admit(job(4, "repo", "org/repo/.github/workflows/release.yml@refs/tags/v1")))
+	})
+
+	t.Run("deferred jobs are admitted in order", func(t *testing.T) {
+		l := newJobConcurrencyLimiter(1, 0)
+		assert.True(t, l.admit(job(1, "repo", "")))
+		assert.False(t, l.admit(job(2, "repo", "")))
+		l.deferJob(job(2, "repo", ""))
+		assert.False(t, l.admit(job(3, "repo", "")))
+		l.deferJob(job(3, "repo", ""))
+		l.deferJob(job(2, "repo", ""))
+
+		assert.Empty(t, l.admitDeferred())
+
+		l.completed(1)
+		assert.Equal(t, []int64{2}, l.admitDeferred())
+		assert.Len(t, l.deferred, 1)
+
+		l.completed(3)
+		assert.Empty(t, l.deferred, "completed jobs are no longer deferred")
+	})
+
+	t.Run("deferred jobs are capped and expire", func(t *testing.T) {
+		now := time.Now()
+		l := newJobConcurrencyLimiter(1, 0)
+		l.now = func() time.Time { return now }
+
+		assert.True(t, l.admit(job(0, "repo", "")))
+		l.assigned(job(0, "repo", ""))
+		for i := int64(1); i <= maxDeferredJobs+1; i++ {
+			l.deferJob(job(i, "repo", ""))
+		}
+		assert.Len(t, l.deferred, maxDeferredJobs)
+		assert.Equal(t, int64(2), l.deferred[0].job.RunnerRequestId, "the oldest deferred job is dropped")
+
+		now = now.Add(deferredJobRetention + time.Second)
+		l.deferJob(job(maxDeferredJobs+2, "repo", ""))
+		l.completed(0)
+		assert.Equal(t, []int64{maxDeferredJobs + 2}, l.admitDeferred(), "expired deferred jobs are dropped")
+		assert.Empty(t, l.deferred)
+	})
+
+	t.Run("unassigned jobs stop counting after a timeout", func(t *testing.T) {
+		now := time.Now()
+		l := newJobConcurrencyLimiter(1, 0)
+		l.now = func() time.Time { return now }
+
+		assert.True(t, l.admit(job(1, "repo", "")))
+		assert.True(t, l.admit(job(2, "other", "")))
+		l.assigned(job(2, "other", ""))
+
+		now = now.Add(unassignedJobTimeout + time.Second)
+		assert.True(t, l.admit(job(3, "repo", "")), "acquisition of job 1 was lost")
+		assert.False(t, l.admit(job(4, "other", "")), "assigned jobs count until they complete")
+	})
+}
@@ -2,11 +2,29 @@ package main
 
 import (
 	"context"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
 )
 
 //go:generate mockery --inpackage --name=KubernetesManager
 type KubernetesManager interface {
-	ScaleEphemeralRunnerSet(ctx context.Context, namespace, resourceName string, runnerCount int) error
+	ScaleEphemeralRunnerSet(ctx context.Context, namespace, resourceName string, runnerCount int, trigger v1alpha1.ScaleTrigger) error
+
+	ScaleEphemeralRunnerSetVariants(ctx context.Context, namespace, resourceName string, variantReplicas map[string]int) error
+
+	GetEphemeralRunnerSetPendingRunners(ctx context.Context, namespace, resourceName string) (int, error)
+
+	GetEphemeralRunnerSetListenerScaleSettings(ctx context.Context, namespace, resourceName string) (*v1alpha1.ListenerScaleSettings, error)
+
+	RemoveEphemeralRunnerSetQueuedJobHints(ctx context.Context, namespace, resourceName string, workflowRunIds []int64) error
+
+	RecordEphemeralRunnerSetLastMessage(ctx context.Context, namespace, resourceName string, messageId int64, processedAt time.Time, statistics *actions.RunnerScaleSetStatistic) error
+
+	RecordEphemeralRunnerSetCircuitBreaker(ctx context.Context, namespace, resourceName string, status *v1alpha1.ListenerCircuitBreakerStatus) error
+
+	UpdateEphemeralRunnerWithJobInfo(ctx context.Context, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName string, jobRequestId, workflowRunId int64, requestLabels []string) error
 
-	UpdateEphemeralRunnerWithJobInfo(ctx context.Context, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName string, jobRequestId, workflowRunId int64) error
+	UpdateEphemeralRunnerWithJobResult(ctx context.Context, namespace, resourceName, result string) error
 }
@@ -18,10 +18,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/actions/actions-runner-controller/build"
 	"github.com/actions/actions-runner-controller/github/actions"
@@ -30,6 +32,10 @@ import (
 	"github.com/kelseyhightower/envconfig"
 )
 
+// shutdownTimeout bounds how long the in-flight message is processed after the listener is asked to stop.
+// Together with the deletion of the message session, it fits the termination grace period of the listener pod.
+const shutdownTimeout = 20 * time.Second
+
 type RunnerScaleSetListenerConfig struct {
 	ConfigureUrl                string `split_words:"true"`
 	AppID                       int64  `split_words:"true"`
@@ -41,18 +47,48 @@ type RunnerScaleSetListenerConfig struct {
 	MaxRunners                  int    `split_words:"true"`
 	MinRunners                  int    `split_words:"true"`
 	RunnerScaleSetId            int    `split_words:"true"`
+	TemplateVariantLabels       string `split_words:"true"`
+
+	HoldJobsAboveMaxRunners bool     `split_words:"true"`
+	OverflowSourceLabels    []string `split_words:"true"`
+
+	MaxJobsPerRepository int `split_words:"true"`
+	MaxJobsPerWorkflow   int `split_words:"true"`
+
+	JobAcquisitionBatchSize int `split_words:"true"`
+	ScalingBufferPercent    int `split_words:"true"`
+	ScalingSmoothingPercent int `split_words:"true"`
+	MaxPendingRunners       int `split_words:"true"`
+
+	AutoscalingRunnerSetName string `split_words:"true"`
+	MetricsAddr              string `split_words:"true"`
+	AuditLog                 bool   `split_words:"true"`
+	LogLevel                 string `split_words:"true" default:"debug"`
+	LogFormat                string `split_words:"true" default:"text"`
+	ServerRootCAFile         string `envconfig:"server_root_ca_file"`
+
+	HealthProbeAddr     string        `split_words:"true"`
+	LivenessPollTimeout time.Duration `split_words:"true"`
 }
 
 func main() {
-	logger, err := logging.NewLogger(logging.LogLevelDebug, logging.LogFormatText)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: creating logger: %v\n", err)
-		os.Exit(1)
+	if ok, err := runPrePullCommand(os.Args[1:]); ok {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	var rc RunnerScaleSetListenerConfig
 	if err := envconfig.Process("github", &rc); err != nil {
-		logger.Error(err, "Error: processing environment variables for RunnerScaleSetListenerConfig")
+		fmt.Fprintf(os.Stderr, "Error: processing environment variables for RunnerScaleSetListenerConfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger, err := logging.NewLogger(rc.LogLevel, rc.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: creating logger: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -73,6 +109,25 @@ func run(rc RunnerScaleSetListenerConfig, logger logr.Logger) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// The message in flight when the listener is asked to stop is still processed,
+	// within the shutdown timeout.
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	go func() {
+		<-ctx.Done()
+		select {
+		case <-time.After(shutdownTimeout):
+			logger.Info("shutdown timeout exceeded, abandoning the message in flight.")
+			cancelWork()
+		case <-workCtx.Done():
+		}
+	}()
+
+	health := newHealthProbes(rc.LivenessPollTimeout)
+	if rc.HealthProbeAddr != "" {
+		serveHTTP(ctx, rc.HealthProbeAddr, health.handler(), logger.WithName("health"))
+	}
+
 	creds := &actions.ActionsAuth{}
 	if rc.Token != "" {
 		creds.Token = rc.Token
@@ -84,22 +139,44 @@ func run(rc RunnerScaleSetListenerConfig, logger logr.Logger) error {
 		}
 	}
 
-	actionsServiceClient, err := actions.NewClient(
-		rc.ConfigureUrl,
-		creds,
+	options := []actions.ClientOption{
 		actions.WithUserAgent(fmt.Sprintf("actions-runner-controller/%s", build.Version)),
 		actions.WithLogger(logger),
-	)
+	}
+	if rc.AuditLog {
+		options = append(options, actions.WithAuditLog(os.Stdout))
+	}
+	if rc.ServerRootCAFile != "" {
+		cert, err := os.ReadFile(rc.ServerRootCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the GitHub server root CAs: %w", err)
+		}
+		rootCAs, err := actions.RootCAsFromConfigMap(map[string][]byte{rc.ServerRootCAFile: cert})
+		if err != nil {
+			return fmt.Errorf("failed to load the GitHub server root CAs: %w", err)
+		}
+		options = append(options, actions.WithRootCAs(rootCAs))
+	}
+
+	actionsServiceClient, err := actions.NewClient(rc.ConfigureUrl, creds, options...)
 	if err != nil {
 		return fmt.Errorf("failed to create an Actions Service client: %w", err)
 	}
 
 	// Create message listener
-	autoScalerClient, err := NewAutoScalerClient(ctx, actionsServiceClient, &logger, rc.RunnerScaleSetId)
+	autoScalerClient, err := NewAutoScalerClient(ctx, actionsServiceClient, &logger, rc.RunnerScaleSetId, func(asc *AutoScalerClient) {
+		asc.health = health
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create a message listener: %w", err)
 	}
-	defer autoScalerClient.Close()
+	defer func() {
+		// Delete the message session, so that the next listener doesn't wait for it to expire.
+		if err := autoScalerClient.Close(); err != nil {
+			logger.Error(err, "failed to delete the message session")
+		}
+	}()
+	health.sessionReady()
 
 	// Create kube manager and scale controller
 	kubeManager, err := NewKubernetesManager(&logger)
@@ -107,15 +184,37 @@ func run(rc RunnerScaleSetListenerConfig, logger logr.Logger) error {
 		return fmt.Errorf("failed to create kubernetes manager: %w", err)
 	}
 
+	var templateVariantLabels map[string][]string
+	if rc.TemplateVariantLabels != "" {
+		if err := json.Unmarshal([]byte(rc.TemplateVariantLabels), &templateVariantLabels); err != nil {
+			return fmt.Errorf("failed to parse template variant labels: %w", err)
+		}
+	}
+
 	scaleSettings := &ScaleSettings{
-		Namespace:    rc.EphemeralRunnerSetNamespace,
-		ResourceName: rc.EphemeralRunnerSetName,
-		MaxRunners:   rc.MaxRunners,
-		MinRunners:   rc.MinRunners,
+		Namespace:               rc.EphemeralRunnerSetNamespace,
+		ResourceName:            rc.EphemeralRunnerSetName,
+		MaxRunners:              rc.MaxRunners,
+		MinRunners:              rc.MinRunners,
+		TemplateVariantLabels:   templateVariantLabels,
+		HoldJobsAboveMaxRunners: rc.HoldJobsAboveMaxRunners,
+		OverflowSourceLabels:    rc.OverflowSourceLabels,
+		MaxJobsPerRepository:    rc.MaxJobsPerRepository,
+		MaxJobsPerWorkflow:      rc.MaxJobsPerWorkflow,
+		JobAcquisitionBatchSize: rc.JobAcquisitionBatchSize,
+		ScalingBufferPercent:    rc.ScalingBufferPercent,
+		ScalingSmoothingPercent: rc.ScalingSmoothingPercent,
+		MaxPendingRunners:       rc.MaxPendingRunners,
+		ScaleSetName:            rc.AutoscalingRunnerSetName,
+	}
+
+	if rc.MetricsAddr != "" {
+		serveHTTP(ctx, rc.MetricsAddr, metricsHandler(), logger.WithName("metrics"))
 	}
 
 	service := NewService(ctx, autoScalerClient, kubeManager, scaleSettings, func(s *Service) {
 		s.logger = logger.WithName("service")
+		s.workCtx = workCtx
 	})
 
 	// Start listening for messages
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// metricsRegistry holds the listener metrics, served by serveMetrics.
+var metricsRegistry = prometheus.NewRegistry()
+
+func init() {
+	metricsRegistry.MustRegister(
+		metricJobQueueToRunningSeconds,
+		metricJobDurationSeconds,
+		metricCircuitBreakerState,
+	)
+}
+
+var metricJobQueueToRunningSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "github_runner_scale_set_job_queue_to_running_seconds",
+		Help:    "The number of seconds from when the listener saw a job queued to when a runner of the scale set started it",
+		Buckets: []float64{1, 2, 5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600, 1200, 1800, 3600},
+	},
+	[]string{"namespace", "name"},
+)
+
+var metricJobDurationSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "github_runner_scale_set_job_duration_seconds",
+		Help:    "The number of seconds from when a job was assigned to a runner of the scale set to when it completed",
+		Buckets: []float64{10, 30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200, 10800, 14400, 21600},
+	},
+	[]string{"namespace", "name", "repository"},
+)
+
+var metricCircuitBreakerState = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "github_runner_scale_set_listener_circuit_breaker_state",
+		Help: "The state of the circuit breaker of the listener calls to the Actions service: 0 closed, 1 half-open, 2 open",
+	},
+	[]string{"namespace", "name"},
+)
+
+// circuitBreakerStateValues are the values of metricCircuitBreakerState.
+var circuitBreakerStateValues = map[v1alpha1.ListenerCircuitBreakerState]float64{
+	v1alpha1.ListenerCircuitBreakerClosed:   0,
+	v1alpha1.ListenerCircuitBreakerHalfOpen: 1,
+	v1alpha1.ListenerCircuitBreakerOpen:     2,
+}
+
+// serveHTTP serves the handler on addr until ctx is done.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler, logger logr.Logger) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "could not shut down server")
+		}
+	}()
+
+	go func() {
+		logger.Info("serving.", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(err, "could not serve")
+		}
+	}()
+}
+
+// metricsHandler serves the listener metrics on /metrics, along with the metrics of the actions client,
+// such as the rate limits of the Actions service, which are registered with the controller-runtime registry.
+func metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(prometheus.Gatherers{metricsRegistry, ctrlmetrics.Registry}, promhttp.HandlerOpts{}))
+	return mux
+}
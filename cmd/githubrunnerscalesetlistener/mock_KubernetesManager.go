@@ -3,9 +3,15 @@
 package main
 
 import (
+	actions "github.com/actions/actions-runner-controller/github/actions"
+
+	v1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // MockKubernetesManager is an autogenerated mock type for the KubernetesManager type
@@ -13,13 +19,127 @@ type MockKubernetesManager struct {
 	mock.Mock
 }
 
-// ScaleEphemeralRunnerSet provides a mock function with given fields: ctx, namespace, resourceName, runnerCount
-func (_m *MockKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Context, namespace string, resourceName string, runnerCount int) error {
-	ret := _m.Called(ctx, namespace, resourceName, runnerCount)
+// GetEphemeralRunnerSetListenerScaleSettings provides a mock function with given fields: ctx, namespace, resourceName
+func (_m *MockKubernetesManager) GetEphemeralRunnerSetListenerScaleSettings(ctx context.Context, namespace string, resourceName string) (*v1alpha1.ListenerScaleSettings, error) {
+	ret := _m.Called(ctx, namespace, resourceName)
+
+	var r0 *v1alpha1.ListenerScaleSettings
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *v1alpha1.ListenerScaleSettings); ok {
+		r0 = rf(ctx, namespace, resourceName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v1alpha1.ListenerScaleSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, namespace, resourceName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEphemeralRunnerSetPendingRunners provides a mock function with given fields: ctx, namespace, resourceName
+func (_m *MockKubernetesManager) GetEphemeralRunnerSetPendingRunners(ctx context.Context, namespace string, resourceName string) (int, error) {
+	ret := _m.Called(ctx, namespace, resourceName)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, namespace, resourceName)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, namespace, resourceName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordEphemeralRunnerSetCircuitBreaker provides a mock function with given fields: ctx, namespace, resourceName, status
+func (_m *MockKubernetesManager) RecordEphemeralRunnerSetCircuitBreaker(ctx context.Context, namespace string, resourceName string, status *v1alpha1.ListenerCircuitBreakerStatus) error {
+	ret := _m.Called(ctx, namespace, resourceName, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *v1alpha1.ListenerCircuitBreakerStatus) error); ok {
+		r0 = rf(ctx, namespace, resourceName, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordEphemeralRunnerSetLastMessage provides a mock function with given fields: ctx, namespace, resourceName, messageId, processedAt, statistics
+func (_m *MockKubernetesManager) RecordEphemeralRunnerSetLastMessage(ctx context.Context, namespace string, resourceName string, messageId int64, processedAt time.Time, statistics *actions.RunnerScaleSetStatistic) error {
+	ret := _m.Called(ctx, namespace, resourceName, messageId, processedAt, statistics)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int64, time.Time, *actions.RunnerScaleSetStatistic) error); ok {
+		r0 = rf(ctx, namespace, resourceName, messageId, processedAt, statistics)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ScaleEphemeralRunnerSet provides a mock function with given fields: ctx, namespace, resourceName, runnerCount, trigger
+func (_m *MockKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Context, namespace string, resourceName string, runnerCount int, trigger v1alpha1.ScaleTrigger) error {
+	ret := _m.Called(ctx, namespace, resourceName, runnerCount, trigger)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, v1alpha1.ScaleTrigger) error); ok {
+		r0 = rf(ctx, namespace, resourceName, runnerCount, trigger)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveEphemeralRunnerSetQueuedJobHints provides a mock function with given fields: ctx, namespace, resourceName, workflowRunIds
+func (_m *MockKubernetesManager) RemoveEphemeralRunnerSetQueuedJobHints(ctx context.Context, namespace string, resourceName string, workflowRunIds []int64) error {
+	ret := _m.Called(ctx, namespace, resourceName, workflowRunIds)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []int64) error); ok {
+		r0 = rf(ctx, namespace, resourceName, workflowRunIds)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ScaleEphemeralRunnerSetVariants provides a mock function with given fields: ctx, namespace, resourceName, variantReplicas
+func (_m *MockKubernetesManager) ScaleEphemeralRunnerSetVariants(ctx context.Context, namespace string, resourceName string, variantReplicas map[string]int) error {
+	ret := _m.Called(ctx, namespace, resourceName, variantReplicas)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]int) error); ok {
+		r0 = rf(ctx, namespace, resourceName, variantReplicas)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateEphemeralRunnerWithJobInfo provides a mock function with given fields: ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName, jobRequestId, workflowRunId, requestLabels
+func (_m *MockKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx context.Context, namespace string, resourceName string, ownerName string, repositoryName string, jobWorkflowRef string, jobDisplayName string, jobRequestId int64, workflowRunId int64, requestLabels []string) error {
+	ret := _m.Called(ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName, jobRequestId, workflowRunId, requestLabels)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) error); ok {
-		r0 = rf(ctx, namespace, resourceName, runnerCount)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string, string, int64, int64, []string) error); ok {
+		r0 = rf(ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName, jobRequestId, workflowRunId, requestLabels)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -27,13 +147,13 @@ func (_m *MockKubernetesManager) ScaleEphemeralRunnerSet(ctx context.Context, na
 	return r0
 }
 
-// UpdateEphemeralRunnerWithJobInfo provides a mock function with given fields: ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName, jobRequestId, workflowRunId
-func (_m *MockKubernetesManager) UpdateEphemeralRunnerWithJobInfo(ctx context.Context, namespace string, resourceName string, ownerName string, repositoryName string, jobWorkflowRef string, jobDisplayName string, jobRequestId int64, workflowRunId int64) error {
-	ret := _m.Called(ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName, jobRequestId, workflowRunId)
+// UpdateEphemeralRunnerWithJobResult provides a mock function with given fields: ctx, namespace, resourceName, result
+func (_m *MockKubernetesManager) UpdateEphemeralRunnerWithJobResult(ctx context.Context, namespace string, resourceName string, result string) error {
+	ret := _m.Called(ctx, namespace, resourceName, result)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string, string, int64, int64) error); ok {
-		r0 = rf(ctx, namespace, resourceName, ownerName, repositoryName, jobWorkflowRef, jobDisplayName, jobRequestId, workflowRunId)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, namespace, resourceName, result)
 	} else {
 		r0 = ret.Error(0)
 	}
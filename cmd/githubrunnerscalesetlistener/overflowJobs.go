@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+)
+
+// overflowJobRetention bounds how long a held job is offered again. The other side of the
+// overflow may have acquired it, or the job was cancelled, in which case no other message
+// about the job is received.
+const overflowJobRetention = time.Hour
+
+// overflowJobDelay is how long the jobs of the scale sets overflowing into this one are left
+// to their own scale set, so that they only run here once it is at its MaxRunners.
+const overflowJobDelay = 30 * time.Second
+
+// overflowJobs are the jobs the listener leaves available for the other side of an overflow,
+// and offers again on the next messages until they are acquired or expire.
+type overflowJobs struct {
+	heldAt map[int64]time.Time
+	order  []int64
+	now    func() time.Time
+}
+
+func newOverflowJobs(now func() time.Time) *overflowJobs {
+	return &overflowJobs{
+		heldAt: make(map[int64]time.Time),
+		now:    now,
+	}
+}
+
+// hold remembers the job. Jobs held again keep their first time.
+func (o *overflowJobs) hold(requestId int64) {
+	if _, ok := o.heldAt[requestId]; ok {
+		return
+	}
+	o.heldAt[requestId] = o.now()
+	o.order = append(o.order, requestId)
+}
+
+// remove forgets the job, e.g. once it is assigned to the scale set.
+func (o *overflowJobs) remove(requestId int64) {
+	if _, ok := o.heldAt[requestId]; !ok {
+		return
+	}
+	delete(o.heldAt, requestId)
+	for i, id := range o.order {
+		if id == requestId {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// release returns at most max of the jobs held for at least minAge, oldest first, and forgets them.
+// Expired jobs are dropped.
+func (o *overflowJobs) release(max int, minAge time.Duration) []int64 {
+	now := o.now()
+	var released []int64
+	order := o.order[:0]
+	for _, id := range o.order {
+		age := now.Sub(o.heldAt[id])
+		switch {
+		case age > overflowJobRetention:
+			delete(o.heldAt, id)
+		case len(released) < max && age >= minAge:
+			delete(o.heldAt, id)
+			released = append(released, id)
+		default:
+			order = append(order, id)
+		}
+	}
+	o.order = order
+	return released
+}
+
+func (o *overflowJobs) count() int {
+	return len(o.order)
+}
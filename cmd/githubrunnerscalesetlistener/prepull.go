@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The image pre-pull DaemonSet of the controller pulls the runner images by running them as init containers.
+// The images may have no shell, so the init containers run a copy of the listener binary, which is static.
+const (
+	// prePullInstallCommand copies the listener binary to the directory given as argument.
+	prePullInstallCommand = "pre-pull-install"
+	// prePullExitCommand exits right away.
+	prePullExitCommand = "pre-pull-exit"
+)
+
+// runPrePullCommand runs the image pre-pull command of the arguments, and returns false when they hold none.
+func runPrePullCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case prePullInstallCommand:
+		if len(args) != 2 {
+			return true, fmt.Errorf("%s takes the destination directory", prePullInstallCommand)
+		}
+		return true, installSelf(args[1])
+	case prePullExitCommand:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func installSelf(dir string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the listener binary: %v", err)
+	}
+	src, err := os.Open(executable)
+	if err != nil {
+		return fmt.Errorf("failed to open the listener binary: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filepath.Join(dir, filepath.Base(executable)), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create the copy of the listener binary: %v", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to copy the listener binary: %v", err)
+	}
+	return dst.Close()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPrePullCommand(t *testing.T) {
+	ok, err := runPrePullCommand(nil)
+	assert.False(t, ok, "the listener should run without arguments")
+	assert.NoError(t, err)
+
+	ok, err = runPrePullCommand([]string{prePullExitCommand})
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	ok, err = runPrePullCommand([]string{prePullInstallCommand, dir})
+	assert.True(t, ok)
+	require.NoError(t, err)
+
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, filepath.Base(executable)))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	_, err = runPrePullCommand([]string{prePullInstallCommand})
+	assert.Error(t, err)
+}
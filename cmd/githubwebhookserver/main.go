@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	actionsgithubcomv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	actionsv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
 	actionssummerwindnet "github.com/actions/actions-runner-controller/controllers/actions.summerwind.net"
 	"github.com/actions/actions-runner-controller/github"
@@ -54,6 +55,7 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = actionsv1alpha1.AddToScheme(scheme)
+	_ = actionsgithubcomv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The actions-runner-controller authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// migrate reads the RunnerDeployments and HorizontalRunnerAutoscalers of a cluster
+// and prints equivalent AutoscalingRunnerSet manifests to stdout.
+// What can't be migrated is reported as warnings on stderr.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	summerwindv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/exec"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = summerwindv1alpha1.AddToScheme(scheme)
+}
+
+func main() {
+	var (
+		namespace string
+		m         migrator
+	)
+
+	flag.StringVar(&namespace, "namespace", "", "The namespace of the RunnerDeployments and HorizontalRunnerAutoscalers to migrate. Defaults to all namespaces.")
+	flag.StringVar(&m.githubURL, "github-url", defaultGitHubURL, "The URL of the GitHub instance the runners register to.")
+	flag.StringVar(&m.githubConfigSecret, "github-config-secret", defaultGitHubConfigSecret, "The secret with the GitHub credentials of the migrated scale sets, unless a RunnerDeployment sets githubAPICredentialsFrom.")
+	flag.Parse()
+
+	if err := run(context.Background(), namespace, &m, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, namespace string, m *migrator, out, warnings io.Writer) error {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load the kubeconfig: %w", err)
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("failed to create the kubernetes client: %w", err)
+	}
+
+	var rds summerwindv1alpha1.RunnerDeploymentList
+	if err := c.List(ctx, &rds, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list runner deployments: %w", err)
+	}
+
+	var hras summerwindv1alpha1.HorizontalRunnerAutoscalerList
+	if err := c.List(ctx, &hras, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list horizontal runner autoscalers: %w", err)
+	}
+
+	sets := m.migrate(rds.Items, hras.Items)
+
+	for _, w := range m.warnings {
+		fmt.Fprintf(warnings, "Warning: %s\n", w)
+	}
+
+	for i := range sets {
+		manifest, err := yaml.Marshal(&sets[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal autoscaling runner set %s/%s: %w", sets[i].Namespace, sets[i].Name, err)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(out, "---")
+		}
+		if _, err := out.Write(manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	summerwindv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultGitHubURL          = "https://github.com"
+	defaultGitHubConfigSecret = "controller-manager"
+
+	defaultRunnerImage = "ghcr.io/actions/actions-runner:latest"
+	dindImage          = "docker:dind"
+
+	runnerContainerName = "runner"
+	dockerContainerName = "docker"
+
+	workVolumeName          = "work"
+	dindCertVolumeName      = "dind-cert"
+	dindExternalsVolumeName = "dind-externals"
+)
+
+// migrator converts RunnerDeployments and the HorizontalRunnerAutoscalers scaling them
+// to AutoscalingRunnerSets, and collects a warning for each setting it can't carry over.
+type migrator struct {
+	githubURL          string
+	githubConfigSecret string
+
+	warnings []string
+}
+
+func (m *migrator) warn(kind string, obj metav1.Object, format string, args ...interface{}) {
+	m.warnings = append(m.warnings, fmt.Sprintf("%s %s/%s: %s", kind, obj.GetNamespace(), obj.GetName(), fmt.Sprintf(format, args...)))
+}
+
+// migrate returns an AutoscalingRunnerSet per RunnerDeployment, scaled within the bounds
+// of the HorizontalRunnerAutoscaler targeting it.
+func (m *migrator) migrate(rds []summerwindv1alpha1.RunnerDeployment, hras []summerwindv1alpha1.HorizontalRunnerAutoscaler) []v1alpha1.AutoscalingRunnerSet {
+	hraByTarget := make(map[string]*summerwindv1alpha1.HorizontalRunnerAutoscaler)
+	for i := range hras {
+		hra := &hras[i]
+		if hra.Spec.ScaleTargetRef.Kind != "" && hra.Spec.ScaleTargetRef.Kind != "RunnerDeployment" {
+			m.warn("HorizontalRunnerAutoscaler", hra, "scale target %s %s is not migrated, only RunnerDeployments are", hra.Spec.ScaleTargetRef.Kind, hra.Spec.ScaleTargetRef.Name)
+			continue
+		}
+		hraByTarget[hra.Namespace+"/"+hra.Spec.ScaleTargetRef.Name] = hra
+	}
+
+	sets := make([]v1alpha1.AutoscalingRunnerSet, 0, len(rds))
+	for i := range rds {
+		rd := &rds[i]
+		key := rd.Namespace + "/" + rd.Name
+		sets = append(sets, *m.migrateRunnerDeployment(rd, hraByTarget[key]))
+		delete(hraByTarget, key)
+	}
+
+	targets := make([]string, 0, len(hraByTarget))
+	for target := range hraByTarget {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		hra := hraByTarget[target]
+		m.warn("HorizontalRunnerAutoscaler", hra, "scale target RunnerDeployment %s is not found", hra.Spec.ScaleTargetRef.Name)
+	}
+
+	return sets
+}
+
+func (m *migrator) migrateRunnerDeployment(rd *summerwindv1alpha1.RunnerDeployment, hra *summerwindv1alpha1.HorizontalRunnerAutoscaler) *v1alpha1.AutoscalingRunnerSet {
+	spec := &rd.Spec.Template.Spec
+
+	ars := &v1alpha1.AutoscalingRunnerSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.GroupVersion.String(),
+			Kind:       "AutoscalingRunnerSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rd.Name,
+			Namespace: rd.Namespace,
+			Labels:    rd.Labels,
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    m.githubConfigURL(spec),
+			GitHubConfigSecret: m.githubConfigSecret,
+			RunnerGroup:        spec.Group,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      rd.Spec.Template.Labels,
+					Annotations: rd.Spec.Template.Annotations,
+				},
+				Spec: m.podSpec(rd),
+			},
+		},
+	}
+
+	if spec.GitHubAPICredentialsFrom != nil && spec.GitHubAPICredentialsFrom.SecretRef.Name != "" {
+		ars.Spec.GitHubConfigSecret = spec.GitHubAPICredentialsFrom.SecretRef.Name
+	}
+
+	if len(spec.Labels) > 0 {
+		m.warn("RunnerDeployment", rd, "runner labels %v are not supported, jobs target the scale set with runs-on: %s", spec.Labels, rd.Name)
+	}
+	if spec.Ephemeral != nil && !*spec.Ephemeral {
+		m.warn("RunnerDeployment", rd, "persistent runners are not supported, the runners of the scale set are ephemeral")
+	}
+	if spec.WorkDir != "" {
+		m.warn("RunnerDeployment", rd, "workDir %q is not supported, the runners work in /actions-runner/_work", spec.WorkDir)
+	}
+	if spec.ContainerMode == "kubernetes" {
+		m.warn("RunnerDeployment", rd, "containerMode kubernetes is not migrated, install the scale set with the containerMode.type=kubernetes chart value instead")
+	}
+	if len(spec.EphemeralContainers) > 0 {
+		m.warn("RunnerDeployment", rd, "ephemeralContainers are not supported in runner pod templates")
+	}
+
+	m.migrateScaling(ars, rd, hra)
+
+	return ars
+}
+
+// githubConfigURL returns the URL of the enterprise, organization or repository the runners register to.
+func (m *migrator) githubConfigURL(spec *summerwindv1alpha1.RunnerSpec) string {
+	var path string
+	switch {
+	case spec.Enterprise != "":
+		path = "enterprises/" + spec.Enterprise
+	case spec.Organization != "":
+		path = spec.Organization
+	default:
+		path = spec.Repository
+	}
+
+	u, err := url.JoinPath(m.githubURL, path)
+	if err != nil {
+		return strings.TrimSuffix(m.githubURL, "/") + "/" + path
+	}
+	return u
+}
+
+// migrateScaling sets the runner bounds of the scale set from the HorizontalRunnerAutoscaler,
+// or pins them to the replicas of the RunnerDeployment when it isn't autoscaled.
+func (m *migrator) migrateScaling(ars *v1alpha1.AutoscalingRunnerSet, rd *summerwindv1alpha1.RunnerDeployment, hra *summerwindv1alpha1.HorizontalRunnerAutoscaler) {
+	if hra == nil {
+		replicas := 1
+		if rd.Spec.Replicas != nil {
+			replicas = *rd.Spec.Replicas
+		}
+		minRunners, maxRunners := replicas, replicas
+		ars.Spec.MinRunners = &minRunners
+		ars.Spec.MaxRunners = &maxRunners
+		return
+	}
+
+	if hra.Spec.MinReplicas != nil {
+		minRunners := *hra.Spec.MinReplicas
+		ars.Spec.MinRunners = &minRunners
+	}
+	if hra.Spec.MaxReplicas != nil {
+		maxRunners := *hra.Spec.MaxReplicas
+		ars.Spec.MaxRunners = &maxRunners
+	}
+
+	if len(hra.Spec.Metrics) > 0 || len(hra.Spec.ScaleUpTriggers) > 0 {
+		m.warn("HorizontalRunnerAutoscaler", hra, "metrics and scaleUpTriggers are not migrated, the scale set scales on the jobs assigned to it")
+	}
+	if hra.Spec.ScaleDownDelaySecondsAfterScaleUp != nil {
+		m.warn("HorizontalRunnerAutoscaler", hra, "scaleDownDelaySecondsAfterScaleOut is not supported, idle runners of the scale set are removed once they are not needed")
+	}
+	if len(hra.Spec.CapacityReservations) > 0 {
+		m.warn("HorizontalRunnerAutoscaler", hra, "capacityReservations are not migrated, keep them by setting scaleTargetRef.kind of the HRA to AutoscalingRunnerSet")
+	}
+	if len(hra.Spec.ScheduledOverrides) > 0 {
+		m.warn("HorizontalRunnerAutoscaler", hra, "scheduledOverrides are not migrated, keep them by setting scaleTargetRef.kind of the HRA to AutoscalingRunnerSet")
+	}
+}
+
+// podSpec returns the runner pod template of the scale set, with a dind sidecar when docker is enabled.
+func (m *migrator) podSpec(rd *summerwindv1alpha1.RunnerDeployment) corev1.PodSpec {
+	spec := &rd.Spec.Template.Spec
+
+	pod := corev1.PodSpec{
+		Volumes:                       append([]corev1.Volume(nil), spec.Volumes...),
+		InitContainers:                append([]corev1.Container(nil), spec.InitContainers...),
+		NodeSelector:                  spec.NodeSelector,
+		ServiceAccountName:            spec.ServiceAccountName,
+		AutomountServiceAccountToken:  spec.AutomountServiceAccountToken,
+		SecurityContext:               spec.SecurityContext,
+		ImagePullSecrets:              spec.ImagePullSecrets,
+		Affinity:                      spec.Affinity,
+		Tolerations:                   spec.Tolerations,
+		PriorityClassName:             spec.PriorityClassName,
+		TerminationGracePeriodSeconds: spec.TerminationGracePeriodSeconds,
+		HostAliases:                   spec.HostAliases,
+		TopologySpreadConstraints:     spec.TopologySpreadConstraints,
+		RuntimeClassName:              spec.RuntimeClassName,
+		DNSPolicy:                     spec.DnsPolicy,
+		DNSConfig:                     spec.DnsConfig,
+		EnableServiceLinks:            spec.EnableServiceLinks,
+		RestartPolicy:                 corev1.RestartPolicyNever,
+	}
+
+	var runner, docker *corev1.Container
+	for i := range spec.Containers {
+		c := spec.Containers[i]
+		switch c.Name {
+		case runnerContainerName:
+			runner = &c
+		case dockerContainerName:
+			docker = &c
+		default:
+			pod.Containers = append(pod.Containers, c)
+		}
+	}
+
+	if runner == nil {
+		runner = &corev1.Container{Name: runnerContainerName}
+	}
+	if runner.Image == "" {
+		runner.Image = spec.Image
+	}
+	if runner.Image == "" {
+		runner.Image = defaultRunnerImage
+	} else {
+		m.warn("RunnerDeployment", rd, "runner image %s must be based on %s to run as a scale set runner", runner.Image, defaultRunnerImage)
+	}
+	if len(runner.Command) == 0 {
+		runner.Command = []string{"/actions-runner/run.sh"}
+	}
+	if runner.ImagePullPolicy == "" {
+		runner.ImagePullPolicy = spec.ImagePullPolicy
+	}
+	if runner.Resources.Limits == nil && runner.Resources.Requests == nil {
+		runner.Resources = spec.Resources
+	}
+	runner.Env = append(runner.Env, spec.Env...)
+	runner.EnvFrom = append(runner.EnvFrom, spec.EnvFrom...)
+	runner.VolumeMounts = append(runner.VolumeMounts, spec.VolumeMounts...)
+
+	pod.Containers = append([]corev1.Container{*runner}, pod.Containers...)
+
+	dockerEnabled := spec.DockerEnabled == nil || *spec.DockerEnabled
+	if dockerEnabled && spec.ContainerMode != "kubernetes" {
+		if spec.DockerdWithinRunnerContainer != nil && *spec.DockerdWithinRunnerContainer {
+			m.warn("RunnerDeployment", rd, "dockerdWithinRunnerContainer is not supported, docker runs in a dind sidecar instead")
+		}
+		m.addDind(&pod, rd, docker)
+	}
+
+	m.addWorkVolume(&pod, rd)
+
+	pod.Containers = append(pod.Containers, spec.SidecarContainers...)
+
+	return pod
+}
+
+// addDind adds the dind sidecar, set up like the dind container mode of the gha-runner-scale-set chart.
+func (m *migrator) addDind(pod *corev1.PodSpec, rd *summerwindv1alpha1.RunnerDeployment, docker *corev1.Container) {
+	spec := &rd.Spec.Template.Spec
+	runner := &pod.Containers[0]
+
+	pod.InitContainers = append(pod.InitContainers, corev1.Container{
+		Name:    "init-dind-externals",
+		Image:   runner.Image,
+		Command: []string{"cp"},
+		Args:    []string{"-r", "-v", "/actions-runner/externals/.", "/actions-runner/tmpDir/"},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: dindExternalsVolumeName, MountPath: "/actions-runner/tmpDir"},
+		},
+	})
+
+	runner.Env = appendEnvIfMissing(runner.Env,
+		corev1.EnvVar{Name: "DOCKER_HOST", Value: "tcp://localhost:2376"},
+		corev1.EnvVar{Name: "DOCKER_TLS_VERIFY", Value: "1"},
+		corev1.EnvVar{Name: "DOCKER_CERT_PATH", Value: "/certs/client"},
+		corev1.EnvVar{Name: "RUNNER_WAIT_FOR_DOCKER_IN_SECONDS", Value: "120"},
+	)
+	runner.VolumeMounts = append(runner.VolumeMounts, corev1.VolumeMount{Name: dindCertVolumeName, MountPath: "/certs/client", ReadOnly: true})
+
+	if docker == nil {
+		docker = &corev1.Container{}
+	}
+	docker.Name = "dind"
+	if docker.Image == "" {
+		docker.Image = dindImage
+	}
+	if docker.SecurityContext == nil {
+		privileged := true
+		docker.SecurityContext = &corev1.SecurityContext{Privileged: &privileged}
+	}
+	if docker.Resources.Limits == nil && docker.Resources.Requests == nil {
+		docker.Resources = spec.DockerdContainerResources
+	}
+	if spec.DockerMTU != nil {
+		docker.Args = append(docker.Args, fmt.Sprintf("--mtu=%d", *spec.DockerMTU))
+	}
+	if spec.DockerRegistryMirror != nil {
+		docker.Args = append(docker.Args, "--registry-mirror="+*spec.DockerRegistryMirror)
+	}
+	docker.Env = append(docker.Env, spec.DockerEnv...)
+	docker.VolumeMounts = append(docker.VolumeMounts,
+		corev1.VolumeMount{Name: dindCertVolumeName, MountPath: "/certs/client"},
+		corev1.VolumeMount{Name: dindExternalsVolumeName, MountPath: "/actions-runner/externals"},
+	)
+	docker.VolumeMounts = append(docker.VolumeMounts, spec.DockerVolumeMounts...)
+	pod.Containers = append(pod.Containers, *docker)
+
+	pod.Volumes = append(pod.Volumes,
+		corev1.Volume{Name: dindCertVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		corev1.Volume{Name: dindExternalsVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	)
+}
+
+// addWorkVolume mounts the work volume into the runner and the dind containers,
+// backed by the workVolumeClaimTemplate or an emptyDir limited by volumeSizeLimit and volumeStorageMedium.
+func (m *migrator) addWorkVolume(pod *corev1.PodSpec, rd *summerwindv1alpha1.RunnerDeployment) {
+	spec := &rd.Spec.Template.Spec
+
+	for _, v := range pod.Volumes {
+		if v.Name == workVolumeName {
+			if spec.WorkVolumeClaimTemplate != nil || spec.VolumeSizeLimit != nil || spec.VolumeStorageMedium != nil {
+				m.warn("RunnerDeployment", rd, "the %q volume takes precedence over workVolumeClaimTemplate, volumeSizeLimit and volumeStorageMedium", workVolumeName)
+			}
+			m.mountWorkVolume(pod)
+			return
+		}
+	}
+
+	work := corev1.Volume{Name: workVolumeName}
+	if t := spec.WorkVolumeClaimTemplate; t != nil {
+		storageClassName := t.StorageClassName
+		work.Ephemeral = &corev1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClassName,
+					AccessModes:      t.AccessModes,
+					Resources:        t.Resources,
+				},
+			},
+		}
+	} else {
+		emptyDir := &corev1.EmptyDirVolumeSource{SizeLimit: spec.VolumeSizeLimit}
+		if spec.VolumeStorageMedium != nil {
+			emptyDir.Medium = corev1.StorageMedium(*spec.VolumeStorageMedium)
+		}
+		work.EmptyDir = emptyDir
+	}
+	pod.Volumes = append(pod.Volumes, work)
+
+	m.mountWorkVolume(pod)
+}
+
+func (m *migrator) mountWorkVolume(pod *corev1.PodSpec) {
+	for i := range pod.Containers {
+		c := &pod.Containers[i]
+		if c.Name != runnerContainerName && c.Name != "dind" {
+			continue
+		}
+
+		mounted := false
+		for _, mount := range c.VolumeMounts {
+			if mount.Name == workVolumeName {
+				mounted = true
+				break
+			}
+		}
+		if !mounted {
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: workVolumeName, MountPath: "/actions-runner/_work"})
+		}
+	}
+}
+
+func appendEnvIfMissing(env []corev1.EnvVar, vars ...corev1.EnvVar) []corev1.EnvVar {
+	for _, v := range vars {
+		found := false
+		for _, e := range env {
+			if e.Name == v.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			env = append(env, v)
+		}
+	}
+	return env
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"testing"
+
+	summerwindv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newMigrator() *migrator {
+	return &migrator{
+		githubURL:          defaultGitHubURL,
+		githubConfigSecret: defaultGitHubConfigSecret,
+	}
+}
+
+func TestMigrate_RunnerDeploymentWithHorizontalRunnerAutoscaler(t *testing.T) {
+	minReplicas, maxReplicas := 1, 10
+	sizeLimit := resource.MustParse("10Gi")
+	mtu := int64(1400)
+
+	rd := summerwindv1alpha1.RunnerDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "runners"},
+		Spec: summerwindv1alpha1.RunnerDeploymentSpec{
+			Template: summerwindv1alpha1.RunnerTemplate{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "a"}},
+				Spec: summerwindv1alpha1.RunnerSpec{
+					RunnerConfig: summerwindv1alpha1.RunnerConfig{
+						Organization:    "my-org",
+						Group:           "my-group",
+						Labels:          []string{"linux"},
+						VolumeSizeLimit: &sizeLimit,
+						DockerMTU:       &mtu,
+					},
+					RunnerPodSpec: summerwindv1alpha1.RunnerPodSpec{
+						Env:          []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+						NodeSelector: map[string]string{"pool": "runners"},
+						Tolerations:  []corev1.Toleration{{Key: "runners", Operator: corev1.TolerationOpExists}},
+						Volumes:      []corev1.Volume{{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+						VolumeMounts: []corev1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+					},
+				},
+			},
+		},
+	}
+	hra := summerwindv1alpha1.HorizontalRunnerAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "runners"},
+		Spec: summerwindv1alpha1.HorizontalRunnerAutoscalerSpec{
+			ScaleTargetRef: summerwindv1alpha1.ScaleTargetRef{Kind: "RunnerDeployment", Name: "example"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    &maxReplicas,
+			Metrics:        []summerwindv1alpha1.MetricSpec{{Type: "PercentageRunnersBusy"}},
+		},
+	}
+
+	m := newMigrator()
+	sets := m.migrate([]summerwindv1alpha1.RunnerDeployment{rd}, []summerwindv1alpha1.HorizontalRunnerAutoscaler{hra})
+	require.Len(t, sets, 1)
+	ars := sets[0]
+
+	assert.Equal(t, "AutoscalingRunnerSet", ars.Kind)
+	assert.Equal(t, "example", ars.Name)
+	assert.Equal(t, "runners", ars.Namespace)
+	assert.Equal(t, "https://github.com/my-org", ars.Spec.GitHubConfigUrl)
+	assert.Equal(t, defaultGitHubConfigSecret, ars.Spec.GitHubConfigSecret)
+	assert.Equal(t, "my-group", ars.Spec.RunnerGroup)
+	assert.Equal(t, 1, *ars.Spec.MinRunners)
+	assert.Equal(t, 10, *ars.Spec.MaxRunners)
+
+	pod := ars.Spec.Template.Spec
+	assert.Equal(t, map[string]string{"team": "a"}, ars.Spec.Template.Labels)
+	assert.Equal(t, map[string]string{"pool": "runners"}, pod.NodeSelector)
+	assert.Len(t, pod.Tolerations, 1)
+
+	require.Len(t, pod.Containers, 2)
+	runner := pod.Containers[0]
+	assert.Equal(t, runnerContainerName, runner.Name)
+	assert.Equal(t, defaultRunnerImage, runner.Image)
+	assert.Equal(t, []string{"/actions-runner/run.sh"}, runner.Command)
+	assert.Contains(t, runner.Env, corev1.EnvVar{Name: "FOO", Value: "bar"})
+	assert.Contains(t, runner.Env, corev1.EnvVar{Name: "DOCKER_HOST", Value: "tcp://localhost:2376"})
+	assert.Contains(t, runner.VolumeMounts, corev1.VolumeMount{Name: "cache", MountPath: "/cache"})
+	assert.Contains(t, runner.VolumeMounts, corev1.VolumeMount{Name: workVolumeName, MountPath: "/actions-runner/_work"})
+
+	dind := pod.Containers[1]
+	assert.Equal(t, "dind", dind.Name)
+	assert.Equal(t, dindImage, dind.Image)
+	assert.True(t, *dind.SecurityContext.Privileged)
+	assert.Equal(t, []string{"--mtu=1400"}, dind.Args)
+	assert.Contains(t, dind.VolumeMounts, corev1.VolumeMount{Name: workVolumeName, MountPath: "/actions-runner/_work"})
+
+	require.Len(t, pod.InitContainers, 1)
+	assert.Equal(t, defaultRunnerImage, pod.InitContainers[0].Image)
+
+	volumes := make(map[string]corev1.Volume)
+	for _, v := range pod.Volumes {
+		volumes[v.Name] = v
+	}
+	assert.Contains(t, volumes, "cache")
+	assert.Contains(t, volumes, dindCertVolumeName)
+	assert.Contains(t, volumes, dindExternalsVolumeName)
+	require.Contains(t, volumes, workVolumeName)
+	assert.Equal(t, &sizeLimit, volumes[workVolumeName].EmptyDir.SizeLimit)
+
+	assert.Len(t, m.warnings, 2, "Labels and metrics should be reported")
+}
+
+func TestMigrate_RunnerDeploymentWithoutAutoscaler(t *testing.T) {
+	replicas := 3
+	dockerEnabled := false
+
+	rd := summerwindv1alpha1.RunnerDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "runners"},
+		Spec: summerwindv1alpha1.RunnerDeploymentSpec{
+			Replicas: &replicas,
+			Template: summerwindv1alpha1.RunnerTemplate{
+				Spec: summerwindv1alpha1.RunnerSpec{
+					RunnerConfig: summerwindv1alpha1.RunnerConfig{
+						Repository:               "owner/repo",
+						Image:                    "my-registry/runner:latest",
+						DockerEnabled:            &dockerEnabled,
+						GitHubAPICredentialsFrom: &summerwindv1alpha1.GitHubAPICredentialsFrom{SecretRef: summerwindv1alpha1.SecretReference{Name: "my-secret"}},
+					},
+				},
+			},
+		},
+	}
+
+	m := &migrator{githubURL: "https://ghes.example.com/", githubConfigSecret: defaultGitHubConfigSecret}
+	sets := m.migrate([]summerwindv1alpha1.RunnerDeployment{rd}, nil)
+	require.Len(t, sets, 1)
+	ars := sets[0]
+
+	assert.Equal(t, "https://ghes.example.com/owner/repo", ars.Spec.GitHubConfigUrl)
+	assert.Equal(t, "my-secret", ars.Spec.GitHubConfigSecret)
+	assert.Equal(t, 3, *ars.Spec.MinRunners)
+	assert.Equal(t, 3, *ars.Spec.MaxRunners)
+
+	pod := ars.Spec.Template.Spec
+	require.Len(t, pod.Containers, 1, "No dind sidecar without docker")
+	assert.Equal(t, "my-registry/runner:latest", pod.Containers[0].Image)
+	assert.Empty(t, pod.InitContainers)
+	require.Len(t, pod.Volumes, 1)
+	assert.Equal(t, workVolumeName, pod.Volumes[0].Name)
+
+	assert.Len(t, m.warnings, 1, "Custom image should be reported")
+}
+
+func TestMigrate_UnmatchedHorizontalRunnerAutoscalers(t *testing.T) {
+	hras := []summerwindv1alpha1.HorizontalRunnerAutoscaler{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "runnerset", Namespace: "runners"},
+			Spec: summerwindv1alpha1.HorizontalRunnerAutoscalerSpec{
+				ScaleTargetRef: summerwindv1alpha1.ScaleTargetRef{Kind: "RunnerSet", Name: "runnerset"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "runners"},
+			Spec: summerwindv1alpha1.HorizontalRunnerAutoscalerSpec{
+				ScaleTargetRef: summerwindv1alpha1.ScaleTargetRef{Name: "missing"},
+			},
+		},
+	}
+
+	m := newMigrator()
+	sets := m.migrate(nil, hras)
+
+	assert.Empty(t, sets)
+	assert.Equal(t, []string{
+		"HorizontalRunnerAutoscaler runners/runnerset: scale target RunnerSet runnerset is not migrated, only RunnerDeployments are",
+		"HorizontalRunnerAutoscaler runners/missing: scale target RunnerDeployment missing is not found",
+	}, m.warnings)
+}
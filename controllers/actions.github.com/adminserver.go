@@ -0,0 +1,329 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const serverShutdownTimeout = 5 * time.Second
+
+// ScaleSetStatus is the status of an AutoscalingRunnerSet returned by the admin API.
+type ScaleSetStatus struct {
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	RunnerScaleSetId int    `json:"runnerScaleSetId,omitempty"`
+	State            string `json:"state,omitempty"`
+
+	DesiredRunners int `json:"desiredRunners"`
+	CurrentRunners int `json:"currentRunners"`
+	RunningRunners int `json:"runningRunners"`
+	PendingRunners int `json:"pendingRunners"`
+
+	RunnerPhases v1alpha1.RunnerPhaseCounts `json:"runnerPhases"`
+
+	LastListenerMessage *ListenerMessageStatus           `json:"lastListenerMessage,omitempty"`
+	JobStatistics       *actions.RunnerScaleSetStatistic `json:"jobStatistics,omitempty"`
+	LastGitHubError     *GitHubErrorStatus               `json:"lastGitHubError,omitempty"`
+}
+
+// ListenerMessageStatus is the last message processed by the listener of a scale set.
+type ListenerMessageStatus struct {
+	MessageId   int64     `json:"messageId"`
+	ProcessedAt time.Time `json:"processedAt"`
+}
+
+// GitHubErrorStatus is the last error returned by GitHub while reconciling a scale set.
+// The message is generic, the details of the error are only logged.
+type GitHubErrorStatus struct {
+	Message    string    `json:"message"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// GitHubErrorRecorder records the errors returned by GitHub for each AutoscalingRunnerSet.
+type GitHubErrorRecorder interface {
+	RecordGitHubError(autoscalingRunnerSet types.NamespacedName, err error)
+}
+
+// AdminServer serves a read-only JSON API with the status of all autoscaling runner sets,
+// for dashboards that should not get access to the Kubernetes API.
+//
+// GitHub errors are kept in memory, so they are only reported by the replica
+// that is running the controllers.
+type AdminServer struct {
+	Client client.Reader
+	Log    logr.Logger
+	Addr   string
+
+	mu           sync.Mutex
+	githubErrors map[types.NamespacedName]GitHubErrorStatus
+	now          func() time.Time
+}
+
+func (s *AdminServer) RecordGitHubError(autoscalingRunnerSet types.NamespacedName, err error) {
+	// The admin API is unauthenticated, so the responses of GitHub, which may name
+	// organizations, repositories or installations, stay in the logs.
+	s.Log.Error(err, "GitHub error", "namespace", autoscalingRunnerSet.Namespace, "name", autoscalingRunnerSet.Name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.githubErrors == nil {
+		s.githubErrors = make(map[types.NamespacedName]GitHubErrorStatus)
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	s.githubErrors[autoscalingRunnerSet] = GitHubErrorStatus{
+		Message:    gitHubErrorMessage(err),
+		OccurredAt: now().UTC(),
+	}
+}
+
+// gitHubErrorMessage returns a message for the error that is safe to serve without authentication.
+func gitHubErrorMessage(err error) string {
+	var actionsErr *actions.ActionsError
+	if errors.As(err, &actionsErr) {
+		return fmt.Sprintf("the Actions service returned status %d", actionsErr.StatusCode)
+	}
+	var apiErr *actions.GitHubAPIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("the GitHub API returned status %d", apiErr.StatusCode)
+	}
+	var rateLimitedErr *actions.RateLimitedError
+	if errors.As(err, &rateLimitedErr) {
+		return "rate limited by GitHub"
+	}
+	return "request to GitHub failed"
+}
+
+func (s *AdminServer) lastGitHubError(autoscalingRunnerSet types.NamespacedName) *GitHubErrorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.githubErrors[autoscalingRunnerSet]
+	if !ok {
+		return nil
+	}
+	return &status
+}
+
+// Handler returns the handler of the admin API. It serves:
+//   - GET /scalesets: the status of all autoscaling runner sets
+//   - GET /scalesets/{namespace}/{name}: the status of a single autoscaling runner set
+func (s *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scalesets", s.handleScaleSets)
+	mux.HandleFunc("/scalesets/", s.handleScaleSets)
+	return mux
+}
+
+func (s *AdminServer) handleScaleSets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/scalesets"), "/")
+	if path == "" {
+		statuses, err := s.scaleSetStatuses(r.Context())
+		if err != nil {
+			s.Log.Error(err, "Failed to get scale set statuses")
+			http.Error(w, "failed to get scale set statuses", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, s.Log, statuses)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	autoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, autoscalingRunnerSet); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.Log.Error(err, "Failed to get autoscaling runner set", "namespace", parts[0], "name", parts[1])
+		http.Error(w, "failed to get scale set status", http.StatusInternalServerError)
+		return
+	}
+
+	status, err := s.scaleSetStatus(r.Context(), autoscalingRunnerSet)
+	if err != nil {
+		s.Log.Error(err, "Failed to get scale set status", "namespace", parts[0], "name", parts[1])
+		http.Error(w, "failed to get scale set status", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, s.Log, status)
+}
+
+func writeJSON(w http.ResponseWriter, log logr.Logger, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error(err, "Failed to write response")
+	}
+}
+
+func (s *AdminServer) scaleSetStatuses(ctx context.Context) ([]ScaleSetStatus, error) {
+	list := new(v1alpha1.AutoscalingRunnerSetList)
+	if err := s.Client.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("failed to list autoscaling runner sets: %v", err)
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		if list.Items[i].Namespace != list.Items[j].Namespace {
+			return list.Items[i].Namespace < list.Items[j].Namespace
+		}
+		return list.Items[i].Name < list.Items[j].Name
+	})
+
+	statuses := make([]ScaleSetStatus, 0, len(list.Items))
+	for i := range list.Items {
+		status, err := s.scaleSetStatus(ctx, &list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+
+	return statuses, nil
+}
+
+func (s *AdminServer) scaleSetStatus(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (*ScaleSetStatus, error) {
+	status := &ScaleSetStatus{
+		Namespace:      autoscalingRunnerSet.Namespace,
+		Name:           autoscalingRunnerSet.Name,
+		State:          autoscalingRunnerSet.Status.State,
+		CurrentRunners: autoscalingRunnerSet.Status.CurrentRunners,
+		LastGitHubError: s.lastGitHubError(types.NamespacedName{
+			Namespace: autoscalingRunnerSet.Namespace,
+			Name:      autoscalingRunnerSet.Name,
+		}),
+	}
+
+	if id, err := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetIdKey]); err == nil {
+		status.RunnerScaleSetId = id
+	}
+
+	list := new(v1alpha1.EphemeralRunnerSetList)
+	if err := s.Client.List(ctx, list, client.InNamespace(autoscalingRunnerSet.Namespace), client.MatchingFields{autoscalingRunnerSetOwnerKey: autoscalingRunnerSet.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list ephemeral runner sets: %v", err)
+	}
+
+	// Runners of all runner sets are counted, including the ones being rolled over.
+	for _, runnerSet := range list.Items {
+		status.DesiredRunners += runnerSet.Spec.Replicas
+		status.RunningRunners += runnerSet.Status.RunningReplicas
+		status.PendingRunners += runnerSet.Status.CurrentReplicas - runnerSet.Status.RunningReplicas
+	}
+	status.RunnerPhases = sumRunnerPhases(list.Items)
+	status.LastListenerMessage, status.JobStatistics = lastListenerReport(list.Items)
+
+	return status, nil
+}
+
+// lastListenerReport returns the last message reported by the listener and its job statistics.
+// Only the runner sets scaled by the listener carry its reports, so the most recent one wins.
+func lastListenerReport(runnerSets []v1alpha1.EphemeralRunnerSet) (*ListenerMessageStatus, *actions.RunnerScaleSetStatistic) {
+	var (
+		message    *ListenerMessageStatus
+		statistics *actions.RunnerScaleSetStatistic
+	)
+	for i := range runnerSets {
+		m := listenerMessageStatus(&runnerSets[i])
+		if m == nil || (message != nil && !m.ProcessedAt.After(message.ProcessedAt)) {
+			continue
+		}
+		message = m
+		statistics = nil
+
+		raw, ok := runnerSets[i].Annotations[v1alpha1.AnnotationKeyJobStatistics]
+		if !ok {
+			continue
+		}
+		var s actions.RunnerScaleSetStatistic
+		if err := json.Unmarshal([]byte(raw), &s); err == nil {
+			statistics = &s
+		}
+	}
+
+	return message, statistics
+}
+
+func listenerMessageStatus(runnerSet *v1alpha1.EphemeralRunnerSet) *ListenerMessageStatus {
+	processedAt, err := time.Parse(time.RFC3339, runnerSet.Annotations[v1alpha1.AnnotationKeyLastListenerMessageTime])
+	if err != nil {
+		return nil
+	}
+
+	messageId, err := strconv.ParseInt(runnerSet.Annotations[v1alpha1.AnnotationKeyLastListenerMessageId], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &ListenerMessageStatus{MessageId: messageId, ProcessedAt: processedAt}
+}
+
+// Start serves the admin API until the context is done.
+func (s *AdminServer) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.Log.Info("Starting admin API server", "addr", s.Addr)
+	return serveUntilDone(ctx, server, server.ListenAndServe)
+}
+
+// serveUntilDone runs serve until it stops or the context is done, in which case the server is shut down.
+// Serve stopping before the context is done is an error, even when it doesn't return one.
+func serveUntilDone(ctx context.Context, server *http.Server, serve func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err, ok := <-errCh:
+		if !ok {
+			return fmt.Errorf("server on %s stopped unexpectedly", server.Addr)
+		}
+		return fmt.Errorf("failed to serve on %s: %w", server.Addr, err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection makes the admin API available on every replica.
+func (s *AdminServer) NeedLeaderElection() bool {
+	return false
+}
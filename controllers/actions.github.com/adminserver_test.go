@@ -0,0 +1,164 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+func newAdminServerForTest(t *testing.T, objs ...client.Object) *AdminServer {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	kubeClient := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithIndex(&v1alpha1.EphemeralRunnerSet{}, autoscalingRunnerSetOwnerKey, func(o client.Object) []string {
+			owner := metav1.GetControllerOf(o)
+			if owner == nil {
+				return nil
+			}
+			return []string{owner.Name}
+		}).
+		Build()
+
+	return &AdminServer{
+		Client: kubeClient,
+		Log:    logr.Discard(),
+	}
+}
+
+func TestAdminServerScaleSets(t *testing.T) {
+	isController := true
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "42"},
+		},
+		Status: v1alpha1.AutoscalingRunnerSetStatus{CurrentRunners: 3},
+	}
+	newRunnerSet := func(name string, replicas, current, running int, annotations map[string]string) *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "runners",
+				Annotations: annotations,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: v1alpha1.GroupVersion.String(),
+						Kind:       "AutoscalingRunnerSet",
+						Name:       autoscalingRunnerSet.Name,
+						Controller: &isController,
+					},
+				},
+			},
+			Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: replicas},
+			Status: v1alpha1.EphemeralRunnerSetStatus{
+				CurrentReplicas: current,
+				RunningReplicas: running,
+			},
+		}
+	}
+	processedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	runnerSet := newRunnerSet("arc-main", 2, 2, 1, map[string]string{
+		v1alpha1.AnnotationKeyLastListenerMessageId:   "7",
+		v1alpha1.AnnotationKeyLastListenerMessageTime: processedAt.Format(time.RFC3339),
+	})
+	runnerSet.Status.RunnerPhases = v1alpha1.RunnerPhaseCounts{PendingPod: 1, Idle: 1}
+	overflow := newRunnerSet("arc-overflow", 1, 1, 0, nil)
+	overflow.Status.RunnerPhases = v1alpha1.RunnerPhaseCounts{PendingPod: 1}
+	other := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+	}
+
+	server := newAdminServerForTest(t, autoscalingRunnerSet, runnerSet, overflow, other)
+	server.now = func() time.Time { return processedAt }
+	server.RecordGitHubError(types.NamespacedName{Namespace: "runners", Name: "arc"}, fmt.Errorf("failed to get runner scale set: %w", &actions.ActionsError{StatusCode: http.StatusUnauthorized, Message: "bad credentials for installation 1234"}))
+
+	t.Run("lists all scale sets", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scalesets", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var statuses []ScaleSetStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+		require.Len(t, statuses, 2)
+		assert.Equal(t, "other", statuses[0].Name)
+		assert.Equal(t, "arc", statuses[1].Name)
+	})
+
+	t.Run("gets a single scale set", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scalesets/runners/arc", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var status ScaleSetStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		expected := ScaleSetStatus{
+			Namespace:           "runners",
+			Name:                "arc",
+			RunnerScaleSetId:    42,
+			DesiredRunners:      3,
+			CurrentRunners:      3,
+			RunningRunners:      1,
+			PendingRunners:      2,
+			RunnerPhases:        v1alpha1.RunnerPhaseCounts{PendingPod: 2, Idle: 1},
+			LastListenerMessage: &ListenerMessageStatus{MessageId: 7, ProcessedAt: processedAt},
+			LastGitHubError:     &GitHubErrorStatus{Message: "the Actions service returned status 401", OccurredAt: processedAt},
+		}
+		assert.Equal(t, expected, status)
+	})
+
+	t.Run("returns not found for unknown scale sets", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scalesets/runners/missing", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("rejects writes", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/scalesets/runners/arc", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+func TestGitHubErrorMessage(t *testing.T) {
+	assert.Equal(t, "the Actions service returned status 404", gitHubErrorMessage(fmt.Errorf("wrapped: %w", &actions.ActionsError{StatusCode: http.StatusNotFound, Message: "scale set 42 of org my-org not found"})))
+	assert.Equal(t, "rate limited by GitHub", gitHubErrorMessage(&actions.RateLimitedError{Until: time.Now()}))
+	assert.Equal(t, "request to GitHub failed", gitHubErrorMessage(errors.New("dial tcp: lookup github.example.com: no such host")))
+}
+
+func TestServeUntilDone(t *testing.T) {
+	server := &http.Server{Addr: ":0"}
+
+	err := serveUntilDone(context.Background(), server, func() error { return http.ErrServerClosed })
+	require.Error(t, err, "The server stopping on its own must be reported")
+	assert.Equal(t, "server on :0 stopped unexpectedly", err.Error())
+
+	err = serveUntilDone(context.Background(), server, func() error { return errors.New("address in use") })
+	assert.EqualError(t, err, "failed to serve on :0: address in use")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	block := make(chan struct{})
+	defer close(block)
+	assert.NoError(t, serveUntilDone(ctx, server, func() error { <-block; return nil }))
+}
@@ -18,13 +18,17 @@ package actionsgithubcom
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/go-logr/logr"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -44,12 +48,37 @@ const (
 	autoscalingListenerFinalizerName = "autoscalinglistener.actions.github.com/finalizer"
 )
 
+// DefaultListenerNodeFailoverTimeout is how long the node of a listener pod can be not ready
+// before the pod is force deleted and re-created on another node, once the failover is enabled.
+// It leaves a node that misses a few heartbeats the time to recover, since the listener of a node
+// that is only cut off from the API server keeps running.
+const DefaultListenerNodeFailoverTimeout = 2 * time.Minute
+
 // AutoscalingListenerReconciler reconciles a AutoscalingListener object
 type AutoscalingListenerReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
 
+	// ListenerAuditLog enables the audit log of the API calls of the listeners.
+	ListenerAuditLog bool
+	// ControllerNamespace, when set, is the only namespace AutoscalingListeners are reconciled in.
+	// Listeners created elsewhere could otherwise mirror the GitHub config secret of any namespace into theirs.
+	ControllerNamespace string
+	// ListenerNodeFailoverTimeout is how long the node of a listener pod can be not ready before the pod
+	// is force deleted, instead of waiting minutes for the pod to be evicted. Zero disables the failover.
+	ListenerNodeFailoverTimeout time.Duration
+	// ListenerLogLevel and ListenerLogFormat set the logging of the listeners. The listeners log at the debug level
+	// in the text format when they are empty.
+	ListenerLogLevel  string
+	ListenerLogFormat string
+	// DefaultGitHubServerTLS is mounted into the listener pods of the AutoscalingRunnerSets without GitHubServerTLS.
+	DefaultGitHubServerTLS *DefaultGitHubServerTLS
+	// DefaultSecurityContext is merged into the listener pods, unless the listener template overrides it.
+	DefaultSecurityContext *DefaultSecurityContext
+	// Config, when set, overrides DefaultSecurityContext with the settings of the controller config map.
+	Config *ControllerConfig
+
 	resourceBuilder resourceBuilder
 }
 
@@ -62,6 +91,8 @@ type AutoscalingListenerReconciler struct {
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners/finalizers,verbs=update
+// +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 
 // Reconcile a AutoscalingListener resource to meet its desired spec.
 func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -72,6 +103,11 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if r.ControllerNamespace != "" && autoscalingListener.Namespace != r.ControllerNamespace {
+		log.Info("Ignoring AutoscalingListener outside of the controller namespace", "controllerNamespace", r.ControllerNamespace)
+		return ctrl.Result{}, nil
+	}
+
 	if !autoscalingListener.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !controllerutil.ContainsFinalizer(autoscalingListener, autoscalingListenerFinalizerName) {
 			return ctrl.Result{}, nil
@@ -122,6 +158,14 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 		return ctrl.Result{}, err
 	}
 
+	if autoscalingListener.Spec.GitHubConfigSecret != autoscalingRunnerSet.Spec.GitHubConfigSecret {
+		// The AutoscalingRunnerSet controller updates the listener with the secret of the runner set.
+		log.Info("GitHub config secret of the listener doesn't match its AutoscalingRunnerSet, waiting for the listener to be updated",
+			"secret", autoscalingListener.Spec.GitHubConfigSecret,
+			"autoscalingRunnerSetSecret", autoscalingRunnerSet.Spec.GitHubConfigSecret)
+		return ctrl.Result{}, nil
+	}
+
 	// Check if the GitHub config secret exists
 	secret := new(corev1.Secret)
 	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingListener.Spec.AutoscalingRunnerSetNamespace, Name: autoscalingListener.Spec.GitHubConfigSecret}, secret); err != nil {
@@ -152,6 +196,13 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 		return r.updateSecretsForListener(ctx, secret, mirrorSecret, log)
 	}
 
+	// Copy the image pull secrets of the AutoscalingRunnerSet for the listener pod
+	imagePullSecrets, err := r.reconcileImagePullSecretMirrors(ctx, &autoscalingRunnerSet, autoscalingListener, log)
+	if err != nil {
+		log.Error(err, "Unable to copy the image pull secrets for the listener pod")
+		return ctrl.Result{}, err
+	}
+
 	// Make sure the runner scale set listener service account is created for the listener pod in the controller namespace
 	serviceAccount := new(corev1.ServiceAccount)
 	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingListener.Namespace, Name: scaleSetListenerServiceAccountName(autoscalingListener)}, serviceAccount); err != nil {
@@ -213,7 +264,7 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 
 		// Create a listener pod in the controller namespace
 		log.Info("Creating a listener pod")
-		return r.createListenerPod(ctx, &autoscalingRunnerSet, autoscalingListener, serviceAccount, mirrorSecret, log)
+		return r.createListenerPod(ctx, &autoscalingRunnerSet, autoscalingListener, serviceAccount, mirrorSecret, imagePullSecrets, log)
 	}
 
 	// The listener pod failed might mean the mirror secret is out of date
@@ -226,9 +277,111 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 		}
 	}
 
+	if restarted, err := r.restartListenerPodOnSecretChange(ctx, listenerPod, mirrorSecret, log); err != nil || restarted {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.updateCircuitBreakerStatus(ctx, autoscalingListener, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.failOverListenerPod(ctx, listenerPod, log)
+}
+
+// restartListenerPodOnSecretChange deletes the listener pod when it was created with another version of the
+// GitHub config secret. The listener reads the secret on start, e.g. a replaced PAT is only used by a new pod.
+func (r *AutoscalingListenerReconciler) restartListenerPodOnSecretChange(ctx context.Context, listenerPod *corev1.Pod, mirrorSecret *corev1.Secret, log logr.Logger) (bool, error) {
+	if !listenerPod.DeletionTimestamp.IsZero() || listenerPod.Annotations[AnnotationKeyGitHubConfigSecretHash] == mirrorSecret.Labels["secret-data-hash"] {
+		return false, nil
+	}
+
+	log.Info("GitHub config secret changed, deleting the listener pod so that it is re-created", "namespace", listenerPod.Namespace, "name", listenerPod.Name)
+	if err := r.Delete(ctx, listenerPod); err != nil && !kerrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to delete the listener pod: %v", err)
+	}
+	return true, nil
+}
+
+// failOverListenerPod force deletes the listener pod once its node has been not ready for longer
+// than ListenerNodeFailoverTimeout, so that it is re-created on another node.
+// The pod of a node that stopped reporting is otherwise only evicted after minutes, without scaling meanwhile.
+func (r *AutoscalingListenerReconciler) failOverListenerPod(ctx context.Context, listenerPod *corev1.Pod, log logr.Logger) (ctrl.Result, error) {
+	if r.ListenerNodeFailoverTimeout <= 0 || listenerPod.Spec.NodeName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	node := new(corev1.Node)
+	notReadyFor := time.Duration(0)
+	if err := r.Get(ctx, types.NamespacedName{Name: listenerPod.Spec.NodeName}, node); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to get node of the listener pod: %v", err)
+		}
+		// The node is gone, so is the listener.
+		notReadyFor = r.ListenerNodeFailoverTimeout
+	} else if since, ok := nodeNotReadySince(node); ok {
+		notReadyFor = time.Since(since)
+	} else {
+		return ctrl.Result{}, nil
+	}
+
+	if remaining := r.ListenerNodeFailoverTimeout - notReadyFor; remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log.Info("Node of the listener pod is not ready, force deleting the pod to re-create it on another node", "node", listenerPod.Spec.NodeName, "notReadyFor", notReadyFor)
+	if err := r.Delete(ctx, listenerPod, client.GracePeriodSeconds(0)); err != nil && !kerrors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to force delete the listener pod: %v", err)
+	}
 	return ctrl.Result{}, nil
 }
 
+// nodeNotReadySince returns when the node stopped being ready, and false when it is ready.
+func nodeNotReadySince(node *corev1.Node) (time.Time, bool) {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			if condition.Status == corev1.ConditionTrue {
+				return time.Time{}, false
+			}
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// updateCircuitBreakerStatus copies the circuit breaker the listener recorded on its EphemeralRunnerSet
+// into the AutoscalingListener status.
+func (r *AutoscalingListenerReconciler) updateCircuitBreakerStatus(ctx context.Context, autoscalingListener *v1alpha1.AutoscalingListener, logger logr.Logger) error {
+	ephemeralRunnerSet := new(v1alpha1.EphemeralRunnerSet)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingListener.Spec.AutoscalingRunnerSetNamespace, Name: autoscalingListener.Spec.EphemeralRunnerSetName}, ephemeralRunnerSet); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	raw, ok := ephemeralRunnerSet.Annotations[v1alpha1.AnnotationKeyListenerCircuitBreaker]
+	if !ok {
+		return nil
+	}
+
+	circuitBreaker := new(v1alpha1.ListenerCircuitBreakerStatus)
+	if err := json.Unmarshal([]byte(raw), circuitBreaker); err != nil {
+		logger.Error(err, "Ignoring invalid circuit breaker annotation", "annotation", raw)
+		return nil
+	}
+
+	if reflect.DeepEqual(autoscalingListener.Status.CircuitBreaker, circuitBreaker) {
+		return nil
+	}
+
+	logger.Info("Updating the circuit breaker status", "state", circuitBreaker.State, "consecutiveFailures", circuitBreaker.ConsecutiveFailures)
+	if err := patchSubResource(ctx, r.Status(), autoscalingListener, func(obj *v1alpha1.AutoscalingListener) {
+		obj.Status.CircuitBreaker = circuitBreaker
+	}); err != nil {
+		logger.Error(err, "Failed to update the circuit breaker status")
+		return err
+	}
+
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AutoscalingListenerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	groupVersionIndexer := func(rawObj client.Object) []string {
@@ -278,13 +431,80 @@ func (r *AutoscalingListenerReconciler) SetupWithManager(mgr ctrl.Manager) error
 		return requests
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	// The listener records its circuit breaker on the EphemeralRunnerSet it scales.
+	ephemeralRunnerSetWatchFunc := func(obj client.Object) []reconcile.Request {
+		var listeners v1alpha1.AutoscalingListenerList
+		if err := mgr.GetClient().List(context.Background(), &listeners); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, listener := range listeners.Items {
+			if listener.Spec.AutoscalingRunnerSetNamespace == obj.GetNamespace() && listener.Spec.EphemeralRunnerSetName == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: listener.Namespace, Name: listener.Name}})
+			}
+		}
+		return requests
+	}
+
+	// Listeners mirror the GitHub config secret of their AutoscalingRunnerSet, and follow its changes.
+	gitHubConfigSecretWatchFunc := func(obj client.Object) []reconcile.Request {
+		var listeners v1alpha1.AutoscalingListenerList
+		if err := mgr.GetClient().List(context.Background(), &listeners); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, listener := range listeners.Items {
+			if listener.Spec.AutoscalingRunnerSetNamespace == obj.GetNamespace() && listener.Spec.GitHubConfigSecret == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: listener.Namespace, Name: listener.Name}})
+			}
+		}
+		return requests
+	}
+
+	// Listener pods are failed over as soon as their node is not ready.
+	nodeWatchFunc := func(obj client.Object) []reconcile.Request {
+		var listeners v1alpha1.AutoscalingListenerList
+		if err := mgr.GetClient().List(context.Background(), &listeners); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, listener := range listeners.Items {
+			pod := new(corev1.Pod)
+			if err := mgr.GetClient().Get(context.Background(), types.NamespacedName{Namespace: listener.Namespace, Name: listener.Name}, pod); err != nil {
+				continue
+			}
+			if pod.Spec.NodeName == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: listener.Namespace, Name: listener.Name}})
+			}
+		}
+		return requests
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.AutoscalingListener{}).
 		Owns(&corev1.Pod{}).
 		Owns(&corev1.ServiceAccount{}).
 		Owns(&corev1.Secret{}).
 		Watches(&source.Kind{Type: &rbacv1.Role{}}, handler.EnqueueRequestsFromMapFunc(labelBasedWatchFunc)).
 		Watches(&source.Kind{Type: &rbacv1.RoleBinding{}}, handler.EnqueueRequestsFromMapFunc(labelBasedWatchFunc)).
+		Watches(&source.Kind{Type: &v1alpha1.EphemeralRunnerSet{}}, handler.EnqueueRequestsFromMapFunc(ephemeralRunnerSetWatchFunc)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(gitHubConfigSecretWatchFunc))
+
+	if r.ListenerNodeFailoverTimeout > 0 {
+		b = b.Watches(
+			&source.Kind{Type: &corev1.Node{}},
+			handler.EnqueueRequestsFromMapFunc(nodeWatchFunc),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				_, notReady := nodeNotReadySince(obj.(*corev1.Node))
+				return notReady
+			})),
+		)
+	}
+
+	return b.
 		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
 		Complete(r)
 }
@@ -344,8 +564,20 @@ func (r *AutoscalingListenerReconciler) createServiceAccountForListener(ctx cont
 	return ctrl.Result{}, nil
 }
 
-func (r *AutoscalingListenerReconciler) createListenerPod(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, serviceAccount *corev1.ServiceAccount, secret *corev1.Secret, logger logr.Logger) (ctrl.Result, error) {
+func (r *AutoscalingListenerReconciler) createListenerPod(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, serviceAccount *corev1.ServiceAccount, secret *corev1.Secret, imagePullSecrets []corev1.LocalObjectReference, logger logr.Logger) (ctrl.Result, error) {
 	newPod := r.resourceBuilder.newScaleSetListenerPod(autoscalingListener, serviceAccount, secret)
+	newPod.Spec.ImagePullSecrets = appendImagePullSecrets(newPod.Spec.ImagePullSecrets, imagePullSecrets...)
+	if r.ListenerAuditLog {
+		newPod.Spec.Containers[0].Env = append(newPod.Spec.Containers[0].Env, corev1.EnvVar{Name: "GITHUB_AUDIT_LOG", Value: "true"})
+	}
+	r.applyDefaultGitHubServerTLSToListener(autoscalingRunnerSet, newPod)
+	applyDefaultSecurityContext(newPod, r.Config.defaultSecurityContext(r.DefaultSecurityContext))
+	if r.ListenerLogLevel != "" {
+		newPod.Spec.Containers[0].Env = append(newPod.Spec.Containers[0].Env, corev1.EnvVar{Name: "GITHUB_LOG_LEVEL", Value: r.ListenerLogLevel})
+	}
+	if r.ListenerLogFormat != "" {
+		newPod.Spec.Containers[0].Env = append(newPod.Spec.Containers[0].Env, corev1.EnvVar{Name: "GITHUB_LOG_FORMAT", Value: r.ListenerLogFormat})
+	}
 
 	if err := ctrl.SetControllerReference(autoscalingListener, newPod, r.Scheme); err != nil {
 		return ctrl.Result{}, err
@@ -3,12 +3,20 @@ package actionsgithubcom
 import (
 	"context"
 	"fmt"
+	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -391,3 +399,108 @@ var _ = Describe("Test AutoScalingListener controller", func() {
 		})
 	})
 })
+
+func TestFailOverListenerPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	newNode := func(name string, ready corev1.ConditionStatus, since time.Time) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: ready, LastTransitionTime: metav1.NewTime(since)},
+				},
+			},
+		}
+	}
+	newPod := func(name, nodeName string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "arc-systems"},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+
+	now := time.Now()
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			newNode("ready", corev1.ConditionTrue, now.Add(-time.Hour)),
+			newNode("lost", corev1.ConditionUnknown, now.Add(-time.Minute)),
+			newNode("flapping", corev1.ConditionFalse, now.Add(-10*time.Second)),
+			newPod("on-ready", "ready"),
+			newPod("on-lost", "lost"),
+			newPod("on-flapping", "flapping"),
+			newPod("on-deleted", "deleted"),
+		).
+		Build()
+
+	r := &AutoscalingListenerReconciler{
+		Client:                      c,
+		Log:                         logr.Discard(),
+		ListenerNodeFailoverTimeout: 30 * time.Second,
+	}
+
+	exists := func(name string) bool {
+		err := c.Get(context.Background(), client.ObjectKey{Namespace: "arc-systems", Name: name}, new(corev1.Pod))
+		return err == nil
+	}
+
+	for _, name := range []string{"on-ready", "on-lost", "on-flapping", "on-deleted"} {
+		pod := new(corev1.Pod)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "arc-systems", Name: name}, pod))
+		result, err := r.failOverListenerPod(context.Background(), pod, logr.Discard())
+		require.NoError(t, err)
+
+		switch name {
+		case "on-flapping":
+			assert.True(t, result.RequeueAfter > 0 && result.RequeueAfter <= 20*time.Second, "The pod is checked again once the timeout expires")
+		default:
+			assert.Zero(t, result.RequeueAfter)
+		}
+	}
+
+	assert.True(t, exists("on-ready"))
+	assert.False(t, exists("on-lost"), "Pods of nodes not ready for longer than the timeout are deleted")
+	assert.True(t, exists("on-flapping"))
+	assert.False(t, exists("on-deleted"), "Pods of deleted nodes are deleted")
+}
+
+func TestRestartListenerPodOnSecretChange(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	mirrorSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "listener-secret",
+			Namespace: "arc-systems",
+			Labels:    map[string]string{"secret-data-hash": "new"},
+		},
+	}
+	newPod := func(name, secretHash string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "arc-systems",
+				Annotations: map[string]string{AnnotationKeyGitHubConfigSecretHash: secretHash},
+			},
+		}
+	}
+
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(newPod("up-to-date", "new"), newPod("outdated", "old")).
+		Build()
+	r := &AutoscalingListenerReconciler{Client: c, Log: logr.Discard()}
+
+	for name, wantRestarted := range map[string]bool{"up-to-date": false, "outdated": true} {
+		pod := new(corev1.Pod)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "arc-systems", Name: name}, pod))
+		restarted, err := r.restartListenerPodOnSecretChange(context.Background(), pod, mirrorSecret, logr.Discard())
+		require.NoError(t, err)
+		assert.Equal(t, wantRestarted, restarted, name)
+
+		err = c.Get(context.Background(), client.ObjectKey{Namespace: "arc-systems", Name: name}, new(corev1.Pod))
+		assert.Equal(t, wantRestarted, kerrors.IsNotFound(err), name)
+	}
+}
@@ -18,19 +18,32 @@ package actionsgithubcom
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	psadmission "k8s.io/pod-security-admission/admission/api"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -47,8 +60,13 @@ const (
 	LabelKeyRunnerSpecHash            = "runner-spec-hash"
 	LabelKeyAutoScaleRunnerSetName    = "auto-scale-runner-set-name"
 	autoscalingRunnerSetFinalizerName = "autoscalingrunnerset.actions.github.com/finalizer"
-	runnerScaleSetIdKey               = "runner-scale-set-id"
-	runnerScaleSetRunnerGroupNameKey  = "runner-scale-set-runner-group-name"
+	runnerScaleSetIdKey               = v1alpha1.AnnotationKeyRunnerScaleSetId
+	runnerScaleSetRunnerGroupNameKey  = v1alpha1.AnnotationKeyRunnerScaleSetRunnerGroupName
+	runnerScaleSetNameKey             = v1alpha1.AnnotationKeyRunnerScaleSetName
+	runnerScaleSetLabelsKey           = v1alpha1.AnnotationKeyRunnerScaleSetLabels
+	defaultRollingUpdateMaxPercent    = "25%"
+
+	unsupportedServerVersionRequeueInterval = 10 * time.Minute
 
 	// scaleSetListenerLabel is the key of pod.meta.labels to label
 	// that the pod is a listener application
@@ -64,8 +82,57 @@ type AutoscalingRunnerSetReconciler struct {
 	DefaultRunnerScaleSetListenerImage            string
 	DefaultRunnerScaleSetListenerImagePullSecrets []string
 	ActionsClient                                 actions.MultiClient
+	// GitHubErrors, when set, is notified of the errors returned by the Actions service.
+	GitHubErrors GitHubErrorRecorder
+	Recorder     record.EventRecorder
+	// RemoteCleanupTimeout is how long a deleted AutoscalingRunnerSet retries deleting its runner scale set
+	// from the Actions service before leaving it there. Zero retries forever.
+	RemoteCleanupTimeout time.Duration
+	// RunnerVersionCheckInterval is how often the latest runner version is fetched for the
+	// AutoscalingRunnerSets tracking their runner version. Defaults to DefaultRunnerVersionCheckInterval.
+	RunnerVersionCheckInterval time.Duration
+	// Config, when set, overrides the listener image, the runner version check interval and
+	// the remote cleanup timeout above with the settings of the controller config map.
+	Config *ControllerConfig
+	// DefaultGitHubServerTLS is the CA bundle of the GitHub server of the AutoscalingRunnerSets without GitHubServerTLS.
+	// It is copied to their namespace for their runner pods.
+	DefaultGitHubServerTLS *DefaultGitHubServerTLS
+	// IsolateNamespaces rejects listener templates setting more than the placement and the resources of the
+	// listener pods, which run in the controller namespace shared by the AutoscalingRunnerSets of all namespaces.
+	IsolateNamespaces bool
+	// ImageDigestResolver resolves the runner images of the AutoscalingRunnerSets pinning them to a digest.
+	// Defaults to querying the registries.
+	ImageDigestResolver ImageDigestResolver
+	// ImageSignatureVerifier verifies the cosign signatures of the runner images of the AutoscalingRunnerSets
+	// verifying them. Defaults to querying the registries.
+	ImageSignatureVerifier ImageSignatureVerifier
+	// EgressResolver resolves the addresses of the GitHub servers and proxies allowed by the runner network policies.
+	// Defaults to the meta API of GitHub.com and DNS.
+	EgressResolver EgressResolver
+	// DefaultSecurityContext is the security context merged into the runner pods, checked with them against the
+	// Pod Security level of their namespace.
+	DefaultSecurityContext *DefaultSecurityContext
+	// PodSecurityAdmissionConfig holds the defaults and exemptions of the Pod Security admission of the cluster,
+	// applied with the Pod Security labels of the namespaces. Without it, namespaces without labels are privileged.
+	PodSecurityAdmissionConfig *psadmission.PodSecurityConfiguration
+
+	// APIReader lists the pods of all namespaces for the AutoscalingRunnerSets capping their runners to the
+	// cluster capacity, and gets their namespaces, without caching them. Defaults to the client.
+	APIReader client.Reader
 
 	resourceBuilder resourceBuilder
+
+	lastClusterCapacityCheckMu sync.Mutex
+	lastClusterCapacityCheck   map[types.NamespacedName]time.Time
+
+	lastRunnerVersionCheckMu sync.Mutex
+	lastRunnerVersionCheck   map[types.NamespacedName]time.Time
+
+	lastRunnerImageDigestResolutionMu sync.Mutex
+	lastRunnerImageDigestResolution   map[types.NamespacedName]runnerImageDigestResolution
+
+	lastRunnerEgressResolutionMu sync.Mutex
+	lastRunnerEgressResolution   map[types.NamespacedName]runnerEgressResolution
 }
 
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets,verbs=get;list;watch;create;update;patch;delete
@@ -75,6 +142,19 @@ type AutoscalingRunnerSetReconciler struct {
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;delete
+// The controller holds the permissions of the kubernetes container mode role to be allowed to grant them.
+// +kubebuilder:rbac:groups=core,resources=pods/exec,verbs=get;create
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;create;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get
 
 // Reconcile a AutoscalingRunnerSet resource to meet its desired spec.
 func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -144,24 +224,147 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, nil
 	}
 
+	if err := validateRunnerTemplates(autoscalingRunnerSet); err != nil {
+		// Requeueing doesn't help, the autoscaling runner set is reconciled again once it is updated.
+		log.Error(err, "Invalid runner pod template")
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateMaintenanceWindows(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid maintenance window")
+		return ctrl.Result{}, nil
+	}
+
+	if err := validatePersistentRunners(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid persistent runners")
+		if err := r.reportInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonPersistentRunners, err); err != nil {
+			log.Error(err, "Failed to report the invalid persistent runners")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.clearInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonPersistentRunners); err != nil {
+		log.Error(err, "Failed to clear the invalid persistent runners")
+		return ctrl.Result{}, err
+	}
+
+	if err := validateInitContainers(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid init containers")
+		if err := r.reportInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonInitContainers, err); err != nil {
+			log.Error(err, "Failed to report the invalid init containers")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.clearInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonInitContainers); err != nil {
+		log.Error(err, "Failed to clear the invalid init containers")
+		return ctrl.Result{}, err
+	}
+
+	if err := validateJobResources(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid job resources")
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateRunnerNetworkPolicy(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid runner network policy")
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateRunnerServiceAccount(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid runner service account")
+		return ctrl.Result{}, nil
+	}
+
+	if _, err := imageSignaturePolicy(autoscalingRunnerSet); err != nil {
+		log.Error(err, "Invalid image signature verification")
+		if err := r.reportImageSignatureUnverified(ctx, autoscalingRunnerSet, v1alpha1.ImageSignatureReasonInvalidPolicy, fmt.Sprintf("Invalid image signature verification: %v", err)); err != nil {
+			log.Error(err, "Failed to report the invalid image signature verification")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.clearInvalidImageSignaturePolicy(ctx, autoscalingRunnerSet); err != nil {
+		log.Error(err, "Failed to clear the invalid image signature verification")
+		return ctrl.Result{}, err
+	}
+
+	if r.IsolateNamespaces {
+		if err := validateListenerTemplate(autoscalingRunnerSet.Spec.ListenerTemplate); err != nil {
+			log.Error(err, "Invalid listener pod template, listeners can only be placed and sized when namespaces are isolated")
+			if err := r.reportInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonListenerTemplate, err); err != nil {
+				log.Error(err, "Failed to report the invalid listener pod template")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+	if err := r.clearInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonListenerTemplate); err != nil {
+		log.Error(err, "Failed to clear the invalid listener pod template")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileKubernetesModeRBAC(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile kubernetes container mode RBAC")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRunnerServiceAccount(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile runner service account")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileContainerHookTemplate(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile the generated hook template")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRunnerNetworkPolicy(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile runner network policy")
+		return ctrl.Result{}, err
+	}
+
+	overflowSources, err := r.overflowSources(ctx, autoscalingRunnerSet)
+	if err != nil {
+		log.Error(err, "Failed to list the autoscaling runner sets overflowing into this one")
+		return ctrl.Result{}, err
+	}
+
 	scaleSetIdRaw, ok := autoscalingRunnerSet.Annotations[runnerScaleSetIdKey]
 	if !ok {
 		// Need to create a new runner scale set on Actions service
 		log.Info("Runner scale set id annotation does not exist. Creating a new runner scale set.")
-		return r.createRunnerScaleSet(ctx, autoscalingRunnerSet, log)
+		return r.createRunnerScaleSet(ctx, autoscalingRunnerSet, overflowSources, log)
 	}
 
 	if id, err := strconv.Atoi(scaleSetIdRaw); err != nil || id <= 0 {
 		log.Info("Runner scale set id annotation is not an id, or is <= 0. Creating a new runner scale set.")
 		// something modified the scaleSetId. Try to create one
-		return r.createRunnerScaleSet(ctx, autoscalingRunnerSet, log)
+		return r.createRunnerScaleSet(ctx, autoscalingRunnerSet, overflowSources, log)
 	}
 
 	// Make sure the runner group of the scale set is up to date
 	currentRunnerGroupName, ok := autoscalingRunnerSet.Annotations[runnerScaleSetRunnerGroupNameKey]
 	if !ok || (len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 && !strings.EqualFold(currentRunnerGroupName, autoscalingRunnerSet.Spec.RunnerGroup)) {
 		log.Info("AutoScalingRunnerSet runner group changed. Updating the runner scale set.")
-		return r.updateRunnerScaleSetRunnerGroup(ctx, autoscalingRunnerSet, log)
+		return r.updateRunnerScaleSet(ctx, autoscalingRunnerSet, overflowSources, log)
+	}
+
+	// Make sure the name of the scale set is up to date.
+	// Scale sets created before the name was tracked are named after the autoscaling runner set.
+	currentRunnerScaleSetName, ok := autoscalingRunnerSet.Annotations[runnerScaleSetNameKey]
+	if !ok {
+		currentRunnerScaleSetName = autoscalingRunnerSet.Name
+	}
+	if currentRunnerScaleSetName != autoscalingRunnerSet.RunnerScaleSetName() {
+		log.Info("AutoScalingRunnerSet runner scale set name changed. Renaming the runner scale set.", "from", currentRunnerScaleSetName, "to", autoscalingRunnerSet.RunnerScaleSetName())
+		return r.updateRunnerScaleSet(ctx, autoscalingRunnerSet, overflowSources, log)
+	}
+
+	// Make sure the labels of the scale set are up to date, e.g. after a template variant changed.
+	if labels := runnerScaleSetLabelsAnnotation(runnerScaleSetLabels(autoscalingRunnerSet, overflowSources)); autoscalingRunnerSet.Annotations[runnerScaleSetLabelsKey] != labels {
+		log.Info("AutoScalingRunnerSet runner scale set labels changed. Updating the runner scale set.", "from", autoscalingRunnerSet.Annotations[runnerScaleSetLabelsKey], "to", labels)
+		return r.updateRunnerScaleSet(ctx, autoscalingRunnerSet, overflowSources, log)
 	}
 
 	secret := new(corev1.Secret)
@@ -172,6 +375,17 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, err
 	}
 
+	if err := r.reconcileGitHubServerTLSMirror(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to copy the default GitHub server TLS config map")
+		return ctrl.Result{}, err
+	}
+
+	// The digest is resolved before comparing the runner sets, so that a new runner image rolls out once.
+	if err := r.reconcileRunnerImageDigest(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile runner image digest")
+		return ctrl.Result{}, err
+	}
+
 	existingRunnerSets, err := r.listEphemeralRunnerSets(ctx, autoscalingRunnerSet)
 	if err != nil {
 		log.Error(err, "Failed to list existing ephemeral runner sets")
@@ -181,7 +395,7 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 	latestRunnerSet := existingRunnerSets.latest()
 	if latestRunnerSet == nil {
 		log.Info("Latest runner set does not exist. Creating a new runner set.")
-		return r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, log)
+		return r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, false, log)
 	}
 
 	desiredSpecHash := autoscalingRunnerSet.RunnerSetSpecHash()
@@ -189,13 +403,44 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		log.Info("Find existing ephemeral runner set", "name", runnerSet.Name, "specHash", runnerSet.Labels[LabelKeyRunnerSpecHash])
 	}
 
+	rolledBack := canaryRolledBack(autoscalingRunnerSet, desiredSpecHash) || rolloutRolledBack(autoscalingRunnerSet, desiredSpecHash)
 	if desiredSpecHash != latestRunnerSet.Labels[LabelKeyRunnerSpecHash] {
-		log.Info("Latest runner set spec hash does not match the current autoscaling runner set. Creating a new runner set")
-		return r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, log)
+		if !rolledBack {
+			if err := r.clearRollback(ctx, autoscalingRunnerSet); err != nil {
+				log.Error(err, "Failed to clear the rolled back runner spec")
+				return ctrl.Result{}, err
+			}
+			log.Info("Latest runner set spec hash does not match the current autoscaling runner set. Creating a new runner set")
+			return r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, rollingUpdateStrategy(autoscalingRunnerSet) != nil || canaryStrategy(autoscalingRunnerSet) != nil, log)
+		}
+		log.Info("The runner spec was rolled back. Keeping the latest runner set until the runner spec changes", "name", latestRunnerSet.Name)
+	} else if rolledBack {
+		log.Info("Waiting for the rolled back runner set to be deleted", "name", latestRunnerSet.Name)
+		return ctrl.Result{}, nil
+	} else if err := r.clearRunnerPodSecurityViolation(ctx, autoscalingRunnerSet); err != nil {
+		// The runner spec was reverted to the one of the latest runner set after a violation.
+		log.Error(err, "Failed to clear the pod security violation")
+		return ctrl.Result{}, err
+	} else if err := r.clearImageSignatureUnverified(ctx, autoscalingRunnerSet); err != nil {
+		log.Error(err, "Failed to clear the unverified image signatures")
+		return ctrl.Result{}, err
+	}
+
+	// Refreshing the idle runners for a new runner release rolls out a new runner set, as a spec change does.
+	if !rolledBack && runnerRefreshNeeded(autoscalingRunnerSet, latestRunnerSet) {
+		log.Info("A newer runner release is available. Creating a new runner set to refresh the idle runners", "version", runnerRefreshVersion(autoscalingRunnerSet))
+		return r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, rollingUpdateStrategy(autoscalingRunnerSet) != nil || canaryStrategy(autoscalingRunnerSet) != nil, log)
 	}
 
 	oldRunnerSets := existingRunnerSets.old()
-	if len(oldRunnerSets) > 0 {
+	rollingUpdate := rollingUpdateStrategy(autoscalingRunnerSet)
+	canary := canaryStrategy(autoscalingRunnerSet)
+	if rolledBack {
+		// The latest runner set is the one the runner spec was rolled back to, and replaces the older ones.
+		canary = nil
+	}
+	blueGreen := blueGreenStrategy(autoscalingRunnerSet)
+	if len(oldRunnerSets) > 0 && rollingUpdate == nil && canary == nil && blueGreen == nil {
 		log.Info("Cleanup old ephemeral runner sets", "count", len(oldRunnerSets))
 		err := r.deleteEphemeralRunnerSets(ctx, oldRunnerSets, log)
 		if err != nil {
@@ -204,20 +449,44 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		}
 	}
 
+	// The listener doesn't run during a maintenance window, so that no new job is acquired.
+	now := time.Now()
+	window, windowChange := activeMaintenanceWindow(autoscalingRunnerSet, now)
+	if window != nil {
+		if err := r.reconcileMaintenanceWindow(ctx, autoscalingRunnerSet, window, windowChange, []*v1alpha1.EphemeralRunnerSet{latestRunnerSet}, log); err != nil {
+			log.Error(err, "Failed to reconcile the maintenance window")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: windowChange.Sub(now)}, nil
+	}
+	if autoscalingRunnerSet.Status.MaintenanceWindowEnd != nil {
+		log.Info("The maintenance window ended. Resuming the runner scale set")
+		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.MaintenanceWindowEnd = nil
+		}); err != nil {
+			log.Error(err, "Failed to clear the maintenance window end")
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Make sure the AutoscalingListener is up and running in the controller namespace
 	listener := new(v1alpha1.AutoscalingListener)
 	if err := r.Get(ctx, client.ObjectKey{Namespace: r.ControllerNamespace, Name: scaleSetListenerName(autoscalingRunnerSet)}, listener); err != nil {
 		if kerrors.IsNotFound(err) {
 			// We don't have a listener
 			log.Info("Creating a new AutoscalingListener for the runner set", "ephemeralRunnerSetName", latestRunnerSet.Name)
-			return r.createAutoScalingListenerForRunnerSet(ctx, autoscalingRunnerSet, latestRunnerSet, log)
+			return r.createAutoScalingListenerForRunnerSet(ctx, autoscalingRunnerSet, latestRunnerSet, overflowSources, log)
 		}
 		log.Error(err, "Failed to get AutoscalingListener resource")
 		return ctrl.Result{}, err
 	}
 
 	// Our listener pod is out of date, so we need to delete it to get a new recreate.
-	if listener.Labels[LabelKeyRunnerSpecHash] != autoscalingRunnerSet.ListenerSpecHash() {
+	if listener.Labels[LabelKeyRunnerSpecHash] != autoscalingRunnerSet.ListenerSpecHash() ||
+		listener.Spec.EphemeralRunnerSetName != latestRunnerSet.Name ||
+		listener.Spec.HoldJobsAboveMaxRunners != (autoscalingRunnerSet.Spec.OverflowTarget != "") ||
+		!reflect.DeepEqual(listener.Spec.OverflowSourceLabels, overflowSources) ||
+		listener.Spec.Image != r.listenerImage(autoscalingRunnerSet) {
 		log.Info("RunnerScaleSetListener is out of date. Deleting it so that it is recreated", "name", listener.Name)
 		if err := r.Delete(ctx, listener); err != nil {
 			if kerrors.IsNotFound(err) {
@@ -231,17 +500,227 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, nil
 	}
 
+	// A new runner set whose runners don't get running is rolled back while an old one can still serve the jobs.
+	var progressCheck time.Duration
+	if deadline := progressDeadline(autoscalingRunnerSet); deadline > 0 && !rolledBack && hasServingRunnerSet(oldRunnerSets) {
+		stalled, recheck, err := r.rolloutStalled(ctx, latestRunnerSet, deadline, time.Now())
+		if err != nil {
+			log.Error(err, "Failed to check the progress of the latest runner set")
+			return ctrl.Result{}, err
+		}
+		if stalled {
+			if err := r.rollBackRollout(ctx, autoscalingRunnerSet, latestRunnerSet, deadline, log); err != nil {
+				log.Error(err, "Failed to roll back the latest runner set")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		progressCheck = recheck
+	}
+
+	// The listener now scales the latest runner set, so the old ones can be rolled over.
+	if len(oldRunnerSets) > 0 && rollingUpdate != nil {
+		if err := r.rollOutEphemeralRunnerSets(ctx, autoscalingRunnerSet, rollingUpdate, latestRunnerSet, oldRunnerSets, log); err != nil {
+			log.Error(err, "Failed to roll out ephemeral runner sets")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(oldRunnerSets) > 0 && blueGreen != nil {
+		if err := r.cutOverBlueGreen(ctx, autoscalingRunnerSet, blueGreen, latestRunnerSet, oldRunnerSets, log); err != nil {
+			log.Error(err, "Failed to cut over to the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(oldRunnerSets) > 0 && canary != nil {
+		if err := r.rollOutCanary(ctx, autoscalingRunnerSet, canary, latestRunnerSet, oldRunnerSets, log); err != nil {
+			log.Error(err, "Failed to roll out canary runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(oldRunnerSets) == 0 && latestRunnerSet.Spec.MaxReplicas != nil {
+		log.Info("Rolling update is complete. Removing the replica limit from the latest runner set", "name", latestRunnerSet.Name)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.MaxReplicas = nil
+		}); err != nil {
+			log.Error(err, "Failed to remove the replica limit from the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.reconcileImagePrePull(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile image pre-pull daemon set")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRunnerToolCache(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile runner tool cache")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRunnerVersion(ctx, autoscalingRunnerSet, log); err != nil {
+		log.Error(err, "Failed to reconcile runner version")
+		return ctrl.Result{}, err
+	}
+
+	if warmReplicas, limit := warmPoolSize(autoscalingRunnerSet), autoscalingRunnerSet.Spec.MaxRunners; latestRunnerSet.Spec.WarmReplicas != warmReplicas || !reflect.DeepEqual(latestRunnerSet.Spec.WarmReplicasLimit, limit) {
+		log.Info("Updating the warm pool of the latest runner set", "name", latestRunnerSet.Name, "warmReplicas", warmReplicas)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.WarmReplicas = warmReplicas
+			obj.Spec.WarmReplicasLimit = limit
+		}); err != nil {
+			log.Error(err, "Failed to update the warm pool of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if timeout, min := autoscalingRunnerSet.Spec.IdleRunnerTimeout, minRunners(autoscalingRunnerSet); !reflect.DeepEqual(latestRunnerSet.Spec.IdleReplicasTimeout, timeout) || latestRunnerSet.Spec.MinReplicas != min {
+		log.Info("Updating the idle runner timeout of the latest runner set", "name", latestRunnerSet.Name, "idleRunnerTimeout", timeout, "minReplicas", min)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.IdleReplicasTimeout = timeout
+			obj.Spec.MinReplicas = min
+		}); err != nil {
+			log.Error(err, "Failed to update the idle runner timeout of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if settings := listenerScaleSettings(autoscalingRunnerSet); !reflect.DeepEqual(latestRunnerSet.Spec.ListenerScaleSettings, settings) {
+		log.Info("Updating the listener scale settings of the latest runner set", "name", latestRunnerSet.Name, "minRunners", settings.MinRunners, "maxRunners", settings.MaxRunners)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.ListenerScaleSettings = settings
+		}); err != nil {
+			log.Error(err, "Failed to update the listener scale settings of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if settings := listenerScaleSettings(autoscalingRunnerSet); !listenerHasScaleSettings(listener, settings) {
+		log.Info("Updating the scale settings of the AutoscalingListener", "name", listener.Name)
+		if err := patch(ctx, r.Client, listener, func(obj *v1alpha1.AutoscalingListener) {
+			obj.Spec.MinRunners = settings.MinRunners
+			obj.Spec.MaxRunners = settings.MaxRunners
+			obj.Spec.JobAcquisitionBatchSize = settings.JobAcquisitionBatchSize
+			obj.Spec.ScalingBufferPercent = settings.ScalingBufferPercent
+			obj.Spec.ScalingSmoothingPercent = settings.ScalingSmoothingPercent
+			obj.Spec.MaxPendingRunners = settings.MaxPendingRunners
+		}); err != nil {
+			log.Error(err, "Failed to update the scale settings of the AutoscalingListener")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if policy := autoscalingRunnerSet.Spec.ScaleDownPolicy; latestRunnerSet.Spec.ScaleDownPolicy != policy {
+		log.Info("Updating the scale down policy of the latest runner set", "name", latestRunnerSet.Name, "scaleDownPolicy", policy)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.ScaleDownPolicy = policy
+		}); err != nil {
+			log.Error(err, "Failed to update the scale down policy of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if timeout := autoscalingRunnerSet.Spec.JobCompletionTimeout; !reflect.DeepEqual(latestRunnerSet.Spec.EphemeralRunnerSpec.JobCompletionTimeout, timeout) {
+		log.Info("Updating the job completion timeout of the latest runner set", "name", latestRunnerSet.Name, "jobCompletionTimeout", timeout)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.EphemeralRunnerSpec.JobCompletionTimeout = timeout
+		}); err != nil {
+			log.Error(err, "Failed to update the job completion timeout of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if policy := autoscalingRunnerSet.Spec.FailurePolicy; !reflect.DeepEqual(latestRunnerSet.Spec.EphemeralRunnerSpec.FailurePolicy, policy) {
+		log.Info("Updating the failure policy of the latest runner set", "name", latestRunnerSet.Name)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.EphemeralRunnerSpec.FailurePolicy = policy
+		}); err != nil {
+			log.Error(err, "Failed to update the failure policy of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	reserved, reservationExpiry := reservedRunners(autoscalingRunnerSet, now)
+	predicted, predictionCheck, err := r.reconcilePredictiveScaling(ctx, autoscalingRunnerSet, latestRunnerSet, now, log)
+	if err != nil {
+		log.Error(err, "Failed to reconcile predictive scaling")
+		return ctrl.Result{}, err
+	}
+	if predicted > reserved {
+		reserved = predicted
+	}
+	if latestRunnerSet.Spec.ReservedReplicas != reserved {
+		log.Info("Updating the reserved capacity of the latest runner set", "name", latestRunnerSet.Name, "reservedReplicas", reserved)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.ReservedReplicas = reserved
+		}); err != nil {
+			log.Error(err, "Failed to update the reserved capacity of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
+	capacityCheck, err := r.reconcileClusterCapacity(ctx, autoscalingRunnerSet, latestRunnerSet, log)
+	if err != nil {
+		log.Error(err, "Failed to reconcile the cluster capacity")
+		return ctrl.Result{}, err
+	}
+
 	// Update the status of autoscaling runner set.
-	if latestRunnerSet.Status.CurrentReplicas != autoscalingRunnerSet.Status.CurrentRunners {
+	registrationMethod := v1alpha1.RunnerRegistrationMethodJIT
+	if latestRunnerSet.Status.RegistrationTokenReplicas > 0 {
+		registrationMethod = v1alpha1.RunnerRegistrationMethodRegistrationToken
+	}
+	phases := sumRunnerPhases(existingRunnerSets.all())
+	if latestRunnerSet.Status.CurrentReplicas != autoscalingRunnerSet.Status.CurrentRunners ||
+		registrationMethod != autoscalingRunnerSet.Status.RegistrationMethod ||
+		phases != autoscalingRunnerSet.Status.RunnerPhases {
 		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
 			obj.Status.CurrentRunners = latestRunnerSet.Status.CurrentReplicas
+			obj.Status.RegistrationMethod = registrationMethod
+			obj.Status.RunnerPhases = phases
 		}); err != nil {
 			log.Error(err, "Failed to update autoscaling runner set status with current runner count")
 			return ctrl.Result{}, err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	var requeueAfter time.Duration
+	if tracking := autoscalingRunnerSet.Spec.RunnerVersionTracking; tracking != nil && tracking.Enabled {
+		requeueAfter = r.runnerVersionCheckInterval()
+	}
+	if pinning := autoscalingRunnerSet.Spec.RunnerImageDigestPinning; pinning != nil && pinning.Enabled {
+		if interval := runnerImageDigestRefreshInterval(autoscalingRunnerSet); requeueAfter == 0 || interval < requeueAfter {
+			requeueAfter = interval
+		}
+	}
+	// Resolve the addresses allowed by the runner network policy again.
+	if runnerNetworkPolicyEnabled(autoscalingRunnerSet) && (requeueAfter == 0 || DefaultRunnerNetworkPolicyRefreshInterval < requeueAfter) {
+		requeueAfter = DefaultRunnerNetworkPolicyRefreshInterval
+	}
+	// Release the reserved capacity once the next reservation expires.
+	if reservationExpiry > 0 && (requeueAfter == 0 || reservationExpiry < requeueAfter) {
+		requeueAfter = reservationExpiry
+	}
+	// Learn the demand of every hour.
+	if predictionCheck > 0 && (requeueAfter == 0 || predictionCheck < requeueAfter) {
+		requeueAfter = predictionCheck
+	}
+	// Follow the capacity of the cluster.
+	if capacityCheck > 0 && (requeueAfter == 0 || capacityCheck < requeueAfter) {
+		requeueAfter = capacityCheck
+	}
+	// Stop the listener once the next maintenance window starts.
+	if maintenanceCheck := windowChange.Sub(now); !windowChange.IsZero() && (requeueAfter == 0 || maintenanceCheck < requeueAfter) {
+		requeueAfter = maintenanceCheck
+	}
+	// Check the latest runner set again once its progress deadline passes.
+	if progressCheck > 0 && (requeueAfter == 0 || progressCheck < requeueAfter) {
+		requeueAfter = progressCheck
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *AutoscalingRunnerSetReconciler) cleanupListener(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (done bool, err error) {
@@ -276,6 +755,14 @@ func (r *AutoscalingRunnerSetReconciler) cleanupEphemeralRunnerSets(ctx context.
 		return true, nil
 	}
 
+	if forceDeleteRequested(autoscalingRunnerSet) {
+		for _, rs := range runnerSets.all() {
+			if err := forceDelete(ctx, r.Client, &rs); err != nil {
+				return false, fmt.Errorf("failed to force delete ephemeral runner set %q: %v", rs.Name, err)
+			}
+		}
+	}
+
 	logger.Info("Deleting all ephemeral runner sets", "count", runnerSets.count())
 	if err := r.deleteEphemeralRunnerSets(ctx, runnerSets.all(), logger); err != nil {
 		return false, fmt.Errorf("failed to delete ephemeral runner sets: %v", err)
@@ -300,42 +787,129 @@ func (r *AutoscalingRunnerSetReconciler) deleteEphemeralRunnerSets(ctx context.C
 	return nil
 }
 
-func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (ctrl.Result, error) {
+// rollOutEphemeralRunnerSets moves runners from the old runner sets to the latest one,
+// keeping the total and available runner counts within the rolling update bounds.
+// Old runner sets are deleted once all their runners are gone.
+func (r *AutoscalingRunnerSetReconciler) rollOutEphemeralRunnerSets(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, strategy *v1alpha1.RollingUpdateStrategy, latestRunnerSet *v1alpha1.EphemeralRunnerSet, oldRunnerSets []v1alpha1.EphemeralRunnerSet, logger logr.Logger) error {
+	desired := latestRunnerSet.Spec.Replicas
+	if autoscalingRunnerSet.Spec.MinRunners != nil && *autoscalingRunnerSet.Spec.MinRunners > desired {
+		desired = *autoscalingRunnerSet.Spec.MinRunners
+	}
+
+	oldCurrent := 0
+	var drained []v1alpha1.EphemeralRunnerSet
+	for _, rs := range oldRunnerSets {
+		if rs.Spec.MaxReplicas != nil && *rs.Spec.MaxReplicas == 0 && rs.Status.CurrentReplicas == 0 {
+			drained = append(drained, rs)
+			continue
+		}
+		oldCurrent += rs.Status.CurrentReplicas
+	}
+
+	if len(drained) > 0 {
+		logger.Info("Cleanup drained ephemeral runner sets", "count", len(drained))
+		if err := r.deleteEphemeralRunnerSets(ctx, drained, logger); err != nil {
+			return err
+		}
+	}
+
+	newMax, oldMax := rollingUpdateReplicas(strategy, desired, latestRunnerSet.Status.RunningReplicas, oldCurrent)
+	logger.Info("Rolling update progress",
+		"desired", desired,
+		"latestRunning", latestRunnerSet.Status.RunningReplicas,
+		"oldCurrent", oldCurrent,
+		"latestMaxReplicas", newMax,
+		"oldMaxReplicas", oldMax,
+	)
+
+	if latestRunnerSet.Spec.MaxReplicas == nil || *latestRunnerSet.Spec.MaxReplicas != newMax {
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.MaxReplicas = &newMax
+		}); err != nil {
+			return fmt.Errorf("failed to limit replicas of the latest runner set: %v", err)
+		}
+	}
+
+	// Old runner sets are sorted newest first, so the oldest ones are drained first.
+	for i := range oldRunnerSets {
+		rs := &oldRunnerSets[i]
+		if !rs.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		keep := rs.Status.CurrentReplicas
+		if keep > oldMax {
+			keep = oldMax
+		}
+		oldMax -= keep
+
+		if rs.Spec.MaxReplicas != nil && *rs.Spec.MaxReplicas == keep {
+			continue
+		}
+
+		logger.Info("Scaling down old ephemeral runner set", "name", rs.Name, "maxReplicas", keep)
+		if err := patch(ctx, r.Client, rs, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.MaxReplicas = &keep
+		}); err != nil {
+			return fmt.Errorf("failed to limit replicas of old runner set %s: %v", rs.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// followLatestRunnerSet scales an old runner set like the latest one, up to maxReplicas. The listener only
+// scales the latest runner set, so old runner sets that keep serving jobs during a rollout follow it.
+func (r *AutoscalingRunnerSetReconciler) followLatestRunnerSet(ctx context.Context, runnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet, maxReplicas *int) error {
+	if runnerSet.Spec.Replicas == latestRunnerSet.Spec.Replicas &&
+		reflect.DeepEqual(runnerSet.Spec.VariantReplicas, latestRunnerSet.Spec.VariantReplicas) &&
+		reflect.DeepEqual(runnerSet.Spec.MaxReplicas, maxReplicas) {
+		return nil
+	}
+	if err := patch(ctx, r.Client, runnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+		if obj.Spec.Replicas != latestRunnerSet.Spec.Replicas {
+			obj.Spec.Replicas = latestRunnerSet.Spec.Replicas
+			setScaleTrigger(obj, scaleTriggerOf(latestRunnerSet))
+		}
+		obj.Spec.VariantReplicas = latestRunnerSet.Spec.VariantReplicas
+		obj.Spec.MaxReplicas = maxReplicas
+	}); err != nil {
+		return fmt.Errorf("failed to scale old runner set %s: %v", runnerSet.Name, err)
+	}
+	return nil
+}
+
+func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, overflowSources []string, logger logr.Logger) (ctrl.Result, error) {
 	logger.Info("Creating a new runner scale set")
 	actionsClient, err := r.actionsClientFor(ctx, autoscalingRunnerSet)
 	if err != nil {
 		logger.Error(err, "Failed to initialize Actions service client for creating a new runner scale set")
 		return ctrl.Result{}, err
 	}
-	runnerScaleSet, err := actionsClient.GetRunnerScaleSet(ctx, autoscalingRunnerSet.Name)
+	runnerScaleSet, err := actionsClient.GetRunnerScaleSet(ctx, autoscalingRunnerSet.RunnerScaleSetName())
 	if err != nil {
+		var unsupportedErr *actions.UnsupportedServerVersionError
+		if errors.As(err, &unsupportedErr) {
+			return r.markUnsupportedServerVersion(ctx, autoscalingRunnerSet, unsupportedErr, logger)
+		}
 		logger.Error(err, "Failed to get runner scale set from Actions service")
+		r.recordGitHubError(autoscalingRunnerSet, err)
 		return ctrl.Result{}, err
 	}
 
-	runnerGroupId := 1
+	labels := runnerScaleSetLabels(autoscalingRunnerSet, overflowSources)
 	if runnerScaleSet == nil {
-		if len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 {
-			runnerGroup, err := actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
-			if err != nil {
-				logger.Error(err, "Failed to get runner group by name", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
-				return ctrl.Result{}, err
-			}
-
-			runnerGroupId = int(runnerGroup.ID)
+		runnerGroupId, err := r.runnerGroupIdFor(ctx, actionsClient, autoscalingRunnerSet, logger)
+		if err != nil {
+			return ctrl.Result{}, err
 		}
 
 		runnerScaleSet, err = actionsClient.CreateRunnerScaleSet(
 			ctx,
 			&actions.RunnerScaleSet{
-				Name:          autoscalingRunnerSet.Name,
+				Name:          autoscalingRunnerSet.RunnerScaleSetName(),
 				RunnerGroupId: runnerGroupId,
-				Labels: []actions.Label{
-					{
-						Name: autoscalingRunnerSet.Name,
-						Type: "System",
-					},
-				},
+				Labels:        labels,
 				RunnerSetting: actions.RunnerSetting{
 					Ephemeral:     true,
 					DisableUpdate: true,
@@ -343,8 +917,12 @@ func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Contex
 			})
 		if err != nil {
 			logger.Error(err, "Failed to create a new runner scale set on Actions service")
+			r.recordGitHubError(autoscalingRunnerSet, err)
 			return ctrl.Result{}, err
 		}
+	} else {
+		// A reused runner scale set is updated once its labels are found out of date.
+		labels = runnerScaleSet.Labels
 	}
 
 	logger.Info("Created/Reused a runner scale set", "id", runnerScaleSet.Id, "runnerGroupName", runnerScaleSet.RunnerGroupName)
@@ -356,16 +934,46 @@ func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Contex
 	if err = patch(ctx, r.Client, autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
 		obj.Annotations[runnerScaleSetIdKey] = strconv.Itoa(runnerScaleSet.Id)
 		obj.Annotations[runnerScaleSetRunnerGroupNameKey] = runnerScaleSet.RunnerGroupName
+		obj.Annotations[runnerScaleSetNameKey] = autoscalingRunnerSet.RunnerScaleSetName()
+		obj.Annotations[runnerScaleSetLabelsKey] = runnerScaleSetLabelsAnnotation(labels)
 	}); err != nil {
 		logger.Error(err, "Failed to add runner scale set ID and runner group name as an annotation")
 		return ctrl.Result{}, err
 	}
 
 	logger.Info("Updated with runner scale set ID and runner group name as an annotation")
+
+	if autoscalingRunnerSet.Status.State == v1alpha1.AutoscalingRunnerSetStateUnsupportedServerVersion {
+		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.State = ""
+		}); err != nil {
+			logger.Error(err, "Failed to clear the unsupported server version state")
+			return ctrl.Result{}, err
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
-func (r *AutoscalingRunnerSetReconciler) updateRunnerScaleSetRunnerGroup(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (ctrl.Result, error) {
+// markUnsupportedServerVersion records that the GitHub server is too old for runner scale sets.
+// Retrying will not help until the server is upgraded, so the check is only repeated occasionally.
+func (r *AutoscalingRunnerSetReconciler) markUnsupportedServerVersion(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, unsupportedErr *actions.UnsupportedServerVersionError, logger logr.Logger) (ctrl.Result, error) {
+	logger.Info("GitHub server does not support runner scale sets", "version", unsupportedErr.Version, "minimumVersion", unsupportedErr.MinimumVersion)
+
+	if autoscalingRunnerSet.Status.State != v1alpha1.AutoscalingRunnerSetStateUnsupportedServerVersion {
+		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.State = v1alpha1.AutoscalingRunnerSetStateUnsupportedServerVersion
+		}); err != nil {
+			logger.Error(err, "Failed to update autoscaling runner set state")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: unsupportedServerVersionRequeueInterval}, nil
+}
+
+// updateRunnerScaleSet updates the name, the labels and the runner group of the runner scale set to match the spec.
+func (r *AutoscalingRunnerSetReconciler) updateRunnerScaleSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, overflowSources []string, logger logr.Logger) (ctrl.Result, error) {
 	runnerScaleSetId, err := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetIdKey])
 	if err != nil {
 		logger.Error(err, "Failed to parse runner scale set ID")
@@ -378,35 +986,72 @@ func (r *AutoscalingRunnerSetReconciler) updateRunnerScaleSetRunnerGroup(ctx con
 		return ctrl.Result{}, err
 	}
 
-	runnerGroupId := 1
-	if len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 {
-		runnerGroup, err := actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
-		if err != nil {
-			logger.Error(err, "Failed to get runner group by name", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
-			return ctrl.Result{}, err
-		}
-
-		runnerGroupId = int(runnerGroup.ID)
+	runnerGroupId, err := r.runnerGroupIdFor(ctx, actionsClient, autoscalingRunnerSet, logger)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	updatedRunnerScaleSet, err := actionsClient.UpdateRunnerScaleSet(ctx, runnerScaleSetId, &actions.RunnerScaleSet{Name: autoscalingRunnerSet.Name, RunnerGroupId: runnerGroupId})
+	labels := runnerScaleSetLabels(autoscalingRunnerSet, overflowSources)
+	updatedRunnerScaleSet, err := actionsClient.UpdateRunnerScaleSet(
+		ctx,
+		runnerScaleSetId,
+		&actions.RunnerScaleSet{
+			Name:          autoscalingRunnerSet.RunnerScaleSetName(),
+			RunnerGroupId: runnerGroupId,
+			Labels:        labels,
+		})
 	if err != nil {
 		logger.Error(err, "Failed to update runner scale set", "runnerScaleSetId", runnerScaleSetId)
+		r.recordGitHubError(autoscalingRunnerSet, err)
 		return ctrl.Result{}, err
 	}
 
-	logger.Info("Updating runner scale set runner group name as an annotation")
+	logger.Info("Updating runner scale set name, runner group name and labels as an annotation")
 	if err := patch(ctx, r.Client, autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
 		obj.Annotations[runnerScaleSetRunnerGroupNameKey] = updatedRunnerScaleSet.RunnerGroupName
+		obj.Annotations[runnerScaleSetNameKey] = autoscalingRunnerSet.RunnerScaleSetName()
+		obj.Annotations[runnerScaleSetLabelsKey] = runnerScaleSetLabelsAnnotation(labels)
 	}); err != nil {
-		logger.Error(err, "Failed to update runner group name annotation")
+		logger.Error(err, "Failed to update runner scale set name, runner group name and labels annotations")
 		return ctrl.Result{}, err
 	}
 
-	logger.Info("Updated runner scale set with match runner group", "runnerGroup", updatedRunnerScaleSet.RunnerGroupName)
+	logger.Info("Updated runner scale set with match name and runner group", "name", updatedRunnerScaleSet.Name, "runnerGroup", updatedRunnerScaleSet.RunnerGroupName)
 	return ctrl.Result{}, nil
 }
 
+// runnerGroupIdFor resolves the ID of the runner group referenced by the spec,
+// creating the group when it is missing and the spec allows it.
+// The default runner group (ID 1) is used when no runner group is specified.
+func (r *AutoscalingRunnerSetReconciler) runnerGroupIdFor(ctx context.Context, actionsClient actions.ActionsService, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (int, error) {
+	if len(autoscalingRunnerSet.Spec.RunnerGroup) == 0 {
+		return 1, nil
+	}
+
+	runnerGroup, err := actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
+	if err == nil {
+		return int(runnerGroup.ID), nil
+	}
+
+	var notFoundErr *actions.RunnerGroupNotFoundError
+	if !errors.As(err, &notFoundErr) || !autoscalingRunnerSet.Spec.CreateRunnerGroupIfMissing {
+		logger.Error(err, "Failed to get runner group by name", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
+		r.recordGitHubError(autoscalingRunnerSet, err)
+		return 0, err
+	}
+
+	logger.Info("Runner group does not exist, creating it", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
+	runnerGroup, err = actionsClient.CreateRunnerGroup(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
+	if err != nil {
+		logger.Error(err, "Failed to create runner group", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
+		r.recordGitHubError(autoscalingRunnerSet, err)
+		return 0, err
+	}
+
+	logger.Info("Created runner group", "runnerGroup", runnerGroup.Name, "id", runnerGroup.ID)
+	return int(runnerGroup.ID), nil
+}
+
 func (r *AutoscalingRunnerSetReconciler) deleteRunnerScaleSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) error {
 	logger.Info("Deleting the runner scale set from Actions service")
 	runnerScaleSetId, err := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetIdKey])
@@ -418,14 +1063,41 @@ func (r *AutoscalingRunnerSetReconciler) deleteRunnerScaleSet(ctx context.Contex
 		return nil
 	}
 
+	if autoscalingRunnerSet.Spec.DeletionPolicy == v1alpha1.RetainDeletionPolicy {
+		logger.Info("Retaining the runner scale set in Actions service as requested by the deletion policy", "runnerScaleSetId", runnerScaleSetId)
+		r.Recorder.Eventf(
+			autoscalingRunnerSet,
+			corev1.EventTypeNormal,
+			"RunnerScaleSetRetained",
+			"Runner scale set %d (%s) is retained in the Actions service",
+			runnerScaleSetId,
+			autoscalingRunnerSet.RunnerScaleSetName(),
+		)
+		return nil
+	}
+
+	if forceDeleteRequested(autoscalingRunnerSet) {
+		r.orphanRunnerScaleSet(autoscalingRunnerSet, runnerScaleSetId, "the autoscaling runner set is force deleted", logger)
+		return nil
+	}
+
 	actionsClient, err := r.actionsClientFor(ctx, autoscalingRunnerSet)
 	if err != nil {
+		if remoteCleanupTimedOut(autoscalingRunnerSet, r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), time.Now()) {
+			r.orphanRunnerScaleSet(autoscalingRunnerSet, runnerScaleSetId, fmt.Sprintf("the Actions service client could not be initialized for %s: %v", r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), err), logger)
+			return nil
+		}
 		logger.Error(err, "Failed to initialize Actions service client for updating a existing runner scale set")
 		return err
 	}
 
 	err = actionsClient.DeleteRunnerScaleSet(ctx, runnerScaleSetId)
 	if err != nil {
+		r.recordGitHubError(autoscalingRunnerSet, err)
+		if remoteCleanupTimedOut(autoscalingRunnerSet, r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), time.Now()) {
+			r.orphanRunnerScaleSet(autoscalingRunnerSet, runnerScaleSetId, fmt.Sprintf("deleting it kept failing for %s: %v", r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), err), logger)
+			return nil
+		}
 		logger.Error(err, "Failed to delete runner scale set", "runnerScaleSetId", runnerScaleSetId)
 		return err
 	}
@@ -434,13 +1106,73 @@ func (r *AutoscalingRunnerSetReconciler) deleteRunnerScaleSet(ctx context.Contex
 	return nil
 }
 
-func (r *AutoscalingRunnerSetReconciler) createEphemeralRunnerSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) (ctrl.Result, error) {
+// orphanRunnerScaleSet records that the runner scale set is left in the Actions service, to be deleted manually.
+func (r *AutoscalingRunnerSetReconciler) orphanRunnerScaleSet(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerScaleSetId int, reason string, logger logr.Logger) {
+	logger.Info("Skipping the deletion of the runner scale set from Actions service", "runnerScaleSetId", runnerScaleSetId, "reason", reason)
+	r.Recorder.Eventf(
+		autoscalingRunnerSet,
+		corev1.EventTypeWarning,
+		"RunnerScaleSetOrphaned",
+		"Runner scale set %d is left in the Actions service since %s. Delete it manually.",
+		runnerScaleSetId,
+		reason,
+	)
+}
+
+func (r *AutoscalingRunnerSetReconciler) recordGitHubError(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, err error) {
+	if r.GitHubErrors == nil {
+		return
+	}
+	r.GitHubErrors.RecordGitHubError(types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: autoscalingRunnerSet.Name}, err)
+}
+
+func (r *AutoscalingRunnerSetReconciler) createEphemeralRunnerSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, rollingUpdate bool, log logr.Logger) (ctrl.Result, error) {
 	desiredRunnerSet, err := r.resourceBuilder.newEphemeralRunnerSet(autoscalingRunnerSet)
 	if err != nil {
 		log.Error(err, "Could not create EphemeralRunnerSet")
 		return ctrl.Result{}, err
 	}
 
+	r.applyDefaultGitHubServerTLS(autoscalingRunnerSet, desiredRunnerSet)
+
+	if err := r.validateTemplatePatches(ctx, desiredRunnerSet); err != nil {
+		log.Error(err, "Invalid template patches. Not creating the runner set")
+		if err := r.reportInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonTemplatePatches, err); err != nil {
+			log.Error(err, "Failed to report the invalid template patches")
+			return ctrl.Result{}, err
+		}
+		// Requeueing doesn't help, the autoscaling runner set is reconciled again once it is updated.
+		return ctrl.Result{}, nil
+	}
+	if err := r.clearInvalidSpec(ctx, autoscalingRunnerSet, v1alpha1.InvalidSpecReasonTemplatePatches); err != nil {
+		log.Error(err, "Failed to clear the invalid template patches")
+		return ctrl.Result{}, err
+	}
+
+	admitted, err := r.reconcileRunnerPodSecurity(ctx, autoscalingRunnerSet, desiredRunnerSet, log)
+	if err != nil {
+		log.Error(err, "Failed to validate the runner pods against the Pod Security level of the namespace")
+		return ctrl.Result{}, err
+	}
+	if !admitted {
+		// The namespace labels aren't watched, so relaxing the Pod Security level is picked up on the next check.
+		return ctrl.Result{RequeueAfter: podSecurityRecheckInterval}, nil
+	}
+
+	verified, err := r.reconcileRunnerImageSignatures(ctx, autoscalingRunnerSet, desiredRunnerSet, log)
+	if err != nil {
+		log.Error(err, "Failed to verify the signatures of the runner images")
+		return ctrl.Result{}, err
+	}
+	if !verified {
+		return ctrl.Result{RequeueAfter: imageSignatureRecheckInterval}, nil
+	}
+
+	if rollingUpdate {
+		// Hold off creating runners until the rollout decides how many the new runner set may have.
+		desiredRunnerSet.Spec.MaxReplicas = new(int)
+	}
+
 	if err := ctrl.SetControllerReference(autoscalingRunnerSet, desiredRunnerSet, r.Scheme); err != nil {
 		log.Error(err, "Failed to set controller reference to a new EphemeralRunnerSet")
 		return ctrl.Result{}, err
@@ -456,7 +1188,7 @@ func (r *AutoscalingRunnerSetReconciler) createEphemeralRunnerSet(ctx context.Co
 	return ctrl.Result{}, nil
 }
 
-func (r *AutoscalingRunnerSetReconciler) createAutoScalingListenerForRunnerSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) (ctrl.Result, error) {
+func (r *AutoscalingRunnerSetReconciler) createAutoScalingListenerForRunnerSet(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, overflowSources []string, log logr.Logger) (ctrl.Result, error) {
 	var imagePullSecrets []corev1.LocalObjectReference
 	for _, imagePullSecret := range r.DefaultRunnerScaleSetListenerImagePullSecrets {
 		imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{
@@ -464,7 +1196,7 @@ func (r *AutoscalingRunnerSetReconciler) createAutoScalingListenerForRunnerSet(c
 		})
 	}
 
-	autoscalingListener, err := r.resourceBuilder.newAutoScalingListener(autoscalingRunnerSet, ephemeralRunnerSet, r.ControllerNamespace, r.DefaultRunnerScaleSetListenerImage, imagePullSecrets)
+	autoscalingListener, err := r.resourceBuilder.newAutoScalingListener(autoscalingRunnerSet, ephemeralRunnerSet, overflowSources, r.ControllerNamespace, r.listenerImage(autoscalingRunnerSet), imagePullSecrets)
 	if err != nil {
 		log.Error(err, "Could not create AutoscalingListener spec")
 		return ctrl.Result{}, err
@@ -480,22 +1212,60 @@ func (r *AutoscalingRunnerSetReconciler) createAutoScalingListenerForRunnerSet(c
 	return ctrl.Result{}, nil
 }
 
+// listenerImage returns the image of the listener of the autoscaling runner set: its own listener image,
+// or the one of the controller.
+func (r *AutoscalingRunnerSetReconciler) listenerImage(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	if autoscalingRunnerSet.Spec.ListenerImage != "" {
+		return autoscalingRunnerSet.Spec.ListenerImage
+	}
+	return r.Config.listenerImage(r.DefaultRunnerScaleSetListenerImage)
+}
+
 func (r *AutoscalingRunnerSetReconciler) listEphemeralRunnerSets(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (*EphemeralRunnerSets, error) {
+	return listEphemeralRunnerSets(ctx, r.Client, autoscalingRunnerSet)
+}
+
+func listEphemeralRunnerSets(ctx context.Context, c client.Reader, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (*EphemeralRunnerSets, error) {
 	list := new(v1alpha1.EphemeralRunnerSetList)
-	if err := r.List(ctx, list, client.InNamespace(autoscalingRunnerSet.Namespace), client.MatchingFields{autoscalingRunnerSetOwnerKey: autoscalingRunnerSet.Name}); err != nil {
+	if err := c.List(ctx, list, client.InNamespace(autoscalingRunnerSet.Namespace), client.MatchingFields{autoscalingRunnerSetOwnerKey: autoscalingRunnerSet.Name}); err != nil {
 		return nil, fmt.Errorf("failed to list ephemeral runner sets: %v", err)
 	}
 
 	return &EphemeralRunnerSets{list: list}, nil
 }
 
+// overflowSources returns the sorted runner scale set names of the autoscaling runner sets overflowing into this one.
+// They are added to the labels of its runner scale set, so that it can acquire their jobs.
+func (r *AutoscalingRunnerSetReconciler) overflowSources(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) ([]string, error) {
+	var list v1alpha1.AutoscalingRunnerSetList
+	if err := r.List(ctx, &list, client.InNamespace(autoscalingRunnerSet.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list autoscaling runner sets: %v", err)
+	}
+
+	var sources []string
+	for i := range list.Items {
+		source := &list.Items[i]
+		if source.Spec.OverflowTarget != autoscalingRunnerSet.Name || source.Name == autoscalingRunnerSet.Name || !source.DeletionTimestamp.IsZero() {
+			continue
+		}
+		sources = append(sources, source.RunnerScaleSetName())
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
 func (r *AutoscalingRunnerSetReconciler) actionsClientFor(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (actions.ActionsService, error) {
 	var configSecret corev1.Secret
 	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: autoscalingRunnerSet.Spec.GitHubConfigSecret}, &configSecret); err != nil {
 		return nil, fmt.Errorf("failed to find GitHub config secret: %w", err)
 	}
 
-	return r.ActionsClient.GetClientFromSecret(ctx, autoscalingRunnerSet.Spec.GitHubConfigUrl, autoscalingRunnerSet.Namespace, configSecret.Data)
+	options, err := actionsClientOptions(ctx, r.Client, autoscalingRunnerSet.Namespace, autoscalingRunnerSet.Spec.Proxy, autoscalingRunnerSet.Spec.GitHubServerTLS, r.DefaultGitHubServerTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ActionsClient.GetClientFromSecret(ctx, autoscalingRunnerSet.Spec.GitHubConfigUrl, autoscalingRunnerSet.Namespace, configSecret.Data, options...)
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -520,9 +1290,20 @@ func (r *AutoscalingRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) erro
 		return err
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("autoscalingrunnerset-controller")
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.AutoscalingRunnerSet{}).
 		Owns(&v1alpha1.EphemeralRunnerSet{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&networkingv1.NetworkPolicy{}).
 		Watches(&source.Kind{Type: &v1alpha1.AutoscalingListener{}}, handler.EnqueueRequestsFromMapFunc(
 			func(o client.Object) []reconcile.Request {
 				autoscalingListener := o.(*v1alpha1.AutoscalingListener)
@@ -536,10 +1317,405 @@ func (r *AutoscalingRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) erro
 				}
 			},
 		)).
-		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
+		Watches(&source.Kind{Type: &v1alpha1.AutoscalingRunnerSet{}}, handler.EnqueueRequestsFromMapFunc(
+			func(o client.Object) []reconcile.Request {
+				// The overflow target advertises the labels of the scale sets overflowing into it.
+				// On updates, both the old and the new object are mapped, so a previous target is reconciled too.
+				autoscalingRunnerSet := o.(*v1alpha1.AutoscalingRunnerSet)
+				if autoscalingRunnerSet.Spec.OverflowTarget == "" {
+					return nil
+				}
+				return []reconcile.Request{
+					{
+						NamespacedName: types.NamespacedName{
+							Namespace: autoscalingRunnerSet.Namespace,
+							Name:      autoscalingRunnerSet.Spec.OverflowTarget,
+						},
+					},
+				}
+			},
+		))
+
+	if r.Config != nil {
+		// The listener image and the runner version check interval of the settings apply to all the autoscaling runner sets.
+		b = b.Watches(&source.Channel{Source: r.Config.subscribe()}, handler.EnqueueRequestsFromMapFunc(r.allAutoscalingRunnerSets))
+	}
+
+	return b.WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
 		Complete(r)
 }
 
+func (r *AutoscalingRunnerSetReconciler) allAutoscalingRunnerSets(client.Object) []reconcile.Request {
+	var autoscalingRunnerSets v1alpha1.AutoscalingRunnerSetList
+	if err := r.List(context.Background(), &autoscalingRunnerSets); err != nil {
+		r.Log.Error(err, "Failed to list the autoscaling runner sets to apply the controller settings")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(autoscalingRunnerSets.Items))
+	for _, autoscalingRunnerSet := range autoscalingRunnerSets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&autoscalingRunnerSet)})
+	}
+	return requests
+}
+
+// runnerScaleSetLabelsAnnotation returns the value of the annotation recording the labels of the runner scale set.
+func runnerScaleSetLabelsAnnotation(labels []actions.Label) string {
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// runnerScaleSetLabels returns the labels of the runner scale set: its name, the labels of its template variants
+// and the names of the scale sets overflowing into it, so jobs requesting them are routed to it.
+func runnerScaleSetLabels(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, overflowSources []string) []actions.Label {
+	name := autoscalingRunnerSet.RunnerScaleSetName()
+	labels := []actions.Label{
+		{
+			Name: name,
+			Type: "System",
+		},
+	}
+
+	seen := map[string]bool{name: true}
+	add := func(label string) {
+		if seen[label] {
+			return
+		}
+		seen[label] = true
+		labels = append(labels, actions.Label{
+			Name: label,
+			Type: "System",
+		})
+	}
+	for _, variant := range autoscalingRunnerSet.RunnerTemplateVariants() {
+		for _, label := range variant.Labels {
+			add(label)
+		}
+	}
+	for _, source := range overflowSources {
+		add(source)
+	}
+
+	return labels
+}
+
+// reconcileImagePrePull keeps the image pre-pull DaemonSets of the autoscaling runner set in sync with its pod template
+// and its template variants.
+func (r *AutoscalingRunnerSetReconciler) reconcileImagePrePull(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	daemonSets := new(appsv1.DaemonSetList)
+	if err := r.List(ctx, daemonSets, client.InNamespace(autoscalingRunnerSet.Namespace), client.MatchingLabels{
+		"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+		"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+		"app.kubernetes.io/component":       "image-pre-pull",
+	}); err != nil {
+		return fmt.Errorf("failed to list image pre-pull daemon sets: %v", err)
+	}
+
+	var desired []*appsv1.DaemonSet
+	if autoscalingRunnerSet.Spec.ImagePrePull != nil && autoscalingRunnerSet.Spec.ImagePrePull.Enabled {
+		desired = r.resourceBuilder.newImagePrePullDaemonSets(autoscalingRunnerSet, r.listenerImage(autoscalingRunnerSet))
+	}
+	desiredByName := make(map[string]*appsv1.DaemonSet, len(desired))
+	for _, daemonSet := range desired {
+		desiredByName[daemonSet.Name] = daemonSet
+	}
+
+	existing := make(map[string]*appsv1.DaemonSet, len(daemonSets.Items))
+	for i := range daemonSets.Items {
+		daemonSet := &daemonSets.Items[i]
+		if !metav1.IsControlledBy(daemonSet, autoscalingRunnerSet) {
+			continue
+		}
+		// The selector of a DaemonSet can't change, so the ones created with another one are recreated.
+		if d, ok := desiredByName[daemonSet.Name]; ok && equality.Semantic.DeepEqual(daemonSet.Spec.Selector, d.Spec.Selector) {
+			existing[daemonSet.Name] = daemonSet
+			continue
+		}
+		log.Info("Deleting image pre-pull daemon set", "name", daemonSet.Name)
+		if err := r.Delete(ctx, daemonSet); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete image pre-pull daemon set: %v", err)
+		}
+	}
+
+	for _, desired := range desired {
+		daemonSet, ok := existing[desired.Name]
+		if !ok {
+			if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+				return fmt.Errorf("failed to set controller reference: %v", err)
+			}
+			log.Info("Creating image pre-pull daemon set", "name", desired.Name)
+			if err := r.Create(ctx, desired); err != nil && !kerrors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to create image pre-pull daemon set: %v", err)
+			}
+			continue
+		}
+
+		if daemonSet.Labels[LabelKeyRunnerSpecHash] == desired.Labels[LabelKeyRunnerSpecHash] {
+			continue
+		}
+
+		log.Info("Runner pod template changed. Updating the image pre-pull daemon set", "name", daemonSet.Name)
+		if err := patch(ctx, r.Client, daemonSet, func(obj *appsv1.DaemonSet) {
+			obj.Labels = desired.Labels
+			obj.Spec.Template = desired.Spec.Template
+		}); err != nil {
+			return fmt.Errorf("failed to update image pre-pull daemon set: %v", err)
+		}
+	}
+	return nil
+}
+
+// warmPoolSize returns the number of idle runners the autoscaling runner set keeps on top of the busy ones.
+// The deprecated warm pool only applies when MinIdleRunners isn't set.
+func warmPoolSize(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) int {
+	if minIdle := autoscalingRunnerSet.Spec.MinIdleRunners; minIdle != nil {
+		return *minIdle
+	}
+	if autoscalingRunnerSet.Spec.WarmPool != nil {
+		return autoscalingRunnerSet.Spec.WarmPool.Size
+	}
+	return 0
+}
+
+// minRunners returns the number of runners the idle runner timeout doesn't scale below.
+// listenerScaleSettings returns the scaling settings of the autoscaling runner set its listener reads
+// from the latest runner set. MaxRunners is unbounded when not set.
+func listenerScaleSettings(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *v1alpha1.ListenerScaleSettings {
+	maxRunners := math.MaxInt32
+	if autoscalingRunnerSet.Spec.MaxRunners != nil {
+		maxRunners = *autoscalingRunnerSet.Spec.MaxRunners
+	}
+	return &v1alpha1.ListenerScaleSettings{
+		MinRunners:              minRunners(autoscalingRunnerSet),
+		MaxRunners:              maxRunners,
+		JobAcquisitionBatchSize: autoscalingRunnerSet.Spec.JobAcquisitionBatchSize,
+		ScalingBufferPercent:    autoscalingRunnerSet.Spec.ScalingBufferPercent,
+		ScalingSmoothingPercent: autoscalingRunnerSet.Spec.ScalingSmoothingPercent,
+		MaxPendingRunners:       autoscalingRunnerSet.Spec.MaxPendingRunners,
+	}
+}
+
+// listenerHasScaleSettings reports whether the spec of the listener has the scale settings. The running
+// listener reads them from the latest runner set, the spec only records them for its next pod.
+func listenerHasScaleSettings(listener *v1alpha1.AutoscalingListener, settings *v1alpha1.ListenerScaleSettings) bool {
+	return listener.Spec.MinRunners == settings.MinRunners &&
+		listener.Spec.MaxRunners == settings.MaxRunners &&
+		reflect.DeepEqual(listener.Spec.JobAcquisitionBatchSize, settings.JobAcquisitionBatchSize) &&
+		reflect.DeepEqual(listener.Spec.ScalingBufferPercent, settings.ScalingBufferPercent) &&
+		reflect.DeepEqual(listener.Spec.ScalingSmoothingPercent, settings.ScalingSmoothingPercent) &&
+		reflect.DeepEqual(listener.Spec.MaxPendingRunners, settings.MaxPendingRunners)
+}
+
+func minRunners(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) int {
+	if autoscalingRunnerSet.Spec.MinRunners == nil {
+		return 0
+	}
+	return *autoscalingRunnerSet.Spec.MinRunners
+}
+
+// reservedRunners returns the number of runners kept for the capacity reservations active at now,
+// on top of MinRunners and within MaxRunners, and how long until the next active reservation expires.
+// It is 0 when no reservation is active.
+func reservedRunners(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, now time.Time) (int, time.Duration) {
+	reserved := 0
+	var nextExpiry time.Duration
+	for _, reservation := range autoscalingRunnerSet.Spec.CapacityReservations {
+		remaining := reservation.ExpirationTime.Sub(now)
+		if remaining <= 0 {
+			continue
+		}
+		reserved += reservation.Replicas
+		if nextExpiry == 0 || remaining < nextExpiry {
+			nextExpiry = remaining
+		}
+	}
+	if reserved == 0 {
+		return 0, 0
+	}
+
+	reserved += minRunners(autoscalingRunnerSet)
+	if max := autoscalingRunnerSet.Spec.MaxRunners; max != nil && reserved > *max {
+		reserved = *max
+	}
+	return reserved, nextExpiry
+}
+
+// validateRunnerTemplates returns an error when a runner pod template can't run on the nodes it targets,
+// when template variants and node placements share a name, since the runners of each are told apart by it,
+// or when a template variant runs in another runtime class than the template.
+func validateRunnerTemplates(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	names := make(map[string]bool, len(autoscalingRunnerSet.Spec.TemplateVariants))
+	for _, variant := range autoscalingRunnerSet.Spec.TemplateVariants {
+		if variant.Name == "" {
+			return fmt.Errorf("template variants require a name")
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("template variant name %q is used more than once", variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	for _, placement := range autoscalingRunnerSet.Spec.NodePlacements {
+		if placement.Name == "" {
+			return fmt.Errorf("node placements require a name")
+		}
+		if names[placement.Name] {
+			return fmt.Errorf("node placement name %q is already used by a template variant or another node placement", placement.Name)
+		}
+		names[placement.Name] = true
+	}
+
+	if err := validateWindowsTemplate(&autoscalingRunnerSet.Spec.Template); err != nil {
+		return fmt.Errorf("invalid template: %v", err)
+	}
+	for _, variant := range autoscalingRunnerSet.RunnerTemplateVariants() {
+		if err := validateWindowsTemplate(&variant.Template); err != nil {
+			return fmt.Errorf("invalid template of variant %q: %v", variant.Name, err)
+		}
+		// Any runner of the scale set can be assigned any of its jobs, whatever their labels, so a sandboxed
+		// runtime only isolates the jobs of a runner scale set that runs all its runners in it.
+		if !equality.Semantic.DeepEqual(variant.Template.Spec.RuntimeClassName, autoscalingRunnerSet.Spec.Template.Spec.RuntimeClassName) {
+			return fmt.Errorf("template variant %q can't change the runtime class of the template, since its runners can be assigned the jobs of the other variants: use a separate runner scale set for the jobs to run in another runtime", variant.Name)
+		}
+	}
+	return nil
+}
+
+// validateTemplatePatches returns an error when the template patches of the runner set can't be applied
+// to its runner pods, as generated by the controller for the template and every template variant.
+func (r *AutoscalingRunnerSetReconciler) validateTemplatePatches(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet) error {
+	if len(runnerSet.Spec.EphemeralRunnerSpec.PodPatches) == 0 {
+		return nil
+	}
+
+	variants := []string{""}
+	for _, v := range runnerSet.Spec.TemplateVariants {
+		variants = append(variants, v.Name)
+	}
+	for _, variant := range variants {
+		runner := r.resourceBuilder.newEphemeralRunner(runnerSet, variant)
+		pod := r.resourceBuilder.newEphemeralRunnerPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.GenerateName}})
+		if _, err := applyPodPatches(pod, runner.Spec.PodPatches); err != nil {
+			if variant != "" {
+				return fmt.Errorf("template variant %q: %v", variant, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// reportInvalidSpec reports with the InvalidSpec condition and an event why the spec of the autoscaling runner set
+// can't be applied.
+func (r *AutoscalingRunnerSetReconciler) reportInvalidSpec(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, reason string, err error) error {
+	message := err.Error()
+	if condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec); condition != nil && condition.Reason == reason && condition.Message == message {
+		return nil
+	}
+	if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.AutoscalingRunnerSetConditionInvalidSpec,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to update autoscaling runner set status with the invalid spec: %v", err)
+	}
+	r.Recorder.Event(autoscalingRunnerSet, corev1.EventTypeWarning, reason, message)
+	return nil
+}
+
+// clearInvalidSpec removes the InvalidSpec condition reported for the reason, once that part of the spec is valid.
+func (r *AutoscalingRunnerSetReconciler) clearInvalidSpec(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, reason string) error {
+	if condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec); condition == nil || condition.Reason != reason {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		meta.RemoveStatusCondition(&obj.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec)
+	})
+}
+
+// rollingUpdateStrategy returns the rolling update settings of the autoscaling runner set,
+// or nil when old runner sets should be recreated.
+func rollingUpdateStrategy(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *v1alpha1.RollingUpdateStrategy {
+	strategy := autoscalingRunnerSet.Spec.UpdateStrategy
+	if strategy == nil || strategy.Type != v1alpha1.RollingUpdateStrategyType {
+		return nil
+	}
+	if strategy.RollingUpdate == nil {
+		return &v1alpha1.RollingUpdateStrategy{}
+	}
+	return strategy.RollingUpdate
+}
+
+// rollingUpdateReplicas returns the number of runners the latest runner set may have
+// and the number of runners the old runner sets may keep, given the desired runner count,
+// the number of running runners in the latest runner set and the number of runners left
+// in the old runner sets.
+func rollingUpdateReplicas(strategy *v1alpha1.RollingUpdateStrategy, desired, latestRunning, oldCurrent int) (latestMax, oldMax int) {
+	defaultValue := intstr.FromString(defaultRollingUpdateMaxPercent)
+
+	maxSurge := strategy.MaxSurge
+	if maxSurge == nil {
+		maxSurge = &defaultValue
+	}
+	surge, err := intstr.GetScaledValueFromIntOrPercent(maxSurge, desired, true)
+	if err != nil || surge < 0 {
+		surge = 0
+	}
+
+	maxUnavailable := strategy.MaxUnavailable
+	if maxUnavailable == nil {
+		maxUnavailable = &defaultValue
+	}
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, desired, false)
+	if err != nil || unavailable < 0 {
+		unavailable = 0
+	}
+
+	// The rollout could never make progress otherwise.
+	if surge == 0 && unavailable == 0 {
+		unavailable = 1
+	}
+
+	latestMax = desired + surge - oldCurrent
+	if latestMax < 0 {
+		latestMax = 0
+	}
+	if latestMax > desired {
+		latestMax = desired
+	}
+
+	oldMax = desired - unavailable - latestRunning
+	if oldMax < 0 {
+		oldMax = 0
+	}
+	if oldMax > oldCurrent {
+		oldMax = oldCurrent
+	}
+
+	return latestMax, oldMax
+}
+
+// sumRunnerPhases adds up the runners in each phase of the runner sets,
+// so that the runners of old runner sets still running jobs during a rollout are counted too.
+func sumRunnerPhases(runnerSets []v1alpha1.EphemeralRunnerSet) v1alpha1.RunnerPhaseCounts {
+	var sum v1alpha1.RunnerPhaseCounts
+	for i := range runnerSets {
+		phases := runnerSets[i].Status.RunnerPhases
+		sum.PendingPod += phases.PendingPod
+		sum.Registering += phases.Registering
+		sum.Idle += phases.Idle
+		sum.RunningJob += phases.RunningJob
+		sum.Finishing += phases.Finishing
+		sum.Failed += phases.Failed
+	}
+	return sum
+}
+
 // NOTE: if this is logic should be used for other resources,
 // consider using generics
 type EphemeralRunnerSets struct {
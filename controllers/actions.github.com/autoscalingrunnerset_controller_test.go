@@ -3,8 +3,11 @@ package actionsgithubcom
 import (
 	"context"
 	"fmt"
+	"math"
+	"testing"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -14,9 +17,22 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/github/actions/fake"
 )
 
@@ -570,3 +586,717 @@ var _ = Describe("Test AutoscalingController creation failures", func() {
 		})
 	})
 })
+
+var _ = Describe("Test AutoscalingController runner group creation", func() {
+	Context("When the runner group does not exist on the service", func() {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		autoscalingNS := new(corev1.Namespace)
+		configSecret := new(corev1.Secret)
+
+		BeforeEach(func() {
+			ctx, cancel = context.WithCancel(context.TODO())
+			autoscalingNS = &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "testns-autoscaling" + RandStringRunes(5)},
+			}
+
+			err := k8sClient.Create(ctx, autoscalingNS)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test namespace for AutoScalingRunnerSet")
+
+			configSecret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "github-config-secret",
+					Namespace: autoscalingNS.Name,
+				},
+				Data: map[string][]byte{
+					"github_token": []byte(autoscalingRunnerSetTestGitHubToken),
+				},
+			}
+
+			err = k8sClient.Create(ctx, configSecret)
+			Expect(err).NotTo(HaveOccurred(), "failed to create config secret")
+
+			mgr, err := ctrl.NewManager(cfg, ctrl.Options{})
+			Expect(err).NotTo(HaveOccurred(), "failed to create manager")
+
+			controller := &AutoscalingRunnerSetReconciler{
+				Client:                             mgr.GetClient(),
+				Scheme:                             mgr.GetScheme(),
+				Log:                                logf.Log,
+				ControllerNamespace:                autoscalingNS.Name,
+				DefaultRunnerScaleSetListenerImage: "ghcr.io/actions/arc",
+				ActionsClient: fake.NewMultiClient(
+					fake.WithDefaultClient(
+						fake.NewFakeClient(
+							fake.WithGetRunnerGroup(nil, &actions.RunnerGroupNotFoundError{Name: "testgroup2"}),
+							fake.WithCreateRunnerGroup(&actions.RunnerGroup{ID: 2, Name: "testgroup2"}, nil),
+						),
+						nil,
+					),
+				),
+			}
+			err = controller.SetupWithManager(mgr)
+			Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
+
+			go func() {
+				defer GinkgoRecover()
+
+				err := mgr.Start(ctx)
+				Expect(err).NotTo(HaveOccurred(), "failed to start manager")
+			}()
+		})
+
+		AfterEach(func() {
+			defer cancel()
+
+			err := k8sClient.Delete(ctx, autoscalingNS)
+			Expect(err).NotTo(HaveOccurred(), "failed to delete test namespace for AutoScalingRunnerSet")
+		})
+
+		It("It should create the missing runner group when createRunnerGroupIfMissing is set", func() {
+			min := 1
+			max := 10
+			autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-asrs",
+					Namespace: autoscalingNS.Name,
+				},
+				Spec: v1alpha1.AutoscalingRunnerSetSpec{
+					GitHubConfigUrl:            "https://github.com/owner/repo",
+					GitHubConfigSecret:         configSecret.Name,
+					MaxRunners:                 &max,
+					MinRunners:                 &min,
+					RunnerGroup:                "testgroup2",
+					CreateRunnerGroupIfMissing: true,
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{
+									Name:  "runner",
+									Image: "ghcr.io/actions/runner",
+								},
+							},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, autoscalingRunnerSet)
+			Expect(err).NotTo(HaveOccurred(), "failed to create AutoScalingRunnerSet")
+
+			ars := new(v1alpha1.AutoscalingRunnerSet)
+			Eventually(
+				func() (string, error) {
+					err := k8sClient.Get(ctx, client.ObjectKey{Name: autoscalingRunnerSet.Name, Namespace: autoscalingRunnerSet.Namespace}, ars)
+					if err != nil {
+						return "", err
+					}
+
+					return ars.Annotations[runnerScaleSetRunnerGroupNameKey], nil
+				},
+				autoscalingRunnerSetTestTimeout,
+				autoscalingRunnerSetTestInterval,
+			).Should(BeEquivalentTo("testgroup2"), "AutoScalingRunnerSet should be assigned to the created runner group")
+		})
+	})
+})
+
+func TestRollingUpdateReplicas(t *testing.T) {
+	intOrStr := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	tests := map[string]struct {
+		strategy      v1alpha1.RollingUpdateStrategy
+		desired       int
+		latestRunning int
+		oldCurrent    int
+		wantLatestMax int
+		wantOldMax    int
+	}{
+		"defaults at rollout start": {
+			desired:       8,
+			oldCurrent:    8,
+			wantLatestMax: 2,
+			wantOldMax:    6,
+		},
+		"old runners drained as new runners come up": {
+			desired:       8,
+			latestRunning: 2,
+			oldCurrent:    6,
+			wantLatestMax: 4,
+			wantOldMax:    4,
+		},
+		"rollout complete": {
+			desired:       8,
+			latestRunning: 8,
+			oldCurrent:    0,
+			wantLatestMax: 8,
+			wantOldMax:    0,
+		},
+		"busy old runners limit surge": {
+			strategy: v1alpha1.RollingUpdateStrategy{
+				MaxSurge:       intOrStr(intstr.FromInt(1)),
+				MaxUnavailable: intOrStr(intstr.FromInt(0)),
+			},
+			desired:       4,
+			oldCurrent:    4,
+			wantLatestMax: 1,
+			wantOldMax:    4,
+		},
+		"zero surge and unavailable still progresses": {
+			strategy: v1alpha1.RollingUpdateStrategy{
+				MaxSurge:       intOrStr(intstr.FromInt(0)),
+				MaxUnavailable: intOrStr(intstr.FromInt(0)),
+			},
+			desired:       4,
+			oldCurrent:    4,
+			wantLatestMax: 0,
+			wantOldMax:    3,
+		},
+		"percentages": {
+			strategy: v1alpha1.RollingUpdateStrategy{
+				MaxSurge:       intOrStr(intstr.FromString("50%")),
+				MaxUnavailable: intOrStr(intstr.FromString("10%")),
+			},
+			desired:       10,
+			oldCurrent:    10,
+			wantLatestMax: 5,
+			wantOldMax:    9,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			latestMax, oldMax := rollingUpdateReplicas(&tc.strategy, tc.desired, tc.latestRunning, tc.oldCurrent)
+			assert.Equal(t, tc.wantLatestMax, latestMax, "latestMax")
+			assert.Equal(t, tc.wantOldMax, oldMax, "oldMax")
+		})
+	}
+}
+
+func TestRollingUpdateStrategy(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{}
+	assert.Nil(t, rollingUpdateStrategy(ars))
+
+	ars.Spec.UpdateStrategy = &v1alpha1.UpdateStrategy{Type: v1alpha1.RecreateUpdateStrategyType}
+	assert.Nil(t, rollingUpdateStrategy(ars))
+
+	ars.Spec.UpdateStrategy = &v1alpha1.UpdateStrategy{Type: v1alpha1.RollingUpdateStrategyType}
+	assert.Equal(t, &v1alpha1.RollingUpdateStrategy{}, rollingUpdateStrategy(ars))
+}
+
+func TestWarmPoolSize(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{}
+	assert.Equal(t, 0, warmPoolSize(ars))
+
+	ars.Spec.WarmPool = &v1alpha1.WarmPool{Size: 3}
+	assert.Equal(t, 3, warmPoolSize(ars), "The deprecated warm pool applies without min idle runners")
+
+	minIdleRunners := 2
+	ars.Spec.MinIdleRunners = &minIdleRunners
+	assert.Equal(t, 2, warmPoolSize(ars), "The min idle runners take precedence over the warm pool")
+
+	minIdleRunners = 0
+	assert.Equal(t, 0, warmPoolSize(ars))
+}
+
+func TestReservedRunners(t *testing.T) {
+	now := time.Now()
+	minRunners, maxRunners := 1, 5
+	ars := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			MinRunners: &minRunners,
+			MaxRunners: &maxRunners,
+		},
+	}
+
+	reserved, nextExpiry := reservedRunners(ars, now)
+	assert.Equal(t, 0, reserved)
+	assert.Zero(t, nextExpiry)
+
+	ars.Spec.CapacityReservations = []v1alpha1.CapacityReservation{
+		{Name: "expired", Replicas: 10, ExpirationTime: metav1.NewTime(now.Add(-time.Minute))},
+		{Name: "release", Replicas: 2, ExpirationTime: metav1.NewTime(now.Add(time.Hour))},
+		{Name: "nightly", Replicas: 1, ExpirationTime: metav1.NewTime(now.Add(10 * time.Minute))},
+	}
+	reserved, nextExpiry = reservedRunners(ars, now)
+	assert.Equal(t, 4, reserved, "Active reservations are on top of min runners")
+	assert.Equal(t, 10*time.Minute, nextExpiry)
+
+	ars.Spec.CapacityReservations[1].Replicas = 20
+	reserved, _ = reservedRunners(ars, now)
+	assert.Equal(t, maxRunners, reserved, "Reservations are capped at max runners")
+}
+
+func TestListenerSpecHash(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+		},
+	}
+	listenerHash := ars.ListenerSpecHash()
+
+	minRunners, maxRunners, bufferPercent := 1, 5, 20
+	ars.Spec.MinRunners = &minRunners
+	ars.Spec.MaxRunners = &maxRunners
+	ars.Spec.ScalingBufferPercent = &bufferPercent
+	assert.Equal(t, listenerHash, ars.ListenerSpecHash(), "The scale settings are passed to the running listener")
+
+	ars.Spec.CapacityReservations = []v1alpha1.CapacityReservation{
+		{Name: "release", Replicas: 2, ExpirationTime: metav1.NewTime(time.Now().Add(time.Hour))},
+	}
+	assert.Equal(t, listenerHash, ars.ListenerSpecHash(), "The capacity reservations are applied by the controller to the runner set")
+
+	ars.Spec.ListenerTemplate = &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "linux"}},
+	}
+	assert.NotEqual(t, listenerHash, ars.ListenerSpecHash(), "The listener pod changes with its template")
+}
+
+func TestListenerScaleSettings(t *testing.T) {
+	ars := &v1alpha1.AutoscalingRunnerSet{}
+	settings := listenerScaleSettings(ars)
+	assert.Equal(t, 0, settings.MinRunners)
+	assert.Equal(t, math.MaxInt32, settings.MaxRunners, "MaxRunners is unbounded when not set")
+
+	minRunners, maxPending := 2, 3
+	ars.Spec.MinRunners = &minRunners
+	ars.Spec.MaxPendingRunners = &maxPending
+	settings = listenerScaleSettings(ars)
+	assert.Equal(t, 2, settings.MinRunners)
+	assert.Equal(t, &maxPending, settings.MaxPendingRunners)
+
+	listener := &v1alpha1.AutoscalingListener{
+		Spec: v1alpha1.AutoscalingListenerSpec{MinRunners: 0, MaxRunners: math.MaxInt32},
+	}
+	assert.False(t, listenerHasScaleSettings(listener, settings))
+
+	listener.Spec.MinRunners = 2
+	listener.Spec.MaxPendingRunners = &maxPending
+	assert.True(t, listenerHasScaleSettings(listener, settings))
+}
+
+func TestCreateRunnerScaleSet_UnsupportedServerVersion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(autoscalingRunnerSetTestGitHubToken)},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://ghes.example.com/owner/repo",
+			GitHubConfigSecret: secret.Name,
+		},
+	}
+
+	client := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(secret, autoscalingRunnerSet).
+		Build()
+
+	actionsClient := &actions.MockActionsService{}
+	actionsClient.On("GetRunnerScaleSet", mock.Anything, autoscalingRunnerSet.Name).Return(nil, &actions.UnsupportedServerVersionError{
+		Version:        "3.8.0",
+		Feature:        actions.ServerFeatureRunnerScaleSets,
+		MinimumVersion: "3.9",
+	})
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+	}
+
+	result, err := r.createRunnerScaleSet(context.Background(), autoscalingRunnerSet, nil, logr.Discard())
+	require.NoError(t, err)
+	assert.Equal(t, unsupportedServerVersionRequeueInterval, result.RequeueAfter)
+
+	updated := new(v1alpha1.AutoscalingRunnerSet)
+	require.NoError(t, client.Get(context.Background(), types.NamespacedName{Name: autoscalingRunnerSet.Name, Namespace: autoscalingRunnerSet.Namespace}, updated))
+	assert.Equal(t, v1alpha1.AutoscalingRunnerSetStateUnsupportedServerVersion, updated.Status.State)
+}
+
+func TestRunnerScaleSetName(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(autoscalingRunnerSetTestGitHubToken)},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: secret.Name,
+			RunnerScaleSetName: "linux-x64",
+		},
+	}
+
+	client := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(secret, autoscalingRunnerSet).
+		Build()
+
+	actionsClient := &actions.MockActionsService{}
+	actionsClient.On("GetRunnerScaleSet", mock.Anything, "linux-x64").Return(nil, nil)
+	actionsClient.On("CreateRunnerScaleSet", mock.Anything, mock.MatchedBy(func(rs *actions.RunnerScaleSet) bool {
+		return rs.Name == "linux-x64" && rs.Labels[0].Name == "linux-x64"
+	})).Return(&actions.RunnerScaleSet{Id: 1, Name: "linux-x64", RunnerGroupName: "Default"}, nil)
+	actionsClient.On("UpdateRunnerScaleSet", mock.Anything, 1, mock.MatchedBy(func(rs *actions.RunnerScaleSet) bool {
+		return rs.Name == "linux-arm64" && rs.Labels[0].Name == "linux-arm64" && rs.RunnerGroupId == 1
+	})).Return(&actions.RunnerScaleSet{Id: 1, Name: "linux-arm64", RunnerGroupName: "Default"}, nil)
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+	}
+
+	_, err := r.createRunnerScaleSet(context.Background(), autoscalingRunnerSet, nil, logr.Discard())
+	require.NoError(t, err)
+
+	updated := new(v1alpha1.AutoscalingRunnerSet)
+	require.NoError(t, client.Get(context.Background(), types.NamespacedName{Name: autoscalingRunnerSet.Name, Namespace: autoscalingRunnerSet.Namespace}, updated))
+	assert.Equal(t, "1", updated.Annotations[runnerScaleSetIdKey])
+	assert.Equal(t, "linux-x64", updated.Annotations[runnerScaleSetNameKey])
+	assert.Equal(t, "linux-x64", updated.Annotations[runnerScaleSetLabelsKey])
+
+	updated.Spec.RunnerScaleSetName = "linux-arm64"
+	_, err = r.updateRunnerScaleSet(context.Background(), updated, nil, logr.Discard())
+	require.NoError(t, err)
+	actionsClient.AssertExpectations(t)
+
+	renamed := new(v1alpha1.AutoscalingRunnerSet)
+	require.NoError(t, client.Get(context.Background(), types.NamespacedName{Name: autoscalingRunnerSet.Name, Namespace: autoscalingRunnerSet.Namespace}, renamed))
+	assert.Equal(t, "linux-arm64", renamed.Annotations[runnerScaleSetNameKey])
+	assert.Equal(t, "linux-arm64", renamed.Annotations[runnerScaleSetLabelsKey])
+}
+
+func TestOverflowSources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	target := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "spot", Namespace: "default"},
+	}
+	ci := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "default"},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{OverflowTarget: "spot", RunnerScaleSetName: "linux-ci"},
+	}
+	batch := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "batch", Namespace: "default"},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{OverflowTarget: "spot"},
+	}
+	otherNamespace := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "other"},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{OverflowTarget: "spot"},
+	}
+	unrelated := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu", Namespace: "default"},
+	}
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client: crfake.NewClientBuilder().WithScheme(scheme).WithObjects(target, ci, batch, otherNamespace, unrelated).Build(),
+		Scheme: scheme,
+	}
+
+	sources, err := r.overflowSources(context.Background(), target)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"batch", "linux-ci"}, sources)
+
+	labels := runnerScaleSetLabels(target, sources)
+	assert.Equal(t, "spot,batch,linux-ci", runnerScaleSetLabelsAnnotation(labels), "the target routes the jobs of its overflow sources to its own scale set")
+}
+
+func TestCreateEphemeralRunnerSet_InvalidTemplatePatches(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			TemplatePatches: []v1alpha1.PodPatch{
+				{Type: v1alpha1.PodPatchTypeJSON6902, Patch: `[{"op": "remove", "path": "/spec/nodeName"}]`},
+			},
+		},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "runners"}}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, namespace).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+
+	_, err := r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, false, logr.Discard())
+	require.NoError(t, err)
+
+	runnerSets := new(v1alpha1.EphemeralRunnerSetList)
+	require.NoError(t, c.List(ctx, runnerSets))
+	assert.Empty(t, runnerSets.Items, "no runner set should be created for patches that can't be applied")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.InvalidSpecReasonTemplatePatches, condition.Reason)
+	assert.Contains(t, condition.Message, "failed to apply JSON6902 pod patch 0")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, v1alpha1.InvalidSpecReasonTemplatePatches)
+
+	// The condition is cleared once the patches apply.
+	autoscalingRunnerSet.Spec.TemplatePatches[0].Patch = `[{"op": "add", "path": "/spec/nodeName", "value": "node"}]`
+	require.NoError(t, c.Update(ctx, autoscalingRunnerSet))
+	_, err = r.createEphemeralRunnerSet(ctx, autoscalingRunnerSet, false, logr.Discard())
+	require.NoError(t, err)
+
+	require.NoError(t, c.List(ctx, runnerSets))
+	assert.Len(t, runnerSets.Items, 1)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	assert.Nil(t, meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec))
+}
+
+func TestReconcile_InvalidInitContainers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "arc",
+			Namespace:  "runners",
+			Finalizers: []string{autoscalingRunnerSetFinalizerName},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			InitContainers:     []corev1.Container{{Name: "fetch-secrets"}, {Name: "fetch-secrets"}},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Log: logr.Discard(), Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(autoscalingRunnerSet)}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, autoscalingRunnerSet))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.InvalidSpecReasonInitContainers, condition.Reason)
+	assert.Contains(t, condition.Message, `init container "fetch-secrets" is defined more than once`)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, v1alpha1.InvalidSpecReasonInitContainers)
+
+	// The condition is cleared once the init containers are fixed. The invalid image signature
+	// verification stops the reconciliation right after.
+	autoscalingRunnerSet.Spec.InitContainers = autoscalingRunnerSet.Spec.InitContainers[:1]
+	autoscalingRunnerSet.Spec.ImageSignatureVerification = &v1alpha1.ImageSignatureVerification{}
+	require.NoError(t, c.Update(ctx, autoscalingRunnerSet))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, autoscalingRunnerSet))
+	assert.Nil(t, meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec))
+}
+
+func TestReconcileImagePrePull(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "default", UID: "test-asrs-uid"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "runner", Image: "ghcr.io/actions/runner:2.300.0"}},
+				},
+			},
+			ImagePrePull: &v1alpha1.ImagePrePull{Enabled: true},
+		},
+	}
+
+	client := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: client, Scheme: scheme}
+	key := types.NamespacedName{Namespace: "default", Name: "test-asrs-image-pre-pull"}
+
+	require.NoError(t, r.reconcileImagePrePull(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	daemonSet := new(appsv1.DaemonSet)
+	require.NoError(t, client.Get(context.Background(), key, daemonSet))
+	assert.True(t, metav1.IsControlledBy(daemonSet, autoscalingRunnerSet))
+	assert.Equal(t, "ghcr.io/actions/runner:2.300.0", daemonSet.Spec.Template.Spec.InitContainers[1].Image)
+
+	autoscalingRunnerSet.Spec.Template.Spec.Containers[0].Image = "ghcr.io/actions/runner:2.301.0"
+	require.NoError(t, r.reconcileImagePrePull(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, client.Get(context.Background(), key, daemonSet))
+	assert.Equal(t, "ghcr.io/actions/runner:2.301.0", daemonSet.Spec.Template.Spec.InitContainers[1].Image)
+
+	// The variants scheduled on other nodes get their own daemon set.
+	variantKey := types.NamespacedName{Namespace: "default", Name: "test-asrs-image-pre-pull-arm64"}
+	autoscalingRunnerSet.Spec.NodePlacements = []v1alpha1.NodePlacement{
+		{Name: "arm64", Labels: []string{"arm64"}, NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"}},
+	}
+	require.NoError(t, r.reconcileImagePrePull(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, client.Get(context.Background(), variantKey, daemonSet))
+
+	autoscalingRunnerSet.Spec.NodePlacements = nil
+	require.NoError(t, r.reconcileImagePrePull(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	err := client.Get(context.Background(), variantKey, daemonSet)
+	assert.True(t, errors.IsNotFound(err), "daemon set of the removed variant should be deleted, got: %v", err)
+
+	autoscalingRunnerSet.Spec.ImagePrePull = nil
+	require.NoError(t, r.reconcileImagePrePull(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	err = client.Get(context.Background(), key, daemonSet)
+	assert.True(t, errors.IsNotFound(err), "daemon set should be deleted, got: %v", err)
+}
+
+func TestDeleteRunnerScaleSet_ForceDelete(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(autoscalingRunnerSetTestGitHubToken)},
+	}
+	deletedAt := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	newAutoscalingRunnerSet := func(annotations map[string]string) *v1alpha1.AutoscalingRunnerSet {
+		return &v1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "test-asrs",
+				Namespace:         "default",
+				DeletionTimestamp: &deletedAt,
+				Annotations:       annotations,
+			},
+			Spec: v1alpha1.AutoscalingRunnerSetSpec{
+				GitHubConfigUrl:    "https://ghes.example.com/owner/repo",
+				GitHubConfigSecret: secret.Name,
+			},
+		}
+	}
+
+	unreachable := fmt.Errorf("dial tcp: connection refused")
+
+	tests := map[string]struct {
+		annotations map[string]string
+		timeout     time.Duration
+		wantErr     bool
+		wantEvent   bool
+		wantDelete  bool
+	}{
+		"force delete annotation skips the service": {
+			annotations: map[string]string{runnerScaleSetIdKey: "1", v1alpha1.AnnotationKeyForceDelete: "true"},
+			timeout:     0,
+			wantEvent:   true,
+		},
+		"failure within the timeout is retried": {
+			annotations: map[string]string{runnerScaleSetIdKey: "1"},
+			timeout:     3 * time.Hour,
+			wantErr:     true,
+			wantDelete:  true,
+		},
+		"failure past the timeout leaves the runner scale set": {
+			annotations: map[string]string{runnerScaleSetIdKey: "1"},
+			timeout:     time.Hour,
+			wantEvent:   true,
+			wantDelete:  true,
+		},
+		"no timeout retries forever": {
+			annotations: map[string]string{runnerScaleSetIdKey: "1"},
+			timeout:     0,
+			wantErr:     true,
+			wantDelete:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := crfake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(secret).
+				Build()
+
+			actionsClient := &actions.MockActionsService{}
+			actionsClient.On("DeleteRunnerScaleSet", mock.Anything, 1).Return(unreachable)
+
+			recorder := record.NewFakeRecorder(1)
+			r := &AutoscalingRunnerSetReconciler{
+				Client:               client,
+				Scheme:               scheme,
+				ActionsClient:        fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+				Recorder:             recorder,
+				RemoteCleanupTimeout: tc.timeout,
+			}
+
+			err := r.deleteRunnerScaleSet(context.Background(), newAutoscalingRunnerSet(tc.annotations), logr.Discard())
+			if tc.wantErr {
+				assert.ErrorIs(t, err, unreachable)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tc.wantDelete {
+				actionsClient.AssertCalled(t, "DeleteRunnerScaleSet", mock.Anything, 1)
+			} else {
+				actionsClient.AssertNotCalled(t, "DeleteRunnerScaleSet", mock.Anything, mock.Anything)
+			}
+
+			if tc.wantEvent {
+				require.Len(t, recorder.Events, 1)
+				assert.Contains(t, <-recorder.Events, "RunnerScaleSetOrphaned")
+			} else {
+				assert.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
+
+func TestDeleteRunnerScaleSet_RetainDeletionPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-asrs",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "github-config-secret",
+			DeletionPolicy:     v1alpha1.RetainDeletionPolicy,
+		},
+	}
+
+	actionsClient := &actions.MockActionsService{}
+	recorder := record.NewFakeRecorder(1)
+	r := &AutoscalingRunnerSetReconciler{
+		Client:        crfake.NewClientBuilder().WithScheme(scheme).Build(),
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+		Recorder:      recorder,
+	}
+
+	require.NoError(t, r.deleteRunnerScaleSet(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	actionsClient.AssertNotCalled(t, "DeleteRunnerScaleSet", mock.Anything, mock.Anything)
+
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "RunnerScaleSetRetained")
+}
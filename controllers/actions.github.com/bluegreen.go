@@ -0,0 +1,107 @@
+package actionsgithubcom
+
+import (
+	"context"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const defaultBlueGreenMinReadyPercent = "100%"
+
+// blueGreenStrategy returns the blue/green settings of the autoscaling runner set,
+// or nil when runner spec changes are not rolled out blue/green.
+func blueGreenStrategy(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *v1alpha1.BlueGreenStrategy {
+	strategy := autoscalingRunnerSet.Spec.UpdateStrategy
+	if strategy == nil || strategy.Type != v1alpha1.BlueGreenUpdateStrategyType {
+		return nil
+	}
+	if strategy.BlueGreen == nil {
+		return &v1alpha1.BlueGreenStrategy{}
+	}
+	return strategy.BlueGreen
+}
+
+// blueGreenMinReadyRunners returns the number of running runners the latest runner set needs
+// before it replaces the old ones.
+func blueGreenMinReadyRunners(strategy *v1alpha1.BlueGreenStrategy, desired int) int {
+	minReady := strategy.MinReadyRunners
+	if minReady == nil {
+		defaultValue := intstr.FromString(defaultBlueGreenMinReadyPercent)
+		minReady = &defaultValue
+	}
+	runners, err := intstr.GetScaledValueFromIntOrPercent(minReady, desired, true)
+	if err != nil || runners > desired {
+		runners = desired
+	}
+	if runners < 0 {
+		runners = 0
+	}
+	return runners
+}
+
+// cutOverBlueGreen keeps an old runner set serving jobs alongside the latest one, until enough runners
+// of the latest runner set are running. The old runner sets are then deleted at once, leaving the desired
+// runners to the latest runner set. The other old runner sets, such as a previous latest runner set that
+// never got ready, are deleted right away.
+func (r *AutoscalingRunnerSetReconciler) cutOverBlueGreen(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, strategy *v1alpha1.BlueGreenStrategy, latestRunnerSet *v1alpha1.EphemeralRunnerSet, oldRunnerSets []v1alpha1.EphemeralRunnerSet, logger logr.Logger) error {
+	desired := latestRunnerSet.Spec.Replicas
+	if autoscalingRunnerSet.Spec.MinRunners != nil && *autoscalingRunnerSet.Spec.MinRunners > desired {
+		desired = *autoscalingRunnerSet.Spec.MinRunners
+	}
+	minReady := blueGreenMinReadyRunners(strategy, desired)
+
+	logger.Info("Blue/green rollout progress",
+		"desired", desired,
+		"latestRunning", latestRunnerSet.Status.RunningReplicas,
+		"minReadyRunners", minReady,
+	)
+
+	if latestRunnerSet.Status.RunningReplicas >= minReady {
+		if !hasServingRunnerSet(oldRunnerSets) {
+			return nil
+		}
+		logger.Info("Latest runner set is ready. Replacing the old runner sets", "name", latestRunnerSet.Name, "count", len(oldRunnerSets))
+		if err := r.deleteEphemeralRunnerSets(ctx, oldRunnerSets, logger); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(
+			autoscalingRunnerSet,
+			corev1.EventTypeNormal,
+			"BlueGreenCutOver",
+			"Runner set %s replaced the old runner sets with %d running runners",
+			latestRunnerSet.Name,
+			latestRunnerSet.Status.RunningReplicas,
+		)
+		return nil
+	}
+
+	// The old runner set with the most running runners keeps serving, the newest one on a tie.
+	// Old runner sets are sorted newest first.
+	var serving *v1alpha1.EphemeralRunnerSet
+	for i := range oldRunnerSets {
+		rs := &oldRunnerSets[i]
+		if rs.DeletionTimestamp.IsZero() && (serving == nil || rs.Status.RunningReplicas > serving.Status.RunningReplicas) {
+			serving = rs
+		}
+	}
+	var superseded []v1alpha1.EphemeralRunnerSet
+	for i := range oldRunnerSets {
+		if rs := &oldRunnerSets[i]; rs != serving {
+			superseded = append(superseded, *rs)
+		}
+	}
+
+	if serving != nil {
+		if err := r.followLatestRunnerSet(ctx, serving, latestRunnerSet, nil); err != nil {
+			return err
+		}
+	}
+	if len(superseded) > 0 {
+		logger.Info("Cleanup superseded ephemeral runner sets", "count", len(superseded))
+		return r.deleteEphemeralRunnerSets(ctx, superseded, logger)
+	}
+	return nil
+}
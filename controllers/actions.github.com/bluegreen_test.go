@@ -0,0 +1,97 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestBlueGreenMinReadyRunners(t *testing.T) {
+	half := intstr.FromString("50%")
+	many := intstr.FromInt(20)
+
+	assert.Equal(t, 10, blueGreenMinReadyRunners(&v1alpha1.BlueGreenStrategy{}, 10))
+	assert.Equal(t, 5, blueGreenMinReadyRunners(&v1alpha1.BlueGreenStrategy{MinReadyRunners: &half}, 9))
+	assert.Equal(t, 10, blueGreenMinReadyRunners(&v1alpha1.BlueGreenStrategy{MinReadyRunners: &many}, 10), "Capped to the desired runners")
+	assert.Equal(t, 0, blueGreenMinReadyRunners(&v1alpha1.BlueGreenStrategy{}, 0))
+}
+
+func TestCutOverBlueGreen(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			UpdateStrategy: &v1alpha1.UpdateStrategy{Type: v1alpha1.BlueGreenUpdateStrategyType},
+		},
+	}
+	newRunnerSets := func(latestRunning int) (*v1alpha1.EphemeralRunnerSet, []v1alpha1.EphemeralRunnerSet) {
+		green := &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "arc-green", Namespace: "default"},
+			Spec: v1alpha1.EphemeralRunnerSetSpec{
+				Replicas:        6,
+				VariantReplicas: map[string]int{"large": 2},
+			},
+			Status: v1alpha1.EphemeralRunnerSetStatus{RunningReplicas: latestRunning},
+		}
+		old := []v1alpha1.EphemeralRunnerSet{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "arc-unready", Namespace: "default"},
+				Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 6},
+				Status:     v1alpha1.EphemeralRunnerSetStatus{RunningReplicas: 1},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "arc-blue", Namespace: "default"},
+				Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 4},
+				Status:     v1alpha1.EphemeralRunnerSetStatus{RunningReplicas: 4},
+			},
+		}
+		return green, old
+	}
+
+	t.Run("keeps the old runner set serving until the latest one is ready", func(t *testing.T) {
+		green, old := newRunnerSets(5)
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, green, &old[0], &old[1]).Build()
+		r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+		ctx := context.Background()
+
+		require.NoError(t, r.cutOverBlueGreen(ctx, autoscalingRunnerSet, blueGreenStrategy(autoscalingRunnerSet), green, old, logr.Discard()))
+
+		blue := new(v1alpha1.EphemeralRunnerSet)
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-blue"}, blue))
+		assert.Equal(t, 6, blue.Spec.Replicas, "The serving runner set follows the desired runners")
+		assert.Equal(t, map[string]int{"large": 2}, blue.Spec.VariantReplicas)
+		assert.Nil(t, blue.Spec.MaxReplicas)
+		assert.True(t, kerrors.IsNotFound(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-unready"}, blue)))
+	})
+
+	t.Run("replaces the old runner sets once the latest one is ready", func(t *testing.T) {
+		green, old := newRunnerSets(6)
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, green, &old[0], &old[1]).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+		ctx := context.Background()
+
+		require.NoError(t, r.cutOverBlueGreen(ctx, autoscalingRunnerSet, blueGreenStrategy(autoscalingRunnerSet), green, old, logr.Discard()))
+
+		list := new(v1alpha1.EphemeralRunnerSetList)
+		require.NoError(t, c.List(ctx, list))
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, "arc-green", list.Items[0].Name)
+		assert.Contains(t, <-recorder.Events, "BlueGreenCutOver")
+	})
+}
@@ -0,0 +1,224 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultCanaryWeight           = "10%"
+	defaultCanarySuccessfulJobs   = 3
+	defaultCanaryFailureThreshold = 1
+)
+
+// canaryStrategy returns the canary settings of the autoscaling runner set,
+// or nil when runner spec changes are not rolled out by canary.
+func canaryStrategy(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *v1alpha1.CanaryStrategy {
+	strategy := autoscalingRunnerSet.Spec.UpdateStrategy
+	if strategy == nil || strategy.Type != v1alpha1.CanaryUpdateStrategyType {
+		return nil
+	}
+	if strategy.Canary == nil {
+		return &v1alpha1.CanaryStrategy{}
+	}
+	return strategy.Canary
+}
+
+// canaryRolledBack reports whether the canary of the given runner spec hash was rolled back,
+// in which case the runner spec is not rolled out again.
+func canaryRolledBack(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, specHash string) bool {
+	status := autoscalingRunnerSet.Status.Canary
+	return status != nil && status.SpecHash == specHash && status.Phase == v1alpha1.CanaryPhaseRolledBack
+}
+
+// canaryReplicas returns the number of the desired runners the canary runner set may have.
+// The canary always gets a runner, so that it can be evaluated.
+func canaryReplicas(strategy *v1alpha1.CanaryStrategy, desired int) int {
+	weight := strategy.Weight
+	if weight == nil {
+		defaultValue := intstr.FromString(defaultCanaryWeight)
+		weight = &defaultValue
+	}
+	replicas, err := intstr.GetScaledValueFromIntOrPercent(weight, desired, true)
+	if err != nil || replicas < 1 {
+		replicas = 1
+	}
+	return replicas
+}
+
+func canarySuccessfulJobs(strategy *v1alpha1.CanaryStrategy) int {
+	if strategy.SuccessfulJobs != nil && *strategy.SuccessfulJobs > 0 {
+		return *strategy.SuccessfulJobs
+	}
+	return defaultCanarySuccessfulJobs
+}
+
+func canaryFailureThreshold(strategy *v1alpha1.CanaryStrategy) int {
+	if strategy.FailureThreshold != nil && *strategy.FailureThreshold > 0 {
+		return *strategy.FailureThreshold
+	}
+	return defaultCanaryFailureThreshold
+}
+
+// rollOutCanary evaluates the latest runner set against the old ones. Until the runners of the latest
+// runner set complete enough jobs successfully, it gets the canary share of the desired runners, and the
+// newest old runner set the rest. It is then promoted, deleting the old runner sets, or deleted once its
+// runners fail, leaving the old runner sets in place.
+func (r *AutoscalingRunnerSetReconciler) rollOutCanary(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, strategy *v1alpha1.CanaryStrategy, latestRunnerSet *v1alpha1.EphemeralRunnerSet, oldRunnerSets []v1alpha1.EphemeralRunnerSet, logger logr.Logger) error {
+	now := metav1.Now()
+	previous := autoscalingRunnerSet.Status.Canary
+	status := &v1alpha1.CanaryStatus{
+		SpecHash:               latestRunnerSet.Labels[LabelKeyRunnerSpecHash],
+		EphemeralRunnerSetName: latestRunnerSet.Name,
+		Phase:                  v1alpha1.CanaryPhaseProgressing,
+		LastTransitionTime:     &now,
+	}
+	if previous != nil && previous.EphemeralRunnerSetName == latestRunnerSet.Name {
+		status.Phase = previous.Phase
+		status.LastTransitionTime = previous.LastTransitionTime
+	}
+	status.SucceededJobs = latestRunnerSet.Status.SucceededJobs
+	status.Failures = latestRunnerSet.Status.FailedJobs + latestRunnerSet.Status.FailedReplicas
+
+	switch {
+	case status.Phase == v1alpha1.CanaryPhasePromoted:
+		if err := r.deleteEphemeralRunnerSets(ctx, oldRunnerSets, logger); err != nil {
+			return err
+		}
+
+	case status.Failures >= canaryFailureThreshold(strategy):
+		logger.Info("Canary runner set failed. Rolling it back", "name", latestRunnerSet.Name, "failures", status.Failures)
+		if err := r.deleteEphemeralRunnerSets(ctx, []v1alpha1.EphemeralRunnerSet{*latestRunnerSet}, logger); err != nil {
+			return err
+		}
+		status.Phase = v1alpha1.CanaryPhaseRolledBack
+		status.LastTransitionTime = &now
+		r.Recorder.Eventf(
+			autoscalingRunnerSet,
+			corev1.EventTypeWarning,
+			"CanaryRolledBack",
+			"Canary runner set %s was rolled back after %d failures. The runner spec is rolled out again once it changes.",
+			latestRunnerSet.Name,
+			status.Failures,
+		)
+
+	case status.SucceededJobs >= canarySuccessfulJobs(strategy):
+		logger.Info("Canary runner set completed enough jobs. Promoting it", "name", latestRunnerSet.Name, "succeededJobs", status.SucceededJobs)
+		if latestRunnerSet.Spec.MaxReplicas != nil {
+			if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+				obj.Spec.MaxReplicas = nil
+			}); err != nil {
+				return fmt.Errorf("failed to remove the replica limit of the canary runner set: %v", err)
+			}
+		}
+		if err := r.deleteEphemeralRunnerSets(ctx, oldRunnerSets, logger); err != nil {
+			return err
+		}
+		status.Phase = v1alpha1.CanaryPhasePromoted
+		status.LastTransitionTime = &now
+		r.Recorder.Eventf(
+			autoscalingRunnerSet,
+			corev1.EventTypeNormal,
+			"CanaryPromoted",
+			"Canary runner set %s was promoted after %d successful jobs",
+			latestRunnerSet.Name,
+			status.SucceededJobs,
+		)
+
+	default:
+		var superseded string
+		if previous != nil && previous.Phase == v1alpha1.CanaryPhaseProgressing {
+			superseded = previous.EphemeralRunnerSetName
+		}
+		if err := r.splitCanaryReplicas(ctx, autoscalingRunnerSet, strategy, latestRunnerSet, oldRunnerSets, superseded, logger); err != nil {
+			return err
+		}
+	}
+
+	if previous != nil && reflect.DeepEqual(*previous, *status) {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		obj.Status.Canary = status
+	})
+}
+
+// splitCanaryReplicas limits the canary runner set to its share of the desired runners, and scales the newest
+// old runner set to the rest of them. The other old runner sets, such as a canary superseded by a newer
+// runner spec, are drained and deleted.
+func (r *AutoscalingRunnerSetReconciler) splitCanaryReplicas(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, strategy *v1alpha1.CanaryStrategy, latestRunnerSet *v1alpha1.EphemeralRunnerSet, oldRunnerSets []v1alpha1.EphemeralRunnerSet, superseded string, logger logr.Logger) error {
+	desired := latestRunnerSet.Spec.Replicas
+	if autoscalingRunnerSet.Spec.MinRunners != nil && *autoscalingRunnerSet.Spec.MinRunners > desired {
+		desired = *autoscalingRunnerSet.Spec.MinRunners
+	}
+	canaryMax := canaryReplicas(strategy, desired)
+	stableMax := desired - canaryMax
+	if stableMax < 0 {
+		stableMax = 0
+	}
+
+	logger.Info("Canary rollout progress",
+		"desired", desired,
+		"canaryMaxReplicas", canaryMax,
+		"stableMaxReplicas", stableMax,
+		"succeededJobs", latestRunnerSet.Status.SucceededJobs,
+	)
+
+	if latestRunnerSet.Spec.MaxReplicas == nil || *latestRunnerSet.Spec.MaxReplicas != canaryMax {
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.MaxReplicas = &canaryMax
+		}); err != nil {
+			return fmt.Errorf("failed to limit replicas of the canary runner set: %v", err)
+		}
+	}
+
+	// Old runner sets are sorted newest first.
+	stable := -1
+	for i := range oldRunnerSets {
+		if oldRunnerSets[i].DeletionTimestamp.IsZero() && oldRunnerSets[i].Name != superseded {
+			stable = i
+			break
+		}
+	}
+
+	var drained []v1alpha1.EphemeralRunnerSet
+	for i := range oldRunnerSets {
+		rs := &oldRunnerSets[i]
+		if !rs.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if i == stable {
+			if err := r.followLatestRunnerSet(ctx, rs, latestRunnerSet, &stableMax); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if rs.Spec.MaxReplicas != nil && *rs.Spec.MaxReplicas == 0 {
+			if rs.Status.CurrentReplicas == 0 {
+				drained = append(drained, *rs)
+			}
+			continue
+		}
+		logger.Info("Draining old ephemeral runner set", "name", rs.Name)
+		if err := patch(ctx, r.Client, rs, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.MaxReplicas = new(int)
+		}); err != nil {
+			return fmt.Errorf("failed to drain old runner set %s: %v", rs.Name, err)
+		}
+	}
+
+	if len(drained) > 0 {
+		logger.Info("Cleanup drained ephemeral runner sets", "count", len(drained))
+		return r.deleteEphemeralRunnerSets(ctx, drained, logger)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCanaryReplicas(t *testing.T) {
+	weight := intstr.FromInt(2)
+	tests := map[string]struct {
+		strategy v1alpha1.CanaryStrategy
+		desired  int
+		want     int
+	}{
+		"default weight rounds up":          {desired: 15, want: 2},
+		"default weight with few runners":   {desired: 3, want: 1},
+		"at least one runner":               {desired: 0, want: 1},
+		"number of runners":                 {strategy: v1alpha1.CanaryStrategy{Weight: &weight}, desired: 10, want: 2},
+		"percentage of the desired runners": {strategy: v1alpha1.CanaryStrategy{Weight: intstrPtr(intstr.FromString("50%"))}, desired: 5, want: 3},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, canaryReplicas(&tc.strategy, tc.desired))
+		})
+	}
+}
+
+func intstrPtr(v intstr.IntOrString) *intstr.IntOrString {
+	return &v
+}
+
+func TestRollOutCanary(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	newObjects := func(canaryStatus v1alpha1.EphemeralRunnerSetStatus) (*v1alpha1.AutoscalingRunnerSet, *v1alpha1.EphemeralRunnerSet, []v1alpha1.EphemeralRunnerSet) {
+		autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default"},
+			Spec: v1alpha1.AutoscalingRunnerSetSpec{
+				UpdateStrategy: &v1alpha1.UpdateStrategy{Type: v1alpha1.CanaryUpdateStrategyType},
+			},
+		}
+		canary := &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "arc-canary",
+				Namespace:         "default",
+				Labels:            map[string]string{LabelKeyRunnerSpecHash: "new"},
+				CreationTimestamp: metav1.NewTime(time.Now()),
+			},
+			Spec:   v1alpha1.EphemeralRunnerSetSpec{Replicas: 10, MaxReplicas: new(int)},
+			Status: canaryStatus,
+		}
+		stableMax := 10
+		old := []v1alpha1.EphemeralRunnerSet{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "arc-stable",
+					Namespace:         "default",
+					Labels:            map[string]string{LabelKeyRunnerSpecHash: "old"},
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+				},
+				Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 4, MaxReplicas: &stableMax},
+			},
+		}
+		return autoscalingRunnerSet, canary, old
+	}
+
+	newReconciler := func(objs ...client.Object) (*AutoscalingRunnerSetReconciler, client.Client) {
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+		return &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}, c
+	}
+
+	t.Run("splits the runners while the canary is evaluated", func(t *testing.T) {
+		autoscalingRunnerSet, canary, old := newObjects(v1alpha1.EphemeralRunnerSetStatus{SucceededJobs: 2})
+		r, c := newReconciler(autoscalingRunnerSet, canary, &old[0])
+		ctx := context.Background()
+
+		require.NoError(t, r.rollOutCanary(ctx, autoscalingRunnerSet, canaryStrategy(autoscalingRunnerSet), canary, old, logr.Discard()))
+
+		got := new(v1alpha1.EphemeralRunnerSet)
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-canary"}, got))
+		assert.Equal(t, 1, *got.Spec.MaxReplicas)
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-stable"}, got))
+		assert.Equal(t, 10, got.Spec.Replicas, "The stable runner set follows the desired runners")
+		assert.Equal(t, 9, *got.Spec.MaxReplicas)
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+		require.NotNil(t, autoscalingRunnerSet.Status.Canary)
+		assert.Equal(t, v1alpha1.CanaryPhaseProgressing, autoscalingRunnerSet.Status.Canary.Phase)
+		assert.Equal(t, "new", autoscalingRunnerSet.Status.Canary.SpecHash)
+		assert.Equal(t, "arc-canary", autoscalingRunnerSet.Status.Canary.EphemeralRunnerSetName)
+		assert.Equal(t, 2, autoscalingRunnerSet.Status.Canary.SucceededJobs)
+	})
+
+	t.Run("promotes the canary after successful jobs", func(t *testing.T) {
+		autoscalingRunnerSet, canary, old := newObjects(v1alpha1.EphemeralRunnerSetStatus{SucceededJobs: 3})
+		r, c := newReconciler(autoscalingRunnerSet, canary, &old[0])
+		ctx := context.Background()
+
+		require.NoError(t, r.rollOutCanary(ctx, autoscalingRunnerSet, canaryStrategy(autoscalingRunnerSet), canary, old, logr.Discard()))
+
+		got := new(v1alpha1.EphemeralRunnerSet)
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-canary"}, got))
+		assert.Nil(t, got.Spec.MaxReplicas)
+		assert.True(t, kerrors.IsNotFound(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-stable"}, got)))
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+		assert.Equal(t, v1alpha1.CanaryPhasePromoted, autoscalingRunnerSet.Status.Canary.Phase)
+	})
+
+	t.Run("rolls back the canary on failures", func(t *testing.T) {
+		autoscalingRunnerSet, canary, old := newObjects(v1alpha1.EphemeralRunnerSetStatus{SucceededJobs: 5, FailedReplicas: 1})
+		r, c := newReconciler(autoscalingRunnerSet, canary, &old[0])
+		ctx := context.Background()
+
+		require.NoError(t, r.rollOutCanary(ctx, autoscalingRunnerSet, canaryStrategy(autoscalingRunnerSet), canary, old, logr.Discard()))
+
+		got := new(v1alpha1.EphemeralRunnerSet)
+		assert.True(t, kerrors.IsNotFound(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-canary"}, got)))
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-stable"}, got))
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+		assert.Equal(t, v1alpha1.CanaryPhaseRolledBack, autoscalingRunnerSet.Status.Canary.Phase)
+		assert.Equal(t, 1, autoscalingRunnerSet.Status.Canary.Failures)
+		assert.True(t, canaryRolledBack(autoscalingRunnerSet, "new"))
+		assert.False(t, canaryRolledBack(autoscalingRunnerSet, "newer"))
+	})
+}
@@ -2,7 +2,12 @@ package actionsgithubcom
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -30,3 +35,45 @@ func patchSubResource[T object[T]](ctx context.Context, client subResourcePatche
 	update(obj)
 	return client.Patch(ctx, obj, kclient.MergeFrom(original))
 }
+
+// actionsClientOptions returns the options that configure the actions client
+// with the proxy and TLS settings of a scale set, falling back to the default TLS settings.
+func actionsClientOptions(ctx context.Context, client kclient.Reader, namespace string, proxy *v1alpha1.ProxyConfig, tls *v1alpha1.GitHubServerTLSConfig, defaultTLS *DefaultGitHubServerTLS) ([]actions.ClientOption, error) {
+	var options []actions.ClientOption
+
+	if proxy != nil {
+		proxyConfig, err := proxy.ToHTTPProxyConfig(func(name string) (*corev1.Secret, error) {
+			secret := new(corev1.Secret)
+			if err := client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+				return nil, err
+			}
+			return secret, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get proxy config: %w", err)
+		}
+		options = append(options, actions.WithProxy(proxyConfig))
+	}
+
+	tls, tlsNamespace := gitHubServerTLSFor(tls, namespace, defaultTLS)
+	if tls != nil && tls.RootCAsConfigMapRef != "" {
+		configMap := new(corev1.ConfigMap)
+		if err := client.Get(ctx, types.NamespacedName{Namespace: tlsNamespace, Name: tls.RootCAsConfigMapRef}, configMap); err != nil {
+			return nil, fmt.Errorf("failed to get GitHub server TLS config map: %w", err)
+		}
+
+		key := gitHubServerTLSCertKey(tls)
+		cert, ok := configMap.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("GitHub server TLS config map %q has no key %q", tls.RootCAsConfigMapRef, key)
+		}
+
+		rootCAs, err := actions.RootCAsFromConfigMap(map[string][]byte{key: []byte(cert)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load GitHub server root CAs: %w", err)
+		}
+		options = append(options, actions.WithRootCAs(rootCAs))
+	}
+
+	return options, nil
+}
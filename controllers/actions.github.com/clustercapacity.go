@@ -0,0 +1,247 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterCapacityCheckInterval is how often the schedulable capacity of the cluster is computed for the
+// AutoscalingRunnerSets capping their runners to it. Every check lists the pods of all namespaces.
+const clusterCapacityCheckInterval = 30 * time.Second
+
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+
+// reconcileClusterCapacity caps the latest runner set to the runners the cluster has capacity for, and reports
+// the runners above it in the status. It returns when to compute the capacity again.
+func (r *AutoscalingRunnerSetReconciler) reconcileClusterCapacity(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) (time.Duration, error) {
+	key := client.ObjectKeyFromObject(autoscalingRunnerSet)
+	capacity := autoscalingRunnerSet.Spec.ClusterCapacity
+	if capacity == nil || !capacity.Enabled {
+		r.forgetClusterCapacityCheck(key)
+		return 0, r.updateClusterCapacity(ctx, autoscalingRunnerSet, latestRunnerSet, nil, 0, log)
+	}
+
+	if !r.clusterCapacityCheckDue(key) {
+		return clusterCapacityCheckInterval, nil
+	}
+
+	nodes := new(corev1.NodeList)
+	if err := r.List(ctx, nodes); err != nil {
+		return 0, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	pods := new(corev1.PodList)
+	if err := reader.List(ctx, pods); err != nil {
+		return 0, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	template := latestRunnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec.Spec.DeepCopy()
+	prependInitContainers(template, latestRunnerSet.Spec.EphemeralRunnerSpec.InitContainers)
+	schedulable := schedulableRunners(nodes.Items, pods.Items, template)
+
+	// The runners that exist are kept, even the pending ones the cluster has no capacity for.
+	limit := latestRunnerSet.Status.RunningReplicas + schedulable
+	if capacity.UnschedulableRunners != nil {
+		limit += *capacity.UnschedulableRunners
+	}
+	if limit < latestRunnerSet.Status.CurrentReplicas {
+		limit = latestRunnerSet.Status.CurrentReplicas
+	}
+
+	uncapped := *latestRunnerSet
+	uncapped.Spec.CapacityReplicas = nil
+	clamped := desiredReplicas(&uncapped, latestRunnerSet.Spec.Replicas) - limit
+	if clamped < 0 {
+		clamped = 0
+	}
+
+	return clusterCapacityCheckInterval, r.updateClusterCapacity(ctx, autoscalingRunnerSet, latestRunnerSet, &limit, clamped, log)
+}
+
+func (r *AutoscalingRunnerSetReconciler) updateClusterCapacity(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet, limit *int, clamped int, log logr.Logger) error {
+	if !reflect.DeepEqual(latestRunnerSet.Spec.CapacityReplicas, limit) {
+		log.Info("Updating the cluster capacity of the latest runner set", "name", latestRunnerSet.Name, "capacityReplicas", limit)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.CapacityReplicas = limit
+		}); err != nil {
+			return fmt.Errorf("failed to update the cluster capacity of the latest runner set: %v", err)
+		}
+	}
+
+	if autoscalingRunnerSet.Status.CapacityClampedRunners != clamped {
+		if clamped > 0 {
+			log.Info("Runners are capped to the cluster capacity", "clampedRunners", clamped)
+		}
+		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.CapacityClampedRunners = clamped
+		}); err != nil {
+			return fmt.Errorf("failed to update the clamped runners: %v", err)
+		}
+	}
+	return nil
+}
+
+// clusterCapacityCheckDue reports whether the cluster capacity should be computed for the given
+// AutoscalingRunnerSet, and if so records the current time as the last check.
+func (r *AutoscalingRunnerSetReconciler) clusterCapacityCheckDue(key types.NamespacedName) bool {
+	r.lastClusterCapacityCheckMu.Lock()
+	defer r.lastClusterCapacityCheckMu.Unlock()
+
+	if r.lastClusterCapacityCheck == nil {
+		r.lastClusterCapacityCheck = make(map[types.NamespacedName]time.Time)
+	}
+
+	now := time.Now()
+	if last, ok := r.lastClusterCapacityCheck[key]; ok && now.Sub(last) < clusterCapacityCheckInterval {
+		return false
+	}
+
+	r.lastClusterCapacityCheck[key] = now
+	return true
+}
+
+func (r *AutoscalingRunnerSetReconciler) forgetClusterCapacityCheck(key types.NamespacedName) {
+	r.lastClusterCapacityCheckMu.Lock()
+	defer r.lastClusterCapacityCheckMu.Unlock()
+
+	delete(r.lastClusterCapacityCheck, key)
+}
+
+// schedulableRunners returns how many more pods of the template fit on the nodes they can be scheduled on,
+// given the requests of the pods already bound to the nodes.
+func schedulableRunners(nodes []corev1.Node, pods []corev1.Pod, template *corev1.PodSpec) int {
+	requested := make(map[string]corev1.ResourceList)
+	podCount := make(map[string]int64)
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		podCount[pod.Spec.NodeName]++
+		list, ok := requested[pod.Spec.NodeName]
+		if !ok {
+			list = corev1.ResourceList{}
+			requested[pod.Spec.NodeName] = list
+		}
+		for name, quantity := range podRequests(&pod.Spec) {
+			sum := list[name]
+			sum.Add(quantity)
+			list[name] = sum
+		}
+	}
+
+	runnerRequests := podRequests(template)
+	total := 0
+	for i := range nodes {
+		node := &nodes[i]
+		if !nodeAcceptsRunners(node, template) {
+			continue
+		}
+
+		fit := int64(-1)
+		if pods, ok := node.Status.Allocatable[corev1.ResourcePods]; ok {
+			fit = pods.Value() - podCount[node.Name]
+		}
+		for name, request := range runnerRequests {
+			if request.IsZero() {
+				continue
+			}
+			allocatable, ok := node.Status.Allocatable[name]
+			if !ok {
+				fit = 0
+				break
+			}
+			free := allocatable.DeepCopy()
+			free.Sub(requested[node.Name][name])
+			n := free.MilliValue() / request.MilliValue()
+			if fit < 0 || n < fit {
+				fit = n
+			}
+		}
+		if fit > 0 {
+			total += int(fit)
+		}
+	}
+	return total
+}
+
+// podRequests returns the resources the scheduler reserves for the pod: the requests of its containers,
+// or of its largest init container, plus the pod overhead.
+func podRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, c := range spec.Containers {
+		for name, quantity := range c.Resources.Requests {
+			sum := requests[name]
+			sum.Add(quantity)
+			requests[name] = sum
+		}
+	}
+	for _, c := range spec.InitContainers {
+		for name, quantity := range c.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	for name, quantity := range spec.Overhead {
+		sum := requests[name]
+		sum.Add(quantity)
+		requests[name] = sum
+	}
+	return requests
+}
+
+// nodeAcceptsRunners reports whether pods of the template can be scheduled on the node, as far as its
+// readiness, node selector and taints go. The node affinity of the template is not taken into account.
+func nodeAcceptsRunners(node *corev1.Node, template *corev1.PodSpec) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+
+	ready := false
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	if !ready {
+		return false
+	}
+
+	for key, value := range template.NodeSelector {
+		if node.Labels[key] != value {
+			return false
+		}
+	}
+
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range template.Tolerations {
+			if template.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
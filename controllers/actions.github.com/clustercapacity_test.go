@@ -0,0 +1,172 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newCapacityTestNode(name string, labels map[string]string, cpu, memory string, pods int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+				corev1.ResourcePods:   *resource.NewQuantity(pods, resource.DecimalSI),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func newCapacityTestPod(name, nodeName, cpu, memory string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Name: "main",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestSchedulableRunners(t *testing.T) {
+	runners := map[string]string{"pool": "runners"}
+	template := &corev1.PodSpec{
+		NodeSelector: runners,
+		Tolerations:  []corev1.Toleration{{Key: "runners", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+		Containers: []corev1.Container{{
+			Name: "runner",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			}},
+		}},
+	}
+
+	tainted := newCapacityTestNode("tainted", runners, "4", "16Gi", 110)
+	tainted.Spec.Taints = []corev1.Taint{{Key: "runners", Effect: corev1.TaintEffectNoSchedule}}
+	untolerated := newCapacityTestNode("untolerated", runners, "4", "16Gi", 110)
+	untolerated.Spec.Taints = []corev1.Taint{{Key: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	cordoned := newCapacityTestNode("cordoned", runners, "4", "16Gi", 110)
+	cordoned.Spec.Unschedulable = true
+	notReady := newCapacityTestNode("not-ready", runners, "4", "16Gi", 110)
+	notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+
+	nodes := []corev1.Node{
+		// 4 CPUs, of which 1.5 are requested: 2 runners fit.
+		*newCapacityTestNode("cpu-bound", runners, "4", "16Gi", 110),
+		// 8Gi, of which 5Gi are requested: 1 runner fits.
+		*newCapacityTestNode("memory-bound", runners, "8", "8Gi", 110),
+		// 3 pods at most, of which 2 run: 1 runner fits.
+		*newCapacityTestNode("pods-bound", runners, "8", "16Gi", 3),
+		// Only the tolerated taint: 4 runners fit.
+		*tainted,
+		*untolerated,
+		*cordoned,
+		*notReady,
+		*newCapacityTestNode("other-pool", nil, "8", "16Gi", 110),
+	}
+
+	finished := newCapacityTestPod("finished", "cpu-bound", "2", "1Gi")
+	finished.Status.Phase = corev1.PodSucceeded
+	pods := []corev1.Pod{
+		*newCapacityTestPod("a", "cpu-bound", "1", "1Gi"),
+		*newCapacityTestPod("b", "cpu-bound", "500m", "1Gi"),
+		*finished,
+		*newCapacityTestPod("c", "memory-bound", "1", "5Gi"),
+		*newCapacityTestPod("d", "pods-bound", "100m", "100Mi"),
+		*newCapacityTestPod("e", "pods-bound", "100m", "100Mi"),
+		*newCapacityTestPod("pending", "", "4", "16Gi"),
+	}
+
+	assert.Equal(t, 8, schedulableRunners(nodes, pods, template))
+}
+
+func TestReconcileClusterCapacity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	unschedulable := 1
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ClusterCapacity: &v1alpha1.ClusterCapacity{Enabled: true, UnschedulableRunners: &unschedulable},
+		},
+	}
+	latestRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc-abcde", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			Replicas: 10,
+			EphemeralRunnerSpec: v1alpha1.EphemeralRunnerSpec{
+				PodTemplateSpec: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "runner",
+							Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("1"),
+							}},
+						}},
+					},
+				},
+			},
+		},
+		Status: v1alpha1.EphemeralRunnerSetStatus{CurrentReplicas: 3, RunningReplicas: 2},
+	}
+	// 2 runners are running on the node, which has room for 2 more.
+	node := newCapacityTestNode("node", nil, "4", "16Gi", 110)
+
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		autoscalingRunnerSet,
+		latestRunnerSet,
+		node,
+		newCapacityTestPod("runner-1", "node", "1", "1Gi"),
+		newCapacityTestPod("runner-2", "node", "1", "1Gi"),
+	).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	recheck, err := r.reconcileClusterCapacity(ctx, autoscalingRunnerSet, latestRunnerSet, logr.Discard())
+	require.NoError(t, err)
+	assert.Equal(t, clusterCapacityCheckInterval, recheck)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(latestRunnerSet), latestRunnerSet))
+	require.NotNil(t, latestRunnerSet.Spec.CapacityReplicas)
+	assert.Equal(t, 5, *latestRunnerSet.Spec.CapacityReplicas, "The running runners, the ones that fit and the unschedulable ones")
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	assert.Equal(t, 5, autoscalingRunnerSet.Status.CapacityClampedRunners)
+
+	autoscalingRunnerSet.Spec.ClusterCapacity = nil
+	_, err = r.reconcileClusterCapacity(ctx, autoscalingRunnerSet, latestRunnerSet, logr.Discard())
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(latestRunnerSet), latestRunnerSet))
+	assert.Nil(t, latestRunnerSet.Spec.CapacityReplicas)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	assert.Zero(t, autoscalingRunnerSet.Status.CapacityClampedRunners)
+}
+
+func TestDesiredReplicas_CapacityReplicas(t *testing.T) {
+	capacity := 4
+	runnerSet := &v1alpha1.EphemeralRunnerSet{Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 10, CapacityReplicas: &capacity}}
+	assert.Equal(t, 4, desiredReplicas(runnerSet, 0))
+}
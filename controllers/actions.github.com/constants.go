@@ -3,9 +3,109 @@ package actionsgithubcom
 const (
 	LabelKeyRunnerTemplateHash = "runner-template-hash"
 	LabelKeyPodTemplateHash    = "pod-template-hash"
+
+	LabelKeyRunnerTemplateVariant = "runner-template-variant"
+
+	// LabelKeyImagePrePullVariant is the label of the image pre-pull DaemonSets holding the template variant
+	// they're scheduled like, empty for the pod template.
+	LabelKeyImagePrePullVariant = "image-pre-pull-variant"
+)
+
+// Keys of the labels and annotations of the runner pods describing the job they run.
+// Labels hold the values trimmed to valid label values, and annotations the values as is.
+const (
+	JobMetadataKeyRepositoryOwner = "actions.github.com/repository-owner"
+	JobMetadataKeyRepositoryName  = "actions.github.com/repository-name"
+	JobMetadataKeyWorkflowName    = "actions.github.com/workflow-name"
+	JobMetadataKeyJobName         = "actions.github.com/job-name"
+	JobMetadataKeyWorkflowRunId   = "actions.github.com/workflow-run-id"
+	JobMetadataKeyRequester       = "actions.github.com/requester"
+
+	// Keys of the annotations only.
+	JobMetadataKeyWorkflowRef  = "actions.github.com/workflow-ref"
+	JobMetadataKeyJobRequestId = "actions.github.com/job-request-id"
+	JobMetadataKeyJobLabels    = "actions.github.com/job-labels"
 )
 
 const (
-	EnvVarRunnerJITConfig      = "ACTIONS_RUNNER_INPUT_JITCONFIG"
-	EnvVarRunnerExtraUserAgent = "GITHUB_ACTIONS_RUNNER_EXTRA_USER_AGENT"
+	// JobMetadataVolumeName is the downward API volume exposing the job metadata annotations
+	// of the runner pod to the runner container.
+	JobMetadataVolumeName = "job-metadata"
+	// JobMetadataMountPath is where the job metadata volume is mounted in the runner container.
+	JobMetadataMountPath = "/etc/actions-runner-controller/job"
+	// WindowsJobMetadataMountPath is where the job metadata volume is mounted in Windows runner pods.
+	WindowsJobMetadataMountPath = `C:\actions-runner-controller\job`
+)
+
+const (
+	// AnnotationKeyClusterAutoscalerSafeToEvict tells the cluster autoscaler whether
+	// it may evict a runner pod to scale down its node.
+	AnnotationKeyClusterAutoscalerSafeToEvict = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+
+	// AnnotationKeyGitHubConfigSecretHash is the hash of the GitHub config secret a listener pod was
+	// created with. The listener reads the secret once on start, so it is restarted when the hash changes.
+	AnnotationKeyGitHubConfigSecretHash = "actions.github.com/github-config-secret-hash"
+
+	// AnnotationKeyJobCompletionTimeout marks the runner pods that keep running their job for up to the
+	// job completion timeout, in seconds, once they are terminating.
+	AnnotationKeyJobCompletionTimeout = "actions.github.com/job-completion-timeout"
+
+	// AnnotationKeyQueuedWorkflowJobs holds the IDs of the jobs the workflow_job webhook saw queued for an
+	// AutoscalingRunnerSet, with when they were queued, in JSON. Every replica of the webhook server reads
+	// them to ignore the events GitHub redelivers for the same job.
+	AnnotationKeyQueuedWorkflowJobs = "actions.github.com/queued-workflow-jobs"
+
+	// AnnotationKeyRetainedUntil is when a failed runner pod retained for debugging is deleted.
+	AnnotationKeyRetainedUntil = "actions.github.com/retained-until"
+
+	// AnnotationKeyRunnerRefreshVersion is the runner release an EphemeralRunnerSet was created for
+	// when its idle runners are refreshed on new runner releases.
+	AnnotationKeyRunnerRefreshVersion = "actions.github.com/runner-refresh-version"
+	// LabelKeyRetainedFailedPod marks the failed runner pods retained for debugging, with the name of their runner set.
+	LabelKeyRetainedFailedPod = "actions.github.com/retained-failed-pod"
+
+	// LabelKeyRunnerNetworkPolicy selects the runner pods of the NetworkPolicy generated for their scale set, named by the label.
+	LabelKeyRunnerNetworkPolicy = "actions.github.com/runner-network-policy"
+)
+
+const (
+	EnvVarRunnerJITConfig        = "ACTIONS_RUNNER_INPUT_JITCONFIG"
+	EnvVarRunnerExtraUserAgent   = "GITHUB_ACTIONS_RUNNER_EXTRA_USER_AGENT"
+	EnvVarNodeExtraCACerts       = "NODE_EXTRA_CA_CERTS"
+	EnvVarSSLCertFile            = "SSL_CERT_FILE"
+	EnvVarJobMetadataDir         = "ARC_JOB_METADATA_DIR"
+	EnvVarRunnerHookJobStarted   = "ACTIONS_RUNNER_HOOK_JOB_STARTED"
+	EnvVarRunnerHookJobCompleted = "ACTIONS_RUNNER_HOOK_JOB_COMPLETED"
+	EnvVarContainerHookTemplate  = "ACTIONS_RUNNER_CONTAINER_HOOK_TEMPLATE"
+	EnvVarRunnerURL              = "RUNNER_URL"
+	EnvVarRunnerToken            = "RUNNER_TOKEN"
+	EnvVarRunnerName             = "RUNNER_NAME"
+	EnvVarRunnerGroup            = "RUNNER_GROUP"
+	EnvVarRunnerLabels           = "RUNNER_LABELS"
+)
+
+const (
+	DefaultGitHubServerTLSCertKey = "ca.crt"
+	GitHubServerTLSVolumeName     = "github-server-tls-cert"
+	GitHubServerTLSMountPath      = "/usr/local/share/ca-certificates/actions-runner-controller"
+)
+
+const (
+	RunnerHooksVolumeName = "runner-hooks"
+	RunnerHooksMountPath  = "/etc/actions-runner-controller/hooks"
+
+	DefaultContainerHookTemplateKey = "template.yaml"
+	ContainerHookTemplateVolumeName = "container-hook-template"
+	ContainerHookTemplateMountPath  = "/etc/actions-runner-controller/container-hook-template"
+)
+
+// DefaultImagePrePullPauseImage is the image keeping the pods of the image pre-pull DaemonSet running.
+const DefaultImagePrePullPauseImage = "registry.k8s.io/pause:3.9"
+
+// The init containers of the image pre-pull DaemonSet run a copy of the listener binary installed in a
+// shared volume, since the images may have no shell. See cmd/githubrunnerscalesetlistener/prepull.go.
+const (
+	imagePrePullListenerBinary  = "/github-runnerscaleset-listener"
+	imagePrePullToolsVolumeName = "pre-pull-tools"
+	imagePrePullToolsMountPath  = "/actions-runner-controller/pre-pull"
 )
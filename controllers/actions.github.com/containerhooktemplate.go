@@ -0,0 +1,212 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// containerHookJobContainerName is the name of the job container in the hook extension templates.
+const containerHookJobContainerName = "$job"
+
+// containerHookTemplate returns the hook extension template of the runners of the autoscaling runner set,
+// or nil unless they run in the kubernetes container mode with one. With job resources, it is the template
+// the controller generates.
+func containerHookTemplate(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *v1alpha1.ContainerHookTemplate {
+	mode := autoscalingRunnerSet.Spec.ContainerMode
+	if mode == nil || mode.Type != v1alpha1.ContainerModeTypeKubernetes {
+		return nil
+	}
+	if mode.JobResources != nil {
+		return &v1alpha1.ContainerHookTemplate{
+			ConfigMapRef: generatedContainerHookTemplateName(autoscalingRunnerSet),
+			ConfigMapKey: DefaultContainerHookTemplateKey,
+		}
+	}
+	if mode.HookTemplate == nil || mode.HookTemplate.ConfigMapRef == "" {
+		return nil
+	}
+	return mode.HookTemplate
+}
+
+func generatedContainerHookTemplateName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return autoscalingRunnerSet.Name + "-hook-template"
+}
+
+// validateJobResources returns an error when the job resources request more than their limits.
+func validateJobResources(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	mode := autoscalingRunnerSet.Spec.ContainerMode
+	if mode == nil || mode.JobResources == nil {
+		return nil
+	}
+	for name, request := range mode.JobResources.Requests {
+		if limit, ok := mode.JobResources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			return fmt.Errorf("job resources request %s of %s, above their limit of %s", request.String(), name, limit.String())
+		}
+	}
+	return nil
+}
+
+// applyJobResources sets the resources of the job container of the hook extension template: the requests
+// it lacks or that are above the limits default to the requests of the job resources, and the limits
+// are the ones of the job resources.
+func applyJobResources(template *corev1.PodTemplateSpec, resources *corev1.ResourceRequirements) {
+	var job *corev1.Container
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].Name == containerHookJobContainerName {
+			job = &template.Spec.Containers[i]
+		}
+	}
+	if job == nil {
+		template.Spec.Containers = append(template.Spec.Containers, corev1.Container{Name: containerHookJobContainerName})
+		job = &template.Spec.Containers[len(template.Spec.Containers)-1]
+	}
+
+	if job.Resources.Requests == nil && (len(resources.Requests) > 0 || len(resources.Limits) > 0) {
+		job.Resources.Requests = corev1.ResourceList{}
+	}
+	for name, request := range resources.Requests {
+		if _, ok := job.Resources.Requests[name]; !ok {
+			job.Resources.Requests[name] = request.DeepCopy()
+		}
+	}
+	if len(resources.Limits) == 0 {
+		return
+	}
+	if job.Resources.Limits == nil {
+		job.Resources.Limits = corev1.ResourceList{}
+	}
+	for name, limit := range resources.Limits {
+		job.Resources.Limits[name] = limit.DeepCopy()
+		if request, ok := job.Resources.Requests[name]; ok && request.Cmp(limit) > 0 {
+			job.Resources.Requests[name] = limit.DeepCopy()
+		}
+	}
+}
+
+// reconcileContainerHookTemplate generates the hook extension template of the runners of the kubernetes
+// container mode with job resources, from the hook template of the container mode, if any, and deletes
+// it once there are no job resources. Changes to the hook template are picked up the next time the
+// autoscaling runner set is reconciled.
+func (r *AutoscalingRunnerSetReconciler) reconcileContainerHookTemplate(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	configMap := new(corev1.ConfigMap)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: generatedContainerHookTemplateName(autoscalingRunnerSet)}, configMap); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get the generated hook template: %v", err)
+		}
+		configMap = nil
+	}
+
+	mode := autoscalingRunnerSet.Spec.ContainerMode
+	if mode == nil || mode.Type != v1alpha1.ContainerModeTypeKubernetes || mode.JobResources == nil {
+		if configMap == nil || !metav1.IsControlledBy(configMap, autoscalingRunnerSet) {
+			return nil
+		}
+		log.Info("Job resources are not set. Deleting the generated hook template", "name", configMap.Name)
+		if err := r.Delete(ctx, configMap); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete the generated hook template: %v", err)
+		}
+		return nil
+	}
+
+	template := new(corev1.PodTemplateSpec)
+	if base := mode.HookTemplate; base != nil && base.ConfigMapRef != "" {
+		source := new(corev1.ConfigMap)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: base.ConfigMapRef}, source); err != nil {
+			return fmt.Errorf("failed to get the hook template %s: %v", base.ConfigMapRef, err)
+		}
+		key := base.ConfigMapKey
+		if key == "" {
+			key = DefaultContainerHookTemplateKey
+		}
+		if err := yaml.Unmarshal([]byte(source.Data[key]), template); err != nil {
+			return fmt.Errorf("failed to parse the hook template %s: %v", base.ConfigMapRef, err)
+		}
+	}
+	applyJobResources(template, mode.JobResources)
+	data, err := yaml.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the generated hook template: %v", err)
+	}
+
+	if configMap == nil {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      generatedContainerHookTemplateName(autoscalingRunnerSet),
+				Namespace: autoscalingRunnerSet.Namespace,
+				Labels: map[string]string{
+					"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+					"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+				},
+			},
+			Data: map[string]string{DefaultContainerHookTemplateKey: string(data)},
+		}
+		if err := ctrl.SetControllerReference(autoscalingRunnerSet, configMap, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set controller reference: %v", err)
+		}
+		log.Info("Creating the generated hook template", "name", configMap.Name)
+		if err := r.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to create the generated hook template: %v", err)
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(configMap, autoscalingRunnerSet) || configMap.Data[DefaultContainerHookTemplateKey] == string(data) {
+		return nil
+	}
+	log.Info("Updating the generated hook template", "name", configMap.Name)
+	if err := patch(ctx, r.Client, configMap, func(obj *corev1.ConfigMap) {
+		obj.Data = map[string]string{DefaultContainerHookTemplateKey: string(data)}
+	}); err != nil {
+		return fmt.Errorf("failed to update the generated hook template: %v", err)
+	}
+	return nil
+}
+
+// addContainerHookTemplate mounts the hook extension template into the runner container and points the
+// container hooks at it. A template the pod template sets with the environment variable wins.
+func addContainerHookTemplate(pod *corev1.Pod, template *v1alpha1.ContainerHookTemplate) {
+	if template == nil {
+		return
+	}
+
+	key := template.ConfigMapKey
+	if key == "" {
+		key = DefaultContainerHookTemplateKey
+	}
+
+	volumes := make([]corev1.Volume, 0, len(pod.Spec.Volumes)+1)
+	volumes = append(volumes, pod.Spec.Volumes...)
+	pod.Spec.Volumes = append(volumes, corev1.Volume{
+		Name: ContainerHookTemplateVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: template.ConfigMapRef},
+				Items:                []corev1.KeyToPath{{Key: key, Path: key}},
+			},
+		},
+	})
+
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		mounts := make([]corev1.VolumeMount, 0, len(c.VolumeMounts)+1)
+		mounts = append(mounts, c.VolumeMounts...)
+		c.VolumeMounts = append(mounts, corev1.VolumeMount{
+			Name:      ContainerHookTemplateVolumeName,
+			MountPath: ContainerHookTemplateMountPath,
+			ReadOnly:  true,
+		})
+		c.Env = appendEnvIfMissing(c.Env, corev1.EnvVar{Name: EnvVarContainerHookTemplate, Value: ContainerHookTemplateMountPath + "/" + key})
+	}
+}
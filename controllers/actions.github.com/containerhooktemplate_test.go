@@ -0,0 +1,130 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestApplyJobResources(t *testing.T) {
+	resources := &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("2Gi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("4Gi"),
+		},
+	}
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: containerHookJobContainerName,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("500m"),
+							corev1.ResourceMemory: resource.MustParse("8Gi"),
+						},
+						Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("16")},
+					},
+				},
+			},
+		},
+	}
+
+	applyJobResources(template, resources)
+
+	require.Len(t, template.Spec.Containers, 1)
+	job := template.Spec.Containers[0].Resources
+	assert.Equal(t, "500m", job.Requests.Cpu().String(), "Requests of the hook template below the limits are kept")
+	assert.Equal(t, "4Gi", job.Requests.Memory().String(), "Requests of the hook template are capped by the limits")
+	assert.Equal(t, "2", job.Limits.Cpu().String())
+	assert.Equal(t, "4Gi", job.Limits.Memory().String())
+
+	template = new(corev1.PodTemplateSpec)
+	applyJobResources(template, resources)
+	require.Len(t, template.Spec.Containers, 1, "The job container is added to the template")
+	assert.Equal(t, "1", template.Spec.Containers[0].Resources.Requests.Cpu().String())
+}
+
+func TestValidateJobResources(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ContainerMode: &v1alpha1.ContainerMode{
+				Type: v1alpha1.ContainerModeTypeKubernetes,
+				JobResources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				},
+			},
+		},
+	}
+	assert.NoError(t, validateJobResources(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.ContainerMode.JobResources.Requests[corev1.ResourceCPU] = resource.MustParse("4")
+	assert.Error(t, validateJobResources(autoscalingRunnerSet))
+}
+
+func TestReconcileContainerHookTemplate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners", UID: "1234"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ContainerMode: &v1alpha1.ContainerMode{
+				Type:         v1alpha1.ContainerModeTypeKubernetes,
+				HookTemplate: &v1alpha1.ContainerHookTemplate{ConfigMapRef: "job-pod"},
+				JobResources: &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+				},
+			},
+		},
+	}
+	hookTemplate := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-pod", Namespace: "runners"},
+		Data: map[string]string{
+			DefaultContainerHookTemplateKey: "spec:\n  securityContext:\n    runAsNonRoot: true\n",
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, hookTemplate).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	require.NoError(t, r.reconcileContainerHookTemplate(ctx, autoscalingRunnerSet, logr.Discard()))
+
+	key := types.NamespacedName{Namespace: "runners", Name: "arc-hook-template"}
+	generated := new(corev1.ConfigMap)
+	require.NoError(t, c.Get(ctx, key, generated))
+	assert.True(t, metav1.IsControlledBy(generated, autoscalingRunnerSet))
+
+	template := new(corev1.PodTemplateSpec)
+	require.NoError(t, yaml.Unmarshal([]byte(generated.Data[DefaultContainerHookTemplateKey]), template))
+	require.NotNil(t, template.Spec.SecurityContext, "The hook template is kept")
+	assert.True(t, *template.Spec.SecurityContext.RunAsNonRoot)
+	require.Len(t, template.Spec.Containers, 1)
+	assert.Equal(t, "4Gi", template.Spec.Containers[0].Resources.Limits.Memory().String())
+
+	assert.Equal(t, &v1alpha1.ContainerHookTemplate{ConfigMapRef: "arc-hook-template", ConfigMapKey: DefaultContainerHookTemplateKey}, containerHookTemplate(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.ContainerMode.JobResources = nil
+	require.NoError(t, r.reconcileContainerHookTemplate(ctx, autoscalingRunnerSet, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, new(corev1.ConfigMap))))
+	assert.Equal(t, "job-pod", containerHookTemplate(autoscalingRunnerSet).ConfigMapRef)
+}
@@ -0,0 +1,286 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	psadmission "k8s.io/pod-security-admission/admission/api"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Keys of the controller config map.
+const (
+	ControllerConfigKeyListenerImage                = "listenerImage"
+	ControllerConfigKeyRunnerVersionCheckInterval   = "runnerVersionCheckInterval"
+	ControllerConfigKeyOrphanedRunnerSweepInterval  = "orphanedRunnerSweepInterval"
+	ControllerConfigKeyWorkVolumeClaimSweepInterval = "workVolumeClaimSweepInterval"
+	ControllerConfigKeyRemoteCleanupTimeout         = "remoteCleanupTimeout"
+	ControllerConfigKeyGlobalMaxRunners             = "globalMaxRunners"
+
+	// The default security contexts of the runner and listener pods and of their containers, in YAML or JSON.
+	ControllerConfigKeyDefaultPodSecurityContext       = "defaultPodSecurityContext"
+	ControllerConfigKeyDefaultContainerSecurityContext = "defaultContainerSecurityContext"
+
+	// The AdmissionConfiguration or PodSecurityConfiguration of the Pod Security admission of the cluster, in YAML or JSON.
+	ControllerConfigKeyPodSecurityAdmissionConfig = "podSecurityAdmissionConfig"
+)
+
+// ControllerSettings are the settings of the controllers that can be changed without restarting the manager.
+// Unset settings keep the values given by the flags of the controller.
+type ControllerSettings struct {
+	ListenerImage                string
+	RunnerVersionCheckInterval   *time.Duration
+	OrphanedRunnerSweepInterval  *time.Duration
+	WorkVolumeClaimSweepInterval *time.Duration
+	RemoteCleanupTimeout         *time.Duration
+	GlobalMaxRunners             *int
+	// DefaultSecurityContext replaces the default pod and container security contexts of the flags it sets.
+	DefaultSecurityContext *DefaultSecurityContext
+	// PodSecurityAdmissionConfig holds the defaults and exemptions of the Pod Security admission of the cluster.
+	PodSecurityAdmissionConfig *psadmission.PodSecurityConfiguration
+}
+
+// ControllerConfig holds the ControllerSettings read from the controller config map, shared by the controllers.
+// A nil ControllerConfig has no settings.
+type ControllerConfig struct {
+	mu          sync.RWMutex
+	settings    ControllerSettings
+	subscribers []chan event.GenericEvent
+}
+
+// Settings returns the current settings.
+func (c *ControllerConfig) Settings() ControllerSettings {
+	if c == nil {
+		return ControllerSettings{}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings
+}
+
+// set replaces the settings, and reports whether they changed.
+func (c *ControllerConfig) set(settings ControllerSettings) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if reflect.DeepEqual(c.settings, settings) {
+		return false
+	}
+	c.settings = settings
+
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event.GenericEvent{Object: &corev1.ConfigMap{}}:
+		default:
+			// A change is already pending, and the subscriber reads the latest settings.
+		}
+	}
+	return true
+}
+
+// subscribe returns a channel receiving an event when the settings change, to trigger the reconciliation
+// of a controller depending on them.
+func (c *ControllerConfig) subscribe() <-chan event.GenericEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan event.GenericEvent, 1)
+	c.subscribers = append(c.subscribers, ch)
+	return ch
+}
+
+// The following return the setting of the config map, or the fallback given by the flags when it's unset.
+
+func (c *ControllerConfig) listenerImage(fallback string) string {
+	if image := c.Settings().ListenerImage; image != "" {
+		return image
+	}
+	return fallback
+}
+
+func (c *ControllerConfig) runnerVersionCheckInterval(fallback time.Duration) time.Duration {
+	return settingOr(c.Settings().RunnerVersionCheckInterval, fallback)
+}
+
+func (c *ControllerConfig) orphanedRunnerSweepInterval(fallback time.Duration) time.Duration {
+	return settingOr(c.Settings().OrphanedRunnerSweepInterval, fallback)
+}
+
+func (c *ControllerConfig) workVolumeClaimSweepInterval(fallback time.Duration) time.Duration {
+	return settingOr(c.Settings().WorkVolumeClaimSweepInterval, fallback)
+}
+
+func (c *ControllerConfig) remoteCleanupTimeout(fallback time.Duration) time.Duration {
+	return settingOr(c.Settings().RemoteCleanupTimeout, fallback)
+}
+
+func (c *ControllerConfig) globalMaxRunners(fallback int) int {
+	return settingOr(c.Settings().GlobalMaxRunners, fallback)
+}
+
+func (c *ControllerConfig) defaultSecurityContext(fallback *DefaultSecurityContext) *DefaultSecurityContext {
+	setting := c.Settings().DefaultSecurityContext
+	if setting == nil {
+		return fallback
+	}
+
+	var defaults DefaultSecurityContext
+	if fallback != nil {
+		defaults = *fallback
+	}
+	if setting.Pod != nil {
+		defaults.Pod = setting.Pod
+	}
+	if setting.Container != nil {
+		defaults.Container = setting.Container
+	}
+	return &defaults
+}
+
+func (c *ControllerConfig) podSecurityAdmissionConfig(fallback *psadmission.PodSecurityConfiguration) *psadmission.PodSecurityConfiguration {
+	if config := c.Settings().PodSecurityAdmissionConfig; config != nil {
+		return config
+	}
+	return fallback
+}
+
+func settingOr[T any](setting *T, fallback T) T {
+	if setting != nil {
+		return *setting
+	}
+	return fallback
+}
+
+// parseControllerSettings parses the data of the controller config map. Unknown keys are ignored.
+func parseControllerSettings(data map[string]string) (ControllerSettings, error) {
+	var settings ControllerSettings
+
+	settings.ListenerImage = data[ControllerConfigKeyListenerImage]
+
+	durations := []struct {
+		key   string
+		value **time.Duration
+	}{
+		{ControllerConfigKeyRunnerVersionCheckInterval, &settings.RunnerVersionCheckInterval},
+		{ControllerConfigKeyOrphanedRunnerSweepInterval, &settings.OrphanedRunnerSweepInterval},
+		{ControllerConfigKeyWorkVolumeClaimSweepInterval, &settings.WorkVolumeClaimSweepInterval},
+		{ControllerConfigKeyRemoteCleanupTimeout, &settings.RemoteCleanupTimeout},
+	}
+	for _, d := range durations {
+		v, ok := data[d.key]
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(v)
+		if err != nil || duration < 0 {
+			return ControllerSettings{}, fmt.Errorf("invalid %s %q: expected a non-negative duration", d.key, v)
+		}
+		*d.value = &duration
+	}
+
+	if v, ok := data[ControllerConfigKeyGlobalMaxRunners]; ok {
+		maxRunners, err := strconv.Atoi(v)
+		if err != nil || maxRunners < 0 {
+			return ControllerSettings{}, fmt.Errorf("invalid %s %q: expected a non-negative integer", ControllerConfigKeyGlobalMaxRunners, v)
+		}
+		settings.GlobalMaxRunners = &maxRunners
+	}
+
+	defaults, err := ParseDefaultSecurityContext(data[ControllerConfigKeyDefaultPodSecurityContext], data[ControllerConfigKeyDefaultContainerSecurityContext])
+	if err != nil {
+		return ControllerSettings{}, err
+	}
+	settings.DefaultSecurityContext = defaults
+
+	podSecurityAdmissionConfig, err := ParsePodSecurityAdmissionConfig(data[ControllerConfigKeyPodSecurityAdmissionConfig])
+	if err != nil {
+		return ControllerSettings{}, err
+	}
+	settings.PodSecurityAdmissionConfig = podSecurityAdmissionConfig
+
+	return settings, nil
+}
+
+// ControllerConfigReconciler watches the controller config map, and applies its settings to the
+// controllers sharing the ControllerConfig as it changes.
+type ControllerConfigReconciler struct {
+	Log logr.Logger
+
+	// ConfigMap is the namespace and the name of the controller config map.
+	ConfigMap types.NamespacedName
+	Config    *ControllerConfig
+
+	// reader reads the controller config map from a cache only holding it.
+	reader client.Reader
+}
+
+func (r *ControllerConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// An invalid config map keeps the previous settings until it's fixed, which triggers another reconciliation.
+	if err := r.Load(ctx, r.reader); err != nil {
+		r.Log.Error(err, "Failed to apply the controller config map. Keeping the previous settings", "configMap", r.ConfigMap)
+	}
+	return ctrl.Result{}, nil
+}
+
+// Load reads the controller config map and applies its settings. The settings are reset
+// to the flags of the controller when the config map doesn't exist.
+func (r *ControllerConfigReconciler) Load(ctx context.Context, reader client.Reader) error {
+	configMap := new(corev1.ConfigMap)
+	if err := reader.Get(ctx, r.ConfigMap, configMap); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get controller config map: %v", err)
+		}
+		configMap.Data = nil
+	}
+
+	settings, err := parseControllerSettings(configMap.Data)
+	if err != nil {
+		return err
+	}
+
+	if r.Config.set(settings) {
+		r.Log.Info("Applied controller settings", "configMap", r.ConfigMap, "data", configMap.Data)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager. The config map is watched through a cache of its own,
+// limited to its name, rather than the cache of the manager, which would hold every config map of the cluster.
+// Reading it only takes a role in the namespace of the controller.
+func (r *ControllerConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	configMapCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: r.ConfigMap.Namespace,
+		SelectorsByObject: cache.SelectorsByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", r.ConfigMap.Name)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the cache of the controller config map: %v", err)
+	}
+	if err := mgr.Add(configMapCache); err != nil {
+		return fmt.Errorf("failed to add the cache of the controller config map: %v", err)
+	}
+	r.reader = configMapCache
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("controller-config-controller").
+		Watches(source.NewKindWithCache(&corev1.ConfigMap{}, configMapCache), &handler.EnqueueRequestForObject{}).
+		Complete(r)
+}
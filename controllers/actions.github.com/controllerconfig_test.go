@@ -0,0 +1,101 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseControllerSettings(t *testing.T) {
+	settings, err := parseControllerSettings(map[string]string{
+		ControllerConfigKeyListenerImage:                "listener:v2",
+		ControllerConfigKeyRunnerVersionCheckInterval:   "30m",
+		ControllerConfigKeyOrphanedRunnerSweepInterval:  "0s",
+		ControllerConfigKeyWorkVolumeClaimSweepInterval: "1h",
+		ControllerConfigKeyGlobalMaxRunners:             "0",
+		ControllerConfigKeyDefaultPodSecurityContext:    "runAsNonRoot: true",
+		"unknown": "ignored",
+	})
+	require.NoError(t, err)
+
+	config := new(ControllerConfig)
+	config.set(settings)
+	assert.Equal(t, "listener:v2", config.listenerImage("listener:v1"))
+	assert.Equal(t, 30*time.Minute, config.runnerVersionCheckInterval(time.Hour))
+	assert.Equal(t, time.Duration(0), config.orphanedRunnerSweepInterval(10*time.Minute), "Zero values of the config map are applied")
+	assert.Equal(t, time.Hour, config.workVolumeClaimSweepInterval(10*time.Minute))
+	assert.Equal(t, time.Hour, config.remoteCleanupTimeout(time.Hour), "Unset settings keep the flags")
+	assert.Equal(t, 0, config.globalMaxRunners(100))
+
+	fallback := &DefaultSecurityContext{Container: &corev1.SecurityContext{Privileged: new(bool)}}
+	defaults := config.defaultSecurityContext(fallback)
+	require.NotNil(t, defaults.Pod)
+	assert.True(t, *defaults.Pod.RunAsNonRoot)
+	assert.Equal(t, fallback.Container, defaults.Container, "The container security context of the flags is kept")
+
+	var nilConfig *ControllerConfig
+	assert.Equal(t, "listener:v1", nilConfig.listenerImage("listener:v1"))
+	assert.Equal(t, 100, nilConfig.globalMaxRunners(100))
+
+	for _, invalid := range []map[string]string{
+		{ControllerConfigKeyRunnerVersionCheckInterval: "hourly"},
+		{ControllerConfigKeyRemoteCleanupTimeout: "-1h"},
+		{ControllerConfigKeyGlobalMaxRunners: "many"},
+		{ControllerConfigKeyDefaultPodSecurityContext: "runAsNonRoot: maybe"},
+		{ControllerConfigKeyDefaultContainerSecurityContext: "unknownField: true"},
+	} {
+		_, err := parseControllerSettings(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestControllerConfigReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	key := types.NamespacedName{Namespace: "arc-systems", Name: "arc-config"}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Data:       map[string]string{ControllerConfigKeyGlobalMaxRunners: "10"},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap).Build()
+
+	config := new(ControllerConfig)
+	changes := config.subscribe()
+	r := &ControllerConfigReconciler{
+		reader:    c,
+		Log:       logr.Discard(),
+		ConfigMap: key,
+		Config:    config,
+	}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, 10, config.globalMaxRunners(0))
+	assert.Len(t, changes, 1, "Subscribers are notified of the change")
+	<-changes
+
+	configMap.Data[ControllerConfigKeyGlobalMaxRunners] = "invalid"
+	require.NoError(t, c.Update(context.Background(), configMap))
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, 10, config.globalMaxRunners(0), "An invalid config map keeps the previous settings")
+	assert.Len(t, changes, 0)
+
+	require.NoError(t, c.Delete(context.Background(), configMap))
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, 0, config.globalMaxRunners(0), "Deleting the config map restores the flags")
+	assert.Len(t, changes, 1)
+}
@@ -0,0 +1,135 @@
+package actionsgithubcom
+
+import (
+	"path"
+	"strconv"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	DockerLayerCacheVolumeName = "docker-layer-cache"
+	// DockerLayerCacheMountPath is where the caches of the node are mounted in the dind container.
+	DockerLayerCacheMountPath = "/var/lib/docker-layer-cache"
+
+	DefaultDockerLayerCacheHostPath         = "/var/lib/actions-runner-controller/docker-layer-cache"
+	DefaultDockerLayerCacheMaxCachesPerNode = 4
+
+	EnvVarDockerLayerCacheDir         = "ARC_DOCKER_LAYER_CACHE_DIR"
+	EnvVarDockerLayerCaches           = "ARC_DOCKER_LAYER_CACHES"
+	EnvVarDockerLayerCacheSizeLimitKB = "ARC_DOCKER_LAYER_CACHE_SIZE_LIMIT_KB"
+
+	// dindEntrypoint is the entrypoint of the docker:dind image, which the cache script runs
+	// when the dind container doesn't set a command.
+	dindEntrypoint = "dockerd-entrypoint.sh"
+)
+
+// dockerLayerCacheScript locks a free cache slot of the node and starts the Docker daemon with its data root
+// in the slot. The lock is a directory kept fresh while the daemon runs, and is taken over once the pod
+// holding it is gone for two minutes. Slots beyond the number of caches of the node are removed, and slots
+// beyond the size limit are wiped before use. Without a free slot the daemon starts with an empty data root.
+const dockerLayerCacheScript = `root="$` + EnvVarDockerLayerCacheDir + `"
+slots="${` + EnvVarDockerLayerCaches + `:-4}"
+limit="${` + EnvVarDockerLayerCacheSizeLimitKB + `:-0}"
+
+for data in "$root"/*; do
+  n="${data##*/}"
+  case "$n" in ''|*[!0-9]*) continue ;; esac
+  if [ "$n" -ge "$slots" ] && mkdir "$root/$n.lock" 2>/dev/null; then
+    echo "Removing Docker layer cache $n"
+    rm -rf "$data" "$root/$n.lock"
+  fi
+done
+
+i=0
+while [ "$i" -lt "$slots" ]; do
+  lock="$root/$i.lock"
+  if mkdir "$lock" 2>/dev/null || {
+    [ -n "$(find "$lock" -maxdepth 0 -mmin +2 2>/dev/null)" ] &&
+      mv "$lock" "$lock.$$" 2>/dev/null && rm -rf "$lock.$$" && mkdir "$lock" 2>/dev/null
+  }; then
+    (while touch "$lock"; do sleep 30; done) &
+    data="$root/$i"
+    if [ "$limit" -gt 0 ] && [ -d "$data" ] && [ "$(du -sk "$data" | cut -f1)" -gt "$limit" ]; then
+      echo "Docker layer cache $i exceeds its size limit, wiping it"
+      rm -rf "$data"
+    fi
+    echo "Using Docker layer cache $i"
+    exec "$@" --data-root="$data"
+  fi
+  i=$((i + 1))
+done
+
+echo "No free Docker layer cache, starting with an empty one"
+exec "$@"
+`
+
+func dockerLayerCacheHostPath(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	hostPath := autoscalingRunnerSet.Spec.DockerLayerCache.HostPath
+	if hostPath == "" {
+		hostPath = DefaultDockerLayerCacheHostPath
+	}
+	return path.Join(hostPath, autoscalingRunnerSet.Namespace, autoscalingRunnerSet.Name)
+}
+
+// applyDockerLayerCache mounts the Docker layer caches of the node into the dind container of the pod template,
+// and wraps its command with the script picking the cache of the Docker daemon. Templates without a dind
+// container, or with a docker-layer-cache volume of their own, are left untouched.
+func applyDockerLayerCache(template *corev1.PodTemplateSpec, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	cache := autoscalingRunnerSet.Spec.DockerLayerCache
+	if cache == nil || isWindowsPod(&template.Spec) {
+		return
+	}
+	for _, v := range template.Spec.Volumes {
+		if v.Name == DockerLayerCacheVolumeName {
+			return
+		}
+	}
+
+	var dind *corev1.Container
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].Name == dindContainerName {
+			dind = &template.Spec.Containers[i]
+			break
+		}
+	}
+	if dind == nil {
+		return
+	}
+
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: DockerLayerCacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: dockerLayerCacheHostPath(autoscalingRunnerSet),
+				Type: &hostPathType,
+			},
+		},
+	})
+
+	dind.VolumeMounts = append(dind.VolumeMounts, corev1.VolumeMount{
+		Name:      DockerLayerCacheVolumeName,
+		MountPath: DockerLayerCacheMountPath,
+	})
+
+	caches := DefaultDockerLayerCacheMaxCachesPerNode
+	if cache.MaxCachesPerNode != nil && *cache.MaxCachesPerNode > 0 {
+		caches = *cache.MaxCachesPerNode
+	}
+	var sizeLimitKB int64
+	if cache.SizeLimit != nil {
+		sizeLimitKB = cache.SizeLimit.Value() / 1024
+	}
+	dind.Env = appendEnvIfMissing(dind.Env, corev1.EnvVar{Name: EnvVarDockerLayerCacheDir, Value: DockerLayerCacheMountPath})
+	dind.Env = appendEnvIfMissing(dind.Env, corev1.EnvVar{Name: EnvVarDockerLayerCaches, Value: strconv.Itoa(caches)})
+	dind.Env = appendEnvIfMissing(dind.Env, corev1.EnvVar{Name: EnvVarDockerLayerCacheSizeLimitKB, Value: strconv.FormatInt(sizeLimitKB, 10)})
+
+	// The arguments of the container are appended to the command, and so passed on to the Docker daemon.
+	command := dind.Command
+	if len(command) == 0 {
+		command = []string{dindEntrypoint}
+	}
+	dind.Command = append([]string{"/bin/sh", "-c", dockerLayerCacheScript, "--"}, command...)
+}
@@ -0,0 +1,117 @@
+package actionsgithubcom
+
+import (
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestDindPodTemplate() corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/runner"},
+				{Name: dindContainerName, Image: "docker:dind"},
+			},
+		},
+	}
+}
+
+func TestNewEphemeralRunnerSet_DockerLayerCache(t *testing.T) {
+	b := resourceBuilder{}
+	maxCaches := 2
+	sizeLimit := resource.MustParse("10Gi")
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl: "https://github.com/owner/repo",
+			Template:        newTestDindPodTemplate(),
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{Name: "large", Labels: []string{"large"}, Template: newTestDindPodTemplate()},
+			},
+			DockerLayerCache: &v1alpha1.DockerLayerCache{
+				MaxCachesPerNode: &maxCaches,
+				SizeLimit:        &sizeLimit,
+			},
+		},
+	}
+
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+
+	for _, template := range []corev1.PodTemplateSpec{runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec, runnerSet.Spec.TemplateVariants[0].Template} {
+		require.Len(t, template.Spec.Volumes, 1)
+		volume := template.Spec.Volumes[0]
+		assert.Equal(t, DockerLayerCacheVolumeName, volume.Name)
+		require.NotNil(t, volume.HostPath)
+		assert.Equal(t, "/var/lib/actions-runner-controller/docker-layer-cache/default/arc", volume.HostPath.Path)
+		assert.Equal(t, corev1.HostPathDirectoryOrCreate, *volume.HostPath.Type)
+
+		assert.Empty(t, template.Spec.Containers[0].VolumeMounts)
+		assert.Empty(t, template.Spec.Containers[0].Command)
+
+		dind := template.Spec.Containers[1]
+		assert.Equal(t, []corev1.VolumeMount{{Name: DockerLayerCacheVolumeName, MountPath: DockerLayerCacheMountPath}}, dind.VolumeMounts)
+		assert.Equal(t, []string{"/bin/sh", "-c", dockerLayerCacheScript, "--", dindEntrypoint}, dind.Command)
+		assert.Equal(t, DockerLayerCacheMountPath, findEnv(dind.Env, EnvVarDockerLayerCacheDir).Value)
+		assert.Equal(t, "2", findEnv(dind.Env, EnvVarDockerLayerCaches).Value)
+		assert.Equal(t, "10485760", findEnv(dind.Env, EnvVarDockerLayerCacheSizeLimitKB).Value)
+	}
+	assert.Empty(t, autoscalingRunnerSet.Spec.Template.Spec.Volumes, "default template must not change")
+}
+
+func TestApplyDockerLayerCache(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			DockerLayerCache: &v1alpha1.DockerLayerCache{HostPath: "/mnt/disks/ssd0/docker"},
+		},
+	}
+
+	t.Run("keeps the command of the dind container", func(t *testing.T) {
+		template := newTestDindPodTemplate()
+		template.Spec.Containers[1].Command = []string{"dockerd"}
+		template.Spec.Containers[1].Args = []string{"--host=unix:///var/run/docker.sock"}
+
+		applyDockerLayerCache(&template, autoscalingRunnerSet)
+
+		assert.Equal(t, "/mnt/disks/ssd0/docker/default/arc", template.Spec.Volumes[0].HostPath.Path)
+		dind := template.Spec.Containers[1]
+		assert.Equal(t, []string{"/bin/sh", "-c", dockerLayerCacheScript, "--", "dockerd"}, dind.Command)
+		assert.Equal(t, []string{"--host=unix:///var/run/docker.sock"}, dind.Args)
+		assert.Equal(t, "4", findEnv(dind.Env, EnvVarDockerLayerCaches).Value)
+		assert.Equal(t, "0", findEnv(dind.Env, EnvVarDockerLayerCacheSizeLimitKB).Value)
+	})
+
+	t.Run("skips templates without a dind container", func(t *testing.T) {
+		template := newTestEphemeralRunner().Spec.PodTemplateSpec
+
+		applyDockerLayerCache(&template, autoscalingRunnerSet)
+
+		assert.Empty(t, template.Spec.Volumes)
+		for _, c := range template.Spec.Containers {
+			assert.Empty(t, c.Command)
+		}
+	})
+
+	t.Run("skips templates with their own cache volume", func(t *testing.T) {
+		template := newTestDindPodTemplate()
+		template.Spec.Volumes = []corev1.Volume{
+			{Name: DockerLayerCacheVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		}
+
+		applyDockerLayerCache(&template, autoscalingRunnerSet)
+
+		assert.Len(t, template.Spec.Volumes, 1)
+		assert.Empty(t, template.Spec.Containers[1].Command)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,29 +31,72 @@ import (
 	"go.uber.org/multierr"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	// EphemeralRunnerContainerName is the name of the runner container.
 	// It represents the name of the container running the self-hosted runner image.
-	EphemeralRunnerContainerName = "runner"
+	EphemeralRunnerContainerName = v1alpha1.RunnerContainerName
 
 	ephemeralRunnerFinalizerName        = "ephemeralrunner.actions.github.com/finalizer"
 	ephemeralRunnerActionsFinalizerName = "ephemeralrunner.actions.github.com/runner-registration-finalizer"
+
+	// defaultMaxFailures is the number of pod failures tolerated when no failure policy is set.
+	defaultMaxFailures = 5
+	// maxFailureBackoff caps the exponential backoff between pod re-creations.
+	maxFailureBackoff = 10 * time.Minute
+
+	podNodeNameKey = "spec.nodeName"
 )
 
+// KnownPreemptionTaints are the taints set on nodes about to be preempted by
+// the AWS node termination handler and GKE. None is watched unless configured,
+// since the runners are replaced even while they run a job.
+var KnownPreemptionTaints = []string{
+	"aws-node-termination-handler/spot-itn",
+	"aws-node-termination-handler/rebalance-recommendation",
+	"aws-node-termination-handler/asg-lifecycle-termination",
+	"cloud.google.com/impending-node-termination",
+}
+
 // EphemeralRunnerReconciler reconciles a EphemeralRunner object
 type EphemeralRunnerReconciler struct {
 	client.Client
-	Log             logr.Logger
-	Scheme          *runtime.Scheme
-	ActionsClient   actions.MultiClient
+	Log           logr.Logger
+	Scheme        *runtime.Scheme
+	ActionsClient actions.MultiClient
+	// PreemptionTaints are the keys of the taints marking nodes that are about to be
+	// preempted. Runners on these nodes are replaced before the node goes away.
+	PreemptionTaints []string
+	Recorder         record.EventRecorder
+	// RemoteCleanupTimeout is how long a deleted EphemeralRunner retries removing its runner
+	// from the Actions service before it is force deleted. Zero retries forever.
+	RemoteCleanupTimeout time.Duration
+	// Config, when set, overrides RemoteCleanupTimeout and DefaultSecurityContext with the ones of the controller config map.
+	Config *ControllerConfig
+	// DefaultSecurityContext is merged into the runner pods, unless their template or pod patches override it.
+	DefaultSecurityContext *DefaultSecurityContext
+	// DefaultGitHubServerTLS is used by the actions client of the EphemeralRunners without GitHubServerTLS.
+	DefaultGitHubServerTLS *DefaultGitHubServerTLS
+	// Diagnostics, when set, uploads the diagnostics of the failed runner pods before they are deleted.
+	Diagnostics *RunnerDiagnostics
+	// NativeSidecars runs the dind containers of the runner pods as native sidecars, which needs
+	// Kubernetes 1.29 or later.
+	NativeSidecars bool
+
 	resourceBuilder resourceBuilder
 }
 
@@ -61,7 +105,11 @@ type EphemeralRunnerReconciler struct {
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=create;get;list;watch;delete
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=create;get;list;watch;update;delete
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=create;get;list;watch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;list;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -82,6 +130,10 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		}
 
 		if controllerutil.ContainsFinalizer(ephemeralRunner, ephemeralRunnerActionsFinalizerName) {
+			if forceDeleteRequested(ephemeralRunner) {
+				return ctrl.Result{}, r.orphanRunner(ctx, ephemeralRunner, log)
+			}
+
 			switch ephemeralRunner.Status.Phase {
 			case corev1.PodSucceeded:
 				// deleted by the runner set, we can just remove finalizer without API calls
@@ -95,6 +147,18 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 				log.Info("Successfully removed runner registration finalizer")
 				return ctrl.Result{}, nil
 			default:
+				waiting, err := r.waitingForJobCompletion(ctx, ephemeralRunner, log)
+				if err != nil {
+					log.Error(err, "Failed to check if the runner is still running its job")
+					return ctrl.Result{}, err
+				}
+				if waiting {
+					remaining := jobCompletionTimeRemaining(ephemeralRunner, time.Now())
+					if remaining > 30*time.Second {
+						remaining = 30 * time.Second
+					}
+					return ctrl.Result{RequeueAfter: remaining}, nil
+				}
 				return r.cleanupRunnerFromService(ctx, ephemeralRunner, log)
 			}
 		}
@@ -163,10 +227,15 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		// Stop reconciling on this object.
 		// The EphemeralRunnerSet is responsible for cleaning it up.
 		log.Info("EphemeralRunner has already finished. Stopping reconciliation and waiting for EphemeralRunnerSet to clean it up", "phase", ephemeralRunner.Status.Phase)
+		// The work volume isn't needed anymore. Its storage is released once the pod is gone.
+		if _, err := r.deleteWorkVolumeClaim(ctx, ephemeralRunner, log); err != nil {
+			log.Error(err, "Failed to delete the work volume claim of the finished runner")
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{}, nil
 	}
 
-	if ephemeralRunner.Status.RunnerId == 0 {
+	if ephemeralRunner.Status.RunnerId == 0 && ephemeralRunner.Status.RegistrationMethod != v1alpha1.RunnerRegistrationMethodRegistrationToken {
 		log.Info("Creating new ephemeral runner registration and updating status with runner config")
 		return r.updateStatusWithRunnerConfig(ctx, ephemeralRunner, log)
 	}
@@ -189,8 +258,8 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			log.Error(err, "Failed to fetch the pod")
 			return ctrl.Result{}, err
 
-		case len(ephemeralRunner.Status.Failures) > 5:
-			log.Info("EphemeralRunner has failed more than 5 times. Marking it as failed")
+		case len(ephemeralRunner.Status.Failures) > maxFailures(ephemeralRunner.Spec.FailurePolicy):
+			log.Info("EphemeralRunner has failed too many times. Marking it as failed", "failures", len(ephemeralRunner.Status.Failures))
 			if err := r.markAsFailed(ctx, ephemeralRunner, log); err != nil {
 				log.Error(err, "Failed to set ephemeral runner to phase Failed")
 				return ctrl.Result{}, err
@@ -198,18 +267,85 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			return ctrl.Result{}, nil
 
 		default:
+			if delay := failureBackoffRemaining(ephemeralRunner, time.Now()); delay > 0 {
+				log.Info("Waiting for the failure backoff before creating new EphemeralRunner pod", "failures", len(ephemeralRunner.Status.Failures), "delay", delay)
+				return ctrl.Result{RequeueAfter: delay}, nil
+			}
+
+			if err := r.createWorkVolumeClaim(ctx, ephemeralRunner, log); err != nil {
+				log.Error(err, "Failed to create the work volume claim")
+				return ctrl.Result{}, err
+			}
+
+			if ephemeralRunner.Status.RegistrationMethod == v1alpha1.RunnerRegistrationMethodRegistrationToken {
+				if err := r.updateRegistrationTokenSecret(ctx, ephemeralRunner, secret, log); err != nil {
+					log.Error(err, "Failed to update the registration token of the runner")
+					return ctrl.Result{}, err
+				}
+			}
+
 			// Pod was not found. Create if the pod has never been created
 			log.Info("Creating new EphemeralRunner pod.")
 			return r.createPod(ctx, ephemeralRunner, secret, log)
 		}
 	}
 
+	if remaining, retained := retainedFailedPodRemaining(pod, time.Now()); retained {
+		// The failure of the retained pod is already recorded. The runner is retried once the pod is deleted.
+		if remaining > 0 {
+			log.Info("Failed pod is retained for debugging", "remaining", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+		if pod.ObjectMeta.DeletionTimestamp.IsZero() {
+			log.Info("Deleting the failed pod retained for debugging")
+			if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
+				log.Error(err, "Failed to delete the retained failed pod")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
 	cs := runnerContainerStatus(pod)
+	if cs == nil || cs.State.Terminated == nil {
+		preempted, err := r.nodePreempted(ctx, pod.Spec.NodeName)
+		if err != nil {
+			log.Error(err, "Failed to check if the node of the pod is being preempted")
+			return ctrl.Result{}, err
+		}
+		if preempted {
+			// Deleting the runner lets the runner set create a replacement right away,
+			// while the finalizer deregisters it once it is no longer running a job.
+			log.Info("Node of the pod is being preempted. Deleting the ephemeral runner to replace it", "node", pod.Spec.NodeName, "jobRequestId", ephemeralRunner.Status.JobRequestId)
+			if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+				log.Error(err, "Failed to delete ephemeral runner on a preempted node")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+
+	pendingRemaining, pendingTimeout := pendingTimeoutRemaining(ephemeralRunner, pod, time.Now())
+	if pendingTimeout && pendingRemaining <= 0 {
+		reason, message := podPendingReason(pod)
+		log.Info("Pod has been pending for longer than the pending timeout. Deleting pod", "reason", reason, "message", message)
+		if err := r.deletePodAsFailed(ctx, ephemeralRunner, pod, log); err != nil {
+			log.Error(err, "Failed to delete the pod pending for too long")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	switch {
 	case cs == nil:
 		// starting, no container state yet
 		log.Info("Waiting for runner container status to be available")
-		return ctrl.Result{}, nil
+		if err := r.updateRunStatusFromPod(ctx, ephemeralRunner, pod, log); err != nil {
+			log.Info("Failed to update ephemeral runner status. Requeue to not miss this event")
+			return ctrl.Result{}, err
+		}
+		// Nothing changes on a pod that can't be scheduled, so the pending timeout is checked again once it expires.
+		return ctrl.Result{RequeueAfter: pendingRemaining}, nil
 	case cs.State.Terminated == nil: // still running or evicted
 		if pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted" {
 			log.Info("Pod set the termination phase, but container state is not terminated. Deleting pod",
@@ -226,18 +362,56 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		}
 
 		log.Info("Ephemeral runner container is still running")
+		if err := r.updatePodSafeToEvict(ctx, ephemeralRunner, pod, log); err != nil {
+			log.Error(err, "Failed to update safe-to-evict annotation of the pod")
+			return ctrl.Result{}, err
+		}
+		if err := r.updatePodJobMetadata(ctx, ephemeralRunner, pod, log); err != nil {
+			log.Error(err, "Failed to update the job metadata of the pod")
+			return ctrl.Result{}, err
+		}
 		if err := r.updateRunStatusFromPod(ctx, ephemeralRunner, pod, log); err != nil {
 			log.Info("Failed to update ephemeral runner status. Requeue to not miss this event")
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{}, nil
+		if ephemeralRunner.Status.RunnerId == 0 && cs.State.Running != nil {
+			// The runner registers itself with the registration token once it starts.
+			registered, err := r.resolveRegisteredRunnerId(ctx, ephemeralRunner, log)
+			if err != nil {
+				log.Error(err, "Failed to look up the runner registered with the registration token")
+				return ctrl.Result{}, err
+			}
+			if !registered {
+				return ctrl.Result{RequeueAfter: registeredRunnerLookupInterval}, nil
+			}
+		}
+		requeueAfter := pendingRemaining
+		if recycle, ok := persistentRunnerRecycleTime(ephemeralRunner); ok && cs.State.Running != nil {
+			remaining := time.Until(recycle)
+			if remaining <= 0 {
+				if err := r.recyclePersistentRunner(ctx, ephemeralRunner, log); err != nil {
+					log.Error(err, "Failed to recycle the persistent runner")
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{}, nil
+			}
+			if requeueAfter <= 0 || remaining < requeueAfter {
+				requeueAfter = remaining
+			}
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 
 	case cs.State.Terminated.ExitCode != 0: // failed
 		log.Info("Ephemeral runner container failed", "exitCode", cs.State.Terminated.ExitCode)
+		// A pod already being deleted had its usage recorded when the deletion was requested.
+		deleted := !pod.ObjectMeta.DeletionTimestamp.IsZero()
 		if err := r.deletePodAsFailed(ctx, ephemeralRunner, pod, log); err != nil {
 			log.Error(err, "Failed to delete runner pod on failure")
 			return ctrl.Result{}, err
 		}
+		if !deleted {
+			recordRunnerUsage(ephemeralRunner, cs)
+		}
 		return ctrl.Result{}, nil
 
 	default:
@@ -255,6 +429,7 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 				log.Error(err, "Failed to mark ephemeral runner as finished")
 				return ctrl.Result{}, err
 			}
+			recordRunnerUsage(ephemeralRunner, cs)
 			return ctrl.Result{}, nil
 		}
 
@@ -268,6 +443,33 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 }
 
+// orphanRunner removes the runner registration finalizer without removing the runner from the service,
+// recording that the runner is left registered.
+func (r *EphemeralRunnerReconciler) orphanRunner(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
+	if ephemeralRunner.Status.RunnerId != 0 && ephemeralRunner.Status.Phase != corev1.PodSucceeded {
+		log.Info("Leaving the runner registered with the service since the ephemeral runner is force deleted", "runnerId", ephemeralRunner.Status.RunnerId)
+		r.Recorder.Eventf(
+			ephemeralRunner,
+			corev1.EventTypeWarning,
+			"RunnerOrphaned",
+			"Runner %d (%s) is left registered with the Actions service since the ephemeral runner is force deleted",
+			ephemeralRunner.Status.RunnerId,
+			ephemeralRunner.Status.RunnerName,
+		)
+	}
+
+	err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		controllerutil.RemoveFinalizer(obj, ephemeralRunnerActionsFinalizerName)
+	})
+	if err != nil {
+		log.Error(err, "Failed to update ephemeral runner without runner registration finalizer")
+		return err
+	}
+
+	log.Info("Successfully removed runner registration finalizer")
+	return nil
+}
+
 func (r *EphemeralRunnerReconciler) cleanupRunnerFromService(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
 	actionsError := &actions.ActionsError{}
 	err := r.deleteRunnerFromService(ctx, ephemeralRunner, log)
@@ -275,10 +477,22 @@ func (r *EphemeralRunnerReconciler) cleanupRunnerFromService(ctx context.Context
 		if errors.As(err, &actionsError) &&
 			actionsError.StatusCode == http.StatusBadRequest &&
 			strings.Contains(actionsError.ExceptionName, "JobStillRunningException") {
+			if ephemeralRunner.Spec.JobCompletionTimeout != nil && jobCompletionTimeRemaining(ephemeralRunner, time.Now()) <= 0 {
+				log.Info("Runner did not finish the job within the job completion timeout. Deleting the runner pod")
+				if err := r.deleteRunnerPod(ctx, ephemeralRunner); err != nil {
+					log.Error(err, "Failed to delete the runner pod")
+					return ctrl.Result{}, err
+				}
+			}
 			log.Info("Runner is still running the job. Re-queue in 30 seconds")
 			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 		}
 
+		if remoteCleanupTimedOut(ephemeralRunner, r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), time.Now()) {
+			log.Info("Runner could not be removed from the service within the remote cleanup timeout. Force deleting the ephemeral runner", "error", err.Error())
+			return ctrl.Result{}, forceDelete(ctx, r.Client, ephemeralRunner)
+		}
+
 		log.Error(err, "Failed clean up runner from the service")
 		return ctrl.Result{}, err
 	}
@@ -330,6 +544,13 @@ func (r *EphemeralRunnerReconciler) cleanupResources(ctx context.Context, epheme
 	}
 	log.Info("Secret is deleted")
 
+	log.Info("Cleaning up the runner work volume claim")
+	deleted, err = r.deleteWorkVolumeClaim(ctx, ephemeralRunner, log)
+	if err != nil || !deleted {
+		return false, err
+	}
+	log.Info("Work volume claim is deleted")
+
 	return true, nil
 }
 
@@ -428,7 +649,7 @@ func (r *EphemeralRunnerReconciler) markAsFailed(ctx context.Context, ephemeralR
 	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
 		obj.Status.Phase = corev1.PodFailed
 		obj.Status.Reason = "TooManyPodFailures"
-		obj.Status.Message = "Pod has failed to start more than 5 times"
+		obj.Status.Message = fmt.Sprintf("Pod has failed to start more than %d times", maxFailures(obj.Spec.FailurePolicy))
 	}); err != nil {
 		return fmt.Errorf("failed to update ephemeral runner status Phase/Message: %v", err)
 	}
@@ -458,21 +679,43 @@ func (r *EphemeralRunnerReconciler) markAsFinished(ctx context.Context, ephemera
 // It should not be responsible for setting the status to Failed.
 func (r *EphemeralRunnerReconciler) deletePodAsFailed(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
 	if pod.ObjectMeta.DeletionTimestamp.IsZero() {
-		log.Info("Deleting the ephemeral runner pod", "podId", pod.UID)
-		if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
-			return fmt.Errorf("failed to delete pod with status failed: %v", err)
+		if r.Diagnostics != nil {
+			r.uploadRunnerDiagnostics(ctx, ephemeralRunner, pod, log)
+		}
+
+		retained, err := r.retainFailedPod(ctx, ephemeralRunner, pod, log)
+		if err != nil {
+			return err
+		}
+		if !retained {
+			log.Info("Deleting the ephemeral runner pod", "podId", pod.UID)
+			if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete pod with status failed: %v", err)
+			}
 		}
 	}
 
 	log.Info("Updating ephemeral runner status to track the failure count")
+	now := metav1.Now()
 	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
-		if obj.Status.Failures == nil {
+		if obj.Status.Failures == nil || failureCountExpired(obj, now.Time) {
 			obj.Status.Failures = make(map[string]bool)
 		}
 		obj.Status.Failures[string(pod.UID)] = true
+		obj.Status.LastFailureTime = &now
 		obj.Status.Ready = false
-		obj.Status.Reason = pod.Status.Reason
-		obj.Status.Message = pod.Status.Message
+		obj.Status.Reason, obj.Status.Message = pod.Status.Reason, pod.Status.Message
+		if pod.Status.Phase == corev1.PodPending {
+			obj.Status.Reason, obj.Status.Message = podPendingReason(pod)
+		}
+		obj.Status.FailureReason = podFailureReason(pod)
+		obj.Status.ExitCode = nil
+		obj.Status.TerminationMessage = ""
+		if cs := runnerContainerStatus(pod); cs != nil && cs.State.Terminated != nil {
+			exitCode := cs.State.Terminated.ExitCode
+			obj.Status.ExitCode = &exitCode
+			obj.Status.TerminationMessage = cs.State.Terminated.Message
+		}
 	}); err != nil {
 		return fmt.Errorf("failed to update ephemeral runner status: failed attempts: %v", err)
 	}
@@ -484,6 +727,11 @@ func (r *EphemeralRunnerReconciler) deletePodAsFailed(ctx context.Context, ephem
 // updateStatusWithRunnerConfig fetches runtime configuration needed by the runner
 // This method should always set .status.runnerId and .status.runnerJITConfig
 func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
+	if ephemeralRunner.Spec.Persistent != nil {
+		// JIT runner configs are always ephemeral.
+		return r.registerWithRegistrationToken(ctx, ephemeralRunner, log)
+	}
+
 	// Runner is not registered with the service. We need to register it first
 	log.Info("Creating ephemeral runner JIT config")
 	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunner)
@@ -496,6 +744,16 @@ func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Con
 	}
 	jitConfig, err := actionsClient.GenerateJitRunnerConfig(ctx, jitSettings, ephemeralRunner.Spec.RunnerScaleSetId)
 	if err != nil {
+		if reason := registrationBlockedReason(err); reason != "" {
+			return r.blockRegistration(ctx, ephemeralRunner, reason, err, log)
+		}
+
+		// A runner registered with a registration token instead wouldn't be a member of the runner scale set,
+		// so the job the runner is created for could never be assigned to it.
+		if jitConfigUnavailable(err) {
+			return r.blockRegistration(ctx, ephemeralRunner, v1alpha1.RegistrationBlockedReasonJITConfigUnavailable, err, log)
+		}
+
 		actionsError := &actions.ActionsError{}
 		if !errors.As(err, &actionsError) {
 			return ctrl.Result{}, fmt.Errorf("failed to generate JIT config with generic error: %v", err)
@@ -503,6 +761,7 @@ func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Con
 
 		if actionsError.StatusCode != http.StatusConflict ||
 			!strings.Contains(actionsError.ExceptionName, "AgentExistsException") {
+			r.recordRegistrationFailure(ctx, ephemeralRunner, err, log)
 			return ctrl.Result{}, fmt.Errorf("failed to generate JIT config with Actions service error: %v", err)
 		}
 
@@ -538,7 +797,8 @@ func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Con
 
 		// TODO: Do we want to mark the ephemeral runner as failed, and let EphemeralRunnerSet to clean it up, so we can recover from this situation?
 		// The situation is that the EphemeralRunner's name is already used by something else to register a runner, and we can't take the control back.
-		return ctrl.Result{}, fmt.Errorf("runner with the same name but doesn't belong to this RunnerScaleSet: %v", err)
+		err = fmt.Errorf("runner with the same name but doesn't belong to this RunnerScaleSet: %v", err)
+		return r.blockRegistration(ctx, ephemeralRunner, v1alpha1.RegistrationBlockedReasonRunnerNameConflict, err, log)
 	}
 	log.Info("Created ephemeral runner JIT config", "runnerId", jitConfig.Runner.Id)
 
@@ -547,6 +807,8 @@ func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Con
 		obj.Status.RunnerId = jitConfig.Runner.Id
 		obj.Status.RunnerName = jitConfig.Runner.Name
 		obj.Status.RunnerJITConfig = jitConfig.EncodedJITConfig
+		obj.Status.RegistrationMethod = v1alpha1.RunnerRegistrationMethodJIT
+		meta.RemoveStatusCondition(&obj.Status.Conditions, v1alpha1.EphemeralRunnerConditionRegistrationBlocked)
 	})
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to update runner status for RunnerId/RunnerName/RunnerJITConfig: %v", err)
@@ -558,7 +820,18 @@ func (r *EphemeralRunnerReconciler) updateStatusWithRunnerConfig(ctx context.Con
 
 func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alpha1.EphemeralRunner, secret *corev1.Secret, log logr.Logger) (ctrl.Result, error) {
 	log.Info("Creating new pod for ephemeral runner")
-	newPod := r.resourceBuilder.newEphemeralRunnerPod(ctx, runner, secret)
+	// The defaults are applied before the patches, which can override them.
+	pod := r.resourceBuilder.newEphemeralRunnerPod(ctx, runner, secret)
+	applyDefaultSecurityContext(pod, r.Config.defaultSecurityContext(r.DefaultSecurityContext))
+	newPod, err := applyPodPatches(pod, runner.Spec.PodPatches)
+	if err != nil {
+		log.Error(err, "Failed to apply pod patches to a new pod")
+		return ctrl.Result{}, err
+	}
+	addJobMetadataVolume(newPod)
+	if r.Diagnostics != nil {
+		setRunnerPrintLogToStdout(newPod)
+	}
 
 	if err := ctrl.SetControllerReference(runner, newPod, r.Scheme); err != nil {
 		log.Error(err, "Failed to set controller reference to a new pod")
@@ -566,7 +839,7 @@ func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alp
 	}
 
 	log.Info("Created new pod spec for ephemeral runner")
-	if err := r.Create(ctx, newPod); err != nil {
+	if err := r.createRunnerPod(ctx, newPod); err != nil {
 		log.Error(err, "Failed to create pod resource for ephemeral runner.")
 		return ctrl.Result{}, err
 	}
@@ -607,16 +880,35 @@ func (r *EphemeralRunnerReconciler) updateRunStatusFromPod(ctx context.Context,
 	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
 		return nil
 	}
-	if ephemeralRunner.Status.Phase == pod.Status.Phase {
+
+	failureReason := ephemeralRunner.Status.FailureReason
+	reason, message := pod.Status.Reason, pod.Status.Message
+	if pod.Status.Phase == corev1.PodPending {
+		reason, message = podPendingReason(pod)
+	}
+	if waiting := imagePullWaitingState(pod); waiting != nil {
+		failureReason = v1alpha1.EphemeralRunnerFailureReasonImagePullBackOff
+		reason, message = waiting.Reason, waiting.Message
+	} else if failureReason == v1alpha1.EphemeralRunnerFailureReasonImagePullBackOff {
+		// The images were pulled in the end.
+		failureReason = ""
+	}
+
+	// The runner stays ready until its pod fails, e.g. when a readiness probe times out during a job.
+	ready := ephemeralRunner.Status.Ready || runnerPodReady(pod)
+	if ephemeralRunner.Status.Phase == pod.Status.Phase && ephemeralRunner.Status.FailureReason == failureReason &&
+		ephemeralRunner.Status.Reason == reason && ephemeralRunner.Status.Message == message &&
+		ephemeralRunner.Status.Ready == ready {
 		return nil
 	}
 
-	log.Info("Updating ephemeral runner status with pod phase", "phase", pod.Status.Phase, "reason", pod.Status.Reason, "message", pod.Status.Message)
+	log.Info("Updating ephemeral runner status with pod phase", "phase", pod.Status.Phase, "ready", ready, "reason", reason, "message", message)
 	err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
 		obj.Status.Phase = pod.Status.Phase
-		obj.Status.Ready = obj.Status.Ready || (pod.Status.Phase == corev1.PodRunning)
-		obj.Status.Reason = pod.Status.Reason
-		obj.Status.Message = pod.Status.Message
+		obj.Status.Ready = ready
+		obj.Status.Reason = reason
+		obj.Status.Message = message
+		obj.Status.FailureReason = failureReason
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update runner status for Phase/Reason/Message: %v", err)
@@ -626,23 +918,64 @@ func (r *EphemeralRunnerReconciler) updateRunStatusFromPod(ctx context.Context,
 	return nil
 }
 
+// updatePodSafeToEvict keeps the cluster autoscaler from evicting the pod while it runs a job,
+// and allows it again for idle runners.
+func (r *EphemeralRunnerReconciler) updatePodSafeToEvict(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	safeToEvict, managed := runnerPodSafeToEvict(ephemeralRunner)
+	if !managed || pod.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict] == safeToEvict {
+		return nil
+	}
+
+	log.Info("Updating safe-to-evict annotation of the pod", "safeToEvict", safeToEvict, "jobRequestId", ephemeralRunner.Status.JobRequestId)
+	return patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Annotations == nil {
+			obj.Annotations = map[string]string{}
+		}
+		obj.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict] = safeToEvict
+	})
+}
+
+// runnerPodSafeToEvict returns the safe-to-evict annotation value of the runner pod: "false" once
+// a job is assigned to the runner and "true" before. The annotation is not managed when the
+// pod template sets it.
+func runnerPodSafeToEvict(ephemeralRunner *v1alpha1.EphemeralRunner) (value string, managed bool) {
+	if _, ok := ephemeralRunner.Spec.PodTemplateSpec.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict]; ok {
+		return "", false
+	}
+	return strconv.FormatBool(ephemeralRunner.Status.JobRequestId == 0), true
+}
+
 func (r *EphemeralRunnerReconciler) actionsClientFor(ctx context.Context, runner *v1alpha1.EphemeralRunner) (actions.ActionsService, error) {
 	secret := new(corev1.Secret)
 	if err := r.Get(ctx, types.NamespacedName{Namespace: runner.Namespace, Name: runner.Spec.GitHubConfigSecret}, secret); err != nil {
 		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
 
-	return r.ActionsClient.GetClientFromSecret(ctx, runner.Spec.GitHubConfigUrl, runner.Namespace, secret.Data)
+	options, err := actionsClientOptions(ctx, r.Client, runner.Namespace, runner.Spec.Proxy, runner.Spec.GitHubServerTLS, r.DefaultGitHubServerTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ActionsClient.GetClientFromSecret(ctx, runner.Spec.GitHubConfigUrl, runner.Namespace, secret.Data, options...)
 }
 
 // runnerRegisteredWithService checks if the runner is still registered with the service
 // Returns found=false and err=nil if ephemeral runner does not exist in GitHub service and should be deleted
-func (r EphemeralRunnerReconciler) runnerRegisteredWithService(ctx context.Context, runner *v1alpha1.EphemeralRunner, log logr.Logger) (found bool, err error) {
+func (r *EphemeralRunnerReconciler) runnerRegisteredWithService(ctx context.Context, runner *v1alpha1.EphemeralRunner, log logr.Logger) (found bool, err error) {
 	actionsClient, err := r.actionsClientFor(ctx, runner)
 	if err != nil {
 		return false, fmt.Errorf("failed to get Actions client for ScaleSet: %w", err)
 	}
 
+	if runner.Status.RunnerId == 0 && runner.Status.RegistrationMethod == v1alpha1.RunnerRegistrationMethodRegistrationToken {
+		log.Info("Checking if runner exists in GitHub service", "runnerName", runner.Status.RunnerName)
+		existing, err := actionsClient.GetRunnerByName(ctx, runner.Status.RunnerName)
+		if err != nil {
+			return false, fmt.Errorf("failed to check if runner exists in GitHub service: %v", err)
+		}
+		return existing != nil, nil
+	}
+
 	log.Info("Checking if runner exists in GitHub service", "runnerId", runner.Status.RunnerId)
 	_, err = actionsClient.GetRunner(ctx, int64(runner.Status.RunnerId))
 	if err != nil {
@@ -664,34 +997,249 @@ func (r EphemeralRunnerReconciler) runnerRegisteredWithService(ctx context.Conte
 	return true, nil
 }
 
+// waitingForJobCompletion reports whether the deletion of a runner that is running a job
+// should be held off, giving the job up to the job completion timeout to finish.
+// Runner pods that wait for their job on termination are deleted right away: the runner
+// is signaled to cancel the job once the timeout expires, as on node drains and evictions.
+func (r *EphemeralRunnerReconciler) waitingForJobCompletion(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (bool, error) {
+	if ephemeralRunner.Spec.JobCompletionTimeout == nil || ephemeralRunner.Status.JobRequestId == 0 {
+		return false, nil
+	}
+
+	if jobCompletionTimeRemaining(ephemeralRunner, time.Now()) <= 0 {
+		log.Info("Job completion timeout expired", "jobRequestId", ephemeralRunner.Status.JobRequestId)
+		// A job result means that the job completed before the timeout.
+		if ephemeralRunner.Status.JobResult == "" && ephemeralRunner.Status.FailureReason != v1alpha1.EphemeralRunnerFailureReasonJobTimeout {
+			if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+				obj.Status.FailureReason = v1alpha1.EphemeralRunnerFailureReasonJobTimeout
+				obj.Status.Reason = string(v1alpha1.EphemeralRunnerFailureReasonJobTimeout)
+				obj.Status.Message = fmt.Sprintf("Job did not complete within %s", obj.Spec.JobCompletionTimeout.Duration)
+			}); err != nil {
+				return false, fmt.Errorf("failed to update status: %v", err)
+			}
+		}
+		return false, nil
+	}
+
+	pod := new(corev1.Pod)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunner.Namespace, Name: ephemeralRunner.Name}, pod); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if cs := runnerContainerStatus(pod); cs != nil && cs.State.Terminated != nil {
+		log.Info("Runner container has terminated, job is complete", "exitCode", cs.State.Terminated.ExitCode)
+		return false, nil
+	}
+
+	if _, ok := pod.Annotations[AnnotationKeyJobCompletionTimeout]; ok && pod.ObjectMeta.DeletionTimestamp.IsZero() {
+		log.Info("Deleting the runner pod, which keeps running the job until it completes or the job completion timeout expires")
+		if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete the runner pod: %v", err)
+		}
+	}
+
+	log.Info("Waiting for the runner to finish its job before terminating", "jobRequestId", ephemeralRunner.Status.JobRequestId)
+	if ephemeralRunner.Status.Reason != "WaitingForJobCompletion" {
+		if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+			obj.Status.Reason = "WaitingForJobCompletion"
+			obj.Status.Message = fmt.Sprintf("Waiting up to %s for job to complete", obj.Spec.JobCompletionTimeout.Duration)
+		}); err != nil {
+			return false, fmt.Errorf("failed to update status: %v", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (r *EphemeralRunnerReconciler) deleteRunnerPod(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner) error {
+	pod := new(corev1.Pod)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunner.Namespace, Name: ephemeralRunner.Name}, pod); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !pod.ObjectMeta.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	if err := r.Delete(ctx, pod); err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete pod: %v", err)
+	}
+	return nil
+}
+
 func (r *EphemeralRunnerReconciler) deleteRunnerFromService(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
+	runnerId := ephemeralRunner.Status.RunnerId
+	tokenRegistered := runnerId == 0 && ephemeralRunner.Status.RegistrationMethod == v1alpha1.RunnerRegistrationMethodRegistrationToken
+	if runnerId == 0 && !tokenRegistered {
+		log.Info("Runner is not registered with the service, nothing to remove")
+		return nil
+	}
+
 	client, err := r.actionsClientFor(ctx, ephemeralRunner)
 	if err != nil {
 		return fmt.Errorf("failed to get actions client for runner: %v", err)
 	}
 
-	log.Info("Removing runner from the service", "runnerId", ephemeralRunner.Status.RunnerId)
-	err = client.RemoveRunner(ctx, int64(ephemeralRunner.Status.RunnerId))
+	if tokenRegistered {
+		// The runner may have registered itself with the registration token before its id was recorded.
+		runner, err := client.GetRunnerByName(ctx, ephemeralRunner.Status.RunnerName)
+		if err != nil {
+			return fmt.Errorf("failed to get runner by name: %w", err)
+		}
+		if runner == nil {
+			log.Info("Runner is not registered with the service, nothing to remove")
+			return nil
+		}
+		runnerId = runner.Id
+	}
+
+	log.Info("Removing runner from the service", "runnerId", runnerId)
+	err = client.RemoveRunner(ctx, int64(runnerId))
 	if err != nil {
 		return fmt.Errorf("failed to remove runner from the service: %w", err)
 	}
 
-	log.Info("Removed runner from the service", "runnerId", ephemeralRunner.Status.RunnerId)
+	log.Info("Removed runner from the service", "runnerId", runnerId)
 	return nil
 }
 
+// nodePreempted reports whether the node has one of the preemption taints.
+func (r *EphemeralRunnerReconciler) nodePreempted(ctx context.Context, nodeName string) (bool, error) {
+	if nodeName == "" || len(r.PreemptionTaints) == 0 {
+		return false, nil
+	}
+
+	node := new(corev1.Node)
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	return hasPreemptionTaint(node, r.PreemptionTaints), nil
+}
+
+func hasPreemptionTaint(node *corev1.Node, preemptionTaints []string) bool {
+	for _, taint := range node.Spec.Taints {
+		for _, key := range preemptionTaints {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runnersOnNode maps a node to the ephemeral runners whose pods run on it.
+func (r *EphemeralRunnerReconciler) runnersOnNode(obj client.Object) []reconcile.Request {
+	pods := new(corev1.PodList)
+	if err := r.List(context.Background(), pods, client.MatchingFields{podNodeNameKey: obj.GetName()}, client.MatchingLabels{"actions-ephemeral-runner": string(corev1.ConditionTrue)}); err != nil {
+		r.Log.Error(err, "Failed to list runner pods on node", "node", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}})
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *EphemeralRunnerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("ephemeralrunner-controller")
+	}
+
 	// TODO(nikola-jokic): Add indexing and filtering fields on corev1.Pod{}
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.EphemeralRunner{}).
 		Owns(&corev1.Pod{}).
 		Owns(&corev1.Secret{}).
+		Owns(&corev1.PersistentVolumeClaim{})
+
+	if len(r.PreemptionTaints) > 0 {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podNodeNameKey, func(rawObj client.Object) []string {
+			pod := rawObj.(*corev1.Pod)
+			if pod.Spec.NodeName == "" {
+				return nil
+			}
+			return []string{pod.Spec.NodeName}
+		}); err != nil {
+			return err
+		}
+
+		b = b.Watches(
+			&source.Kind{Type: &corev1.Node{}},
+			handler.EnqueueRequestsFromMapFunc(r.runnersOnNode),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return hasPreemptionTaint(obj.(*corev1.Node), r.PreemptionTaints)
+			})),
+		)
+	}
+
+	return b.
 		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
 		Named("ephemeral-runner-controller").
 		Complete(r)
 }
 
+// maxFailures returns the number of pod failures tolerated before the runner is marked as failed.
+func maxFailures(policy *v1alpha1.FailurePolicy) int {
+	if policy == nil || policy.MaxFailures == nil {
+		return defaultMaxFailures
+	}
+	return *policy.MaxFailures
+}
+
+// failureCountExpired reports whether the recorded failures are older than the reset window
+// of the failure policy, and should not count towards the max failures anymore.
+func failureCountExpired(ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time) bool {
+	policy := ephemeralRunner.Spec.FailurePolicy
+	if policy == nil || policy.ResetWindow == nil || ephemeralRunner.Status.LastFailureTime == nil {
+		return false
+	}
+	return now.Sub(ephemeralRunner.Status.LastFailureTime.Time) >= policy.ResetWindow.Duration
+}
+
+// failureBackoffRemaining returns how long to wait before re-creating the pod of a runner
+// that failed, based on the backoff of the failure policy.
+func failureBackoffRemaining(ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time) time.Duration {
+	policy := ephemeralRunner.Spec.FailurePolicy
+	failures := len(ephemeralRunner.Status.Failures)
+	if policy == nil || policy.BackoffDuration == nil || failures == 0 || ephemeralRunner.Status.LastFailureTime == nil {
+		return 0
+	}
+
+	backoff := policy.BackoffDuration.Duration
+	for i := 1; i < failures && backoff < maxFailureBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxFailureBackoff {
+		backoff = maxFailureBackoff
+	}
+
+	return ephemeralRunner.Status.LastFailureTime.Add(backoff).Sub(now)
+}
+
+// jobCompletionTimeRemaining returns how much longer a deleted runner may keep running its job.
+func jobCompletionTimeRemaining(ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time) time.Duration {
+	if ephemeralRunner.Spec.JobCompletionTimeout == nil || ephemeralRunner.ObjectMeta.DeletionTimestamp.IsZero() {
+		return 0
+	}
+	return ephemeralRunner.ObjectMeta.DeletionTimestamp.Add(ephemeralRunner.Spec.JobCompletionTimeout.Duration).Sub(now)
+}
+
+// runnerPodReady returns whether the pod is ready. Without a readiness probe, the pod is ready once its
+// containers started.
+func runnerPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 func runnerContainerStatus(pod *corev1.Pod) *corev1.ContainerStatus {
 	for i := range pod.Status.ContainerStatuses {
 		cs := &pod.Status.ContainerStatuses[i]
@@ -4,21 +4,30 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"testing"
 	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/github/actions"
 
 	"github.com/actions/actions-runner-controller/github/actions/fake"
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
@@ -774,3 +783,187 @@ var _ = Describe("EphemeralRunner", func() {
 		})
 	})
 })
+
+func TestFailurePolicy(t *testing.T) {
+	now := time.Now()
+	intPtr := func(i int) *int { return &i }
+	duration := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+	runnerWithFailures := func(policy *v1alpha1.FailurePolicy, failures int, lastFailure time.Time) *v1alpha1.EphemeralRunner {
+		runner := &v1alpha1.EphemeralRunner{
+			Spec: v1alpha1.EphemeralRunnerSpec{FailurePolicy: policy},
+		}
+		runner.Status.Failures = make(map[string]bool)
+		for i := 0; i < failures; i++ {
+			runner.Status.Failures[fmt.Sprintf("pod-%d", i)] = true
+		}
+		if failures > 0 {
+			runner.Status.LastFailureTime = &metav1.Time{Time: lastFailure}
+		}
+		return runner
+	}
+
+	t.Run("max failures", func(t *testing.T) {
+		assert.Equal(t, defaultMaxFailures, maxFailures(nil))
+		assert.Equal(t, defaultMaxFailures, maxFailures(&v1alpha1.FailurePolicy{}))
+		assert.Equal(t, 10, maxFailures(&v1alpha1.FailurePolicy{MaxFailures: intPtr(10)}))
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		policy := &v1alpha1.FailurePolicy{BackoffDuration: duration(10 * time.Second)}
+
+		assert.Zero(t, failureBackoffRemaining(runnerWithFailures(nil, 3, now), now), "no policy means no backoff")
+		assert.Zero(t, failureBackoffRemaining(runnerWithFailures(policy, 0, now), now), "no failures means no backoff")
+		assert.Equal(t, 10*time.Second, failureBackoffRemaining(runnerWithFailures(policy, 1, now), now))
+		assert.Equal(t, 40*time.Second, failureBackoffRemaining(runnerWithFailures(policy, 3, now), now))
+		assert.Equal(t, 30*time.Second, failureBackoffRemaining(runnerWithFailures(policy, 3, now.Add(-10*time.Second)), now))
+		assert.Equal(t, maxFailureBackoff, failureBackoffRemaining(runnerWithFailures(policy, 100, now), now))
+		assert.Negative(t, int64(failureBackoffRemaining(runnerWithFailures(policy, 1, now.Add(-time.Minute)), now)))
+	})
+
+	t.Run("reset window", func(t *testing.T) {
+		policy := &v1alpha1.FailurePolicy{ResetWindow: duration(time.Hour)}
+
+		assert.False(t, failureCountExpired(runnerWithFailures(nil, 3, now.Add(-2*time.Hour)), now), "no policy never resets")
+		assert.False(t, failureCountExpired(runnerWithFailures(policy, 3, now.Add(-time.Minute)), now))
+		assert.True(t, failureCountExpired(runnerWithFailures(policy, 3, now.Add(-2*time.Hour)), now))
+	})
+}
+
+func TestJobCompletionTimeRemaining(t *testing.T) {
+	now := time.Now()
+	deletedAt := metav1.NewTime(now.Add(-time.Minute))
+
+	runner := &v1alpha1.EphemeralRunner{}
+	assert.Zero(t, jobCompletionTimeRemaining(runner, now), "no timeout means no wait")
+
+	runner.Spec.JobCompletionTimeout = &metav1.Duration{Duration: 5 * time.Minute}
+	assert.Zero(t, jobCompletionTimeRemaining(runner, now), "runner that is not deleted does not wait")
+
+	runner.DeletionTimestamp = &deletedAt
+	assert.Equal(t, 4*time.Minute, jobCompletionTimeRemaining(runner, now))
+
+	runner.Spec.JobCompletionTimeout = &metav1.Duration{Duration: 30 * time.Second}
+	assert.Negative(t, int64(jobCompletionTimeRemaining(runner, now)))
+}
+
+func TestUpdatePodSafeToEvict(t *testing.T) {
+	runner := newExampleRunner("runner", "default", "secret")
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runner.Name,
+			Namespace: runner.Namespace,
+			Annotations: map[string]string{
+				AnnotationKeyClusterAutoscalerSafeToEvict: "true",
+			},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+	}
+
+	get := func() string {
+		updated := new(corev1.Pod)
+		assert.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+		return updated.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict]
+	}
+
+	runner.Status.JobRequestId = 1
+	assert.NoError(t, r.updatePodSafeToEvict(context.Background(), runner, pod, logr.Discard()))
+	assert.Equal(t, "false", get(), "busy runner must not be evicted")
+
+	runner.Spec.PodTemplateSpec.Annotations = map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "true"}
+	runner.Status.JobRequestId = 0
+	pod.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict] = "false"
+	assert.NoError(t, r.updatePodSafeToEvict(context.Background(), runner, pod, logr.Discard()))
+	assert.Equal(t, "false", get(), "annotation set by the pod template must be left alone")
+}
+
+func TestPreemptedNodeRunners(t *testing.T) {
+	preempted := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "spot"},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{{Key: "aws-node-termination-handler/spot-itn", Effect: corev1.TaintEffectNoSchedule}},
+		},
+	}
+	healthy := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "on-demand"}}
+	newPod := func(name, nodeName string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+	runnerLabels := map[string]string{"actions-ephemeral-runner": "True"}
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				preempted,
+				healthy,
+				newPod("runner-1", "spot", runnerLabels),
+				newPod("runner-2", "on-demand", runnerLabels),
+				newPod("other", "spot", nil),
+			).
+			WithIndex(&corev1.Pod{}, podNodeNameKey, func(o client.Object) []string {
+				return []string{o.(*corev1.Pod).Spec.NodeName}
+			}).
+			Build(),
+		Log:              logr.Discard(),
+		PreemptionTaints: KnownPreemptionTaints,
+	}
+
+	ok, err := r.nodePreempted(context.Background(), "spot")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = r.nodePreempted(context.Background(), "on-demand")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = r.nodePreempted(context.Background(), "gone")
+	assert.NoError(t, err)
+	assert.False(t, ok, "missing node is not treated as preempted")
+
+	requests := r.runnersOnNode(preempted)
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "runner-1"}}}, requests)
+
+	r.PreemptionTaints = nil
+	ok, err = r.nodePreempted(context.Background(), "spot")
+	assert.NoError(t, err)
+	assert.False(t, ok, "preemption handling is disabled without taints")
+}
+
+func TestOrphanRunner(t *testing.T) {
+	runner := newExampleRunner("runner", "default", "secret")
+	runner.Finalizers = []string{ephemeralRunnerFinalizerName, ephemeralRunnerActionsFinalizerName}
+	runner.Annotations = map[string]string{v1alpha1.AnnotationKeyForceDelete: "true"}
+	runner.Status.RunnerId = 1
+	runner.Status.RunnerName = "runner"
+	runner.Status.Phase = corev1.PodRunning
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	recorder := record.NewFakeRecorder(1)
+	r := &EphemeralRunnerReconciler{
+		Client:   crfake.NewClientBuilder().WithScheme(scheme).WithObjects(runner).Build(),
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+
+	assert.NoError(t, r.orphanRunner(context.Background(), runner, logr.Discard()))
+
+	updated := new(v1alpha1.EphemeralRunner)
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(runner), updated))
+	assert.Equal(t, []string{ephemeralRunnerFinalizerName}, updated.Finalizers)
+
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, "RunnerOrphaned")
+	}
+}
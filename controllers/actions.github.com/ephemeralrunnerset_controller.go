@@ -20,9 +20,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/github/actions"
@@ -51,13 +55,38 @@ type EphemeralRunnerSetReconciler struct {
 	Scheme        *runtime.Scheme
 	ActionsClient actions.MultiClient
 
+	// OrphanedRunnerSweepInterval is how often the runners registered to the scale set
+	// are compared against the EphemeralRunners in the cluster to remove the ones left behind.
+	// Zero, the default, disables the sweep. Scale sets that are retained or shared by
+	// several AutoscalingRunnerSets are never swept.
+	OrphanedRunnerSweepInterval time.Duration
+	// WorkVolumeClaimSweepInterval is how often the work volume claims of the EphemeralRunners
+	// that are gone are deleted. Zero disables the sweep.
+	WorkVolumeClaimSweepInterval time.Duration
+
+	// RemoteCleanupTimeout is how long a deleted EphemeralRunnerSet retries removing its runners
+	// from the Actions service before it is force deleted. Zero retries forever.
+	RemoteCleanupTimeout time.Duration
+	// Config, when set, overrides OrphanedRunnerSweepInterval, WorkVolumeClaimSweepInterval and
+	// RemoteCleanupTimeout with the settings of the controller config map.
+	Config *ControllerConfig
+	// DefaultGitHubServerTLS is used by the actions client of the EphemeralRunnerSets without GitHubServerTLS.
+	DefaultGitHubServerTLS *DefaultGitHubServerTLS
+
 	resourceBuilder resourceBuilder
+
+	lastSweepMu              sync.Mutex
+	lastOrphanedRunnerSweep  map[types.NamespacedName]time.Time
+	lastWorkVolumeClaimSweep map[types.NamespacedName]time.Time
 }
 
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/status,verbs=get
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=list;delete
+//+kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets/status,verbs=get;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -104,6 +133,8 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 			return ctrl.Result{}, err
 		}
 
+		r.forgetSweeps(req.NamespacedName)
+
 		log.Info("Successfully removed finalizer after cleanup")
 		return ctrl.Result{}, nil
 	}
@@ -147,12 +178,30 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 
 	// cleanup finished runners and proceed
 	var errs []error
+	var succeededJobs, failedJobs int
 	for i := range finishedEphemeralRunners {
 		log.Info("Deleting finished ephemeral runner", "name", finishedEphemeralRunners[i].Name)
 		if err := r.Delete(ctx, finishedEphemeralRunners[i]); err != nil {
 			if !kerrors.IsNotFound(err) {
 				errs = append(errs, err)
 			}
+			continue
+		}
+		// The jobs are counted once, when their runner is deleted.
+		switch finishedEphemeralRunners[i].Status.JobResult {
+		case v1alpha1.JobResultSucceeded:
+			succeededJobs++
+		case v1alpha1.JobResultFailed:
+			failedJobs++
+		}
+	}
+
+	if succeededJobs > 0 || failedJobs > 0 {
+		if err := patchSubResource(ctx, r.Status(), ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Status.SucceededJobs += succeededJobs
+			obj.Status.FailedJobs += failedJobs
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update job counts: %v", err))
 		}
 	}
 
@@ -162,38 +211,116 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, mergedErrs
 	}
 
+	if err := r.updateEphemeralRunnerSettings(ctx, ephemeralRunnerSet, append(append(pendingEphemeralRunners, runningEphemeralRunners...), failedEphemeralRunners...), log); err != nil {
+		log.Error(err, "Failed to update the settings of the ephemeral runners")
+		return ctrl.Result{}, err
+	}
+
+	// The queued job hints the listener never matched with an assigned job stop adding runners.
+	hints, nextHintExpiry := unexpiredQueuedJobHints(ephemeralRunnerSet.Spec.QueuedJobHints, time.Now())
+	if len(hints) != len(ephemeralRunnerSet.Spec.QueuedJobHints) {
+		log.Info("Removing expired queued job hints", "expired", len(ephemeralRunnerSet.Spec.QueuedJobHints)-len(hints))
+		original := ephemeralRunnerSet.DeepCopy()
+		ephemeralRunnerSet.Spec.QueuedJobHints = hints
+		if err := r.Patch(ctx, ephemeralRunnerSet, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+			log.Error(err, "Failed to remove expired queued job hints")
+			return ctrl.Result{}, err
+		}
+	}
+
+	var nextIdleExpiry time.Duration
+	if timeout := ephemeralRunnerSet.Spec.IdleReplicasTimeout; timeout != nil && timeout.Duration > 0 {
+		var expired int
+		expired, nextIdleExpiry = expiredIdleEphemeralRunners(runningEphemeralRunners, timeout.Duration, time.Now())
+		if replicas := idleScaledDownReplicas(ephemeralRunnerSet, expired); replicas < ephemeralRunnerSet.Spec.Replicas {
+			log.Info("Scaling down ephemeral runners idle for longer than the idle timeout", "expired", expired, "replicas", replicas, "timeout", timeout.Duration)
+			if err := patch(ctx, r.Client, ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+				obj.Spec.Replicas = replicas
+				setScaleTrigger(obj, v1alpha1.ScaleTriggerIdleTimeout)
+			}); err != nil {
+				log.Error(err, "Failed to scale down idle ephemeral runners")
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
 	total := len(pendingEphemeralRunners) + len(runningEphemeralRunners) + len(failedEphemeralRunners)
-	log.Info("Scaling comparison", "current", total, "desired", ephemeralRunnerSet.Spec.Replicas)
+	busy := countBusyEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners)
+	desired, trigger := desiredReplicasWithTrigger(ephemeralRunnerSet, busy)
+	log.Info("Scaling comparison", "current", total, "desired", desired, "busy", busy)
 	switch {
-	case total < ephemeralRunnerSet.Spec.Replicas: // Handle scale up
-		count := ephemeralRunnerSet.Spec.Replicas - total
+	case total < desired: // Handle scale up
+		count := desired - total
 		log.Info("Creating new ephemeral runners (scale up)", "count", count)
-		if err := r.createEphemeralRunners(ctx, ephemeralRunnerSet, count, log); err != nil {
+		existing := existingEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners)
+		variants := templateVariantsToCreate(ephemeralRunnerSet, existing, count)
+		if err := r.createEphemeralRunners(ctx, ephemeralRunnerSet, variants, log); err != nil {
 			log.Error(err, "failed to make ephemeral runner")
 			return ctrl.Result{}, err
 		}
 
-	case total > ephemeralRunnerSet.Spec.Replicas: // Handle scale down scenario.
-		count := total - ephemeralRunnerSet.Spec.Replicas
+	case total > desired || hasTemplateVariantSurplus(ephemeralRunnerSet, existingEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners), desired): // Handle scale down scenario.
+		count := total - desired
 		log.Info("Deleting ephemeral runners (scale down)", "count", count)
-		if err := r.deleteIdleEphemeralRunners(ctx, ephemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners, count, log); err != nil {
+		if err := r.deleteIdleEphemeralRunners(ctx, ephemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners, count, desired, log); err != nil {
 			log.Error(err, "failed to delete idle runners")
 			return ctrl.Result{}, err
 		}
 	}
 
+	// The scale event is best effort: the old and new runner sets of a rollout append to the same events,
+	// and losing that race mustn't hold off scaling.
+	if err := r.recordScaleEvent(ctx, ephemeralRunnerSet, desired, trigger, log); err != nil {
+		log.Error(err, "Failed to record scale event")
+	}
+
 	// Update the status if needed.
-	if ephemeralRunnerSet.Status.CurrentReplicas != total {
-		log.Info("Updating status with current runners count", "count", total)
+	registrationToken := countRegistrationTokenEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners)
+	phases := countRunnerPhases(pendingEphemeralRunners, runningEphemeralRunners, finishedEphemeralRunners, failedEphemeralRunners, deletingEphemeralRunners)
+	if ephemeralRunnerSet.Status.CurrentReplicas != total ||
+		ephemeralRunnerSet.Status.RunningReplicas != len(runningEphemeralRunners) ||
+		ephemeralRunnerSet.Status.FailedReplicas != len(failedEphemeralRunners) ||
+		ephemeralRunnerSet.Status.RegistrationTokenReplicas != registrationToken ||
+		ephemeralRunnerSet.Status.RunnerPhases != phases {
+		log.Info("Updating status with current runners count", "count", total, "running", len(runningEphemeralRunners), "failed", len(failedEphemeralRunners), "registrationToken", registrationToken, "phases", phases)
 		if err := patchSubResource(ctx, r.Status(), ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
 			obj.Status.CurrentReplicas = total
+			obj.Status.RunningReplicas = len(runningEphemeralRunners)
+			obj.Status.FailedReplicas = len(failedEphemeralRunners)
+			obj.Status.RegistrationTokenReplicas = registrationToken
+			obj.Status.RunnerPhases = phases
 		}); err != nil {
 			log.Error(err, "Failed to update status with current runners count")
 			return ctrl.Result{}, err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	if sweepInterval := r.Config.orphanedRunnerSweepInterval(r.OrphanedRunnerSweepInterval); sweepInterval > 0 {
+		if r.orphanedRunnerSweepDue(req.NamespacedName) {
+			if err := r.sweepOrphanedRunners(ctx, ephemeralRunnerSet, log); err != nil {
+				// The sweep is best effort, the next one will retry.
+				log.Error(err, "Failed to sweep orphaned runners")
+			}
+		}
+		if nextIdleExpiry == 0 || sweepInterval < nextIdleExpiry {
+			nextIdleExpiry = sweepInterval
+		}
+	}
+	if sweepInterval := r.Config.workVolumeClaimSweepInterval(r.WorkVolumeClaimSweepInterval); sweepInterval > 0 {
+		if r.workVolumeClaimSweepDue(req.NamespacedName) {
+			if err := r.sweepOrphanedWorkVolumeClaims(ctx, ephemeralRunnerSet, log); err != nil {
+				log.Error(err, "Failed to sweep orphaned work volume claims")
+			}
+		}
+		if nextIdleExpiry == 0 || sweepInterval < nextIdleExpiry {
+			nextIdleExpiry = sweepInterval
+		}
+	}
+	if nextHintExpiry > 0 && (nextIdleExpiry == 0 || nextHintExpiry < nextIdleExpiry) {
+		nextIdleExpiry = nextHintExpiry
+	}
+
+	return ctrl.Result{RequeueAfter: nextIdleExpiry}, nil
 }
 
 func (r *EphemeralRunnerSetReconciler) cleanUpEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) (done bool, err error) {
@@ -209,6 +336,10 @@ func (r *EphemeralRunnerSetReconciler) cleanUpEphemeralRunners(ctx context.Conte
 		return true, nil
 	}
 
+	if forceDeleteRequested(ephemeralRunnerSet) {
+		return false, r.forceDeleteEphemeralRunners(ctx, ephemeralRunnerList, log)
+	}
+
 	pendingEphemeralRunners, runningEphemeralRunners, finishedEphemeralRunners, failedEphemeralRunners, deletingEphemeralRunners := categorizeEphemeralRunners(ephemeralRunnerList)
 
 	log.Info("Clean up runner counts",
@@ -241,21 +372,51 @@ func (r *EphemeralRunnerSetReconciler) cleanUpEphemeralRunners(ctx context.Conte
 
 	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunnerSet)
 	if err != nil {
+		if remoteCleanupTimedOut(ephemeralRunnerSet, r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), time.Now()) {
+			log.Info("Actions service client could not be initialized within the remote cleanup timeout. Force deleting the ephemeral runner set", "error", err.Error())
+			return false, forceDelete(ctx, r.Client, ephemeralRunnerSet)
+		}
 		return false, err
 	}
 
 	log.Info("Cleanup pending or running ephemeral runners")
 	errs = errs[0:0]
+	busy := 0
 	for _, ephemeralRunner := range append(pendingEphemeralRunners, runningEphemeralRunners...) {
+		switch {
+		case ephemeralRunner.Status.JobRequestId > 0:
+			// Let the runner finish its job. We get notified once it is done.
+			busy++
+			continue
+		case ephemeralRunner.Status.RunnerId == 0:
+			// Not registered with the service yet, so it can't be running a job.
+			log.Info("Deleting unregistered ephemeral runner", "name", ephemeralRunner.Name)
+			if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
 		log.Info("Removing the ephemeral runner from the service", "name", ephemeralRunner.Name)
-		_, err := r.deleteEphemeralRunnerWithActionsClient(ctx, ephemeralRunner, actionsClient, log)
+		ok, err := r.deleteEphemeralRunnerWithActionsClient(ctx, ephemeralRunner, actionsClient, log)
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err == nil && !ok {
+			busy++
+		}
+	}
+
+	if busy > 0 {
+		log.Info("Waiting for busy ephemeral runners to finish their jobs", "count", busy)
 	}
 
 	if len(errs) > 0 {
 		mergedErrs := multierr.Combine(errs...)
+		if remoteCleanupTimedOut(ephemeralRunnerSet, r.Config.remoteCleanupTimeout(r.RemoteCleanupTimeout), time.Now()) {
+			log.Info("Ephemeral runners could not be removed from the service within the remote cleanup timeout. Force deleting the ephemeral runner set", "error", mergedErrs.Error())
+			return false, forceDelete(ctx, r.Client, ephemeralRunnerSet)
+		}
 		log.Error(mergedErrs, "Failed to remove ephemeral runners from the service")
 		return false, mergedErrs
 	}
@@ -263,12 +424,35 @@ func (r *EphemeralRunnerSetReconciler) cleanUpEphemeralRunners(ctx context.Conte
 	return false, nil
 }
 
-// createEphemeralRunners provisions `count` number of v1alpha1.EphemeralRunner resources in the cluster.
-func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet, count int, log logr.Logger) error {
+// forceDeleteEphemeralRunners deletes the ephemeral runners, busy ones included,
+// without removing them from the service.
+func (r *EphemeralRunnerSetReconciler) forceDeleteEphemeralRunners(ctx context.Context, ephemeralRunnerList *v1alpha1.EphemeralRunnerList, log logr.Logger) error {
+	log.Info("Force deleting ephemeral runners", "count", len(ephemeralRunnerList.Items))
+	var errs []error
+	for i := range ephemeralRunnerList.Items {
+		ephemeralRunner := &ephemeralRunnerList.Items[i]
+		if err := forceDelete(ctx, r.Client, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+		if !ephemeralRunner.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// createEphemeralRunners provisions one v1alpha1.EphemeralRunner resource per entry of `variants` in the cluster,
+// using the pod template of the named template variant.
+func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet, variants []string, log logr.Logger) error {
 	// Track multiple errors at once and return the bundle.
 	errs := make([]error, 0)
-	for i := 0; i < count; i++ {
-		ephemeralRunner := r.resourceBuilder.newEphemeralRunner(runnerSet)
+	count := len(variants)
+	for i, variant := range variants {
+		ephemeralRunner := r.resourceBuilder.newEphemeralRunner(runnerSet, variant)
 
 		// Make sure that we own the resource we create.
 		if err := ctrl.SetControllerReference(runnerSet, ephemeralRunner, r.Scheme); err != nil {
@@ -277,7 +461,7 @@ func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Contex
 			continue
 		}
 
-		log.Info("Creating new ephemeral runner", "progress", i+1, "total", count)
+		log.Info("Creating new ephemeral runner", "progress", i+1, "total", count, "templateVariant", variant)
 		if err := r.Create(ctx, ephemeralRunner); err != nil {
 			log.Error(err, "failed to make ephemeral runner")
 			errs = append(errs, err)
@@ -297,8 +481,11 @@ func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Contex
 // if there are not enough ephemeral runners that have registered with Actions service.
 // When this happens, the next reconcile loop will try to delete the remaining ephemeral runners
 // after we get notified by any of the `v1alpha1.EphemeralRunner.Status` updates.
-func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner, count int, log logr.Logger) error {
-	runners := newEphemeralRunnerStepper(pendingEphemeralRunners, runningEphemeralRunners)
+// deleteIdleEphemeralRunners deletes up to count idle runners. The idle runners of the template variants
+// with more runners than desired are deleted first, even beyond count, so that the next scale up
+// creates the runners of the variants that are short of their desired replicas.
+func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners []*v1alpha1.EphemeralRunner, count, desired int, log logr.Logger) error {
+	runners := newEphemeralRunnerStepper(ephemeralRunnerSet.Spec.ScaleDownPolicy, pendingEphemeralRunners, runningEphemeralRunners)
 	if runners.len() == 0 {
 		log.Info("No pending or running ephemeral runners running at this time for scale down")
 		return nil
@@ -307,8 +494,9 @@ func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Co
 	if err != nil {
 		return fmt.Errorf("failed to create actions client for ephemeral runner replica set: %v", err)
 	}
-	var errs []error
-	deletedCount := 0
+
+	surplus := templateVariantSurplus(ephemeralRunnerSet, existingEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners), desired)
+	var surplusRunners, others []*v1alpha1.EphemeralRunner
 	for runners.next() {
 		ephemeralRunner := runners.object()
 		if ephemeralRunner.Status.RunnerId == 0 {
@@ -321,19 +509,29 @@ func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Co
 			continue
 		}
 
-		log.Info("Removing the idle ephemeral runner", "name", ephemeralRunner.Name)
-		ok, err := r.deleteEphemeralRunnerWithActionsClient(ctx, ephemeralRunner, actionsClient, log)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		if !ok {
+		if variant := ephemeralRunner.Labels[LabelKeyRunnerTemplateVariant]; surplus[variant] > 0 {
+			surplus[variant]--
+			surplusRunners = append(surplusRunners, ephemeralRunner)
 			continue
 		}
+		others = append(others, ephemeralRunner)
+	}
 
-		deletedCount++
-		if deletedCount == count {
+	var errs []error
+	deletedCount := 0
+	for i, ephemeralRunner := range append(surplusRunners, others...) {
+		if i >= len(surplusRunners) && deletedCount >= count {
 			break
 		}
+
+		log.Info("Removing the idle ephemeral runner", "name", ephemeralRunner.Name, "variant", ephemeralRunner.Labels[LabelKeyRunnerTemplateVariant])
+		ok, err := r.deleteEphemeralRunnerWithActionsClient(ctx, ephemeralRunner, actionsClient, log)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ok {
+			deletedCount++
+		}
 	}
 
 	return multierr.Combine(errs...)
@@ -361,13 +559,183 @@ func (r *EphemeralRunnerSetReconciler) deleteEphemeralRunnerWithActionsClient(ct
 	return true, nil
 }
 
+// updateEphemeralRunnerSettings applies the settings of the runner set that change without a rollout,
+// e.g. the failure policy, to its existing runners.
+func (r *EphemeralRunnerSetReconciler) updateEphemeralRunnerSettings(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, ephemeralRunners []*v1alpha1.EphemeralRunner, log logr.Logger) error {
+	policy := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.FailurePolicy
+	for _, ephemeralRunner := range ephemeralRunners {
+		if reflect.DeepEqual(ephemeralRunner.Spec.FailurePolicy, policy) {
+			continue
+		}
+
+		log.Info("Updating the failure policy of the ephemeral runner", "name", ephemeralRunner.Name)
+		if err := patch(ctx, r.Client, ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+			obj.Spec.FailurePolicy = policy
+		}); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to update the failure policy of ephemeral runner %s: %v", ephemeralRunner.Name, err)
+		}
+	}
+	return nil
+}
+
+// sweepOrphanedRunners removes runners registered to the scale set on the service
+// that are no longer backed by an EphemeralRunner, e.g. after a node crash.
+func (r *EphemeralRunnerSetReconciler) sweepOrphanedRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunnerSet)
+	if err != nil {
+		return fmt.Errorf("failed to create actions client for ephemeral runner replica set: %v", err)
+	}
+
+	reason, err := r.orphanedRunnerSweepSkipReason(ctx, ephemeralRunnerSet)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		log.V(1).Info("Skipping orphaned runner sweep", "reason", reason)
+		return nil
+	}
+
+	runnerScaleSetId := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.RunnerScaleSetId
+	runners, err := actionsClient.GetRunnersByScaleSet(ctx, runnerScaleSetId)
+	if err != nil {
+		return fmt.Errorf("failed to get runners of the scale set: %v", err)
+	}
+
+	if len(runners) == 0 {
+		return nil
+	}
+
+	// List EphemeralRunners only after fetching runners from the service. A runner is registered
+	// after its EphemeralRunner is observed, so any runner we got is already known to the cache.
+	// EphemeralRunners of every EphemeralRunnerSet are considered, since old and new
+	// EphemeralRunnerSets of the same AutoscalingRunnerSet share the scale set.
+	ephemeralRunnerList := new(v1alpha1.EphemeralRunnerList)
+	if err := r.List(ctx, ephemeralRunnerList); err != nil {
+		return fmt.Errorf("failed to list ephemeral runners: %v", err)
+	}
+
+	knownNames := make(map[string]bool)
+	knownIds := make(map[int]bool)
+	for _, ephemeralRunner := range ephemeralRunnerList.Items {
+		if ephemeralRunner.Spec.RunnerScaleSetId != runnerScaleSetId {
+			continue
+		}
+		knownNames[ephemeralRunner.Name] = true
+		if ephemeralRunner.Status.RunnerId != 0 {
+			knownIds[ephemeralRunner.Status.RunnerId] = true
+		}
+	}
+
+	var errs []error
+	for _, runner := range runners {
+		if knownNames[runner.Name] || knownIds[runner.Id] {
+			continue
+		}
+
+		log.Info("Removing orphaned runner from the service", "runnerName", runner.Name, "runnerId", runner.Id)
+		if err := actionsClient.RemoveRunner(ctx, int64(runner.Id)); err != nil {
+			actionsError := &actions.ActionsError{}
+			if errors.As(err, &actionsError) &&
+				actionsError.StatusCode == http.StatusBadRequest &&
+				strings.Contains(actionsError.ExceptionName, "JobStillRunningException") {
+				log.Info("Skipping orphaned runner since it is still running a job", "runnerName", runner.Name, "runnerId", runner.Id)
+				continue
+			}
+
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Info("Removed orphaned runner from the service", "runnerName", runner.Name, "runnerId", runner.Id)
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// orphanedRunnerSweepSkipReason returns why the runners of the scale set must not be swept, if they must not.
+// The runners of a scale set that is retained, or shared by several AutoscalingRunnerSets, may belong to
+// EphemeralRunners this controller doesn't see, e.g. in another cluster.
+func (r *EphemeralRunnerSetReconciler) orphanedRunnerSweepSkipReason(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) (string, error) {
+	owner := metav1.GetControllerOf(ephemeralRunnerSet)
+	if owner == nil || owner.Kind != "AutoscalingRunnerSet" {
+		return "", nil
+	}
+
+	autoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: owner.Name}, autoscalingRunnerSet); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "the autoscaling runner set is gone", nil
+		}
+		return "", fmt.Errorf("failed to get autoscaling runner set %s: %v", owner.Name, err)
+	}
+
+	if autoscalingRunnerSet.Spec.DeletionPolicy == v1alpha1.RetainDeletionPolicy {
+		return "the deletion policy of the autoscaling runner set is Retain", nil
+	}
+
+	runnerScaleSetId := autoscalingRunnerSet.Annotations[runnerScaleSetIdKey]
+	autoscalingRunnerSetList := new(v1alpha1.AutoscalingRunnerSetList)
+	if err := r.List(ctx, autoscalingRunnerSetList); err != nil {
+		return "", fmt.Errorf("failed to list autoscaling runner sets: %v", err)
+	}
+	for _, other := range autoscalingRunnerSetList.Items {
+		if other.UID != autoscalingRunnerSet.UID && other.Annotations[runnerScaleSetIdKey] == runnerScaleSetId {
+			return fmt.Sprintf("the runner scale set is shared with autoscaling runner set %s/%s", other.Namespace, other.Name), nil
+		}
+	}
+
+	return "", nil
+}
+
+// orphanedRunnerSweepDue reports whether the orphaned runner sweep should run for the given
+// EphemeralRunnerSet, and if so records the current time as the last sweep.
+func (r *EphemeralRunnerSetReconciler) orphanedRunnerSweepDue(key types.NamespacedName) bool {
+	return r.sweepDue(&r.lastOrphanedRunnerSweep, key, r.Config.orphanedRunnerSweepInterval(r.OrphanedRunnerSweepInterval))
+}
+
+// workVolumeClaimSweepDue reports whether the work volume claim sweep should run for the given
+// EphemeralRunnerSet, and if so records the current time as the last sweep.
+func (r *EphemeralRunnerSetReconciler) workVolumeClaimSweepDue(key types.NamespacedName) bool {
+	return r.sweepDue(&r.lastWorkVolumeClaimSweep, key, r.Config.workVolumeClaimSweepInterval(r.WorkVolumeClaimSweepInterval))
+}
+
+func (r *EphemeralRunnerSetReconciler) sweepDue(lastSweep *map[types.NamespacedName]time.Time, key types.NamespacedName, interval time.Duration) bool {
+	r.lastSweepMu.Lock()
+	defer r.lastSweepMu.Unlock()
+
+	if *lastSweep == nil {
+		*lastSweep = make(map[types.NamespacedName]time.Time)
+	}
+
+	now := time.Now()
+	if last, ok := (*lastSweep)[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+
+	(*lastSweep)[key] = now
+	return true
+}
+
+func (r *EphemeralRunnerSetReconciler) forgetSweeps(key types.NamespacedName) {
+	r.lastSweepMu.Lock()
+	defer r.lastSweepMu.Unlock()
+
+	delete(r.lastOrphanedRunnerSweep, key)
+	delete(r.lastWorkVolumeClaimSweep, key)
+}
+
 func (r *EphemeralRunnerSetReconciler) actionsClientFor(ctx context.Context, rs *v1alpha1.EphemeralRunnerSet) (actions.ActionsService, error) {
 	secret := new(corev1.Secret)
 	if err := r.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: rs.Spec.EphemeralRunnerSpec.GitHubConfigSecret}, secret); err != nil {
 		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
 
-	return r.ActionsClient.GetClientFromSecret(ctx, rs.Spec.EphemeralRunnerSpec.GitHubConfigUrl, rs.Namespace, secret.Data)
+	options, err := actionsClientOptions(ctx, r.Client, rs.Namespace, rs.Spec.EphemeralRunnerSpec.Proxy, rs.Spec.EphemeralRunnerSpec.GitHubServerTLS, r.DefaultGitHubServerTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ActionsClient.GetClientFromSecret(ctx, rs.Spec.EphemeralRunnerSpec.GitHubConfigUrl, rs.Namespace, secret.Data, options...)
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -406,13 +774,9 @@ type ephemeralRunnerStepper struct {
 	index int
 }
 
-func newEphemeralRunnerStepper(pending, running []*v1alpha1.EphemeralRunner) *ephemeralRunnerStepper {
-	sort.Slice(pending, func(i, j int) bool {
-		return pending[i].GetCreationTimestamp().Time.Before(pending[j].GetCreationTimestamp().Time)
-	})
-	sort.Slice(running, func(i, j int) bool {
-		return running[i].GetCreationTimestamp().Time.Before(running[j].GetCreationTimestamp().Time)
-	})
+func newEphemeralRunnerStepper(policy v1alpha1.ScaleDownPolicy, pending, running []*v1alpha1.EphemeralRunner) *ephemeralRunnerStepper {
+	sortScaleDownCandidates(policy, pending)
+	sortScaleDownCandidates(policy, running)
 
 	return &ephemeralRunnerStepper{
 		items: append(pending, running...),
@@ -420,6 +784,32 @@ func newEphemeralRunnerStepper(pending, running []*v1alpha1.EphemeralRunner) *ep
 	}
 }
 
+// sortScaleDownCandidates orders the runners in which they are removed when scaling down:
+// idle runners first, in the order of the scale down policy, then the runners running a job.
+func sortScaleDownCandidates(policy v1alpha1.ScaleDownPolicy, runners []*v1alpha1.EphemeralRunner) {
+	if policy == v1alpha1.ScaleDownPolicyRandom {
+		rand.Shuffle(len(runners), func(i, j int) {
+			runners[i], runners[j] = runners[j], runners[i]
+		})
+	}
+
+	sort.SliceStable(runners, func(i, j int) bool {
+		if idleI, idleJ := runners[i].Status.JobRequestId == 0, runners[j].Status.JobRequestId == 0; idleI != idleJ {
+			return idleI
+		}
+
+		createdI, createdJ := runners[i].GetCreationTimestamp().Time, runners[j].GetCreationTimestamp().Time
+		switch policy {
+		case v1alpha1.ScaleDownPolicyRandom:
+			return false
+		case v1alpha1.ScaleDownPolicyNewest:
+			return createdJ.Before(createdI)
+		default:
+			return createdI.Before(createdJ)
+		}
+	})
+}
+
 func (s *ephemeralRunnerStepper) next() bool {
 	if s.index+1 < len(s.items) {
 		s.index++
@@ -439,6 +829,208 @@ func (s *ephemeralRunnerStepper) len() int {
 	return len(s.items)
 }
 
+func existingEphemeralRunners(pendingEphemeralRunners, runningEphemeralRunners, failedEphemeralRunners []*v1alpha1.EphemeralRunner) []*v1alpha1.EphemeralRunner {
+	existing := make([]*v1alpha1.EphemeralRunner, 0, len(pendingEphemeralRunners)+len(runningEphemeralRunners)+len(failedEphemeralRunners))
+	existing = append(existing, pendingEphemeralRunners...)
+	existing = append(existing, runningEphemeralRunners...)
+	return append(existing, failedEphemeralRunners...)
+}
+
+// templateVariantSurplus returns how many runners each template variant has above its desired replicas.
+// The default pod template, an empty string, gets the desired runners left over by the variants.
+func templateVariantSurplus(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, existing []*v1alpha1.EphemeralRunner, desired int) map[string]int {
+	surplus := make(map[string]int)
+	if len(ephemeralRunnerSet.Spec.TemplateVariants) == 0 {
+		return surplus
+	}
+
+	for _, runner := range existing {
+		surplus[runner.Labels[LabelKeyRunnerTemplateVariant]]++
+	}
+
+	defaultReplicas := desired
+	for _, variant := range ephemeralRunnerSet.Spec.TemplateVariants {
+		replicas := ephemeralRunnerSet.Spec.VariantReplicas[variant.Name]
+		surplus[variant.Name] -= replicas
+		defaultReplicas -= replicas
+	}
+	if defaultReplicas > 0 {
+		surplus[""] -= defaultReplicas
+	}
+
+	for variant, n := range surplus {
+		if n <= 0 {
+			delete(surplus, variant)
+		}
+	}
+	return surplus
+}
+
+// hasTemplateVariantSurplus reports whether a template variant has more runners than desired.
+func hasTemplateVariantSurplus(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, existing []*v1alpha1.EphemeralRunner, desired int) bool {
+	return len(templateVariantSurplus(ephemeralRunnerSet, existing, desired)) > 0
+}
+
+// templateVariantsToCreate returns the template variant of each of the `count` runners
+// to create, filling up the variants that are short of their desired replicas first.
+// An empty string stands for the default pod template.
+func templateVariantsToCreate(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, existing []*v1alpha1.EphemeralRunner, count int) []string {
+	current := make(map[string]int)
+	for _, runner := range existing {
+		current[runner.Labels[LabelKeyRunnerTemplateVariant]]++
+	}
+
+	variants := make([]string, 0, count)
+	for _, variant := range ephemeralRunnerSet.Spec.TemplateVariants {
+		missing := ephemeralRunnerSet.Spec.VariantReplicas[variant.Name] - current[variant.Name]
+		for ; missing > 0 && len(variants) < count; missing-- {
+			variants = append(variants, variant.Name)
+		}
+	}
+
+	for len(variants) < count {
+		variants = append(variants, "")
+	}
+
+	return variants
+}
+
+// desiredReplicas returns the number of runners of the runner set: the replicas requested by the listener
+// and a runner for each queued job hint, or more to keep the reserved replicas or the warm pool idle on top of the busy runners, within the limits of the runner set
+// and its share of the global budget and of the cluster capacity.
+func desiredReplicas(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, busy int) int {
+	desired, _ := desiredReplicasWithTrigger(ephemeralRunnerSet, busy)
+	return desired
+}
+
+// desiredReplicasWithTrigger returns the desired replicas of the runner set, and what decided them.
+func desiredReplicasWithTrigger(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, busy int) (int, v1alpha1.ScaleTrigger) {
+	desired, trigger := ephemeralRunnerSet.Spec.Replicas, scaleTriggerOf(ephemeralRunnerSet)
+	if hints := len(ephemeralRunnerSet.Spec.QueuedJobHints); hints > 0 {
+		desired, trigger = desired+hints, v1alpha1.ScaleTriggerWorkflowJobWebhook
+	}
+	if reserved := ephemeralRunnerSet.Spec.ReservedReplicas; reserved > desired {
+		desired, trigger = reserved, v1alpha1.ScaleTriggerReservedRunners
+	}
+	if warm := ephemeralRunnerSet.Spec.WarmReplicas; warm > 0 {
+		withWarm := busy + warm
+		if limit := ephemeralRunnerSet.Spec.WarmReplicasLimit; limit != nil && *limit < withWarm {
+			withWarm = *limit
+		}
+		if withWarm > desired {
+			desired, trigger = withWarm, v1alpha1.ScaleTriggerWarmPool
+		}
+	}
+	if ephemeralRunnerSet.Spec.MaxReplicas != nil && *ephemeralRunnerSet.Spec.MaxReplicas < desired {
+		desired, trigger = *ephemeralRunnerSet.Spec.MaxReplicas, v1alpha1.ScaleTriggerRollout
+	}
+	if ephemeralRunnerSet.Spec.BudgetReplicas != nil && *ephemeralRunnerSet.Spec.BudgetReplicas < desired {
+		desired, trigger = *ephemeralRunnerSet.Spec.BudgetReplicas, v1alpha1.ScaleTriggerBudget
+	}
+	if ephemeralRunnerSet.Spec.CapacityReplicas != nil && *ephemeralRunnerSet.Spec.CapacityReplicas < desired {
+		desired, trigger = *ephemeralRunnerSet.Spec.CapacityReplicas, v1alpha1.ScaleTriggerClusterCapacity
+	}
+	return desired, trigger
+}
+
+// unexpiredQueuedJobHints returns the queued job hints received within queuedJobHintRetention,
+// and how long until the next one expires.
+func unexpiredQueuedJobHints(hints []v1alpha1.QueuedJobHint, now time.Time) (unexpired []v1alpha1.QueuedJobHint, nextExpiry time.Duration) {
+	for _, hint := range hints {
+		remaining := hint.QueuedAt.Add(queuedJobHintRetention).Sub(now)
+		if remaining <= 0 {
+			continue
+		}
+		unexpired = append(unexpired, hint)
+		if nextExpiry == 0 || remaining < nextExpiry {
+			nextExpiry = remaining
+		}
+	}
+	return unexpired, nextExpiry
+}
+
+// expiredIdleEphemeralRunners returns the number of registered runners without a job that have been
+// idle for longer than the timeout, and how long until the next idle runner expires.
+// Ephemeral runners only run a single job, so they are idle since they were created.
+func expiredIdleEphemeralRunners(runningEphemeralRunners []*v1alpha1.EphemeralRunner, timeout time.Duration, now time.Time) (expired int, nextExpiry time.Duration) {
+	for _, runner := range runningEphemeralRunners {
+		if runner.Status.RunnerId == 0 || runner.Status.JobRequestId != 0 {
+			continue
+		}
+
+		remaining := runner.CreationTimestamp.Add(timeout).Sub(now)
+		if remaining <= 0 {
+			expired++
+			continue
+		}
+		if nextExpiry == 0 || remaining < nextExpiry {
+			nextExpiry = remaining
+		}
+	}
+	return expired, nextExpiry
+}
+
+// idleScaledDownReplicas returns the replicas of the runner set without the expired idle runners,
+// down to MinReplicas. It never scales up.
+func idleScaledDownReplicas(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, expired int) int {
+	replicas := ephemeralRunnerSet.Spec.Replicas - expired
+	if replicas < ephemeralRunnerSet.Spec.MinReplicas {
+		replicas = ephemeralRunnerSet.Spec.MinReplicas
+	}
+	if replicas > ephemeralRunnerSet.Spec.Replicas {
+		replicas = ephemeralRunnerSet.Spec.Replicas
+	}
+	return replicas
+}
+
+// countBusyEphemeralRunners returns the number of runners a job was assigned to.
+func countBusyEphemeralRunners(ephemeralRunners ...[]*v1alpha1.EphemeralRunner) int {
+	busy := 0
+	for _, runners := range ephemeralRunners {
+		for _, runner := range runners {
+			if runner.Status.JobRequestId != 0 {
+				busy++
+			}
+		}
+	}
+	return busy
+}
+
+// countRegistrationTokenEphemeralRunners returns how many of the runners register with a registration token.
+func countRegistrationTokenEphemeralRunners(ephemeralRunners ...[]*v1alpha1.EphemeralRunner) int {
+	count := 0
+	for _, runners := range ephemeralRunners {
+		for _, runner := range runners {
+			if runner.Status.RegistrationMethod == v1alpha1.RunnerRegistrationMethodRegistrationToken {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// countRunnerPhases counts the runners in each phase. Runners with a running pod are registering until
+// their pod is ready, and idle until a job is assigned to them. The runner ID of JIT runners is known
+// before their pod is created, so it doesn't tell that the runner is online.
+func countRunnerPhases(pending, running, finished, failed, deleting []*v1alpha1.EphemeralRunner) v1alpha1.RunnerPhaseCounts {
+	phases := v1alpha1.RunnerPhaseCounts{
+		PendingPod: len(pending),
+		Finishing:  len(finished) + len(deleting),
+		Failed:     len(failed),
+	}
+	for _, runner := range running {
+		switch {
+		case runner.Status.JobRequestId != 0:
+			phases.RunningJob++
+		case !runner.Status.Ready:
+			phases.Registering++
+		default:
+			phases.Idle++
+		}
+	}
+	return phases
+}
+
 func categorizeEphemeralRunners(ephemeralRunnerList *v1alpha1.EphemeralRunnerList) (pendingEphemeralRunners, runningEphemeralRunners, finishedEphemeralRunners, failedEphemeralRunners, deletingEphemeralRunners []*v1alpha1.EphemeralRunner) {
 	for i := range ephemeralRunnerList.Items {
 		r := &ephemeralRunnerList.Items[i]
@@ -3,12 +3,20 @@ package actionsgithubcom
 import (
 	"context"
 	"fmt"
+	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -16,6 +24,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	actionsv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/github/actions/fake"
 )
 
@@ -584,4 +593,583 @@ var _ = Describe("Test EphemeralRunnerSet controller", func() {
 				ephemeralRunnerSetTestInterval).Should(BeEquivalentTo(0), "0 EphemeralRunner should be created")
 		})
 	})
+
+	Context("When the EphemeralRunnerSet has a replica limit", func() {
+		It("It should not create more EphemeralRunners than MaxReplicas", func() {
+			maxReplicas := 2
+			updated := new(actionsv1alpha1.EphemeralRunnerSet)
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: ephemeralRunnerSet.Name, Namespace: ephemeralRunnerSet.Namespace}, updated)
+			Expect(err).NotTo(HaveOccurred(), "failed to get EphemeralRunnerSet")
+
+			updated.Spec.Replicas = 5
+			updated.Spec.MaxReplicas = &maxReplicas
+			err = k8sClient.Update(ctx, updated)
+			Expect(err).NotTo(HaveOccurred(), "failed to update EphemeralRunnerSet")
+
+			countRunners := func() (int, error) {
+				runnerList := new(actionsv1alpha1.EphemeralRunnerList)
+				err := k8sClient.List(ctx, runnerList, client.InNamespace(ephemeralRunnerSet.Namespace))
+				if err != nil {
+					return -1, err
+				}
+
+				return len(runnerList.Items), nil
+			}
+
+			Eventually(
+				countRunners,
+				ephemeralRunnerSetTestTimeout,
+				ephemeralRunnerSetTestInterval).Should(BeEquivalentTo(2), "2 EphemeralRunner should be created")
+
+			Consistently(
+				countRunners,
+				ephemeralRunnerSetTestTimeout,
+				ephemeralRunnerSetTestInterval).Should(BeEquivalentTo(2), "EphemeralRunner count should not exceed MaxReplicas")
+
+			// Removing the limit scales up to the desired replicas
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: ephemeralRunnerSet.Name, Namespace: ephemeralRunnerSet.Namespace}, updated)
+			Expect(err).NotTo(HaveOccurred(), "failed to get EphemeralRunnerSet")
+
+			updated.Spec.MaxReplicas = nil
+			err = k8sClient.Update(ctx, updated)
+			Expect(err).NotTo(HaveOccurred(), "failed to update EphemeralRunnerSet")
+
+			Eventually(
+				countRunners,
+				ephemeralRunnerSetTestTimeout,
+				ephemeralRunnerSetTestInterval).Should(BeEquivalentTo(5), "5 EphemeralRunner should be created")
+		})
+	})
 })
+
+func TestEphemeralRunnerSetReconciler_sweepOrphanedRunners(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, actionsv1alpha1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(ephemeralRunnerSetTestGitHubToken)},
+	}
+
+	ephemeralRunnerSet := &actionsv1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-runnerset", Namespace: "default"},
+		Spec: actionsv1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: actionsv1alpha1.EphemeralRunnerSpec{
+				GitHubConfigUrl:    "https://github.com/owner/repo",
+				GitHubConfigSecret: secret.Name,
+				RunnerScaleSetId:   100,
+			},
+		},
+	}
+
+	known := &actionsv1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-runnerset-runner-known", Namespace: "default"},
+		Spec:       actionsv1alpha1.EphemeralRunnerSpec{RunnerScaleSetId: 100},
+	}
+	// Belongs to an older EphemeralRunnerSet of the same scale set.
+	registered := &actionsv1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "old-runnerset-runner-registered", Namespace: "default"},
+		Spec:       actionsv1alpha1.EphemeralRunnerSpec{RunnerScaleSetId: 100},
+		Status:     actionsv1alpha1.EphemeralRunnerStatus{RunnerId: 2},
+	}
+
+	client := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(secret, ephemeralRunnerSet, known, registered).
+		Build()
+
+	actionsClient := &actions.MockActionsService{}
+	actionsClient.On("GetRunnersByScaleSet", mock.Anything, 100).Return([]actions.RunnerReference{
+		{Id: 1, Name: known.Name, RunnerScaleSetId: 100},
+		{Id: 2, Name: registered.Name, RunnerScaleSetId: 100},
+		{Id: 3, Name: "test-runnerset-runner-orphaned", RunnerScaleSetId: 100},
+	}, nil)
+	actionsClient.On("RemoveRunner", mock.Anything, int64(3)).Return(nil)
+
+	r := &EphemeralRunnerSetReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+	}
+
+	err := r.sweepOrphanedRunners(context.Background(), ephemeralRunnerSet, logr.Discard())
+	require.NoError(t, err)
+
+	actionsClient.AssertExpectations(t)
+	actionsClient.AssertNumberOfCalls(t, "RemoveRunner", 1)
+}
+
+func TestEphemeralRunnerSetReconciler_orphanedRunnerSweepSkipReason(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, actionsv1alpha1.AddToScheme(scheme))
+
+	newAutoscalingRunnerSet := func(namespace string, policy actionsv1alpha1.DeletionPolicy) *actionsv1alpha1.AutoscalingRunnerSet {
+		return &actionsv1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-asrs",
+				Namespace:   namespace,
+				UID:         types.UID(namespace + "-uid"),
+				Annotations: map[string]string{runnerScaleSetIdKey: "100"},
+			},
+			Spec: actionsv1alpha1.AutoscalingRunnerSetSpec{DeletionPolicy: policy},
+		}
+	}
+	newEphemeralRunnerSet := func(owner *actionsv1alpha1.AutoscalingRunnerSet) *actionsv1alpha1.EphemeralRunnerSet {
+		return &actionsv1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-runnerset",
+				Namespace: owner.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(owner, actionsv1alpha1.GroupVersion.WithKind("AutoscalingRunnerSet")),
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		objects    []runtime.Object
+		wantReason string
+	}{
+		"exclusive scale set is swept": {
+			objects: []runtime.Object{newAutoscalingRunnerSet("default", "")},
+		},
+		"retained scale set is not swept": {
+			objects:    []runtime.Object{newAutoscalingRunnerSet("default", actionsv1alpha1.RetainDeletionPolicy)},
+			wantReason: "the deletion policy of the autoscaling runner set is Retain",
+		},
+		"shared scale set is not swept": {
+			objects:    []runtime.Object{newAutoscalingRunnerSet("default", ""), newAutoscalingRunnerSet("other", "")},
+			wantReason: "the runner scale set is shared with autoscaling runner set other/test-asrs",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &EphemeralRunnerSetReconciler{
+				Client: crfake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(tc.objects...).Build(),
+				Scheme: scheme,
+			}
+
+			reason, err := r.orphanedRunnerSweepSkipReason(context.Background(), newEphemeralRunnerSet(tc.objects[0].(*actionsv1alpha1.AutoscalingRunnerSet)))
+			require.NoError(t, err)
+			require.Equal(t, tc.wantReason, reason)
+		})
+	}
+}
+
+func TestEphemeralRunnerSetReconciler_updateEphemeralRunnerSettings(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, actionsv1alpha1.AddToScheme(scheme))
+
+	maxFailures := 2
+	policy := &actionsv1alpha1.FailurePolicy{MaxFailures: &maxFailures}
+	ephemeralRunnerSet := &actionsv1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-runnerset", Namespace: "default"},
+		Spec: actionsv1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: actionsv1alpha1.EphemeralRunnerSpec{FailurePolicy: policy},
+		},
+	}
+	outdated := &actionsv1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-runnerset-runner-outdated", Namespace: "default"},
+	}
+	client := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(outdated).Build()
+
+	r := &EphemeralRunnerSetReconciler{Client: client, Scheme: scheme}
+	err := r.updateEphemeralRunnerSettings(context.Background(), ephemeralRunnerSet, []*actionsv1alpha1.EphemeralRunner{outdated}, logr.Discard())
+	require.NoError(t, err)
+
+	updated := new(actionsv1alpha1.EphemeralRunner)
+	require.NoError(t, client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: outdated.Name}, updated))
+	require.Equal(t, policy, updated.Spec.FailurePolicy)
+}
+
+func TestEphemeralRunnerSetReconciler_orphanedRunnerSweepDue(t *testing.T) {
+	r := &EphemeralRunnerSetReconciler{OrphanedRunnerSweepInterval: time.Hour}
+	key := types.NamespacedName{Namespace: "default", Name: "test-runnerset"}
+
+	require.True(t, r.orphanedRunnerSweepDue(key), "first sweep should be due")
+	require.False(t, r.orphanedRunnerSweepDue(key), "sweep should not be due before the interval elapsed")
+
+	r.forgetSweeps(key)
+	require.True(t, r.orphanedRunnerSweepDue(key), "sweep should be due after the set is forgotten")
+}
+
+func TestEphemeralRunnerSetReconciler_workVolumeClaimSweepDue(t *testing.T) {
+	r := &EphemeralRunnerSetReconciler{WorkVolumeClaimSweepInterval: time.Hour}
+	key := types.NamespacedName{Namespace: "default", Name: "test-runnerset"}
+
+	require.True(t, r.orphanedRunnerSweepDue(key), "orphaned runner sweep should be due")
+	require.True(t, r.workVolumeClaimSweepDue(key), "work volume claim sweep should be due independently of the orphaned runner sweep")
+	require.False(t, r.workVolumeClaimSweepDue(key), "sweep should not be due before the interval elapsed")
+
+	r.forgetSweeps(key)
+	require.True(t, r.workVolumeClaimSweepDue(key), "sweep should be due after the set is forgotten")
+}
+
+func TestEphemeralRunnerSetReconciler_cleanUpEphemeralRunnersWaitsForBusyRunners(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, actionsv1alpha1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(ephemeralRunnerSetTestGitHubToken)},
+	}
+
+	ephemeralRunnerSet := &actionsv1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-runnerset", Namespace: "default"},
+		Spec: actionsv1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: actionsv1alpha1.EphemeralRunnerSpec{
+				GitHubConfigUrl:    "https://github.com/owner/repo",
+				GitHubConfigSecret: secret.Name,
+				RunnerScaleSetId:   100,
+			},
+		},
+	}
+
+	isController := true
+	newRunner := func(name string, status actionsv1alpha1.EphemeralRunnerStatus) *actionsv1alpha1.EphemeralRunner {
+		return &actionsv1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: actionsv1alpha1.GroupVersion.String(),
+						Kind:       "EphemeralRunnerSet",
+						Name:       ephemeralRunnerSet.Name,
+						Controller: &isController,
+					},
+				},
+			},
+			Status: status,
+		}
+	}
+
+	idle := newRunner("idle", actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, RunnerId: 1})
+	busy := newRunner("busy", actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, RunnerId: 2, JobRequestId: 10})
+	unregistered := newRunner("unregistered", actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodPending})
+
+	client := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(secret, ephemeralRunnerSet, idle, busy, unregistered).
+		WithIndex(&actionsv1alpha1.EphemeralRunner{}, ephemeralRunnerSetReconcilerOwnerKey, func(o client.Object) []string {
+			owner := metav1.GetControllerOf(o)
+			if owner == nil {
+				return nil
+			}
+			return []string{owner.Name}
+		}).
+		Build()
+
+	actionsClient := &actions.MockActionsService{}
+	actionsClient.On("RemoveRunner", mock.Anything, int64(1)).Return(nil)
+
+	r := &EphemeralRunnerSetReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+	}
+
+	done, err := r.cleanUpEphemeralRunners(context.Background(), ephemeralRunnerSet, logr.Discard())
+	require.NoError(t, err)
+	require.False(t, done)
+
+	actionsClient.AssertExpectations(t)
+	actionsClient.AssertNumberOfCalls(t, "RemoveRunner", 1)
+
+	runners := new(actionsv1alpha1.EphemeralRunnerList)
+	require.NoError(t, client.List(context.Background(), runners))
+	require.Len(t, runners.Items, 1)
+	require.Equal(t, busy.Name, runners.Items[0].Name)
+}
+
+func TestEphemeralRunnerSetReconciler_cleanUpEphemeralRunnersForceDelete(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, actionsv1alpha1.AddToScheme(scheme))
+
+	ephemeralRunnerSet := &actionsv1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-runnerset",
+			Namespace:   "default",
+			Annotations: map[string]string{actionsv1alpha1.AnnotationKeyForceDelete: "true"},
+		},
+		Spec: actionsv1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: actionsv1alpha1.EphemeralRunnerSpec{
+				GitHubConfigUrl:    "https://github.com/owner/repo",
+				GitHubConfigSecret: "github-config-secret",
+				RunnerScaleSetId:   100,
+			},
+		},
+	}
+
+	isController := true
+	busy := &actionsv1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "busy",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: actionsv1alpha1.GroupVersion.String(),
+					Kind:       "EphemeralRunnerSet",
+					Name:       ephemeralRunnerSet.Name,
+					Controller: &isController,
+				},
+			},
+		},
+		Status: actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, RunnerId: 1, JobRequestId: 10},
+	}
+
+	client := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(ephemeralRunnerSet, busy).
+		WithIndex(&actionsv1alpha1.EphemeralRunner{}, ephemeralRunnerSetReconcilerOwnerKey, func(o client.Object) []string {
+			owner := metav1.GetControllerOf(o)
+			if owner == nil {
+				return nil
+			}
+			return []string{owner.Name}
+		}).
+		Build()
+
+	actionsClient := &actions.MockActionsService{}
+
+	r := &EphemeralRunnerSetReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+	}
+
+	done, err := r.cleanUpEphemeralRunners(context.Background(), ephemeralRunnerSet, logr.Discard())
+	require.NoError(t, err)
+	require.False(t, done)
+
+	actionsClient.AssertNotCalled(t, "RemoveRunner", mock.Anything, mock.Anything)
+
+	runners := new(actionsv1alpha1.EphemeralRunnerList)
+	require.NoError(t, client.List(context.Background(), runners))
+	require.Empty(t, runners.Items, "Busy runners should be deleted when the ephemeral runner set is force deleted")
+}
+
+func TestTemplateVariantsToCreate(t *testing.T) {
+	runnerSet := &actionsv1alpha1.EphemeralRunnerSet{
+		Spec: actionsv1alpha1.EphemeralRunnerSetSpec{
+			TemplateVariants: []actionsv1alpha1.TemplateVariant{
+				{Name: "gpu"},
+				{Name: "large"},
+			},
+			VariantReplicas: map[string]int{"gpu": 2, "large": 1},
+		},
+	}
+
+	existing := []*actionsv1alpha1.EphemeralRunner{
+		{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelKeyRunnerTemplateVariant: "gpu"}}},
+		{},
+	}
+
+	require.Equal(t, []string{"gpu", "large", ""}, templateVariantsToCreate(runnerSet, existing, 3))
+	require.Equal(t, []string{"gpu"}, templateVariantsToCreate(runnerSet, existing, 1))
+	require.Equal(t, []string{"", ""}, templateVariantsToCreate(&actionsv1alpha1.EphemeralRunnerSet{}, existing, 2))
+}
+
+func TestTemplateVariantSurplus(t *testing.T) {
+	runnerSet := &actionsv1alpha1.EphemeralRunnerSet{
+		Spec: actionsv1alpha1.EphemeralRunnerSetSpec{
+			TemplateVariants: []actionsv1alpha1.TemplateVariant{{Name: "gpu"}},
+			VariantReplicas:  map[string]int{"gpu": 1},
+		},
+	}
+	gpu := &actionsv1alpha1.EphemeralRunner{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelKeyRunnerTemplateVariant: "gpu"}}}
+	existing := []*actionsv1alpha1.EphemeralRunner{gpu, gpu, gpu, {}}
+
+	require.Equal(t, map[string]int{"gpu": 2}, templateVariantSurplus(runnerSet, existing, 4), "The default template is short of the runners the variant has in excess")
+	require.Equal(t, map[string]int{"gpu": 2, "": 1}, templateVariantSurplus(runnerSet, existing, 1))
+	require.Empty(t, templateVariantSurplus(&actionsv1alpha1.EphemeralRunnerSet{}, existing, 1), "Runner sets without variants have no surplus")
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	tests := map[string]struct {
+		spec     actionsv1alpha1.EphemeralRunnerSetSpec
+		busy     int
+		expected int
+	}{
+		"replicas without warm pool": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 3},
+			busy:     3,
+			expected: 3,
+		},
+		"warm pool on top of busy runners": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 3, WarmReplicas: 2},
+			busy:     3,
+			expected: 5,
+		},
+		"replicas above the warm pool": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 6, WarmReplicas: 2},
+			busy:     1,
+			expected: 6,
+		},
+		"warm pool within its limit": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 3, WarmReplicas: 2, WarmReplicasLimit: intPtr(4)},
+			busy:     3,
+			expected: 4,
+		},
+		"warm pool within the budget": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 3, WarmReplicas: 2, BudgetReplicas: intPtr(3)},
+			busy:     3,
+			expected: 3,
+		},
+		"reserved replicas above replicas": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 1, ReservedReplicas: 4},
+			busy:     1,
+			expected: 4,
+		},
+		"reserved replicas within the budget": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 1, ReservedReplicas: 4, BudgetReplicas: intPtr(2)},
+			busy:     1,
+			expected: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			runnerSet := &actionsv1alpha1.EphemeralRunnerSet{Spec: tc.spec}
+			require.Equal(t, tc.expected, desiredReplicas(runnerSet, tc.busy))
+		})
+	}
+}
+
+func TestCountBusyEphemeralRunners(t *testing.T) {
+	idle := &actionsv1alpha1.EphemeralRunner{}
+	busy := &actionsv1alpha1.EphemeralRunner{Status: actionsv1alpha1.EphemeralRunnerStatus{JobRequestId: 1}}
+
+	require.Equal(t, 2, countBusyEphemeralRunners([]*actionsv1alpha1.EphemeralRunner{idle, busy}, []*actionsv1alpha1.EphemeralRunner{busy}))
+	require.Equal(t, 0, countBusyEphemeralRunners())
+}
+
+func TestCountRunnerPhases(t *testing.T) {
+	pending := &actionsv1alpha1.EphemeralRunner{}
+	// JIT runners get their runner ID before their pod is created.
+	registering := &actionsv1alpha1.EphemeralRunner{Status: actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, RunnerId: 3}}
+	idle := &actionsv1alpha1.EphemeralRunner{Status: actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, Ready: true, RunnerId: 1}}
+	busy := &actionsv1alpha1.EphemeralRunner{Status: actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodRunning, Ready: true, RunnerId: 2, JobRequestId: 1}}
+	finished := &actionsv1alpha1.EphemeralRunner{Status: actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodSucceeded}}
+	failed := &actionsv1alpha1.EphemeralRunner{Status: actionsv1alpha1.EphemeralRunnerStatus{Phase: corev1.PodFailed}}
+
+	phases := countRunnerPhases(
+		[]*actionsv1alpha1.EphemeralRunner{pending, pending},
+		[]*actionsv1alpha1.EphemeralRunner{registering, idle, idle, busy},
+		[]*actionsv1alpha1.EphemeralRunner{finished},
+		[]*actionsv1alpha1.EphemeralRunner{failed},
+		[]*actionsv1alpha1.EphemeralRunner{busy},
+	)
+	require.Equal(t, actionsv1alpha1.RunnerPhaseCounts{PendingPod: 2, Registering: 1, Idle: 2, RunningJob: 1, Finishing: 2, Failed: 1}, phases)
+}
+
+func TestExpiredIdleEphemeralRunners(t *testing.T) {
+	now := time.Now()
+	runner := func(age time.Duration, runnerId, jobRequestId int) *actionsv1alpha1.EphemeralRunner {
+		return &actionsv1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-age))},
+			Status:     actionsv1alpha1.EphemeralRunnerStatus{RunnerId: runnerId, JobRequestId: int64(jobRequestId)},
+		}
+	}
+
+	runners := []*actionsv1alpha1.EphemeralRunner{
+		runner(20*time.Minute, 1, 0),
+		runner(15*time.Minute, 2, 0),
+		runner(20*time.Minute, 3, 1), // busy
+		runner(20*time.Minute, 0, 0), // not registered yet
+		runner(8*time.Minute, 4, 0),
+		runner(5*time.Minute, 5, 0),
+	}
+
+	expired, nextExpiry := expiredIdleEphemeralRunners(runners, 10*time.Minute, now)
+	require.Equal(t, 2, expired)
+	require.Equal(t, 2*time.Minute, nextExpiry)
+
+	expired, nextExpiry = expiredIdleEphemeralRunners(runners, time.Hour, now)
+	require.Equal(t, 0, expired)
+	require.Equal(t, 40*time.Minute, nextExpiry)
+}
+
+func TestIdleScaledDownReplicas(t *testing.T) {
+	tests := map[string]struct {
+		spec     actionsv1alpha1.EphemeralRunnerSetSpec
+		expired  int
+		expected int
+	}{
+		"no expired runners": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 5, MinReplicas: 1},
+			expected: 5,
+		},
+		"expired runners are scaled down": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 5, MinReplicas: 1},
+			expired:  3,
+			expected: 2,
+		},
+		"down to min replicas": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 5, MinReplicas: 3},
+			expired:  4,
+			expected: 3,
+		},
+		"replicas below min replicas are kept": {
+			spec:     actionsv1alpha1.EphemeralRunnerSetSpec{Replicas: 1, MinReplicas: 3},
+			expired:  1,
+			expected: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			runnerSet := &actionsv1alpha1.EphemeralRunnerSet{Spec: tc.spec}
+			require.Equal(t, tc.expected, idleScaledDownReplicas(runnerSet, tc.expired))
+		})
+	}
+}
+
+func TestSortScaleDownCandidates(t *testing.T) {
+	now := time.Now()
+	runner := func(name string, age time.Duration, jobRequestId int64) *actionsv1alpha1.EphemeralRunner {
+		return &actionsv1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))},
+			Status:     actionsv1alpha1.EphemeralRunnerStatus{JobRequestId: jobRequestId},
+		}
+	}
+	runners := func() []*actionsv1alpha1.EphemeralRunner {
+		return []*actionsv1alpha1.EphemeralRunner{
+			runner("busy-oldest", 4*time.Minute, 1),
+			runner("idle-new", 1*time.Minute, 0),
+			runner("idle-old", 3*time.Minute, 0),
+			runner("idle-mid", 2*time.Minute, 0),
+		}
+	}
+	names := func(runners []*actionsv1alpha1.EphemeralRunner) []string {
+		var names []string
+		for _, r := range runners {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+
+	tests := map[actionsv1alpha1.ScaleDownPolicy][]string{
+		"":                                    {"idle-old", "idle-mid", "idle-new", "busy-oldest"},
+		actionsv1alpha1.ScaleDownPolicyOldest: {"idle-old", "idle-mid", "idle-new", "busy-oldest"},
+		actionsv1alpha1.ScaleDownPolicyNewest: {"idle-new", "idle-mid", "idle-old", "busy-oldest"},
+	}
+	for policy, expected := range tests {
+		candidates := runners()
+		sortScaleDownCandidates(policy, candidates)
+		require.Equal(t, expected, names(candidates), "policy %q", policy)
+	}
+
+	candidates := runners()
+	sortScaleDownCandidates(actionsv1alpha1.ScaleDownPolicyRandom, candidates)
+	require.ElementsMatch(t, []string{"idle-old", "idle-mid", "idle-new"}, names(candidates[:3]))
+	require.Equal(t, "busy-oldest", candidates[3].Name, "Busy runners come last")
+}
@@ -0,0 +1,278 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	genericfilters "k8s.io/apiserver/pkg/endpoints/filters"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	externalMetricsGroupVersion = "external.metrics.k8s.io/v1beta1"
+	externalMetricsPathPrefix   = "/apis/" + externalMetricsGroupVersion
+
+	// ExternalMetricLabelAutoscalingRunnerSet is the metric label holding the name of the AutoscalingRunnerSet,
+	// to select the scale set in the metric selector of a HorizontalPodAutoscaler.
+	ExternalMetricLabelAutoscalingRunnerSet = "autoscaling-runner-set"
+)
+
+// externalMetrics maps the names of the served external metrics to the job statistic they expose.
+var externalMetrics = map[string]func(*actions.RunnerScaleSetStatistic) int{
+	"github-runner-scale-set-queued-jobs":   func(s *actions.RunnerScaleSetStatistic) int { return s.TotalAvailableJobs },
+	"github-runner-scale-set-acquired-jobs": func(s *actions.RunnerScaleSetStatistic) int { return s.TotalAcquiredJobs },
+	"github-runner-scale-set-assigned-jobs": func(s *actions.RunnerScaleSetStatistic) int { return s.TotalAssignedJobs },
+	"github-runner-scale-set-running-jobs":  func(s *actions.RunnerScaleSetStatistic) int { return s.TotalRunningJobs },
+}
+
+// externalMetricValueList mirrors ExternalMetricValueList of the external.metrics.k8s.io/v1beta1 API.
+type externalMetricValueList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []externalMetricValue `json:"items"`
+}
+
+// externalMetricValue mirrors ExternalMetricValue of the external.metrics.k8s.io/v1beta1 API.
+type externalMetricValue struct {
+	MetricName   string            `json:"metricName"`
+	MetricLabels map[string]string `json:"metricLabels"`
+	Timestamp    metav1.Time       `json:"timestamp"`
+	Value        resource.Quantity `json:"value"`
+}
+
+// ExternalMetricsServer implements the external.metrics.k8s.io/v1beta1 API with the job statistics
+// reported by the listeners, so HorizontalPodAutoscalers of workloads supporting the runners
+// (cache proxies, registries...) can scale with the job queue.
+//
+// It is meant to be registered as an APIService, which requires serving over TLS. Like the other
+// aggregated API servers, it delegates the authentication and the authorization of the requests
+// to the Kubernetes API server, see DelegateAuth.
+// Every autoscaling runner set in the requested namespace is returned as a metric value,
+// labeled with its name so the HorizontalPodAutoscaler can select it.
+type ExternalMetricsServer struct {
+	Client client.Reader
+	Log    logr.Logger
+	Addr   string
+	// CertDir is the directory holding the tls.crt and tls.key files the server is served with.
+	CertDir string
+
+	// Authenticator authenticates the requests, and Authorizer authorizes them.
+	// The server refuses to start without them.
+	Authenticator authenticator.Request
+	Authorizer    authorizer.Authorizer
+	// ClientCA verifies the client certificates of the requests, e.g. the front proxy certificate
+	// of the Kubernetes API server.
+	ClientCA dynamiccertificates.CAContentProvider
+}
+
+// DelegateAuth authenticates the requests and authorizes them with the Kubernetes API server the
+// controller runs in, like the other aggregated API servers. The requests proxied by the API server
+// are authenticated with its front proxy client certificate, using the extension-apiserver-authentication
+// config map of kube-system, and the other ones with TokenReviews. Every request is then authorized
+// with a SubjectAccessReview.
+func (s *ExternalMetricsServer) DelegateAuth() error {
+	var (
+		authenticationInfo genericapiserver.AuthenticationInfo
+		authorizationInfo  genericapiserver.AuthorizationInfo
+		servingInfo        genericapiserver.SecureServingInfo
+	)
+	if err := genericoptions.NewDelegatingAuthenticationOptions().ApplyTo(&authenticationInfo, &servingInfo, nil); err != nil {
+		return fmt.Errorf("failed to set up the delegated authentication: %v", err)
+	}
+	if err := genericoptions.NewDelegatingAuthorizationOptions().ApplyTo(&authorizationInfo); err != nil {
+		return fmt.Errorf("failed to set up the delegated authorization: %v", err)
+	}
+
+	s.Authenticator = authenticationInfo.Authenticator
+	s.Authorizer = authorizationInfo.Authorizer
+	s.ClientCA = servingInfo.ClientCA
+	return nil
+}
+
+// Handler returns the handler of the external metrics API.
+// The requests are authenticated and authorized when the server has an Authenticator and an Authorizer.
+func (s *ExternalMetricsServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(externalMetricsPathPrefix, s.handleDiscovery)
+	mux.HandleFunc(externalMetricsPathPrefix+"/", s.handleMetric)
+
+	if s.Authenticator == nil || s.Authorizer == nil {
+		return mux
+	}
+
+	codecs := serializer.NewCodecFactory(scheme.Scheme)
+	var handler http.Handler = mux
+	handler = genericfilters.WithAuthorization(handler, s.Authorizer, codecs)
+	handler = genericfilters.WithAuthentication(handler, s.Authenticator, genericfilters.Unauthorized(codecs), nil)
+	handler = genericfilters.WithRequestInfo(handler, &request.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("apis", "api"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	})
+	return handler
+}
+
+func (s *ExternalMetricsServer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names := make([]string, 0, len(externalMetrics))
+	for name := range externalMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: externalMetricsGroupVersion,
+	}
+	for _, name := range names {
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       name,
+			Namespaced: true,
+			Kind:       "ExternalMetricValueList",
+			Verbs:      metav1.Verbs{"get"},
+		})
+	}
+
+	writeJSON(w, s.Log, list)
+}
+
+// handleMetric serves GET /apis/external.metrics.k8s.io/v1beta1/namespaces/{namespace}/{metric}.
+func (s *ExternalMetricsServer) handleMetric(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, externalMetricsPathPrefix), "/"), "/")
+	if len(parts) != 3 || parts[0] != "namespaces" {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, metricName := parts[1], parts[2]
+
+	value, ok := externalMetrics[metricName]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid label selector: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.metricValues(r.Context(), namespace, metricName, value, selector)
+	if err != nil {
+		s.Log.Error(err, "Failed to get external metric", "namespace", namespace, "metric", metricName)
+		http.Error(w, "failed to get external metric", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, s.Log, &externalMetricValueList{
+		TypeMeta: metav1.TypeMeta{Kind: "ExternalMetricValueList", APIVersion: externalMetricsGroupVersion},
+		Items:    items,
+	})
+}
+
+func (s *ExternalMetricsServer) metricValues(ctx context.Context, namespace, metricName string, value func(*actions.RunnerScaleSetStatistic) int, selector labels.Selector) ([]externalMetricValue, error) {
+	autoscalingRunnerSets := new(v1alpha1.AutoscalingRunnerSetList)
+	if err := s.Client.List(ctx, autoscalingRunnerSets, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list autoscaling runner sets: %v", err)
+	}
+
+	items := make([]externalMetricValue, 0, len(autoscalingRunnerSets.Items))
+	for _, autoscalingRunnerSet := range autoscalingRunnerSets.Items {
+		metricLabels := map[string]string{ExternalMetricLabelAutoscalingRunnerSet: autoscalingRunnerSet.Name}
+		if !selector.Matches(labels.Set(metricLabels)) {
+			continue
+		}
+
+		runnerSets := new(v1alpha1.EphemeralRunnerSetList)
+		if err := s.Client.List(ctx, runnerSets, client.InNamespace(namespace), client.MatchingFields{autoscalingRunnerSetOwnerKey: autoscalingRunnerSet.Name}); err != nil {
+			return nil, fmt.Errorf("failed to list ephemeral runner sets: %v", err)
+		}
+
+		message, statistics := lastListenerReport(runnerSets.Items)
+		if statistics == nil {
+			// The listener has not reported yet, the job queue is unknown.
+			continue
+		}
+
+		items = append(items, externalMetricValue{
+			MetricName:   metricName,
+			MetricLabels: metricLabels,
+			Timestamp:    metav1.NewTime(message.ProcessedAt),
+			Value:        *resource.NewQuantity(int64(value(statistics)), resource.DecimalSI),
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].MetricLabels[ExternalMetricLabelAutoscalingRunnerSet] < items[j].MetricLabels[ExternalMetricLabelAutoscalingRunnerSet]
+	})
+
+	return items, nil
+}
+
+// Start serves the external metrics API until the context is done.
+func (s *ExternalMetricsServer) Start(ctx context.Context) error {
+	if s.Authenticator == nil || s.Authorizer == nil {
+		return errors.New("the external metrics API requires an authenticator and an authorizer")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.ClientCA != nil {
+		// The client certificates are verified by the authenticator.
+		tlsConfig.ClientAuth = tls.RequestClientCert
+		if controller, ok := s.ClientCA.(dynamiccertificates.ControllerRunner); ok {
+			if err := controller.RunOnce(ctx); err != nil {
+				return fmt.Errorf("failed to load the client CA: %v", err)
+			}
+			go controller.Run(ctx, 1)
+		}
+	}
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.Log.Info("Starting external metrics API server", "addr", s.Addr)
+	return serveUntilDone(ctx, server, func() error {
+		return server.ListenAndServeTLS(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	})
+}
+
+// NeedLeaderElection makes the external metrics API available on every replica.
+func (s *ExternalMetricsServer) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,166 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+)
+
+func TestExternalMetricsServer(t *testing.T) {
+	isController := true
+	newRunnerSet := func(owner, name string, processedAt time.Time, statistics string) *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "runners",
+				Annotations: map[string]string{
+					v1alpha1.AnnotationKeyLastListenerMessageId:   "1",
+					v1alpha1.AnnotationKeyLastListenerMessageTime: processedAt.Format(time.RFC3339),
+					v1alpha1.AnnotationKeyJobStatistics:           statistics,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: v1alpha1.GroupVersion.String(),
+						Kind:       "AutoscalingRunnerSet",
+						Name:       owner,
+						Controller: &isController,
+					},
+				},
+			},
+		}
+	}
+	processedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	adminServer := newAdminServerForTest(
+		t,
+		&v1alpha1.AutoscalingRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners"}},
+		&v1alpha1.AutoscalingRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "gpu", Namespace: "runners"}},
+		&v1alpha1.AutoscalingRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "silent", Namespace: "runners"}},
+		newRunnerSet("arc", "arc-old", processedAt.Add(-time.Minute), `{"totalAvailableJobs":9}`),
+		newRunnerSet("arc", "arc-new", processedAt, `{"totalAvailableJobs":3,"totalAssignedJobs":2}`),
+		newRunnerSet("gpu", "gpu", processedAt, `{"totalAvailableJobs":1}`),
+	)
+	server := &ExternalMetricsServer{Client: adminServer.Client, Log: logr.Discard()}
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("discovery lists the metrics", func(t *testing.T) {
+		rec := get(t, "/apis/external.metrics.k8s.io/v1beta1")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list metav1.APIResourceList
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Equal(t, "external.metrics.k8s.io/v1beta1", list.GroupVersion)
+		assert.Len(t, list.APIResources, len(externalMetrics))
+	})
+
+	t.Run("returns the job statistics of every reporting scale set", func(t *testing.T) {
+		rec := get(t, "/apis/external.metrics.k8s.io/v1beta1/namespaces/runners/github-runner-scale-set-queued-jobs")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list externalMetricValueList
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		require.Len(t, list.Items, 2)
+		assert.Equal(t, map[string]string{ExternalMetricLabelAutoscalingRunnerSet: "arc"}, list.Items[0].MetricLabels)
+		assert.Equal(t, int64(3), list.Items[0].Value.Value(), "statistics of the latest report should be used")
+		assert.True(t, processedAt.Equal(list.Items[0].Timestamp.Time))
+		assert.Equal(t, int64(1), list.Items[1].Value.Value())
+	})
+
+	t.Run("filters by label selector", func(t *testing.T) {
+		rec := get(t, "/apis/external.metrics.k8s.io/v1beta1/namespaces/runners/github-runner-scale-set-assigned-jobs?labelSelector=autoscaling-runner-set%3Darc")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list externalMetricValueList
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, "github-runner-scale-set-assigned-jobs", list.Items[0].MetricName)
+		assert.Equal(t, int64(2), list.Items[0].Value.Value())
+	})
+
+	t.Run("returns not found for unknown metrics", func(t *testing.T) {
+		rec := get(t, "/apis/external.metrics.k8s.io/v1beta1/namespaces/runners/unknown")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestExternalMetricsServer_Auth(t *testing.T) {
+	adminServer := newAdminServerForTest(
+		t,
+		&v1alpha1.AutoscalingRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners"}},
+	)
+
+	const hpaUser = "system:serviceaccount:kube-system:horizontal-pod-autoscaler"
+	var authorized []authorizer.Attributes
+	server := &ExternalMetricsServer{
+		Client: adminServer.Client,
+		Log:    logr.Discard(),
+		Authenticator: authenticator.RequestFunc(func(r *http.Request) (*authenticator.Response, bool, error) {
+			name := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if name == "" {
+				return nil, false, nil
+			}
+			return &authenticator.Response{User: &user.DefaultInfo{Name: name}}, true, nil
+		}),
+		Authorizer: authorizer.AuthorizerFunc(func(ctx context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+			authorized = append(authorized, a)
+			if a.GetUser().GetName() == hpaUser {
+				return authorizer.DecisionAllow, "", nil
+			}
+			return authorizer.DecisionNoOpinion, "", nil
+		}),
+	}
+
+	get := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/apis/external.metrics.k8s.io/v1beta1/namespaces/runners/github-runner-scale-set-queued-jobs", nil)
+		if user != "" {
+			req.Header.Set("Authorization", "Bearer "+user)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("rejects unauthenticated requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("").Code)
+	})
+
+	t.Run("rejects unauthorized requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get("someone").Code)
+	})
+
+	t.Run("serves authorized requests", func(t *testing.T) {
+		authorized = nil
+
+		rec := get(hpaUser)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, authorized, 1)
+		assert.Equal(t, "external.metrics.k8s.io", authorized[0].GetAPIGroup())
+		assert.Equal(t, "github-runner-scale-set-queued-jobs", authorized[0].GetResource())
+		assert.Equal(t, "runners", authorized[0].GetNamespace())
+		assert.Equal(t, "list", authorized[0].GetVerb())
+	})
+
+	t.Run("refuses to start without authentication", func(t *testing.T) {
+		err := (&ExternalMetricsServer{Log: logr.Discard()}).Start(context.Background())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,41 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultRemoteCleanupTimeout is how long the deletion of a resource retries cleaning up
+// the Actions service before the resource is force deleted.
+const DefaultRemoteCleanupTimeout = time.Hour
+
+// forceDeleteRequested reports whether obj is deleted without cleaning up the Actions service.
+func forceDeleteRequested(obj metav1.Object) bool {
+	return obj.GetAnnotations()[v1alpha1.AnnotationKeyForceDelete] == "true"
+}
+
+// remoteCleanupTimedOut reports whether obj has been deleted for longer than timeout.
+// A timeout of 0 never times out.
+func remoteCleanupTimedOut(obj metav1.Object, timeout time.Duration, now time.Time) bool {
+	deletionTimestamp := obj.GetDeletionTimestamp()
+	return timeout > 0 && deletionTimestamp != nil && now.Sub(deletionTimestamp.Time) > timeout
+}
+
+// forceDelete annotates obj to be deleted without cleaning up the Actions service,
+// which is propagated to the resources it owns as they are deleted.
+func forceDelete[T object[T]](ctx context.Context, client patcher, obj T) error {
+	if forceDeleteRequested(obj) {
+		return nil
+	}
+	return patch(ctx, client, obj, func(obj T) {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[v1alpha1.AnnotationKeyForceDelete] = "true"
+		obj.SetAnnotations(annotations)
+	})
+}
@@ -0,0 +1,138 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// EnvVarGitHubServerRootCAFile is the path of the CA bundle of the GitHub server in the listener pod.
+const EnvVarGitHubServerRootCAFile = "GITHUB_SERVER_ROOT_CA_FILE"
+
+// DefaultGitHubServerTLS is the CA bundle of the GitHub server used by the autoscaling runner sets
+// that don't set their own GitHubServerTLS: by their actions clients, listeners and runner pods.
+type DefaultGitHubServerTLS struct {
+	// Namespace of the config map holding the CA bundle, which is the namespace of the controller.
+	Namespace string
+	v1alpha1.GitHubServerTLSConfig
+}
+
+// gitHubServerTLSFor returns the GitHubServerTLS of a resource, and the namespace of its config map.
+// Resources without one use the default, when there is one.
+func gitHubServerTLSFor(tls *v1alpha1.GitHubServerTLSConfig, namespace string, defaultTLS *DefaultGitHubServerTLS) (*v1alpha1.GitHubServerTLSConfig, string) {
+	if tls == nil && defaultTLS != nil {
+		return &defaultTLS.GitHubServerTLSConfig, defaultTLS.Namespace
+	}
+	return tls, namespace
+}
+
+// usesDefaultGitHubServerTLS reports whether the autoscaling runner set uses the default CA bundle.
+func usesDefaultGitHubServerTLS(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, defaultTLS *DefaultGitHubServerTLS) bool {
+	return autoscalingRunnerSet.Spec.GitHubServerTLS == nil && defaultTLS != nil
+}
+
+// gitHubServerTLSMirrorName is the name of the config map the default CA bundle is copied to
+// in the namespace of the autoscaling runner set, to be mounted into its runner pods.
+func gitHubServerTLSMirrorName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return autoscalingRunnerSet.Name + "-github-server-tls"
+}
+
+// applyDefaultGitHubServerTLS points the runners of a new runner set to the copy of the default CA bundle.
+func (r *AutoscalingRunnerSetReconciler) applyDefaultGitHubServerTLS(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerSet *v1alpha1.EphemeralRunnerSet) {
+	if !usesDefaultGitHubServerTLS(autoscalingRunnerSet, r.DefaultGitHubServerTLS) {
+		return
+	}
+	runnerSet.Spec.EphemeralRunnerSpec.GitHubServerTLS = &v1alpha1.GitHubServerTLSConfig{
+		RootCAsConfigMapRef: gitHubServerTLSMirrorName(autoscalingRunnerSet),
+		RootCAsConfigMapKey: gitHubServerTLSCertKey(&r.DefaultGitHubServerTLS.GitHubServerTLSConfig),
+	}
+}
+
+// reconcileGitHubServerTLSMirror keeps the copy of the default CA bundle in the namespace of the autoscaling
+// runner set in sync with the original. The copy is owned by the autoscaling runner set.
+func (r *AutoscalingRunnerSetReconciler) reconcileGitHubServerTLSMirror(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	if !usesDefaultGitHubServerTLS(autoscalingRunnerSet, r.DefaultGitHubServerTLS) {
+		return nil
+	}
+
+	key := gitHubServerTLSCertKey(&r.DefaultGitHubServerTLS.GitHubServerTLSConfig)
+	original := new(corev1.ConfigMap)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.DefaultGitHubServerTLS.Namespace, Name: r.DefaultGitHubServerTLS.RootCAsConfigMapRef}, original); err != nil {
+		return fmt.Errorf("failed to get default GitHub server TLS config map: %v", err)
+	}
+	cert, ok := original.Data[key]
+	if !ok {
+		return fmt.Errorf("default GitHub server TLS config map %q has no key %q", original.Name, key)
+	}
+
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      gitHubServerTLSMirrorName(autoscalingRunnerSet),
+			Namespace: autoscalingRunnerSet.Namespace,
+		},
+		Data: map[string]string{key: cert},
+	}
+	if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set controller reference to the GitHub server TLS config map: %v", err)
+	}
+
+	mirror := new(corev1.ConfigMap)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, mirror); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get GitHub server TLS config map: %v", err)
+		}
+
+		log.Info("Copying the default GitHub server TLS config map", "name", desired.Name)
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create GitHub server TLS config map: %v", err)
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(mirror.Data, desired.Data) {
+		return nil
+	}
+
+	log.Info("Updating the copy of the default GitHub server TLS config map", "name", desired.Name)
+	if err := patch(ctx, r.Client, mirror, func(obj *corev1.ConfigMap) {
+		obj.Data = desired.Data
+	}); err != nil {
+		return fmt.Errorf("failed to update GitHub server TLS config map: %v", err)
+	}
+	return nil
+}
+
+// applyDefaultGitHubServerTLSToListener mounts the default CA bundle into the listener pod, which runs
+// in the namespace of the controller, and points the listener to it.
+func (r *AutoscalingListenerReconciler) applyDefaultGitHubServerTLSToListener(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, pod *corev1.Pod) {
+	if !usesDefaultGitHubServerTLS(autoscalingRunnerSet, r.DefaultGitHubServerTLS) || pod.Namespace != r.DefaultGitHubServerTLS.Namespace {
+		return
+	}
+
+	key := gitHubServerTLSCertKey(&r.DefaultGitHubServerTLS.GitHubServerTLSConfig)
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: GitHubServerTLSVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: r.DefaultGitHubServerTLS.RootCAsConfigMapRef},
+				Items:                []corev1.KeyToPath{{Key: key, Path: key}},
+			},
+		},
+	})
+
+	listener := &pod.Spec.Containers[0]
+	listener.VolumeMounts = append(listener.VolumeMounts, corev1.VolumeMount{
+		Name:      GitHubServerTLSVolumeName,
+		MountPath: GitHubServerTLSMountPath,
+		ReadOnly:  true,
+	})
+	listener.Env = appendEnvIfMissing(listener.Env, corev1.EnvVar{Name: EnvVarGitHubServerRootCAFile, Value: GitHubServerTLSMountPath + "/" + key})
+}
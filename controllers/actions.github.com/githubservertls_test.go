@@ -0,0 +1,86 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDefaultGitHubServerTLS(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	defaultTLS := &DefaultGitHubServerTLS{
+		Namespace:             "arc-systems",
+		GitHubServerTLSConfig: v1alpha1.GitHubServerTLSConfig{RootCAsConfigMapRef: "ghes-ca"},
+	}
+	original := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "arc-systems", Name: "ghes-ca"},
+		Data:       map[string]string{"ca.crt": "cert-1"},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "runners", Name: "linux", UID: "uid"},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(original, autoscalingRunnerSet).Build()
+
+	r := &AutoscalingRunnerSetReconciler{
+		Client:                 c,
+		Log:                    logr.Discard(),
+		Scheme:                 scheme,
+		DefaultGitHubServerTLS: defaultTLS,
+	}
+
+	ctx := context.Background()
+	mirrorKey := types.NamespacedName{Namespace: "runners", Name: "linux-github-server-tls"}
+	mirror := new(corev1.ConfigMap)
+
+	require.NoError(t, r.reconcileGitHubServerTLSMirror(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, mirrorKey, mirror))
+	assert.Equal(t, map[string]string{"ca.crt": "cert-1"}, mirror.Data)
+	assert.Equal(t, "linux", metav1.GetControllerOf(mirror).Name, "The copy is owned by the autoscaling runner set")
+
+	original.Data["ca.crt"] = "cert-2"
+	require.NoError(t, c.Update(ctx, original))
+	require.NoError(t, r.reconcileGitHubServerTLSMirror(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, mirrorKey, mirror))
+	assert.Equal(t, map[string]string{"ca.crt": "cert-2"}, mirror.Data, "The copy follows the original")
+
+	runnerSet := new(v1alpha1.EphemeralRunnerSet)
+	r.applyDefaultGitHubServerTLS(autoscalingRunnerSet, runnerSet)
+	assert.Equal(t, &v1alpha1.GitHubServerTLSConfig{RootCAsConfigMapRef: "linux-github-server-tls", RootCAsConfigMapKey: "ca.crt"}, runnerSet.Spec.EphemeralRunnerSpec.GitHubServerTLS)
+
+	listenerReconciler := &AutoscalingListenerReconciler{DefaultGitHubServerTLS: defaultTLS}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "arc-systems"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "listener"}}},
+	}
+	listenerReconciler.applyDefaultGitHubServerTLSToListener(autoscalingRunnerSet, pod)
+	require.Len(t, pod.Spec.Volumes, 1)
+	assert.Equal(t, "ghes-ca", pod.Spec.Volumes[0].ConfigMap.Name)
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: EnvVarGitHubServerRootCAFile, Value: GitHubServerTLSMountPath + "/ca.crt"})
+
+	// Autoscaling runner sets with their own GitHubServerTLS don't use the default.
+	own := autoscalingRunnerSet.DeepCopy()
+	own.Spec.GitHubServerTLS = &v1alpha1.GitHubServerTLSConfig{RootCAsConfigMapRef: "own-ca"}
+	runnerSet = new(v1alpha1.EphemeralRunnerSet)
+	r.applyDefaultGitHubServerTLS(own, runnerSet)
+	assert.Nil(t, runnerSet.Spec.EphemeralRunnerSpec.GitHubServerTLS)
+
+	tls, namespace := gitHubServerTLSFor(own.Spec.GitHubServerTLS, "runners", defaultTLS)
+	assert.Equal(t, "own-ca", tls.RootCAsConfigMapRef)
+	assert.Equal(t, "runners", namespace)
+	tls, namespace = gitHubServerTLSFor(nil, "runners", defaultTLS)
+	assert.Equal(t, "ghes-ca", tls.RootCAsConfigMapRef)
+	assert.Equal(t, "arc-systems", namespace)
+}
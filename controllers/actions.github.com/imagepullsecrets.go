@@ -0,0 +1,108 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/hash"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// appendImagePullSecrets appends the secrets to the image pull secrets of a pod, skipping the ones it already has.
+func appendImagePullSecrets(secrets []corev1.LocalObjectReference, add ...corev1.LocalObjectReference) []corev1.LocalObjectReference {
+	for _, secret := range add {
+		found := false
+		for _, existing := range secrets {
+			if existing.Name == secret.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// runnerImagePullSecrets returns the image pull secrets of the runner pods of the autoscaling runner set
+// generated from the given pod template.
+func runnerImagePullSecrets(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, template *corev1.PodTemplateSpec) []corev1.LocalObjectReference {
+	if len(autoscalingRunnerSet.Spec.ImagePullSecrets) == 0 {
+		return template.Spec.ImagePullSecrets
+	}
+	secrets := append([]corev1.LocalObjectReference{}, template.Spec.ImagePullSecrets...)
+	return appendImagePullSecrets(secrets, autoscalingRunnerSet.Spec.ImagePullSecrets...)
+}
+
+// scaleSetListenerImagePullSecretMirrorName is the name of the copy of an image pull secret of the
+// autoscaling runner set in the namespace of the listener.
+func scaleSetListenerImagePullSecretMirrorName(autoscalingListener *v1alpha1.AutoscalingListener, name string) string {
+	namespaceHash := hash.FNVHashString(autoscalingListener.Spec.AutoscalingRunnerSetNamespace)
+	if len(namespaceHash) > 8 {
+		namespaceHash = namespaceHash[:8]
+	}
+	return fmt.Sprintf("%v-%v-pull-%v", autoscalingListener.Spec.AutoscalingRunnerSetName, namespaceHash, name)
+}
+
+// reconcileImagePullSecretMirrors copies the image pull secrets of the autoscaling runner set to the namespace
+// of the listener, and keeps the copies in sync. The copies are owned by the listener, and returned to be
+// referenced by the listener pod.
+func (r *AutoscalingListenerReconciler) reconcileImagePullSecretMirrors(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, log logr.Logger) ([]corev1.LocalObjectReference, error) {
+	var mirrors []corev1.LocalObjectReference
+	for _, ref := range autoscalingRunnerSet.Spec.ImagePullSecrets {
+		original := new(corev1.Secret)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: ref.Name}, original); err != nil {
+			return nil, fmt.Errorf("failed to get image pull secret %q: %v", ref.Name, err)
+		}
+
+		desired := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      scaleSetListenerImagePullSecretMirrorName(autoscalingListener, ref.Name),
+				Namespace: autoscalingListener.Namespace,
+				Labels: map[string]string{
+					"auto-scaling-runner-set-namespace": autoscalingListener.Spec.AutoscalingRunnerSetNamespace,
+					"auto-scaling-runner-set-name":      autoscalingListener.Spec.AutoscalingRunnerSetName,
+				},
+			},
+			Type: original.Type,
+			Data: original.Data,
+		}
+		if err := ctrl.SetControllerReference(autoscalingListener, desired, r.Scheme); err != nil {
+			return nil, fmt.Errorf("failed to set controller reference to the image pull secret copy: %v", err)
+		}
+		mirrors = append(mirrors, corev1.LocalObjectReference{Name: desired.Name})
+
+		mirror := new(corev1.Secret)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, mirror); err != nil {
+			if !kerrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get image pull secret copy: %v", err)
+			}
+
+			log.Info("Copying image pull secret for the listener pod", "secret", ref.Name, "name", desired.Name)
+			if err := r.Create(ctx, desired); err != nil {
+				return nil, fmt.Errorf("failed to create image pull secret copy: %v", err)
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(mirror.Data, desired.Data) {
+			continue
+		}
+
+		log.Info("Updating the copy of image pull secret for the listener pod", "secret", ref.Name, "name", desired.Name)
+		if err := patch(ctx, r.Client, mirror, func(obj *corev1.Secret) {
+			obj.Data = desired.Data
+		}); err != nil {
+			return nil, fmt.Errorf("failed to update image pull secret copy: %v", err)
+		}
+	}
+	return mirrors, nil
+}
@@ -0,0 +1,107 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestImagePullSecrets_RunnerPods(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-asrs",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:  "https://github.com/owner/repo",
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {Name: "template"}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "template"}},
+					Containers:       []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "registry.example.com/runner:latest"}},
+				},
+			},
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{
+					Name:   "gpu",
+					Labels: []string{"gpu"},
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "registry.example.com/runner:gpu"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	specHash := autoscalingRunnerSet.RunnerSetSpecHash()
+	runnerSet, err := new(resourceBuilder).newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "template"}, {Name: "registry"}}, runnerSet.Spec.EphemeralRunnerSpec.Spec.ImagePullSecrets)
+	require.Len(t, runnerSet.Spec.TemplateVariants, 1)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "registry"}, {Name: "template"}}, runnerSet.Spec.TemplateVariants[0].Template.Spec.ImagePullSecrets)
+	assert.Empty(t, autoscalingRunnerSet.Spec.TemplateVariants[0].Template.Spec.ImagePullSecrets, "The autoscaling runner set is not modified")
+
+	daemonSet := new(resourceBuilder).newImagePrePullDaemonSets(autoscalingRunnerSet, "ghcr.io/actions/listener:latest")[0]
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "template"}, {Name: "registry"}}, daemonSet.Spec.Template.Spec.ImagePullSecrets)
+
+	autoscalingRunnerSet.Spec.ImagePullSecrets = append(autoscalingRunnerSet.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: "another"})
+	assert.NotEqual(t, specHash, autoscalingRunnerSet.RunnerSetSpecHash(), "Changing the image pull secrets rolls out new runners")
+}
+
+func TestImagePullSecrets_Listener(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "runners"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "linux", Namespace: "runners"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: pullSecret.Name}},
+		},
+	}
+	autoscalingListener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "linux-listener", Namespace: "arc-systems", UID: "uid"},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			AutoscalingRunnerSetNamespace: "runners",
+			AutoscalingRunnerSetName:      "linux",
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(pullSecret, autoscalingRunnerSet, autoscalingListener).Build()
+	r := &AutoscalingListenerReconciler{Client: c, Scheme: scheme}
+
+	ctx := context.Background()
+	refs, err := r.reconcileImagePullSecretMirrors(ctx, autoscalingRunnerSet, autoscalingListener, logr.Discard())
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+
+	mirror := new(corev1.Secret)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "arc-systems", Name: refs[0].Name}, mirror))
+	assert.Equal(t, corev1.SecretTypeDockerConfigJson, mirror.Type)
+	assert.Equal(t, pullSecret.Data, mirror.Data)
+	assert.Equal(t, "linux-listener", metav1.GetControllerOf(mirror).Name, "The copy is owned by the listener")
+
+	pullSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{"ghcr.io":{}}}`)
+	require.NoError(t, c.Update(ctx, pullSecret))
+	_, err = r.reconcileImagePullSecretMirrors(ctx, autoscalingRunnerSet, autoscalingListener, logr.Discard())
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "arc-systems", Name: refs[0].Name}, mirror))
+	assert.Equal(t, pullSecret.Data, mirror.Data, "The copy follows the original")
+}
@@ -0,0 +1,216 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/pkg/imagedigest"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// imageSignatureRecheckInterval is how often the signatures of the images of a runner set refused
+	// for its unsigned images are verified again, e.g. once the images are signed.
+	imageSignatureRecheckInterval = 5 * time.Minute
+
+	reasonImageSignatureUnverified = "ImageSignatureUnverified"
+)
+
+// ImageSignatureVerifier verifies the cosign signatures of images, returning the digest the signature covers.
+type ImageSignatureVerifier interface {
+	VerifySignature(ctx context.Context, image string, policy *imagedigest.SignaturePolicy, keychain imagedigest.Keychain) (string, error)
+}
+
+// imageSignaturePolicy returns the policy of the image signature verification of the autoscaling runner set,
+// or nil when the images aren't verified.
+func imageSignaturePolicy(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (*imagedigest.SignaturePolicy, error) {
+	verification := autoscalingRunnerSet.Spec.ImageSignatureVerification
+	if verification == nil {
+		return nil, nil
+	}
+	if (verification.PublicKey == "") == (verification.Keyless == nil) {
+		return nil, fmt.Errorf("exactly one of publicKey and keyless has to be set")
+	}
+
+	if verification.PublicKey != "" {
+		key, err := imagedigest.ParsePublicKey([]byte(verification.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+		if verification.RekorPublicKey == "" {
+			return nil, fmt.Errorf("the Rekor public key is required with publicKey")
+		}
+		rekorKey, err := imagedigest.ParsePublicKey([]byte(verification.RekorPublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Rekor public key: %v", err)
+		}
+		return &imagedigest.SignaturePolicy{PublicKey: key, RekorPublicKey: rekorKey}, nil
+	}
+
+	keyless := verification.Keyless
+	if keyless.Issuer == "" {
+		return nil, fmt.Errorf("the issuer of the keyless verification is required")
+	}
+	if (keyless.Subject == "") == (keyless.SubjectRegExp == "") {
+		return nil, fmt.Errorf("exactly one of subject and subjectRegExp has to be set")
+	}
+	subject := "^" + regexp.QuoteMeta(keyless.Subject) + "$"
+	if keyless.SubjectRegExp != "" {
+		subject = "^(?:" + keyless.SubjectRegExp + ")$"
+	}
+	subjectRegExp, err := regexp.Compile(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subjectRegExp: %v", err)
+	}
+	roots, intermediates, err := imagedigest.ParseCertificates([]byte(keyless.FulcioCertificates))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Fulcio certificates: %v", err)
+	}
+	rekorKey, err := imagedigest.ParsePublicKey([]byte(keyless.RekorPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Rekor public key: %v", err)
+	}
+	return &imagedigest.SignaturePolicy{
+		Keyless: &imagedigest.KeylessPolicy{
+			Roots:          roots,
+			Intermediates:  intermediates,
+			Issuer:         keyless.Issuer,
+			Subject:        subjectRegExp,
+			RekorPublicKey: rekorKey,
+		},
+	}, nil
+}
+
+// signedImages returns the runner and dind images of the templates of the runner set.
+func signedImages(runnerSet *v1alpha1.EphemeralRunnerSet) []string {
+	templates := []corev1.PodTemplateSpec{runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec}
+	for _, v := range runnerSet.Spec.TemplateVariants {
+		templates = append(templates, v.Template)
+	}
+
+	seen := make(map[string]bool)
+	var images []string
+	for _, template := range templates {
+		for _, c := range template.Spec.Containers {
+			if (c.Name == EphemeralRunnerContainerName || c.Name == dindContainerName) && c.Image != "" && !seen[c.Image] {
+				seen[c.Image] = true
+				images = append(images, c.Image)
+			}
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// reconcileRunnerImageSignatures verifies the cosign signatures of the runner and dind images of the runner set,
+// reports the images without a verified signature with the ImageSignatureUnverified condition, and returns
+// whether all the images are verified. Registry failures also leave the images unverified.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerImageSignatures(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) (bool, error) {
+	policy, err := imageSignaturePolicy(autoscalingRunnerSet)
+	if err != nil {
+		return false, err
+	}
+	if policy == nil {
+		return true, r.clearImageSignatureUnverified(ctx, autoscalingRunnerSet)
+	}
+
+	keychain, err := r.runnerImageKeychain(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return false, err
+	}
+	verifier := r.ImageSignatureVerifier
+	if verifier == nil {
+		verifier = &imagedigest.Resolver{}
+	}
+
+	reason := v1alpha1.ImageSignatureReasonUnsigned
+	var failures []string
+	digests := make(map[string]string)
+	for _, image := range signedImages(runnerSet) {
+		digest, err := verifier.VerifySignature(ctx, image, policy, keychain)
+		if err != nil {
+			if !errors.Is(err, imagedigest.ErrUnverifiedSignature) {
+				reason = v1alpha1.ImageSignatureReasonVerificationFailed
+			}
+			failures = append(failures, err.Error())
+			continue
+		}
+		log.Info("Verified the signature of the image", "image", image, "digest", digest)
+		digests[image] = digest
+	}
+	if len(failures) == 0 {
+		pinVerifiedImages(runnerSet, digests)
+		return true, r.clearImageSignatureUnverified(ctx, autoscalingRunnerSet)
+	}
+
+	message := fmt.Sprintf("Not rolling out the runners: %s", strings.Join(failures, ", "))
+	log.Info("Images of the runners have no verified signature. Not creating the runner set", "failures", failures)
+	return false, r.reportImageSignatureUnverified(ctx, autoscalingRunnerSet, reason, message)
+}
+
+// reportImageSignatureUnverified reports with the ImageSignatureUnverified condition and an event why the images
+// of the autoscaling runner set aren't rolled out.
+func (r *AutoscalingRunnerSetReconciler) reportImageSignatureUnverified(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, reason, message string) error {
+	if condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified); condition != nil && condition.Reason == reason && condition.Message == message {
+		return nil
+	}
+	if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to update autoscaling runner set status with the unverified images: %v", err)
+	}
+	r.Recorder.Event(autoscalingRunnerSet, corev1.EventTypeWarning, reasonImageSignatureUnverified, message)
+	return nil
+}
+
+// pinVerifiedImages replaces the runner and dind images of the templates of the runner set with the digests
+// their signatures were verified for, so that the runner pods don't pull another image the tag was moved to.
+func pinVerifiedImages(runnerSet *v1alpha1.EphemeralRunnerSet, digests map[string]string) {
+	templates := []*corev1.PodTemplateSpec{&runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec}
+	for i := range runnerSet.Spec.TemplateVariants {
+		templates = append(templates, &runnerSet.Spec.TemplateVariants[i].Template)
+	}
+	for _, template := range templates {
+		for i := range template.Spec.Containers {
+			c := &template.Spec.Containers[i]
+			if c.Name != EphemeralRunnerContainerName && c.Name != dindContainerName {
+				continue
+			}
+			if digest, ok := digests[c.Image]; ok && !strings.Contains(c.Image, "@") {
+				c.Image += "@" + digest
+			}
+		}
+	}
+}
+
+// clearInvalidImageSignaturePolicy removes the ImageSignatureUnverified condition reported for an invalid
+// image signature verification, once it's valid.
+func (r *AutoscalingRunnerSetReconciler) clearInvalidImageSignaturePolicy(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified); condition == nil || condition.Reason != v1alpha1.ImageSignatureReasonInvalidPolicy {
+		return nil
+	}
+	return r.clearImageSignatureUnverified(ctx, autoscalingRunnerSet)
+}
+
+func (r *AutoscalingRunnerSetReconciler) clearImageSignatureUnverified(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified) == nil {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		meta.RemoveStatusCondition(&obj.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified)
+	})
+}
@@ -0,0 +1,224 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/pkg/imagedigest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeImageSignatureVerifier struct {
+	errs     map[string]error
+	verified []string
+}
+
+func (f *fakeImageSignatureVerifier) VerifySignature(ctx context.Context, image string, policy *imagedigest.SignaturePolicy, keychain imagedigest.Keychain) (string, error) {
+	if err := f.errs[image]; err != nil {
+		return "", err
+	}
+	f.verified = append(f.verified, image)
+	return "sha256:aaaa", nil
+}
+
+func testPublicKeyPEM(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestReconcileRunnerImageSignatures(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:            "https://github.com/owner/repo",
+			GitHubConfigSecret:         "secret",
+			Template:                   newTestEphemeralRunner().Spec.PodTemplateSpec,
+			ImageSignatureVerification: &v1alpha1.ImageSignatureVerification{PublicKey: testPublicKeyPEM(t), RekorPublicKey: testPublicKeyPEM(t)},
+		},
+	}
+	autoscalingRunnerSet.Spec.Template.Spec.Containers = append(autoscalingRunnerSet.Spec.Template.Spec.Containers, corev1.Container{
+		Name:  dindContainerName,
+		Image: "docker:dind",
+	})
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	recorder := record.NewFakeRecorder(10)
+	verifier := &fakeImageSignatureVerifier{errs: map[string]error{
+		"docker:dind": fmt.Errorf("%w: docker:dind is not signed", imagedigest.ErrUnverifiedSignature),
+	}}
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: recorder, ImageSignatureVerifier: verifier}
+
+	runnerSet, err := r.resourceBuilder.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	verified, err := r.reconcileRunnerImageSignatures(ctx, autoscalingRunnerSet, runnerSet, logr.Discard())
+	require.NoError(t, err)
+	assert.False(t, verified)
+	assert.Equal(t, []string{"ghcr.io/actions/runner"}, verifier.verified, "Only the runner and dind images are verified")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.ImageSignatureReasonUnsigned, condition.Reason)
+	assert.Contains(t, condition.Message, "docker:dind is not signed")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, reasonImageSignatureUnverified)
+
+	// Registry failures leave the images unverified too.
+	verifier.errs["docker:dind"] = errors.New("connection refused")
+	verified, err = r.reconcileRunnerImageSignatures(ctx, autoscalingRunnerSet, runnerSet, logr.Discard())
+	require.NoError(t, err)
+	assert.False(t, verified)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	condition = meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.ImageSignatureReasonVerificationFailed, condition.Reason)
+
+	// The condition is cleared once the images are signed.
+	delete(verifier.errs, "docker:dind")
+	verified, err = r.reconcileRunnerImageSignatures(ctx, autoscalingRunnerSet, runnerSet, logr.Discard())
+	require.NoError(t, err)
+	assert.True(t, verified)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	assert.Nil(t, meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified))
+
+	// The runner set runs the verified digests of the images.
+	images := make(map[string]string)
+	for _, c := range runnerSet.Spec.EphemeralRunnerSpec.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	assert.Equal(t, "ghcr.io/actions/runner@sha256:aaaa", images[EphemeralRunnerContainerName])
+	assert.Equal(t, "docker:dind@sha256:aaaa", images[dindContainerName])
+}
+
+func TestPinVerifiedImages(t *testing.T) {
+	runnerSet := &v1alpha1.EphemeralRunnerSet{}
+	runnerSet.Spec.EphemeralRunnerSpec.Spec.Containers = []corev1.Container{
+		{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/runner@sha256:bbbb"},
+		{Name: "sidecar", Image: "busybox"},
+	}
+	runnerSet.Spec.TemplateVariants = []v1alpha1.TemplateVariant{{
+		Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/runner:gpu"},
+		}}},
+	}}
+
+	pinVerifiedImages(runnerSet, map[string]string{
+		"ghcr.io/actions/runner@sha256:bbbb": "sha256:bbbb",
+		"ghcr.io/actions/runner:gpu":         "sha256:cccc",
+		"busybox":                            "sha256:dddd",
+	})
+	assert.Equal(t, "ghcr.io/actions/runner@sha256:bbbb", runnerSet.Spec.EphemeralRunnerSpec.Spec.Containers[0].Image, "Pinned images are kept")
+	assert.Equal(t, "busybox", runnerSet.Spec.EphemeralRunnerSpec.Spec.Containers[1].Image, "Only the runner and dind images are pinned")
+	assert.Equal(t, "ghcr.io/actions/runner:gpu@sha256:cccc", runnerSet.Spec.TemplateVariants[0].Template.Spec.Containers[0].Image)
+}
+
+func TestImageSignaturePolicy(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{}
+	policy, err := imageSignaturePolicy(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	autoscalingRunnerSet.Spec.ImageSignatureVerification = &v1alpha1.ImageSignatureVerification{}
+	_, err = imageSignaturePolicy(autoscalingRunnerSet)
+	assert.Error(t, err, "A public key or keyless verification is required")
+
+	autoscalingRunnerSet.Spec.ImageSignatureVerification.PublicKey = "not a key"
+	_, err = imageSignaturePolicy(autoscalingRunnerSet)
+	assert.Error(t, err)
+
+	autoscalingRunnerSet.Spec.ImageSignatureVerification.PublicKey = testPublicKeyPEM(t)
+	_, err = imageSignaturePolicy(autoscalingRunnerSet)
+	assert.ErrorContains(t, err, "the Rekor public key is required", "Key-based signatures must be logged")
+
+	autoscalingRunnerSet.Spec.ImageSignatureVerification.RekorPublicKey = "not a key"
+	_, err = imageSignaturePolicy(autoscalingRunnerSet)
+	assert.ErrorContains(t, err, "invalid Rekor public key")
+
+	autoscalingRunnerSet.Spec.ImageSignatureVerification.RekorPublicKey = testPublicKeyPEM(t)
+	policy, err = imageSignaturePolicy(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.NotNil(t, policy.PublicKey)
+	assert.NotNil(t, policy.RekorPublicKey)
+
+	autoscalingRunnerSet.Spec.ImageSignatureVerification = &v1alpha1.ImageSignatureVerification{
+		Keyless: &v1alpha1.KeylessSignatureVerification{
+			Issuer:         "https://token.actions.githubusercontent.com",
+			Subject:        "https://github.com/octo-org/runner-images/.github/workflows/release.yml@refs/heads/main",
+			RekorPublicKey: testPublicKeyPEM(t),
+		},
+	}
+	_, err = imageSignaturePolicy(autoscalingRunnerSet)
+	assert.ErrorContains(t, err, "invalid Fulcio certificates")
+}
+
+func TestReconcile_InvalidImageSignatureVerification(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "arc",
+			Namespace:  "runners",
+			Finalizers: []string{autoscalingRunnerSetFinalizerName},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:            "https://github.com/owner/repo",
+			GitHubConfigSecret:         "secret",
+			Template:                   newTestEphemeralRunner().Spec.PodTemplateSpec,
+			ImageSignatureVerification: &v1alpha1.ImageSignatureVerification{PublicKey: testPublicKeyPEM(t)},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Log: logr.Discard(), Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(autoscalingRunnerSet)}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, autoscalingRunnerSet))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.ImageSignatureReasonInvalidPolicy, condition.Reason)
+	assert.Contains(t, condition.Message, "the Rekor public key is required")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, reasonImageSignatureUnverified)
+
+	// The condition is cleared once the verification is fixed.
+	autoscalingRunnerSet.Spec.ImageSignatureVerification.RekorPublicKey = testPublicKeyPEM(t)
+	require.NoError(t, r.clearInvalidImageSignaturePolicy(ctx, autoscalingRunnerSet))
+	require.NoError(t, c.Get(ctx, req.NamespacedName, autoscalingRunnerSet))
+	assert.Nil(t, meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionImageSignatureUnverified))
+}
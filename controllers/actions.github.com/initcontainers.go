@@ -0,0 +1,52 @@
+package actionsgithubcom
+
+import (
+	"fmt"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// prependInitContainers makes the init containers run before the init containers of the pod spec.
+func prependInitContainers(spec *corev1.PodSpec, initContainers []corev1.Container) {
+	if len(initContainers) == 0 {
+		return
+	}
+	merged := make([]corev1.Container, 0, len(initContainers)+len(spec.InitContainers))
+	for i := range initContainers {
+		merged = append(merged, *initContainers[i].DeepCopy())
+	}
+	spec.InitContainers = append(merged, spec.InitContainers...)
+}
+
+// validateInitContainers returns an error when an init container of the autoscaling runner set has no name,
+// or the name of another container of the runner pods.
+func validateInitContainers(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if len(autoscalingRunnerSet.Spec.InitContainers) == 0 {
+		return nil
+	}
+
+	templates := []*corev1.PodTemplateSpec{&autoscalingRunnerSet.Spec.Template}
+	for i := range autoscalingRunnerSet.Spec.TemplateVariants {
+		templates = append(templates, &autoscalingRunnerSet.Spec.TemplateVariants[i].Template)
+	}
+
+	names := make(map[string]bool)
+	for _, c := range autoscalingRunnerSet.Spec.InitContainers {
+		if c.Name == "" {
+			return fmt.Errorf("init container without a name")
+		}
+		if names[c.Name] {
+			return fmt.Errorf("init container %q is defined more than once", c.Name)
+		}
+		names[c.Name] = true
+	}
+	for _, template := range templates {
+		for _, c := range append(append([]corev1.Container{}, template.Spec.InitContainers...), template.Spec.Containers...) {
+			if names[c.Name] {
+				return fmt.Errorf("init container %q has the name of a container of the template", c.Name)
+			}
+		}
+	}
+	return nil
+}
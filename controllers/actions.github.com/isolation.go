@@ -0,0 +1,102 @@
+package actionsgithubcom
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// validateListenerTemplate returns an error when the listener pod template sets fields other than the ones
+// placing and sizing the listener pod. The listener pod runs in the controller namespace with the listener
+// credentials, so a template could otherwise read the listener secrets of other namespaces through secret
+// or config map references, run sidecars or privileged containers, mount the node or swap the listener image.
+//
+// The template may set the labels and annotations of the pod, its node selector, affinity, tolerations,
+// topology spread constraints, priority class and security context, emptyDir volumes, and the resources,
+// plain and field environment variables, volume mounts and unprivileged security context of the listener container.
+func validateListenerTemplate(template *corev1.PodTemplateSpec) error {
+	if template == nil {
+		return nil
+	}
+	spec := &template.Spec
+
+	if len(spec.InitContainers) > 0 || len(spec.EphemeralContainers) > 0 {
+		return fmt.Errorf("the listener pod can't have init or ephemeral containers")
+	}
+	for _, c := range spec.Containers {
+		if c.Name != name {
+			return fmt.Errorf("container %q is a sidecar, only the %q listener container can be customized", c.Name, name)
+		}
+		if err := validateListenerContainer(&c); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range spec.Volumes {
+		if v.EmptyDir == nil {
+			return fmt.Errorf("volume %q isn't an emptyDir volume", v.Name)
+		}
+	}
+
+	if sc := spec.SecurityContext; sc != nil && sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+		return fmt.Errorf("the listener pod can't be a host process")
+	}
+
+	allowed := corev1.PodSpec{
+		Containers:                spec.Containers,
+		Volumes:                   spec.Volumes,
+		NodeSelector:              spec.NodeSelector,
+		Affinity:                  spec.Affinity,
+		Tolerations:               spec.Tolerations,
+		TopologySpreadConstraints: spec.TopologySpreadConstraints,
+		PriorityClassName:         spec.PriorityClassName,
+		SecurityContext:           spec.SecurityContext,
+	}
+	if !equality.Semantic.DeepEqual(*spec, allowed) {
+		return fmt.Errorf("the listener pod template sets pod fields other than nodeSelector, affinity, tolerations, topologySpreadConstraints, priorityClassName, securityContext, volumes and containers")
+	}
+
+	return nil
+}
+
+func validateListenerContainer(c *corev1.Container) error {
+	if c.Image != "" {
+		return fmt.Errorf("container %q can't change the listener image, set listenerImage instead", c.Name)
+	}
+
+	for _, env := range c.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+		if env.ValueFrom.FieldRef == nil && env.ValueFrom.ResourceFieldRef == nil {
+			return fmt.Errorf("environment variable %q of container %q references a secret or a config map", env.Name, c.Name)
+		}
+	}
+
+	if sc := c.SecurityContext; sc != nil {
+		switch {
+		case sc.Privileged != nil && *sc.Privileged:
+			return fmt.Errorf("container %q can't be privileged", c.Name)
+		case sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation:
+			return fmt.Errorf("container %q can't allow privilege escalation", c.Name)
+		case sc.Capabilities != nil && len(sc.Capabilities.Add) > 0:
+			return fmt.Errorf("container %q can't add capabilities", c.Name)
+		case sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess:
+			return fmt.Errorf("container %q can't be a host process", c.Name)
+		}
+	}
+
+	allowed := corev1.Container{
+		Name:            c.Name,
+		Resources:       c.Resources,
+		Env:             c.Env,
+		VolumeMounts:    c.VolumeMounts,
+		SecurityContext: c.SecurityContext,
+	}
+	if !equality.Semantic.DeepEqual(*c, allowed) {
+		return fmt.Errorf("container %q sets fields other than resources, env, volumeMounts and securityContext", c.Name)
+	}
+
+	return nil
+}
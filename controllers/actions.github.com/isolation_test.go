@@ -0,0 +1,138 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateListenerTemplate(t *testing.T) {
+	privileged := true
+	tests := map[string]struct {
+		spec    corev1.PodSpec
+		invalid bool
+	}{
+		"placement": {
+			spec: corev1.PodSpec{
+				NodeSelector:      map[string]string{"kubernetes.io/os": "linux"},
+				Tolerations:       []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+				PriorityClassName: "system-cluster-critical",
+			},
+		},
+		"empty dir volume": {
+			spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}},
+		},
+		"listener container resources and environment": {
+			spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "autoscaler",
+				Env:  []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				},
+			}}},
+		},
+		"sidecar": {
+			spec:    corev1.PodSpec{Containers: []corev1.Container{{Name: "autoscaler"}, {Name: "sidecar", Image: "busybox"}}},
+			invalid: true,
+		},
+		"init container": {
+			spec:    corev1.PodSpec{InitContainers: []corev1.Container{{Name: "init", Image: "busybox"}}},
+			invalid: true,
+		},
+		"host path volume": {
+			spec:    corev1.PodSpec{Volumes: []corev1.Volume{{Name: "root", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}}}},
+			invalid: true,
+		},
+		"csi volume": {
+			spec:    corev1.PodSpec{Volumes: []corev1.Volume{{Name: "secrets", VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{Driver: "secrets-store.csi.k8s.io"}}}}},
+			invalid: true,
+		},
+		"secret volume": {
+			spec:    corev1.PodSpec{Volumes: []corev1.Volume{{Name: "creds", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "other"}}}}},
+			invalid: true,
+		},
+		"privileged container": {
+			spec:    corev1.PodSpec{Containers: []corev1.Container{{Name: "autoscaler", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}}}},
+			invalid: true,
+		},
+		"listener image": {
+			spec:    corev1.PodSpec{Containers: []corev1.Container{{Name: "autoscaler", Image: "attacker/listener"}}},
+			invalid: true,
+		},
+		"listener command": {
+			spec:    corev1.PodSpec{Containers: []corev1.Container{{Name: "autoscaler", Command: []string{"sh", "-c", "cat /var/run/secrets/*"}}}},
+			invalid: true,
+		},
+		"host network": {
+			spec:    corev1.PodSpec{HostNetwork: true},
+			invalid: true,
+		},
+		"secret environment variable": {
+			spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "autoscaler", Env: []corev1.EnvVar{{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "other"}, Key: "github_token"},
+			}}}}}},
+			invalid: true,
+		},
+		"environment from a config map": {
+			spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "autoscaler", EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "other"}},
+			}}}}},
+			invalid: true,
+		},
+	}
+
+	assert.NoError(t, validateListenerTemplate(nil))
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateListenerTemplate(&corev1.PodTemplateSpec{Spec: tc.spec})
+			if tc.invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAutoscalingListenerReconciler_ControllerNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	listener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "tenant-a"},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			AutoscalingRunnerSetNamespace: "tenant-b",
+			AutoscalingRunnerSetName:      "test-asrs",
+			GitHubConfigSecret:            "github-config-secret",
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(listener).Build()
+
+	r := &AutoscalingListenerReconciler{
+		Client:              c,
+		Log:                 logr.Discard(),
+		Scheme:              scheme,
+		ControllerNamespace: "arc-systems",
+	}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(listener)})
+	require.NoError(t, err)
+
+	updated := new(v1alpha1.AutoscalingListener)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(listener), updated))
+	assert.Empty(t, updated.Finalizers, "Listeners outside of the controller namespace are not reconciled")
+}
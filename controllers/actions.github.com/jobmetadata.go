@@ -0,0 +1,189 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// jobMetadata returns the labels and the annotations of the runner pod describing the job of the runner.
+// Metadata that isn't known is left out.
+func jobMetadata(ephemeralRunner *v1alpha1.EphemeralRunner) (labels, annotations map[string]string) {
+	status := ephemeralRunner.Status
+	owner, repository, _ := strings.Cut(status.JobRepositoryName, "/")
+	workflowName := status.JobWorkflowName
+	if workflowName == "" {
+		workflowName = workflowNameFromRef(status.JobWorkflowRef)
+	}
+	var workflowRunId string
+	if status.WorkflowRunId != 0 {
+		workflowRunId = strconv.FormatInt(status.WorkflowRunId, 10)
+	}
+
+	labels = make(map[string]string)
+	annotations = make(map[string]string)
+	for key, value := range map[string]string{
+		JobMetadataKeyRepositoryOwner: owner,
+		JobMetadataKeyRepositoryName:  repository,
+		JobMetadataKeyWorkflowName:    workflowName,
+		JobMetadataKeyJobName:         status.JobDisplayName,
+		JobMetadataKeyWorkflowRunId:   workflowRunId,
+		JobMetadataKeyRequester:       status.JobRequester,
+	} {
+		if value == "" {
+			continue
+		}
+		annotations[key] = value
+		if labelValue := jobMetadataLabelValue(value); labelValue != "" {
+			labels[key] = labelValue
+		}
+	}
+	if status.JobWorkflowRef != "" {
+		annotations[JobMetadataKeyWorkflowRef] = status.JobWorkflowRef
+	}
+	if status.JobRequestId != 0 {
+		annotations[JobMetadataKeyJobRequestId] = strconv.FormatInt(status.JobRequestId, 10)
+	}
+	if len(status.JobRequestLabels) > 0 {
+		annotations[JobMetadataKeyJobLabels] = strings.Join(status.JobRequestLabels, ",")
+	}
+	return labels, annotations
+}
+
+// jobMetadataFiles are the files of the job metadata volume, named after the environment variables
+// they're meant for, with the annotations of the runner pod they hold.
+var jobMetadataFiles = []struct {
+	name       string
+	annotation string
+}{
+	{name: "ARC_JOB_REPOSITORY_OWNER", annotation: JobMetadataKeyRepositoryOwner},
+	{name: "ARC_JOB_REPOSITORY_NAME", annotation: JobMetadataKeyRepositoryName},
+	{name: "ARC_JOB_WORKFLOW_NAME", annotation: JobMetadataKeyWorkflowName},
+	{name: "ARC_JOB_WORKFLOW_REF", annotation: JobMetadataKeyWorkflowRef},
+	{name: "ARC_JOB_WORKFLOW_RUN_ID", annotation: JobMetadataKeyWorkflowRunId},
+	{name: "ARC_JOB_NAME", annotation: JobMetadataKeyJobName},
+	{name: "ARC_JOB_REQUEST_ID", annotation: JobMetadataKeyJobRequestId},
+	{name: "ARC_JOB_REQUESTER", annotation: JobMetadataKeyRequester},
+	{name: "ARC_JOB_LABELS", annotation: JobMetadataKeyJobLabels},
+}
+
+// addJobMetadataVolume mounts the job metadata annotations of the pod into the runner container as files.
+// The environment of a running container can't change, while the kubelet refreshes the files once the
+// pod is annotated with the job of the runner. The pod template can replace the volume with its own.
+func addJobMetadataVolume(pod *corev1.Pod) {
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == JobMetadataVolumeName {
+			return
+		}
+	}
+
+	items := make([]corev1.DownwardAPIVolumeFile, 0, len(jobMetadataFiles))
+	for _, f := range jobMetadataFiles {
+		items = append(items, corev1.DownwardAPIVolumeFile{
+			Path:     f.name,
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: fmt.Sprintf("metadata.annotations['%s']", f.annotation)},
+		})
+	}
+	volumes := make([]corev1.Volume, 0, len(pod.Spec.Volumes)+1)
+	volumes = append(volumes, pod.Spec.Volumes...)
+	pod.Spec.Volumes = append(volumes, corev1.Volume{
+		Name: JobMetadataVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{Items: items},
+		},
+	})
+
+	mountPath := JobMetadataMountPath
+	if isWindowsPod(&pod.Spec) {
+		mountPath = WindowsJobMetadataMountPath
+	}
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		mounts := make([]corev1.VolumeMount, 0, len(c.VolumeMounts)+1)
+		mounts = append(mounts, c.VolumeMounts...)
+		c.VolumeMounts = append(mounts, corev1.VolumeMount{
+			Name:      JobMetadataVolumeName,
+			MountPath: mountPath,
+			ReadOnly:  true,
+		})
+		c.Env = appendEnvIfMissing(c.Env, corev1.EnvVar{Name: EnvVarJobMetadataDir, Value: mountPath})
+	}
+}
+
+// workflowNameFromRef returns the name of the workflow file of a workflow ref,
+// e.g. ci for owner/repo/.github/workflows/ci.yaml@refs/heads/main.
+func workflowNameFromRef(ref string) string {
+	file, _, _ := strings.Cut(ref, "@")
+	if file == "" {
+		return ""
+	}
+	name := path.Base(file)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// jobMetadataLabelValue turns the value into a valid label value, replacing the invalid characters
+// with dashes and truncating it to the maximum length.
+func jobMetadataLabelValue(value string) string {
+	b := []byte(value)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			b[i] = '-'
+		}
+	}
+	if len(b) > validation.LabelValueMaxLength {
+		b = b[:validation.LabelValueMaxLength]
+	}
+	// Label values begin and end with an alphanumeric character.
+	return strings.Trim(string(b), "-_.")
+}
+
+// updatePodJobMetadata labels and annotates the runner pod with the metadata of the job of the runner,
+// once the listener reported the job.
+func (r *EphemeralRunnerReconciler) updatePodJobMetadata(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	if ephemeralRunner.Status.JobRequestId == 0 {
+		return nil
+	}
+
+	labels, annotations := jobMetadata(ephemeralRunner)
+	if containsAll(pod.Labels, labels) && containsAll(pod.Annotations, annotations) {
+		return nil
+	}
+
+	log.Info("Updating the runner pod with the metadata of its job", "jobRequestId", ephemeralRunner.Status.JobRequestId)
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Labels == nil {
+			obj.Labels = make(map[string]string)
+		}
+		for key, value := range labels {
+			obj.Labels[key] = value
+		}
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string)
+		}
+		for key, value := range annotations {
+			obj.Annotations[key] = value
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to update the job metadata of the runner pod: %v", err)
+	}
+	return nil
+}
+
+func containsAll(m, entries map[string]string) bool {
+	for key, value := range entries {
+		if v, ok := m[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
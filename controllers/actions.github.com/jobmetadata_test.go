@@ -0,0 +1,123 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestJobMetadata(t *testing.T) {
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		Status: v1alpha1.EphemeralRunnerStatus{
+			JobRequestId:      7,
+			JobRepositoryName: "owner/repo",
+			JobWorkflowRef:    "owner/repo/.github/workflows/ci.yaml@refs/heads/main",
+			JobDisplayName:    "build (ubuntu, 1.20)",
+			WorkflowRunId:     100,
+			JobRequester:      "octocat",
+			JobRequestLabels:  []string{"self-hosted", "linux"},
+		},
+	}
+
+	labels, annotations := jobMetadata(ephemeralRunner)
+	assert.Equal(t, map[string]string{
+		JobMetadataKeyRepositoryOwner: "owner",
+		JobMetadataKeyRepositoryName:  "repo",
+		JobMetadataKeyWorkflowName:    "ci",
+		JobMetadataKeyJobName:         "build--ubuntu--1.20",
+		JobMetadataKeyWorkflowRunId:   "100",
+		JobMetadataKeyRequester:       "octocat",
+	}, labels)
+	assert.Equal(t, map[string]string{
+		JobMetadataKeyRepositoryOwner: "owner",
+		JobMetadataKeyRepositoryName:  "repo",
+		JobMetadataKeyWorkflowName:    "ci",
+		JobMetadataKeyJobName:         "build (ubuntu, 1.20)",
+		JobMetadataKeyWorkflowRunId:   "100",
+		JobMetadataKeyRequester:       "octocat",
+		JobMetadataKeyWorkflowRef:     "owner/repo/.github/workflows/ci.yaml@refs/heads/main",
+		JobMetadataKeyJobRequestId:    "7",
+		JobMetadataKeyJobLabels:       "self-hosted,linux",
+	}, annotations)
+
+	ephemeralRunner.Status.JobWorkflowName = "Continuous Integration"
+	labels, annotations = jobMetadata(ephemeralRunner)
+	assert.Equal(t, "Continuous-Integration", labels[JobMetadataKeyWorkflowName], "The workflow name of the webhook events wins over the workflow file")
+	assert.Equal(t, "Continuous Integration", annotations[JobMetadataKeyWorkflowName])
+
+	assert.Len(t, jobMetadataLabelValue(strings.Repeat("a", 100)), 63)
+	assert.Equal(t, "", jobMetadataLabelValue("🚀"))
+}
+
+func TestAddJobMetadataVolume(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: EphemeralRunnerContainerName}, {Name: "dind"}},
+		},
+	}
+	addJobMetadataVolume(pod)
+
+	require.Len(t, pod.Spec.Volumes, 1)
+	volume := pod.Spec.Volumes[0]
+	assert.Equal(t, JobMetadataVolumeName, volume.Name)
+	require.NotNil(t, volume.DownwardAPI)
+	assert.Contains(t, volume.DownwardAPI.Items, corev1.DownwardAPIVolumeFile{
+		Path:     "ARC_JOB_WORKFLOW_RUN_ID",
+		FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations['actions.github.com/workflow-run-id']"},
+	})
+	assert.Equal(t, []corev1.VolumeMount{{Name: JobMetadataVolumeName, MountPath: JobMetadataMountPath, ReadOnly: true}}, pod.Spec.Containers[0].VolumeMounts)
+	assert.Equal(t, []corev1.EnvVar{{Name: EnvVarJobMetadataDir, Value: JobMetadataMountPath}}, pod.Spec.Containers[0].Env)
+	assert.Empty(t, pod.Spec.Containers[1].VolumeMounts)
+
+	addJobMetadataVolume(pod)
+	assert.Len(t, pod.Spec.Volumes, 1, "The volume is added once")
+	assert.Len(t, pod.Spec.Containers[0].VolumeMounts, 1)
+
+	windowsPod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{corev1.LabelOSStable: "windows"},
+			Containers:   []corev1.Container{{Name: EphemeralRunnerContainerName}},
+		},
+	}
+	addJobMetadataVolume(windowsPod)
+	assert.Equal(t, WindowsJobMetadataMountPath, windowsPod.Spec.Containers[0].VolumeMounts[0].MountPath)
+}
+
+func TestUpdatePodJobMetadata(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default", Labels: map[string]string{"app": "runner"}},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme}
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+	}
+	require.NoError(t, r.updatePodJobMetadata(context.Background(), ephemeralRunner, pod, logr.Discard()))
+	assert.Equal(t, map[string]string{"app": "runner"}, pod.Labels, "Idle runners are not labeled")
+
+	ephemeralRunner.Status.JobRequestId = 7
+	ephemeralRunner.Status.JobRepositoryName = "owner/repo"
+	require.NoError(t, r.updatePodJobMetadata(context.Background(), ephemeralRunner, pod, logr.Discard()))
+
+	updated := new(corev1.Pod)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+	assert.Equal(t, "runner", updated.Labels["app"])
+	assert.Equal(t, "repo", updated.Labels[JobMetadataKeyRepositoryName])
+	assert.Equal(t, "7", updated.Annotations[JobMetadataKeyJobRequestId])
+}
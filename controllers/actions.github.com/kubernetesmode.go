@@ -0,0 +1,192 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kubernetesModeRBAC reports whether the controller manages the service account of the runner pods
+// of the autoscaling runner set for the container hooks of the kubernetes container mode.
+// A service account set by the template is used as it is.
+func kubernetesModeRBAC(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) bool {
+	mode := autoscalingRunnerSet.Spec.ContainerMode
+	return mode != nil && mode.Type == v1alpha1.ContainerModeTypeKubernetes && autoscalingRunnerSet.Spec.Template.Spec.ServiceAccountName == ""
+}
+
+func kubernetesModeServiceAccountName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return autoscalingRunnerSet.Name + "-kube-mode"
+}
+
+// applyKubernetesModeServiceAccount runs the runner pods of the template with the service account
+// of the kubernetes container mode, when the controller manages it and the template sets none.
+func applyKubernetesModeServiceAccount(template *corev1.PodTemplateSpec, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	if kubernetesModeRBAC(autoscalingRunnerSet) && template.Spec.ServiceAccountName == "" {
+		template.Spec.ServiceAccountName = kubernetesModeServiceAccountName(autoscalingRunnerSet)
+	}
+}
+
+// rulesForKubernetesModeRole are the permissions the container hooks need to run the containers
+// of the jobs in pods of their own.
+func rulesForKubernetesModeRole() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"get", "list", "create", "delete"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods/exec"},
+			Verbs:     []string{"get", "create"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods/log"},
+			Verbs:     []string{"get", "list", "watch"},
+		},
+		{
+			APIGroups: []string{"batch"},
+			Resources: []string{"jobs"},
+			Verbs:     []string{"get", "list", "create", "delete"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+			Verbs:     []string{"get", "list", "create", "delete"},
+		},
+	}
+}
+
+func (b *resourceBuilder) newKubernetesModeServiceAccount(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubernetesModeServiceAccountName(autoscalingRunnerSet),
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+				"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+			},
+			Annotations: runnerServiceAccountAnnotations(autoscalingRunnerSet),
+		},
+	}
+}
+
+func (b *resourceBuilder) newKubernetesModeRole(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubernetesModeServiceAccountName(autoscalingRunnerSet),
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+				"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+			},
+		},
+		Rules: rulesForKubernetesModeRole(),
+	}
+}
+
+func (b *resourceBuilder) newKubernetesModeRoleBinding(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *rbacv1.RoleBinding {
+	name := kubernetesModeServiceAccountName(autoscalingRunnerSet)
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+				"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Namespace: autoscalingRunnerSet.Namespace,
+				Name:      name,
+			},
+		},
+	}
+}
+
+// reconcileKubernetesModeRBAC creates the service account, role and role binding of the runner pods of the
+// kubernetes container mode, owned by the autoscaling runner set, and deletes them once the controller no
+// longer manages them. They are created before the runner sets, so that the runner pods can use them right away.
+func (r *AutoscalingRunnerSetReconciler) reconcileKubernetesModeRBAC(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	key := types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: kubernetesModeServiceAccountName(autoscalingRunnerSet)}
+	objects := []client.Object{
+		r.resourceBuilder.newKubernetesModeServiceAccount(autoscalingRunnerSet),
+		r.resourceBuilder.newKubernetesModeRole(autoscalingRunnerSet),
+		r.resourceBuilder.newKubernetesModeRoleBinding(autoscalingRunnerSet),
+	}
+	enabled := kubernetesModeRBAC(autoscalingRunnerSet)
+
+	for _, desired := range objects {
+		kind := reflect.TypeOf(desired).Elem().Name()
+		current := desired.DeepCopyObject().(client.Object)
+		if err := r.Get(ctx, key, current); err != nil {
+			if !kerrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get kubernetes mode %s: %v", kind, err)
+			}
+			current = nil
+		}
+
+		if !enabled {
+			if current == nil || !metav1.IsControlledBy(current, autoscalingRunnerSet) {
+				continue
+			}
+			log.Info("Kubernetes container mode RBAC is no longer managed. Deleting it", "kind", kind, "name", key.Name)
+			if err := r.Delete(ctx, current); err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete kubernetes mode %s: %v", kind, err)
+			}
+			continue
+		}
+
+		if current == nil {
+			if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+				return fmt.Errorf("failed to set controller reference: %v", err)
+			}
+			log.Info("Creating kubernetes container mode RBAC", "kind", kind, "name", key.Name)
+			if err := r.Create(ctx, desired); err != nil {
+				return fmt.Errorf("failed to create kubernetes mode %s: %v", kind, err)
+			}
+			continue
+		}
+
+		// The workload identity annotations of the service account and the rules of the role are the only parts
+		// that can change. Objects the controller doesn't own are left untouched.
+		if serviceAccount, ok := current.(*corev1.ServiceAccount); ok {
+			if !metav1.IsControlledBy(serviceAccount, autoscalingRunnerSet) {
+				continue
+			}
+			if err := r.syncRunnerServiceAccountAnnotations(ctx, serviceAccount, autoscalingRunnerSet, log); err != nil {
+				return err
+			}
+			continue
+		}
+		role, ok := current.(*rbacv1.Role)
+		if !ok || !metav1.IsControlledBy(role, autoscalingRunnerSet) || reflect.DeepEqual(role.Rules, desired.(*rbacv1.Role).Rules) {
+			continue
+		}
+		log.Info("Updating the rules of the kubernetes container mode role", "name", key.Name)
+		if err := patch(ctx, r.Client, role, func(obj *rbacv1.Role) {
+			obj.Rules = desired.(*rbacv1.Role).Rules
+		}); err != nil {
+			return fmt.Errorf("failed to update kubernetes mode role: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileKubernetesModeRBAC(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners", UID: "1234"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ContainerMode: &v1alpha1.ContainerMode{Type: v1alpha1.ContainerModeTypeKubernetes},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	require.NoError(t, r.reconcileKubernetesModeRBAC(ctx, autoscalingRunnerSet, logr.Discard()))
+
+	key := types.NamespacedName{Namespace: "runners", Name: "arc-kube-mode"}
+	serviceAccount := new(corev1.ServiceAccount)
+	require.NoError(t, c.Get(ctx, key, serviceAccount))
+	assert.True(t, metav1.IsControlledBy(serviceAccount, autoscalingRunnerSet))
+
+	role := new(rbacv1.Role)
+	require.NoError(t, c.Get(ctx, key, role))
+	assert.Equal(t, rulesForKubernetesModeRole(), role.Rules)
+
+	roleBinding := new(rbacv1.RoleBinding)
+	require.NoError(t, c.Get(ctx, key, roleBinding))
+	assert.Equal(t, "arc-kube-mode", roleBinding.RoleRef.Name)
+	require.Len(t, roleBinding.Subjects, 1)
+	assert.Equal(t, "arc-kube-mode", roleBinding.Subjects[0].Name)
+	assert.Equal(t, "runners", roleBinding.Subjects[0].Namespace)
+
+	// Outdated rules are restored.
+	require.NoError(t, patch(ctx, c, role, func(obj *rbacv1.Role) {
+		obj.Rules = obj.Rules[:1]
+	}))
+	require.NoError(t, r.reconcileKubernetesModeRBAC(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, key, role))
+	assert.Equal(t, rulesForKubernetesModeRole(), role.Rules)
+
+	// A service account of the template is used as it is.
+	autoscalingRunnerSet.Spec.Template.Spec.ServiceAccountName = "my-runners"
+	require.NoError(t, r.reconcileKubernetesModeRBAC(ctx, autoscalingRunnerSet, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, new(corev1.ServiceAccount))))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, new(rbacv1.Role))))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, new(rbacv1.RoleBinding))))
+}
+
+func TestNewEphemeralRunnerSet_KubernetesMode(t *testing.T) {
+	b := resourceBuilder{}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			ContainerMode:      &v1alpha1.ContainerMode{Type: v1alpha1.ContainerModeTypeKubernetes},
+		},
+	}
+
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, "arc-kube-mode", runnerSet.Spec.EphemeralRunnerSpec.Spec.ServiceAccountName)
+	assert.Empty(t, autoscalingRunnerSet.Spec.Template.Spec.ServiceAccountName, "The template of the autoscaling runner set is left as it is")
+
+	autoscalingRunnerSet.Spec.ContainerMode.Type = v1alpha1.ContainerModeTypeDind
+	runnerSet, err = b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Empty(t, runnerSet.Spec.EphemeralRunnerSpec.Spec.ServiceAccountName)
+}
@@ -0,0 +1,252 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cronSearchLimit bounds the search for the next start of a schedule, e.g. "0 0 30 2 *" never matches.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// cronSchedule is a parsed cron expression with five fields: minute, hour, day of month, month and day of week.
+// Every field is the set of values it matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// A restricted day of month or day of week matches the day when either of them matches,
+	// like in crontab(5).
+	domRestricted, dowRestricted bool
+
+	location *time.Location
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// 7 is Sunday as well.
+	{name: "day of week", min: 0, max: 7},
+}
+
+func parseCronSchedule(expression, timeZone string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	var values [5]uint64
+	for i, field := range fields {
+		v, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	location := time.UTC
+	if timeZone != "" {
+		l, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %v", timeZone, err)
+		}
+		location = l
+	}
+
+	dow := values[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return &cronSchedule{
+		minute:        values[0],
+		hour:          values[1],
+		dom:           values[2],
+		month:         values[3],
+		dow:           dow,
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+		location:      location,
+	}, nil
+}
+
+// parseCronField parses a comma separated list of "*", values and ranges, each with an optional step.
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, part)
+			}
+			rangePart, step = part[:i], s
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || start > end {
+				return 0, fmt.Errorf("invalid range in %s %q", f.name, part)
+			}
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s %q", f.name, part)
+			}
+			start, end = v, v
+			if step > 1 {
+				// "5/15" is every 15 from 5 on.
+				end = f.max
+			}
+		}
+		if start < f.min || end > f.max {
+			return 0, fmt.Errorf("%s %q is out of range %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// next returns the first start of the schedule after t, or the zero time when there is none within the search limit.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = s.advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location))
+		case !s.matchesDay(t):
+			t = s.advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location))
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = s.advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location))
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// advance returns to, unless a daylight saving time transition moved it before t.
+func (s *cronSchedule) advance(t, to time.Time) time.Time {
+	if !to.After(t) {
+		return t.Add(time.Minute)
+	}
+	return to
+}
+
+// validateMaintenanceWindows returns an error when a maintenance window can't be scheduled.
+func validateMaintenanceWindows(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	for i, window := range autoscalingRunnerSet.Spec.MaintenanceWindows {
+		if _, err := parseCronSchedule(window.Schedule, window.TimeZone); err != nil {
+			return fmt.Errorf("invalid schedule %q of maintenance window %d: %v", window.Schedule, i, err)
+		}
+		if window.Duration.Duration <= 0 {
+			return fmt.Errorf("invalid duration %q of maintenance window %d: must be positive", window.Duration.Duration, i)
+		}
+	}
+	return nil
+}
+
+// activeMaintenanceWindow returns the maintenance window the autoscaling runner set is in at now, and when it ends.
+// Outside of the windows, it returns nil and when the next window starts.
+// The windows are expected to be valid, see validateMaintenanceWindows.
+func activeMaintenanceWindow(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, now time.Time) (*v1alpha1.MaintenanceWindow, time.Time) {
+	var active *v1alpha1.MaintenanceWindow
+	var end, nextStart time.Time
+	for i := range autoscalingRunnerSet.Spec.MaintenanceWindows {
+		window := &autoscalingRunnerSet.Spec.MaintenanceWindows[i]
+		schedule, err := parseCronSchedule(window.Schedule, window.TimeZone)
+		if err != nil {
+			continue
+		}
+
+		// The window is active when the schedule started within its duration. The latest start ends last.
+		var start time.Time
+		for s := schedule.next(now.Add(-window.Duration.Duration)); !s.IsZero() && !s.After(now); s = schedule.next(s) {
+			start = s
+		}
+		if !start.IsZero() {
+			if windowEnd := start.Add(window.Duration.Duration); windowEnd.After(end) {
+				active, end = window, windowEnd
+			}
+			continue
+		}
+
+		if s := schedule.next(now); !s.IsZero() && (nextStart.IsZero() || s.Before(nextStart)) {
+			nextStart = s
+		}
+	}
+
+	if active != nil {
+		return active, end
+	}
+	return nil, nextStart
+}
+
+// reconcileMaintenanceWindow stops the listener of the autoscaling runner set during the maintenance window,
+// and scales the runner sets down to zero when the window drains the runners.
+func (r *AutoscalingRunnerSetReconciler) reconcileMaintenanceWindow(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, window *v1alpha1.MaintenanceWindow, end time.Time, runnerSets []*v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	if status := autoscalingRunnerSet.Status.MaintenanceWindowEnd; status == nil || !status.Time.Equal(end) {
+		log.Info("Entering the maintenance window", "schedule", window.Schedule, "end", end, "drainRunners", window.DrainRunners)
+		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.MaintenanceWindowEnd = &metav1.Time{Time: end}
+		}); err != nil {
+			return fmt.Errorf("failed to update the maintenance window end: %v", err)
+		}
+	}
+
+	if _, err := r.cleanupListener(ctx, autoscalingRunnerSet, log); err != nil {
+		return err
+	}
+
+	if !window.DrainRunners {
+		return nil
+	}
+	for _, runnerSet := range runnerSets {
+		if runnerSet.Spec.Replicas == 0 && runnerSet.Spec.WarmReplicas == 0 && runnerSet.Spec.ReservedReplicas == 0 {
+			continue
+		}
+		log.Info("Draining the runner set for the maintenance window", "name", runnerSet.Name)
+		if err := patch(ctx, r.Client, runnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.Replicas = 0
+			obj.Spec.WarmReplicas = 0
+			obj.Spec.ReservedReplicas = 0
+			setScaleTrigger(obj, v1alpha1.ScaleTriggerMaintenanceWindow)
+		}); err != nil {
+			return fmt.Errorf("failed to drain runner set %q: %v", runnerSet.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCronSchedule(t *testing.T) {
+	// A Monday.
+	now := time.Date(2023, 1, 2, 9, 7, 30, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"* * * * *":        time.Date(2023, 1, 2, 9, 8, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2023, 1, 2, 9, 15, 0, 0, time.UTC),
+		"0 2 * * 6":        time.Date(2023, 1, 7, 2, 0, 0, 0, time.UTC),
+		"0 2 * * 7":        time.Date(2023, 1, 8, 2, 0, 0, 0, time.UTC),
+		"30 1-3,22 1 * *":  time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC),
+		"0 0 15 * 1":       time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":       time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"5/20 9 * * 1-5":   time.Date(2023, 1, 2, 9, 25, 0, 0, time.UTC),
+		"0 0 * 12 0":       time.Date(2023, 12, 3, 0, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":       {},
+		"59 23 31 12 *":    time.Date(2023, 12, 31, 23, 59, 0, 0, time.UTC),
+		"0   9   2  1   *": time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+	}
+	for expression, want := range tests {
+		schedule, err := parseCronSchedule(expression, "")
+		require.NoError(t, err, expression)
+		assert.True(t, want.Equal(schedule.next(now)), "%s: want %s, got %s", expression, want, schedule.next(now))
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	schedule, err := parseCronSchedule("0 2 * * *", "Europe/Berlin")
+	require.NoError(t, err)
+	assert.True(t, time.Date(2023, 1, 3, 2, 0, 0, 0, berlin).Equal(schedule.next(now)))
+	assert.True(t, time.Date(2023, 3, 27, 2, 0, 0, 0, berlin).Equal(schedule.next(time.Date(2023, 3, 26, 0, 0, 0, 0, berlin))),
+		"A start skipped by the daylight saving time transition is missed")
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "MON * * * *"} {
+		_, err := parseCronSchedule(expression, "")
+		assert.Error(t, err, expression)
+	}
+	_, err = parseCronSchedule("* * * * *", "Not/AZone")
+	assert.Error(t, err)
+}
+
+func TestActiveMaintenanceWindow(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			MaintenanceWindows: []v1alpha1.MaintenanceWindow{
+				{Schedule: "0 2 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+				{Schedule: "0 5 * * 6", Duration: metav1.Duration{Duration: 2 * time.Hour}, DrainRunners: true},
+			},
+		},
+	}
+	saturday := time.Date(2023, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	window, next := activeMaintenanceWindow(autoscalingRunnerSet, saturday.Add(time.Hour))
+	assert.Nil(t, window)
+	assert.Equal(t, saturday.Add(2*time.Hour), next)
+
+	window, end := activeMaintenanceWindow(autoscalingRunnerSet, saturday.Add(2*time.Hour))
+	require.NotNil(t, window)
+	assert.False(t, window.DrainRunners)
+	assert.Equal(t, saturday.Add(6*time.Hour), end)
+
+	window, end = activeMaintenanceWindow(autoscalingRunnerSet, saturday.Add(5*time.Hour+30*time.Minute))
+	require.NotNil(t, window)
+	assert.True(t, window.DrainRunners, "The overlapping window ending last is active")
+	assert.Equal(t, saturday.Add(7*time.Hour), end)
+
+	window, next = activeMaintenanceWindow(autoscalingRunnerSet, saturday.Add(7*time.Hour))
+	assert.Nil(t, window)
+	assert.Equal(t, saturday.Add(7*24*time.Hour+2*time.Hour), next)
+
+	assert.NoError(t, validateMaintenanceWindows(autoscalingRunnerSet))
+	autoscalingRunnerSet.Spec.MaintenanceWindows[1].Duration.Duration = 0
+	assert.Error(t, validateMaintenanceWindows(autoscalingRunnerSet))
+}
+
+func TestReconcileMaintenanceWindow(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	window := v1alpha1.MaintenanceWindow{Schedule: "0 2 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour}, DrainRunners: true}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default", UID: "arc"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			MaintenanceWindows: []v1alpha1.MaintenanceWindow{window},
+		},
+	}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc-abcde", Namespace: "default"},
+		Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 5, WarmReplicas: 2, ReservedReplicas: 3},
+	}
+	listener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{Name: scaleSetListenerName(autoscalingRunnerSet), Namespace: "arc-systems"},
+	}
+
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, runnerSet, listener).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, ControllerNamespace: "arc-systems"}
+
+	end := time.Date(2023, 1, 7, 6, 0, 0, 0, time.UTC)
+	require.NoError(t, r.reconcileMaintenanceWindow(ctx, autoscalingRunnerSet, &window, end, []*v1alpha1.EphemeralRunnerSet{runnerSet}, logr.Discard()))
+
+	err := c.Get(ctx, types.NamespacedName{Namespace: "arc-systems", Name: listener.Name}, listener)
+	assert.True(t, kerrors.IsNotFound(err), "The listener is stopped during the window")
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: runnerSet.Name}, runnerSet))
+	assert.Zero(t, runnerSet.Spec.Replicas)
+	assert.Zero(t, runnerSet.Spec.WarmReplicas)
+	assert.Zero(t, runnerSet.Spec.ReservedReplicas)
+	assert.Equal(t, v1alpha1.ScaleTriggerMaintenanceWindow, scaleTriggerOf(runnerSet))
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	require.NotNil(t, autoscalingRunnerSet.Status.MaintenanceWindowEnd)
+	assert.True(t, end.Equal(autoscalingRunnerSet.Status.MaintenanceWindowEnd.Time))
+}
@@ -0,0 +1,56 @@
+package actionsgithubcom
+
+import (
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		metricRunnerSeconds,
+		metricRunnerJobs,
+	)
+}
+
+var (
+	metricRunnerSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_runner_job_runner_seconds_total",
+			Help: "The number of seconds ephemeral runner containers ran for jobs of the organization and repository",
+		},
+		[]string{"namespace", "organization", "repository"},
+	)
+	metricRunnerJobs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_runner_jobs_total",
+			Help: "The number of jobs of the organization and repository ephemeral runners ran",
+		},
+		[]string{"namespace", "organization", "repository"},
+	)
+)
+
+// recordRunnerUsage attributes the run time of the terminated runner container to the repository
+// of the job the runner was assigned. Runners that never got a job are not attributed.
+func recordRunnerUsage(ephemeralRunner *v1alpha1.EphemeralRunner, cs *corev1.ContainerStatus) {
+	if ephemeralRunner.Status.JobRepositoryName == "" || cs == nil || cs.State.Terminated == nil {
+		return
+	}
+
+	terminated := cs.State.Terminated
+	if terminated.StartedAt.IsZero() || terminated.FinishedAt.Before(&terminated.StartedAt) {
+		return
+	}
+
+	organization, repository, _ := strings.Cut(ephemeralRunner.Status.JobRepositoryName, "/")
+	labels := prometheus.Labels{
+		"namespace":    ephemeralRunner.Namespace,
+		"organization": organization,
+		"repository":   repository,
+	}
+	metricRunnerSeconds.With(labels).Add(terminated.FinishedAt.Sub(terminated.StartedAt.Time).Seconds())
+	metricRunnerJobs.With(labels).Inc()
+}
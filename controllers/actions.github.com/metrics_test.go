@@ -0,0 +1,42 @@
+package actionsgithubcom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordRunnerUsage(t *testing.T) {
+	started := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	terminated := &corev1.ContainerStatus{
+		State: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{
+				StartedAt:  metav1.NewTime(started),
+				FinishedAt: metav1.NewTime(started.Add(90 * time.Second)),
+			},
+		},
+	}
+	newRunner := func(repository string) *v1alpha1.EphemeralRunner {
+		return &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "usage-test"},
+			Status:     v1alpha1.EphemeralRunnerStatus{JobRepositoryName: repository},
+		}
+	}
+
+	recordRunnerUsage(newRunner("octo-org/octo-repo"), terminated)
+	recordRunnerUsage(newRunner("octo-org/octo-repo"), terminated)
+	// Runners without a job and containers still running are not attributed.
+	recordRunnerUsage(newRunner(""), terminated)
+	recordRunnerUsage(newRunner("octo-org/octo-repo"), &corev1.ContainerStatus{})
+
+	seconds := metricRunnerSeconds.WithLabelValues("usage-test", "octo-org", "octo-repo")
+	assert.Equal(t, 180.0, testutil.ToFloat64(seconds))
+	jobs := metricRunnerJobs.WithLabelValues("usage-test", "octo-org", "octo-repo")
+	assert.Equal(t, 2.0, testutil.ToFloat64(jobs))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metricRunnerJobs.WithLabelValues("usage-test", "", "")))
+}
@@ -0,0 +1,119 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Values of the --dind-native-sidecar flag of the controller.
+const (
+	NativeSidecarAuto     = "auto"
+	NativeSidecarEnabled  = "enabled"
+	NativeSidecarDisabled = "disabled"
+)
+
+// nativeSidecarMinMinorVersion is the first minor version of Kubernetes 1 enabling sidecar containers by default.
+const nativeSidecarMinMinorVersion = 29
+
+// NativeSidecarsSupported reports whether the Kubernetes server of the version runs init containers
+// with restartPolicy Always as sidecars.
+func NativeSidecarsSupported(info *version.Info) bool {
+	if info == nil {
+		return false
+	}
+	major, err := strconv.Atoi(strings.TrimSuffix(info.Major, "+"))
+	if err != nil {
+		return false
+	}
+	// Minor versions of managed clusters come with a suffix, like "29+".
+	minor, err := strconv.Atoi(strings.TrimSuffix(info.Minor, "+"))
+	if err != nil {
+		return false
+	}
+	return major > 1 || (major == 1 && minor >= nativeSidecarMinMinorVersion)
+}
+
+// ResolveNativeSidecar returns whether the dind containers run as native sidecars for the value of
+// the --dind-native-sidecar flag, asking the version of the Kubernetes server in auto mode.
+func ResolveNativeSidecar(mode string, serverVersion func() (*version.Info, error)) (bool, error) {
+	switch mode {
+	case NativeSidecarEnabled:
+		return true, nil
+	case NativeSidecarDisabled:
+		return false, nil
+	case NativeSidecarAuto, "":
+		info, err := serverVersion()
+		if err != nil {
+			return false, fmt.Errorf("failed to get the version of the Kubernetes server: %v", err)
+		}
+		return NativeSidecarsSupported(info), nil
+	default:
+		return false, fmt.Errorf("invalid dind native sidecar mode %q: must be one of %q, %q and %q", mode, NativeSidecarAuto, NativeSidecarEnabled, NativeSidecarDisabled)
+	}
+}
+
+// nativeSidecarPod returns the pod with its dind container moved to the end of its init containers
+// with restartPolicy Always, so that it starts before the runner and is stopped once the runner exits,
+// completing the pod. The pod is returned as unstructured since the restartPolicy of containers is
+// unknown to the pod types of the controller. It returns nil when the pod has no dind container.
+func nativeSidecarPod(pod *corev1.Pod) (*unstructured.Unstructured, error) {
+	index := -1
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == dindContainerName {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, nil
+	}
+
+	spec := pod.Spec.DeepCopy()
+	dind := spec.Containers[index]
+	spec.Containers = append(spec.Containers[:index], spec.Containers[index+1:]...)
+	spec.InitContainers = append(spec.InitContainers, dind)
+
+	obj := pod.DeepCopy()
+	obj.Spec = *spec
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the pod to unstructured: %v", err)
+	}
+
+	initContainers, _, err := unstructured.NestedSlice(content, "spec", "initContainers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the init containers of the pod: %v", err)
+	}
+	sidecar := initContainers[len(initContainers)-1].(map[string]interface{})
+	sidecar["restartPolicy"] = string(corev1.RestartPolicyAlways)
+	if err := unstructured.SetNestedSlice(content, initContainers, "spec", "initContainers"); err != nil {
+		return nil, fmt.Errorf("failed to set the init containers of the pod: %v", err)
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+	return u, nil
+}
+
+// createRunnerPod creates the runner pod, with its dind container as a native sidecar when the
+// cluster supports it.
+func (r *EphemeralRunnerReconciler) createRunnerPod(ctx context.Context, pod *corev1.Pod) error {
+	if !r.NativeSidecars {
+		return r.Create(ctx, pod)
+	}
+	sidecarPod, err := nativeSidecarPod(pod)
+	if err != nil {
+		return err
+	}
+	if sidecarPod == nil {
+		return r.Create(ctx, pod)
+	}
+	return r.Create(ctx, sidecarPod)
+}
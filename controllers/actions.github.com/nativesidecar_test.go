@@ -0,0 +1,130 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNativeSidecarsSupported(t *testing.T) {
+	tests := map[string]struct {
+		info *version.Info
+		want bool
+	}{
+		"1.28":         {info: &version.Info{Major: "1", Minor: "28"}, want: false},
+		"1.29":         {info: &version.Info{Major: "1", Minor: "29"}, want: true},
+		"managed 1.30": {info: &version.Info{Major: "1", Minor: "30+"}, want: true},
+		"unparsable":   {info: &version.Info{Major: "1", Minor: "latest"}, want: false},
+		"unknown":      {info: nil, want: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NativeSidecarsSupported(tc.info))
+		})
+	}
+}
+
+func TestResolveNativeSidecar(t *testing.T) {
+	serverVersion := func() (*version.Info, error) {
+		return &version.Info{Major: "1", Minor: "29"}, nil
+	}
+
+	enabled, err := ResolveNativeSidecar(NativeSidecarAuto, serverVersion)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	enabled, err = ResolveNativeSidecar(NativeSidecarDisabled, serverVersion)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = ResolveNativeSidecar(NativeSidecarAuto, func() (*version.Info, error) {
+		return nil, errors.New("unreachable")
+	})
+	assert.Error(t, err)
+
+	_, err = ResolveNativeSidecar("always", serverVersion)
+	assert.Error(t, err)
+}
+
+func TestNativeSidecarPod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			RestartPolicy:  corev1.RestartPolicyNever,
+			InitContainers: []corev1.Container{{Name: "init-dind-externals", Image: "runner"}},
+			Containers: []corev1.Container{
+				{Name: EphemeralRunnerContainerName, Image: "runner"},
+				{Name: dindContainerName, Image: "docker:dind"},
+			},
+		},
+	}
+
+	sidecarPod, err := nativeSidecarPod(pod)
+	require.NoError(t, err)
+	require.NotNil(t, sidecarPod)
+	assert.Equal(t, "Pod", sidecarPod.GetKind())
+
+	initContainers, _, err := unstructured.NestedSlice(sidecarPod.Object, "spec", "initContainers")
+	require.NoError(t, err)
+	require.Len(t, initContainers, 2)
+	sidecar := initContainers[1].(map[string]interface{})
+	assert.Equal(t, dindContainerName, sidecar["name"], "The dind container starts after the other init containers")
+	assert.Equal(t, "Always", sidecar["restartPolicy"])
+	assert.NotContains(t, initContainers[0].(map[string]interface{}), "restartPolicy")
+
+	containers, _, err := unstructured.NestedSlice(sidecarPod.Object, "spec", "containers")
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, EphemeralRunnerContainerName, containers[0].(map[string]interface{})["name"])
+	assert.Len(t, pod.Spec.Containers, 2, "The pod is left as it is")
+
+	pod.Spec.Containers = pod.Spec.Containers[:1]
+	sidecarPod, err = nativeSidecarPod(pod)
+	require.NoError(t, err)
+	assert.Nil(t, sidecarPod, "Pods without a dind container are created as they are")
+}
+
+func TestCreateRunnerPod_NativeSidecars(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: EphemeralRunnerContainerName, Image: "runner"},
+					{Name: dindContainerName, Image: "docker:dind"},
+				},
+			},
+		}
+	}
+
+	for _, nativeSidecars := range []bool{false, true} {
+		c := crfake.NewClientBuilder().WithScheme(scheme).Build()
+		r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme, NativeSidecars: nativeSidecars}
+		require.NoError(t, r.createRunnerPod(context.Background(), newPod()))
+
+		created := new(corev1.Pod)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "runner"}, created))
+		if nativeSidecars {
+			require.Len(t, created.Spec.InitContainers, 1)
+			assert.Equal(t, dindContainerName, created.Spec.InitContainers[0].Name)
+			assert.Len(t, created.Spec.Containers, 1)
+		} else {
+			assert.Empty(t, created.Spec.InitContainers)
+			assert.Len(t, created.Spec.Containers, 2)
+		}
+	}
+}
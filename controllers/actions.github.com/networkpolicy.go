@@ -0,0 +1,409 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultRunnerNetworkPolicyRefreshInterval is how often the addresses of the GitHub server and of the proxies
+// allowed by the NetworkPolicy of the runner pods are resolved again.
+const DefaultRunnerNetworkPolicyRefreshInterval = time.Hour
+
+// Reasons of the events of the NetworkPolicy of the runner pods.
+const reasonRunnerNetworkPolicyResolutionFailed = "RunnerNetworkPolicyResolutionFailed"
+
+// githubMetaEgressKeys are the services of the meta API of GitHub.com the runners reach.
+var githubMetaEgressKeys = []string{"web", "api", "git", "packages"}
+
+// hostedActionsHosts are the hosts of the Actions service of GitHub.com the runners reach, which are
+// not listed by the meta API.
+var hostedActionsHosts = []string{
+	"pipelines.actions.githubusercontent.com",
+	"results-receiver.actions.githubusercontent.com",
+	"broker.actions.githubusercontent.com",
+	"objects.githubusercontent.com",
+}
+
+// EgressResolver resolves the addresses the runner pods of a scale set reach GitHub at, as CIDRs.
+type EgressResolver interface {
+	// GitHubCIDRs returns the CIDRs of the GitHub server of the config URL.
+	GitHubCIDRs(ctx context.Context, config *actions.GitHubConfig) ([]string, error)
+	// HostCIDRs returns the CIDRs of the addresses of the host, e.g. of a proxy.
+	HostCIDRs(ctx context.Context, host string) ([]string, error)
+}
+
+// netEgressResolver resolves the CIDRs of GitHub.com with its meta API, and the others with DNS.
+type netEgressResolver struct {
+	httpClient *http.Client
+	resolver   *net.Resolver
+}
+
+func (r *netEgressResolver) GitHubCIDRs(ctx context.Context, config *actions.GitHubConfig) ([]string, error) {
+	if !config.IsHosted {
+		return r.HostCIDRs(ctx, config.ConfigURL.Hostname())
+	}
+
+	cidrs, err := r.githubMetaCIDRs(ctx, config.GitHubAPIURL("/meta").String())
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hostedActionsHosts {
+		hostCIDRs, err := r.HostCIDRs(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, hostCIDRs...)
+	}
+	return cidrs, nil
+}
+
+// githubMetaCIDRs returns the ranges of the services of the meta API the runners reach.
+func (r *netEgressResolver) githubMetaCIDRs(ctx context.Context, metaURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	client := r.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the meta of GitHub: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the meta of GitHub: unexpected status %s", resp.Status)
+	}
+
+	var meta map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode the meta of GitHub: %v", err)
+	}
+
+	var cidrs []string
+	for _, key := range githubMetaEgressKeys {
+		var ranges []string
+		if raw, ok := meta[key]; ok {
+			if err := json.Unmarshal(raw, &ranges); err != nil {
+				return nil, fmt.Errorf("failed to decode the %s ranges of the meta of GitHub: %v", key, err)
+			}
+		}
+		cidrs = append(cidrs, ranges...)
+	}
+	return cidrs, nil
+}
+
+func (r *netEgressResolver) HostCIDRs(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ipCIDR(ip)}, nil
+	}
+
+	resolver := r.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	cidrs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		cidrs = append(cidrs, ipCIDR(addr.IP))
+	}
+	return cidrs, nil
+}
+
+// ipCIDR returns the CIDR of the single address.
+func ipCIDR(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// runnerNetworkPolicyEnabled reports whether the controller generates the NetworkPolicy of the runner pods.
+func runnerNetworkPolicyEnabled(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) bool {
+	policy := autoscalingRunnerSet.Spec.NetworkPolicy
+	return policy != nil && policy.Enabled
+}
+
+func runnerNetworkPolicyName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return autoscalingRunnerSet.Name + "-runners"
+}
+
+// applyRunnerNetworkPolicy labels the runner pods of the template for the NetworkPolicy of the autoscaling runner set.
+func applyRunnerNetworkPolicy(template *corev1.PodTemplateSpec, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	if !runnerNetworkPolicyEnabled(autoscalingRunnerSet) {
+		return
+	}
+	labels := make(map[string]string, len(template.Labels)+1)
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels[LabelKeyRunnerNetworkPolicy] = runnerNetworkPolicyName(autoscalingRunnerSet)
+	template.Labels = labels
+}
+
+func validateRunnerNetworkPolicy(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if !runnerNetworkPolicyEnabled(autoscalingRunnerSet) {
+		return nil
+	}
+	for _, cidr := range autoscalingRunnerSet.Spec.NetworkPolicy.ExtraEgressCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid extra egress CIDR %q: %v", cidr, err)
+		}
+	}
+	return nil
+}
+
+// runnerNetworkPolicyPort returns the TCP port of the URL, defaulting to the port of its scheme.
+func runnerNetworkPolicyPort(u *url.URL) (int32, error) {
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "http" {
+			return 80, nil
+		}
+		return 443, nil
+	}
+	p, err := strconv.ParseInt(port, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port of %s: %v", u.Redacted(), err)
+	}
+	return int32(p), nil
+}
+
+// resolveRunnerEgress returns the egress rules of the GitHub server and of the proxies of the autoscaling runner set.
+func (r *AutoscalingRunnerSetReconciler) resolveRunnerEgress(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	resolver := r.EgressResolver
+	if resolver == nil {
+		resolver = &netEgressResolver{}
+	}
+
+	config, err := actions.ParseGitHubConfigFromURL(autoscalingRunnerSet.Spec.GitHubConfigUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the GitHub config URL: %v", err)
+	}
+	port, err := runnerNetworkPolicyPort(config.ConfigURL)
+	if err != nil {
+		return nil, err
+	}
+	cidrs, err := resolver.GitHubCIDRs(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	rules := []networkingv1.NetworkPolicyEgressRule{egressRule(cidrs, port)}
+
+	if proxy := autoscalingRunnerSet.Spec.Proxy; proxy != nil {
+		for _, server := range []*v1alpha1.ProxyServerConfig{proxy.HTTP, proxy.HTTPS} {
+			if server == nil || server.Url == "" {
+				continue
+			}
+			u, err := url.Parse(server.Url)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the proxy URL: %v", err)
+			}
+			port, err := runnerNetworkPolicyPort(u)
+			if err != nil {
+				return nil, err
+			}
+			cidrs, err := resolver.HostCIDRs(ctx, u.Hostname())
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, egressRule(cidrs, port))
+		}
+	}
+	return rules, nil
+}
+
+// egressRule allows the CIDRs on the TCP port, or on every port when the port is zero.
+func egressRule(cidrs []string, port int32) networkingv1.NetworkPolicyEgressRule {
+	unique := make(map[string]struct{}, len(cidrs))
+	sorted := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if _, ok := unique[cidr]; ok {
+			continue
+		}
+		unique[cidr] = struct{}{}
+		sorted = append(sorted, cidr)
+	}
+	sort.Strings(sorted)
+
+	rule := networkingv1.NetworkPolicyEgressRule{}
+	for _, cidr := range sorted {
+		rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if port != 0 {
+		tcp := corev1.ProtocolTCP
+		p := intstr.FromInt(int(port))
+		rule.Ports = []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &p}}
+	}
+	return rule
+}
+
+func (b *resourceBuilder) newRunnerNetworkPolicy(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, egress []networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	name := runnerNetworkPolicyName(autoscalingRunnerSet)
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dns := intstr.FromInt(53)
+
+	// DNS is allowed to any destination, as clusters serve it from pods, node-local caches or the nodes.
+	rules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dns},
+				{Protocol: &tcp, Port: &dns},
+			},
+		},
+	}
+	rules = append(rules, egress...)
+	if extra := autoscalingRunnerSet.Spec.NetworkPolicy.ExtraEgressCIDRs; len(extra) > 0 {
+		rules = append(rules, egressRule(extra, 0))
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+				"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					LabelKeyRunnerNetworkPolicy: name,
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      rules,
+		},
+	}
+}
+
+// runnerEgressResolution is the last resolution of the egress of the runner pods of an AutoscalingRunnerSet.
+type runnerEgressResolution struct {
+	githubConfigUrl string
+	proxy           *v1alpha1.ProxyConfig
+	egress          []networkingv1.NetworkPolicyEgressRule
+	time            time.Time
+}
+
+// runnerEgress returns the egress rules of the GitHub server and of the proxies of the autoscaling runner set,
+// resolved again once the refresh interval elapses, and immediately when the GitHub config URL or the proxies change.
+func (r *AutoscalingRunnerSetReconciler) runnerEgress(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	key := types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: autoscalingRunnerSet.Name}
+
+	r.lastRunnerEgressResolutionMu.Lock()
+	last, ok := r.lastRunnerEgressResolution[key]
+	r.lastRunnerEgressResolutionMu.Unlock()
+
+	now := time.Now()
+	if ok && last.githubConfigUrl == autoscalingRunnerSet.Spec.GitHubConfigUrl && reflect.DeepEqual(last.proxy, autoscalingRunnerSet.Spec.Proxy) && now.Sub(last.time) < DefaultRunnerNetworkPolicyRefreshInterval {
+		return last.egress, nil
+	}
+
+	egress, err := r.resolveRunnerEgress(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lastRunnerEgressResolutionMu.Lock()
+	defer r.lastRunnerEgressResolutionMu.Unlock()
+	if r.lastRunnerEgressResolution == nil {
+		r.lastRunnerEgressResolution = make(map[types.NamespacedName]runnerEgressResolution)
+	}
+	r.lastRunnerEgressResolution[key] = runnerEgressResolution{
+		githubConfigUrl: autoscalingRunnerSet.Spec.GitHubConfigUrl,
+		proxy:           autoscalingRunnerSet.Spec.Proxy.DeepCopy(),
+		egress:          egress,
+		time:            now,
+	}
+	return egress, nil
+}
+
+func (r *AutoscalingRunnerSetReconciler) forgetRunnerEgressResolution(key types.NamespacedName) {
+	r.lastRunnerEgressResolutionMu.Lock()
+	defer r.lastRunnerEgressResolutionMu.Unlock()
+
+	delete(r.lastRunnerEgressResolution, key)
+}
+
+// reconcileRunnerNetworkPolicy keeps the NetworkPolicy of the runner pods of the autoscaling runner set in sync with
+// the addresses of its GitHub server, proxies and extra CIDRs, and deletes it once disabled.
+// Resolution failures are reported with an event, and the NetworkPolicy keeps the previous addresses.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerNetworkPolicy(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	key := types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: runnerNetworkPolicyName(autoscalingRunnerSet)}
+
+	current := new(networkingv1.NetworkPolicy)
+	if err := r.Get(ctx, key, current); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get runner network policy: %v", err)
+		}
+		current = nil
+	}
+
+	if !runnerNetworkPolicyEnabled(autoscalingRunnerSet) {
+		r.forgetRunnerEgressResolution(types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: autoscalingRunnerSet.Name})
+		if current == nil || !metav1.IsControlledBy(current, autoscalingRunnerSet) {
+			return nil
+		}
+		log.Info("Runner network policy is disabled. Deleting it", "name", key.Name)
+		if err := r.Delete(ctx, current); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete runner network policy: %v", err)
+		}
+		return nil
+	}
+
+	egress, err := r.runnerEgress(ctx, autoscalingRunnerSet)
+	if err != nil {
+		log.Error(err, "Failed to resolve the egress of the runner network policy")
+		r.Recorder.Eventf(autoscalingRunnerSet, corev1.EventTypeWarning, reasonRunnerNetworkPolicyResolutionFailed, "Failed to resolve the egress of the runner network policy: %v", err)
+		return nil
+	}
+	desired := r.resourceBuilder.newRunnerNetworkPolicy(autoscalingRunnerSet, egress)
+
+	if current == nil {
+		if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set controller reference: %v", err)
+		}
+		log.Info("Creating runner network policy", "name", key.Name)
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create runner network policy: %v", err)
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(current, autoscalingRunnerSet) || reflect.DeepEqual(current.Spec, desired.Spec) {
+		return nil
+	}
+	log.Info("Updating runner network policy", "name", key.Name)
+	if err := patch(ctx, r.Client, current, func(obj *networkingv1.NetworkPolicy) {
+		obj.Spec = desired.Spec
+	}); err != nil {
+		return fmt.Errorf("failed to update runner network policy: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeEgressResolver struct {
+	github map[string][]string
+	hosts  map[string][]string
+	err    error
+}
+
+func (f *fakeEgressResolver) GitHubCIDRs(ctx context.Context, config *actions.GitHubConfig) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.github[config.ConfigURL.Host], nil
+}
+
+func (f *fakeEgressResolver) HostCIDRs(ctx context.Context, host string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.hosts[host], nil
+}
+
+func egressCIDRs(rule networkingv1.NetworkPolicyEgressRule) []string {
+	var cidrs []string
+	for _, peer := range rule.To {
+		cidrs = append(cidrs, peer.IPBlock.CIDR)
+	}
+	return cidrs
+}
+
+func TestReconcileRunnerNetworkPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners", UID: "1234"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl: "https://ghes.example.com:8443/org",
+			Proxy: &v1alpha1.ProxyConfig{
+				HTTPS: &v1alpha1.ProxyServerConfig{Url: "http://proxy.example.com:3128"},
+			},
+			NetworkPolicy: &v1alpha1.RunnerNetworkPolicy{
+				Enabled:          true,
+				ExtraEgressCIDRs: []string{"10.0.0.0/8"},
+			},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	resolver := &fakeEgressResolver{
+		github: map[string][]string{"ghes.example.com:8443": {"192.0.2.2/32", "192.0.2.1/32", "192.0.2.1/32"}},
+		hosts:  map[string][]string{"proxy.example.com": {"198.51.100.1/32"}},
+	}
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, EgressResolver: resolver, Recorder: record.NewFakeRecorder(10)}
+
+	require.NoError(t, r.reconcileRunnerNetworkPolicy(ctx, autoscalingRunnerSet, logr.Discard()))
+
+	key := types.NamespacedName{Namespace: "runners", Name: "arc-runners"}
+	policy := new(networkingv1.NetworkPolicy)
+	require.NoError(t, c.Get(ctx, key, policy))
+	assert.True(t, metav1.IsControlledBy(policy, autoscalingRunnerSet))
+	assert.Equal(t, map[string]string{LabelKeyRunnerNetworkPolicy: "arc-runners"}, policy.Spec.PodSelector.MatchLabels)
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}, policy.Spec.PolicyTypes)
+
+	require.Len(t, policy.Spec.Egress, 4)
+	dns := policy.Spec.Egress[0]
+	assert.Empty(t, dns.To, "DNS is allowed to any destination")
+	require.Len(t, dns.Ports, 2)
+	assert.Equal(t, 53, dns.Ports[0].Port.IntValue())
+
+	github := policy.Spec.Egress[1]
+	assert.Equal(t, []string{"192.0.2.1/32", "192.0.2.2/32"}, egressCIDRs(github))
+	require.Len(t, github.Ports, 1)
+	assert.Equal(t, 8443, github.Ports[0].Port.IntValue())
+
+	proxy := policy.Spec.Egress[2]
+	assert.Equal(t, []string{"198.51.100.1/32"}, egressCIDRs(proxy))
+	require.Len(t, proxy.Ports, 1)
+	assert.Equal(t, 3128, proxy.Ports[0].Port.IntValue())
+
+	extra := policy.Spec.Egress[3]
+	assert.Equal(t, []string{"10.0.0.0/8"}, egressCIDRs(extra))
+	assert.Empty(t, extra.Ports, "Extra CIDRs are allowed on every port")
+
+	// The addresses are resolved again once the GitHub config URL changes.
+	resolver.github["ghes2.example.com"] = []string{"192.0.2.3/32"}
+	autoscalingRunnerSet.Spec.GitHubConfigUrl = "https://ghes2.example.com/org"
+	require.NoError(t, r.reconcileRunnerNetworkPolicy(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, key, policy))
+	assert.Equal(t, []string{"192.0.2.3/32"}, egressCIDRs(policy.Spec.Egress[1]))
+	assert.Equal(t, 443, policy.Spec.Egress[1].Ports[0].Port.IntValue())
+
+	// Resolution failures keep the previous addresses.
+	autoscalingRunnerSet.Spec.GitHubConfigUrl = "https://ghes3.example.com/org"
+	resolver.err = errors.New("no such host")
+	require.NoError(t, r.reconcileRunnerNetworkPolicy(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, key, policy))
+	assert.Equal(t, []string{"192.0.2.3/32"}, egressCIDRs(policy.Spec.Egress[1]))
+
+	// The network policy is deleted once disabled.
+	autoscalingRunnerSet.Spec.NetworkPolicy.Enabled = false
+	require.NoError(t, r.reconcileRunnerNetworkPolicy(ctx, autoscalingRunnerSet, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, new(networkingv1.NetworkPolicy))))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNetEgressResolver_GitHubCIDRs(t *testing.T) {
+	var requested string
+	resolver := &netEgressResolver{
+		httpClient: &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requested = req.URL.String()
+			body := `{"verifiable_password_authentication":false,"web":["192.30.252.0/22"],"api":["192.30.252.0/22","2a0a:a440::/29"],"git":["140.82.112.0/20"],"packages":["140.82.121.33/32"],"hooks":["192.0.2.0/24"]}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+		})},
+	}
+
+	// Only the hosts resolved with DNS are checked for GitHub Enterprise Server, without the meta API.
+	config, err := actions.ParseGitHubConfigFromURL("https://192.0.2.10/org")
+	require.NoError(t, err)
+	cidrs, err := resolver.GitHubCIDRs(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.10/32"}, cidrs)
+	assert.Empty(t, requested)
+
+	cidrs, err = resolver.HostCIDRs(context.Background(), "2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2001:db8::1/128"}, cidrs)
+
+	// GitHub.com is resolved with the meta API, leaving out the services runners don't reach.
+	meta, err := resolver.githubMetaCIDRs(context.Background(), "https://api.github.com/meta")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.github.com/meta", requested)
+	assert.Equal(t, []string{"192.30.252.0/22", "192.30.252.0/22", "2a0a:a440::/29", "140.82.112.0/20", "140.82.121.33/32"}, meta)
+}
+
+func TestNewEphemeralRunnerSet_RunnerNetworkPolicy(t *testing.T) {
+	b := resourceBuilder{}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{Name: "gpu", Labels: []string{"gpu"}, Template: newTestEphemeralRunner().Spec.PodTemplateSpec},
+			},
+		},
+	}
+	withoutPolicy := autoscalingRunnerSet.RunnerSetSpecHash()
+
+	autoscalingRunnerSet.Spec.NetworkPolicy = &v1alpha1.RunnerNetworkPolicy{Enabled: true}
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, "arc-runners", runnerSet.Spec.EphemeralRunnerSpec.Labels[LabelKeyRunnerNetworkPolicy])
+	require.Len(t, runnerSet.Spec.TemplateVariants, 1)
+	assert.Equal(t, "arc-runners", runnerSet.Spec.TemplateVariants[0].Template.Labels[LabelKeyRunnerNetworkPolicy])
+	assert.NotContains(t, autoscalingRunnerSet.Spec.Template.Labels, LabelKeyRunnerNetworkPolicy, "The template of the autoscaling runner set is left as it is")
+	assert.NotEqual(t, withoutPolicy, autoscalingRunnerSet.RunnerSetSpecHash(), "Enabling the network policy rolls out the runners to label them")
+
+	// The extra CIDRs don't roll out the runners.
+	withPolicy := autoscalingRunnerSet.RunnerSetSpecHash()
+	autoscalingRunnerSet.Spec.NetworkPolicy.ExtraEgressCIDRs = []string{"10.0.0.0/8"}
+	assert.Equal(t, withPolicy, autoscalingRunnerSet.RunnerSetSpecHash())
+}
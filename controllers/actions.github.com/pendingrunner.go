@@ -0,0 +1,77 @@
+package actionsgithubcom
+
+import (
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pendingTimeoutRemaining returns how long the pod may still stay Pending before it times out.
+// It returns false when the pod isn't Pending or the failure policy of the runner has no pending timeout.
+func pendingTimeoutRemaining(ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, now time.Time) (time.Duration, bool) {
+	policy := ephemeralRunner.Spec.FailurePolicy
+	if policy == nil || policy.PendingTimeout == nil || policy.PendingTimeout.Duration <= 0 {
+		return 0, false
+	}
+	if pod.Status.Phase != corev1.PodPending || !pod.ObjectMeta.DeletionTimestamp.IsZero() {
+		return 0, false
+	}
+	return pod.CreationTimestamp.Add(policy.PendingTimeout.Duration).Sub(now), true
+}
+
+// podPendingReason returns why the pod is Pending: the reason of the scheduler when the pod can't be
+// scheduled, or the reason a container is waiting for.
+func podPendingReason(pod *corev1.Pod) (reason, message string) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			return condition.Reason, condition.Message
+		}
+	}
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			if waiting := statuses[i].State.Waiting; waiting != nil && waiting.Reason != "" {
+				return waiting.Reason, waiting.Message
+			}
+		}
+	}
+	return pod.Status.Reason, pod.Status.Message
+}
+
+// relaxedScheduling reports whether the pod of the runner replaces one that timed out pending, and should be
+// created with the scheduling constraints relaxed.
+func relaxedScheduling(ephemeralRunner *v1alpha1.EphemeralRunner) bool {
+	policy := ephemeralRunner.Spec.FailurePolicy
+	return policy != nil && policy.RelaxSchedulingOnPendingTimeout &&
+		ephemeralRunner.Status.FailureReason == v1alpha1.EphemeralRunnerFailureReasonPendingTimeout
+}
+
+// relaxPodScheduling drops the scheduling constraints of the pod that a healthy node pool would satisfy,
+// but that may keep the pod from being scheduled while a node pool is unavailable: preferred affinities,
+// pod affinities and anti-affinities, and topology spread constraints that don't allow skew.
+// The node selector, required node affinity and tolerations are kept so the pod lands on a node it can run on.
+func relaxPodScheduling(spec *corev1.PodSpec) {
+	if spec.Affinity != nil {
+		affinity := &corev1.Affinity{}
+		if nodeAffinity := spec.Affinity.NodeAffinity; nodeAffinity != nil && nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+			affinity.NodeAffinity = &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.DeepCopy(),
+			}
+		}
+		spec.Affinity = nil
+		if affinity.NodeAffinity != nil {
+			spec.Affinity = affinity
+		}
+	}
+
+	if len(spec.TopologySpreadConstraints) > 0 {
+		constraints := make([]corev1.TopologySpreadConstraint, len(spec.TopologySpreadConstraints))
+		for i := range spec.TopologySpreadConstraints {
+			spec.TopologySpreadConstraints[i].DeepCopyInto(&constraints[i])
+			constraints[i].WhenUnsatisfiable = corev1.ScheduleAnyway
+			// MinDomains is only allowed with DoNotSchedule.
+			constraints[i].MinDomains = nil
+		}
+		spec.TopologySpreadConstraints = constraints
+	}
+}
@@ -0,0 +1,135 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPendingTimeoutRemaining(t *testing.T) {
+	created := time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC)
+	ephemeralRunner := &v1alpha1.EphemeralRunner{}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+
+	_, ok := pendingTimeoutRemaining(ephemeralRunner, pod, created)
+	assert.False(t, ok, "No timeout without a failure policy")
+
+	ephemeralRunner.Spec.FailurePolicy = &v1alpha1.FailurePolicy{PendingTimeout: &metav1.Duration{Duration: 10 * time.Minute}}
+	remaining, ok := pendingTimeoutRemaining(ephemeralRunner, pod, created.Add(4*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 6*time.Minute, remaining)
+
+	remaining, ok = pendingTimeoutRemaining(ephemeralRunner, pod, created.Add(11*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, -time.Minute, remaining)
+
+	pod.Status.Phase = corev1.PodRunning
+	_, ok = pendingTimeoutRemaining(ephemeralRunner, pod, created.Add(11*time.Minute))
+	assert.False(t, ok, "Running pods don't time out")
+}
+
+func TestPendingRunnerStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default", UID: "pod-uid"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 Insufficient cpu.",
+			}},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, pod).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme}
+
+	get := func() *v1alpha1.EphemeralRunner {
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ephemeralRunner), updated))
+		return updated
+	}
+
+	require.NoError(t, r.updateRunStatusFromPod(context.Background(), ephemeralRunner, pod, logr.Discard()))
+	updated := get()
+	assert.Equal(t, corev1.PodPending, updated.Status.Phase)
+	assert.Equal(t, corev1.PodReasonUnschedulable, updated.Status.Reason)
+	assert.Equal(t, "0/3 nodes are available: 3 Insufficient cpu.", updated.Status.Message)
+	assert.Empty(t, updated.Status.FailureReason)
+
+	require.NoError(t, r.deletePodAsFailed(context.Background(), updated, pod, logr.Discard()))
+	updated = get()
+	assert.Equal(t, v1alpha1.EphemeralRunnerFailureReasonPendingTimeout, updated.Status.FailureReason)
+	assert.Equal(t, corev1.PodReasonUnschedulable, updated.Status.Reason)
+	assert.Equal(t, "0/3 nodes are available: 3 Insufficient cpu.", updated.Status.Message)
+	assert.Len(t, updated.Status.Failures, 1)
+}
+
+func TestNewEphemeralRunnerPod_RelaxedScheduling(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+	minDomains := int32(3)
+
+	runner := newTestEphemeralRunner()
+	runner.Spec.Spec.NodeSelector = map[string]string{"pool": "runners"}
+	runner.Spec.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: "kubernetes.io/arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd64"}},
+				}}},
+			},
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{Weight: 100}},
+		},
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{TopologyKey: "kubernetes.io/hostname"}},
+		},
+	}
+	runner.Spec.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       "topology.kubernetes.io/zone",
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		MinDomains:        &minDomains,
+	}}
+	runner.Spec.FailurePolicy = &v1alpha1.FailurePolicy{RelaxSchedulingOnPendingTimeout: true}
+
+	pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+	assert.Equal(t, runner.Spec.Spec.Affinity, pod.Spec.Affinity, "The constraints are kept until the pod timed out pending")
+
+	runner.Status.FailureReason = v1alpha1.EphemeralRunnerFailureReasonPendingTimeout
+	pod = b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+	assert.Equal(t, map[string]string{"pool": "runners"}, pod.Spec.NodeSelector)
+	require.NotNil(t, pod.Spec.Affinity)
+	require.NotNil(t, pod.Spec.Affinity.NodeAffinity)
+	assert.Equal(t, runner.Spec.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution, pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+	assert.Empty(t, pod.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution)
+	assert.Nil(t, pod.Spec.Affinity.PodAntiAffinity)
+	require.Len(t, pod.Spec.TopologySpreadConstraints, 1)
+	assert.Equal(t, corev1.ScheduleAnyway, pod.Spec.TopologySpreadConstraints[0].WhenUnsatisfiable)
+	assert.Nil(t, pod.Spec.TopologySpreadConstraints[0].MinDomains)
+
+	assert.Equal(t, corev1.DoNotSchedule, runner.Spec.Spec.TopologySpreadConstraints[0].WhenUnsatisfiable, "The template is left untouched")
+	assert.NotNil(t, runner.Spec.Spec.Affinity.PodAntiAffinity)
+}
@@ -0,0 +1,74 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultPersistentRunnerMaxLifetime is how long a persistent runner runs jobs before it is recycled,
+// unless its MaxLifetime says otherwise.
+const defaultPersistentRunnerMaxLifetime = 24 * time.Hour
+
+// persistentRunners returns the persistent runner settings of the runners of the autoscaling runner set,
+// or nil when they are ephemeral.
+func persistentRunners(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *v1alpha1.PersistentRunners {
+	if p := autoscalingRunnerSet.Spec.PersistentRunners; p != nil && p.Enabled {
+		return p
+	}
+	return nil
+}
+
+// validatePersistentRunners returns an error when persistent runners are enabled. A persistent runner would be
+// registered with a registration token as a self-hosted runner outside of the runner scale set: the jobs the
+// listener acquires for the scale set can't be assigned to it, and the listener doesn't see the jobs it runs.
+// JIT runners are always ephemeral, so they can't be reused across jobs either.
+func validatePersistentRunners(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if persistentRunners(autoscalingRunnerSet) == nil {
+		return nil
+	}
+	return fmt.Errorf("persistent runners aren't supported by runner scale sets: they wouldn't be members of the runner scale set the jobs are acquired for")
+}
+
+// persistentRunnerRecycleTime returns when a persistent runner created before persistent runners were rejected is recycled: when it reaches its maximum
+// lifetime, or at the first start of the recycle schedule after it was created, whichever comes first.
+// It returns false when the runner is ephemeral.
+func persistentRunnerRecycleTime(ephemeralRunner *v1alpha1.EphemeralRunner) (time.Time, bool) {
+	p := ephemeralRunner.Spec.Persistent
+	if p == nil {
+		return time.Time{}, false
+	}
+
+	created := ephemeralRunner.CreationTimestamp.Time
+	lifetime := defaultPersistentRunnerMaxLifetime
+	if p.MaxLifetime != nil && p.MaxLifetime.Duration > 0 {
+		lifetime = p.MaxLifetime.Duration
+	}
+	recycle := created.Add(lifetime)
+
+	if p.RecycleSchedule != "" {
+		// The schedule is validated with the AutoscalingRunnerSet.
+		if schedule, err := parseCronSchedule(p.RecycleSchedule, p.TimeZone); err == nil {
+			if next := schedule.next(created); !next.IsZero() && next.Before(recycle) {
+				recycle = next
+			}
+		}
+	}
+	return recycle, true
+}
+
+// recyclePersistentRunner deletes the persistent runner so that the runner set replaces it.
+// The finalizer of the runner waits for the job it is running, if any, before removing it from the service.
+func (r *EphemeralRunnerReconciler) recyclePersistentRunner(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
+	log.Info("Persistent runner reached its recycle time. Deleting the ephemeral runner to replace it", "created", ephemeralRunner.CreationTimestamp)
+	r.Recorder.Event(ephemeralRunner, corev1.EventTypeNormal, "RunnerRecycled", "Recycling the persistent runner")
+	if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the persistent runner: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPersistentRunnerRecycleTime(t *testing.T) {
+	// A Monday.
+	created := time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC)
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+	}
+
+	_, ok := persistentRunnerRecycleTime(ephemeralRunner)
+	assert.False(t, ok, "Ephemeral runners are not recycled")
+
+	ephemeralRunner.Spec.Persistent = &v1alpha1.PersistentRunners{Enabled: true}
+	recycle, ok := persistentRunnerRecycleTime(ephemeralRunner)
+	require.True(t, ok)
+	assert.Equal(t, created.Add(defaultPersistentRunnerMaxLifetime), recycle)
+
+	ephemeralRunner.Spec.Persistent.MaxLifetime = &metav1.Duration{Duration: 4 * time.Hour}
+	recycle, _ = persistentRunnerRecycleTime(ephemeralRunner)
+	assert.Equal(t, created.Add(4*time.Hour), recycle)
+
+	ephemeralRunner.Spec.Persistent.RecycleSchedule = "0 12 * * *"
+	recycle, _ = persistentRunnerRecycleTime(ephemeralRunner)
+	assert.Equal(t, time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC), recycle, "The schedule comes before the lifetime")
+
+	ephemeralRunner.Spec.Persistent.RecycleSchedule = "0 3 * * *"
+	recycle, _ = persistentRunnerRecycleTime(ephemeralRunner)
+	assert.Equal(t, created.Add(4*time.Hour), recycle, "The lifetime comes before the schedule")
+}
+
+func TestValidatePersistentRunners(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			PersistentRunners: &v1alpha1.PersistentRunners{Enabled: true},
+		},
+	}
+	assert.ErrorContains(t, validatePersistentRunners(autoscalingRunnerSet), "persistent runners aren't supported")
+
+	autoscalingRunnerSet.Spec.PersistentRunners.Enabled = false
+	assert.NoError(t, validatePersistentRunners(autoscalingRunnerSet))
+}
+
+func TestReconcile_PersistentRunners(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "arc",
+			Namespace:  "runners",
+			Finalizers: []string{autoscalingRunnerSetFinalizerName},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			PersistentRunners:  &v1alpha1.PersistentRunners{Enabled: true},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Log: logr.Discard(), Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(autoscalingRunnerSet)}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, autoscalingRunnerSet))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionInvalidSpec)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.InvalidSpecReasonPersistentRunners, condition.Reason)
+	require.Len(t, recorder.Events, 1)
+}
+
+func TestNewEphemeralRunnerSet_PersistentRunners(t *testing.T) {
+	b := resourceBuilder{}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:           "https://github.com/owner/repo",
+			GitHubConfigSecret:        "secret",
+			Template:                  newTestEphemeralRunner().Spec.PodTemplateSpec,
+			RegistrationTokenFallback: true,
+		},
+	}
+
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Nil(t, runnerSet.Spec.EphemeralRunnerSpec.Persistent)
+	assert.Nil(t, runnerSet.Spec.EphemeralRunnerSpec.RegistrationTokenFallback, "The runners are always registered with JIT runner configs")
+}
+
+func TestApplyRegistrationTokenFlow_PersistentRunner(t *testing.T) {
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			Persistent:                &v1alpha1.PersistentRunners{Enabled: true},
+			RegistrationTokenFallback: &v1alpha1.RegistrationTokenFallback{},
+		},
+		Status: v1alpha1.EphemeralRunnerStatus{
+			RunnerName:         "runner",
+			RegistrationMethod: v1alpha1.RunnerRegistrationMethodRegistrationToken,
+		},
+	}
+	c := corev1.Container{Name: EphemeralRunnerContainerName}
+	applyRegistrationTokenFlow(&c, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "runner"}})
+	require.Len(t, c.Args, 1)
+	assert.False(t, strings.Contains(c.Args[0], "--ephemeral"), "Persistent runners are not registered as ephemeral runners")
+}
+
+func TestUpdateStatusWithRunnerConfig_PersistentRunner(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			Persistent:                &v1alpha1.PersistentRunners{Enabled: true},
+			RegistrationTokenFallback: &v1alpha1.RegistrationTokenFallback{},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	// No JIT runner config is generated, so no actions client is needed.
+	_, err := r.updateStatusWithRunnerConfig(context.Background(), ephemeralRunner, logr.Discard())
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ephemeralRunner), ephemeralRunner))
+	assert.Equal(t, v1alpha1.RunnerRegistrationMethodRegistrationToken, ephemeralRunner.Status.RegistrationMethod)
+}
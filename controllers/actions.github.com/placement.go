@@ -0,0 +1,109 @@
+package actionsgithubcom
+
+import (
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/hash"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	labelKeyTopologyZone     = "topology.kubernetes.io/zone"
+	labelKeyTopologyHostname = "kubernetes.io/hostname"
+
+	placementAffinityWeight = 100
+)
+
+// runnerPlacementLabelValue returns the value of the LabelKeyAutoScaleRunnerSetName label
+// selecting the runner pods of the autoscaling runner set, within the limits of label values.
+func runnerPlacementLabelValue(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	if len(autoscalingRunnerSet.Name) <= validation.LabelValueMaxLength {
+		return autoscalingRunnerSet.Name
+	}
+	return hash.FNVHashString(autoscalingRunnerSet.Name)
+}
+
+// applyRunnerPlacement adds the topology spread constraints and the pod affinities of the placement
+// of the autoscaling runner set to the pod template, keeping the ones the template sets.
+// The runner pods are labeled with LabelKeyAutoScaleRunnerSetName so that they select each other.
+func applyRunnerPlacement(template *corev1.PodTemplateSpec, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	placement := autoscalingRunnerSet.Spec.Placement
+	if placement == nil {
+		return
+	}
+
+	topologyKey := ""
+	switch placement.Spread {
+	case v1alpha1.RunnerPlacementSpreadZone:
+		topologyKey = labelKeyTopologyZone
+	case v1alpha1.RunnerPlacementSpreadNode:
+		topologyKey = labelKeyTopologyHostname
+	}
+
+	if topologyKey == "" && placement.Packing == "" {
+		return
+	}
+
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelKeyAutoScaleRunnerSetName: runnerPlacementLabelValue(autoscalingRunnerSet),
+		},
+	}
+
+	labels := make(map[string]string, len(template.Labels)+1)
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels[LabelKeyAutoScaleRunnerSetName] = selector.MatchLabels[LabelKeyAutoScaleRunnerSetName]
+	template.Labels = labels
+
+	if topologyKey != "" && !hasTopologySpreadConstraint(&template.Spec, topologyKey) {
+		template.Spec.TopologySpreadConstraints = append(template.Spec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       topologyKey,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     selector,
+		})
+	}
+
+	term := corev1.WeightedPodAffinityTerm{
+		Weight: placementAffinityWeight,
+		PodAffinityTerm: corev1.PodAffinityTerm{
+			LabelSelector: selector,
+			TopologyKey:   labelKeyTopologyHostname,
+		},
+	}
+
+	switch placement.Packing {
+	case v1alpha1.RunnerPlacementPackingBinpack:
+		affinity := podAffinityOf(&template.Spec)
+		if affinity.PodAffinity == nil {
+			affinity.PodAffinity = &corev1.PodAffinity{}
+		}
+		affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, term)
+	case v1alpha1.RunnerPlacementPackingSpread:
+		affinity := podAffinityOf(&template.Spec)
+		if affinity.PodAntiAffinity == nil {
+			affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, term)
+	}
+}
+
+func hasTopologySpreadConstraint(spec *corev1.PodSpec, topologyKey string) bool {
+	for _, c := range spec.TopologySpreadConstraints {
+		if c.TopologyKey == topologyKey {
+			return true
+		}
+	}
+	return false
+}
+
+// podAffinityOf returns the affinity of the pod spec, creating it when it is missing.
+func podAffinityOf(spec *corev1.PodSpec) *corev1.Affinity {
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	return spec.Affinity
+}
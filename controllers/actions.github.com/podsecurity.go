@@ -0,0 +1,231 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	psadmission "k8s.io/pod-security-admission/admission/api"
+	psadmissionload "k8s.io/pod-security-admission/admission/api/load"
+	psapi "k8s.io/pod-security-admission/api"
+	"k8s.io/pod-security-admission/policy"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	podSecurityRecheckInterval  = 5 * time.Minute
+	reasonRunnerPodSecurityFail = "RunnerPodSecurityViolation"
+
+	podSecurityPluginName = "PodSecurity"
+)
+
+// podSecurityEvaluator runs the checks of the Pod Security admission.
+var podSecurityEvaluator = func() policy.Evaluator {
+	evaluator, err := policy.NewEvaluator(policy.DefaultChecks())
+	if err != nil {
+		panic(err)
+	}
+	return evaluator
+}()
+
+// ParsePodSecurityAdmissionConfig parses the configuration of the Pod Security admission of the cluster, in YAML or JSON:
+// either the AdmissionConfiguration given to the API server with --admission-control-config-file, configuring the
+// PodSecurity plugin inline, or the PodSecurityConfiguration of the plugin. An empty configuration returns nil.
+func ParsePodSecurityAdmissionConfig(data string) (*psadmission.PodSecurityConfiguration, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal([]byte(data), &typeMeta); err != nil {
+		return nil, fmt.Errorf("invalid pod security admission configuration: %v", err)
+	}
+	config := []byte(data)
+	if typeMeta.Kind == "AdmissionConfiguration" {
+		var admission apiserverv1.AdmissionConfiguration
+		if err := yaml.Unmarshal([]byte(data), &admission); err != nil {
+			return nil, fmt.Errorf("invalid admission configuration: %v", err)
+		}
+		config = nil
+		for _, plugin := range admission.Plugins {
+			if plugin.Name != podSecurityPluginName {
+				continue
+			}
+			if plugin.Configuration == nil {
+				return nil, fmt.Errorf("the %s plugin of the admission configuration has to be configured inline", podSecurityPluginName)
+			}
+			config = plugin.Configuration.Raw
+		}
+		if config == nil {
+			return nil, fmt.Errorf("the admission configuration doesn't configure the %s plugin", podSecurityPluginName)
+		}
+	}
+
+	podSecurity, err := psadmissionload.LoadFromData(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod security admission configuration: %v", err)
+	}
+	if _, err := podSecurityDefaults(podSecurity); err != nil {
+		return nil, err
+	}
+	return podSecurity, nil
+}
+
+// podSecurityDefaults returns the policy enforced on the namespaces without Pod Security labels:
+// the defaults of the configuration, privileged without one.
+func podSecurityDefaults(config *psadmission.PodSecurityConfiguration) (psapi.Policy, error) {
+	defaults := psapi.Policy{Enforce: psapi.LevelVersion{Level: psapi.LevelPrivileged, Version: psapi.LatestVersion()}}
+	if config == nil {
+		return defaults, nil
+	}
+
+	var err error
+	if config.Defaults.Enforce != "" {
+		if defaults.Enforce.Level, err = psapi.ParseLevel(config.Defaults.Enforce); err != nil {
+			return psapi.Policy{}, fmt.Errorf("invalid default enforce level of the pod security admission configuration: %v", err)
+		}
+	}
+	if config.Defaults.EnforceVersion != "" {
+		if defaults.Enforce.Version, err = psapi.ParseVersion(config.Defaults.EnforceVersion); err != nil {
+			return psapi.Policy{}, fmt.Errorf("invalid default enforce version of the pod security admission configuration: %v", err)
+		}
+	}
+	return defaults, nil
+}
+
+// podSecurityViolations returns the checks of the Pod Security level the pod fails, as the Pod Security admission
+// reports them. The privileged level admits every pod.
+func podSecurityViolations(pod *corev1.Pod, level psapi.LevelVersion) []string {
+	if level.Level == psapi.LevelPrivileged {
+		return nil
+	}
+
+	var violations []string
+	for _, result := range podSecurityEvaluator.EvaluatePod(level, &pod.ObjectMeta, &pod.Spec) {
+		if result.Allowed {
+			continue
+		}
+		violation := result.ForbiddenReason
+		if result.ForbiddenDetail != "" {
+			violation += " (" + result.ForbiddenDetail + ")"
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+func isExempt(exemptions []string, name string) bool {
+	for _, e := range exemptions {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runnerPodSecurityViolations returns the Pod Security level enforced on the namespace of the autoscaling runner set,
+// and the checks of the level the runner pods of the runner set would fail, as created by the controller.
+// The level is the one of the labels of the namespace, or the default of the pod security admission configuration.
+func (r *AutoscalingRunnerSetReconciler) runnerPodSecurityViolations(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerSet *v1alpha1.EphemeralRunnerSet) (psapi.LevelVersion, []string, error) {
+	config := r.Config.podSecurityAdmissionConfig(r.PodSecurityAdmissionConfig)
+	podSecurityPolicy, err := podSecurityDefaults(config)
+	if err != nil {
+		return psapi.LevelVersion{}, nil, err
+	}
+	if config != nil && isExempt(config.Exemptions.Namespaces, autoscalingRunnerSet.Namespace) {
+		return podSecurityPolicy.Enforce, nil, nil
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	namespace := new(corev1.Namespace)
+	if err := reader.Get(ctx, types.NamespacedName{Name: autoscalingRunnerSet.Namespace}, namespace); err != nil {
+		return psapi.LevelVersion{}, nil, fmt.Errorf("failed to get namespace: %v", err)
+	}
+	// Invalid labels are enforced as the restricted level, as the Pod Security admission does.
+	podSecurityPolicy, _ = psapi.PolicyToEvaluate(namespace.Labels, podSecurityPolicy)
+	level := podSecurityPolicy.Enforce
+	if level.Level == psapi.LevelPrivileged {
+		return level, nil, nil
+	}
+
+	variants := []string{""}
+	for _, v := range runnerSet.Spec.TemplateVariants {
+		variants = append(variants, v.Name)
+	}
+	defaults := r.Config.defaultSecurityContext(r.DefaultSecurityContext)
+
+	var violations []string
+	for _, variant := range variants {
+		runner := r.resourceBuilder.newEphemeralRunner(runnerSet, variant)
+		pod := r.resourceBuilder.newEphemeralRunnerPod(ctx, runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: runner.GenerateName}})
+		applyDefaultSecurityContext(pod, defaults)
+		pod, err := applyPodPatches(pod, runner.Spec.PodPatches)
+		if err != nil {
+			return level, nil, err
+		}
+		addJobMetadataVolume(pod)
+		if name := pod.Spec.RuntimeClassName; config != nil && name != nil && isExempt(config.Exemptions.RuntimeClasses, *name) {
+			continue
+		}
+
+		for _, v := range podSecurityViolations(pod, level) {
+			if variant != "" {
+				v = fmt.Sprintf("%s (template variant %s)", v, variant)
+			}
+			violations = append(violations, v)
+		}
+	}
+	sort.Strings(violations)
+	return level, violations, nil
+}
+
+// reconcileRunnerPodSecurity reports with the PodSecurityViolation condition whether the runner pods of the runner set
+// would be rejected by the Pod Security admission of the namespace, and returns whether they would be admitted.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerPodSecurity(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) (bool, error) {
+	level, violations, err := r.runnerPodSecurityViolations(ctx, autoscalingRunnerSet, runnerSet)
+	if err != nil {
+		return false, err
+	}
+	if len(violations) == 0 {
+		return true, r.clearRunnerPodSecurityViolation(ctx, autoscalingRunnerSet)
+	}
+
+	message := fmt.Sprintf("The runner pods violate PodSecurity %q enforced on namespace %s: %s", level.String(), autoscalingRunnerSet.Namespace, strings.Join(violations, ", "))
+	log.Info("Runner pods would be rejected by the Pod Security admission. Not creating the runner set", "level", level.String(), "violations", violations)
+	if condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionPodSecurityViolation); condition != nil && condition.Message == message {
+		return false, nil
+	}
+	if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.AutoscalingRunnerSetConditionPodSecurityViolation,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.PodSecurityViolationReasonEnforceLevel,
+			Message: message,
+		})
+	}); err != nil {
+		return false, fmt.Errorf("failed to update autoscaling runner set status with the pod security violations: %v", err)
+	}
+	r.Recorder.Event(autoscalingRunnerSet, corev1.EventTypeWarning, reasonRunnerPodSecurityFail, message)
+	return false, nil
+}
+
+func (r *AutoscalingRunnerSetReconciler) clearRunnerPodSecurityViolation(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionPodSecurityViolation) == nil {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		meta.RemoveStatusCondition(&obj.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionPodSecurityViolation)
+	})
+}
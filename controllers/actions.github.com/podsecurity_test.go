@@ -0,0 +1,201 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	psadmission "k8s.io/pod-security-admission/admission/api"
+	psapi "k8s.io/pod-security-admission/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPodSecurityViolations(t *testing.T) {
+	yes, no := true, false
+	restricted := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &no,
+		RunAsNonRoot:             &yes,
+		SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: EphemeralRunnerContainerName, SecurityContext: restricted},
+			},
+			Volumes: []corev1.Volume{
+				{Name: "work", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+	baseline := psapi.LevelVersion{Level: psapi.LevelBaseline, Version: psapi.LatestVersion()}
+	restrictedLevel := psapi.LevelVersion{Level: psapi.LevelRestricted, Version: psapi.LatestVersion()}
+	assert.Empty(t, podSecurityViolations(pod, restrictedLevel))
+
+	dind := pod.DeepCopy()
+	dind.Spec.Containers = append(dind.Spec.Containers, corev1.Container{
+		Name:            dindContainerName,
+		SecurityContext: &corev1.SecurityContext{Privileged: &yes},
+	})
+	assert.Equal(t, []string{`privileged (container "dind" must not set securityContext.privileged=true)`}, podSecurityViolations(dind, baseline))
+	assert.Contains(t, podSecurityViolations(dind, restrictedLevel), `unrestricted capabilities (container "dind" must set securityContext.capabilities.drop=["ALL"])`)
+	assert.Empty(t, podSecurityViolations(dind, psapi.LevelVersion{Level: psapi.LevelPrivileged, Version: psapi.LatestVersion()}))
+
+	host := pod.DeepCopy()
+	host.Spec.HostNetwork = true
+	host.Spec.Volumes = append(host.Spec.Volumes, corev1.Volume{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}})
+	host.Spec.Containers[0].SecurityContext = restricted.DeepCopy()
+	host.Spec.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"SYS_ADMIN", "CHOWN"}
+	assert.ElementsMatch(t, []string{
+		"host namespaces (hostNetwork=true)",
+		`hostPath volumes (volume "docker")`,
+		`non-default capabilities (container "runner" must not include "SYS_ADMIN" in securityContext.capabilities.add)`,
+	}, podSecurityViolations(host, baseline))
+	assert.Contains(t, podSecurityViolations(host, restrictedLevel), `unrestricted capabilities (container "runner" must not include "CHOWN", "SYS_ADMIN" in securityContext.capabilities.add)`)
+
+	// The pod security context covers the containers leaving runAsNonRoot and seccompProfile unset.
+	inherited := pod.DeepCopy()
+	inherited.Spec.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot:   &yes,
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	inherited.Spec.Containers[0].SecurityContext = restricted.DeepCopy()
+	inherited.Spec.Containers[0].SecurityContext.RunAsNonRoot = nil
+	inherited.Spec.Containers[0].SecurityContext.SeccompProfile = nil
+	assert.Empty(t, podSecurityViolations(inherited, restrictedLevel))
+}
+
+func TestParsePodSecurityAdmissionConfig(t *testing.T) {
+	config, err := ParsePodSecurityAdmissionConfig("")
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	config, err = ParsePodSecurityAdmissionConfig(`
+apiVersion: apiserver.config.k8s.io/v1
+kind: AdmissionConfiguration
+plugins:
+- name: PodSecurity
+  configuration:
+    apiVersion: pod-security.admission.config.k8s.io/v1
+    kind: PodSecurityConfiguration
+    defaults:
+      enforce: baseline
+      enforce-version: v1.25
+    exemptions:
+      namespaces: [kube-system]
+`)
+	require.NoError(t, err)
+	assert.Equal(t, "baseline", config.Defaults.Enforce)
+	assert.Equal(t, "v1.25", config.Defaults.EnforceVersion)
+	assert.Equal(t, []string{"kube-system"}, config.Exemptions.Namespaces)
+
+	config, err = ParsePodSecurityAdmissionConfig(`{"apiVersion":"pod-security.admission.config.k8s.io/v1","kind":"PodSecurityConfiguration","defaults":{"enforce":"restricted"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "restricted", config.Defaults.Enforce)
+	assert.Equal(t, "latest", config.Defaults.EnforceVersion)
+
+	_, err = ParsePodSecurityAdmissionConfig(`
+apiVersion: apiserver.config.k8s.io/v1
+kind: AdmissionConfiguration
+plugins:
+- name: PodSecurity
+  path: /etc/kubernetes/pod-security.yaml
+`)
+	assert.ErrorContains(t, err, "inline")
+
+	_, err = ParsePodSecurityAdmissionConfig(`{"apiVersion":"pod-security.admission.config.k8s.io/v1","kind":"PodSecurityConfiguration","defaults":{"enforce":"strict"}}`)
+	assert.Error(t, err)
+}
+
+func TestReconcileRunnerPodSecurity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	yes := true
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+		},
+	}
+	autoscalingRunnerSet.Spec.Template.Spec.Containers = append(autoscalingRunnerSet.Spec.Template.Spec.Containers, corev1.Container{
+		Name:            dindContainerName,
+		Image:           "docker:dind",
+		SecurityContext: &corev1.SecurityContext{Privileged: &yes},
+	})
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "runners", Labels: map[string]string{psapi.EnforceLevelLabel: string(psapi.LevelBaseline)}},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, namespace).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+
+	runnerSet, err := r.resourceBuilder.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	admitted, err := r.reconcileRunnerPodSecurity(ctx, autoscalingRunnerSet, runnerSet, logr.Discard())
+	require.NoError(t, err)
+	assert.False(t, admitted)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionPodSecurityViolation)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, v1alpha1.PodSecurityViolationReasonEnforceLevel, condition.Reason)
+	assert.Equal(t, `The runner pods violate PodSecurity "baseline:latest" enforced on namespace runners: privileged (container "dind" must not set securityContext.privileged=true)`, condition.Message)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, reasonRunnerPodSecurityFail)
+
+	// The violations of the template variants are reported too.
+	runnerSet.Spec.TemplateVariants = []v1alpha1.TemplateVariant{
+		{Name: "gpu", Template: *autoscalingRunnerSet.Spec.Template.DeepCopy()},
+	}
+	_, violations, err := r.runnerPodSecurityViolations(ctx, autoscalingRunnerSet, runnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`privileged (container "dind" must not set securityContext.privileged=true)`,
+		`privileged (container "dind" must not set securityContext.privileged=true) (template variant gpu)`,
+	}, violations)
+
+	// The namespaces without labels get the default level of the pod security admission configuration,
+	// unless they're exempt.
+	delete(namespace.Labels, psapi.EnforceLevelLabel)
+	require.NoError(t, c.Update(ctx, namespace))
+	r.PodSecurityAdmissionConfig = &psadmission.PodSecurityConfiguration{Defaults: psadmission.PodSecurityDefaults{Enforce: "baseline", EnforceVersion: "latest"}}
+	level, violations, err := r.runnerPodSecurityViolations(ctx, autoscalingRunnerSet, runnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, "baseline:latest", level.String())
+	assert.Len(t, violations, 2)
+
+	r.PodSecurityAdmissionConfig.Exemptions.Namespaces = []string{"runners"}
+	_, violations, err = r.runnerPodSecurityViolations(ctx, autoscalingRunnerSet, runnerSet)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	// The condition is cleared once the namespace admits the runner pods.
+	r.PodSecurityAdmissionConfig = nil
+	namespace.Labels[psapi.EnforceLevelLabel] = string(psapi.LevelPrivileged)
+	require.NoError(t, c.Update(ctx, namespace))
+	admitted, err = r.reconcileRunnerPodSecurity(ctx, autoscalingRunnerSet, runnerSet, logr.Discard())
+	require.NoError(t, err)
+	assert.True(t, admitted)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	assert.Nil(t, meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionPodSecurityViolation))
+}
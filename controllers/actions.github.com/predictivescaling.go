@@ -0,0 +1,209 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	defaultPredictiveScalingLeadTime = 10 * time.Minute
+
+	// jobArrivalSmoothing is the weight of the latest week in the learned peak of an hour of the week,
+	// so that the predictions follow a changing demand within a few weeks.
+	jobArrivalSmoothing = 0.3
+
+	hoursPerWeek = 7 * 24
+
+	jobArrivalHistoryKey = "history"
+)
+
+// jobArrivalHistoryName is the name of the config map holding the job arrival history of the autoscaling runner set.
+func jobArrivalHistoryName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return autoscalingRunnerSet.Name + "-job-arrivals"
+}
+
+// jobArrivalHistory is the demand of a scale set learned for every hour of the week.
+type jobArrivalHistory struct {
+	// Demand is the smoothed peak of assigned jobs of every hour of the week in UTC, starting on Sunday.
+	Demand []float64 `json:"demand"`
+
+	// Observed is how many times every hour of the week was learned.
+	Observed []int `json:"observed"`
+
+	// Hour is the start of the hour being observed, and Peak its peak of assigned jobs so far.
+	Hour time.Time `json:"hour"`
+	Peak int       `json:"peak"`
+}
+
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// observe records the assigned jobs at now. The peak of an hour is learned once the next hour is observed,
+// hours without observations are not learned.
+func (h *jobArrivalHistory) observe(now time.Time, assigned int) {
+	if len(h.Demand) != hoursPerWeek || len(h.Observed) != hoursPerWeek {
+		h.Demand = make([]float64, hoursPerWeek)
+		h.Observed = make([]int, hoursPerWeek)
+	}
+
+	hour := now.UTC().Truncate(time.Hour)
+	if !hour.After(h.Hour) {
+		if assigned > h.Peak {
+			h.Peak = assigned
+		}
+		return
+	}
+
+	if !h.Hour.IsZero() {
+		i := hourOfWeek(h.Hour)
+		if h.Observed[i] == 0 {
+			h.Demand[i] = float64(h.Peak)
+		} else {
+			h.Demand[i] += jobArrivalSmoothing * (float64(h.Peak) - h.Demand[i])
+		}
+		h.Observed[i]++
+	}
+	h.Hour = hour
+	h.Peak = assigned
+}
+
+// predict returns the highest learned demand of the hours from now to the end of the lead time.
+func (h *jobArrivalHistory) predict(now time.Time, leadTime time.Duration) int {
+	if len(h.Demand) != hoursPerWeek || len(h.Observed) != hoursPerWeek {
+		return 0
+	}
+
+	predicted := 0
+	end := now.Add(leadTime)
+	for t := now.UTC().Truncate(time.Hour); !t.After(end); t = t.Add(time.Hour) {
+		i := hourOfWeek(t)
+		if h.Observed[i] == 0 {
+			continue
+		}
+		if demand := int(math.Ceil(h.Demand[i])); demand > predicted {
+			predicted = demand
+		}
+	}
+	return predicted
+}
+
+// assignedJobs returns the assigned jobs of the last message the listener recorded on the runner set.
+func assignedJobs(runnerSet *v1alpha1.EphemeralRunnerSet) (int, bool) {
+	raw, ok := runnerSet.Annotations[v1alpha1.AnnotationKeyJobStatistics]
+	if !ok {
+		return 0, false
+	}
+	var statistics actions.RunnerScaleSetStatistic
+	if err := json.Unmarshal([]byte(raw), &statistics); err != nil {
+		return 0, false
+	}
+	return statistics.TotalAssignedJobs, true
+}
+
+// reconcilePredictiveScaling learns the demand from the job statistics the listener records on the latest runner set,
+// and reports the predicted runners in the status. It returns the runners to keep, which are 0 in RecommendOnly mode,
+// and when to check again, so that every hour is learned.
+func (r *AutoscalingRunnerSetReconciler) reconcilePredictiveScaling(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet, now time.Time, log logr.Logger) (int, time.Duration, error) {
+	predictive := autoscalingRunnerSet.Spec.PredictiveScaling
+	if predictive == nil {
+		if autoscalingRunnerSet.Status.PredictedRunners != 0 {
+			if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+				obj.Status.PredictedRunners = 0
+			}); err != nil {
+				return 0, 0, fmt.Errorf("failed to clear the predicted runners: %v", err)
+			}
+		}
+		return 0, 0, nil
+	}
+
+	configMap := new(corev1.ConfigMap)
+	found := true
+	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: jobArrivalHistoryName(autoscalingRunnerSet)}, configMap); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return 0, 0, fmt.Errorf("failed to get job arrival history: %v", err)
+		}
+		found = false
+	}
+
+	var history jobArrivalHistory
+	if raw, ok := configMap.Data[jobArrivalHistoryKey]; ok {
+		if err := json.Unmarshal([]byte(raw), &history); err != nil {
+			log.Info("Discarding the invalid job arrival history", "name", configMap.Name, "error", err.Error())
+			history = jobArrivalHistory{}
+		}
+	}
+
+	if assigned, ok := assignedJobs(latestRunnerSet); ok {
+		history.observe(now, assigned)
+	}
+
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to marshal job arrival history: %v", err)
+	}
+
+	switch {
+	case !found:
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobArrivalHistoryName(autoscalingRunnerSet),
+				Namespace: autoscalingRunnerSet.Namespace,
+			},
+			Data: map[string]string{jobArrivalHistoryKey: string(raw)},
+		}
+		if err := ctrl.SetControllerReference(autoscalingRunnerSet, configMap, r.Scheme); err != nil {
+			return 0, 0, fmt.Errorf("failed to set controller reference to the job arrival history: %v", err)
+		}
+		log.Info("Creating the job arrival history", "name", configMap.Name)
+		if err := r.Create(ctx, configMap); err != nil {
+			return 0, 0, fmt.Errorf("failed to create job arrival history: %v", err)
+		}
+	case configMap.Data[jobArrivalHistoryKey] != string(raw):
+		if err := patch(ctx, r.Client, configMap, func(obj *corev1.ConfigMap) {
+			if obj.Data == nil {
+				obj.Data = make(map[string]string)
+			}
+			obj.Data[jobArrivalHistoryKey] = string(raw)
+		}); err != nil {
+			return 0, 0, fmt.Errorf("failed to update job arrival history: %v", err)
+		}
+	}
+
+	leadTime := defaultPredictiveScalingLeadTime
+	if predictive.LeadTime != nil {
+		leadTime = predictive.LeadTime.Duration
+	}
+	predicted := history.predict(now, leadTime)
+	if max := autoscalingRunnerSet.Spec.MaxRunners; max != nil && predicted > *max {
+		predicted = *max
+	}
+
+	if autoscalingRunnerSet.Status.PredictedRunners != predicted {
+		log.Info("Predicted runners changed", "predictedRunners", predicted, "mode", predictive.Mode)
+		if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.PredictedRunners = predicted
+		}); err != nil {
+			return 0, 0, fmt.Errorf("failed to update the predicted runners: %v", err)
+		}
+	}
+
+	nextHour := now.UTC().Truncate(time.Hour).Add(time.Hour).Sub(now)
+	if predictive.Mode == v1alpha1.PredictiveScalingModeRecommendOnly {
+		return 0, nextHour, nil
+	}
+	return predicted, nextHour, nil
+}
@@ -0,0 +1,102 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestJobArrivalHistory(t *testing.T) {
+	// A Monday.
+	monday9am := time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC)
+	var history jobArrivalHistory
+
+	history.observe(monday9am.Add(5*time.Minute), 4)
+	history.observe(monday9am.Add(30*time.Minute), 10)
+	history.observe(monday9am.Add(50*time.Minute), 2)
+	assert.Zero(t, history.predict(monday9am.Add(-15*time.Minute), 30*time.Minute), "The peak of an hour is learned once it ends")
+
+	history.observe(monday9am.Add(time.Hour), 0)
+	assert.Equal(t, 10, history.predict(monday9am.Add(-15*time.Minute), 30*time.Minute))
+	assert.Zero(t, history.predict(monday9am.Add(-15*time.Minute), 10*time.Minute), "Hours past the lead time are not predicted")
+	assert.Zero(t, history.predict(monday9am.Add(24*time.Hour), 0), "Other days of the week are learned on their own")
+
+	nextMonday9am := monday9am.Add(7 * 24 * time.Hour)
+	history.observe(nextMonday9am, 0)
+	history.observe(nextMonday9am.Add(time.Hour), 0)
+	assert.Equal(t, 7, history.predict(nextMonday9am, 0), "The peaks of the past weeks are smoothed")
+}
+
+func TestReconcilePredictiveScaling(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	maxRunners := 8
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default", UID: "arc"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			MaxRunners:        &maxRunners,
+			PredictiveScaling: &v1alpha1.PredictiveScaling{},
+		},
+	}
+	latestRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc-abcde",
+			Namespace:   "default",
+			Annotations: map[string]string{v1alpha1.AnnotationKeyJobStatistics: `{"totalAssignedJobs":3}`},
+		},
+	}
+
+	now := time.Date(2023, 1, 2, 9, 50, 0, 0, time.UTC)
+	history := jobArrivalHistory{}
+	history.observe(now.Add(-7*24*time.Hour+15*time.Minute), 12)
+	history.observe(now.Add(-7*24*time.Hour+time.Hour), 0)
+	raw, err := json.Marshal(history)
+	require.NoError(t, err)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc-job-arrivals", Namespace: "default"},
+		Data:       map[string]string{jobArrivalHistoryKey: string(raw)},
+	}
+
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, latestRunnerSet, configMap).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	kept, recheck, err := r.reconcilePredictiveScaling(ctx, autoscalingRunnerSet, latestRunnerSet, now, logr.Discard())
+	require.NoError(t, err)
+	assert.Equal(t, maxRunners, kept, "The predicted demand of the next hour is kept within max runners")
+	assert.Equal(t, 10*time.Minute, recheck, "The next hour is learned once it starts")
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	assert.Equal(t, maxRunners, autoscalingRunnerSet.Status.PredictedRunners)
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-job-arrivals"}, configMap))
+	require.NoError(t, json.Unmarshal([]byte(configMap.Data[jobArrivalHistoryKey]), &history))
+	assert.Equal(t, 3, history.Peak, "The assigned jobs of the listener are recorded")
+
+	autoscalingRunnerSet.Spec.PredictiveScaling.Mode = v1alpha1.PredictiveScalingModeRecommendOnly
+	kept, _, err = r.reconcilePredictiveScaling(ctx, autoscalingRunnerSet, latestRunnerSet, now, logr.Discard())
+	require.NoError(t, err)
+	assert.Zero(t, kept, "Recommendations are not kept")
+	assert.Equal(t, maxRunners, autoscalingRunnerSet.Status.PredictedRunners)
+
+	autoscalingRunnerSet.Spec.PredictiveScaling = nil
+	kept, _, err = r.reconcilePredictiveScaling(ctx, autoscalingRunnerSet, latestRunnerSet, now, logr.Discard())
+	require.NoError(t, err)
+	assert.Zero(t, kept)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	assert.Zero(t, autoscalingRunnerSet.Status.PredictedRunners)
+}
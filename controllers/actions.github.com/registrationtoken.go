@@ -0,0 +1,168 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// The runners registered with a registration token are the persistent runners created before persistent runners
+// were rejected: they aren't members of the runner scale set. They are still run and removed, until they are recycled.
+
+const (
+	// registrationTokenKey is the key of the registration token in the secret of a runner
+	// registered with a registration token.
+	registrationTokenKey = "registrationToken"
+
+	// registeredRunnerLookupInterval is how often a running runner registered with a registration token
+	// is looked up in the service until it registered itself.
+	registeredRunnerLookupInterval = 10 * time.Second
+
+	// defaultRunnerDir is the directory of the runner in the runner image.
+	defaultRunnerDir = "/home/runner"
+)
+
+// jitConfigUnavailable reports whether generating the JIT runner config failed since the GitHub instance
+// can't generate them for the runner scale set, e.g. on older GitHub Enterprise Server versions or without
+// the permission to, rather than for a transient reason.
+func jitConfigUnavailable(err error) bool {
+	unsupportedServerVersionError := &actions.UnsupportedServerVersionError{}
+	if errors.As(err, &unsupportedServerVersionError) {
+		return true
+	}
+
+	actionsError := &actions.ActionsError{}
+	if !errors.As(err, &actionsError) {
+		return false
+	}
+	switch actionsError.StatusCode {
+	case http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// registerWithRegistrationToken switches the runner to register itself with a registration token.
+// The token is issued when the pod is created, since it expires after an hour.
+func (r *EphemeralRunnerReconciler) registerWithRegistrationToken(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (ctrl.Result, error) {
+	log.Info("Registering the runner with a registration token")
+
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.RegistrationMethod = v1alpha1.RunnerRegistrationMethodRegistrationToken
+		obj.Status.RunnerName = obj.Name
+	}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update the registration method of the runner: %v", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// updateRegistrationTokenSecret stores a new registration token in the secret of the runner.
+func (r *EphemeralRunnerReconciler) updateRegistrationTokenSecret(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, secret *corev1.Secret, log logr.Logger) error {
+	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunner)
+	if err != nil {
+		return fmt.Errorf("failed to get actions client for creating a registration token: %v", err)
+	}
+
+	token, err := actionsClient.CreateRegistrationToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create a registration token: %v", err)
+	}
+	if token == nil || token.Token == nil {
+		return fmt.Errorf("failed to create a registration token: the response has no token")
+	}
+
+	log.Info("Updating the secret of the runner with a new registration token")
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[registrationTokenKey] = []byte(*token.Token)
+	if err := r.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to update the secret with the registration token: %v", err)
+	}
+	return nil
+}
+
+// resolveRegisteredRunnerId records the id of the runner once it registered itself with the registration token.
+// It returns false while the runner isn't registered yet.
+func (r *EphemeralRunnerReconciler) resolveRegisteredRunnerId(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (bool, error) {
+	actionsClient, err := r.actionsClientFor(ctx, ephemeralRunner)
+	if err != nil {
+		return false, fmt.Errorf("failed to get actions client for looking up the runner: %v", err)
+	}
+
+	runner, err := actionsClient.GetRunnerByName(ctx, ephemeralRunner.Status.RunnerName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get runner by name: %v", err)
+	}
+	if runner == nil {
+		log.Info("Runner has not registered itself with the registration token yet", "runnerName", ephemeralRunner.Status.RunnerName)
+		return false, nil
+	}
+
+	log.Info("Runner registered itself with the registration token", "runnerId", runner.Id)
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.RunnerId = runner.Id
+	}); err != nil {
+		return false, fmt.Errorf("failed to update runner status for RunnerId: %v", err)
+	}
+	return true, nil
+}
+
+// applyRegistrationTokenFlow makes the runner container configure the runner with the registration token
+// of its secret before running it, instead of running it with the JIT runner config.
+func applyRegistrationTokenFlow(c *corev1.Container, runner *v1alpha1.EphemeralRunner, secret *corev1.Secret) {
+	fallback := runner.Spec.RegistrationTokenFallback
+
+	dir := defaultRunnerDir
+	if len(c.Command) > 0 && path.IsAbs(c.Command[0]) {
+		dir = path.Dir(c.Command[0])
+	}
+
+	args := []string{"--unattended"}
+	if runner.Spec.Persistent == nil {
+		args = append(args, "--ephemeral")
+	}
+	args = append(args,
+		"--disableupdate",
+		"--replace",
+		`--url "$`+EnvVarRunnerURL+`"`,
+		`--token "$`+EnvVarRunnerToken+`"`,
+		`--name "$`+EnvVarRunnerName+`"`,
+	)
+	c.Env = append(c.Env,
+		corev1.EnvVar{Name: EnvVarRunnerURL, Value: runner.Spec.GitHubConfigUrl},
+		corev1.EnvVar{
+			Name: EnvVarRunnerToken,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: secret.Name,
+					},
+					Key: registrationTokenKey,
+				},
+			},
+		},
+		corev1.EnvVar{Name: EnvVarRunnerName, Value: runner.Status.RunnerName},
+	)
+	if fallback.RunnerGroup != "" {
+		args = append(args, `--runnergroup "$`+EnvVarRunnerGroup+`"`)
+		c.Env = append(c.Env, corev1.EnvVar{Name: EnvVarRunnerGroup, Value: fallback.RunnerGroup})
+	}
+	if len(fallback.Labels) > 0 {
+		args = append(args, `--labels "$`+EnvVarRunnerLabels+`"`)
+		c.Env = append(c.Env, corev1.EnvVar{Name: EnvVarRunnerLabels, Value: strings.Join(fallback.Labels, ",")})
+	}
+
+	c.Command = []string{"/bin/bash", "-c"}
+	c.Args = []string{fmt.Sprintf("%s/config.sh %s && exec %s/run.sh", dir, strings.Join(args, " "), dir)}
+}
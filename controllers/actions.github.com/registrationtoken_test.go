@@ -0,0 +1,161 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/github/actions/fake"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestJITConfigUnavailable(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"unsupported server": {err: &actions.UnsupportedServerVersionError{}, want: true},
+		"forbidden":          {err: &actions.ActionsError{StatusCode: http.StatusForbidden}, want: true},
+		"not found":          {err: &actions.ActionsError{StatusCode: http.StatusNotFound}, want: true},
+		"not implemented":    {err: &actions.ActionsError{StatusCode: http.StatusNotImplemented}, want: true},
+		"conflict":           {err: &actions.ActionsError{StatusCode: http.StatusConflict, ExceptionName: "AgentExistsException"}, want: false},
+		"server error":       {err: &actions.ActionsError{StatusCode: http.StatusInternalServerError}, want: false},
+		"generic error":      {err: errors.New("connection reset"), want: false},
+		"bad credentials":    {err: &actions.GitHubAPIError{StatusCode: http.StatusUnauthorized}, want: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, jitConfigUnavailable(tc.err))
+		})
+	}
+}
+
+func TestApplyRegistrationTokenFlow(t *testing.T) {
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			GitHubConfigUrl: "https://github.example.com/org",
+			RegistrationTokenFallback: &v1alpha1.RegistrationTokenFallback{
+				RunnerGroup: "runners",
+				Labels:      []string{"arc", "linux"},
+			},
+		},
+		Status: v1alpha1.EphemeralRunnerStatus{
+			RunnerName:         "runner",
+			RegistrationMethod: v1alpha1.RunnerRegistrationMethodRegistrationToken,
+		},
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"}}
+
+	c := corev1.Container{Name: EphemeralRunnerContainerName, Command: []string{"/actions-runner/run.sh"}}
+	applyRegistrationTokenFlow(&c, runner, secret)
+
+	assert.Equal(t, []string{"/bin/bash", "-c"}, c.Command)
+	require.Len(t, c.Args, 1)
+	assert.Equal(t, `/actions-runner/config.sh --unattended --ephemeral --disableupdate --replace --url "$RUNNER_URL" --token "$RUNNER_TOKEN" --name "$RUNNER_NAME" --runnergroup "$RUNNER_GROUP" --labels "$RUNNER_LABELS" && exec /actions-runner/run.sh`, c.Args[0])
+
+	env := make(map[string]corev1.EnvVar)
+	for _, e := range c.Env {
+		env[e.Name] = e
+	}
+	assert.Equal(t, "https://github.example.com/org", env[EnvVarRunnerURL].Value)
+	require.NotNil(t, env[EnvVarRunnerToken].ValueFrom)
+	assert.Equal(t, registrationTokenKey, env[EnvVarRunnerToken].ValueFrom.SecretKeyRef.Key)
+	assert.Equal(t, "runner", env[EnvVarRunnerToken].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "runner", env[EnvVarRunnerName].Value)
+	assert.Equal(t, "runners", env[EnvVarRunnerGroup].Value)
+	assert.Equal(t, "arc,linux", env[EnvVarRunnerLabels].Value)
+
+	c = corev1.Container{Name: EphemeralRunnerContainerName}
+	runner.Spec.RegistrationTokenFallback = &v1alpha1.RegistrationTokenFallback{}
+	applyRegistrationTokenFlow(&c, runner, secret)
+	assert.Equal(t, `/home/runner/config.sh --unattended --ephemeral --disableupdate --replace --url "$RUNNER_URL" --token "$RUNNER_TOKEN" --name "$RUNNER_NAME" && exec /home/runner/run.sh`, c.Args[0])
+}
+
+func TestUpdateStatusWithRunnerConfig_JITConfigUnavailable(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			RunnerScaleSetId:          1,
+			GitHubConfigUrl:           "https://github.example.com/org",
+			GitHubConfigSecret:        "github-config",
+			RegistrationTokenFallback: &v1alpha1.RegistrationTokenFallback{},
+		},
+	}
+	configSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte("token")},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, configSecret).Build()
+
+	actionsClient := fake.NewFakeClient(
+		fake.WithGenerateJitRunnerConfig(nil, &actions.ActionsError{StatusCode: http.StatusForbidden}),
+	)
+	recorder := record.NewFakeRecorder(10)
+	r := &EphemeralRunnerReconciler{
+		Client:        c,
+		Scheme:        scheme,
+		Recorder:      recorder,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+	}
+
+	result, err := r.updateStatusWithRunnerConfig(ctx, ephemeralRunner, logr.Discard())
+	require.NoError(t, err)
+	assert.Equal(t, registrationBlockedRetryInterval, result.RequeueAfter, "The registration is retried")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(ephemeralRunner), ephemeralRunner))
+	assert.Empty(t, ephemeralRunner.Status.RegistrationMethod, "The runner isn't registered outside of the runner scale set")
+	condition := meta.FindStatusCondition(ephemeralRunner.Status.Conditions, v1alpha1.EphemeralRunnerConditionRegistrationBlocked)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.RegistrationBlockedReasonJITConfigUnavailable, condition.Reason)
+	require.Len(t, recorder.Events, 1)
+}
+
+func TestUpdateRegistrationTokenSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			RunnerScaleSetId:   1,
+			GitHubConfigUrl:    "https://github.example.com/org",
+			GitHubConfigSecret: "github-config",
+		},
+	}
+	configSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte("token")},
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"}}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, configSecret, secret).Build()
+	r := &EphemeralRunnerReconciler{
+		Client:        c,
+		Scheme:        scheme,
+		Recorder:      record.NewFakeRecorder(10),
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(), nil)),
+	}
+
+	require.NoError(t, r.updateRegistrationTokenSecret(ctx, ephemeralRunner, secret, logr.Discard()))
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+	assert.Equal(t, "registration-token", string(secret.Data[registrationTokenKey]))
+}
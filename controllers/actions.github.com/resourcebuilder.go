@@ -2,20 +2,35 @@ package actionsgithubcom
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/build"
 	"github.com/actions/actions-runner-controller/hash"
+	jsonpatch "github.com/evanphx/json-patch"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
 )
 
 const (
 	jitTokenKey = "jitToken"
+
+	// listenerMetricsPort is the port the listener serves its metrics on.
+	listenerMetricsPort = 8080
+	// listenerHealthProbePort is the port the listener serves its liveness and readiness probes on.
+	listenerHealthProbePort = 8081
+
+	listenerTerminationGracePeriodSeconds = 60
 )
 
 type resourceBuilder struct {
@@ -50,6 +65,84 @@ func (b *resourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 			Name:  "GITHUB_RUNNER_SCALE_SET_ID",
 			Value: strconv.Itoa(autoscalingListener.Spec.RunnerScaleSetId),
 		},
+		{
+			Name:  "GITHUB_AUTOSCALING_RUNNER_SET_NAME",
+			Value: autoscalingListener.Spec.AutoscalingRunnerSetName,
+		},
+		{
+			Name:  "GITHUB_METRICS_ADDR",
+			Value: fmt.Sprintf(":%d", listenerMetricsPort),
+		},
+		{
+			Name:  "GITHUB_HEALTH_PROBE_ADDR",
+			Value: fmt.Sprintf(":%d", listenerHealthProbePort),
+		},
+	}
+
+	if autoscalingListener.Spec.HoldJobsAboveMaxRunners {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_HOLD_JOBS_ABOVE_MAX_RUNNERS",
+			Value: "true",
+		})
+	}
+
+	if len(autoscalingListener.Spec.OverflowSourceLabels) > 0 {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_OVERFLOW_SOURCE_LABELS",
+			Value: strings.Join(autoscalingListener.Spec.OverflowSourceLabels, ","),
+		})
+	}
+
+	if len(autoscalingListener.Spec.TemplateVariantLabels) > 0 {
+		// Marshaling a map of string slices can't fail.
+		variantLabels, _ := json.Marshal(autoscalingListener.Spec.TemplateVariantLabels)
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_TEMPLATE_VARIANT_LABELS",
+			Value: string(variantLabels),
+		})
+	}
+
+	if limits := autoscalingListener.Spec.JobConcurrencyLimits; limits != nil {
+		if limits.MaxJobsPerRepository != nil {
+			listenerEnv = append(listenerEnv, corev1.EnvVar{
+				Name:  "GITHUB_MAX_JOBS_PER_REPOSITORY",
+				Value: strconv.Itoa(*limits.MaxJobsPerRepository),
+			})
+		}
+		if limits.MaxJobsPerWorkflow != nil {
+			listenerEnv = append(listenerEnv, corev1.EnvVar{
+				Name:  "GITHUB_MAX_JOBS_PER_WORKFLOW",
+				Value: strconv.Itoa(*limits.MaxJobsPerWorkflow),
+			})
+		}
+	}
+
+	if batchSize := autoscalingListener.Spec.JobAcquisitionBatchSize; batchSize != nil {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_JOB_ACQUISITION_BATCH_SIZE",
+			Value: strconv.Itoa(*batchSize),
+		})
+	}
+
+	if bufferPercent := autoscalingListener.Spec.ScalingBufferPercent; bufferPercent != nil {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_SCALING_BUFFER_PERCENT",
+			Value: strconv.Itoa(*bufferPercent),
+		})
+	}
+
+	if smoothingPercent := autoscalingListener.Spec.ScalingSmoothingPercent; smoothingPercent != nil {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_SCALING_SMOOTHING_PERCENT",
+			Value: strconv.Itoa(*smoothingPercent),
+		})
+	}
+
+	if maxPending := autoscalingListener.Spec.MaxPendingRunners; maxPending != nil {
+		listenerEnv = append(listenerEnv, corev1.EnvVar{
+			Name:  "GITHUB_MAX_PENDING_RUNNERS",
+			Value: strconv.Itoa(*maxPending),
+		})
 	}
 
 	if _, ok := secret.Data["github_token"]; ok {
@@ -108,6 +201,11 @@ func (b *resourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 		})
 	}
 
+	imagePullPolicy := autoscalingListener.Spec.ImagePullPolicy
+	if imagePullPolicy == "" {
+		imagePullPolicy = corev1.PullIfNotPresent
+	}
+
 	podSpec := corev1.PodSpec{
 		ServiceAccountName: serviceAccount.Name,
 		Containers: []corev1.Container{
@@ -115,15 +213,66 @@ func (b *resourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 				Name:            name,
 				Image:           autoscalingListener.Spec.Image,
 				Env:             listenerEnv,
-				ImagePullPolicy: corev1.PullIfNotPresent,
+				ImagePullPolicy: imagePullPolicy,
 				Command: []string{
 					"/github-runnerscaleset-listener",
 				},
+				Ports: []corev1.ContainerPort{
+					{
+						Name:          "metrics",
+						ContainerPort: listenerMetricsPort,
+						Protocol:      corev1.ProtocolTCP,
+					},
+					{
+						Name:          "health",
+						ContainerPort: listenerHealthProbePort,
+						Protocol:      corev1.ProtocolTCP,
+					},
+				},
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						HTTPGet: &corev1.HTTPGetAction{
+							Path: "/healthz",
+							Port: intstr.FromString("health"),
+						},
+					},
+					PeriodSeconds:    30,
+					FailureThreshold: 3,
+				},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						HTTPGet: &corev1.HTTPGetAction{
+							Path: "/readyz",
+							Port: intstr.FromString("health"),
+						},
+					},
+					PeriodSeconds: 10,
+				},
 			},
 		},
 		ImagePullSecrets: autoscalingListener.Spec.ImagePullSecrets,
 		RestartPolicy:    corev1.RestartPolicyNever,
 	}
+	// Leaves the listener the time to process its message in flight and delete its message session.
+	terminationGracePeriodSeconds := int64(listenerTerminationGracePeriodSeconds)
+	podSpec.TerminationGracePeriodSeconds = &terminationGracePeriodSeconds
+
+	annotations := map[string]string{
+		AnnotationKeyGitHubConfigSecretHash: secret.Labels["secret-data-hash"],
+	}
+	if template := autoscalingListener.Spec.Template; template != nil {
+		podSpec = mergeListenerPodSpec(podSpec, &template.Spec)
+		for k, v := range template.Labels {
+			if _, ok := newLabels[k]; !ok {
+				newLabels[k] = v
+			}
+		}
+		for k, v := range template.Annotations {
+			if _, ok := annotations[k]; !ok {
+				annotations[k] = v
+			}
+		}
+	}
 
 	newRunnerScaleSetListenerPod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
@@ -131,9 +280,10 @@ func (b *resourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      autoscalingListener.Name,
-			Namespace: autoscalingListener.Namespace,
-			Labels:    newLabels,
+			Name:        autoscalingListener.Name,
+			Namespace:   autoscalingListener.Namespace,
+			Labels:      newLabels,
+			Annotations: annotations,
 		},
 		Spec: podSpec,
 	}
@@ -141,6 +291,54 @@ func (b *resourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 	return newRunnerScaleSetListenerPod
 }
 
+// mergeListenerPodSpec merges the generated listener pod spec into the spec of the listener template.
+// The listener container of the template is completed with the generated one, whose env vars and
+// service account take precedence, and the other containers of the template are kept.
+func mergeListenerPodSpec(generated corev1.PodSpec, template *corev1.PodSpec) corev1.PodSpec {
+	spec := template.DeepCopy()
+	spec.ServiceAccountName = generated.ServiceAccountName
+	spec.RestartPolicy = generated.RestartPolicy
+	if spec.TerminationGracePeriodSeconds == nil {
+		spec.TerminationGracePeriodSeconds = generated.TerminationGracePeriodSeconds
+	}
+	spec.ImagePullSecrets = append(append([]corev1.LocalObjectReference{}, generated.ImagePullSecrets...), spec.ImagePullSecrets...)
+
+	listener := generated.Containers[0]
+	containers := []corev1.Container{listener}
+	for _, c := range spec.Containers {
+		if c.Name != listener.Name {
+			containers = append(containers, c)
+			continue
+		}
+
+		merged := c
+		if merged.Image == "" {
+			merged.Image = listener.Image
+		}
+		if merged.ImagePullPolicy == "" {
+			merged.ImagePullPolicy = listener.ImagePullPolicy
+		}
+		if len(merged.Command) == 0 {
+			merged.Command = listener.Command
+		}
+		if merged.LivenessProbe == nil {
+			merged.LivenessProbe = listener.LivenessProbe
+		}
+		if merged.ReadinessProbe == nil {
+			merged.ReadinessProbe = listener.ReadinessProbe
+		}
+		merged.Env = listener.Env
+		for _, env := range c.Env {
+			merged.Env = appendEnvIfMissing(merged.Env, env)
+		}
+		merged.Ports = append(listener.Ports, c.Ports...)
+		containers[0] = merged
+	}
+	spec.Containers = containers
+
+	return *spec
+}
+
 func (b *resourceBuilder) newEphemeralRunnerSet(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (*v1alpha1.EphemeralRunnerSet, error) {
 	runnerScaleSetId, err := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetIdKey])
 	if err != nil {
@@ -151,6 +349,40 @@ func (b *resourceBuilder) newEphemeralRunnerSet(autoscalingRunnerSet *v1alpha1.A
 	newLabels := map[string]string{}
 	newLabels[LabelKeyRunnerSpecHash] = runnerSpecHash
 
+	template := autoscalingRunnerSet.Spec.Template.DeepCopy()
+	if image := autoscalingRunnerSet.PinnedRunnerImage(); image != "" {
+		for i := range template.Spec.Containers {
+			if template.Spec.Containers[i].Name == EphemeralRunnerContainerName {
+				template.Spec.Containers[i].Image = image
+			}
+		}
+	}
+	applyRunnerPlacement(template, autoscalingRunnerSet)
+	applyRunnerToolCache(template, autoscalingRunnerSet)
+	applyDockerLayerCache(template, autoscalingRunnerSet)
+	applyKubernetesModeServiceAccount(template, autoscalingRunnerSet)
+	applyRunnerServiceAccount(template, autoscalingRunnerSet)
+	applyRunnerNetworkPolicy(template, autoscalingRunnerSet)
+	template.Spec.ImagePullSecrets = runnerImagePullSecrets(autoscalingRunnerSet, template)
+
+	variants := autoscalingRunnerSet.RunnerTemplateVariants()
+	if autoscalingRunnerSet.Spec.Placement != nil || autoscalingRunnerSet.Spec.ToolCache != nil || autoscalingRunnerSet.Spec.DockerLayerCache != nil || len(autoscalingRunnerSet.Spec.ImagePullSecrets) > 0 || kubernetesModeRBAC(autoscalingRunnerSet) || autoscalingRunnerSet.Spec.RunnerServiceAccount != nil || runnerNetworkPolicyEnabled(autoscalingRunnerSet) {
+		placed := make([]v1alpha1.TemplateVariant, len(variants))
+		for i := range variants {
+			variants[i].DeepCopyInto(&placed[i])
+			applyRunnerPlacement(&placed[i].Template, autoscalingRunnerSet)
+			applyRunnerToolCache(&placed[i].Template, autoscalingRunnerSet)
+			applyDockerLayerCache(&placed[i].Template, autoscalingRunnerSet)
+			applyKubernetesModeServiceAccount(&placed[i].Template, autoscalingRunnerSet)
+			applyRunnerServiceAccount(&placed[i].Template, autoscalingRunnerSet)
+			applyRunnerNetworkPolicy(&placed[i].Template, autoscalingRunnerSet)
+			placed[i].Template.Spec.ImagePullSecrets = runnerImagePullSecrets(autoscalingRunnerSet, &placed[i].Template)
+		}
+		variants = placed
+	}
+
+	reservedReplicas, _ := reservedRunners(autoscalingRunnerSet, time.Now())
+
 	newEphemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
@@ -159,17 +391,36 @@ func (b *resourceBuilder) newEphemeralRunnerSet(autoscalingRunnerSet *v1alpha1.A
 			Labels:       newLabels,
 		},
 		Spec: v1alpha1.EphemeralRunnerSetSpec{
-			Replicas: 0,
+			Replicas:              0,
+			WarmReplicas:          warmPoolSize(autoscalingRunnerSet),
+			WarmReplicasLimit:     autoscalingRunnerSet.Spec.MaxRunners,
+			ScaleDownPolicy:       autoscalingRunnerSet.Spec.ScaleDownPolicy,
+			IdleReplicasTimeout:   autoscalingRunnerSet.Spec.IdleRunnerTimeout,
+			MinReplicas:           minRunners(autoscalingRunnerSet),
+			ReservedReplicas:      reservedReplicas,
+			ListenerScaleSettings: listenerScaleSettings(autoscalingRunnerSet),
 			EphemeralRunnerSpec: v1alpha1.EphemeralRunnerSpec{
-				RunnerScaleSetId:   runnerScaleSetId,
-				GitHubConfigUrl:    autoscalingRunnerSet.Spec.GitHubConfigUrl,
-				GitHubConfigSecret: autoscalingRunnerSet.Spec.GitHubConfigSecret,
-				Proxy:              autoscalingRunnerSet.Spec.Proxy,
-				GitHubServerTLS:    autoscalingRunnerSet.Spec.GitHubServerTLS,
-				PodTemplateSpec:    autoscalingRunnerSet.Spec.Template,
+				RunnerScaleSetId:        runnerScaleSetId,
+				GitHubConfigUrl:         autoscalingRunnerSet.Spec.GitHubConfigUrl,
+				GitHubConfigSecret:      autoscalingRunnerSet.Spec.GitHubConfigSecret,
+				Proxy:                   autoscalingRunnerSet.Spec.Proxy,
+				GitHubServerTLS:         autoscalingRunnerSet.Spec.GitHubServerTLS,
+				Hooks:                   autoscalingRunnerSet.Spec.Hooks,
+				WorkVolumeClaimTemplate: autoscalingRunnerSet.Spec.WorkVolumeClaimTemplate,
+				FailurePolicy:           autoscalingRunnerSet.Spec.FailurePolicy,
+				RetainFailedPods:        autoscalingRunnerSet.Spec.RetainFailedPods,
+				JobCompletionTimeout:    autoscalingRunnerSet.Spec.JobCompletionTimeout,
+				PodPatches:              autoscalingRunnerSet.Spec.TemplatePatches,
+				InitContainers:          autoscalingRunnerSet.Spec.InitContainers,
+				PodTemplateSpec:         *template,
 			},
+			TemplateVariants: variants,
 		},
 	}
+	newEphemeralRunnerSet.Spec.EphemeralRunnerSpec.ContainerHookTemplate = containerHookTemplate(autoscalingRunnerSet)
+	if version := runnerRefreshVersion(autoscalingRunnerSet); version != "" {
+		newEphemeralRunnerSet.Annotations = map[string]string{AnnotationKeyRunnerRefreshVersion: version}
+	}
 
 	return newEphemeralRunnerSet, nil
 }
@@ -245,7 +496,7 @@ func (b *resourceBuilder) newScaleSetListenerRoleBinding(autoscalingListener *v1
 }
 
 func (b *resourceBuilder) newScaleSetListenerSecretMirror(autoscalingListener *v1alpha1.AutoscalingListener, secret *corev1.Secret) *corev1.Secret {
-	dataHash := hash.ComputeTemplateHash(&secret.Data)
+	dataHash := hash.ComputeTemplateHash(secret.Data)
 
 	newListenerSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -263,20 +514,13 @@ func (b *resourceBuilder) newScaleSetListenerSecretMirror(autoscalingListener *v
 	return newListenerSecret
 }
 
-func (b *resourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, namespace, image string, imagePullSecrets []corev1.LocalObjectReference) (*v1alpha1.AutoscalingListener, error) {
+func (b *resourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, overflowSources []string, namespace, image string, imagePullSecrets []corev1.LocalObjectReference) (*v1alpha1.AutoscalingListener, error) {
 	runnerScaleSetId, err := strconv.Atoi(autoscalingRunnerSet.Annotations[runnerScaleSetIdKey])
 	if err != nil {
 		return nil, err
 	}
 
-	effectiveMinRunners := 0
-	effectiveMaxRunners := math.MaxInt32
-	if autoscalingRunnerSet.Spec.MaxRunners != nil {
-		effectiveMaxRunners = *autoscalingRunnerSet.Spec.MaxRunners
-	}
-	if autoscalingRunnerSet.Spec.MinRunners != nil {
-		effectiveMinRunners = *autoscalingRunnerSet.Spec.MinRunners
-	}
+	settings := listenerScaleSettings(autoscalingRunnerSet)
 
 	autoscalingListener := &v1alpha1.AutoscalingListener{
 		ObjectMeta: metav1.ObjectMeta{
@@ -295,25 +539,203 @@ func (b *resourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.
 			AutoscalingRunnerSetNamespace: autoscalingRunnerSet.Namespace,
 			AutoscalingRunnerSetName:      autoscalingRunnerSet.Name,
 			EphemeralRunnerSetName:        ephemeralRunnerSet.Name,
-			MinRunners:                    effectiveMinRunners,
-			MaxRunners:                    effectiveMaxRunners,
+			MinRunners:                    settings.MinRunners,
+			MaxRunners:                    settings.MaxRunners,
 			Image:                         image,
+			ImagePullPolicy:               autoscalingRunnerSet.Spec.ListenerImagePullPolicy,
 			ImagePullSecrets:              imagePullSecrets,
+			TemplateVariantLabels:         autoscalingRunnerSet.TemplateVariantLabels(),
+			JobConcurrencyLimits:          autoscalingRunnerSet.Spec.JobConcurrencyLimits,
+			JobAcquisitionBatchSize:       settings.JobAcquisitionBatchSize,
+			ScalingBufferPercent:          settings.ScalingBufferPercent,
+			ScalingSmoothingPercent:       settings.ScalingSmoothingPercent,
+			MaxPendingRunners:             settings.MaxPendingRunners,
+			HoldJobsAboveMaxRunners:       autoscalingRunnerSet.Spec.OverflowTarget != "",
+			OverflowSourceLabels:          overflowSources,
+			Template:                      autoscalingRunnerSet.Spec.ListenerTemplate,
 		},
 	}
 
 	return autoscalingListener, nil
 }
 
-func (b *resourceBuilder) newEphemeralRunner(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) *v1alpha1.EphemeralRunner {
-	return &v1alpha1.EphemeralRunner{
+// newImagePrePullDaemonSets builds the DaemonSets pulling the images of the runner pod template and its variants
+// on the nodes their runners can be scheduled on. The templates scheduled alike share a DaemonSet, the first one
+// pulling the images of the pod template. The listener image provides the command run in the pulled images.
+func (b *resourceBuilder) newImagePrePullDaemonSets(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, listenerImage string) []*appsv1.DaemonSet {
+	type group struct {
+		variant   string
+		template  *corev1.PodTemplateSpec
+		templates []*corev1.PodTemplateSpec
+	}
+	var groups []*group
+	byScheduling := make(map[string]*group)
+	add := func(variant string, template *corev1.PodTemplateSpec) {
+		spec := &template.Spec
+		key := hash.ComputeTemplateHash(struct {
+			NodeSelector       map[string]string
+			Tolerations        []corev1.Toleration
+			Affinity           *corev1.Affinity
+			OS                 *corev1.PodOS
+			ServiceAccountName string
+			ImagePullSecrets   []corev1.LocalObjectReference
+		}{spec.NodeSelector, spec.Tolerations, spec.Affinity, spec.OS, spec.ServiceAccountName, runnerImagePullSecrets(autoscalingRunnerSet, template)})
+		if g, ok := byScheduling[key]; ok {
+			g.templates = append(g.templates, template)
+			return
+		}
+		g := &group{variant: variant, template: template, templates: []*corev1.PodTemplateSpec{template}}
+		byScheduling[key] = g
+		groups = append(groups, g)
+	}
+
+	add("", &autoscalingRunnerSet.Spec.Template)
+	variants := autoscalingRunnerSet.RunnerTemplateVariants()
+	for i := range variants {
+		add(variants[i].Name, &variants[i].Template)
+	}
+
+	daemonSets := make([]*appsv1.DaemonSet, 0, len(groups))
+	for _, g := range groups {
+		daemonSets = append(daemonSets, b.newImagePrePullDaemonSet(autoscalingRunnerSet, g.variant, g.template, g.templates, listenerImage))
+	}
+	return daemonSets
+}
+
+// newImagePrePullDaemonSet builds the DaemonSet pulling the images of the templates on the nodes the given
+// template is scheduled on, named after its template variant.
+func (b *resourceBuilder) newImagePrePullDaemonSet(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, variant string, scheduling *corev1.PodTemplateSpec, templates []*corev1.PodTemplateSpec, listenerImage string) *appsv1.DaemonSet {
+	template := &scheduling.Spec
+	windows := isWindowsPod(template)
+
+	pauseImage := DefaultImagePrePullPauseImage
+	if autoscalingRunnerSet.Spec.ImagePrePull != nil && autoscalingRunnerSet.Spec.ImagePrePull.PauseImage != "" {
+		pauseImage = autoscalingRunnerSet.Spec.ImagePrePull.PauseImage
+	}
+
+	// The images are pulled by init containers exiting right away, the pause container keeps the pod
+	// running so that the images are not garbage collected as unused. The images may have no shell,
+	// so the Linux ones run the static listener binary, installed in a shared volume by the first
+	// init container. Windows images all ship cmd.
+	var initContainers []corev1.Container
+	var volumes []corev1.Volume
+	exitCommand := []string{"cmd", "/c", "exit 0"}
+	var volumeMounts []corev1.VolumeMount
+	if !windows {
+		volumes = []corev1.Volume{{Name: imagePrePullToolsVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+		volumeMounts = []corev1.VolumeMount{{Name: imagePrePullToolsVolumeName, MountPath: imagePrePullToolsMountPath}}
+		initContainers = append(initContainers, corev1.Container{
+			Name:         "pre-pull-tools",
+			Image:        listenerImage,
+			Command:      []string{imagePrePullListenerBinary, "pre-pull-install", imagePrePullToolsMountPath},
+			Resources:    imagePrePullResources(),
+			VolumeMounts: volumeMounts,
+		})
+		exitCommand = []string{imagePrePullToolsMountPath + imagePrePullListenerBinary, "pre-pull-exit"}
+	}
+
+	seen := make(map[string]bool)
+	pulls := 0
+	for _, t := range templates {
+		for _, c := range append(append([]corev1.Container{}, t.Spec.InitContainers...), t.Spec.Containers...) {
+			if c.Image == "" || seen[c.Image] {
+				continue
+			}
+			seen[c.Image] = true
+			initContainers = append(initContainers, corev1.Container{
+				Name:            fmt.Sprintf("pre-pull-%d", pulls),
+				Image:           c.Image,
+				ImagePullPolicy: c.ImagePullPolicy,
+				Command:         exitCommand,
+				Resources:       imagePrePullResources(),
+				VolumeMounts:    volumeMounts,
+			})
+			pulls++
+		}
+	}
+
+	automountServiceAccountToken := false
+	podSpec := corev1.PodSpec{
+		InitContainers: initContainers,
+		Containers: []corev1.Container{
+			{
+				Name:      "pause",
+				Image:     pauseImage,
+				Resources: imagePrePullResources(),
+			},
+		},
+		Volumes: volumes,
+		// The service account of the runners may hold the pull secrets of the images.
+		ServiceAccountName:           template.ServiceAccountName,
+		AutomountServiceAccountToken: &automountServiceAccountToken,
+		ImagePullSecrets:             runnerImagePullSecrets(autoscalingRunnerSet, scheduling),
+		NodeSelector:                 template.NodeSelector,
+		Tolerations:                  template.Tolerations,
+		Affinity:                     template.Affinity,
+		OS:                           template.OS,
+	}
+
+	selectorLabels := map[string]string{
+		"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+		"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+		"app.kubernetes.io/component":       "image-pre-pull",
+		LabelKeyImagePrePullVariant:         variant,
+	}
+	labels := make(map[string]string, len(selectorLabels)+1)
+	for k, v := range selectorLabels {
+		labels[k] = v
+	}
+	labels[LabelKeyRunnerSpecHash] = hash.ComputeTemplateHash(&podSpec)
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      imagePrePullDaemonSetName(autoscalingRunnerSet, variant),
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: selectorLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: selectorLabels},
+				Spec:       podSpec,
+			},
+		},
+	}
+}
+
+func imagePrePullResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1m"),
+			corev1.ResourceMemory: resource.MustParse("8Mi"),
+		},
+	}
+}
+
+// newEphemeralRunner builds an EphemeralRunner of the runner set using the pod template
+// of the named template variant, or the default pod template when variant is empty.
+func (b *resourceBuilder) newEphemeralRunner(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, variant string) *v1alpha1.EphemeralRunner {
+	runner := &v1alpha1.EphemeralRunner{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: ephemeralRunnerSet.Name + "-runner-",
 			Namespace:    ephemeralRunnerSet.Namespace,
 		},
-		Spec: ephemeralRunnerSet.Spec.EphemeralRunnerSpec,
+		Spec: *ephemeralRunnerSet.Spec.EphemeralRunnerSpec.DeepCopy(),
+	}
+
+	for _, v := range ephemeralRunnerSet.Spec.TemplateVariants {
+		if v.Name != variant {
+			continue
+		}
+		runner.Labels = map[string]string{
+			LabelKeyRunnerTemplateVariant: v.Name,
+		}
+		runner.Spec.PodTemplateSpec = *v.Template.DeepCopy()
+		break
 	}
+
+	return runner
 }
 
 func (b *resourceBuilder) newEphemeralRunnerPod(ctx context.Context, runner *v1alpha1.EphemeralRunner, secret *corev1.Secret) *corev1.Pod {
@@ -345,6 +767,10 @@ func (b *resourceBuilder) newEphemeralRunnerPod(ctx context.Context, runner *v1a
 
 	labels["actions-ephemeral-runner"] = string(corev1.ConditionTrue)
 
+	if safeToEvict, managed := runnerPodSafeToEvict(runner); managed {
+		annotations[AnnotationKeyClusterAutoscalerSafeToEvict] = safeToEvict
+	}
+
 	objectMeta := metav1.ObjectMeta{
 		Name:        runner.ObjectMeta.Name,
 		Namespace:   runner.ObjectMeta.Namespace,
@@ -354,35 +780,231 @@ func (b *resourceBuilder) newEphemeralRunnerPod(ctx context.Context, runner *v1a
 
 	newPod.ObjectMeta = objectMeta
 	newPod.Spec = runner.Spec.PodTemplateSpec.Spec
+	prependInitContainers(&newPod.Spec, runner.Spec.InitContainers)
 	newPod.Spec.Containers = make([]corev1.Container, 0, len(runner.Spec.PodTemplateSpec.Spec.Containers))
 
 	for _, c := range runner.Spec.PodTemplateSpec.Spec.Containers {
 		if c.Name == EphemeralRunnerContainerName {
-			c.Env = append(
-				c.Env,
-				corev1.EnvVar{
-					Name: EnvVarRunnerJITConfig,
-					ValueFrom: &corev1.EnvVarSource{
-						SecretKeyRef: &corev1.SecretKeySelector{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: secret.Name,
+			if runner.Status.RegistrationMethod == v1alpha1.RunnerRegistrationMethodRegistrationToken {
+				applyRegistrationTokenFlow(&c, runner, secret)
+			} else {
+				c.Env = append(
+					c.Env,
+					corev1.EnvVar{
+						Name: EnvVarRunnerJITConfig,
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: secret.Name,
+								},
+								Key: jitTokenKey,
 							},
-							Key: jitTokenKey,
 						},
 					},
-				},
-				corev1.EnvVar{
-					Name:  EnvVarRunnerExtraUserAgent,
-					Value: fmt.Sprintf("actions-runner-controller/%s", build.Version),
+				)
+			}
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  EnvVarRunnerExtraUserAgent,
+				Value: fmt.Sprintf("actions-runner-controller/%s", build.Version),
+			})
+
+			if tls := runner.Spec.GitHubServerTLS; tls != nil && tls.RootCAsConfigMapRef != "" {
+				mountPath, certPath := gitHubServerTLSMountPath(runner), gitHubServerTLSCertPath(runner)
+				c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+					Name:      GitHubServerTLSVolumeName,
+					MountPath: mountPath,
+					ReadOnly:  true,
 				})
+				c.Env = appendEnvIfMissing(c.Env, corev1.EnvVar{Name: EnvVarNodeExtraCACerts, Value: certPath})
+				c.Env = appendEnvIfMissing(c.Env, corev1.EnvVar{Name: EnvVarSSLCertFile, Value: certPath})
+			}
 		}
 
 		newPod.Spec.Containers = append(newPod.Spec.Containers, c)
 	}
 
+	if tls := runner.Spec.GitHubServerTLS; tls != nil && tls.RootCAsConfigMapRef != "" {
+		volumes := make([]corev1.Volume, 0, len(newPod.Spec.Volumes)+1)
+		volumes = append(volumes, newPod.Spec.Volumes...)
+		newPod.Spec.Volumes = append(volumes, corev1.Volume{
+			Name: GitHubServerTLSVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: tls.RootCAsConfigMapRef,
+					},
+					Items: []corev1.KeyToPath{
+						{
+							Key:  gitHubServerTLSCertKey(tls),
+							Path: gitHubServerTLSCertKey(tls),
+						},
+					},
+				},
+			},
+		})
+	}
+
+	if relaxedScheduling(runner) {
+		relaxPodScheduling(&newPod.Spec)
+	}
+
+	addRunnerHooks(&newPod, runner.Spec.Hooks)
+	addContainerHookTemplate(&newPod, runner.Spec.ContainerHookTemplate)
+	addWorkVolumeClaim(&newPod, runner)
+	applyJobCompletionTimeout(&newPod, runner)
+
+	if isWindowsPod(&newPod.Spec) {
+		applyWindowsDefaults(&newPod)
+	}
+
 	return &newPod
 }
 
+// runnerJobCancellationGracePeriod is how long the runner has to cancel its job and report it
+// once it is signaled after the job completion timeout.
+const runnerJobCancellationGracePeriod = 30 * time.Second
+
+// waitForJobScript waits for the runner worker, which runs the job, to exit, for up to %d seconds.
+// The pattern is bracketed so that it doesn't match the command line of the script itself.
+const waitForJobScript = `i=0; while [ "$i" -lt %d ] && grep -qs 'Runner[.]Worker' /proc/[0-9]*/cmdline; do sleep 1; i=$((i+1)); done`
+
+// waitForRunnerScript waits for the runner listener, which cancels the job and reports it once the runner
+// is signaled, to exit, for up to %d seconds. It runs in the dind container, sharing the process namespace
+// of the pod, so that the job keeps its docker daemon until the runner is done with it.
+const waitForRunnerScript = `i=0; while [ "$i" -lt %d ] && grep -qs 'Runner[.]Listener' /proc/[0-9]*/cmdline; do sleep 1; i=$((i+1)); done`
+
+// applyJobCompletionTimeout keeps a terminating runner pod running its job for up to the job completion
+// timeout, with a preStop hook on the runner container. The pod waits the same way whether it is deleted
+// with its runner, drained from its node or evicted. Once the hook returns, the runner is signaled and
+// cancels the job. The dind container gets a preStop hook too, holding it until the runner exits, since
+// it would otherwise be stopped right away unless it runs as a native sidecar; the containers of the pod
+// share their process namespace for it, unless the template opts out. Windows pods and containers with
+// their own preStop hook are left as they are.
+func applyJobCompletionTimeout(pod *corev1.Pod, runner *v1alpha1.EphemeralRunner) {
+	timeout := runner.Spec.JobCompletionTimeout
+	if timeout == nil || timeout.Duration < time.Second || isWindowsPod(&pod.Spec) {
+		return
+	}
+
+	seconds := int64(timeout.Duration / time.Second)
+	gracePeriod := seconds + int64(runnerJobCancellationGracePeriod/time.Second)
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Lifecycle != nil && c.Lifecycle.PreStop != nil {
+			continue
+		}
+
+		switch c.Name {
+		case EphemeralRunnerContainerName:
+			setPreStopCommand(c, fmt.Sprintf(waitForJobScript, seconds))
+			if pod.Spec.TerminationGracePeriodSeconds == nil || *pod.Spec.TerminationGracePeriodSeconds < gracePeriod {
+				pod.Spec.TerminationGracePeriodSeconds = &gracePeriod
+			}
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[AnnotationKeyJobCompletionTimeout] = strconv.FormatInt(seconds, 10)
+		case dindContainerName:
+			if pod.Spec.ShareProcessNamespace != nil && !*pod.Spec.ShareProcessNamespace {
+				continue
+			}
+			shareProcessNamespace := true
+			pod.Spec.ShareProcessNamespace = &shareProcessNamespace
+			setPreStopCommand(c, fmt.Sprintf(waitForRunnerScript, gracePeriod))
+		}
+	}
+}
+
+// setPreStopCommand sets a preStop hook running the shell script in the container.
+func setPreStopCommand(c *corev1.Container, script string) {
+	lifecycle := &corev1.Lifecycle{}
+	if c.Lifecycle != nil {
+		lifecycle = c.Lifecycle.DeepCopy()
+	}
+	lifecycle.PreStop = &corev1.LifecycleHandler{
+		Exec: &corev1.ExecAction{
+			Command: []string{"/bin/sh", "-c", script},
+		},
+	}
+	c.Lifecycle = lifecycle
+}
+
+func gitHubServerTLSCertKey(tls *v1alpha1.GitHubServerTLSConfig) string {
+	if tls.RootCAsConfigMapKey != "" {
+		return tls.RootCAsConfigMapKey
+	}
+	return DefaultGitHubServerTLSCertKey
+}
+
+func gitHubServerTLSMountPath(runner *v1alpha1.EphemeralRunner) string {
+	if isWindowsPod(&runner.Spec.PodTemplateSpec.Spec) {
+		return WindowsGitHubServerTLSMountPath
+	}
+	return GitHubServerTLSMountPath
+}
+
+func gitHubServerTLSCertPath(runner *v1alpha1.EphemeralRunner) string {
+	if isWindowsPod(&runner.Spec.PodTemplateSpec.Spec) {
+		return WindowsGitHubServerTLSMountPath + `\` + gitHubServerTLSCertKey(runner.Spec.GitHubServerTLS)
+	}
+	return GitHubServerTLSMountPath + "/" + gitHubServerTLSCertKey(runner.Spec.GitHubServerTLS)
+}
+
+// appendEnvIfMissing appends the env var unless the container already defines
+// one with the same name, so user provided values always win.
+func appendEnvIfMissing(env []corev1.EnvVar, v corev1.EnvVar) []corev1.EnvVar {
+	for _, e := range env {
+		if e.Name == v.Name {
+			return env
+		}
+	}
+	return append(env, v)
+}
+
+// applyPodPatches applies the patches in order to the generated runner pod.
+func applyPodPatches(pod *corev1.Pod, patches []v1alpha1.PodPatch) (*corev1.Pod, error) {
+	if len(patches) == 0 {
+		return pod, nil
+	}
+
+	doc, err := json.Marshal(pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pod: %v", err)
+	}
+
+	for i, p := range patches {
+		patch, err := yaml.YAMLToJSON([]byte(p.Patch))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pod patch %d: %v", i, err)
+		}
+
+		switch p.Type {
+		case v1alpha1.PodPatchTypeJSON6902:
+			decoded, err := jsonpatch.DecodePatch(patch)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode JSON6902 pod patch %d: %v", i, err)
+			}
+			doc, err = decoded.Apply(doc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply JSON6902 pod patch %d: %v", i, err)
+			}
+		case v1alpha1.PodPatchTypeStrategicMerge, "":
+			doc, err = strategicpatch.StrategicMergePatch(doc, patch, corev1.Pod{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply strategic merge pod patch %d: %v", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported pod patch type %q", p.Type)
+		}
+	}
+
+	patched := new(corev1.Pod)
+	if err := json.Unmarshal(doc, patched); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal patched pod: %v", err)
+	}
+	return patched, nil
+}
+
 func (b *resourceBuilder) newEphemeralRunnerJitSecret(ephemeralRunner *v1alpha1.EphemeralRunner) *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -403,6 +1025,15 @@ func scaleSetListenerName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) s
 	return fmt.Sprintf("%v-%v-listener", autoscalingRunnerSet.Name, namespaceHash)
 }
 
+// imagePrePullDaemonSetName returns the name of the image pre-pull DaemonSet of the template variant,
+// or of the pod template when variant is empty.
+func imagePrePullDaemonSetName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, variant string) string {
+	if variant == "" {
+		return fmt.Sprintf("%v-image-pre-pull", autoscalingRunnerSet.Name)
+	}
+	return fmt.Sprintf("%v-image-pre-pull-%v", autoscalingRunnerSet.Name, variant)
+}
+
 func scaleSetListenerServiceAccountName(autoscalingListener *v1alpha1.AutoscalingListener) string {
 	namespaceHash := hash.FNVHashString(autoscalingListener.Spec.AutoscalingRunnerSetNamespace)
 	if len(namespaceHash) > 8 {
@@ -433,7 +1064,7 @@ func rulesForListenerRole(resourceNames []string) []rbacv1.PolicyRule {
 			APIGroups:     []string{"actions.github.com"},
 			Resources:     []string{"ephemeralrunnersets"},
 			ResourceNames: resourceNames,
-			Verbs:         []string{"patch"},
+			Verbs:         []string{"get", "patch"},
 		},
 		{
 			APIGroups: []string{"actions.github.com"},
@@ -0,0 +1,853 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestEphemeralRunner() *v1alpha1.EphemeralRunner {
+	return &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-runner",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			RunnerScaleSetId:   1,
+			PodTemplateSpec: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  EphemeralRunnerContainerName,
+							Image: "ghcr.io/actions/runner",
+						},
+						{
+							Name:  "sidecar",
+							Image: "busybox",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func findEnv(env []corev1.EnvVar, name string) *corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			return &env[i]
+		}
+	}
+	return nil
+}
+
+func TestNewEphemeralRunnerPod_GitHubServerTLS(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+
+	t.Run("no tls config", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		assert.Empty(t, pod.Spec.Volumes)
+		assert.Nil(t, findEnv(pod.Spec.Containers[0].Env, EnvVarNodeExtraCACerts))
+		assert.Nil(t, findEnv(pod.Spec.Containers[0].Env, EnvVarSSLCertFile))
+	})
+
+	t.Run("mounts the CA bundle into the runner container", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.GitHubServerTLS = &v1alpha1.GitHubServerTLSConfig{
+			RootCAsConfigMapRef: "ghes-ca",
+		}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		require.Len(t, pod.Spec.Volumes, 1)
+		volume := pod.Spec.Volumes[0]
+		assert.Equal(t, GitHubServerTLSVolumeName, volume.Name)
+		require.NotNil(t, volume.ConfigMap)
+		assert.Equal(t, "ghes-ca", volume.ConfigMap.Name)
+		assert.Equal(t, []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}}, volume.ConfigMap.Items)
+
+		runnerContainer := pod.Spec.Containers[0]
+		require.Len(t, runnerContainer.VolumeMounts, 1)
+		assert.Equal(t, GitHubServerTLSMountPath, runnerContainer.VolumeMounts[0].MountPath)
+
+		expectedPath := GitHubServerTLSMountPath + "/ca.crt"
+		require.NotNil(t, findEnv(runnerContainer.Env, EnvVarNodeExtraCACerts))
+		assert.Equal(t, expectedPath, findEnv(runnerContainer.Env, EnvVarNodeExtraCACerts).Value)
+		require.NotNil(t, findEnv(runnerContainer.Env, EnvVarSSLCertFile))
+		assert.Equal(t, expectedPath, findEnv(runnerContainer.Env, EnvVarSSLCertFile).Value)
+
+		sidecar := pod.Spec.Containers[1]
+		assert.Empty(t, sidecar.VolumeMounts)
+		assert.Empty(t, sidecar.Env)
+	})
+
+	t.Run("respects custom key and user provided env", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.GitHubServerTLS = &v1alpha1.GitHubServerTLSConfig{
+			RootCAsConfigMapRef: "ghes-ca",
+			RootCAsConfigMapKey: "bundle.pem",
+		}
+		runner.Spec.Spec.Containers[0].Env = []corev1.EnvVar{
+			{Name: EnvVarSSLCertFile, Value: "/etc/ssl/custom.pem"},
+		}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		require.Len(t, pod.Spec.Volumes, 1)
+		assert.Equal(t, []corev1.KeyToPath{{Key: "bundle.pem", Path: "bundle.pem"}}, pod.Spec.Volumes[0].ConfigMap.Items)
+
+		env := pod.Spec.Containers[0].Env
+		assert.Equal(t, GitHubServerTLSMountPath+"/bundle.pem", findEnv(env, EnvVarNodeExtraCACerts).Value)
+		assert.Equal(t, "/etc/ssl/custom.pem", findEnv(env, EnvVarSSLCertFile).Value)
+	})
+}
+
+func TestNewEphemeralRunner_TemplateVariant(t *testing.T) {
+	b := resourceBuilder{}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-runnerset", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: newTestEphemeralRunner().Spec,
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{
+					Name:   "gpu",
+					Labels: []string{"gpu"},
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/runner-gpu"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runner := b.newEphemeralRunner(runnerSet, "")
+	assert.Empty(t, runner.Labels[LabelKeyRunnerTemplateVariant])
+	assert.Equal(t, "ghcr.io/actions/runner", runner.Spec.Spec.Containers[0].Image)
+
+	runner = b.newEphemeralRunner(runnerSet, "gpu")
+	assert.Equal(t, "gpu", runner.Labels[LabelKeyRunnerTemplateVariant])
+	assert.Equal(t, "ghcr.io/actions/runner-gpu", runner.Spec.Spec.Containers[0].Image)
+	assert.Equal(t, runnerSet.Spec.EphemeralRunnerSpec.GitHubConfigUrl, runner.Spec.GitHubConfigUrl)
+}
+
+func TestNewEphemeralRunnerSet_NodePlacements(t *testing.T) {
+	b := resourceBuilder{}
+	template := newTestEphemeralRunner().Spec.PodTemplateSpec
+	template.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "linux"}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           template,
+			NodePlacements: []v1alpha1.NodePlacement{
+				{
+					Name:         "gpu",
+					Labels:       []string{"gpu"},
+					NodeSelector: map[string]string{"accelerator": "nvidia"},
+					Tolerations:  []corev1.Toleration{{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+				},
+			},
+		},
+	}
+
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	require.Len(t, runnerSet.Spec.TemplateVariants, 1)
+
+	variant := runnerSet.Spec.TemplateVariants[0]
+	assert.Equal(t, "gpu", variant.Name)
+	assert.Equal(t, []string{"gpu"}, variant.Labels)
+	assert.Equal(t, template.Spec.Containers, variant.Template.Spec.Containers)
+	assert.Equal(t, map[string]string{"kubernetes.io/os": "linux", "accelerator": "nvidia"}, variant.Template.Spec.NodeSelector)
+	assert.Equal(t, autoscalingRunnerSet.Spec.NodePlacements[0].Tolerations, variant.Template.Spec.Tolerations)
+	assert.Equal(t, map[string]string{"kubernetes.io/os": "linux"}, autoscalingRunnerSet.Spec.Template.Spec.NodeSelector, "default template must not change")
+
+	assert.Equal(t, map[string][]string{"gpu": {"gpu"}}, autoscalingRunnerSet.TemplateVariantLabels())
+}
+
+func TestValidateRunnerTemplates_Names(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			TemplateVariants: []v1alpha1.TemplateVariant{{Name: "gpu", Labels: []string{"gpu"}}},
+			NodePlacements:   []v1alpha1.NodePlacement{{Name: "arm64", Labels: []string{"arm64"}}},
+		},
+	}
+	assert.NoError(t, validateRunnerTemplates(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.NodePlacements = append(autoscalingRunnerSet.Spec.NodePlacements, v1alpha1.NodePlacement{Name: "gpu", Labels: []string{"cuda"}})
+	assert.ErrorContains(t, validateRunnerTemplates(autoscalingRunnerSet), `node placement name "gpu"`)
+
+	autoscalingRunnerSet.Spec.NodePlacements = []v1alpha1.NodePlacement{{Name: "arm64"}, {Name: "arm64"}}
+	assert.ErrorContains(t, validateRunnerTemplates(autoscalingRunnerSet), `node placement name "arm64"`)
+
+	autoscalingRunnerSet.Spec.NodePlacements = []v1alpha1.NodePlacement{{Labels: []string{"arm64"}}}
+	assert.ErrorContains(t, validateRunnerTemplates(autoscalingRunnerSet), "require a name")
+}
+
+func TestNodePlacements_PriorityClass(t *testing.T) {
+	priority := int32(1000)
+	template := newTestEphemeralRunner().Spec.PodTemplateSpec
+	template.Spec.PriorityClassName = "ci-default"
+	template.Spec.Priority = &priority
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template: template,
+			NodePlacements: []v1alpha1.NodePlacement{
+				{Name: "release", Labels: []string{"release"}, PriorityClassName: "ci-high"},
+				{Name: "nightly", Labels: []string{"nightly"}, PriorityClassName: "ci-low"},
+			},
+		},
+	}
+
+	variants := autoscalingRunnerSet.RunnerTemplateVariants()
+	require.Len(t, variants, 2)
+	assert.Equal(t, "ci-high", variants[0].Template.Spec.PriorityClassName)
+	assert.Nil(t, variants[0].Template.Spec.Priority, "priority must be resolved from the new class")
+	assert.Equal(t, "ci-low", variants[1].Template.Spec.PriorityClassName)
+	assert.Equal(t, "ci-default", autoscalingRunnerSet.Spec.Template.Spec.PriorityClassName)
+}
+
+func TestValidateRunnerTemplates_RuntimeClass(t *testing.T) {
+	gvisor := "gvisor"
+	sandboxed := newTestEphemeralRunner().Spec.PodTemplateSpec
+	sandboxed.Spec.RuntimeClassName = &gvisor
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template: newTestEphemeralRunner().Spec.PodTemplateSpec,
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{Name: "untrusted", Labels: []string{"fork"}, Template: sandboxed},
+			},
+		},
+	}
+	assert.ErrorContains(t, validateRunnerTemplates(autoscalingRunnerSet), `template variant "untrusted" can't change the runtime class`)
+
+	// A runner scale set running all its runners sandboxed is fine.
+	autoscalingRunnerSet.Spec.Template = *sandboxed.DeepCopy()
+	autoscalingRunnerSet.Spec.NodePlacements = []v1alpha1.NodePlacement{{Name: "arm64", Labels: []string{"arm64"}}}
+	assert.NoError(t, validateRunnerTemplates(autoscalingRunnerSet))
+}
+
+func TestNodePlacements_Architecture(t *testing.T) {
+	template := newTestEphemeralRunner().Spec.PodTemplateSpec
+	template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "busybox"}}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template: template,
+			NodePlacements: []v1alpha1.NodePlacement{
+				{
+					Name:         "arm64",
+					Labels:       []string{"arm64"},
+					NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"},
+					Images: map[string]string{
+						EphemeralRunnerContainerName: "ghcr.io/actions/runner:arm64",
+						"init":                       "busybox:arm64",
+					},
+				},
+			},
+		},
+	}
+
+	variants := autoscalingRunnerSet.RunnerTemplateVariants()
+	require.Len(t, variants, 1)
+	spec := variants[0].Template.Spec
+	assert.Equal(t, map[string]string{"kubernetes.io/arch": "arm64"}, spec.NodeSelector)
+	assert.Equal(t, "ghcr.io/actions/runner:arm64", spec.Containers[0].Image)
+	assert.Equal(t, "busybox", spec.Containers[1].Image, "containers without an image of the placement are unchanged")
+	assert.Equal(t, "busybox:arm64", spec.InitContainers[0].Image)
+	assert.Equal(t, "ghcr.io/actions/runner", autoscalingRunnerSet.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestNewAutoScalingListener_Overflow(t *testing.T) {
+	b := resourceBuilder{}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "primary",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			OverflowTarget:     "spot",
+		},
+	}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "primary-abcde"}}
+
+	listener, err := b.newAutoScalingListener(autoscalingRunnerSet, runnerSet, []string{"batch", "ci"}, "arc-systems", "ghcr.io/actions/listener", nil)
+	require.NoError(t, err)
+	assert.True(t, listener.Spec.HoldJobsAboveMaxRunners)
+	assert.Equal(t, []string{"batch", "ci"}, listener.Spec.OverflowSourceLabels)
+
+	pod := b.newScaleSetListenerPod(listener, &corev1.ServiceAccount{}, &corev1.Secret{})
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "GITHUB_HOLD_JOBS_ABOVE_MAX_RUNNERS", Value: "true"})
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "GITHUB_OVERFLOW_SOURCE_LABELS", Value: "batch,ci"})
+
+	role := b.newScaleSetListenerRole(listener)
+	assert.Equal(t, []string{"primary-abcde"}, role.Rules[0].ResourceNames, "the listener only scales the runner set of its own scale set")
+}
+
+func TestApplyPodPatches(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+
+	t.Run("applies patches in order", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.PodPatches = []v1alpha1.PodPatch{
+			{
+				Patch: `
+spec:
+  containers:
+  - name: sidecar
+    resources:
+      limits:
+        memory: 1Gi
+`,
+			},
+			{
+				Type:  v1alpha1.PodPatchTypeJSON6902,
+				Patch: `[{"op": "replace", "path": "/spec/containers/1/image", "value": "busybox:1.36"}]`,
+			},
+		}
+
+		pod, err := applyPodPatches(b.newEphemeralRunnerPod(context.Background(), runner, secret), runner.Spec.PodPatches)
+		require.NoError(t, err)
+
+		require.Len(t, pod.Spec.Containers, 2)
+		sidecar := pod.Spec.Containers[1]
+		assert.Equal(t, "busybox:1.36", sidecar.Image)
+		assert.Equal(t, "1Gi", sidecar.Resources.Limits.Memory().String())
+
+		// Controller injected settings are kept.
+		assert.NotNil(t, findEnv(pod.Spec.Containers[0].Env, EnvVarRunnerJITConfig))
+		assert.Equal(t, "test-runner", pod.Name)
+	})
+
+	t.Run("invalid patch", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.PodPatches = []v1alpha1.PodPatch{
+			{
+				Type:  v1alpha1.PodPatchTypeJSON6902,
+				Patch: `[{"op": "replace", "path": "/spec/containers/5/image", "value": "busybox"}]`,
+			},
+		}
+
+		_, err := applyPodPatches(b.newEphemeralRunnerPod(context.Background(), runner, secret), runner.Spec.PodPatches)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewEphemeralRunnerPod_Hooks(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+
+	t.Run("mounts the hook scripts into the runner container", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.Hooks = &v1alpha1.RunnerHooks{
+			JobStarted:   &v1alpha1.RunnerHookScript{ConfigMapRef: "hooks", ConfigMapKey: "setup.sh"},
+			JobCompleted: &v1alpha1.RunnerHookScript{ConfigMapRef: "cleanup", ConfigMapKey: "cleanup.sh"},
+		}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		require.Len(t, pod.Spec.Volumes, 1)
+		volume := pod.Spec.Volumes[0]
+		assert.Equal(t, RunnerHooksVolumeName, volume.Name)
+		require.NotNil(t, volume.Projected)
+		require.Len(t, volume.Projected.Sources, 2)
+		assert.Equal(t, "hooks", volume.Projected.Sources[0].ConfigMap.Name)
+		assert.Equal(t, "setup.sh", volume.Projected.Sources[0].ConfigMap.Items[0].Key)
+		assert.Equal(t, "job-started/setup.sh", volume.Projected.Sources[0].ConfigMap.Items[0].Path)
+		assert.Equal(t, "cleanup", volume.Projected.Sources[1].ConfigMap.Name)
+		assert.Equal(t, "job-completed/cleanup.sh", volume.Projected.Sources[1].ConfigMap.Items[0].Path)
+
+		runnerContainer := pod.Spec.Containers[0]
+		assert.Equal(t, []corev1.VolumeMount{{Name: RunnerHooksVolumeName, MountPath: RunnerHooksMountPath, ReadOnly: true}}, runnerContainer.VolumeMounts)
+		require.NotNil(t, findEnv(runnerContainer.Env, EnvVarRunnerHookJobStarted))
+		assert.Equal(t, RunnerHooksMountPath+"/job-started/setup.sh", findEnv(runnerContainer.Env, EnvVarRunnerHookJobStarted).Value)
+		require.NotNil(t, findEnv(runnerContainer.Env, EnvVarRunnerHookJobCompleted))
+		assert.Equal(t, RunnerHooksMountPath+"/job-completed/cleanup.sh", findEnv(runnerContainer.Env, EnvVarRunnerHookJobCompleted).Value)
+
+		sidecar := pod.Spec.Containers[1]
+		assert.Empty(t, sidecar.VolumeMounts)
+		assert.Empty(t, sidecar.Env)
+	})
+
+	t.Run("respects the hooks of the template", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.Hooks = &v1alpha1.RunnerHooks{
+			JobStarted: &v1alpha1.RunnerHookScript{ConfigMapRef: "hooks", ConfigMapKey: "setup.sh"},
+		}
+		runner.Spec.Spec.Containers[0].Env = []corev1.EnvVar{
+			{Name: EnvVarRunnerHookJobStarted, Value: "/home/runner/setup.sh"},
+		}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		env := pod.Spec.Containers[0].Env
+		assert.Equal(t, "/home/runner/setup.sh", findEnv(env, EnvVarRunnerHookJobStarted).Value)
+		assert.Nil(t, findEnv(env, EnvVarRunnerHookJobCompleted))
+	})
+
+	t.Run("windows", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+		runner.Spec.Hooks = &v1alpha1.RunnerHooks{
+			JobCompleted: &v1alpha1.RunnerHookScript{ConfigMapRef: "hooks", ConfigMapKey: "cleanup.ps1"},
+		}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		runnerContainer := pod.Spec.Containers[0]
+		assert.Equal(t, WindowsRunnerHooksMountPath, runnerContainer.VolumeMounts[0].MountPath)
+		assert.Equal(t, WindowsRunnerHooksMountPath+`\job-completed\cleanup.ps1`, findEnv(runnerContainer.Env, EnvVarRunnerHookJobCompleted).Value)
+	})
+}
+
+func TestNewEphemeralRunnerPod_ContainerHookTemplate(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+
+	runner := newTestEphemeralRunner()
+	runner.Spec.ContainerHookTemplate = &v1alpha1.ContainerHookTemplate{ConfigMapRef: "job-pod"}
+
+	pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+	require.Len(t, pod.Spec.Volumes, 1)
+	volume := pod.Spec.Volumes[0]
+	assert.Equal(t, ContainerHookTemplateVolumeName, volume.Name)
+	require.NotNil(t, volume.ConfigMap)
+	assert.Equal(t, "job-pod", volume.ConfigMap.Name)
+	assert.Equal(t, []corev1.KeyToPath{{Key: DefaultContainerHookTemplateKey, Path: DefaultContainerHookTemplateKey}}, volume.ConfigMap.Items)
+
+	runnerContainer := pod.Spec.Containers[0]
+	assert.Equal(t, []corev1.VolumeMount{{Name: ContainerHookTemplateVolumeName, MountPath: ContainerHookTemplateMountPath, ReadOnly: true}}, runnerContainer.VolumeMounts)
+	require.NotNil(t, findEnv(runnerContainer.Env, EnvVarContainerHookTemplate))
+	assert.Equal(t, ContainerHookTemplateMountPath+"/template.yaml", findEnv(runnerContainer.Env, EnvVarContainerHookTemplate).Value)
+	assert.Empty(t, pod.Spec.Containers[1].VolumeMounts)
+
+	// Only the kubernetes container mode passes its template to the runners.
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ContainerMode: &v1alpha1.ContainerMode{
+				Type:         v1alpha1.ContainerModeTypeKubernetes,
+				HookTemplate: &v1alpha1.ContainerHookTemplate{ConfigMapRef: "job-pod", ConfigMapKey: "pod.yaml"},
+			},
+		},
+	}
+	assert.Equal(t, "pod.yaml", containerHookTemplate(autoscalingRunnerSet).ConfigMapKey)
+	autoscalingRunnerSet.Spec.ContainerMode.Type = v1alpha1.ContainerModeTypeDind
+	assert.Nil(t, containerHookTemplate(autoscalingRunnerSet))
+}
+
+func TestNewEphemeralRunnerPod_Windows(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+
+	t.Run("linux pod is unchanged", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		assert.Nil(t, pod.Spec.OS)
+		assert.Empty(t, pod.Spec.Containers[0].WorkingDir)
+		assert.Empty(t, pod.Spec.Containers[0].Command)
+	})
+
+	t.Run("defaults the runner container", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "windows"}
+		runner.Spec.GitHubServerTLS = &v1alpha1.GitHubServerTLSConfig{RootCAsConfigMapRef: "ghes-ca"}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		require.NotNil(t, pod.Spec.OS)
+		assert.Equal(t, corev1.Windows, pod.Spec.OS.Name)
+
+		runnerContainer := pod.Spec.Containers[0]
+		assert.Equal(t, WindowsRunnerWorkingDir, runnerContainer.WorkingDir)
+		assert.Equal(t, []string{WindowsRunnerCommand}, runnerContainer.Command)
+		require.Len(t, runnerContainer.VolumeMounts, 1)
+		assert.Equal(t, WindowsGitHubServerTLSMountPath, runnerContainer.VolumeMounts[0].MountPath)
+		assert.Equal(t, WindowsGitHubServerTLSMountPath+`\ca.crt`, findEnv(runnerContainer.Env, EnvVarNodeExtraCACerts).Value)
+
+		sidecar := pod.Spec.Containers[1]
+		assert.Empty(t, sidecar.WorkingDir)
+		assert.Empty(t, sidecar.Command)
+	})
+
+	t.Run("keeps the command of the template", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+		runner.Spec.Spec.Containers[0].Command = []string{`D:\runner\run.cmd`}
+		runner.Spec.Spec.Containers[0].WorkingDir = `D:\runner`
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		assert.Equal(t, []string{`D:\runner\run.cmd`}, pod.Spec.Containers[0].Command)
+		assert.Equal(t, `D:\runner`, pod.Spec.Containers[0].WorkingDir)
+	})
+}
+
+func TestNewImagePrePullDaemonSets(t *testing.T) {
+	b := resourceBuilder{}
+	template := newTestEphemeralRunner().Spec.PodTemplateSpec
+	template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "ghcr.io/actions/runner"}}
+	template.Spec.NodeSelector = map[string]string{"pool": "runners"}
+	template.Spec.Tolerations = []corev1.Toleration{{Key: "runners", Operator: corev1.TolerationOpExists}}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template:     template,
+			ImagePrePull: &v1alpha1.ImagePrePull{Enabled: true},
+		},
+	}
+
+	daemonSets := b.newImagePrePullDaemonSets(autoscalingRunnerSet, "ghcr.io/actions/listener:latest")
+	require.Len(t, daemonSets, 1)
+	daemonSet := daemonSets[0]
+
+	assert.Equal(t, "test-asrs-image-pre-pull", daemonSet.Name)
+	assert.Equal(t, "default", daemonSet.Namespace)
+	assert.Equal(t, daemonSet.Spec.Selector.MatchLabels, daemonSet.Spec.Template.Labels)
+
+	spec := daemonSet.Spec.Template.Spec
+	assert.Equal(t, template.Spec.NodeSelector, spec.NodeSelector)
+	assert.Equal(t, template.Spec.Tolerations, spec.Tolerations)
+	require.Len(t, spec.InitContainers, 3, "each image is pulled once, after installing the command")
+	assert.Equal(t, "ghcr.io/actions/listener:latest", spec.InitContainers[0].Image)
+	assert.Equal(t, []string{"/github-runnerscaleset-listener", "pre-pull-install", imagePrePullToolsMountPath}, spec.InitContainers[0].Command)
+	assert.Equal(t, "ghcr.io/actions/runner", spec.InitContainers[1].Image)
+	assert.Equal(t, "busybox", spec.InitContainers[2].Image)
+	for _, c := range spec.InitContainers[1:] {
+		assert.Equal(t, []string{imagePrePullToolsMountPath + "/github-runnerscaleset-listener", "pre-pull-exit"}, c.Command, "images without a shell must be pulled too")
+		assert.Equal(t, imagePrePullToolsMountPath, c.VolumeMounts[0].MountPath)
+	}
+	require.Len(t, spec.Containers, 1)
+	assert.Equal(t, DefaultImagePrePullPauseImage, spec.Containers[0].Image)
+
+	hash := daemonSet.Labels[LabelKeyRunnerSpecHash]
+	autoscalingRunnerSet.Spec.Template.Spec.Containers[1].Image = "busybox:1.36"
+	assert.NotEqual(t, hash, b.newImagePrePullDaemonSets(autoscalingRunnerSet, "ghcr.io/actions/listener:latest")[0].Labels[LabelKeyRunnerSpecHash])
+
+	t.Run("template variants", func(t *testing.T) {
+		gpu := *template.DeepCopy()
+		gpu.Spec.Containers[0].Image = "ghcr.io/actions/runner-cuda"
+		autoscalingRunnerSet := autoscalingRunnerSet.DeepCopy()
+		autoscalingRunnerSet.Spec.TemplateVariants = []v1alpha1.TemplateVariant{{Name: "gpu", Labels: []string{"gpu"}, Template: gpu}}
+		autoscalingRunnerSet.Spec.NodePlacements = []v1alpha1.NodePlacement{
+			{Name: "arm64", Labels: []string{"arm64"}, NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"}, Images: map[string]string{"runner": "ghcr.io/actions/runner-arm64"}},
+		}
+
+		daemonSets := b.newImagePrePullDaemonSets(autoscalingRunnerSet, "ghcr.io/actions/listener:latest")
+		require.Len(t, daemonSets, 2, "the variants scheduled like the template share its daemon set")
+
+		images := func(daemonSet *appsv1.DaemonSet) []string {
+			var images []string
+			for _, c := range daemonSet.Spec.Template.Spec.InitContainers[1:] {
+				images = append(images, c.Image)
+			}
+			return images
+		}
+		assert.Equal(t, "test-asrs-image-pre-pull", daemonSets[0].Name)
+		assert.Contains(t, images(daemonSets[0]), "ghcr.io/actions/runner-cuda")
+		assert.Equal(t, "test-asrs-image-pre-pull-arm64", daemonSets[1].Name)
+		assert.Equal(t, "arm64", daemonSets[1].Spec.Template.Spec.NodeSelector["kubernetes.io/arch"])
+		assert.Contains(t, images(daemonSets[1]), "ghcr.io/actions/runner-arm64")
+		assert.NotEqual(t, daemonSets[0].Spec.Selector.MatchLabels, daemonSets[1].Spec.Selector.MatchLabels)
+	})
+
+	t.Run("windows", func(t *testing.T) {
+		autoscalingRunnerSet := autoscalingRunnerSet.DeepCopy()
+		autoscalingRunnerSet.Spec.Template.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "windows"}
+
+		spec := b.newImagePrePullDaemonSets(autoscalingRunnerSet, "ghcr.io/actions/listener:latest")[0].Spec.Template.Spec
+		assert.Equal(t, "ghcr.io/actions/runner", spec.InitContainers[0].Image, "the Linux listener binary can't run on Windows")
+		assert.Equal(t, []string{"cmd", "/c", "exit 0"}, spec.InitContainers[0].Command)
+		assert.Empty(t, spec.Volumes)
+	})
+}
+
+func TestNewScaleSetListenerPod_Template(t *testing.T) {
+	autoscalingListener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "arc-systems"},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			GitHubConfigUrl:               "https://github.com/owner/repo",
+			AutoscalingRunnerSetNamespace: "default",
+			AutoscalingRunnerSetName:      "test-asrs",
+			EphemeralRunnerSetName:        "test-asrs-abcde",
+			Image:                         "ghcr.io/actions/listener:latest",
+			ImagePullSecrets:              []corev1.LocalObjectReference{{Name: "controller-pull-secret"}},
+			Template: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"team": "platform", scaleSetListenerLabel: "overridden"},
+					Annotations: map[string]string{"example.com/owner": "platform"},
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector:       map[string]string{"kubernetes.io/os": "linux"},
+					Tolerations:        []corev1.Toleration{{Key: "dedicated", Value: "arc", Effect: corev1.TaintEffectNoSchedule}},
+					ServiceAccountName: "ignored",
+					Containers: []corev1.Container{
+						{
+							Name: name,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+							},
+							Env: []corev1.EnvVar{
+								{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+								{Name: "GITHUB_CONFIGURE_URL", Value: "ignored"},
+							},
+						},
+						{Name: "sidecar", Image: "busybox"},
+					},
+				},
+			},
+		},
+	}
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "listener-sa"}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "listener-secret", Labels: map[string]string{"secret-data-hash": "abc"}}}
+
+	var b resourceBuilder
+	pod := b.newScaleSetListenerPod(autoscalingListener, serviceAccount, secret)
+
+	assert.Equal(t, "platform", pod.Labels["team"])
+	assert.Equal(t, "default-test-asrs", pod.Labels[scaleSetListenerLabel], "generated labels take precedence")
+	assert.Equal(t, "platform", pod.Annotations["example.com/owner"])
+	assert.Equal(t, "abc", pod.Annotations[AnnotationKeyGitHubConfigSecretHash], "The pod is restarted when the secret changes")
+	assert.Equal(t, "listener-sa", pod.Spec.ServiceAccountName)
+	assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+	assert.Equal(t, map[string]string{"kubernetes.io/os": "linux"}, pod.Spec.NodeSelector)
+	assert.Len(t, pod.Spec.Tolerations, 1)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "controller-pull-secret"}}, pod.Spec.ImagePullSecrets)
+
+	require.Len(t, pod.Spec.Containers, 2)
+	listener := pod.Spec.Containers[0]
+	assert.Equal(t, name, listener.Name)
+	assert.Equal(t, "ghcr.io/actions/listener:latest", listener.Image)
+	assert.Equal(t, []string{"/github-runnerscaleset-listener"}, listener.Command)
+	assert.Equal(t, resource.MustParse("100m"), listener.Resources.Requests[corev1.ResourceCPU])
+	assert.Contains(t, listener.Env, corev1.EnvVar{Name: "HTTPS_PROXY", Value: "http://proxy:3128"})
+	assert.Contains(t, listener.Env, corev1.EnvVar{Name: "GITHUB_CONFIGURE_URL", Value: "https://github.com/owner/repo"})
+	assert.NotContains(t, listener.Env, corev1.EnvVar{Name: "GITHUB_CONFIGURE_URL", Value: "ignored"})
+	require.NotNil(t, listener.LivenessProbe)
+	assert.Equal(t, "/healthz", listener.LivenessProbe.HTTPGet.Path)
+	require.NotNil(t, listener.ReadinessProbe)
+	assert.Equal(t, "/readyz", listener.ReadinessProbe.HTTPGet.Path)
+	require.NotNil(t, pod.Spec.TerminationGracePeriodSeconds)
+	assert.Equal(t, int64(60), *pod.Spec.TerminationGracePeriodSeconds)
+	assert.Equal(t, "sidecar", pod.Spec.Containers[1].Name)
+}
+
+func TestNewEphemeralRunnerSet_Placement(t *testing.T) {
+	b := resourceBuilder{}
+	template := newTestEphemeralRunner().Spec.PodTemplateSpec
+	template.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+		{MaxSkew: 2, TopologyKey: "kubernetes.io/hostname", WhenUnsatisfiable: corev1.DoNotSchedule},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           template,
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{Name: "large", Labels: []string{"large"}, Template: template},
+			},
+			Placement: &v1alpha1.RunnerPlacement{
+				Spread:  v1alpha1.RunnerPlacementSpreadZone,
+				Packing: v1alpha1.RunnerPlacementPackingBinpack,
+			},
+		},
+	}
+
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{LabelKeyAutoScaleRunnerSetName: "arc"}}
+	for _, placed := range []corev1.PodTemplateSpec{runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec, runnerSet.Spec.TemplateVariants[0].Template} {
+		assert.Equal(t, "arc", placed.Labels[LabelKeyAutoScaleRunnerSetName])
+
+		require.Len(t, placed.Spec.TopologySpreadConstraints, 2)
+		assert.Equal(t, template.Spec.TopologySpreadConstraints[0], placed.Spec.TopologySpreadConstraints[0])
+		assert.Equal(t, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     selector,
+		}, placed.Spec.TopologySpreadConstraints[1])
+
+		require.NotNil(t, placed.Spec.Affinity)
+		require.NotNil(t, placed.Spec.Affinity.PodAffinity)
+		assert.Nil(t, placed.Spec.Affinity.PodAntiAffinity)
+		assert.Equal(t, []corev1.WeightedPodAffinityTerm{
+			{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: selector,
+					TopologyKey:   "kubernetes.io/hostname",
+				},
+			},
+		}, placed.Spec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+
+	assert.Len(t, autoscalingRunnerSet.Spec.Template.Spec.TopologySpreadConstraints, 1, "The template of the autoscaling runner set must not change")
+	assert.Nil(t, autoscalingRunnerSet.Spec.Template.Spec.Affinity, "The template of the autoscaling runner set must not change")
+
+	autoscalingRunnerSet.Spec.Placement = &v1alpha1.RunnerPlacement{
+		Spread:  v1alpha1.RunnerPlacementSpreadNode,
+		Packing: v1alpha1.RunnerPlacementPackingSpread,
+	}
+	runnerSet, err = b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+
+	placed := runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec
+	assert.Equal(t, template.Spec.TopologySpreadConstraints, placed.Spec.TopologySpreadConstraints, "The node spread constraint of the template must be kept")
+	require.NotNil(t, placed.Spec.Affinity.PodAntiAffinity)
+	assert.Len(t, placed.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, 1)
+}
+
+func TestNewAutoScalingListener_ListenerImage(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-asrs",
+			Namespace:   "default",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:         "https://github.com/owner/repo",
+			ListenerImage:           "registry.example.com/mirror/listener:0.4.0",
+			ListenerImagePullPolicy: corev1.PullAlways,
+		},
+	}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "test-asrs-abcde"}}
+
+	r := &AutoscalingRunnerSetReconciler{DefaultRunnerScaleSetListenerImage: "ghcr.io/actions/listener:0.4.0"}
+	var b resourceBuilder
+	listener, err := b.newAutoScalingListener(autoscalingRunnerSet, runnerSet, nil, "arc-systems", r.listenerImage(autoscalingRunnerSet), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/mirror/listener:0.4.0", listener.Spec.Image)
+	assert.Equal(t, corev1.PullAlways, listener.Spec.ImagePullPolicy)
+
+	pod := b.newScaleSetListenerPod(listener, &corev1.ServiceAccount{}, &corev1.Secret{})
+	assert.Equal(t, "registry.example.com/mirror/listener:0.4.0", pod.Spec.Containers[0].Image)
+	assert.Equal(t, corev1.PullAlways, pod.Spec.Containers[0].ImagePullPolicy)
+
+	autoscalingRunnerSet.Spec.ListenerImage = ""
+	autoscalingRunnerSet.Spec.ListenerImagePullPolicy = ""
+	assert.Equal(t, "ghcr.io/actions/listener:0.4.0", r.listenerImage(autoscalingRunnerSet), "The image of the controller is the default")
+	listener, err = b.newAutoScalingListener(autoscalingRunnerSet, runnerSet, nil, "arc-systems", r.listenerImage(autoscalingRunnerSet), nil)
+	require.NoError(t, err)
+	pod = b.newScaleSetListenerPod(listener, &corev1.ServiceAccount{}, &corev1.Secret{})
+	assert.Equal(t, corev1.PullIfNotPresent, pod.Spec.Containers[0].ImagePullPolicy)
+}
+
+func TestNewEphemeralRunnerPod_InitContainers(t *testing.T) {
+	b := resourceBuilder{}
+	runner := newTestEphemeralRunner()
+	runner.Spec.Spec.InitContainers = []corev1.Container{{Name: "init-dind-externals", Image: "ghcr.io/actions/runner"}}
+	runner.Spec.InitContainers = []corev1.Container{
+		{Name: "fetch-secrets", Image: "vault"},
+		{Name: "warm-cache", Image: "busybox"},
+	}
+
+	pod := b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}})
+
+	names := make([]string, 0, len(pod.Spec.InitContainers))
+	for _, c := range pod.Spec.InitContainers {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"fetch-secrets", "warm-cache", "init-dind-externals"}, names)
+	assert.Len(t, runner.Spec.Spec.InitContainers, 1, "The template must not change")
+}
+
+func TestNewEphemeralRunnerPod_JobCompletionTimeout(t *testing.T) {
+	b := resourceBuilder{}
+	runner := newTestEphemeralRunner()
+	runner.Spec.JobCompletionTimeout = &metav1.Duration{Duration: 10 * time.Minute}
+
+	pod := b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}})
+
+	require.NotNil(t, pod.Spec.TerminationGracePeriodSeconds)
+	assert.Equal(t, int64(630), *pod.Spec.TerminationGracePeriodSeconds, "The runner gets 30s to cancel the job after the timeout")
+	assert.Equal(t, "600", pod.Annotations[AnnotationKeyJobCompletionTimeout])
+	for _, c := range pod.Spec.Containers {
+		if c.Name == EphemeralRunnerContainerName {
+			require.NotNil(t, c.Lifecycle)
+			require.NotNil(t, c.Lifecycle.PreStop)
+			assert.Contains(t, c.Lifecycle.PreStop.Exec.Command[2], `"$i" -lt 600`)
+		}
+	}
+	assert.Nil(t, pod.Spec.ShareProcessNamespace, "No dind container to hold")
+
+	runner.Spec.PodTemplateSpec.Spec.Containers = append(runner.Spec.PodTemplateSpec.Spec.Containers, corev1.Container{Name: dindContainerName, Image: "docker:dind"})
+	pod = b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}})
+	require.NotNil(t, pod.Spec.ShareProcessNamespace)
+	assert.True(t, *pod.Spec.ShareProcessNamespace, "The dind container must see the runner processes")
+	for _, c := range pod.Spec.Containers {
+		if c.Name == dindContainerName {
+			require.NotNil(t, c.Lifecycle)
+			require.NotNil(t, c.Lifecycle.PreStop)
+			assert.Contains(t, c.Lifecycle.PreStop.Exec.Command[2], `"$i" -lt 630`, "The dind container waits for the runner to cancel the job too")
+			assert.Contains(t, c.Lifecycle.PreStop.Exec.Command[2], "Runner[.]Listener")
+		}
+	}
+
+	optOut := false
+	runner.Spec.PodTemplateSpec.Spec.ShareProcessNamespace = &optOut
+	pod = b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}})
+	assert.False(t, *pod.Spec.ShareProcessNamespace)
+	for _, c := range pod.Spec.Containers {
+		if c.Name == dindContainerName {
+			assert.Nil(t, c.Lifecycle, "The dind container can't see the runner without a shared process namespace")
+		}
+	}
+	runner.Spec.PodTemplateSpec.Spec.ShareProcessNamespace = nil
+
+	runner.Spec.JobCompletionTimeout = nil
+	pod = b.newEphemeralRunnerPod(context.Background(), runner, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}})
+	assert.Nil(t, pod.Spec.TerminationGracePeriodSeconds)
+	assert.NotContains(t, pod.Annotations, AnnotationKeyJobCompletionTimeout)
+}
+
+func TestValidateInitContainers(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			Template:       newTestEphemeralRunner().Spec.PodTemplateSpec,
+			InitContainers: []corev1.Container{{Name: "fetch-secrets"}},
+		},
+	}
+	assert.NoError(t, validateInitContainers(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.InitContainers = append(autoscalingRunnerSet.Spec.InitContainers, corev1.Container{Name: "fetch-secrets"})
+	assert.Error(t, validateInitContainers(autoscalingRunnerSet), "Duplicate names")
+
+	autoscalingRunnerSet.Spec.InitContainers = []corev1.Container{{Name: "sidecar"}}
+	assert.Error(t, validateInitContainers(autoscalingRunnerSet), "Name of a container of the template")
+
+	autoscalingRunnerSet.Spec.InitContainers = []corev1.Container{{}}
+	assert.Error(t, validateInitContainers(autoscalingRunnerSet), "No name")
+}
@@ -0,0 +1,88 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	defaultRetainFailedPodsDuration = time.Hour
+	defaultRetainFailedPodsCount    = 1
+)
+
+// retainFailedPod keeps the failed pod of the runner instead of deleting it, when the runner retains failed pods
+// and fewer than the count of them are retained for its runner set. It returns whether the pod is retained.
+// Pending pods are never retained, since they could still start the runner.
+func (r *EphemeralRunnerReconciler) retainFailedPod(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) (bool, error) {
+	retain := ephemeralRunner.Spec.RetainFailedPods
+	if retain == nil || pod.Status.Phase == corev1.PodPending {
+		return false, nil
+	}
+
+	group := retainedFailedPodGroup(ephemeralRunner)
+	retained := new(corev1.PodList)
+	if err := r.List(ctx, retained, client.InNamespace(pod.Namespace), client.MatchingLabels{LabelKeyRetainedFailedPod: group}); err != nil {
+		return false, fmt.Errorf("failed to list the retained failed pods: %v", err)
+	}
+	count := defaultRetainFailedPodsCount
+	if retain.Count != nil {
+		count = *retain.Count
+	}
+	if len(retained.Items) >= count {
+		log.Info("Not retaining the failed pod since enough failed pods of the runner set are retained", "retained", len(retained.Items))
+		return false, nil
+	}
+
+	duration := defaultRetainFailedPodsDuration
+	if retain.Duration != nil && retain.Duration.Duration > 0 {
+		duration = retain.Duration.Duration
+	}
+	until := time.Now().Add(duration).UTC().Truncate(time.Second)
+
+	log.Info("Retaining the failed pod for debugging", "until", until)
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Labels == nil {
+			obj.Labels = make(map[string]string)
+		}
+		obj.Labels[LabelKeyRetainedFailedPod] = group
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string)
+		}
+		obj.Annotations[AnnotationKeyRetainedUntil] = until.Format(time.RFC3339)
+	}); err != nil {
+		return false, fmt.Errorf("failed to mark the failed pod as retained: %v", err)
+	}
+
+	r.Recorder.Eventf(ephemeralRunner, corev1.EventTypeWarning, "FailedPodRetained", "Retaining the failed pod %s for debugging until %s", pod.Name, until.Format(time.RFC3339))
+	return true, nil
+}
+
+// retainedFailedPodRemaining returns how long the failed pod is still retained for.
+// It returns false when the pod isn't retained.
+func retainedFailedPodRemaining(pod *corev1.Pod, now time.Time) (time.Duration, bool) {
+	value, ok := pod.Annotations[AnnotationKeyRetainedUntil]
+	if !ok {
+		return 0, false
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// An annotation that can't be parsed doesn't keep the runner from being retried.
+		return 0, true
+	}
+	return until.Sub(now), true
+}
+
+// retainedFailedPodGroup returns the name of the runner set the failed pods of the runner are counted for.
+func retainedFailedPodGroup(ephemeralRunner *v1alpha1.EphemeralRunner) string {
+	if owner := metav1.GetControllerOf(ephemeralRunner); owner != nil {
+		return owner.Name
+	}
+	return ephemeralRunner.Name
+}
@@ -0,0 +1,77 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRetainFailedPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	newRunner := func(name string) *v1alpha1.EphemeralRunner {
+		controller := true
+		return &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "EphemeralRunnerSet", Name: "arc-abcde", Controller: &controller}},
+			},
+			Spec: v1alpha1.EphemeralRunnerSpec{
+				RetainFailedPods: &v1alpha1.RetainFailedPods{Duration: &metav1.Duration{Duration: 30 * time.Minute}},
+			},
+		}
+	}
+	newPod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	first, second := newRunner("first"), newRunner("second")
+	firstPod, secondPod, pendingPod := newPod("first", corev1.PodFailed), newPod("second", corev1.PodFailed), newPod("pending", corev1.PodPending)
+
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second, firstPod, secondPod, pendingPod).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	retained, err := r.retainFailedPod(ctx, first, pendingPod, logr.Discard())
+	require.NoError(t, err)
+	assert.False(t, retained, "Pending pods are not retained")
+
+	retained, err = r.retainFailedPod(ctx, first, firstPod, logr.Discard())
+	require.NoError(t, err)
+	assert.True(t, retained)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(firstPod), firstPod))
+	assert.Equal(t, "arc-abcde", firstPod.Labels[LabelKeyRetainedFailedPod])
+	remaining, ok := retainedFailedPodRemaining(firstPod, time.Now())
+	require.True(t, ok)
+	assert.InDelta(t, (30 * time.Minute).Seconds(), remaining.Seconds(), 5)
+
+	retained, err = r.retainFailedPod(ctx, second, secondPod, logr.Discard())
+	require.NoError(t, err)
+	assert.False(t, retained, "A single failed pod of the runner set is retained by default")
+
+	count := 2
+	second.Spec.RetainFailedPods.Count = &count
+	retained, err = r.retainFailedPod(ctx, second, secondPod, logr.Discard())
+	require.NoError(t, err)
+	assert.True(t, retained)
+
+	_, ok = retainedFailedPodRemaining(newPod("other", corev1.PodFailed), time.Now())
+	assert.False(t, ok)
+}
@@ -0,0 +1,111 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// progressDeadline returns how long the runners of a new runner set may take to get running,
+// or 0 when rollouts are not rolled back automatically.
+func progressDeadline(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) time.Duration {
+	strategy := autoscalingRunnerSet.Spec.UpdateStrategy
+	if strategy == nil || strategy.ProgressDeadlineSeconds == nil || *strategy.ProgressDeadlineSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(*strategy.ProgressDeadlineSeconds) * time.Second
+}
+
+// rolloutRolledBack reports whether the rollout of the given runner spec hash missed its progress deadline,
+// in which case the runner spec is not rolled out again.
+func rolloutRolledBack(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, specHash string) bool {
+	rolledBack := autoscalingRunnerSet.Status.RolledBackRunnerSpecHash
+	return rolledBack != "" && rolledBack == specHash
+}
+
+// hasServingRunnerSet reports whether any of the runner sets is not being deleted.
+func hasServingRunnerSet(runnerSets []v1alpha1.EphemeralRunnerSet) bool {
+	for i := range runnerSets {
+		if runnerSets[i].DeletionTimestamp.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// rolloutStalled reports whether none of the runners of the latest runner set got running within the
+// progress deadline. The deadline starts with the oldest runner of the runner set, so that a runner set
+// without demand for runners never stalls. Otherwise, it returns when to check again.
+func (r *AutoscalingRunnerSetReconciler) rolloutStalled(ctx context.Context, latestRunnerSet *v1alpha1.EphemeralRunnerSet, deadline time.Duration, now time.Time) (bool, time.Duration, error) {
+	status := latestRunnerSet.Status
+	if status.RunningReplicas > 0 || status.SucceededJobs > 0 || status.FailedJobs > 0 {
+		return false, 0, nil
+	}
+
+	runners := new(v1alpha1.EphemeralRunnerList)
+	if err := r.List(ctx, runners, client.InNamespace(latestRunnerSet.Namespace), client.MatchingFields{ephemeralRunnerSetReconcilerOwnerKey: latestRunnerSet.Name}); err != nil {
+		return false, 0, fmt.Errorf("failed to list ephemeral runners of the latest runner set: %v", err)
+	}
+
+	var oldest time.Time
+	for _, runner := range runners.Items {
+		if runner.Status.Phase == corev1.PodRunning || runner.Status.Phase == corev1.PodSucceeded {
+			return false, 0, nil
+		}
+		if oldest.IsZero() || runner.CreationTimestamp.Time.Before(oldest) {
+			oldest = runner.CreationTimestamp.Time
+		}
+	}
+	if oldest.IsZero() {
+		return false, 0, nil
+	}
+
+	if waited := now.Sub(oldest); waited < deadline {
+		return false, deadline - waited, nil
+	}
+	return true, 0, nil
+}
+
+// rollBackRollout deletes the latest runner set, so that the previous one becomes the latest runner set again,
+// and records the runner spec hash of the deleted one with the RolledBack condition.
+func (r *AutoscalingRunnerSetReconciler) rollBackRollout(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, latestRunnerSet *v1alpha1.EphemeralRunnerSet, deadline time.Duration, logger logr.Logger) error {
+	logger.Info("Latest runner set did not get a running runner within the progress deadline. Rolling it back", "name", latestRunnerSet.Name, "progressDeadline", deadline)
+	if err := r.deleteEphemeralRunnerSets(ctx, []v1alpha1.EphemeralRunnerSet{*latestRunnerSet}, logger); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Runner set %s did not get a running runner within %s. The previous runner set serves the jobs until the runner spec changes", latestRunnerSet.Name, deadline)
+	if err := patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		obj.Status.RolledBackRunnerSpecHash = latestRunnerSet.Labels[LabelKeyRunnerSpecHash]
+		meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.AutoscalingRunnerSetConditionRolledBack,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.RolledBackReasonProgressDeadlineExceeded,
+			Message: message,
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to update autoscaling runner set status with the rollback: %v", err)
+	}
+
+	r.Recorder.Event(autoscalingRunnerSet, corev1.EventTypeWarning, "RolloutRolledBack", message)
+	return nil
+}
+
+// clearRollback forgets a rolled back runner spec once a new one rolls out.
+func (r *AutoscalingRunnerSetReconciler) clearRollback(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	if autoscalingRunnerSet.Status.RolledBackRunnerSpecHash == "" &&
+		meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRolledBack) == nil {
+		return nil
+	}
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		obj.Status.RolledBackRunnerSpecHash = ""
+		meta.RemoveStatusCondition(&obj.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRolledBack)
+	})
+}
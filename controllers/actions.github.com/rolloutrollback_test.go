@@ -0,0 +1,134 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestRolloutStalled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	// Creation timestamps are stored with a precision of seconds.
+	now := time.Now().Truncate(time.Second)
+	latestRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc-new", Namespace: "default", UID: "new"},
+	}
+	newRunner := func(name string, age time.Duration, phase corev1.PodPhase) *v1alpha1.EphemeralRunner {
+		runner := &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Status: v1alpha1.EphemeralRunnerStatus{Phase: phase},
+		}
+		require.NoError(t, controllerutil.SetControllerReference(latestRunnerSet, runner, scheme))
+		return runner
+	}
+
+	tests := map[string]struct {
+		status      v1alpha1.EphemeralRunnerSetStatus
+		runners     []client.Object
+		wantStalled bool
+		wantRecheck time.Duration
+	}{
+		"no runners": {},
+		"pending runners within the deadline": {
+			runners:     []client.Object{newRunner("a", 2*time.Minute, corev1.PodPending), newRunner("b", time.Minute, corev1.PodPending)},
+			wantRecheck: 3 * time.Minute,
+		},
+		"pending runners past the deadline": {
+			runners:     []client.Object{newRunner("a", 6*time.Minute, corev1.PodPending), newRunner("b", time.Minute, corev1.PodFailed)},
+			wantStalled: true,
+		},
+		"a running runner": {
+			runners: []client.Object{newRunner("a", 6*time.Minute, corev1.PodPending), newRunner("b", time.Minute, corev1.PodRunning)},
+		},
+		"completed jobs": {
+			status:  v1alpha1.EphemeralRunnerSetStatus{SucceededJobs: 1},
+			runners: []client.Object{newRunner("a", 6*time.Minute, corev1.PodPending)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := crfake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(tc.runners...).
+				WithIndex(&v1alpha1.EphemeralRunner{}, ephemeralRunnerSetReconcilerOwnerKey, func(o client.Object) []string {
+					owner := metav1.GetControllerOf(o)
+					if owner == nil {
+						return nil
+					}
+					return []string{owner.Name}
+				}).
+				Build()
+			r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+
+			runnerSet := latestRunnerSet.DeepCopy()
+			runnerSet.Status = tc.status
+			stalled, recheck, err := r.rolloutStalled(context.Background(), runnerSet, 5*time.Minute, now)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantStalled, stalled)
+			assert.Equal(t, tc.wantRecheck, recheck)
+		})
+	}
+}
+
+func TestRollBackRollout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "default"},
+	}
+	latestRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "arc-new",
+			Namespace: "default",
+			Labels:    map[string]string{LabelKeyRunnerSpecHash: "new"},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, latestRunnerSet).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	require.NoError(t, r.rollBackRollout(ctx, autoscalingRunnerSet, latestRunnerSet, 10*time.Minute, logr.Discard()))
+
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc-new"}, new(v1alpha1.EphemeralRunnerSet))))
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	assert.Equal(t, "new", autoscalingRunnerSet.Status.RolledBackRunnerSpecHash)
+	assert.True(t, rolloutRolledBack(autoscalingRunnerSet, "new"))
+	assert.False(t, rolloutRolledBack(autoscalingRunnerSet, "newer"))
+	condition := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRolledBack)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, v1alpha1.RolledBackReasonProgressDeadlineExceeded, condition.Reason)
+	assert.Contains(t, <-recorder.Events, "RolloutRolledBack")
+
+	require.NoError(t, r.clearRollback(ctx, autoscalingRunnerSet))
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "arc"}, autoscalingRunnerSet))
+	assert.Empty(t, autoscalingRunnerSet.Status.RolledBackRunnerSpecHash)
+	assert.Nil(t, meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRolledBack))
+}
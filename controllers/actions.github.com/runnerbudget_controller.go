@@ -0,0 +1,249 @@
+/*
+Copyright 2020 The actions-runner-controller authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// runnerBudgetKey is the single request the runner budget is reconciled with,
+// since every change affects the shares of all scale sets.
+var runnerBudgetKey = types.NamespacedName{Name: "runner-budget"}
+
+// RunnerBudgetReconciler enforces a global maximum number of runners across all AutoscalingRunnerSets.
+//
+// When the runners desired by all scale sets exceed MaxRunners, the budget is distributed by
+// weighted fair share: every scale set gets runners in proportion to its FairShareWeight, and
+// the share a scale set doesn't need goes to the others. The share of a scale set is enforced
+// with the BudgetReplicas of its EphemeralRunnerSets, oldest first.
+type RunnerBudgetReconciler struct {
+	client.Client
+	Log logr.Logger
+
+	// MaxRunners is the global runner budget. Zero disables the budget.
+	MaxRunners int
+	// Config holds the settings of the controller config map, which take precedence over MaxRunners.
+	Config *ControllerConfig
+}
+
+// +kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets,verbs=get;list;watch;patch
+
+func (r *RunnerBudgetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	maxRunners := r.Config.globalMaxRunners(r.MaxRunners)
+	log := r.Log.WithValues("maxRunners", maxRunners)
+
+	runnerSets := new(v1alpha1.EphemeralRunnerSetList)
+	if err := r.List(ctx, runnerSets); err != nil {
+		log.Error(err, "Failed to list ephemeral runner sets")
+		return ctrl.Result{}, err
+	}
+
+	autoscalingRunnerSets := new(v1alpha1.AutoscalingRunnerSetList)
+	if err := r.List(ctx, autoscalingRunnerSets); err != nil {
+		log.Error(err, "Failed to list autoscaling runner sets")
+		return ctrl.Result{}, err
+	}
+
+	budgets := runnerSetBudgets(maxRunners, autoscalingRunnerSets.Items, runnerSets.Items)
+	for i := range runnerSets.Items {
+		runnerSet := &runnerSets.Items[i]
+		budget := budgets[client.ObjectKeyFromObject(runnerSet)]
+		if reflect.DeepEqual(budget, runnerSet.Spec.BudgetReplicas) {
+			continue
+		}
+
+		log.Info("Updating runner budget of ephemeral runner set", "ephemeralRunnerSet", client.ObjectKeyFromObject(runnerSet), "budgetReplicas", budget)
+		if err := patch(ctx, r.Client, runnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.BudgetReplicas = budget
+		}); err != nil {
+			log.Error(err, "Failed to update runner budget of ephemeral runner set", "ephemeralRunnerSet", client.ObjectKeyFromObject(runnerSet))
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// Enabled reports whether a budget is set when the controller starts, by MaxRunners or the controller config map.
+// The budget is only reconciled when it is, so that a controller without a budget doesn't watch every runner set.
+func (r *RunnerBudgetReconciler) Enabled() bool {
+	return r.Config.globalMaxRunners(r.MaxRunners) > 0
+}
+
+// ClearBudgets removes the BudgetReplicas left on the ephemeral runner sets by a controller that ran with a budget.
+// It runs once when the budget is disabled.
+func (r *RunnerBudgetReconciler) ClearBudgets(ctx context.Context) error {
+	runnerSets := new(v1alpha1.EphemeralRunnerSetList)
+	if err := r.List(ctx, runnerSets); err != nil {
+		return fmt.Errorf("failed to list ephemeral runner sets: %v", err)
+	}
+
+	for i := range runnerSets.Items {
+		runnerSet := &runnerSets.Items[i]
+		if runnerSet.Spec.BudgetReplicas == nil {
+			continue
+		}
+
+		r.Log.Info("Removing runner budget of ephemeral runner set", "ephemeralRunnerSet", client.ObjectKeyFromObject(runnerSet))
+		if err := patch(ctx, r.Client, runnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.BudgetReplicas = nil
+		}); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to remove runner budget of ephemeral runner set %s: %v", client.ObjectKeyFromObject(runnerSet), err)
+		}
+	}
+	return nil
+}
+
+// runnerSetBudgets returns the BudgetReplicas of each ephemeral runner set, which is nil for all of them
+// when the budget is disabled or covers the demand.
+func runnerSetBudgets(maxRunners int, autoscalingRunnerSets []v1alpha1.AutoscalingRunnerSet, runnerSets []v1alpha1.EphemeralRunnerSet) map[types.NamespacedName]*int {
+	if maxRunners <= 0 {
+		return nil
+	}
+
+	weights := make(map[types.NamespacedName]int, len(autoscalingRunnerSets))
+	for _, ars := range autoscalingRunnerSets {
+		weight := 1
+		if ars.Spec.FairShareWeight != nil && *ars.Spec.FairShareWeight > 0 {
+			weight = *ars.Spec.FairShareWeight
+		}
+		weights[client.ObjectKeyFromObject(&ars)] = weight
+	}
+
+	owned := make(map[types.NamespacedName][]*v1alpha1.EphemeralRunnerSet)
+	demands := make(map[types.NamespacedName]int)
+	totalDemand := 0
+	for i := range runnerSets {
+		runnerSet := &runnerSets[i]
+		owner := metav1.GetControllerOf(runnerSet)
+		if owner == nil || !runnerSet.DeletionTimestamp.IsZero() {
+			continue
+		}
+		key := types.NamespacedName{Namespace: runnerSet.Namespace, Name: owner.Name}
+		owned[key] = append(owned[key], runnerSet)
+		demands[key] += runnerSetDemand(runnerSet)
+		totalDemand += runnerSetDemand(runnerSet)
+	}
+
+	if totalDemand <= maxRunners {
+		return nil
+	}
+
+	shares := fairShares(maxRunners, demands, weights)
+
+	budgets := make(map[types.NamespacedName]*int)
+	for key, sets := range owned {
+		sort.Slice(sets, func(i, j int) bool {
+			if !sets[i].CreationTimestamp.Equal(&sets[j].CreationTimestamp) {
+				return sets[i].CreationTimestamp.Before(&sets[j].CreationTimestamp)
+			}
+			return sets[i].Name < sets[j].Name
+		})
+
+		// Older runner sets are being rolled over and hold the busy runners, so they keep their share first.
+		remaining := shares[key]
+		for _, runnerSet := range sets {
+			budget := runnerSetDemand(runnerSet)
+			if budget > remaining {
+				budget = remaining
+			}
+			remaining -= budget
+			budgets[client.ObjectKeyFromObject(runnerSet)] = &budget
+		}
+	}
+
+	return budgets
+}
+
+// runnerSetDemand is the number of runners the runner set wants, regardless of the budget.
+// The reserved replicas and the warm pool count as well, since they run pods too. The busy runners the warm pool
+// is kept on top of aren't known here, the replicas requested by the listener stand for them.
+func runnerSetDemand(runnerSet *v1alpha1.EphemeralRunnerSet) int {
+	unbudgeted := *runnerSet
+	unbudgeted.Spec.BudgetReplicas = nil
+	return desiredReplicas(&unbudgeted, runnerSet.Spec.Replicas)
+}
+
+// fairShares distributes the budget by weighted max-min fairness: runners are handed out one at a time
+// to the consumer with the lowest share relative to its weight that still wants more.
+func fairShares(budget int, demands map[types.NamespacedName]int, weights map[types.NamespacedName]int) map[types.NamespacedName]int {
+	keys := make([]types.NamespacedName, 0, len(demands))
+	for key := range demands {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	weightOf := func(key types.NamespacedName) int {
+		if weight, ok := weights[key]; ok {
+			return weight
+		}
+		return 1
+	}
+
+	shares := make(map[types.NamespacedName]int, len(demands))
+	for ; budget > 0; budget-- {
+		next := -1
+		for i, key := range keys {
+			if shares[key] >= demands[key] {
+				continue
+			}
+			// Compare (share+1)/weight without floating point.
+			if next < 0 || (shares[key]+1)*weightOf(keys[next]) < (shares[keys[next]]+1)*weightOf(key) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		shares[keys[next]]++
+	}
+
+	return shares
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RunnerBudgetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enqueueBudget := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: runnerBudgetKey}}
+	})
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("runner-budget-controller").
+		Watches(&source.Kind{Type: &v1alpha1.EphemeralRunnerSet{}}, enqueueBudget).
+		Watches(&source.Kind{Type: &v1alpha1.AutoscalingRunnerSet{}}, enqueueBudget)
+
+	if r.Config != nil {
+		// The budget is rebalanced when the settings change.
+		b = b.Watches(&source.Channel{Source: r.Config.subscribe()}, enqueueBudget)
+	}
+
+	return b.Complete(r)
+}
@@ -0,0 +1,160 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunnerBudgetReconciler(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	created := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	newAutoscalingRunnerSet := func(name string, weight *int) *v1alpha1.AutoscalingRunnerSet {
+		return &v1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			Spec:       v1alpha1.AutoscalingRunnerSetSpec{FairShareWeight: weight},
+		}
+	}
+	newRunnerSet := func(name string, owner *v1alpha1.AutoscalingRunnerSet, creationTimestamp metav1.Time, replicas int) *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: creationTimestamp,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(owner, v1alpha1.GroupVersion.WithKind("AutoscalingRunnerSet")),
+				},
+			},
+			Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: replicas},
+		}
+	}
+
+	small := newAutoscalingRunnerSet("small", nil)
+	large := newAutoscalingRunnerSet("large", intPtr(3))
+	idle := newAutoscalingRunnerSet("idle", nil)
+
+	objects := []client.Object{
+		small,
+		large,
+		idle,
+		newRunnerSet("small-1", small, created, 10),
+		// large is rolling over to a new runner set, the old one keeps its runners first.
+		newRunnerSet("large-old", large, created, 4),
+		newRunnerSet("large-new", large, metav1.NewTime(created.Add(time.Minute)), 10),
+		newRunnerSet("idle-1", idle, created, 1),
+	}
+
+	budgetReplicas := func(t *testing.T, c client.Client) map[string]*int {
+		runnerSets := new(v1alpha1.EphemeralRunnerSetList)
+		require.NoError(t, c.List(context.Background(), runnerSets))
+		budgets := make(map[string]*int)
+		for _, runnerSet := range runnerSets.Items {
+			budgets[runnerSet.Name] = runnerSet.Spec.BudgetReplicas
+		}
+		return budgets
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	t.Run("distributes the budget by weight", func(t *testing.T) {
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		r := &RunnerBudgetReconciler{Client: c, Log: logr.Discard(), MaxRunners: 13}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: runnerBudgetKey})
+		require.NoError(t, err)
+
+		// idle only needs 1 runner, the remaining 12 are split 1:3 between small and large.
+		assert.Equal(t, map[string]*int{
+			"small-1":   intPtr(3),
+			"large-old": intPtr(4),
+			"large-new": intPtr(5),
+			"idle-1":    intPtr(1),
+		}, budgetReplicas(t, c))
+	})
+
+	t.Run("removes the caps when the budget covers the demand", func(t *testing.T) {
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		r := &RunnerBudgetReconciler{Client: c, Log: logr.Discard(), MaxRunners: 13}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: runnerBudgetKey})
+		require.NoError(t, err)
+
+		r.MaxRunners = 25
+		_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: runnerBudgetKey})
+		require.NoError(t, err)
+
+		for name, budget := range budgetReplicas(t, c) {
+			assert.Nil(t, budget, name)
+		}
+	})
+
+	t.Run("counts the reserved and warm runners", func(t *testing.T) {
+		reserved := newRunnerSet("small-1", small, created, 2)
+		reserved.Spec.ReservedReplicas = 6
+		warm := newRunnerSet("large-new", large, created, 2)
+		warm.Spec.WarmReplicas = 4
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(small, large, reserved, warm).Build()
+		r := &RunnerBudgetReconciler{Client: c, Log: logr.Discard(), MaxRunners: 8}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: runnerBudgetKey})
+		require.NoError(t, err)
+
+		// The listeners only request 4 runners, but 12 are wanted with the reserved and warm ones.
+		assert.Equal(t, map[string]*int{
+			"small-1":   intPtr(2),
+			"large-new": intPtr(6),
+		}, budgetReplicas(t, c))
+	})
+
+	t.Run("disabled budget", func(t *testing.T) {
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		r := &RunnerBudgetReconciler{Client: c, Log: logr.Discard()}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: runnerBudgetKey})
+		require.NoError(t, err)
+
+		for name, budget := range budgetReplicas(t, c) {
+			assert.Nil(t, budget, name)
+		}
+	})
+
+	t.Run("budgets left by a previous controller are cleared", func(t *testing.T) {
+		c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		r := &RunnerBudgetReconciler{Client: c, Log: logr.Discard(), MaxRunners: 13}
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: runnerBudgetKey})
+		require.NoError(t, err)
+
+		r.MaxRunners = 0
+		assert.False(t, r.Enabled())
+		require.NoError(t, r.ClearBudgets(context.Background()))
+
+		for name, budget := range budgetReplicas(t, c) {
+			assert.Nil(t, budget, name)
+		}
+	})
+}
+
+func TestFairShares(t *testing.T) {
+	a := types.NamespacedName{Namespace: "default", Name: "a"}
+	b := types.NamespacedName{Namespace: "default", Name: "b"}
+
+	shares := fairShares(10, map[types.NamespacedName]int{a: 20, b: 20}, map[types.NamespacedName]int{a: 1, b: 4})
+	assert.Equal(t, map[types.NamespacedName]int{a: 2, b: 8}, shares)
+
+	// The share b doesn't need goes to a.
+	shares = fairShares(10, map[types.NamespacedName]int{a: 20, b: 3}, map[types.NamespacedName]int{a: 1, b: 4})
+	assert.Equal(t, map[types.NamespacedName]int{a: 7, b: 3}, shares)
+}
@@ -0,0 +1,166 @@
+package actionsgithubcom
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/pkg/objectstore"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultRunnerDiagnosticsTimeout bounds the collection and the upload of the diagnostics of a failed runner pod.
+	DefaultRunnerDiagnosticsTimeout = time.Minute
+
+	// runnerPrintLogToStdoutEnvName makes the runner print its _diag logs to stdout, which keeps them in the
+	// container logs once the runner container exits.
+	runnerPrintLogToStdoutEnvName = "ACTIONS_RUNNER_PRINT_LOG_TO_STDOUT"
+
+	// runnerDiagnosticsLogLimitBytes caps the size of each container log kept in the diagnostics.
+	runnerDiagnosticsLogLimitBytes = 10 << 20
+)
+
+// Reasons of the events of runner diagnostics uploads.
+const (
+	reasonRunnerDiagnosticsUploaded     = "RunnerDiagnosticsUploaded"
+	reasonRunnerDiagnosticsUploadFailed = "RunnerDiagnosticsUploadFailed"
+)
+
+// RunnerDiagnostics uploads the diagnostics of the failed runner pods to an object store before they are deleted.
+type RunnerDiagnostics struct {
+	Store objectstore.Store
+	// Clientset reads the container logs and the events of the runner pods.
+	Clientset kubernetes.Interface
+	// Timeout bounds the collection and the upload of the diagnostics of a pod.
+	// Defaults to DefaultRunnerDiagnosticsTimeout.
+	Timeout time.Duration
+}
+
+// runnerDiagnosticsKey is the key of the diagnostics of a runner pod in the object store.
+func runnerDiagnosticsKey(pod *corev1.Pod) string {
+	return fmt.Sprintf("%s/%s/%s.tar.gz", pod.Namespace, pod.Name, pod.UID)
+}
+
+// uploadRunnerDiagnostics uploads the diagnostics of the failed runner pod. Failures are reported with an event,
+// and don't prevent the pod from being deleted.
+func (r *EphemeralRunnerReconciler) uploadRunnerDiagnostics(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) {
+	timeout := r.Diagnostics.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRunnerDiagnosticsTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	key := runnerDiagnosticsKey(pod)
+	log.Info("Uploading the diagnostics of the failed runner pod", "key", key)
+
+	archive, err := r.Diagnostics.collect(ctx, pod)
+	if err == nil {
+		err = r.Diagnostics.Store.Put(ctx, key, archive, "application/gzip")
+	}
+	if err != nil {
+		log.Error(err, "Failed to upload the diagnostics of the failed runner pod", "key", key)
+		r.Recorder.Eventf(ephemeralRunner, corev1.EventTypeWarning, reasonRunnerDiagnosticsUploadFailed, "Failed to upload the diagnostics of pod %s: %v", pod.UID, err)
+		return
+	}
+
+	r.Recorder.Eventf(ephemeralRunner, corev1.EventTypeNormal, reasonRunnerDiagnosticsUploaded, "Uploaded the diagnostics of pod %s to %s", pod.UID, key)
+}
+
+// collect archives the pod, its events and the logs of its containers. The logs of the containers that haven't
+// started are skipped.
+func (d *RunnerDiagnostics) collect(ctx context.Context, pod *corev1.Pod) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	modTime := time.Now()
+
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	podJSON, err := json.MarshalIndent(pod, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pod: %v", err)
+	}
+	if err := add("pod.json", podJSON); err != nil {
+		return nil, err
+	}
+
+	events, err := d.Clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.uid=" + string(pod.UID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events of the pod: %v", err)
+	}
+	eventsJSON, err := json.MarshalIndent(events.Items, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal events: %v", err)
+	}
+	if err := add("events.json", eventsJSON); err != nil {
+		return nil, err
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.LastTerminationState.Terminated == nil {
+			continue
+		}
+
+		limitBytes := int64(runnerDiagnosticsLogLimitBytes)
+		stream, err := d.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:  status.Name,
+			LimitBytes: &limitBytes,
+		}).Stream(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs of container %q: %v", status.Name, err)
+		}
+		logs, err := io.ReadAll(stream)
+		stream.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read logs of container %q: %v", status.Name, err)
+		}
+		if err := add("logs/"+status.Name+".log", logs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setRunnerPrintLogToStdout makes the runner container print its _diag logs to stdout, unless the pod template
+// sets it already.
+func setRunnerPrintLogToStdout(pod *corev1.Pod) {
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		for _, env := range c.Env {
+			if env.Name == runnerPrintLogToStdoutEnvName {
+				return
+			}
+		}
+		c.Env = append(c.Env, corev1.EnvVar{Name: runnerPrintLogToStdoutEnvName, Value: "true"})
+	}
+}
@@ -0,0 +1,123 @@
+package actionsgithubcom
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeObjectStore struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (f *fakeObjectStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.objects[key] = body
+	return nil
+}
+
+func TestDeletePodAsFailed_UploadsRunnerDiagnostics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	newObjects := func() (*v1alpha1.EphemeralRunner, *corev1.Pod) {
+		ephemeralRunner := &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default", UID: "pod-uid"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: "init-dind-externals", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: EphemeralRunnerContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+					{Name: "dind", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+				},
+			},
+		}
+		return ephemeralRunner, pod
+	}
+
+	ephemeralRunner, pod := newObjects()
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, pod).Build()
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	recorder := record.NewFakeRecorder(2)
+	r := &EphemeralRunnerReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Recorder: recorder,
+		Diagnostics: &RunnerDiagnostics{
+			Store:     store,
+			Clientset: k8sfake.NewSimpleClientset(pod),
+		},
+	}
+
+	require.NoError(t, r.deletePodAsFailed(context.Background(), ephemeralRunner, pod, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(pod), new(corev1.Pod))))
+	assert.Contains(t, <-recorder.Events, reasonRunnerDiagnosticsUploaded)
+
+	archive, ok := store.objects["default/runner/pod-uid.tar.gz"]
+	require.True(t, ok)
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{"pod.json", "events.json", "logs/init-dind-externals.log", "logs/runner.log"}, names, "The logs of the containers that haven't started are skipped")
+
+	// Upload failures don't prevent the pod from being deleted.
+	ephemeralRunner, pod = newObjects()
+	c = crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, pod).Build()
+	r.Client = c
+	r.Diagnostics.Store = &fakeObjectStore{err: errors.New("access denied")}
+
+	require.NoError(t, r.deletePodAsFailed(context.Background(), ephemeralRunner, pod, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(pod), new(corev1.Pod))))
+	assert.Contains(t, <-recorder.Events, reasonRunnerDiagnosticsUploadFailed)
+}
+
+func TestSetRunnerPrintLogToStdout(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: EphemeralRunnerContainerName}, {Name: "dind"}},
+		},
+	}
+	setRunnerPrintLogToStdout(pod)
+	assert.Equal(t, []corev1.EnvVar{{Name: runnerPrintLogToStdoutEnvName, Value: "true"}}, pod.Spec.Containers[0].Env)
+	assert.Empty(t, pod.Spec.Containers[1].Env)
+
+	pod.Spec.Containers[0].Env[0].Value = "false"
+	setRunnerPrintLogToStdout(pod)
+	assert.Equal(t, []corev1.EnvVar{{Name: runnerPrintLogToStdoutEnvName, Value: "false"}}, pod.Spec.Containers[0].Env, "The pod template wins")
+}
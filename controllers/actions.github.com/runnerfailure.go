@@ -0,0 +1,147 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// registrationBlockedRetryInterval is how often the registration of a runner is retried while it fails
+// for a reason retrying won't fix, e.g. until the credentials are fixed.
+const registrationBlockedRetryInterval = 10 * time.Minute
+
+// imagePullWaitingReasons are the reasons of the containers waiting for an image that can't be pulled.
+var imagePullWaitingReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// imagePullWaitingState returns the state of the first container of the pod, init containers included,
+// waiting for an image that can't be pulled.
+func imagePullWaitingState(pod *corev1.Pod) *corev1.ContainerStateWaiting {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			if waiting := statuses[i].State.Waiting; waiting != nil && imagePullWaitingReasons[waiting.Reason] {
+				return waiting
+			}
+		}
+	}
+	return nil
+}
+
+// podFailureReason classifies the failure of a runner pod.
+func podFailureReason(pod *corev1.Pod) v1alpha1.EphemeralRunnerFailureReason {
+	if pod.Status.Reason == "Evicted" {
+		return v1alpha1.EphemeralRunnerFailureReasonEvicted
+	}
+
+	if cs := runnerContainerStatus(pod); cs != nil && cs.State.Terminated != nil {
+		switch {
+		case cs.State.Terminated.Reason == "OOMKilled":
+			return v1alpha1.EphemeralRunnerFailureReasonOOMKilled
+		case cs.State.Terminated.ExitCode != 0:
+			return v1alpha1.EphemeralRunnerFailureReasonRunnerFailed
+		default:
+			// The runner exited successfully without taking a job, and is still registered with the service.
+			return v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed
+		}
+	}
+
+	if imagePullWaitingState(pod) != nil {
+		return v1alpha1.EphemeralRunnerFailureReasonImagePullBackOff
+	}
+	if pod.Status.Phase == corev1.PodPending {
+		return v1alpha1.EphemeralRunnerFailureReasonPendingTimeout
+	}
+	return v1alpha1.EphemeralRunnerFailureReasonRunnerFailed
+}
+
+// recordRegistrationFailure records in the status that the runner can't be registered with the Actions service.
+func (r *EphemeralRunnerReconciler) recordRegistrationFailure(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, cause error, log logr.Logger) {
+	message := fmt.Sprintf("Failed to register the runner with the Actions service: %v", cause)
+	if ephemeralRunner.Status.FailureReason == v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed && ephemeralRunner.Status.Message == message {
+		return
+	}
+
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.FailureReason = v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed
+		obj.Status.Reason = string(v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed)
+		obj.Status.Message = message
+	}); err != nil {
+		log.Error(err, "Failed to record the registration failure in the ephemeral runner status")
+	}
+}
+
+// registrationBlockedReason returns the reason of the RegistrationBlocked condition when registering the runner
+// failed for a reason retrying won't fix, or "" when the registration should be retried: network errors,
+// server errors and rate limits. A rejected admin token is re-issued by the client before giving up.
+func registrationBlockedReason(err error) string {
+	gitHubAPIError := &actions.GitHubAPIError{}
+	if errors.As(err, &gitHubAPIError) && gitHubAPIError.StatusCode == http.StatusUnauthorized {
+		return v1alpha1.RegistrationBlockedReasonBadCredentials
+	}
+
+	runnerGroupNotFoundError := &actions.RunnerGroupNotFoundError{}
+	if errors.As(err, &runnerGroupNotFoundError) {
+		return v1alpha1.RegistrationBlockedReasonRunnerGroupNotFound
+	}
+
+	actionsError := &actions.ActionsError{}
+	if !errors.As(err, &actionsError) {
+		return ""
+	}
+	switch {
+	case actionsError.StatusCode == http.StatusUnauthorized:
+		return v1alpha1.RegistrationBlockedReasonBadCredentials
+	case strings.Contains(actionsError.ExceptionName, "RunnerGroupNotFound") || strings.Contains(actionsError.ExceptionName, "AgentPoolNotFound"):
+		return v1alpha1.RegistrationBlockedReasonRunnerGroupNotFound
+	case actionsError.StatusCode == http.StatusNotFound:
+		return v1alpha1.RegistrationBlockedReasonRunnerScaleSetNotFound
+	}
+	return ""
+}
+
+// blockRegistration surfaces the RegistrationBlocked condition on the runner, and retries the registration
+// only every registrationBlockedRetryInterval instead of backing off on the error.
+func (r *EphemeralRunnerReconciler) blockRegistration(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, reason string, cause error, log logr.Logger) (ctrl.Result, error) {
+	message := fmt.Sprintf("Failed to register the runner with the Actions service: %v", cause)
+	log.Info("Registration of the runner is blocked, retrying later", "reason", reason, "error", cause.Error(), "retryAfter", registrationBlockedRetryInterval)
+
+	condition := meta.FindStatusCondition(ephemeralRunner.Status.Conditions, v1alpha1.EphemeralRunnerConditionRegistrationBlocked)
+	if condition != nil && condition.Reason == reason && condition.Message == message {
+		return ctrl.Result{RequeueAfter: registrationBlockedRetryInterval}, nil
+	}
+
+	if condition == nil || condition.Reason != reason {
+		r.Recorder.Event(ephemeralRunner, corev1.EventTypeWarning, reason, message)
+	}
+
+	if err := patchSubResource(ctx, r.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+		obj.Status.FailureReason = v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed
+		obj.Status.Reason = reason
+		obj.Status.Message = message
+		meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.EphemeralRunnerConditionRegistrationBlocked,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+	}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update the registration blocked condition: %v", err)
+	}
+
+	return ctrl.Result{RequeueAfter: registrationBlockedRetryInterval}, nil
+}
@@ -0,0 +1,201 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPodFailureReason(t *testing.T) {
+	terminated := func(exitCode int32, reason string) corev1.PodStatus {
+		return corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: EphemeralRunnerContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Reason: reason}}},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		status corev1.PodStatus
+		want   v1alpha1.EphemeralRunnerFailureReason
+	}{
+		"evicted": {
+			status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"},
+			want:   v1alpha1.EphemeralRunnerFailureReasonEvicted,
+		},
+		"out of memory": {
+			status: terminated(137, "OOMKilled"),
+			want:   v1alpha1.EphemeralRunnerFailureReasonOOMKilled,
+		},
+		"non-zero exit code": {
+			status: terminated(1, "Error"),
+			want:   v1alpha1.EphemeralRunnerFailureReasonRunnerFailed,
+		},
+		"exited without a job": {
+			status: terminated(0, "Completed"),
+			want:   v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed,
+		},
+		"image pull": {
+			status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: "init", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+				},
+			},
+			want: v1alpha1.EphemeralRunnerFailureReasonImagePullBackOff,
+		},
+		"pending": {
+			status: corev1.PodStatus{Phase: corev1.PodPending},
+			want:   v1alpha1.EphemeralRunnerFailureReasonPendingTimeout,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, podFailureReason(&corev1.Pod{Status: tc.status}))
+		})
+	}
+}
+
+func TestEphemeralRunnerFailureStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default", UID: "pod-uid"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: EphemeralRunnerContainerName, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "manifest unknown"}}},
+			},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, pod).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme}
+
+	get := func() *v1alpha1.EphemeralRunner {
+		updated := new(v1alpha1.EphemeralRunner)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ephemeralRunner), updated))
+		return updated
+	}
+
+	require.NoError(t, r.updateRunStatusFromPod(context.Background(), ephemeralRunner, pod, logr.Discard()))
+	updated := get()
+	assert.Equal(t, v1alpha1.EphemeralRunnerFailureReasonImagePullBackOff, updated.Status.FailureReason)
+	assert.Equal(t, "ErrImagePull", updated.Status.Reason)
+	assert.Equal(t, "manifest unknown", updated.Status.Message)
+
+	// The failure reason is cleared once the image is pulled.
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	require.NoError(t, r.updateRunStatusFromPod(context.Background(), updated, pod, logr.Discard()))
+	updated = get()
+	assert.Empty(t, updated.Status.FailureReason)
+	assert.Equal(t, corev1.PodRunning, updated.Status.Phase)
+	assert.False(t, updated.Status.Ready, "The pod isn't ready yet")
+
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	require.NoError(t, r.updateRunStatusFromPod(context.Background(), updated, pod, logr.Discard()))
+	updated = get()
+	assert.True(t, updated.Status.Ready)
+
+	pod.Status.Phase = corev1.PodFailed
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled", Message: "killed"}}
+	require.NoError(t, r.deletePodAsFailed(context.Background(), updated, pod, logr.Discard()))
+	updated = get()
+	assert.Equal(t, v1alpha1.EphemeralRunnerFailureReasonOOMKilled, updated.Status.FailureReason)
+	require.NotNil(t, updated.Status.ExitCode)
+	assert.Equal(t, int32(137), *updated.Status.ExitCode)
+	assert.Equal(t, "killed", updated.Status.TerminationMessage)
+}
+
+func TestRegistrationBlockedReason(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want string
+	}{
+		"network error": {
+			err:  errors.New("dial tcp: connection refused"),
+			want: "",
+		},
+		"server error": {
+			err:  &actions.ActionsError{StatusCode: http.StatusServiceUnavailable},
+			want: "",
+		},
+		"rate limited": {
+			err:  &actions.RateLimitedError{},
+			want: "",
+		},
+		"bad credentials": {
+			err:  fmt.Errorf("failed to get runner registration token on refresh: %w", &actions.GitHubAPIError{StatusCode: http.StatusUnauthorized}),
+			want: v1alpha1.RegistrationBlockedReasonBadCredentials,
+		},
+		"rejected admin token": {
+			err:  &actions.ActionsError{StatusCode: http.StatusUnauthorized},
+			want: v1alpha1.RegistrationBlockedReasonBadCredentials,
+		},
+		"runner scale set not found": {
+			err:  &actions.ActionsError{StatusCode: http.StatusNotFound, ExceptionName: "RunnerScaleSetNotFoundException"},
+			want: v1alpha1.RegistrationBlockedReasonRunnerScaleSetNotFound,
+		},
+		"runner group not found": {
+			err:  &actions.RunnerGroupNotFoundError{Name: "runners"},
+			want: v1alpha1.RegistrationBlockedReasonRunnerGroupNotFound,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, registrationBlockedReason(tc.err))
+		})
+	}
+}
+
+func TestBlockRegistration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default"},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+
+	cause := &actions.GitHubAPIError{StatusCode: http.StatusUnauthorized}
+	result, err := r.blockRegistration(context.Background(), ephemeralRunner, v1alpha1.RegistrationBlockedReasonBadCredentials, cause, logr.Discard())
+	require.NoError(t, err, "Blocked registrations are not retried with a backoff")
+	assert.Equal(t, registrationBlockedRetryInterval, result.RequeueAfter)
+
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ephemeralRunner), updated))
+	assert.Equal(t, v1alpha1.EphemeralRunnerFailureReasonRegistrationFailed, updated.Status.FailureReason)
+	assert.Equal(t, v1alpha1.RegistrationBlockedReasonBadCredentials, updated.Status.Reason)
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.EphemeralRunnerConditionRegistrationBlocked)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, v1alpha1.RegistrationBlockedReasonBadCredentials, condition.Reason)
+	require.Len(t, recorder.Events, 1)
+
+	_, err = r.blockRegistration(context.Background(), updated, v1alpha1.RegistrationBlockedReasonBadCredentials, cause, logr.Discard())
+	require.NoError(t, err)
+	assert.Len(t, recorder.Events, 1, "The event is recorded once per reason")
+}
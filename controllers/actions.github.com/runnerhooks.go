@@ -0,0 +1,97 @@
+package actionsgithubcom
+
+import (
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// runnerHookScriptMode lets the hook scripts be executed directly, on top of being run by the runner.
+const runnerHookScriptMode int32 = 0o755
+
+// runnerHook is a hook script of the runner, with the environment variable pointing the runner at it
+// and the directory of the hooks volume it is mounted in.
+type runnerHook struct {
+	env    string
+	dir    string
+	script *v1alpha1.RunnerHookScript
+}
+
+func runnerHooks(hooks *v1alpha1.RunnerHooks) []runnerHook {
+	if hooks == nil {
+		return nil
+	}
+
+	var result []runnerHook
+	if s := hooks.JobStarted; s != nil && s.ConfigMapRef != "" && s.ConfigMapKey != "" {
+		result = append(result, runnerHook{env: EnvVarRunnerHookJobStarted, dir: "job-started", script: s})
+	}
+	if s := hooks.JobCompleted; s != nil && s.ConfigMapRef != "" && s.ConfigMapKey != "" {
+		result = append(result, runnerHook{env: EnvVarRunnerHookJobCompleted, dir: "job-completed", script: s})
+	}
+	return result
+}
+
+// addRunnerHooks mounts the hook scripts into the runner container and points the runner at them.
+// Hooks the pod template sets with the environment variables win.
+func addRunnerHooks(pod *corev1.Pod, hooks *v1alpha1.RunnerHooks) {
+	scripts := runnerHooks(hooks)
+	if len(scripts) == 0 {
+		return
+	}
+
+	windows := isWindowsPod(&pod.Spec)
+	mountPath := RunnerHooksMountPath
+	if windows {
+		mountPath = WindowsRunnerHooksMountPath
+	}
+
+	mode := runnerHookScriptMode
+	sources := make([]corev1.VolumeProjection, 0, len(scripts))
+	env := make([]corev1.EnvVar, 0, len(scripts))
+	for _, h := range scripts {
+		sources = append(sources, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: h.script.ConfigMapRef},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  h.script.ConfigMapKey,
+						Path: h.dir + "/" + h.script.ConfigMapKey,
+						Mode: &mode,
+					},
+				},
+			},
+		})
+
+		path := mountPath + "/" + h.dir + "/" + h.script.ConfigMapKey
+		if windows {
+			path = mountPath + `\` + h.dir + `\` + h.script.ConfigMapKey
+		}
+		env = append(env, corev1.EnvVar{Name: h.env, Value: path})
+	}
+
+	volumes := make([]corev1.Volume, 0, len(pod.Spec.Volumes)+1)
+	volumes = append(volumes, pod.Spec.Volumes...)
+	pod.Spec.Volumes = append(volumes, corev1.Volume{
+		Name: RunnerHooksVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+		},
+	})
+
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		mounts := make([]corev1.VolumeMount, 0, len(c.VolumeMounts)+1)
+		mounts = append(mounts, c.VolumeMounts...)
+		c.VolumeMounts = append(mounts, corev1.VolumeMount{
+			Name:      RunnerHooksVolumeName,
+			MountPath: mountPath,
+			ReadOnly:  true,
+		})
+		for _, e := range env {
+			c.Env = appendEnvIfMissing(c.Env, e)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/pkg/imagedigest"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultRunnerImageDigestRefreshInterval is how often the tag of a pinned runner image is resolved again
+// when the AutoscalingRunnerSet doesn't set its refresh interval.
+const DefaultRunnerImageDigestRefreshInterval = time.Hour
+
+// Reasons of the events of runner image digest pinning.
+const (
+	reasonRunnerImageDigestChanged          = "RunnerImageDigestChanged"
+	reasonRunnerImageDigestResolutionFailed = "RunnerImageDigestResolutionFailed"
+)
+
+// ImageDigestResolver resolves the tag of an image to the digest of its manifest.
+type ImageDigestResolver interface {
+	Resolve(ctx context.Context, image string, keychain imagedigest.Keychain) (string, error)
+}
+
+// reconcileRunnerImageDigest resolves the tag of the runner image to a digest and records it in the status,
+// which pins the runner pods of the next runner set to it. The tag is resolved again once the refresh
+// interval elapses, and immediately when the runner image changes. A new digest changes the runner set
+// spec hash, which rolls out a new runner set following the update strategy.
+// Resolution failures are reported with an event, and the runners keep the previous digest.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerImageDigest(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	key := types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: autoscalingRunnerSet.Name}
+	image := autoscalingRunnerSet.RunnerImage()
+
+	pinning := autoscalingRunnerSet.Spec.RunnerImageDigestPinning
+	// Images referenced by digest are pinned already.
+	if pinning == nil || !pinning.Enabled || image == "" || strings.Contains(image, "@") {
+		r.forgetRunnerImageDigestResolution(key)
+		if autoscalingRunnerSet.Status.ResolvedRunnerImage == "" && autoscalingRunnerSet.Status.RunnerImageDigest == "" {
+			return nil
+		}
+		return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.ResolvedRunnerImage = ""
+			obj.Status.RunnerImageDigest = ""
+		})
+	}
+
+	if !r.runnerImageDigestResolutionDue(key, image, runnerImageDigestRefreshInterval(autoscalingRunnerSet)) {
+		return nil
+	}
+
+	digest, err := r.resolveRunnerImageDigest(ctx, autoscalingRunnerSet, image)
+	if err != nil {
+		log.Error(err, "Failed to resolve the digest of the runner image", "image", image)
+		r.Recorder.Eventf(autoscalingRunnerSet, corev1.EventTypeWarning, reasonRunnerImageDigestResolutionFailed, "Failed to resolve the digest of %s: %v", image, err)
+		return nil
+	}
+
+	if image == autoscalingRunnerSet.Status.ResolvedRunnerImage && digest == autoscalingRunnerSet.Status.RunnerImageDigest {
+		return nil
+	}
+
+	log.Info("Pinning the runners to the digest of the runner image", "image", image, "digest", digest, "previousDigest", autoscalingRunnerSet.Status.RunnerImageDigest)
+	r.Recorder.Eventf(autoscalingRunnerSet, corev1.EventTypeNormal, reasonRunnerImageDigestChanged, "Pinning the runners to %s@%s", image, digest)
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		obj.Status.ResolvedRunnerImage = image
+		obj.Status.RunnerImageDigest = digest
+	})
+}
+
+func (r *AutoscalingRunnerSetReconciler) resolveRunnerImageDigest(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, image string) (string, error) {
+	keychain, err := r.runnerImageKeychain(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return "", err
+	}
+
+	resolver := r.ImageDigestResolver
+	if resolver == nil {
+		resolver = &imagedigest.Resolver{}
+	}
+	return resolver.Resolve(ctx, image, keychain)
+}
+
+// runnerImageKeychain returns the registry credentials of the image pull secrets of the runner pods.
+func (r *AutoscalingRunnerSetReconciler) runnerImageKeychain(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (imagedigest.Keychain, error) {
+	keychain := make(imagedigest.Keychain)
+	for _, ref := range runnerImagePullSecrets(autoscalingRunnerSet, &autoscalingRunnerSet.Spec.Template) {
+		secret := new(corev1.Secret)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("failed to get image pull secret %q: %v", ref.Name, err)
+		}
+		data, ok := secret.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			continue
+		}
+		credentials, err := imagedigest.KeychainFromDockerConfigJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image pull secret %q: %v", ref.Name, err)
+		}
+		// The first secret with credentials for a registry wins, as for the kubelet.
+		for registry, c := range credentials {
+			if _, ok := keychain[registry]; !ok {
+				keychain[registry] = c
+			}
+		}
+	}
+	return keychain, nil
+}
+
+// runnerImageDigestResolution is the last time the runner image of an AutoscalingRunnerSet was resolved.
+type runnerImageDigestResolution struct {
+	image string
+	time  time.Time
+}
+
+// runnerImageDigestResolutionDue reports whether the runner image of the given AutoscalingRunnerSet should be
+// resolved, and if so records the current time as the last resolution. A new runner image is due right away.
+func (r *AutoscalingRunnerSetReconciler) runnerImageDigestResolutionDue(key types.NamespacedName, image string, interval time.Duration) bool {
+	r.lastRunnerImageDigestResolutionMu.Lock()
+	defer r.lastRunnerImageDigestResolutionMu.Unlock()
+
+	if r.lastRunnerImageDigestResolution == nil {
+		r.lastRunnerImageDigestResolution = make(map[types.NamespacedName]runnerImageDigestResolution)
+	}
+
+	now := time.Now()
+	if last, ok := r.lastRunnerImageDigestResolution[key]; ok && last.image == image && now.Sub(last.time) < interval {
+		return false
+	}
+
+	r.lastRunnerImageDigestResolution[key] = runnerImageDigestResolution{image: image, time: now}
+	return true
+}
+
+func (r *AutoscalingRunnerSetReconciler) forgetRunnerImageDigestResolution(key types.NamespacedName) {
+	r.lastRunnerImageDigestResolutionMu.Lock()
+	defer r.lastRunnerImageDigestResolutionMu.Unlock()
+
+	delete(r.lastRunnerImageDigestResolution, key)
+}
+
+func runnerImageDigestRefreshInterval(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) time.Duration {
+	if pinning := autoscalingRunnerSet.Spec.RunnerImageDigestPinning; pinning != nil && pinning.RefreshInterval != nil && pinning.RefreshInterval.Duration > 0 {
+		return pinning.RefreshInterval.Duration
+	}
+	return DefaultRunnerImageDigestRefreshInterval
+}
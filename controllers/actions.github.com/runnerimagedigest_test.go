@@ -0,0 +1,148 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/pkg/imagedigest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeImageDigestResolver struct {
+	digest   string
+	err      error
+	calls    int
+	keychain imagedigest.Keychain
+}
+
+func (f *fakeImageDigestResolver) Resolve(ctx context.Context, image string, keychain imagedigest.Keychain) (string, error) {
+	f.calls++
+	f.keychain = keychain
+	return f.digest, f.err
+}
+
+func TestReconcileRunnerImageDigest(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ghcr", Namespace: "default"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"ghcr.io":{"username":"bot","password":"token"}}}`),
+		},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl: "https://github.com/owner/repo",
+			RunnerImageDigestPinning: &v1alpha1.RunnerImageDigestPinning{
+				Enabled:         true,
+				RefreshInterval: &metav1.Duration{Duration: time.Hour},
+			},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: pullSecret.Name}},
+					Containers:       []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/actions-runner:latest"}},
+				},
+			},
+		},
+	}
+
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(pullSecret, autoscalingRunnerSet).
+		Build()
+
+	resolver := &fakeImageDigestResolver{digest: "sha256:aaaa"}
+	recorder := record.NewFakeRecorder(4)
+	r := &AutoscalingRunnerSetReconciler{
+		Client:              c,
+		Scheme:              scheme,
+		Recorder:            recorder,
+		ImageDigestResolver: resolver,
+	}
+
+	get := func() *v1alpha1.AutoscalingRunnerSet {
+		updated := new(v1alpha1.AutoscalingRunnerSet)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(autoscalingRunnerSet), updated))
+		return updated
+	}
+
+	unpinnedHash := autoscalingRunnerSet.RunnerSetSpecHash()
+
+	require.NoError(t, r.reconcileRunnerImageDigest(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	updated := get()
+	assert.Equal(t, "ghcr.io/actions/actions-runner:latest", updated.Status.ResolvedRunnerImage)
+	assert.Equal(t, "sha256:aaaa", updated.Status.RunnerImageDigest)
+	assert.Equal(t, "ghcr.io/actions/actions-runner:latest@sha256:aaaa", updated.PinnedRunnerImage())
+	assert.Equal(t, imagedigest.Keychain{"ghcr.io": {Username: "bot", Password: "token"}}, resolver.keychain)
+	assert.Contains(t, <-recorder.Events, reasonRunnerImageDigestChanged)
+	pinnedHash := updated.RunnerSetSpecHash()
+	assert.NotEqual(t, unpinnedHash, pinnedHash)
+
+	// The tag is not resolved again within the refresh interval.
+	resolver.digest = "sha256:bbbb"
+	require.NoError(t, r.reconcileRunnerImageDigest(context.Background(), updated, logr.Discard()))
+	assert.Equal(t, 1, resolver.calls)
+	assert.Equal(t, "sha256:aaaa", get().Status.RunnerImageDigest)
+
+	// A new runner image is resolved right away. Failures keep the previous digest.
+	updated.Spec.Template.Spec.Containers[0].Image = "ghcr.io/actions/actions-runner:2.303.0"
+	resolver.err = errors.New("registry unavailable")
+	require.NoError(t, r.reconcileRunnerImageDigest(context.Background(), updated, logr.Discard()))
+	assert.Equal(t, 2, resolver.calls)
+	assert.Contains(t, <-recorder.Events, reasonRunnerImageDigestResolutionFailed)
+	assert.Equal(t, "sha256:aaaa", get().Status.RunnerImageDigest)
+	assert.Empty(t, updated.PinnedRunnerImage(), "The digest of another image is not used")
+
+	// Disabling the pinning clears the status.
+	updated.Spec.RunnerImageDigestPinning = nil
+	require.NoError(t, r.reconcileRunnerImageDigest(context.Background(), updated, logr.Discard()))
+	updated = get()
+	assert.Empty(t, updated.Status.ResolvedRunnerImage)
+	assert.Empty(t, updated.Status.RunnerImageDigest)
+}
+
+func TestRunnerSetSpecHash_RunnerImageDigestPinning(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl: "https://github.com/owner/repo",
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/actions-runner:latest"}},
+				},
+			},
+		},
+		Status: v1alpha1.AutoscalingRunnerSetStatus{
+			ResolvedRunnerImage: "ghcr.io/actions/actions-runner:latest",
+			RunnerImageDigest:   "sha256:aaaa",
+		},
+	}
+	specHash := autoscalingRunnerSet.RunnerSetSpecHash()
+
+	autoscalingRunnerSet.Spec.RunnerImageDigestPinning = &v1alpha1.RunnerImageDigestPinning{Enabled: true}
+	pinnedHash := autoscalingRunnerSet.RunnerSetSpecHash()
+	assert.NotEqual(t, specHash, pinnedHash)
+
+	autoscalingRunnerSet.Status.RunnerImageDigest = "sha256:bbbb"
+	assert.NotEqual(t, pinnedHash, autoscalingRunnerSet.RunnerSetSpecHash(), "A new digest must roll out a new runner set")
+
+	autoscalingRunnerSet.Annotations = map[string]string{runnerScaleSetIdKey: "1"}
+	runnerSet, err := new(resourceBuilder).newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/actions/actions-runner:latest@sha256:bbbb", runnerSet.Spec.EphemeralRunnerSpec.Spec.Containers[0].Image)
+}
@@ -0,0 +1,186 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Annotations and labels of the workload identities of the cloud providers.
+const (
+	annotationKeyAWSRoleARN          = "eks.amazonaws.com/role-arn"
+	annotationKeyGCPServiceAccount   = "iam.gke.io/gcp-service-account"
+	annotationKeyAzureClientID       = "azure.workload.identity/client-id"
+	annotationKeyAzureTenantID       = "azure.workload.identity/tenant-id"
+	labelKeyAzureWorkloadIdentityUse = "azure.workload.identity/use"
+)
+
+// runnerServiceAccountManaged reports whether the controller creates the runner service account of the autoscaling
+// runner set. With the kubernetes container mode, the service account of the container hooks takes its place.
+func runnerServiceAccountManaged(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) bool {
+	return autoscalingRunnerSet.Spec.RunnerServiceAccount != nil &&
+		autoscalingRunnerSet.Spec.Template.Spec.ServiceAccountName == "" &&
+		!kubernetesModeRBAC(autoscalingRunnerSet)
+}
+
+func runnerServiceAccountName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return autoscalingRunnerSet.Name + "-runner"
+}
+
+// runnerServiceAccountAnnotations returns the annotations of the runner service account, the ones of the cloud
+// providers taking precedence over the extra annotations. It returns nil without runnerServiceAccount.
+func runnerServiceAccountAnnotations(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) map[string]string {
+	sa := autoscalingRunnerSet.Spec.RunnerServiceAccount
+	if sa == nil {
+		return nil
+	}
+	annotations := make(map[string]string, len(sa.Annotations)+3)
+	for k, v := range sa.Annotations {
+		annotations[k] = v
+	}
+	if sa.AWS != nil {
+		annotations[annotationKeyAWSRoleARN] = sa.AWS.RoleARN
+	}
+	if sa.GCP != nil {
+		annotations[annotationKeyGCPServiceAccount] = sa.GCP.ServiceAccount
+	}
+	if sa.Azure != nil {
+		annotations[annotationKeyAzureClientID] = sa.Azure.ClientID
+		if sa.Azure.TenantID != "" {
+			annotations[annotationKeyAzureTenantID] = sa.Azure.TenantID
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// applyRunnerServiceAccount runs the runner pods of the template with the runner service account, when the
+// controller creates it and the template sets none, and labels them for the Azure workload identity webhook.
+func applyRunnerServiceAccount(template *corev1.PodTemplateSpec, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	if !runnerServiceAccountManaged(autoscalingRunnerSet) && !kubernetesModeRBAC(autoscalingRunnerSet) {
+		return
+	}
+	if runnerServiceAccountManaged(autoscalingRunnerSet) && template.Spec.ServiceAccountName == "" {
+		template.Spec.ServiceAccountName = runnerServiceAccountName(autoscalingRunnerSet)
+	}
+	if sa := autoscalingRunnerSet.Spec.RunnerServiceAccount; sa != nil && sa.Azure != nil {
+		labels := make(map[string]string, len(template.Labels)+1)
+		for k, v := range template.Labels {
+			labels[k] = v
+		}
+		labels[labelKeyAzureWorkloadIdentityUse] = "true"
+		template.Labels = labels
+	}
+}
+
+func validateRunnerServiceAccount(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) error {
+	sa := autoscalingRunnerSet.Spec.RunnerServiceAccount
+	if sa == nil {
+		return nil
+	}
+	if sa.AWS != nil && !strings.HasPrefix(sa.AWS.RoleARN, "arn:") {
+		return fmt.Errorf("invalid AWS role ARN %q", sa.AWS.RoleARN)
+	}
+	if sa.GCP != nil && !strings.Contains(sa.GCP.ServiceAccount, "@") {
+		return fmt.Errorf("invalid GCP service account %q, expected its email", sa.GCP.ServiceAccount)
+	}
+	if sa.Azure != nil && sa.Azure.ClientID == "" {
+		return fmt.Errorf("the client ID of the Azure workload identity is required")
+	}
+	return nil
+}
+
+func (b *resourceBuilder) newRunnerServiceAccount(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runnerServiceAccountName(autoscalingRunnerSet),
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				"auto-scaling-runner-set-namespace": autoscalingRunnerSet.Namespace,
+				"auto-scaling-runner-set-name":      autoscalingRunnerSet.Name,
+			},
+			Annotations: runnerServiceAccountAnnotations(autoscalingRunnerSet),
+		},
+	}
+}
+
+// reconcileRunnerServiceAccount creates the runner service account owned by the autoscaling runner set before
+// the runner sets, keeps its annotations in sync with the spec, and deletes it once it is no longer managed.
+// Annotations added to the service account by others are kept.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerServiceAccount(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	key := types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: runnerServiceAccountName(autoscalingRunnerSet)}
+
+	current := new(corev1.ServiceAccount)
+	if err := r.Get(ctx, key, current); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get runner service account: %v", err)
+		}
+		current = nil
+	}
+
+	if !runnerServiceAccountManaged(autoscalingRunnerSet) {
+		if current == nil || !metav1.IsControlledBy(current, autoscalingRunnerSet) {
+			return nil
+		}
+		log.Info("Runner service account is no longer managed. Deleting it", "name", key.Name)
+		if err := r.Delete(ctx, current); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete runner service account: %v", err)
+		}
+		return nil
+	}
+
+	desired := r.resourceBuilder.newRunnerServiceAccount(autoscalingRunnerSet)
+	if current == nil {
+		if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set controller reference: %v", err)
+		}
+		log.Info("Creating runner service account", "name", key.Name)
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create runner service account: %v", err)
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(current, autoscalingRunnerSet) {
+		return nil
+	}
+	return r.syncRunnerServiceAccountAnnotations(ctx, current, autoscalingRunnerSet, log)
+}
+
+// syncRunnerServiceAccountAnnotations sets the workload identity annotations of the spec on the service account,
+// and removes the ones of the cloud providers the spec no longer sets.
+func (r *AutoscalingRunnerSetReconciler) syncRunnerServiceAccountAnnotations(ctx context.Context, serviceAccount *corev1.ServiceAccount, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	desired := runnerServiceAccountAnnotations(autoscalingRunnerSet)
+	annotations := make(map[string]string, len(serviceAccount.Annotations))
+	for k, v := range serviceAccount.Annotations {
+		annotations[k] = v
+	}
+	for _, k := range []string{annotationKeyAWSRoleARN, annotationKeyGCPServiceAccount, annotationKeyAzureClientID, annotationKeyAzureTenantID} {
+		delete(annotations, k)
+	}
+	for k, v := range desired {
+		annotations[k] = v
+	}
+	if len(annotations) == 0 && len(serviceAccount.Annotations) == 0 || reflect.DeepEqual(annotations, serviceAccount.Annotations) {
+		return nil
+	}
+
+	log.Info("Updating the workload identity annotations of the service account", "name", serviceAccount.Name)
+	if err := patch(ctx, r.Client, serviceAccount, func(obj *corev1.ServiceAccount) {
+		obj.Annotations = annotations
+	}); err != nil {
+		return fmt.Errorf("failed to update the annotations of service account %s: %v", serviceAccount.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRunnerServiceAccount(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners", UID: "1234"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			RunnerServiceAccount: &v1alpha1.RunnerServiceAccount{
+				AWS:         &v1alpha1.AWSWorkloadIdentity{RoleARN: "arn:aws:iam::111122223333:role/runners"},
+				Annotations: map[string]string{"example.com/team": "ci"},
+			},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	require.NoError(t, r.reconcileRunnerServiceAccount(ctx, autoscalingRunnerSet, logr.Discard()))
+
+	key := types.NamespacedName{Namespace: "runners", Name: "arc-runner"}
+	serviceAccount := new(corev1.ServiceAccount)
+	require.NoError(t, c.Get(ctx, key, serviceAccount))
+	assert.True(t, metav1.IsControlledBy(serviceAccount, autoscalingRunnerSet))
+	assert.Equal(t, map[string]string{
+		annotationKeyAWSRoleARN: "arn:aws:iam::111122223333:role/runners",
+		"example.com/team":      "ci",
+	}, serviceAccount.Annotations)
+
+	// Switching to another cloud replaces the workload identity annotations, and keeps the ones added by others.
+	require.NoError(t, patch(ctx, c, serviceAccount, func(obj *corev1.ServiceAccount) {
+		obj.Annotations["kubernetes.io/enforce-mountable-secrets"] = "true"
+	}))
+	autoscalingRunnerSet.Spec.RunnerServiceAccount.AWS = nil
+	autoscalingRunnerSet.Spec.RunnerServiceAccount.GCP = &v1alpha1.GCPWorkloadIdentity{ServiceAccount: "runners@my-project.iam.gserviceaccount.com"}
+	require.NoError(t, r.reconcileRunnerServiceAccount(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, key, serviceAccount))
+	assert.Equal(t, map[string]string{
+		annotationKeyGCPServiceAccount:            "runners@my-project.iam.gserviceaccount.com",
+		"example.com/team":                        "ci",
+		"kubernetes.io/enforce-mountable-secrets": "true",
+	}, serviceAccount.Annotations)
+
+	// A service account of the template is used as it is.
+	autoscalingRunnerSet.Spec.Template.Spec.ServiceAccountName = "my-runners"
+	require.NoError(t, r.reconcileRunnerServiceAccount(ctx, autoscalingRunnerSet, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, new(corev1.ServiceAccount))))
+}
+
+func TestReconcileKubernetesModeRBAC_RunnerServiceAccount(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners", UID: "1234"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			ContainerMode: &v1alpha1.ContainerMode{Type: v1alpha1.ContainerModeTypeKubernetes},
+		},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+	require.NoError(t, r.reconcileKubernetesModeRBAC(ctx, autoscalingRunnerSet, logr.Discard()))
+
+	// The service account of the container hooks is annotated in place of the runner service account.
+	autoscalingRunnerSet.Spec.RunnerServiceAccount = &v1alpha1.RunnerServiceAccount{
+		Azure: &v1alpha1.AzureWorkloadIdentity{ClientID: "00000000-0000-0000-0000-000000000000"},
+	}
+	require.NoError(t, r.reconcileKubernetesModeRBAC(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, r.reconcileRunnerServiceAccount(ctx, autoscalingRunnerSet, logr.Discard()))
+
+	serviceAccount := new(corev1.ServiceAccount)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "runners", Name: "arc-kube-mode"}, serviceAccount))
+	assert.Equal(t, map[string]string{annotationKeyAzureClientID: "00000000-0000-0000-0000-000000000000"}, serviceAccount.Annotations)
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, types.NamespacedName{Namespace: "runners", Name: "arc-runner"}, new(corev1.ServiceAccount))))
+}
+
+func TestNewEphemeralRunnerSet_RunnerServiceAccount(t *testing.T) {
+	b := resourceBuilder{}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "runners",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			TemplateVariants: []v1alpha1.TemplateVariant{
+				{Name: "gpu", Labels: []string{"gpu"}, Template: newTestEphemeralRunner().Spec.PodTemplateSpec},
+			},
+		},
+	}
+	without := autoscalingRunnerSet.RunnerSetSpecHash()
+
+	autoscalingRunnerSet.Spec.RunnerServiceAccount = &v1alpha1.RunnerServiceAccount{
+		Azure: &v1alpha1.AzureWorkloadIdentity{ClientID: "00000000-0000-0000-0000-000000000000"},
+	}
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	template := runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec
+	assert.Equal(t, "arc-runner", template.Spec.ServiceAccountName)
+	assert.Equal(t, "true", template.Labels[labelKeyAzureWorkloadIdentityUse])
+	require.Len(t, runnerSet.Spec.TemplateVariants, 1)
+	assert.Equal(t, "arc-runner", runnerSet.Spec.TemplateVariants[0].Template.Spec.ServiceAccountName)
+	assert.NotEqual(t, without, autoscalingRunnerSet.RunnerSetSpecHash())
+
+	// Changing the identity doesn't roll out the runners, the service account is annotated in place.
+	withAzure := autoscalingRunnerSet.RunnerSetSpecHash()
+	autoscalingRunnerSet.Spec.RunnerServiceAccount.Azure.ClientID = "11111111-1111-1111-1111-111111111111"
+	assert.Equal(t, withAzure, autoscalingRunnerSet.RunnerSetSpecHash())
+}
+
+func TestValidateRunnerServiceAccount(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{}
+	assert.NoError(t, validateRunnerServiceAccount(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.RunnerServiceAccount = &v1alpha1.RunnerServiceAccount{
+		AWS: &v1alpha1.AWSWorkloadIdentity{RoleARN: "runners"},
+	}
+	assert.Error(t, validateRunnerServiceAccount(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.RunnerServiceAccount = &v1alpha1.RunnerServiceAccount{
+		GCP: &v1alpha1.GCPWorkloadIdentity{ServiceAccount: "runners"},
+	}
+	assert.Error(t, validateRunnerServiceAccount(autoscalingRunnerSet))
+
+	autoscalingRunnerSet.Spec.RunnerServiceAccount = &v1alpha1.RunnerServiceAccount{
+		Azure: &v1alpha1.AzureWorkloadIdentity{},
+	}
+	assert.Error(t, validateRunnerServiceAccount(autoscalingRunnerSet))
+}
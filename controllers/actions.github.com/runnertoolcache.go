@@ -0,0 +1,147 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	RunnerToolCacheVolumeName = "tool-cache"
+	// RunnerToolCacheMountPath is where the tool cache is mounted in the runner container,
+	// as on the GitHub-hosted runners.
+	RunnerToolCacheMountPath = "/opt/hostedtoolcache"
+
+	EnvVarRunnerToolCache     = "RUNNER_TOOL_CACHE"
+	EnvVarAgentToolsDirectory = "AGENT_TOOLSDIRECTORY"
+)
+
+func runnerToolCacheClaimName(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	return fmt.Sprintf("%v-tool-cache", autoscalingRunnerSet.Name)
+}
+
+func (b *resourceBuilder) newRunnerToolCacheClaim(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) *corev1.PersistentVolumeClaim {
+	spec := autoscalingRunnerSet.Spec.ToolCache.Spec.DeepCopy()
+	if len(spec.AccessModes) == 0 {
+		spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runnerToolCacheClaimName(autoscalingRunnerSet),
+			Namespace: autoscalingRunnerSet.Namespace,
+			Labels: map[string]string{
+				LabelKeyAutoScaleRunnerSetName: runnerPlacementLabelValue(autoscalingRunnerSet),
+			},
+		},
+		Spec: *spec,
+	}
+}
+
+// applyRunnerToolCache mounts the tool cache of the autoscaling runner set into the runner container of the
+// pod template, and points the runner and the setup actions at it. A tool-cache volume of the template wins.
+func applyRunnerToolCache(template *corev1.PodTemplateSpec, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	toolCache := autoscalingRunnerSet.Spec.ToolCache
+	if toolCache == nil {
+		return
+	}
+	for _, v := range template.Spec.Volumes {
+		if v.Name == RunnerToolCacheVolumeName {
+			return
+		}
+	}
+
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: RunnerToolCacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: runnerToolCacheClaimName(autoscalingRunnerSet),
+				ReadOnly:  toolCache.ReadOnly,
+			},
+		},
+	})
+
+	mountPath := RunnerToolCacheMountPath
+	if isWindowsPod(&template.Spec) {
+		mountPath = WindowsRunnerToolCacheMountPath
+	}
+	for i := range template.Spec.Containers {
+		c := &template.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:        RunnerToolCacheVolumeName,
+			MountPath:   mountPath,
+			ReadOnly:    toolCache.ReadOnly,
+			SubPathExpr: toolCache.SubPathExpr,
+		})
+		c.Env = appendEnvIfMissing(c.Env, corev1.EnvVar{Name: EnvVarRunnerToolCache, Value: mountPath})
+		c.Env = appendEnvIfMissing(c.Env, corev1.EnvVar{Name: EnvVarAgentToolsDirectory, Value: mountPath})
+	}
+}
+
+// reconcileRunnerToolCache creates the tool cache claim of the autoscaling runner set, expands it when its
+// storage request grows, and deletes it once the tool cache is disabled. The claim outlives the runner sets,
+// and is only deleted with the autoscaling runner set.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerToolCache(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	claim := new(corev1.PersistentVolumeClaim)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: runnerToolCacheClaimName(autoscalingRunnerSet)}, claim); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get tool cache claim: %v", err)
+		}
+		claim = nil
+	}
+
+	if autoscalingRunnerSet.Spec.ToolCache == nil {
+		if claim == nil || !metav1.IsControlledBy(claim, autoscalingRunnerSet) || !claim.DeletionTimestamp.IsZero() {
+			return nil
+		}
+		log.Info("Tool cache is disabled. Deleting the tool cache claim", "name", claim.Name)
+		if err := r.Delete(ctx, claim); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete tool cache claim: %v", err)
+		}
+		return nil
+	}
+
+	desired := r.resourceBuilder.newRunnerToolCacheClaim(autoscalingRunnerSet)
+	if claim == nil {
+		if err := ctrl.SetControllerReference(autoscalingRunnerSet, desired, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set controller reference: %v", err)
+		}
+		log.Info("Creating the tool cache claim", "name", desired.Name)
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create tool cache claim: %v", err)
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(claim, autoscalingRunnerSet) {
+		return nil
+	}
+
+	// The storage request is the only field of the spec of a bound claim that can change.
+	requested, ok := desired.Spec.Resources.Requests[corev1.ResourceStorage]
+	current := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok || requested.Cmp(current) <= 0 {
+		return nil
+	}
+
+	log.Info("Expanding the tool cache claim", "name", claim.Name, "from", current.String(), "to", requested.String())
+	if err := patch(ctx, r.Client, claim, func(obj *corev1.PersistentVolumeClaim) {
+		if obj.Spec.Resources.Requests == nil {
+			obj.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		obj.Spec.Resources.Requests[corev1.ResourceStorage] = requested
+	}); err != nil {
+		return fmt.Errorf("failed to expand tool cache claim: %v", err)
+	}
+	return nil
+}
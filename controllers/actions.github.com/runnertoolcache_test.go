@@ -0,0 +1,103 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestToolCacheAutoscalingRunnerSet() *v1alpha1.AutoscalingRunnerSet {
+	return &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "arc",
+			Namespace:   "default",
+			UID:         "arc-uid",
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "secret",
+			Template:           newTestEphemeralRunner().Spec.PodTemplateSpec,
+			ToolCache: &v1alpha1.RunnerToolCache{
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("50Gi")},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNewEphemeralRunnerSet_ToolCache(t *testing.T) {
+	b := resourceBuilder{}
+	autoscalingRunnerSet := newTestToolCacheAutoscalingRunnerSet()
+	autoscalingRunnerSet.Spec.ToolCache.ReadOnly = true
+	autoscalingRunnerSet.Spec.ToolCache.SubPathExpr = "$(NODE_NAME)"
+	autoscalingRunnerSet.Spec.TemplateVariants = []v1alpha1.TemplateVariant{
+		{Name: "large", Labels: []string{"large"}, Template: newTestEphemeralRunner().Spec.PodTemplateSpec},
+	}
+
+	runnerSet, err := b.newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+
+	for _, template := range []corev1.PodTemplateSpec{runnerSet.Spec.EphemeralRunnerSpec.PodTemplateSpec, runnerSet.Spec.TemplateVariants[0].Template} {
+		require.Len(t, template.Spec.Volumes, 1)
+		volume := template.Spec.Volumes[0]
+		assert.Equal(t, RunnerToolCacheVolumeName, volume.Name)
+		require.NotNil(t, volume.PersistentVolumeClaim)
+		assert.Equal(t, corev1.PersistentVolumeClaimVolumeSource{ClaimName: "arc-tool-cache", ReadOnly: true}, *volume.PersistentVolumeClaim)
+
+		runnerContainer := template.Spec.Containers[0]
+		assert.Equal(t, []corev1.VolumeMount{{Name: RunnerToolCacheVolumeName, MountPath: RunnerToolCacheMountPath, ReadOnly: true, SubPathExpr: "$(NODE_NAME)"}}, runnerContainer.VolumeMounts)
+		assert.Equal(t, RunnerToolCacheMountPath, findEnv(runnerContainer.Env, EnvVarRunnerToolCache).Value)
+		assert.Equal(t, RunnerToolCacheMountPath, findEnv(runnerContainer.Env, EnvVarAgentToolsDirectory).Value)
+		assert.Empty(t, template.Spec.Containers[1].VolumeMounts)
+	}
+	assert.Empty(t, autoscalingRunnerSet.Spec.Template.Spec.Volumes, "default template must not change")
+}
+
+func TestReconcileRunnerToolCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	autoscalingRunnerSet := newTestToolCacheAutoscalingRunnerSet()
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet).Build()
+	r := &AutoscalingRunnerSetReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "arc-tool-cache"}
+
+	require.NoError(t, r.reconcileRunnerToolCache(ctx, autoscalingRunnerSet, logr.Discard()))
+	claim := new(corev1.PersistentVolumeClaim)
+	require.NoError(t, c.Get(ctx, key, claim))
+	assert.True(t, metav1.IsControlledBy(claim, autoscalingRunnerSet))
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}, claim.Spec.AccessModes)
+	assert.Equal(t, resource.MustParse("50Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+
+	autoscalingRunnerSet.Spec.ToolCache.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("20Gi")
+	require.NoError(t, r.reconcileRunnerToolCache(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, key, claim))
+	assert.Equal(t, resource.MustParse("50Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage], "Claims can't shrink")
+
+	autoscalingRunnerSet.Spec.ToolCache.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("100Gi")
+	require.NoError(t, r.reconcileRunnerToolCache(ctx, autoscalingRunnerSet, logr.Discard()))
+	require.NoError(t, c.Get(ctx, key, claim))
+	assert.Equal(t, resource.MustParse("100Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+
+	autoscalingRunnerSet.Spec.ToolCache = nil
+	require.NoError(t, r.reconcileRunnerToolCache(ctx, autoscalingRunnerSet, logr.Discard()))
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, claim)))
+}
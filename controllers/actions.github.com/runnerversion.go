@@ -0,0 +1,229 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultRunnerVersionCheckInterval is how often the latest runner version is fetched
+// for the autoscaling runner sets tracking their runner version.
+const DefaultRunnerVersionCheckInterval = time.Hour
+
+// runnerImageTagVersion matches the runner version at the start of runner image tags,
+// such as 2.303.0 or v2.303.0-ubuntu-22.04.
+var runnerImageTagVersion = regexp.MustCompile(`^v?(\d+\.\d+\.\d+)`)
+
+// runnerImageVersion returns the runner version of the runner container image of the template,
+// or an empty string when its tag is not a runner version, e.g. latest or a digest.
+func runnerImageVersion(template *corev1.PodTemplateSpec) string {
+	for _, c := range template.Spec.Containers {
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+
+		image := c.Image
+		if i := strings.Index(image, "@"); i >= 0 {
+			image = image[:i]
+		}
+		i := strings.LastIndex(image, ":")
+		if i < 0 || strings.Contains(image[i:], "/") {
+			return ""
+		}
+
+		if match := runnerImageTagVersion.FindStringSubmatch(image[i+1:]); match != nil {
+			return match[1]
+		}
+		return ""
+	}
+	return ""
+}
+
+// runnerRefreshVersion returns the runner version idle runners are refreshed for,
+// or an empty string when they aren't refreshed on new runner releases.
+func runnerRefreshVersion(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) string {
+	tracking := autoscalingRunnerSet.Spec.RunnerVersionTracking
+	if tracking == nil || !tracking.Enabled || !tracking.RefreshIdleRunners {
+		return ""
+	}
+	// A runner image tagged with a runner version runs the same version once refreshed.
+	if autoscalingRunnerSet.Status.RunnerVersion != "" {
+		return ""
+	}
+	return autoscalingRunnerSet.Status.LatestRunnerVersion
+}
+
+// runnerRefreshNeeded reports whether the runner set was created before the runner release
+// the idle runners are refreshed for. The version is compared apart from the runner spec hash,
+// so that the rollbacks and canaries of the runner spec don't depend on the runner releases,
+// and a release that can't be fetched doesn't roll out the runners again.
+func runnerRefreshNeeded(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, runnerSet *v1alpha1.EphemeralRunnerSet) bool {
+	version := runnerRefreshVersion(autoscalingRunnerSet)
+	if version == "" {
+		return false
+	}
+	current := runnerSet.Annotations[AnnotationKeyRunnerRefreshVersion]
+	return current == "" || compareRunnerVersions(current, version) < 0
+}
+
+// compareRunnerVersions compares two runner versions such as "2.303.0" and "2.298.2",
+// returning -1, 0 or 1 like strings.Compare.
+func compareRunnerVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// runnerVersionCondition returns the RunnerVersionOutdated condition of the runner version
+// compared to the latest and the minimum runner versions.
+func runnerVersionCondition(runnerVersion, latestVersion, minimumVersion string) metav1.Condition {
+	condition := metav1.Condition{
+		Type: v1alpha1.AutoscalingRunnerSetConditionRunnerVersionOutdated,
+	}
+
+	switch {
+	case runnerVersion == "":
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = v1alpha1.RunnerVersionReasonUnknown
+		condition.Message = "The tag of the runner image is not a runner version"
+	case minimumVersion != "" && compareRunnerVersions(runnerVersion, minimumVersion) < 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = v1alpha1.RunnerVersionReasonTooOld
+		condition.Message = fmt.Sprintf("Runner version %s is older than the minimum version %s and can't accept jobs. Update the runner image", runnerVersion, minimumVersion)
+	case latestVersion == "":
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = v1alpha1.RunnerVersionReasonUnknown
+		condition.Message = "The latest runner version is not known yet"
+	case compareRunnerVersions(runnerVersion, latestVersion) < 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = v1alpha1.RunnerVersionReasonNewerVersionAvailable
+		condition.Message = fmt.Sprintf("Runner version %s is older than the latest version %s", runnerVersion, latestVersion)
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = v1alpha1.RunnerVersionReasonUpToDate
+		condition.Message = fmt.Sprintf("Runner version %s is the latest version", runnerVersion)
+	}
+
+	return condition
+}
+
+// reconcileRunnerVersion updates the runner versions and the RunnerVersionOutdated condition in the status.
+// The latest runner version is fetched from the GitHub instance at most once per RunnerVersionCheckInterval.
+// A new latest version changes the runner set spec hash when idle runners are refreshed, which rolls out new runners.
+func (r *AutoscalingRunnerSetReconciler) reconcileRunnerVersion(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, log logr.Logger) error {
+	key := types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: autoscalingRunnerSet.Name}
+	tracking := autoscalingRunnerSet.Spec.RunnerVersionTracking
+	if tracking == nil || !tracking.Enabled {
+		r.forgetRunnerVersionCheck(key)
+		if autoscalingRunnerSet.Status.RunnerVersion == "" &&
+			autoscalingRunnerSet.Status.LatestRunnerVersion == "" &&
+			meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRunnerVersionOutdated) == nil {
+			return nil
+		}
+		return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+			obj.Status.RunnerVersion = ""
+			obj.Status.LatestRunnerVersion = ""
+			meta.RemoveStatusCondition(&obj.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRunnerVersionOutdated)
+		})
+	}
+
+	latestVersion := autoscalingRunnerSet.Status.LatestRunnerVersion
+	if r.runnerVersionCheckDue(key) {
+		actionsClient, err := r.actionsClientFor(ctx, autoscalingRunnerSet)
+		if err != nil {
+			return fmt.Errorf("failed to initialize Actions service client for getting the latest runner version: %v", err)
+		}
+
+		version, err := actionsClient.GetLatestRunnerVersion(ctx)
+		if err != nil {
+			// The check is best effort, the next one will retry.
+			log.Error(err, "Failed to get the latest runner version")
+			r.recordGitHubError(autoscalingRunnerSet, err)
+		} else {
+			latestVersion = version
+		}
+	}
+
+	runnerVersion := runnerImageVersion(&autoscalingRunnerSet.Spec.Template)
+	condition := runnerVersionCondition(runnerVersion, latestVersion, tracking.MinimumVersion)
+	condition.ObservedGeneration = autoscalingRunnerSet.Generation
+
+	current := meta.FindStatusCondition(autoscalingRunnerSet.Status.Conditions, condition.Type)
+	if autoscalingRunnerSet.Status.RunnerVersion == runnerVersion &&
+		autoscalingRunnerSet.Status.LatestRunnerVersion == latestVersion &&
+		current != nil &&
+		current.Status == condition.Status &&
+		current.Reason == condition.Reason &&
+		current.Message == condition.Message &&
+		current.ObservedGeneration == condition.ObservedGeneration {
+		return nil
+	}
+
+	if condition.Reason == v1alpha1.RunnerVersionReasonTooOld && (current == nil || current.Reason != condition.Reason) {
+		r.Recorder.Event(autoscalingRunnerSet, corev1.EventTypeWarning, v1alpha1.RunnerVersionReasonTooOld, condition.Message)
+	}
+
+	log.Info("Updating the runner version status", "runnerVersion", runnerVersion, "latestRunnerVersion", latestVersion, "reason", condition.Reason)
+	return patchSubResource(ctx, r.Status(), autoscalingRunnerSet, func(obj *v1alpha1.AutoscalingRunnerSet) {
+		obj.Status.RunnerVersion = runnerVersion
+		obj.Status.LatestRunnerVersion = latestVersion
+		meta.SetStatusCondition(&obj.Status.Conditions, condition)
+	})
+}
+
+// runnerVersionCheckDue reports whether the latest runner version should be fetched for the given
+// AutoscalingRunnerSet, and if so records the current time as the last check.
+func (r *AutoscalingRunnerSetReconciler) runnerVersionCheckDue(key types.NamespacedName) bool {
+	r.lastRunnerVersionCheckMu.Lock()
+	defer r.lastRunnerVersionCheckMu.Unlock()
+
+	if r.lastRunnerVersionCheck == nil {
+		r.lastRunnerVersionCheck = make(map[types.NamespacedName]time.Time)
+	}
+
+	now := time.Now()
+	if last, ok := r.lastRunnerVersionCheck[key]; ok && now.Sub(last) < r.runnerVersionCheckInterval() {
+		return false
+	}
+
+	r.lastRunnerVersionCheck[key] = now
+	return true
+}
+
+func (r *AutoscalingRunnerSetReconciler) forgetRunnerVersionCheck(key types.NamespacedName) {
+	r.lastRunnerVersionCheckMu.Lock()
+	defer r.lastRunnerVersionCheckMu.Unlock()
+
+	delete(r.lastRunnerVersionCheck, key)
+}
+
+func (r *AutoscalingRunnerSetReconciler) runnerVersionCheckInterval() time.Duration {
+	if interval := r.Config.runnerVersionCheckInterval(r.RunnerVersionCheckInterval); interval > 0 {
+		return interval
+	}
+	return DefaultRunnerVersionCheckInterval
+}
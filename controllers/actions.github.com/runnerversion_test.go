@@ -0,0 +1,192 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/github/actions/fake"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunnerImageVersion(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/actions/actions-runner:2.303.0":                         "2.303.0",
+		"summerwind/actions-runner:v2.303.0-ubuntu-22.04":                "2.303.0",
+		"registry.example.com:5000/actions-runner:2.298.2":               "2.298.2",
+		"ghcr.io/actions/actions-runner:latest":                          "",
+		"ghcr.io/actions/actions-runner":                                 "",
+		"registry.example.com:5000/actions-runner":                       "",
+		"ghcr.io/actions/actions-runner@sha256:0123456789abcdef":         "",
+		"ghcr.io/actions/actions-runner:2.303.0@sha256:0123456789abcdef": "2.303.0",
+	}
+
+	for image, want := range tests {
+		template := &corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "sidecar", Image: "busybox:1.36.0"},
+					{Name: EphemeralRunnerContainerName, Image: image},
+				},
+			},
+		}
+		assert.Equal(t, want, runnerImageVersion(template), image)
+	}
+}
+
+func TestRunnerVersionCondition(t *testing.T) {
+	tests := map[string]struct {
+		runnerVersion, latestVersion, minimumVersion string
+		wantStatus                                   metav1.ConditionStatus
+		wantReason                                   string
+	}{
+		"up to date":             {"2.303.0", "2.303.0", "", metav1.ConditionFalse, v1alpha1.RunnerVersionReasonUpToDate},
+		"newer version":          {"2.298.2", "2.303.0", "", metav1.ConditionTrue, v1alpha1.RunnerVersionReasonNewerVersionAvailable},
+		"newer patch version":    {"2.303.0", "2.303.10", "", metav1.ConditionTrue, v1alpha1.RunnerVersionReasonNewerVersionAvailable},
+		"too old":                {"2.298.2", "2.303.0", "2.300.0", metav1.ConditionTrue, v1alpha1.RunnerVersionReasonTooOld},
+		"above minimum":          {"2.301.0", "2.303.0", "2.300.0", metav1.ConditionTrue, v1alpha1.RunnerVersionReasonNewerVersionAvailable},
+		"unknown runner":         {"", "2.303.0", "", metav1.ConditionUnknown, v1alpha1.RunnerVersionReasonUnknown},
+		"unknown latest":         {"2.303.0", "", "", metav1.ConditionUnknown, v1alpha1.RunnerVersionReasonUnknown},
+		"too old without latest": {"2.298.2", "", "2.300.0", metav1.ConditionTrue, v1alpha1.RunnerVersionReasonTooOld},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			condition := runnerVersionCondition(tc.runnerVersion, tc.latestVersion, tc.minimumVersion)
+			assert.Equal(t, v1alpha1.AutoscalingRunnerSetConditionRunnerVersionOutdated, condition.Type)
+			assert.Equal(t, tc.wantStatus, condition.Status)
+			assert.Equal(t, tc.wantReason, condition.Reason)
+		})
+	}
+}
+
+func TestReconcileRunnerVersion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-config-secret", Namespace: "default"},
+		Data:       map[string][]byte{"github_token": []byte(autoscalingRunnerSetTestGitHubToken)},
+	}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "default"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: secret.Name,
+			RunnerVersionTracking: &v1alpha1.RunnerVersionTracking{
+				Enabled:        true,
+				MinimumVersion: "2.300.0",
+			},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/actions-runner:2.298.2"}},
+				},
+			},
+		},
+	}
+
+	c := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(secret, autoscalingRunnerSet).
+		Build()
+
+	actionsClient := &actions.MockActionsService{}
+	actionsClient.On("GetLatestRunnerVersion", mock.Anything).Return("2.303.0", nil).Once()
+
+	recorder := record.NewFakeRecorder(2)
+	r := &AutoscalingRunnerSetReconciler{
+		Client:        c,
+		Scheme:        scheme,
+		ActionsClient: fake.NewMultiClient(fake.WithDefaultClient(actionsClient, nil)),
+		Recorder:      recorder,
+	}
+
+	get := func() *v1alpha1.AutoscalingRunnerSet {
+		updated := new(v1alpha1.AutoscalingRunnerSet)
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(autoscalingRunnerSet), updated))
+		return updated
+	}
+
+	require.NoError(t, r.reconcileRunnerVersion(context.Background(), autoscalingRunnerSet, logr.Discard()))
+	updated := get()
+	assert.Equal(t, "2.298.2", updated.Status.RunnerVersion)
+	assert.Equal(t, "2.303.0", updated.Status.LatestRunnerVersion)
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.AutoscalingRunnerSetConditionRunnerVersionOutdated)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1alpha1.RunnerVersionReasonTooOld, condition.Reason)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, v1alpha1.RunnerVersionReasonTooOld)
+
+	// The latest version is not fetched again within the check interval.
+	require.NoError(t, r.reconcileRunnerVersion(context.Background(), updated, logr.Discard()))
+	actionsClient.AssertNumberOfCalls(t, "GetLatestRunnerVersion", 1)
+	assert.Empty(t, recorder.Events)
+
+	// Disabling the tracking clears the status.
+	updated.Spec.RunnerVersionTracking = nil
+	require.NoError(t, r.reconcileRunnerVersion(context.Background(), updated, logr.Discard()))
+	updated = get()
+	assert.Empty(t, updated.Status.RunnerVersion)
+	assert.Empty(t, updated.Status.LatestRunnerVersion)
+	assert.Empty(t, updated.Status.Conditions)
+}
+
+func TestRunnerRefreshNeeded(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{runnerScaleSetIdKey: "1"},
+		},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl: "https://github.com/owner/repo",
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: EphemeralRunnerContainerName, Image: "ghcr.io/actions/actions-runner:latest"}},
+				},
+			},
+		},
+		Status: v1alpha1.AutoscalingRunnerSetStatus{LatestRunnerVersion: "2.303.0"},
+	}
+	specHash := autoscalingRunnerSet.RunnerSetSpecHash()
+	runnerSet, err := new(resourceBuilder).newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Empty(t, runnerSet.Annotations[AnnotationKeyRunnerRefreshVersion])
+
+	autoscalingRunnerSet.Spec.RunnerVersionTracking = &v1alpha1.RunnerVersionTracking{Enabled: true}
+	assert.False(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet), "Tracking the runner version alone must not refresh runners")
+
+	autoscalingRunnerSet.Spec.RunnerVersionTracking.RefreshIdleRunners = true
+	assert.True(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet), "Runner sets created before the refresh was enabled are refreshed")
+	assert.Equal(t, specHash, autoscalingRunnerSet.RunnerSetSpecHash(), "The runner version is compared apart from the runner spec")
+
+	runnerSet, err = new(resourceBuilder).newEphemeralRunnerSet(autoscalingRunnerSet)
+	require.NoError(t, err)
+	assert.Equal(t, "2.303.0", runnerSet.Annotations[AnnotationKeyRunnerRefreshVersion])
+	assert.False(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet))
+
+	autoscalingRunnerSet.Status.LatestRunnerVersion = "2.304.0"
+	assert.True(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet), "A new runner release must refresh runners")
+	assert.Equal(t, specHash, autoscalingRunnerSet.RunnerSetSpecHash())
+
+	autoscalingRunnerSet.Status.LatestRunnerVersion = ""
+	assert.False(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet), "A runner release that can't be fetched must not refresh runners")
+
+	autoscalingRunnerSet.Status.LatestRunnerVersion = "2.302.0"
+	assert.False(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet), "An older runner release must not refresh runners")
+
+	autoscalingRunnerSet.Status.LatestRunnerVersion = "2.304.0"
+	autoscalingRunnerSet.Status.RunnerVersion = "2.303.0"
+	assert.False(t, runnerRefreshNeeded(autoscalingRunnerSet, runnerSet), "Runner images tagged with a runner version are not refreshed")
+}
@@ -0,0 +1,197 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// WorkVolumeName is the volume of the work directory of the runner, as named by the pod templates
+	// of the dind and kubernetes container modes.
+	WorkVolumeName = "work"
+	// RunnerWorkDir is the work directory of the runner in the runner image.
+	RunnerWorkDir = "/actions-runner/_work"
+
+	// LabelKeyEphemeralRunnerName is the label of the work volume claims naming their runner,
+	// which finds the claims left behind by deleted runners.
+	LabelKeyEphemeralRunnerName = "ephemeral-runner-name"
+
+	// orphanedWorkVolumeClaimMinAge keeps the sweep away from claims that are being created.
+	orphanedWorkVolumeClaimMinAge = time.Minute
+)
+
+func workVolumeClaimName(ephemeralRunner *v1alpha1.EphemeralRunner) string {
+	return ephemeralRunner.Name + "-work"
+}
+
+func (b *resourceBuilder) newEphemeralRunnerWorkVolumeClaim(ephemeralRunner *v1alpha1.EphemeralRunner) *corev1.PersistentVolumeClaim {
+	template := ephemeralRunner.Spec.WorkVolumeClaimTemplate
+
+	labels := make(map[string]string)
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	for k, v := range ephemeralRunner.Labels {
+		labels[k] = v
+	}
+	labels[LabelKeyEphemeralRunnerName] = ephemeralRunner.Name
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workVolumeClaimName(ephemeralRunner),
+			Namespace:   ephemeralRunner.Namespace,
+			Labels:      labels,
+			Annotations: template.Annotations,
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+}
+
+// addWorkVolumeClaim backs the work directory of the runner with the persistent volume claim of the runner.
+// The work volume of the pod template is replaced by the claim, so that the containers sharing it, such as
+// the dind sidecar, keep sharing it. Otherwise the claim is mounted at the work directory of the runner container.
+func addWorkVolumeClaim(pod *corev1.Pod, ephemeralRunner *v1alpha1.EphemeralRunner) {
+	if ephemeralRunner.Spec.WorkVolumeClaimTemplate == nil {
+		return
+	}
+
+	source := corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: workVolumeClaimName(ephemeralRunner),
+		},
+	}
+
+	volumes := make([]corev1.Volume, 0, len(pod.Spec.Volumes)+1)
+	replaced := false
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == WorkVolumeName {
+			v.VolumeSource = source
+			replaced = true
+		}
+		volumes = append(volumes, v)
+	}
+	if !replaced {
+		volumes = append(volumes, corev1.Volume{Name: WorkVolumeName, VolumeSource: source})
+	}
+	pod.Spec.Volumes = volumes
+	if replaced {
+		return
+	}
+
+	mountPath := RunnerWorkDir
+	if isWindowsPod(&pod.Spec) {
+		mountPath = WindowsRunnerWorkingDir + `\_work`
+	}
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		mounts := make([]corev1.VolumeMount, 0, len(c.VolumeMounts)+1)
+		mounts = append(mounts, c.VolumeMounts...)
+		c.VolumeMounts = append(mounts, corev1.VolumeMount{
+			Name:      WorkVolumeName,
+			MountPath: mountPath,
+		})
+	}
+}
+
+// createWorkVolumeClaim creates the persistent volume claim of the work directory of the runner, when the runner
+// has a work volume claim template and the claim doesn't exist yet. The claim is kept across the pods of the runner.
+func (r *EphemeralRunnerReconciler) createWorkVolumeClaim(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
+	if ephemeralRunner.Spec.WorkVolumeClaimTemplate == nil {
+		return nil
+	}
+
+	claim := new(corev1.PersistentVolumeClaim)
+	err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunner.Namespace, Name: workVolumeClaimName(ephemeralRunner)}, claim)
+	switch {
+	case err == nil:
+		return nil
+	case !kerrors.IsNotFound(err):
+		return fmt.Errorf("failed to get work volume claim: %v", err)
+	}
+
+	claim = r.resourceBuilder.newEphemeralRunnerWorkVolumeClaim(ephemeralRunner)
+	if err := ctrl.SetControllerReference(ephemeralRunner, claim, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set controller reference: %v", err)
+	}
+
+	log.Info("Creating the work volume claim of the runner", "name", claim.Name)
+	if err := r.Create(ctx, claim); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create work volume claim: %v", err)
+	}
+	return nil
+}
+
+// deleteWorkVolumeClaim deletes the persistent volume claim of the work directory of the runner,
+// and reports whether it is gone.
+func (r *EphemeralRunnerReconciler) deleteWorkVolumeClaim(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) (deleted bool, err error) {
+	claim := new(corev1.PersistentVolumeClaim)
+	err = r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunner.Namespace, Name: workVolumeClaimName(ephemeralRunner)}, claim)
+	switch {
+	case kerrors.IsNotFound(err):
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to get work volume claim: %v", err)
+	}
+
+	if !metav1.IsControlledBy(claim, ephemeralRunner) {
+		return true, nil
+	}
+	if claim.ObjectMeta.DeletionTimestamp.IsZero() {
+		log.Info("Deleting the work volume claim of the runner", "name", claim.Name)
+		if err := r.Delete(ctx, claim); err != nil && !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete work volume claim: %v", err)
+		}
+	}
+	return false, nil
+}
+
+// sweepOrphanedWorkVolumeClaims deletes the work volume claims of the namespace of the runner set whose runner
+// is gone, e.g. after the runner was force deleted with its finalizers removed, or deleted orphaning its dependents.
+// A claim is orphaned when the runner named by its label doesn't exist, or isn't the runner that created the claim.
+func (r *EphemeralRunnerSetReconciler) sweepOrphanedWorkVolumeClaims(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, log logr.Logger) error {
+	claims := new(corev1.PersistentVolumeClaimList)
+	if err := r.List(ctx, claims, client.InNamespace(ephemeralRunnerSet.Namespace), client.HasLabels{LabelKeyEphemeralRunnerName}); err != nil {
+		return fmt.Errorf("failed to list work volume claims: %v", err)
+	}
+
+	var errs []error
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if !claim.DeletionTimestamp.IsZero() || time.Since(claim.CreationTimestamp.Time) < orphanedWorkVolumeClaimMinAge {
+			continue
+		}
+
+		ephemeralRunner := new(v1alpha1.EphemeralRunner)
+		err := r.Get(ctx, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Labels[LabelKeyEphemeralRunnerName]}, ephemeralRunner)
+		switch {
+		case err == nil:
+			if owner := metav1.GetControllerOf(claim); owner == nil || owner.UID == ephemeralRunner.UID {
+				continue
+			}
+		case !kerrors.IsNotFound(err):
+			errs = append(errs, fmt.Errorf("failed to get ephemeral runner of work volume claim %s: %v", claim.Name, err))
+			continue
+		}
+
+		log.Info("Deleting orphaned work volume claim", "name", claim.Name, "runnerName", claim.Labels[LabelKeyEphemeralRunnerName])
+		if err := r.Delete(ctx, claim); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete work volume claim %s: %v", claim.Name, err))
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
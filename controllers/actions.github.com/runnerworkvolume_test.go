@@ -0,0 +1,194 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestWorkVolumeClaimTemplate() *corev1.PersistentVolumeClaimTemplate {
+	storageClassName := "fast"
+	return &corev1.PersistentVolumeClaimTemplate{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "build"}},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("100Gi")},
+			},
+		},
+	}
+}
+
+func TestNewEphemeralRunnerPod_WorkVolumeClaim(t *testing.T) {
+	b := resourceBuilder{}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-runner"}}
+
+	t.Run("mounts the claim at the work directory", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.WorkVolumeClaimTemplate = newTestWorkVolumeClaimTemplate()
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		require.Len(t, pod.Spec.Volumes, 1)
+		assert.Equal(t, WorkVolumeName, pod.Spec.Volumes[0].Name)
+		require.NotNil(t, pod.Spec.Volumes[0].PersistentVolumeClaim)
+		assert.Equal(t, "test-runner-work", pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+		assert.Equal(t, []corev1.VolumeMount{{Name: WorkVolumeName, MountPath: RunnerWorkDir}}, pod.Spec.Containers[0].VolumeMounts)
+		assert.Empty(t, pod.Spec.Containers[1].VolumeMounts)
+	})
+
+	t.Run("replaces the work volume of the template", func(t *testing.T) {
+		runner := newTestEphemeralRunner()
+		runner.Spec.WorkVolumeClaimTemplate = newTestWorkVolumeClaimTemplate()
+		runner.Spec.Spec.Volumes = []corev1.Volume{
+			{Name: "dind-cert", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: WorkVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		}
+		for i := range runner.Spec.Spec.Containers {
+			runner.Spec.Spec.Containers[i].VolumeMounts = []corev1.VolumeMount{{Name: WorkVolumeName, MountPath: "/actions-runner/_work"}}
+		}
+
+		pod := b.newEphemeralRunnerPod(context.Background(), runner, secret)
+
+		require.Len(t, pod.Spec.Volumes, 2)
+		assert.NotNil(t, pod.Spec.Volumes[0].EmptyDir)
+		assert.Nil(t, pod.Spec.Volumes[1].EmptyDir)
+		require.NotNil(t, pod.Spec.Volumes[1].PersistentVolumeClaim)
+		assert.Equal(t, "test-runner-work", pod.Spec.Volumes[1].PersistentVolumeClaim.ClaimName)
+		assert.Len(t, pod.Spec.Containers[0].VolumeMounts, 1)
+		assert.NotNil(t, runner.Spec.Spec.Volumes[1].EmptyDir, "The runner spec is left untouched")
+	})
+}
+
+func TestWorkVolumeClaimLifecycle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "runner",
+			Namespace: "default",
+			UID:       "runner-uid",
+			Labels:    map[string]string{LabelKeyRunnerSpecHash: "abc"},
+		},
+		Spec: v1alpha1.EphemeralRunnerSpec{WorkVolumeClaimTemplate: newTestWorkVolumeClaimTemplate()},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "runner-work"}
+
+	require.NoError(t, r.createWorkVolumeClaim(ctx, ephemeralRunner, logr.Discard()))
+	claim := new(corev1.PersistentVolumeClaim)
+	require.NoError(t, c.Get(ctx, key, claim))
+	assert.True(t, metav1.IsControlledBy(claim, ephemeralRunner))
+	assert.Equal(t, "build", claim.Labels["team"])
+	assert.Equal(t, "abc", claim.Labels[LabelKeyRunnerSpecHash])
+	assert.Equal(t, "runner", claim.Labels[LabelKeyEphemeralRunnerName])
+	assert.Equal(t, "fast", *claim.Spec.StorageClassName)
+	assert.Equal(t, resource.MustParse("100Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+
+	require.NoError(t, r.createWorkVolumeClaim(ctx, ephemeralRunner, logr.Discard()), "The claim is kept across the pods of the runner")
+
+	deleted, err := r.deleteWorkVolumeClaim(ctx, ephemeralRunner, logr.Discard())
+	require.NoError(t, err)
+	assert.False(t, deleted, "The claim is reported as deleted once it is gone")
+	assert.True(t, kerrors.IsNotFound(c.Get(ctx, key, claim)))
+
+	deleted, err = r.deleteWorkVolumeClaim(ctx, ephemeralRunner, logr.Discard())
+	require.NoError(t, err)
+	assert.True(t, deleted)
+}
+
+func TestDeleteWorkVolumeClaim_KeepsClaimsOfOthers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default", UID: "runner-uid"},
+	}
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-work", Namespace: "default"},
+	}
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(ephemeralRunner, claim).Build()
+	r := &EphemeralRunnerReconciler{Client: c, Scheme: scheme}
+
+	deleted, err := r.deleteWorkVolumeClaim(context.Background(), ephemeralRunner, logr.Discard())
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "runner-work"}, claim))
+}
+
+func TestSweepOrphanedWorkVolumeClaims(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "runners", Namespace: "default"},
+	}
+	ephemeralRunner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "default", UID: "runner-uid"},
+	}
+	created := metav1.NewTime(time.Now().Add(-time.Hour))
+	newClaim := func(name, runnerName string, owner types.UID) *corev1.PersistentVolumeClaim {
+		claim := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: created,
+			},
+		}
+		if runnerName != "" {
+			claim.Labels = map[string]string{LabelKeyEphemeralRunnerName: runnerName}
+		}
+		if owner != "" {
+			isController := true
+			claim.OwnerReferences = []metav1.OwnerReference{
+				{APIVersion: v1alpha1.GroupVersion.String(), Kind: "EphemeralRunner", Name: runnerName, UID: owner, Controller: &isController},
+			}
+		}
+		return claim
+	}
+
+	young := newClaim("young-work", "young", "young-uid")
+	young.CreationTimestamp = metav1.Now()
+
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		ephemeralRunnerSet,
+		ephemeralRunner,
+		newClaim("runner-work", "runner", "runner-uid"),
+		newClaim("gone-work", "gone", "gone-uid"),
+		newClaim("orphaned-work", "gone-too", ""),
+		newClaim("recreated-work", "runner", "old-runner-uid"),
+		newClaim("data", "", ""),
+		young,
+	).Build()
+	r := &EphemeralRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	require.NoError(t, r.sweepOrphanedWorkVolumeClaims(context.Background(), ephemeralRunnerSet, logr.Discard()))
+
+	claims := new(corev1.PersistentVolumeClaimList)
+	require.NoError(t, c.List(context.Background(), claims))
+	var names []string
+	for _, claim := range claims.Items {
+		names = append(names, claim.Name)
+	}
+	assert.ElementsMatch(t, []string{"runner-work", "data", "young-work"}, names)
+}
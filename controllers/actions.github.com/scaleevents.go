@@ -0,0 +1,81 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setScaleTrigger records what changed the replicas of the runner set, along with the replicas.
+// It is called once the replicas are set.
+func setScaleTrigger(runnerSet *v1alpha1.EphemeralRunnerSet, trigger v1alpha1.ScaleTrigger) {
+	if runnerSet.Annotations == nil {
+		runnerSet.Annotations = make(map[string]string)
+	}
+	runnerSet.Annotations[v1alpha1.AnnotationKeyScaleTrigger] = string(trigger)
+	runnerSet.Annotations[v1alpha1.AnnotationKeyScaleTriggerReplicas] = strconv.Itoa(runnerSet.Spec.Replicas)
+}
+
+// scaleTriggerOf returns what changed the replicas of the runner set last. The trigger is unknown when the
+// replicas were changed without setting it, e.g. with kubectl.
+func scaleTriggerOf(runnerSet *v1alpha1.EphemeralRunnerSet) v1alpha1.ScaleTrigger {
+	trigger := runnerSet.Annotations[v1alpha1.AnnotationKeyScaleTrigger]
+	if trigger == "" || runnerSet.Annotations[v1alpha1.AnnotationKeyScaleTriggerReplicas] != strconv.Itoa(runnerSet.Spec.Replicas) {
+		return v1alpha1.ScaleTriggerUnknown
+	}
+	return v1alpha1.ScaleTrigger(trigger)
+}
+
+// recordScaleEvent appends a change of the desired replicas of the runner set to the scale events of its
+// autoscaling runner set. The desired replicas are kept in the status of the runner set, so that each change
+// is recorded once, and a change that failed to be recorded is recorded on the next reconciliation.
+func (r *EphemeralRunnerSetReconciler) recordScaleEvent(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, desired int, trigger v1alpha1.ScaleTrigger, log logr.Logger) error {
+	previous := ephemeralRunnerSet.Status.DesiredReplicas
+	if previous == desired {
+		return nil
+	}
+
+	log.Info("Recording scale event", "previous", previous, "desired", desired, "trigger", trigger)
+	if owner := metav1.GetControllerOf(ephemeralRunnerSet); owner != nil && owner.Kind == "AutoscalingRunnerSet" {
+		autoscalingRunnerSet := new(v1alpha1.AutoscalingRunnerSet)
+		err := r.Get(ctx, types.NamespacedName{Namespace: ephemeralRunnerSet.Namespace, Name: owner.Name}, autoscalingRunnerSet)
+		switch {
+		case kerrors.IsNotFound(err):
+		case err != nil:
+			return fmt.Errorf("failed to get autoscaling runner set %s: %v", owner.Name, err)
+		default:
+			original := autoscalingRunnerSet.DeepCopy()
+			autoscalingRunnerSet.Status.ScaleEvents = appendScaleEvent(autoscalingRunnerSet.Status.ScaleEvents, v1alpha1.ScaleEvent{
+				Time:                   metav1.Now(),
+				EphemeralRunnerSetName: ephemeralRunnerSet.Name,
+				PreviousRunners:        previous,
+				DesiredRunners:         desired,
+				Trigger:                trigger,
+			})
+			// The old and new runner sets of a rollout append to the same events.
+			if err := r.Status().Patch(ctx, autoscalingRunnerSet, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+				return fmt.Errorf("failed to record scale event on autoscaling runner set %s: %v", owner.Name, err)
+			}
+		}
+	}
+
+	return patchSubResource(ctx, r.Status(), ephemeralRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+		obj.Status.DesiredReplicas = desired
+	})
+}
+
+// appendScaleEvent appends the event to the events, keeping the last MaxScaleEvents events.
+func appendScaleEvent(events []v1alpha1.ScaleEvent, event v1alpha1.ScaleEvent) []v1alpha1.ScaleEvent {
+	events = append(events, event)
+	if len(events) > v1alpha1.MaxScaleEvents {
+		events = events[len(events)-v1alpha1.MaxScaleEvents:]
+	}
+	return events
+}
@@ -0,0 +1,101 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDesiredReplicasWithTrigger(t *testing.T) {
+	two, four := 2, 4
+	runnerSet := &v1alpha1.EphemeralRunnerSet{Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 3}}
+	desired, trigger := desiredReplicasWithTrigger(runnerSet, 0)
+	assert.Equal(t, 3, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerUnknown, trigger, "Replicas set without a trigger")
+
+	setScaleTrigger(runnerSet, v1alpha1.ScaleTriggerJobsAssigned)
+	desired, trigger = desiredReplicasWithTrigger(runnerSet, 0)
+	assert.Equal(t, 3, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerJobsAssigned, trigger)
+
+	runnerSet.Spec.Replicas = 4
+	desired, trigger = desiredReplicasWithTrigger(runnerSet, 0)
+	assert.Equal(t, 4, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerUnknown, trigger, "Replicas changed since the trigger was set")
+
+	runnerSet.Spec.Replicas = 3
+
+	runnerSet.Spec.ReservedReplicas = 5
+	desired, trigger = desiredReplicasWithTrigger(runnerSet, 0)
+	assert.Equal(t, 5, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerReservedRunners, trigger)
+
+	runnerSet.Spec.WarmReplicas = 2
+	desired, trigger = desiredReplicasWithTrigger(runnerSet, 4)
+	assert.Equal(t, 6, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerWarmPool, trigger)
+
+	runnerSet.Spec.BudgetReplicas = &four
+	desired, trigger = desiredReplicasWithTrigger(runnerSet, 4)
+	assert.Equal(t, 4, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerBudget, trigger)
+
+	runnerSet.Spec.CapacityReplicas = &two
+	desired, trigger = desiredReplicasWithTrigger(runnerSet, 4)
+	assert.Equal(t, 2, desired)
+	assert.Equal(t, v1alpha1.ScaleTriggerClusterCapacity, trigger)
+}
+
+func TestRecordScaleEvent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc", Namespace: "runners", UID: "1234"},
+	}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "arc-abcde", Namespace: "runners"},
+	}
+	require.NoError(t, ctrl.SetControllerReference(autoscalingRunnerSet, runnerSet, scheme))
+	c := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(autoscalingRunnerSet, runnerSet).Build()
+	r := &EphemeralRunnerSetReconciler{Client: c, Scheme: scheme}
+
+	require.NoError(t, r.recordScaleEvent(ctx, runnerSet, 12, v1alpha1.ScaleTriggerJobsAssigned, logr.Discard()))
+	assert.Equal(t, 12, runnerSet.Status.DesiredReplicas)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	require.Len(t, autoscalingRunnerSet.Status.ScaleEvents, 1)
+	event := autoscalingRunnerSet.Status.ScaleEvents[0]
+	assert.Equal(t, "arc-abcde", event.EphemeralRunnerSetName)
+	assert.Equal(t, 0, event.PreviousRunners)
+	assert.Equal(t, 12, event.DesiredRunners)
+	assert.Equal(t, v1alpha1.ScaleTriggerJobsAssigned, event.Trigger)
+
+	// An unchanged decision isn't recorded again.
+	require.NoError(t, r.recordScaleEvent(ctx, runnerSet, 12, v1alpha1.ScaleTriggerJobsAssigned, logr.Discard()))
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	assert.Len(t, autoscalingRunnerSet.Status.ScaleEvents, 1)
+
+	// Only the last events are kept.
+	for i := 0; i < v1alpha1.MaxScaleEvents; i++ {
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(runnerSet), runnerSet))
+		require.NoError(t, r.recordScaleEvent(ctx, runnerSet, i, v1alpha1.ScaleTriggerMinRunners, logr.Discard()))
+	}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet))
+	require.Len(t, autoscalingRunnerSet.Status.ScaleEvents, v1alpha1.MaxScaleEvents)
+	first := autoscalingRunnerSet.Status.ScaleEvents[0]
+	assert.Equal(t, 12, first.PreviousRunners)
+	assert.Equal(t, 0, first.DesiredRunners)
+	assert.Equal(t, v1alpha1.MaxScaleEvents-1, autoscalingRunnerSet.Status.ScaleEvents[v1alpha1.MaxScaleEvents-1].DesiredRunners)
+}
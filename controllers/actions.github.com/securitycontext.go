@@ -0,0 +1,98 @@
+package actionsgithubcom
+
+import (
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultSecurityContext is the security context merged into the runner and listener pods generated by the
+// controller, e.g. to run them as non-root with the runtime default seccomp profile and no capabilities.
+// The fields set by the pods themselves are kept.
+type DefaultSecurityContext struct {
+	Pod       *corev1.PodSecurityContext
+	Container *corev1.SecurityContext
+}
+
+// ParseDefaultSecurityContext parses the default pod and container security contexts, in YAML or JSON.
+// It returns nil when both are empty.
+func ParseDefaultSecurityContext(pod, container string) (*DefaultSecurityContext, error) {
+	if pod == "" && container == "" {
+		return nil, nil
+	}
+
+	defaults := new(DefaultSecurityContext)
+	if pod != "" {
+		defaults.Pod = new(corev1.PodSecurityContext)
+		if err := yaml.UnmarshalStrict([]byte(pod), defaults.Pod); err != nil {
+			return nil, fmt.Errorf("invalid default pod security context: %v", err)
+		}
+	}
+	if container != "" {
+		defaults.Container = new(corev1.SecurityContext)
+		if err := yaml.UnmarshalStrict([]byte(container), defaults.Container); err != nil {
+			return nil, fmt.Errorf("invalid default container security context: %v", err)
+		}
+	}
+	return defaults, nil
+}
+
+// applyDefaultSecurityContext merges the default security context into the pod and its containers.
+// Each field of the defaults is only used when the pod or the container leaves it unset, so that
+// capabilities set by a container replace the default capabilities as a whole.
+// Privileged containers, like the dind sidecar, are left as they are, and keep running as root
+// when the pod defaults to runAsNonRoot. Windows pods are left as they are, as they don't support
+// most of the fields.
+func applyDefaultSecurityContext(pod *corev1.Pod, defaults *DefaultSecurityContext) {
+	if defaults == nil || isWindowsPod(&pod.Spec) {
+		return
+	}
+
+	if defaults.Pod != nil {
+		securityContext := defaults.Pod.DeepCopy()
+		if pod.Spec.SecurityContext != nil {
+			securityContext = pod.Spec.SecurityContext.DeepCopy()
+			fillUnsetFields(securityContext, defaults.Pod.DeepCopy())
+		}
+		pod.Spec.SecurityContext = securityContext
+	}
+	runAsNonRoot := pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.RunAsNonRoot != nil && *pod.Spec.SecurityContext.RunAsNonRoot
+
+	apply := func(containers []corev1.Container) []corev1.Container {
+		if len(containers) == 0 {
+			return containers
+		}
+		applied := make([]corev1.Container, len(containers))
+		for i := range containers {
+			c := *containers[i].DeepCopy()
+			switch {
+			case c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged:
+				if runAsNonRoot && c.SecurityContext.RunAsNonRoot == nil {
+					asRoot := false
+					c.SecurityContext.RunAsNonRoot = &asRoot
+				}
+			case defaults.Container == nil:
+			case c.SecurityContext == nil:
+				c.SecurityContext = defaults.Container.DeepCopy()
+			default:
+				fillUnsetFields(c.SecurityContext, defaults.Container.DeepCopy())
+			}
+			applied[i] = c
+		}
+		return applied
+	}
+	pod.Spec.InitContainers = apply(pod.Spec.InitContainers)
+	pod.Spec.Containers = apply(pod.Spec.Containers)
+}
+
+// fillUnsetFields sets the zero fields of the struct dst points to to the fields of the struct defaults points to.
+func fillUnsetFields(dst, defaults interface{}) {
+	d, v := reflect.ValueOf(dst).Elem(), reflect.ValueOf(defaults).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		if f := d.Field(i); f.IsZero() {
+			f.Set(v.Field(i))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package actionsgithubcom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApplyDefaultSecurityContext(t *testing.T) {
+	defaults, err := ParseDefaultSecurityContext(
+		`{"runAsNonRoot":true,"runAsUser":1001,"seccompProfile":{"type":"RuntimeDefault"}}`,
+		"allowPrivilegeEscalation: false\ncapabilities:\n  drop: [ALL]\n",
+	)
+	require.NoError(t, err)
+
+	privileged, runAsUser := true, int64(0)
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{RunAsUser: &runAsUser},
+			InitContainers: []corev1.Container{
+				{Name: "init"},
+			},
+			Containers: []corev1.Container{
+				{
+					Name: EphemeralRunnerContainerName,
+					SecurityContext: &corev1.SecurityContext{
+						Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_PTRACE"}},
+					},
+				},
+				{
+					Name:            dindContainerName,
+					SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				},
+			},
+		},
+	}
+
+	applyDefaultSecurityContext(pod, defaults)
+
+	assert.Equal(t, int64(0), *pod.Spec.SecurityContext.RunAsUser, "The fields set by the pod are kept")
+	assert.True(t, *pod.Spec.SecurityContext.RunAsNonRoot)
+	assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, pod.Spec.SecurityContext.SeccompProfile.Type)
+
+	assert.Equal(t, defaults.Container, pod.Spec.InitContainers[0].SecurityContext)
+
+	runner := pod.Spec.Containers[0].SecurityContext
+	assert.False(t, *runner.AllowPrivilegeEscalation)
+	assert.Equal(t, &corev1.Capabilities{Add: []corev1.Capability{"SYS_PTRACE"}}, runner.Capabilities, "The capabilities of the container replace the default ones")
+
+	dind := pod.Spec.Containers[1].SecurityContext
+	assert.Nil(t, dind.Capabilities, "Privileged containers are left as they are")
+	require.NotNil(t, dind.RunAsNonRoot)
+	assert.False(t, *dind.RunAsNonRoot, "Privileged containers keep running as root")
+
+	// The defaults are copied into the pod.
+	*pod.Spec.SecurityContext.RunAsNonRoot = false
+	assert.True(t, *defaults.Pod.RunAsNonRoot)
+
+	windows := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"kubernetes.io/os": "windows"},
+			Containers:   []corev1.Container{{Name: EphemeralRunnerContainerName}},
+		},
+	}
+	applyDefaultSecurityContext(windows, defaults)
+	assert.Nil(t, windows.Spec.SecurityContext)
+	assert.Nil(t, windows.Spec.Containers[0].SecurityContext)
+}
+
+func TestParseDefaultSecurityContext(t *testing.T) {
+	defaults, err := ParseDefaultSecurityContext("", "")
+	require.NoError(t, err)
+	assert.Nil(t, defaults)
+
+	_, err = ParseDefaultSecurityContext(`{"runAsNonRoot":"yes"}`, "")
+	assert.Error(t, err)
+	_, err = ParseDefaultSecurityContext("", `{"dropCapabilities":["ALL"]}`)
+	assert.Error(t, err)
+}
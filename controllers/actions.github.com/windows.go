@@ -0,0 +1,83 @@
+package actionsgithubcom
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// WindowsRunnerWorkingDir is the default working directory of the runner container in Windows runner pods.
+	WindowsRunnerWorkingDir = `C:\actions-runner`
+	// WindowsRunnerCommand is the default command of the runner container in Windows runner pods.
+	WindowsRunnerCommand = WindowsRunnerWorkingDir + `\run.cmd`
+	// WindowsGitHubServerTLSMountPath is where the GitHub server CA bundle is mounted in Windows runner pods.
+	WindowsGitHubServerTLSMountPath = `C:\actions-runner-controller\certs`
+	// WindowsRunnerHooksMountPath is where the runner hook scripts are mounted in Windows runner pods.
+	WindowsRunnerHooksMountPath = `C:\actions-runner-controller\hooks`
+	// WindowsRunnerToolCacheMountPath is where the tool cache is mounted in Windows runner pods,
+	// as on the GitHub-hosted runners.
+	WindowsRunnerToolCacheMountPath = `C:\hostedtoolcache\windows`
+
+	labelKeyNodeOS = "kubernetes.io/os"
+
+	// envVarContainerHooks enables the runner container hooks, which need a Linux runner.
+	envVarContainerHooks = "ACTIONS_RUNNER_CONTAINER_HOOKS"
+	dindContainerName    = "dind"
+)
+
+// isWindowsPod reports whether the pod is meant to run on Windows nodes,
+// from either its OS or its node selector.
+func isWindowsPod(spec *corev1.PodSpec) bool {
+	if spec.OS != nil {
+		return spec.OS.Name == corev1.Windows
+	}
+	return spec.NodeSelector[labelKeyNodeOS] == string(corev1.Windows)
+}
+
+// applyWindowsDefaults sets the OS of the Windows runner pod and defaults the runner container
+// to the layout of Windows runner images, keeping what the pod template sets.
+func applyWindowsDefaults(pod *corev1.Pod) {
+	if pod.Spec.OS == nil {
+		pod.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+	}
+
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		if c.WorkingDir == "" {
+			c.WorkingDir = WindowsRunnerWorkingDir
+		}
+		if len(c.Command) == 0 {
+			c.Command = []string{WindowsRunnerCommand}
+		}
+	}
+}
+
+// validateWindowsTemplate returns an error when a Windows pod template uses a container mode
+// Windows nodes can't run: Docker in Docker requires privileged containers, and container hooks
+// run the job containers as Linux pods.
+func validateWindowsTemplate(template *corev1.PodTemplateSpec) error {
+	if !isWindowsPod(&template.Spec) {
+		return nil
+	}
+
+	containers := append(append([]corev1.Container{}, template.Spec.InitContainers...), template.Spec.Containers...)
+	for _, c := range containers {
+		if c.Name == dindContainerName || (c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged) {
+			return fmt.Errorf("container %q: Docker in Docker is not supported on Windows", c.Name)
+		}
+		if c.Name != EphemeralRunnerContainerName {
+			continue
+		}
+		for _, env := range c.Env {
+			if env.Name == envVarContainerHooks {
+				return fmt.Errorf("container %q: container hooks are not supported on Windows", c.Name)
+			}
+		}
+	}
+
+	return nil
+}
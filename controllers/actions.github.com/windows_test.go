@@ -0,0 +1,44 @@
+package actionsgithubcom
+
+import (
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateRunnerTemplates_Windows(t *testing.T) {
+	privileged := true
+	windows := map[string]string{"kubernetes.io/os": "windows"}
+	newAutoscalingRunnerSet := func(nodeSelector map[string]string, containers ...corev1.Container) *v1alpha1.AutoscalingRunnerSet {
+		return &v1alpha1.AutoscalingRunnerSet{
+			Spec: v1alpha1.AutoscalingRunnerSetSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						NodeSelector: nodeSelector,
+						Containers:   append([]corev1.Container{{Name: EphemeralRunnerContainerName}}, containers...),
+					},
+				},
+			},
+		}
+	}
+	dind := corev1.Container{
+		Name:            "dind",
+		SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+	}
+
+	assert.NoError(t, validateRunnerTemplates(newAutoscalingRunnerSet(windows)))
+	assert.NoError(t, validateRunnerTemplates(newAutoscalingRunnerSet(nil, dind)), "dind is supported on linux")
+	assert.ErrorContains(t, validateRunnerTemplates(newAutoscalingRunnerSet(windows, dind)), "Docker in Docker")
+
+	kubernetesMode := newAutoscalingRunnerSet(windows)
+	kubernetesMode.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "ACTIONS_RUNNER_CONTAINER_HOOKS", Value: "/actions-runner/k8s/index.js"}}
+	assert.ErrorContains(t, validateRunnerTemplates(kubernetesMode), "container hooks")
+
+	placement := newAutoscalingRunnerSet(nil, dind)
+	placement.Spec.NodePlacements = []v1alpha1.NodePlacement{
+		{Name: "windows", Labels: []string{"windows"}, NodeSelector: windows},
+	}
+	assert.ErrorContains(t, validateRunnerTemplates(placement), `variant "windows"`)
+}
@@ -0,0 +1,415 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	gogithub "github.com/google/go-github/v47/github"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// queuedJobRetention bounds how long the ID of a queued job is kept to ignore
+// redelivered webhook events for the same job.
+const queuedJobRetention = time.Hour
+
+// queuedJobHintRetention bounds how long a queued job hint adds a runner when the listener
+// never sees a job of its workflow run assigned, e.g. when another scale set took the job.
+const queuedJobHintRetention = 5 * time.Minute
+
+// WorkflowJobWebhookServer receives the workflow_job webhook events of GitHub, and adds a runner
+// to the autoscaling runner sets whose runner scale set is requested by a queued job.
+// It reacts ahead of the listener with a queued job hint on the latest runner set, which adds a runner
+// on top of the replicas of the listener until the listener sees the job assigned.
+// It also records the workflow name and the requester of the jobs in progress on their ephemeral runners.
+//
+// The server runs on every replica, so the jobs seen queued are recorded on the autoscaling runner sets,
+// where each replica finds them.
+type WorkflowJobWebhookServer struct {
+	Client client.Client
+	Log    logr.Logger
+	Addr   string
+
+	// SecretKey validates the signature of the events. The server doesn't start without it,
+	// since anyone reaching it could scale up the runner scale sets otherwise.
+	SecretKey []byte
+
+	now func() time.Time
+}
+
+// Handler returns the handler of the webhook events, served on every path.
+func (s *WorkflowJobWebhookServer) Handler() http.Handler {
+	return http.HandlerFunc(s.handleEvent)
+}
+
+func (s *WorkflowJobWebhookServer) handleEvent(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	// Respond ok to GET, e.g. for health checks.
+	if r.Method == http.MethodGet {
+		fmt.Fprintln(w, "webhook server is running")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost}, ", "))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := gogithub.ValidatePayload(r, s.SecretKey)
+	if err != nil {
+		s.Log.Error(err, "Failed to read webhook event")
+		http.Error(w, "invalid webhook event", http.StatusBadRequest)
+		return
+	}
+
+	eventType := gogithub.WebHookType(r)
+	event, err := gogithub.ParseWebHook(eventType, payload)
+	if err != nil {
+		s.Log.Error(err, "Failed to parse webhook event", "event", eventType)
+		http.Error(w, "invalid webhook event", http.StatusBadRequest)
+		return
+	}
+
+	log := s.Log.WithValues("event", eventType, "delivery", r.Header.Get("X-GitHub-Delivery"))
+
+	e, ok := event.(*gogithub.WorkflowJobEvent)
+	if ok && e.GetAction() == "in_progress" && e.GetWorkflowJob().GetRunnerName() != "" {
+		recorded, err := s.recordJobMetadata(r.Context(), e, payload, eventEnterprise(payload, log), log)
+		if err != nil {
+			log.Error(err, "Failed to record the metadata of the workflow job")
+			http.Error(w, "failed to record job metadata", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "recorded the job metadata of %d ephemeral runners\n", recorded)
+		return
+	}
+	if !ok || e.GetAction() != "queued" || e.GetWorkflowJob() == nil {
+		fmt.Fprintln(w, "event ignored")
+		return
+	}
+
+	job := e.GetWorkflowJob()
+	log = log.WithValues("repository", e.GetRepo().GetFullName(), "jobId", job.GetID(), "labels", job.Labels)
+
+	scaled, err := s.scaleUpForQueuedJob(r.Context(), e, eventEnterprise(payload, log), log)
+	if err != nil {
+		log.Error(err, "Failed to scale up for the queued workflow job")
+		http.Error(w, "failed to scale up", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "scaled up %d runner scale sets\n", scaled)
+}
+
+// eventEnterprise returns the slug of the enterprise of the event, which is not part of WorkflowJobEvent.
+func eventEnterprise(payload []byte, log logr.Logger) string {
+	var enterpriseEvent struct {
+		Enterprise struct {
+			Slug string `json:"slug,omitempty"`
+		} `json:"enterprise,omitempty"`
+	}
+	if err := json.Unmarshal(payload, &enterpriseEvent); err != nil {
+		log.Error(err, "Failed to parse the enterprise of the webhook event")
+	}
+	return enterpriseEvent.Enterprise.Slug
+}
+
+// claimQueuedJob records the job as queued on the autoscaling runner set, and reports whether the job is seen
+// queued for the first time, as GitHub redelivers events that were not acknowledged in time, possibly to another
+// replica. The jobs recorded for longer than queuedJobRetention are dropped.
+func (s *WorkflowJobWebhookServer) claimQueuedJob(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, jobId int64) (bool, error) {
+	claimed := false
+	err := s.updateQueuedJobs(ctx, autoscalingRunnerSet, func(queuedJobs map[string]time.Time) bool {
+		key := strconv.FormatInt(jobId, 10)
+		if _, ok := queuedJobs[key]; ok {
+			claimed = false
+			return false
+		}
+		queuedJobs[key] = s.clock()().UTC()
+		claimed = true
+		return true
+	})
+	return claimed, err
+}
+
+// releaseQueuedJob removes the job recorded by claimQueuedJob, so that the event is handled again when
+// GitHub redelivers it.
+func (s *WorkflowJobWebhookServer) releaseQueuedJob(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, jobId int64) error {
+	return s.updateQueuedJobs(ctx, autoscalingRunnerSet, func(queuedJobs map[string]time.Time) bool {
+		key := strconv.FormatInt(jobId, 10)
+		if _, ok := queuedJobs[key]; !ok {
+			return false
+		}
+		delete(queuedJobs, key)
+		return true
+	})
+}
+
+// updateQueuedJobs applies update to the unexpired queued jobs of the autoscaling runner set, and patches them
+// with an optimistic lock when update reports a change.
+func (s *WorkflowJobWebhookServer) updateQueuedJobs(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, update func(queuedJobs map[string]time.Time) bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.Client.Get(ctx, client.ObjectKeyFromObject(autoscalingRunnerSet), autoscalingRunnerSet); err != nil {
+			return err
+		}
+
+		queuedJobs := make(map[string]time.Time)
+		if raw, ok := autoscalingRunnerSet.Annotations[AnnotationKeyQueuedWorkflowJobs]; ok {
+			// An invalid annotation is replaced.
+			_ = json.Unmarshal([]byte(raw), &queuedJobs)
+		}
+		now := s.clock()()
+		expired := false
+		for id, queuedAt := range queuedJobs {
+			if now.Sub(queuedAt) > queuedJobRetention {
+				delete(queuedJobs, id)
+				expired = true
+			}
+		}
+		if !update(queuedJobs) && !expired {
+			return nil
+		}
+
+		raw, err := json.Marshal(queuedJobs)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the queued workflow jobs: %v", err)
+		}
+		original := autoscalingRunnerSet.DeepCopy()
+		if autoscalingRunnerSet.Annotations == nil {
+			autoscalingRunnerSet.Annotations = map[string]string{}
+		}
+		autoscalingRunnerSet.Annotations[AnnotationKeyQueuedWorkflowJobs] = string(raw)
+		return s.Client.Patch(ctx, autoscalingRunnerSet, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// scaleUpForQueuedJob adds a queued job hint to the latest runner set of the autoscaling runner sets matching
+// the queued job, within their MaxRunners, and returns the number of runner sets scaled up.
+func (s *WorkflowJobWebhookServer) scaleUpForQueuedJob(ctx context.Context, e *gogithub.WorkflowJobEvent, enterprise string, log logr.Logger) (int, error) {
+	list := new(v1alpha1.AutoscalingRunnerSetList)
+	if err := s.Client.List(ctx, list); err != nil {
+		return 0, fmt.Errorf("failed to list autoscaling runner sets: %v", err)
+	}
+
+	scaled := 0
+	for i := range list.Items {
+		autoscalingRunnerSet := &list.Items[i]
+		if !autoscalingRunnerSet.DeletionTimestamp.IsZero() || !workflowJobMatches(autoscalingRunnerSet, e, enterprise) {
+			continue
+		}
+
+		runnerSets, err := listEphemeralRunnerSets(ctx, s.Client, autoscalingRunnerSet)
+		if err != nil {
+			return scaled, err
+		}
+		latestRunnerSet := runnerSets.latest()
+		if latestRunnerSet == nil {
+			continue
+		}
+
+		jobId := e.GetWorkflowJob().GetID()
+		claimed, err := s.claimQueuedJob(ctx, autoscalingRunnerSet, jobId)
+		if err != nil {
+			return scaled, fmt.Errorf("failed to record the queued workflow job on autoscaling runner set %s/%s: %v", autoscalingRunnerSet.Namespace, autoscalingRunnerSet.Name, err)
+		}
+		if !claimed {
+			log.Info("Ignoring a workflow job queued again", "namespace", autoscalingRunnerSet.Namespace, "name", autoscalingRunnerSet.Name)
+			continue
+		}
+
+		hint := v1alpha1.QueuedJobHint{WorkflowRunId: e.GetWorkflowJob().GetRunID(), QueuedAt: metav1.NewTime(s.clock()())}
+		added, err := s.addQueuedJobHint(ctx, latestRunnerSet, hint, autoscalingRunnerSet.Spec.MaxRunners)
+		if err != nil {
+			// Let GitHub redeliver the event.
+			if err := s.releaseQueuedJob(ctx, autoscalingRunnerSet, jobId); err != nil {
+				log.Error(err, "Failed to release the queued workflow job", "namespace", autoscalingRunnerSet.Namespace, "name", autoscalingRunnerSet.Name)
+			}
+			return scaled, fmt.Errorf("failed to add a queued job hint to ephemeral runner set %s/%s: %v", latestRunnerSet.Namespace, latestRunnerSet.Name, err)
+		}
+		if !added {
+			log.Info("Runner scale set is at its max runners", "namespace", autoscalingRunnerSet.Namespace, "name", autoscalingRunnerSet.Name, "maxRunners", *autoscalingRunnerSet.Spec.MaxRunners)
+			continue
+		}
+
+		log.Info("Added a queued job hint to the runner set for the queued workflow job", "namespace", latestRunnerSet.Namespace, "name", latestRunnerSet.Name, "workflowRunId", hint.WorkflowRunId)
+		scaled++
+	}
+
+	return scaled, nil
+}
+
+// addQueuedJobHint adds the hint to the runner set unless its runners would exceed max. The listener
+// removes the hints concurrently, so the hint is added with an optimistic lock.
+func (s *WorkflowJobWebhookServer) addQueuedJobHint(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet, hint v1alpha1.QueuedJobHint, max *int) (bool, error) {
+	added := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.Client.Get(ctx, client.ObjectKeyFromObject(runnerSet), runnerSet); err != nil {
+			return err
+		}
+		if max != nil && runnerSet.Spec.Replicas+len(runnerSet.Spec.QueuedJobHints)+1 > *max {
+			added = false
+			return nil
+		}
+
+		original := runnerSet.DeepCopy()
+		runnerSet.Spec.QueuedJobHints = append(runnerSet.Spec.QueuedJobHints, hint)
+		added = true
+		return s.Client.Patch(ctx, runnerSet, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	})
+	return added, err
+}
+
+func (s *WorkflowJobWebhookServer) clock() func() time.Time {
+	if s.now != nil {
+		return s.now
+	}
+	return time.Now
+}
+
+// recordJobMetadata records the workflow name and the requester of the job, which the listener doesn't know,
+// in the status of the ephemeral runner running it. The runner is looked up by name in the autoscaling runner
+// sets matching the job, and must belong to one of their runner sets and have the runner ID of the job, so that
+// runners of the same name in other namespaces or clusters are left alone. It returns the number of ephemeral
+// runners updated.
+func (s *WorkflowJobWebhookServer) recordJobMetadata(ctx context.Context, e *gogithub.WorkflowJobEvent, payload []byte, enterprise string, log logr.Logger) (int, error) {
+	// The workflow name of the job is not part of WorkflowJob.
+	var workflowJobEvent struct {
+		WorkflowJob struct {
+			WorkflowName string `json:"workflow_name,omitempty"`
+		} `json:"workflow_job,omitempty"`
+	}
+	if err := json.Unmarshal(payload, &workflowJobEvent); err != nil {
+		log.Error(err, "Failed to parse the workflow name of the webhook event")
+	}
+	workflowName := workflowJobEvent.WorkflowJob.WorkflowName
+	requester := e.GetSender().GetLogin()
+	job := e.GetWorkflowJob()
+
+	list := new(v1alpha1.AutoscalingRunnerSetList)
+	if err := s.Client.List(ctx, list); err != nil {
+		return 0, fmt.Errorf("failed to list autoscaling runner sets: %v", err)
+	}
+
+	recorded := 0
+	for i := range list.Items {
+		autoscalingRunnerSet := &list.Items[i]
+		if !workflowJobMatches(autoscalingRunnerSet, e, enterprise) {
+			continue
+		}
+
+		ephemeralRunner := new(v1alpha1.EphemeralRunner)
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: autoscalingRunnerSet.Namespace, Name: job.GetRunnerName()}, ephemeralRunner); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return recorded, fmt.Errorf("failed to get ephemeral runner %s/%s: %v", autoscalingRunnerSet.Namespace, job.GetRunnerName(), err)
+		}
+		if job.GetRunnerID() != 0 && int64(ephemeralRunner.Status.RunnerId) != job.GetRunnerID() {
+			continue
+		}
+		ownedBy, err := ownedByAutoscalingRunnerSet(ctx, s.Client, ephemeralRunner, autoscalingRunnerSet)
+		if err != nil {
+			return recorded, err
+		}
+		if !ownedBy {
+			continue
+		}
+		if ephemeralRunner.Status.JobWorkflowName == workflowName && ephemeralRunner.Status.JobRequester == requester {
+			continue
+		}
+
+		log.Info("Recording the job metadata of the ephemeral runner", "namespace", ephemeralRunner.Namespace, "name", ephemeralRunner.Name, "workflowName", workflowName, "requester", requester)
+		if err := patchSubResource(ctx, s.Client.Status(), ephemeralRunner, func(obj *v1alpha1.EphemeralRunner) {
+			obj.Status.JobWorkflowName = workflowName
+			obj.Status.JobRequester = requester
+		}); err != nil {
+			return recorded, fmt.Errorf("failed to update ephemeral runner %s/%s: %v", ephemeralRunner.Namespace, ephemeralRunner.Name, err)
+		}
+		recorded++
+	}
+
+	return recorded, nil
+}
+
+// ownedByAutoscalingRunnerSet reports whether the ephemeral runner belongs to a runner set of the autoscaling runner set.
+func ownedByAutoscalingRunnerSet(ctx context.Context, c client.Reader, ephemeralRunner *v1alpha1.EphemeralRunner, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) (bool, error) {
+	owner := metav1.GetControllerOf(ephemeralRunner)
+	if owner == nil || owner.Kind != "EphemeralRunnerSet" {
+		return false, nil
+	}
+
+	runnerSets, err := listEphemeralRunnerSets(ctx, c, autoscalingRunnerSet)
+	if err != nil {
+		return false, err
+	}
+	for _, runnerSet := range runnerSets.list.Items {
+		if runnerSet.Name == owner.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// workflowJobMatches reports whether the queued job runs on the runner scale set of the autoscaling runner set:
+// the job requests the runner scale set name, and the repository of the job is in the scope of the scale set.
+func workflowJobMatches(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, e *gogithub.WorkflowJobEvent, enterprise string) bool {
+	requested := false
+	for _, label := range e.GetWorkflowJob().Labels {
+		if strings.EqualFold(label, autoscalingRunnerSet.RunnerScaleSetName()) {
+			requested = true
+			break
+		}
+	}
+	if !requested {
+		return false
+	}
+
+	config, err := actions.ParseGitHubConfigFromURL(autoscalingRunnerSet.Spec.GitHubConfigUrl)
+	if err != nil {
+		return false
+	}
+
+	owner := e.GetRepo().GetOwner().GetLogin()
+	switch config.Scope {
+	case actions.GitHubScopeRepository:
+		return strings.EqualFold(config.Organization, owner) && strings.EqualFold(config.Repository, e.GetRepo().GetName())
+	case actions.GitHubScopeOrganization:
+		return strings.EqualFold(config.Organization, owner)
+	case actions.GitHubScopeEnterprise:
+		return strings.EqualFold(config.Enterprise, enterprise)
+	}
+	return false
+}
+
+// Start serves the webhook events until the context is done.
+func (s *WorkflowJobWebhookServer) Start(ctx context.Context) error {
+	if len(s.SecretKey) == 0 {
+		return fmt.Errorf("the workflow job webhook server requires a secret token to validate the events")
+	}
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.Log.Info("Starting workflow job webhook server", "addr", s.Addr)
+	return serveUntilDone(ctx, server, server.ListenAndServe)
+}
+
+// NeedLeaderElection receives the webhook events on every replica behind the same service.
+func (s *WorkflowJobWebhookServer) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,248 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+)
+
+func TestWorkflowJobWebhookServer(t *testing.T) {
+	isController := true
+	maxRunners := 3
+	newAutoscalingRunnerSet := func(name, configUrl string) *v1alpha1.AutoscalingRunnerSet {
+		return &v1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "runners"},
+			Spec: v1alpha1.AutoscalingRunnerSetSpec{
+				GitHubConfigUrl: configUrl,
+				MaxRunners:      &maxRunners,
+			},
+		}
+	}
+	newRunnerSet := func(owner string, replicas int) *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      owner + "-abcde",
+				Namespace: "runners",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: v1alpha1.GroupVersion.String(),
+						Kind:       "AutoscalingRunnerSet",
+						Name:       owner,
+						Controller: &isController,
+					},
+				},
+			},
+			Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: replicas},
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	kubeClient := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			newAutoscalingRunnerSet("linux", "https://github.com/owner"),
+			newRunnerSet("linux", 1),
+			newAutoscalingRunnerSet("other-repo", "https://github.com/owner/other"),
+			newRunnerSet("other-repo", 1),
+			newAutoscalingRunnerSet("full", "https://github.com/owner/repo"),
+			newRunnerSet("full", maxRunners),
+		).
+		WithIndex(&v1alpha1.EphemeralRunnerSet{}, autoscalingRunnerSetOwnerKey, func(o client.Object) []string {
+			owner := metav1.GetControllerOf(o)
+			if owner == nil {
+				return nil
+			}
+			return []string{owner.Name}
+		}).
+		Build()
+
+	secret := []byte("webhook-secret")
+	server := &WorkflowJobWebhookServer{
+		Client:    kubeClient,
+		Log:       logr.Discard(),
+		SecretKey: secret,
+	}
+
+	sendTo := func(server *WorkflowJobWebhookServer, body string, sign bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "workflow_job")
+		if sign {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(body))
+			req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	send := func(body string, sign bool) *httptest.ResponseRecorder {
+		return sendTo(server, body, sign)
+	}
+	runnerSet := func(name string) *v1alpha1.EphemeralRunnerSet {
+		runnerSet := new(v1alpha1.EphemeralRunnerSet)
+		require.NoError(t, kubeClient.Get(context.Background(), client.ObjectKey{Namespace: "runners", Name: name + "-abcde"}, runnerSet))
+		return runnerSet
+	}
+
+	queued := func(action string, jobId int, labels string) string {
+		return `{"action":"` + action + `","workflow_job":{"id":` + strconv.Itoa(jobId) + `,"run_id":10,"labels":[` + labels + `]},` +
+			`"repository":{"name":"repo","full_name":"owner/repo","owner":{"login":"owner"}}}`
+	}
+
+	rec := send(queued("queued", 1, `"linux"`), false)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "Unsigned events are rejected")
+	assert.Empty(t, runnerSet("linux").Spec.QueuedJobHints)
+
+	rec = send(queued("queued", 1, `"linux"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	linux := runnerSet("linux")
+	require.Len(t, linux.Spec.QueuedJobHints, 1)
+	assert.Equal(t, int64(10), linux.Spec.QueuedJobHints[0].WorkflowRunId)
+	assert.Equal(t, 1, linux.Spec.Replicas, "The replicas are left to the listener")
+	assert.Equal(t, 2, desiredReplicas(linux, 0))
+	assert.Empty(t, runnerSet("other-repo").Spec.QueuedJobHints, "Repositories out of the scope of the scale set are ignored")
+
+	rec = send(queued("queued", 1, `"linux"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, runnerSet("linux").Spec.QueuedJobHints, 1, "Redelivered events are ignored")
+
+	// Another replica of the server sees the jobs recorded on the autoscaling runner set.
+	replica := &WorkflowJobWebhookServer{Client: kubeClient, Log: logr.Discard(), SecretKey: secret}
+	rec = sendTo(replica, queued("queued", 1, `"linux"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, runnerSet("linux").Spec.QueuedJobHints, 1, "Events redelivered to another replica are ignored")
+
+	rec = send(queued("completed", 2, `"linux"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, runnerSet("linux").Spec.QueuedJobHints, 1, "Only queued jobs scale up")
+
+	rec = send(queued("queued", 3, `"linux"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, runnerSet("linux").Spec.QueuedJobHints, 2)
+
+	rec = send(queued("queued", 4, `"linux"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, runnerSet("linux").Spec.QueuedJobHints, 2, "The hints don't take the scale set above its max runners")
+
+	rec = send(queued("queued", 5, `"full"`), true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, runnerSet("full").Spec.QueuedJobHints, "Scale sets are not scaled above their max runners")
+}
+
+func TestWorkflowJobWebhookServer_RequiresSecret(t *testing.T) {
+	server := &WorkflowJobWebhookServer{Log: logr.Discard(), Addr: "127.0.0.1:0"}
+	err := server.Start(context.Background())
+	require.Error(t, err, "Anyone could scale up the runner scale sets without validating the events")
+}
+
+func TestUnexpiredQueuedJobHints(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	hints := []v1alpha1.QueuedJobHint{
+		{WorkflowRunId: 1, QueuedAt: metav1.NewTime(now.Add(-queuedJobHintRetention))},
+		{WorkflowRunId: 2, QueuedAt: metav1.NewTime(now.Add(-time.Minute))},
+		{WorkflowRunId: 3, QueuedAt: metav1.NewTime(now.Add(-2 * time.Minute))},
+	}
+
+	unexpired, nextExpiry := unexpiredQueuedJobHints(hints, now)
+	assert.Equal(t, hints[1:], unexpired)
+	assert.Equal(t, queuedJobHintRetention-2*time.Minute, nextExpiry)
+
+	runnerSet := &v1alpha1.EphemeralRunnerSet{Spec: v1alpha1.EphemeralRunnerSetSpec{Replicas: 1, QueuedJobHints: unexpired}}
+	desired, trigger := desiredReplicasWithTrigger(runnerSet, 0)
+	assert.Equal(t, 3, desired, "Each hint adds a runner on top of the replicas of the listener")
+	assert.Equal(t, v1alpha1.ScaleTriggerWorkflowJobWebhook, trigger)
+}
+
+func TestWorkflowJobWebhookServer_JobMetadata(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	isController := true
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "linux", Namespace: "runners"},
+		Spec:       v1alpha1.AutoscalingRunnerSetSpec{GitHubConfigUrl: "https://github.com/owner"},
+	}
+	runnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "linux-abcde",
+			Namespace: "runners",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: v1alpha1.GroupVersion.String(), Kind: "AutoscalingRunnerSet", Name: "linux", Controller: &isController},
+			},
+		},
+	}
+	newEphemeralRunner := func(namespace string, runnerId int) *v1alpha1.EphemeralRunner {
+		return &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "linux-abcde-runner-fghij",
+				Namespace: namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: v1alpha1.GroupVersion.String(), Kind: "EphemeralRunnerSet", Name: "linux-abcde", Controller: &isController},
+				},
+			},
+			Status: v1alpha1.EphemeralRunnerStatus{JobRequestId: 7, RunnerId: runnerId},
+		}
+	}
+	ephemeralRunner := newEphemeralRunner("runners", 12)
+	// A runner of the same name and runner set name in a namespace without a matching scale set.
+	unrelated := newEphemeralRunner("other", 12)
+	kubeClient := crfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(autoscalingRunnerSet, runnerSet, ephemeralRunner, unrelated).
+		WithIndex(&v1alpha1.EphemeralRunnerSet{}, autoscalingRunnerSetOwnerKey, func(o client.Object) []string {
+			owner := metav1.GetControllerOf(o)
+			if owner == nil {
+				return nil
+			}
+			return []string{owner.Name}
+		}).
+		Build()
+	secret := []byte("webhook-secret")
+	server := &WorkflowJobWebhookServer{Client: kubeClient, Log: logr.Discard(), SecretKey: secret}
+
+	send := func(runnerId int, workflowName string) {
+		body := `{"action":"in_progress","workflow_job":{"id":1,"runner_id":` + strconv.Itoa(runnerId) + `,"runner_name":"linux-abcde-runner-fghij","workflow_name":"` + workflowName + `","labels":["linux"]},` +
+			`"repository":{"name":"repo","full_name":"owner/repo","owner":{"login":"owner"}},"sender":{"login":"octocat"}}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "workflow_job")
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	send(99, "Other")
+	updated := new(v1alpha1.EphemeralRunner)
+	require.NoError(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(ephemeralRunner), updated))
+	assert.Empty(t, updated.Status.JobWorkflowName, "Jobs of another runner of the same name are ignored")
+
+	send(12, "CI")
+	require.NoError(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(ephemeralRunner), updated))
+	assert.Equal(t, "CI", updated.Status.JobWorkflowName)
+	assert.Equal(t, "octocat", updated.Status.JobRequester)
+	assert.Equal(t, int64(7), updated.Status.JobRequestId, "The job reported by the listener is kept")
+
+	require.NoError(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(unrelated), updated))
+	assert.Empty(t, updated.Status.JobWorkflowName, "Runners out of the scale sets matching the job are left alone")
+}
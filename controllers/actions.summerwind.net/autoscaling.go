@@ -30,6 +30,12 @@ func (r *HorizontalRunnerAutoscalerReconciler) suggestDesiredReplicas(ghc *arcgi
 		return nil, fmt.Errorf("horizontalrunnerautoscaler %s/%s is missing maxReplicas", hra.Namespace, hra.Name)
 	}
 
+	if st.kind == "autoscalingrunnerset" {
+		// Runners of autoscaling runner sets are scaled by their listener,
+		// so only minReplicas and capacity reservations apply.
+		return nil, nil
+	}
+
 	metrics := hra.Spec.Metrics
 	numMetrics := len(metrics)
 	if numMetrics == 0 {
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,8 +36,10 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	actionsgithubcomv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
 	"github.com/actions/actions-runner-controller/github"
+	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/simulator"
 )
 
@@ -85,6 +88,7 @@ func (autoscaler *HorizontalRunnerAutoscalerGitHubWebhook) Reconcile(_ context.C
 // +kubebuilder:rbac:groups=actions.summerwind.dev,resources=horizontalrunnerautoscalers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.summerwind.dev,resources=horizontalrunnerautoscalers/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.summerwind.dev,resources=horizontalrunnerautoscalers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 func (autoscaler *HorizontalRunnerAutoscalerGitHubWebhook) Handle(w http.ResponseWriter, r *http.Request) {
@@ -502,6 +506,13 @@ func (autoscaler *HorizontalRunnerAutoscalerGitHubWebhook) getManagedRunnerGroup
 				return groups, err
 			}
 			o, e, g = rd.Spec.Template.Spec.Organization, rd.Spec.Template.Spec.Enterprise, rd.Spec.Template.Spec.Group
+		case "AutoscalingRunnerSet":
+			var ars actionsgithubcomv1alpha1.AutoscalingRunnerSet
+			if err := autoscaler.Client.Get(context.Background(), types.NamespacedName{Namespace: hra.Namespace, Name: hra.Spec.ScaleTargetRef.Name}, &ars); err != nil {
+				return groups, err
+			}
+			e, o, _ = autoscalingRunnerSetScope(&ars)
+			g = ars.Spec.RunnerGroup
 		default:
 			return nil, fmt.Errorf("unsupported scale target kind: %v", kind)
 		}
@@ -650,6 +661,26 @@ HRA:
 				}
 			}
 
+			return &ScaleTarget{HorizontalRunnerAutoscaler: hra, ScaleUpTrigger: v1alpha1.ScaleUpTrigger{Duration: duration}}, nil
+		case "AutoscalingRunnerSet":
+			var ars actionsgithubcomv1alpha1.AutoscalingRunnerSet
+
+			if err := autoscaler.Client.Get(context.Background(), types.NamespacedName{Namespace: hra.Namespace, Name: hra.Spec.ScaleTargetRef.Name}, &ars); err != nil {
+				return nil, err
+			}
+
+			// Runners of a runner scale set have a single label, the name of the runner scale set.
+			for _, l := range labels {
+				// ignore "self-hosted" label as all instance here are self-hosted
+				if l == "self-hosted" {
+					continue
+				}
+
+				if !strings.EqualFold(l, ars.RunnerScaleSetName()) {
+					continue HRA
+				}
+			}
+
 			return &ScaleTarget{HorizontalRunnerAutoscaler: hra, ScaleUpTrigger: v1alpha1.ScaleUpTrigger{Duration: duration}}, nil
 		default:
 			return nil, fmt.Errorf("unsupported scaleTargetRef.kind: %v", hra.Spec.ScaleTargetRef.Kind)
@@ -752,11 +783,59 @@ func (autoscaler *HorizontalRunnerAutoscalerGitHubWebhook) indexer(rawObj client
 		}
 		autoscaler.Log.V(2).Info(fmt.Sprintf("HRA keys indexed for HRA %s: %v", hra.Name, keys))
 		return keys
+	case "AutoscalingRunnerSet":
+		var ars actionsgithubcomv1alpha1.AutoscalingRunnerSet
+		if err := autoscaler.Client.Get(context.Background(), types.NamespacedName{Namespace: hra.Namespace, Name: hra.Spec.ScaleTargetRef.Name}, &ars); err != nil {
+			autoscaler.Log.V(1).Info(fmt.Sprintf("AutoscalingRunnerSet not found with scale target ref name %s for hra %s", hra.Spec.ScaleTargetRef.Name, hra.Name))
+			return nil
+		}
+
+		enterprise, org, repo := autoscalingRunnerSetScope(&ars)
+		group := ars.Spec.RunnerGroup
+
+		keys := []string{}
+		if repo != "" {
+			keys = append(keys, repo) // Repository runners
+		}
+		if org != "" {
+			keys = append(keys, org) // Organization runners
+			if group != "" {
+				keys = append(keys, organizationalRunnerGroupKey(org, group)) // Organization runner groups
+			}
+		}
+		if enterprise != "" {
+			keys = append(keys, enterpriseKey(enterprise)) // Enterprise runners
+			if group != "" {
+				keys = append(keys, enterpriseRunnerGroupKey(enterprise, group)) // Enterprise runner groups
+			}
+		}
+		autoscaler.Log.V(2).Info(fmt.Sprintf("HRA keys indexed for HRA %s: %v", hra.Name, keys))
+		return keys
 	}
 
 	return nil
 }
 
+// autoscalingRunnerSetScope returns the enterprise, the organization, or the owner/name of the repository
+// the runner scale set of the AutoscalingRunnerSet is registered to, as set for RunnerDeployments.
+func autoscalingRunnerSetScope(ars *actionsgithubcomv1alpha1.AutoscalingRunnerSet) (enterprise, org, repo string) {
+	config, err := actions.ParseGitHubConfigFromURL(ars.Spec.GitHubConfigUrl)
+	if err != nil {
+		return "", "", ""
+	}
+
+	switch config.Scope {
+	case actions.GitHubScopeRepository:
+		return "", "", config.Organization + "/" + config.Repository
+	case actions.GitHubScopeOrganization:
+		return "", config.Organization, ""
+	case actions.GitHubScopeEnterprise:
+		return config.Enterprise, "", ""
+	}
+
+	return "", "", ""
+}
+
 func enterpriseKey(name string) string {
 	return keyPrefixEnterprise + name
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	actionsgithubcomv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	actionsv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
 	"github.com/go-logr/logr"
 	"github.com/google/go-github/v47/github"
@@ -28,6 +29,7 @@ var (
 func init() {
 	_ = clientgoscheme.AddToScheme(sc)
 	_ = actionsv1alpha1.AddToScheme(sc)
+	_ = actionsgithubcomv1alpha1.AddToScheme(sc)
 }
 
 func TestWebhookPing(t *testing.T) {
@@ -361,6 +363,87 @@ func TestWebhookWorkflowJobWithSelfHostedLabel(t *testing.T) {
 	})
 }
 
+func TestWebhookWorkflowJobWithAutoscalingRunnerSet(t *testing.T) {
+	setupTest := func() github.WorkflowJobEvent {
+		f, err := os.Open("testdata/org_webhook_workflow_job_payload.json")
+		if err != nil {
+			t.Fatalf("could not open the fixture: %s", err)
+		}
+		defer f.Close()
+		var e github.WorkflowJobEvent
+		if err := json.NewDecoder(f).Decode(&e); err != nil {
+			t.Fatalf("invalid json: %s", err)
+		}
+
+		return e
+	}
+	newHRA := func() *actionsv1alpha1.HorizontalRunnerAutoscaler {
+		return &actionsv1alpha1.HorizontalRunnerAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-name",
+			},
+			Spec: actionsv1alpha1.HorizontalRunnerAutoscalerSpec{
+				ScaleTargetRef: actionsv1alpha1.ScaleTargetRef{
+					Kind: "AutoscalingRunnerSet",
+					Name: "test-name",
+				},
+				ScaleUpTriggers: []actionsv1alpha1.ScaleUpTrigger{
+					{
+						GitHubEvent: &actionsv1alpha1.GitHubEventScaleUpTriggerSpec{
+							WorkflowJob: &actionsv1alpha1.WorkflowJobSpec{},
+						},
+					},
+				},
+			},
+		}
+	}
+	t.Run("Successful", func(t *testing.T) {
+		e := setupTest()
+
+		ars := &actionsgithubcomv1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-name",
+			},
+			Spec: actionsgithubcomv1alpha1.AutoscalingRunnerSetSpec{
+				GitHubConfigUrl:    "https://github.com/MYORG",
+				RunnerScaleSetName: "label1",
+			},
+		}
+
+		initObjs := []runtime.Object{newHRA(), ars}
+
+		testServerWithInitObjs(t,
+			"workflow_job",
+			&e,
+			200,
+			"scaled test-name by 1",
+			initObjs,
+		)
+	})
+	t.Run("WrongRunnerScaleSetName", func(t *testing.T) {
+		e := setupTest()
+
+		ars := &actionsgithubcomv1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-name",
+			},
+			Spec: actionsgithubcomv1alpha1.AutoscalingRunnerSetSpec{
+				GitHubConfigUrl: "https://github.com/MYORG",
+			},
+		}
+
+		initObjs := []runtime.Object{newHRA(), ars}
+
+		testServerWithInitObjs(t,
+			"workflow_job",
+			&e,
+			200,
+			"no horizontalrunnerautoscaler to scale for this github event",
+			initObjs,
+		)
+	})
+}
+
 func TestGetRequest(t *testing.T) {
 	hra := HorizontalRunnerAutoscalerGitHubWebhook{}
 	request, _ := http.NewRequest(http.MethodGet, "/", nil)
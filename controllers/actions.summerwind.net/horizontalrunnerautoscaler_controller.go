@@ -35,6 +35,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	actionsgithubcomv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
 	"github.com/actions/actions-runner-controller/controllers/actions.summerwind.net/metrics"
 	arcgithub "github.com/actions/actions-runner-controller/github"
@@ -61,6 +62,7 @@ const defaultReplicas = 1
 // +kubebuilder:rbac:groups=actions.summerwind.dev,resources=horizontalrunnerautoscalers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.summerwind.dev,resources=horizontalrunnerautoscalers/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.summerwind.dev,resources=horizontalrunnerautoscalers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=actions.github.com,resources=autoscalingrunnersets,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 func (r *HorizontalRunnerAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -236,11 +238,54 @@ func (r *HorizontalRunnerAutoscalerReconciler) Reconcile(ctx context.Context, re
 				}
 			}
 
+			return nil
+		})
+	case "AutoscalingRunnerSet":
+		var ars actionsgithubcomv1alpha1.AutoscalingRunnerSet
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: req.Namespace,
+			Name:      hra.Spec.ScaleTargetRef.Name,
+		}, &ars); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+
+		if !ars.ObjectMeta.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, nil
+		}
+
+		if len(hra.Spec.Metrics) > 0 {
+			// The listener of the autoscaling runner set scales runners by the jobs assigned to it.
+			// The HRA only drives its minRunners from minReplicas, scheduled overrides and capacity reservations.
+			log.V(1).Info("Ignoring metrics of the HRA targeting an AutoscalingRunnerSet")
+		}
+
+		st := scaleTarget{
+			st:       ars.Name,
+			kind:     "autoscalingrunnerset",
+			replicas: ars.Spec.MinRunners,
+		}
+
+		return r.reconcile(ctx, req, log, hra, st, func(newDesiredReplicas int) error {
+			if max := ars.Spec.MaxRunners; max != nil && newDesiredReplicas > *max {
+				newDesiredReplicas = *max
+			}
+
+			if ars.Spec.MinRunners != nil && *ars.Spec.MinRunners == newDesiredReplicas {
+				return nil
+			}
+
+			copy := ars.DeepCopy()
+			copy.Spec.MinRunners = &newDesiredReplicas
+
+			if err := r.Client.Patch(ctx, copy, client.MergeFrom(&ars)); err != nil {
+				return fmt.Errorf("patching autoscalingrunnerset to have %d min runners: %w", newDesiredReplicas, err)
+			}
+
 			return nil
 		})
 	}
 
-	log.Info(fmt.Sprintf("Unsupported scale target %s %s: kind %s is not supported. valid kinds are %s and %s", kind, hra.Spec.ScaleTargetRef.Name, kind, "RunnerDeployment", "RunnerSet"))
+	log.Info(fmt.Sprintf("Unsupported scale target %s %s: kind %s is not supported. valid kinds are %s, %s and %s", kind, hra.Spec.ScaleTargetRef.Name, kind, "RunnerDeployment", "RunnerSet", "AutoscalingRunnerSet"))
 
 	return ctrl.Result{}, nil
 }
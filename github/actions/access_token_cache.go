@@ -0,0 +1,141 @@
+package actions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// accessTokenMinValidity is how long a cached installation access token has to stay valid to be used,
+	// so that it doesn't expire in the middle of the operation that uses it.
+	accessTokenMinValidity = 5 * time.Minute
+
+	// accessTokenRefreshWindow is how long before their expiry installation access tokens are
+	// refreshed in the background. Installation access tokens are valid for an hour.
+	accessTokenRefreshWindow = 15 * time.Minute
+
+	// accessTokenRefreshInterval is how often the tokens are checked for a refresh in the background.
+	accessTokenRefreshInterval = time.Minute
+)
+
+// accessTokenCacheKey identifies the installation access tokens of a GitHub App installation
+// on a GitHub instance.
+type accessTokenCacheKey struct {
+	apiHost        string
+	appID          int64
+	installationID int64
+}
+
+// accessTokenCache caches the installation access tokens of GitHub Apps, instead of minting a new one
+// for every call to the GitHub API. The tokens of a MultiClient are refreshed ahead of their expiry
+// in the background, see refreshLoop.
+type accessTokenCache struct {
+	mu      sync.Mutex
+	entries map[accessTokenCacheKey]*cachedAccessToken
+	now     func() time.Time
+}
+
+type cachedAccessToken struct {
+	// serializes minting, so that concurrent callers share the new token
+	mu       sync.Mutex
+	token    *accessToken
+	mintedAt time.Time
+	lastUsed time.Time
+	mint     func(ctx context.Context) (*accessToken, error)
+}
+
+func newAccessTokenCache() *accessTokenCache {
+	return &accessTokenCache{
+		entries: make(map[accessTokenCacheKey]*cachedAccessToken),
+		now:     time.Now,
+	}
+}
+
+func (c *accessTokenCache) entry(key accessTokenCacheKey) *cachedAccessToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cachedAccessToken{}
+		c.entries[key] = entry
+	}
+	return entry
+}
+
+// get returns the cached token of the installation, or mints a new one when there is none
+// or it is about to expire.
+func (c *accessTokenCache) get(ctx context.Context, key accessTokenCacheKey, mint func(ctx context.Context) (*accessToken, error)) (*accessToken, error) {
+	entry := c.entry(key)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	// The latest client knows the latest private key.
+	entry.mint = mint
+
+	if entry.token == nil || !entry.token.ExpiresAt.After(c.now().Add(accessTokenMinValidity)) {
+		token, err := mint(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entry.token = token
+		entry.mintedAt = c.now()
+	}
+	entry.lastUsed = c.now()
+	return entry.token, nil
+}
+
+// refresh mints new tokens for the installations whose token expires within the refresh window,
+// as long as the token was used since it was minted. Tokens that expired unused are dropped,
+// so that the installations no client uses anymore are forgotten.
+func (c *accessTokenCache) refresh(ctx context.Context, logger logr.Logger) {
+	c.mu.Lock()
+	entries := make(map[accessTokenCacheKey]*cachedAccessToken, len(c.entries))
+	for key, entry := range c.entries {
+		entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	for key, entry := range entries {
+		entry.mu.Lock()
+		now := c.now()
+		switch {
+		case entry.token == nil || entry.token.ExpiresAt.After(now.Add(accessTokenRefreshWindow)):
+		case entry.lastUsed.Before(entry.mintedAt):
+			if entry.token.ExpiresAt.Before(now) {
+				c.mu.Lock()
+				delete(c.entries, key)
+				c.mu.Unlock()
+			}
+		default:
+			token, err := entry.mint(ctx)
+			if err != nil {
+				// The token is minted again on its next use.
+				logger.Error(err, "failed to refresh GitHub App installation access token", "appID", key.appID, "installationID", key.installationID)
+				break
+			}
+			entry.token = token
+			entry.mintedAt = now
+		}
+		entry.mu.Unlock()
+	}
+}
+
+// refreshLoop refreshes the tokens until ctx is done.
+func (c *accessTokenCache) refreshLoop(ctx context.Context, interval time.Duration, logger logr.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refresh(ctx, logger)
+		}
+	}
+}
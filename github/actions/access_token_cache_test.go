@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := accessTokenCacheKey{apiHost: "api.github.com", appID: 1, installationID: 2}
+
+	newCache := func() (*accessTokenCache, *int, func(ctx context.Context) (*accessToken, error)) {
+		cache := newAccessTokenCache()
+		cache.now = func() time.Time { return now }
+		minted := 0
+		mint := func(ctx context.Context) (*accessToken, error) {
+			minted++
+			return &accessToken{Token: "token", ExpiresAt: now.Add(time.Hour)}, nil
+		}
+		return cache, &minted, mint
+	}
+
+	t.Run("tokens are reused until they are about to expire", func(t *testing.T) {
+		cache, minted, mint := newCache()
+
+		_, err := cache.get(ctx, key, mint)
+		require.NoError(t, err)
+		now = now.Add(50 * time.Minute)
+		_, err = cache.get(ctx, key, mint)
+		require.NoError(t, err)
+		assert.Equal(t, 1, *minted)
+
+		now = now.Add(6 * time.Minute)
+		token, err := cache.get(ctx, key, mint)
+		require.NoError(t, err)
+		assert.Equal(t, 2, *minted, "A token valid for less than the minimum validity is minted again")
+		assert.Equal(t, now.Add(time.Hour), token.ExpiresAt)
+	})
+
+	t.Run("failures to mint are not cached", func(t *testing.T) {
+		cache, minted, mint := newCache()
+
+		_, err := cache.get(ctx, key, func(ctx context.Context) (*accessToken, error) {
+			return nil, errors.New("bad credentials")
+		})
+		require.Error(t, err)
+		_, err = cache.get(ctx, key, mint)
+		require.NoError(t, err)
+		assert.Equal(t, 1, *minted)
+	})
+
+	t.Run("used tokens are refreshed ahead of their expiry", func(t *testing.T) {
+		cache, minted, mint := newCache()
+
+		_, err := cache.get(ctx, key, mint)
+		require.NoError(t, err)
+
+		cache.refresh(ctx, logr.Discard())
+		assert.Equal(t, 1, *minted, "Tokens are not refreshed outside of the refresh window")
+
+		now = now.Add(50 * time.Minute)
+		cache.refresh(ctx, logr.Discard())
+		assert.Equal(t, 2, *minted)
+
+		_, err = cache.get(ctx, key, mint)
+		require.NoError(t, err)
+		assert.Equal(t, 2, *minted, "The refreshed token is used")
+	})
+
+	t.Run("unused tokens expire", func(t *testing.T) {
+		cache, minted, mint := newCache()
+
+		_, err := cache.get(ctx, key, mint)
+		require.NoError(t, err)
+		now = now.Add(50 * time.Minute)
+		cache.refresh(ctx, logr.Discard())
+		require.Equal(t, 2, *minted)
+
+		now = now.Add(50 * time.Minute)
+		cache.refresh(ctx, logr.Discard())
+		assert.Equal(t, 2, *minted, "A token unused since its refresh is not refreshed again")
+
+		now = now.Add(20 * time.Minute)
+		cache.refresh(ctx, logr.Discard())
+		assert.Empty(t, cache.entries)
+	})
+}
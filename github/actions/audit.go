@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuditEntry is the audit record of a single HTTP request the client sent to GitHub,
+// retries and token exchanges included.
+type AuditEntry struct {
+	// Audit marks the line as an audit record among the other logs.
+	Audit            bool      `json:"audit"`
+	Time             time.Time `json:"time"`
+	Method           string    `json:"method"`
+	Host             string    `json:"host"`
+	Path             string    `json:"path"`
+	RunnerScaleSetId int       `json:"runnerScaleSetId,omitempty"`
+	LatencySeconds   float64   `json:"latencySeconds"`
+	StatusCode       int       `json:"statusCode,omitempty"`
+	Error            string    `json:"error,omitempty"`
+
+	RateLimitLimit     string `json:"rateLimitLimit,omitempty"`
+	RateLimitRemaining string `json:"rateLimitRemaining,omitempty"`
+	RateLimitReset     string `json:"rateLimitReset,omitempty"`
+	RateLimitResource  string `json:"rateLimitResource,omitempty"`
+}
+
+// WithAuditLog writes an AuditEntry as a line of JSON to w for every request of the client.
+// Query strings, headers and bodies are left out, so that no credential ends up in the audit log.
+func WithAuditLog(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.auditLog = &auditLog{w: w}
+	}
+}
+
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var scaleSetIdInPath = regexp.MustCompile(`/` + scaleSetEndpoint + `/(\d+)`)
+
+func (a *auditLog) record(entry *AuditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, _ = a.w.Write(line)
+}
+
+// auditTransport records every round trip to the audit log.
+type auditTransport struct {
+	next http.RoundTripper
+	log  *auditLog
+	now  func() time.Time
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := t.now()
+	resp, err := t.next.RoundTrip(req)
+
+	entry := &AuditEntry{
+		Audit:          true,
+		Time:           start.UTC(),
+		Method:         req.Method,
+		Host:           req.URL.Host,
+		Path:           req.URL.Path,
+		LatencySeconds: t.now().Sub(start).Seconds(),
+	}
+	if m := scaleSetIdInPath.FindStringSubmatch(req.URL.Path); m != nil {
+		entry.RunnerScaleSetId, _ = strconv.Atoi(m[1])
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if resp != nil {
+		entry.StatusCode = resp.StatusCode
+		entry.RateLimitLimit = resp.Header.Get(headerRateLimitLimit)
+		entry.RateLimitRemaining = resp.Header.Get(headerRateLimitRemaining)
+		entry.RateLimitReset = resp.Header.Get(headerRateLimitReset)
+		entry.RateLimitResource = resp.Header.Get(headerRateLimitResource)
+	}
+
+	t.log.record(entry)
+	return resp, err
+}
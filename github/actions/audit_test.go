@@ -0,0 +1,49 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1672574400")
+		w.Write([]byte(`{"id":7,"name":"ScaleSet"}`))
+	}))
+
+	var auditLog bytes.Buffer
+	client, err := actions.NewClient(server.configURLForOrg("my-org"), &actions.ActionsAuth{Token: "ghp_secret"}, actions.WithAuditLog(&auditLog))
+	require.NoError(t, err)
+
+	_, err = client.GetRunnerScaleSetById(context.Background(), 7)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(auditLog.String()), "\n")
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		assert.NotContains(t, line, "ghp_secret", "audit log must not contain credentials")
+	}
+
+	// The last call is the one for the scale set, after the token exchange.
+	var entry actions.AuditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+	assert.True(t, entry.Audit)
+	assert.Equal(t, http.MethodGet, entry.Method)
+	assert.Equal(t, "/tenant/123/_apis/runtime/runnerscalesets/7", entry.Path)
+	assert.Equal(t, 7, entry.RunnerScaleSetId)
+	assert.Equal(t, http.StatusOK, entry.StatusCode)
+	assert.Equal(t, "5000", entry.RateLimitLimit)
+	assert.Equal(t, "4999", entry.RateLimitRemaining)
+	assert.Equal(t, "1672574400", entry.RateLimitReset)
+	assert.GreaterOrEqual(t, entry.LatencySeconds, 0.0)
+}
@@ -0,0 +1,169 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gitHubEnterpriseVersionHeader is returned by GitHub Enterprise Server on every API response.
+const gitHubEnterpriseVersionHeader = "X-GitHub-Enterprise-Version"
+
+type ServerFeature string
+
+const (
+	// ServerFeatureRunnerScaleSets is the runner scale set API used by the
+	// autoscaling runner sets and the listener.
+	ServerFeatureRunnerScaleSets ServerFeature = "runner scale sets"
+
+	// ServerFeatureRunnerGroupCreation is the creation of runner groups
+	// through the Actions service.
+	ServerFeatureRunnerGroupCreation ServerFeature = "runner group creation"
+)
+
+// minimumGHESVersions is the first GitHub Enterprise Server version supporting each feature.
+// Features are always supported on github.com.
+var minimumGHESVersions = map[ServerFeature]string{
+	ServerFeatureRunnerScaleSets:     "3.9",
+	ServerFeatureRunnerGroupCreation: "3.10",
+}
+
+// ServerCapabilities describes the GitHub server the client is talking to.
+type ServerCapabilities struct {
+	IsHosted bool
+
+	// Version is the installed version of GitHub Enterprise Server.
+	// It is empty for github.com and when the version could not be detected.
+	Version string
+}
+
+// Supports reports whether the server supports the feature.
+// Servers with an unknown version are assumed to support every feature.
+func (s *ServerCapabilities) Supports(feature ServerFeature) bool {
+	if s.IsHosted || s.Version == "" {
+		return true
+	}
+
+	minimum, ok := minimumGHESVersions[feature]
+	if !ok {
+		return true
+	}
+
+	return compareServerVersions(s.Version, minimum) >= 0
+}
+
+// check returns an UnsupportedServerVersionError when the server does not support the feature.
+func (s *ServerCapabilities) check(feature ServerFeature) error {
+	if s.Supports(feature) {
+		return nil
+	}
+
+	return &UnsupportedServerVersionError{
+		Version:        s.Version,
+		Feature:        feature,
+		MinimumVersion: minimumGHESVersions[feature],
+	}
+}
+
+// GetServerCapabilities detects the version of the GitHub server.
+// The result is cached for the lifetime of the client.
+func (c *Client) GetServerCapabilities(ctx context.Context) (*ServerCapabilities, error) {
+	c.capabilitiesMu.Lock()
+	defer c.capabilitiesMu.Unlock()
+
+	if c.capabilities != nil {
+		return c.capabilities, nil
+	}
+
+	if c.config.IsHosted {
+		c.capabilities = &ServerCapabilities{IsHosted: true}
+		return c.capabilities, nil
+	}
+
+	req, err := c.NewGitHubAPIRequest(ctx, http.MethodGet, "/meta", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from GitHub API during server version detection: %v", resp.Status)
+	}
+
+	var meta struct {
+		InstalledVersion string `json:"installed_version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, err
+	}
+
+	version := meta.InstalledVersion
+	if version == "" {
+		version = resp.Header.Get(gitHubEnterpriseVersionHeader)
+	}
+
+	c.logger.Info("detected GitHub Enterprise Server version", "version", version)
+	c.capabilities = &ServerCapabilities{Version: version}
+	return c.capabilities, nil
+}
+
+// requireFeature returns an UnsupportedServerVersionError when the server is known
+// not to support the feature. Failures to detect the server version are ignored
+// so that the request itself surfaces the actual problem.
+func (c *Client) requireFeature(ctx context.Context, feature ServerFeature) error {
+	capabilities, err := c.GetServerCapabilities(ctx)
+	if err != nil {
+		c.logger.Info("failed to detect server capabilities", "error", err.Error())
+		return nil
+	}
+
+	return capabilities.check(feature)
+}
+
+// parseErrorFromResponse parses the error of a failed Actions service request.
+// Endpoints that do not exist on older GitHub Enterprise Server versions respond
+// with 404, which is reported as an UnsupportedServerVersionError instead.
+func (c *Client) parseErrorFromResponse(ctx context.Context, resp *http.Response, feature ServerFeature) error {
+	if resp.StatusCode == http.StatusNotFound && !c.config.IsHosted {
+		if err := c.requireFeature(ctx, feature); err != nil {
+			resp.Body.Close()
+			return err
+		}
+	}
+
+	return ParseActionsErrorFromResponse(resp)
+}
+
+// compareServerVersions compares two dotted versions such as "3.9.2" and "3.10",
+// returning -1, 0 or 1. Missing or non-numeric parts count as 0.
+func compareServerVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+
+		switch {
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -8,12 +8,15 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,12 +24,16 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/net/http/httpproxy"
 )
 
 const (
 	runnerEndpoint       = "_apis/distributedtask/pools/0/agents"
+	runnerGroupEndpoint  = "_apis/runtime/runnergroups"
 	scaleSetEndpoint     = "_apis/runtime/runnerscalesets"
 	apiVersionQueryParam = "api-version=6.0-preview"
+
+	tokenRefreshFailureBackoff = 30 * time.Second
 )
 
 //go:generate mockery --inpackage --name=ActionsService
@@ -34,6 +41,7 @@ type ActionsService interface {
 	GetRunnerScaleSet(ctx context.Context, runnerScaleSetName string) (*RunnerScaleSet, error)
 	GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int) (*RunnerScaleSet, error)
 	GetRunnerGroupByName(ctx context.Context, runnerGroup string) (*RunnerGroup, error)
+	CreateRunnerGroup(ctx context.Context, runnerGroup string) (*RunnerGroup, error)
 	CreateRunnerScaleSet(ctx context.Context, runnerScaleSet *RunnerScaleSet) (*RunnerScaleSet, error)
 	UpdateRunnerScaleSet(ctx context.Context, runnerScaleSetId int, runnerScaleSet *RunnerScaleSet) (*RunnerScaleSet, error)
 	DeleteRunnerScaleSet(ctx context.Context, runnerScaleSetId int) error
@@ -52,7 +60,12 @@ type ActionsService interface {
 
 	GetRunner(ctx context.Context, runnerId int64) (*RunnerReference, error)
 	GetRunnerByName(ctx context.Context, runnerName string) (*RunnerReference, error)
+	GetRunnersByScaleSet(ctx context.Context, runnerScaleSetId int) ([]RunnerReference, error)
 	RemoveRunner(ctx context.Context, runnerId int64) error
+
+	GetLatestRunnerVersion(ctx context.Context) (string, error)
+
+	CreateRegistrationToken(ctx context.Context) (*RegistrationToken, error)
 }
 
 type Client struct {
@@ -61,6 +74,10 @@ type Client struct {
 	// lock for refreshing the ActionsServiceAdminToken and ActionsServiceAdminTokenExpiresAt
 	mu sync.Mutex
 
+	// error of the last failed token refresh, returned until tokenRefreshRetryAt
+	tokenRefreshErr     error
+	tokenRefreshRetryAt time.Time
+
 	// TODO: Convert to unexported fields once refactor of Listener is complete
 	ActionsServiceAdminToken          string
 	ActionsServiceAdminTokenExpiresAt time.Time
@@ -76,6 +93,32 @@ type Client struct {
 
 	rootCAs               *x509.CertPool
 	tlsInsecureSkipVerify bool
+
+	proxy *httpproxy.Config
+
+	transportSettings TransportSettings
+
+	capabilitiesMu sync.Mutex
+	capabilities   *ServerCapabilities
+
+	// requests are delayed until this time after a rate limited response
+	rateLimitMu      sync.Mutex
+	rateLimitedUntil time.Time
+
+	// tokens kept by the MultiClient for the clients of the same key
+	tokenCache *tokenCache
+	// namespace of the client in the MultiClient, scoping its entries in the token cache
+	tokenCacheNamespace string
+
+	// GitHub App installation access tokens, shared with other clients of the same MultiClient
+	accessTokens *accessTokenCache
+
+	// installation of the GitHub App discovered for credentials without an installation ID
+	installationIDMu sync.Mutex
+	installationID   int64
+
+	// records every request when the audit log is enabled
+	auditLog *auditLog
 }
 
 type ClientOption func(*Client)
@@ -116,6 +159,26 @@ func WithoutTLSVerify() ClientOption {
 	}
 }
 
+// WithProxy sends the requests of the client through the proxies of the config.
+func WithProxy(proxy *httpproxy.Config) ClientOption {
+	return func(c *Client) {
+		c.proxy = proxy
+	}
+}
+
+func withTokenCache(cache *tokenCache, namespace string) ClientOption {
+	return func(c *Client) {
+		c.tokenCache = cache
+		c.tokenCacheNamespace = namespace
+	}
+}
+
+func withAccessTokenCache(cache *accessTokenCache) ClientOption {
+	return func(c *Client) {
+		c.accessTokens = cache
+	}
+}
+
 func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOption) (*Client, error) {
 	config, err := ParseGitHubConfigFromURL(githubConfigURL)
 	if err != nil {
@@ -136,11 +199,21 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 		option(ac)
 	}
 
+	if ac.accessTokens == nil {
+		ac.accessTokens = newAccessTokenCache()
+	}
+
 	retryClient := retryablehttp.NewClient()
 	retryClient.Logger = log.New(io.Discard, "", log.LstdFlags)
 
 	retryClient.RetryMax = ac.retryMax
 	retryClient.RetryWaitMax = ac.retryWaitMax
+	retryClient.CheckRetry = checkRetry
+	retryClient.Backoff = backoff
+	retryClient.ErrorHandler = retryErrorHandler
+	retryClient.ResponseLogHook = func(_ retryablehttp.Logger, resp *http.Response) {
+		ac.observeRateLimit(resp)
+	}
 
 	transport, ok := retryClient.HTTPClient.Transport.(*http.Transport)
 	if !ok {
@@ -160,7 +233,19 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 		transport.TLSClientConfig.InsecureSkipVerify = true
 	}
 
-	retryClient.HTTPClient.Transport = transport
+	if ac.proxy != nil {
+		proxyFunc := ac.proxy.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	ac.transportSettings.apply(transport)
+
+	retryClient.HTTPClient.Transport = &metricsTransport{next: transport}
+	if ac.auditLog != nil {
+		retryClient.HTTPClient.Transport = &auditTransport{next: retryClient.HTTPClient.Transport, log: ac.auditLog, now: time.Now}
+	}
 	ac.Client = retryClient.StandardClient()
 
 	return ac, nil
@@ -186,10 +271,38 @@ func (c *Client) Identifier() string {
 		)
 	}
 
+	if c.proxy != nil {
+		identifier += fmt.Sprintf(
+			",proxy:%q,%q,%q",
+			c.proxy.HTTPProxy,
+			c.proxy.HTTPSProxy,
+			c.proxy.NoProxy,
+		)
+	}
+
+	if c.rootCAs != nil {
+		// Subjects is deprecated because it does not include the system roots,
+		// which are the same for every client anyway.
+		identifier += fmt.Sprintf(",rootCAs:%q", c.rootCAs.Subjects()) //nolint:staticcheck
+	}
+
+	if c.tlsInsecureSkipVerify {
+		identifier += ",skipTLSVerify"
+	}
+
 	return uuid.NewHash(sha256.New(), uuid.NameSpaceOID, []byte(identifier), 6).String()
 }
 
+// tokenCacheKey returns the key of the client in the MultiClient, which scopes its entries in the token cache.
+func (c *Client) tokenCacheKey() ActionsClientKey {
+	return ActionsClientKey{Identifier: c.Identifier(), Namespace: c.tokenCacheNamespace}
+}
+
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if err := c.waitForRateLimit(req.Context()); err != nil {
+		return nil, err
+	}
+
 	resp, err := c.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -280,7 +393,7 @@ func (c *Client) GetRunnerScaleSet(ctx context.Context, runnerScaleSetName strin
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ParseActionsErrorFromResponse(resp)
+		return nil, c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerScaleSets)
 	}
 
 	var runnerScaleSetList *runnerScaleSetsResponse
@@ -311,7 +424,7 @@ func (c *Client) GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ParseActionsErrorFromResponse(resp)
+		return nil, c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerScaleSets)
 	}
 
 	var runnerScaleSet *RunnerScaleSet
@@ -323,7 +436,7 @@ func (c *Client) GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int
 }
 
 func (c *Client) GetRunnerGroupByName(ctx context.Context, runnerGroup string) (*RunnerGroup, error) {
-	path := fmt.Sprintf("/_apis/runtime/runnergroups/?groupName=%s", runnerGroup)
+	path := fmt.Sprintf("/%s/?groupName=%s", runnerGroupEndpoint, runnerGroup)
 	req, err := c.NewActionsServiceRequest(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
@@ -349,7 +462,7 @@ func (c *Client) GetRunnerGroupByName(ctx context.Context, runnerGroup string) (
 	}
 
 	if runnerGroupList.Count == 0 {
-		return nil, fmt.Errorf("no runner group found with name '%s'", runnerGroup)
+		return nil, &RunnerGroupNotFoundError{Name: runnerGroup}
 	}
 
 	if runnerGroupList.Count > 1 {
@@ -359,6 +472,34 @@ func (c *Client) GetRunnerGroupByName(ctx context.Context, runnerGroup string) (
 	return &runnerGroupList.RunnerGroups[0], nil
 }
 
+func (c *Client) CreateRunnerGroup(ctx context.Context, runnerGroup string) (*RunnerGroup, error) {
+	body, err := json.Marshal(&RunnerGroupSetting{Name: runnerGroup})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.NewActionsServiceRequest(ctx, http.MethodPost, runnerGroupEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerGroupCreation)
+	}
+
+	var createdRunnerGroup *RunnerGroup
+	err = json.NewDecoder(resp.Body).Decode(&createdRunnerGroup)
+	if err != nil {
+		return nil, err
+	}
+	return createdRunnerGroup, nil
+}
+
 func (c *Client) CreateRunnerScaleSet(ctx context.Context, runnerScaleSet *RunnerScaleSet) (*RunnerScaleSet, error) {
 	body, err := json.Marshal(runnerScaleSet)
 	if err != nil {
@@ -376,7 +517,7 @@ func (c *Client) CreateRunnerScaleSet(ctx context.Context, runnerScaleSet *Runne
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ParseActionsErrorFromResponse(resp)
+		return nil, c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerScaleSets)
 	}
 	var createdRunnerScaleSet *RunnerScaleSet
 	err = json.NewDecoder(resp.Body).Decode(&createdRunnerScaleSet)
@@ -405,7 +546,7 @@ func (c *Client) UpdateRunnerScaleSet(ctx context.Context, runnerScaleSetId int,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ParseActionsErrorFromResponse(resp)
+		return nil, c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerScaleSets)
 	}
 
 	var updatedRunnerScaleSet *RunnerScaleSet
@@ -429,7 +570,7 @@ func (c *Client) DeleteRunnerScaleSet(ctx context.Context, runnerScaleSetId int)
 	}
 
 	if resp.StatusCode != http.StatusNoContent {
-		return ParseActionsErrorFromResponse(resp)
+		return c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerScaleSets)
 	}
 
 	defer resp.Body.Close()
@@ -578,7 +719,7 @@ func (c *Client) doSessionRequest(ctx context.Context, method, path string, requ
 	}
 
 	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-		return ParseActionsErrorFromResponse(resp)
+		return c.parseErrorFromResponse(ctx, resp, ServerFeatureRunnerScaleSets)
 	}
 
 	defer resp.Body.Close()
@@ -660,6 +801,34 @@ func (c *Client) GetAcquirableJobs(ctx context.Context, runnerScaleSetId int) (*
 }
 
 func (c *Client) GenerateJitRunnerConfig(ctx context.Context, jitRunnerSetting *RunnerScaleSetJitRunnerSetting, scaleSetId int) (*RunnerScaleSetJitRunnerConfig, error) {
+	var cacheKey jitConfigCacheKey
+	if c.tokenCache != nil {
+		cacheKey = jitConfigCacheKey{client: c.tokenCacheKey(), scaleSetId: scaleSetId, runnerName: jitRunnerSetting.Name}
+		if config, ok := c.tokenCache.jitRunnerConfig(cacheKey); ok {
+			return config, nil
+		}
+	}
+
+	runnerJitConfig, err := c.generateJitRunnerConfig(ctx, jitRunnerSetting, scaleSetId)
+	actionsError := &ActionsError{}
+	if errors.As(err, &actionsError) && actionsError.StatusCode == http.StatusUnauthorized {
+		// The admin token was rejected before it expired, e.g. since it was revoked. It is re-issued once.
+		c.logger.Info("admin token was rejected, re-issuing it", "githubConfigUrl", c.config.ConfigURL.String())
+		c.invalidateAdminToken()
+		runnerJitConfig, err = c.generateJitRunnerConfig(ctx, jitRunnerSetting, scaleSetId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tokenCache != nil {
+		c.tokenCache.setJitRunnerConfig(cacheKey, runnerJitConfig)
+	}
+
+	return runnerJitConfig, nil
+}
+
+func (c *Client) generateJitRunnerConfig(ctx context.Context, jitRunnerSetting *RunnerScaleSetJitRunnerSetting, scaleSetId int) (*RunnerScaleSetJitRunnerConfig, error) {
 	path := fmt.Sprintf("/%s/%d/generatejitconfig", scaleSetEndpoint, scaleSetId)
 
 	body, err := json.Marshal(jitRunnerSetting)
@@ -686,6 +855,7 @@ func (c *Client) GenerateJitRunnerConfig(ctx context.Context, jitRunnerSetting *
 	if err != nil {
 		return nil, err
 	}
+
 	return runnerJitConfig, nil
 }
 
@@ -749,6 +919,40 @@ func (c *Client) GetRunnerByName(ctx context.Context, runnerName string) (*Runne
 	return &runnerList.RunnerReferences[0], nil
 }
 
+func (c *Client) GetRunnersByScaleSet(ctx context.Context, runnerScaleSetId int) ([]RunnerReference, error) {
+	path := fmt.Sprintf("/%s", runnerEndpoint)
+
+	req, err := c.NewActionsServiceRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ParseActionsErrorFromResponse(resp)
+	}
+
+	var runnerList *RunnerReferenceList
+	err = json.NewDecoder(resp.Body).Decode(&runnerList)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pool contains runners of every scale set, so only keep the ones registered to this scale set.
+	runners := make([]RunnerReference, 0, len(runnerList.RunnerReferences))
+	for _, runner := range runnerList.RunnerReferences {
+		if runner.RunnerScaleSetId == runnerScaleSetId {
+			runners = append(runners, runner)
+		}
+	}
+
+	return runners, nil
+}
+
 func (c *Client) RemoveRunner(ctx context.Context, runnerId int64) error {
 	path := fmt.Sprintf("/%s/%d", runnerEndpoint, runnerId)
 
@@ -767,15 +971,27 @@ func (c *Client) RemoveRunner(ctx context.Context, runnerId int64) error {
 	}
 
 	defer resp.Body.Close()
+
+	if c.tokenCache != nil {
+		c.tokenCache.removeRunner(c.tokenCacheKey(), runnerId)
+	}
+
 	return nil
 }
 
-type registrationToken struct {
+// RegistrationToken is a token runners register themselves with, instead of a JIT runner config.
+type RegistrationToken struct {
 	Token     *string    `json:"token,omitempty"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
-func (c *Client) getRunnerRegistrationToken(ctx context.Context) (*registrationToken, error) {
+// CreateRegistrationToken creates a token for the enterprise, organization or repository of the config URL
+// that runners register themselves with, for GitHub instances that can't generate JIT runner configs.
+func (c *Client) CreateRegistrationToken(ctx context.Context) (*RegistrationToken, error) {
+	return c.getRunnerRegistrationToken(ctx)
+}
+
+func (c *Client) getRunnerRegistrationToken(ctx context.Context) (*RegistrationToken, error) {
 	path, err := createRegistrationTokenPath(c.config)
 	if err != nil {
 		return nil, err
@@ -787,18 +1003,9 @@ func (c *Client) getRunnerRegistrationToken(ctx context.Context) (*registrationT
 		return nil, err
 	}
 
-	bearerToken := ""
-
-	if c.creds.Token != "" {
-		encodedToken := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("github:%v", c.creds.Token)))
-		bearerToken = fmt.Sprintf("Basic %v", encodedToken)
-	} else {
-		accessToken, err := c.fetchAccessToken(ctx, c.config.ConfigURL.String(), c.creds.AppCreds)
-		if err != nil {
-			return nil, err
-		}
-
-		bearerToken = fmt.Sprintf("Bearer %v", accessToken.Token)
+	bearerToken, err := c.gitHubAPIAuthorization(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/vnd.github.v3+json")
@@ -817,10 +1024,13 @@ func (c *Client) getRunnerRegistrationToken(ctx context.Context) (*registrationT
 		if err != nil {
 			return nil, err
 		}
-		return nil, fmt.Errorf("unexpected response from Actions service during registration token call: %v - %v", resp.StatusCode, string(body))
+		return nil, &GitHubAPIError{
+			msg:        fmt.Sprintf("unexpected response from Actions service during registration token call: %v - %v", resp.StatusCode, string(body)),
+			StatusCode: resp.StatusCode,
+		}
 	}
 
-	var registrationToken *registrationToken
+	var registrationToken *RegistrationToken
 	if err := json.NewDecoder(resp.Body).Decode(&registrationToken); err != nil {
 		return nil, err
 	}
@@ -828,6 +1038,181 @@ func (c *Client) getRunnerRegistrationToken(ctx context.Context) (*registrationT
 	return registrationToken, nil
 }
 
+// gitHubAPIAuthorization returns the Authorization header of requests to the GitHub API,
+// for either the personal access token or the GitHub App installation of the client.
+func (c *Client) gitHubAPIAuthorization(ctx context.Context) (string, error) {
+	if c.creds.Token != "" {
+		encodedToken := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("github:%v", c.creds.Token)))
+		return fmt.Sprintf("Basic %v", encodedToken), nil
+	}
+
+	accessToken, err := c.installationAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Bearer %v", accessToken.Token), nil
+}
+
+// installationAccessToken returns a cached access token of the GitHub App installation of the client,
+// minting a new one when it is about to expire.
+func (c *Client) installationAccessToken(ctx context.Context) (*accessToken, error) {
+	installationID, err := c.appInstallationID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := *c.creds.AppCreds
+	creds.AppInstallationID = installationID
+
+	key := accessTokenCacheKey{
+		apiHost:        c.config.GitHubAPIURL("/").Host,
+		appID:          creds.AppID,
+		installationID: installationID,
+	}
+	return c.accessTokens.get(ctx, key, func(ctx context.Context) (*accessToken, error) {
+		return c.fetchAccessToken(ctx, c.config.ConfigURL.String(), &creds)
+	})
+}
+
+// appInstallationID returns the installation ID of the GitHub App credentials. Credentials without one
+// use the installation of the app on the enterprise, organization or user of the config URL.
+func (c *Client) appInstallationID(ctx context.Context) (int64, error) {
+	if c.creds.AppCreds.AppInstallationID != 0 {
+		return c.creds.AppCreds.AppInstallationID, nil
+	}
+
+	c.installationIDMu.Lock()
+	defer c.installationIDMu.Unlock()
+
+	if c.installationID == 0 {
+		installationID, err := c.findAppInstallation(ctx)
+		if err != nil {
+			return 0, err
+		}
+		c.installationID = installationID
+	}
+	return c.installationID, nil
+}
+
+// Format: https://docs.github.com/en/rest/apps/apps#list-installations-for-the-authenticated-app
+type appInstallation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+		// set instead of the login for enterprises
+		Slug string `json:"slug"`
+	} `json:"account"`
+}
+
+func (c *Client) findAppInstallation(ctx context.Context) (int64, error) {
+	account := c.config.Organization
+	if c.config.Scope == GitHubScopeEnterprise {
+		account = c.config.Enterprise
+	}
+
+	appJWT, err := createJWTForGitHubApp(c.creds.AppCreds)
+	if err != nil {
+		return 0, err
+	}
+
+	const perPage = 100
+	for page := 1; ; page++ {
+		req, err := c.NewGitHubAPIRequest(ctx, http.MethodGet, "/app/installations", nil)
+		if err != nil {
+			return 0, err
+		}
+		req.URL.RawQuery = url.Values{"per_page": {strconv.Itoa(perPage)}, "page": {strconv.Itoa(page)}}.Encode()
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", appJWT))
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return 0, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return 0, err
+			}
+			return 0, fmt.Errorf("unexpected response from GitHub API during app installations call: %v - %v", resp.StatusCode, string(body))
+		}
+
+		var installations []appInstallation
+		err = json.NewDecoder(resp.Body).Decode(&installations)
+		resp.Body.Close()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, installation := range installations {
+			if strings.EqualFold(installation.Account.Login, account) || strings.EqualFold(installation.Account.Slug, account) {
+				c.logger.Info("discovered GitHub App installation", "appID", c.creds.AppCreds.AppID, "installationID", installation.ID, "account", account)
+				return installation.ID, nil
+			}
+		}
+
+		if len(installations) < perPage {
+			return 0, fmt.Errorf("GitHub App %d is not installed on %q, install it or set github_app_installation_id", c.creds.AppCreds.AppID, account)
+		}
+	}
+}
+
+// GetLatestRunnerVersion returns the version of the runner release the GitHub instance
+// serves for download, which is the latest runner version the instance supports.
+func (c *Client) GetLatestRunnerVersion(ctx context.Context) (string, error) {
+	path, err := createRunnerDownloadsPath(c.config)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := c.NewGitHubAPIRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	authorization, err := c.gitHubAPIAuthorization(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("unexpected response from GitHub API during runner downloads call: %v - %v", resp.StatusCode, string(body))
+	}
+
+	var applications []RunnerApplication
+	if err := json.NewDecoder(resp.Body).Decode(&applications); err != nil {
+		return "", err
+	}
+
+	for _, application := range applications {
+		if match := runnerApplicationVersion.FindStringSubmatch(application.Filename); match != nil {
+			return match[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("no runner version found in the %d runner downloads", len(applications))
+}
+
+// runnerApplicationVersion matches the version in the filenames of runner downloads,
+// such as actions-runner-linux-x64-2.303.0.tar.gz.
+var runnerApplicationVersion = regexp.MustCompile(`^actions-runner-.+-(\d+\.\d+\.\d+)\.(tar\.gz|zip)$`)
+
 // Format: https://docs.github.com/en/rest/apps/apps#create-an-installation-access-token-for-an-app
 type accessToken struct {
 	Token     string    `json:"token"`
@@ -857,6 +1242,17 @@ func (c *Client) fetchAccessToken(ctx context.Context, gitHubConfigURL string, c
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusCreated {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &GitHubAPIError{
+			msg:        fmt.Sprintf("unexpected response from GitHub API during access token call: %v - %v", resp.StatusCode, string(body)),
+			StatusCode: resp.StatusCode,
+		}
+	}
+
 	// Format: https://docs.github.com/en/rest/apps/apps#create-an-installation-access-token-for-an-app
 	var accessToken *accessToken
 	err = json.NewDecoder(resp.Body).Decode(&accessToken)
@@ -868,7 +1264,7 @@ type ActionsServiceAdminConnection struct {
 	AdminToken        *string `json:"token,omitempty"`
 }
 
-func (c *Client) getActionsServiceAdminConnection(ctx context.Context, rt *registrationToken) (*ActionsServiceAdminConnection, error) {
+func (c *Client) getActionsServiceAdminConnection(ctx context.Context, rt *RegistrationToken) (*ActionsServiceAdminConnection, error) {
 	path := "/actions/runner-registration"
 
 	body := struct {
@@ -930,6 +1326,22 @@ func createRegistrationTokenPath(config *GitHubConfig) (string, error) {
 	}
 }
 
+func createRunnerDownloadsPath(config *GitHubConfig) (string, error) {
+	switch config.Scope {
+	case GitHubScopeOrganization:
+		return fmt.Sprintf("/orgs/%s/actions/runners/downloads", config.Organization), nil
+
+	case GitHubScopeEnterprise:
+		return fmt.Sprintf("/enterprises/%s/actions/runners/downloads", config.Enterprise), nil
+
+	case GitHubScopeRepository:
+		return fmt.Sprintf("/repos/%s/%s/actions/runners/downloads", config.Organization, config.Repository), nil
+
+	default:
+		return "", fmt.Errorf("unknown scope for config url: %s", config.ConfigURL)
+	}
+}
+
 func createJWTForGitHubApp(appAuth *GitHubAppAuth) (string, error) {
 	// Encode as JWT
 	// See https://docs.github.com/en/developers/apps/building-github-apps/authenticating-with-github-apps#authenticating-as-a-github-app
@@ -985,6 +1397,48 @@ func (c *Client) updateTokenIfNeeded(ctx context.Context) error {
 		return nil
 	}
 
+	if c.tokenCache != nil {
+		if conn, ok := c.tokenCache.adminConnection(c.tokenCacheKey()); ok {
+			c.ActionsServiceURL = conn.actionsServiceURL
+			c.ActionsServiceAdminToken = conn.adminToken
+			c.ActionsServiceAdminTokenExpiresAt = conn.expiresAt
+			return nil
+		}
+	}
+
+	// Failing fast after a failed refresh keeps bad credentials from tying up
+	// every caller of the client with requests that are bound to fail.
+	if time.Now().Before(c.tokenRefreshRetryAt) {
+		return c.tokenRefreshErr
+	}
+
+	if err := c.refreshToken(ctx); err != nil {
+		if ctx.Err() == nil {
+			c.tokenRefreshErr = err
+			c.tokenRefreshRetryAt = time.Now().Add(tokenRefreshFailureBackoff)
+		}
+		return err
+	}
+
+	c.tokenRefreshErr = nil
+	c.tokenRefreshRetryAt = time.Time{}
+	return nil
+}
+
+// invalidateAdminToken drops the admin token of the client and the cached one, so that the next request re-issues it.
+func (c *Client) invalidateAdminToken() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tokenCache != nil {
+		c.tokenCache.removeAdminConnection(c.tokenCacheKey(), c.ActionsServiceAdminToken)
+	}
+	c.ActionsServiceAdminTokenExpiresAt = time.Time{}
+	c.tokenRefreshErr = nil
+	c.tokenRefreshRetryAt = time.Time{}
+}
+
+func (c *Client) refreshToken(ctx context.Context) error {
 	c.logger.Info("refreshing token", "githubConfigUrl", c.config.ConfigURL.String())
 	rt, err := c.getRunnerRegistrationToken(ctx)
 	if err != nil {
@@ -1003,5 +1457,13 @@ func (c *Client) updateTokenIfNeeded(ctx context.Context) error {
 		return fmt.Errorf("failed to get admin token expire at on refresh: %w", err)
 	}
 
+	if c.tokenCache != nil {
+		c.tokenCache.setAdminConnection(c.tokenCacheKey(), cachedAdminConnection{
+			actionsServiceURL: c.ActionsServiceURL,
+			adminToken:        c.ActionsServiceAdminToken,
+			expiresAt:         c.ActionsServiceAdminTokenExpiresAt,
+		})
+	}
+
 	return nil
 }
@@ -0,0 +1,87 @@
+package actions_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppInstallationDiscovery(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	newServer := func(t *testing.T, installationCalls *int) *actionsServer {
+		return newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v3/app/installations":
+				*installationCalls++
+				if r.URL.Query().Get("page") != "1" {
+					w.Write([]byte(`[{"id":7,"account":{"slug":"my-enterprise"}}]`))
+					return
+				}
+				// A full first page, so that the client asks for the second one.
+				w.Write([]byte(`[`))
+				for i := 0; i < 100; i++ {
+					if i > 0 {
+						w.Write([]byte(`,`))
+					}
+					fmt.Fprintf(w, `{"id":%d,"account":{"login":"other-org-%d"}}`, 100+i, i)
+				}
+				w.Write([]byte(`]`))
+			case "/api/v3/app/installations/7/access_tokens":
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"token":"installation-token","expires_at":"2099-01-01T00:00:00Z"}`))
+			case "/api/v3/enterprises/my-enterprise/actions/runners/downloads":
+				assert.Equal(t, "Bearer installation-token", r.Header.Get("Authorization"))
+				w.Write([]byte(`[{"filename":"actions-runner-linux-x64-2.303.0.tar.gz"}]`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	}
+
+	t.Run("Uses the installation on the account of the config URL", func(t *testing.T) {
+		installationCalls := 0
+		server := newServer(t, &installationCalls)
+
+		client, err := actions.NewClient(server.URL+"/enterprises/my-enterprise", &actions.ActionsAuth{
+			AppCreds: &actions.GitHubAppAuth{AppID: 1, AppPrivateKey: privateKey},
+		})
+		require.NoError(t, err)
+
+		version, err := client.GetLatestRunnerVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "2.303.0", version)
+		assert.Equal(t, 2, installationCalls)
+
+		_, err = client.GetLatestRunnerVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, installationCalls, "The installation should be discovered once")
+	})
+
+	t.Run("Fails when the app is not installed on the account", func(t *testing.T) {
+		installationCalls := 0
+		server := newServer(t, &installationCalls)
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), &actions.ActionsAuth{
+			AppCreds: &actions.GitHubAppAuth{AppID: 1, AppPrivateKey: privateKey},
+		})
+		require.NoError(t, err)
+
+		_, err = client.GetLatestRunnerVersion(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `GitHub App 1 is not installed on "my-org"`)
+	})
+}
@@ -0,0 +1,116 @@
+package actions_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetServerCapabilities(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	t.Run("Reads the installed version from the meta endpoint", func(t *testing.T) {
+		metaCalls := 0
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v3/meta", r.URL.Path)
+			metaCalls++
+			w.Write([]byte(`{"installed_version":"3.9.2"}`))
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		capabilities, err := client.GetServerCapabilities(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "3.9.2", capabilities.Version)
+		assert.True(t, capabilities.Supports(actions.ServerFeatureRunnerScaleSets))
+		assert.False(t, capabilities.Supports(actions.ServerFeatureRunnerGroupCreation))
+
+		_, err = client.GetServerCapabilities(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, metaCalls, "capabilities should be cached")
+	})
+
+	t.Run("Falls back to the enterprise version header", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-GitHub-Enterprise-Version", "3.10.0")
+			w.Write([]byte(`{}`))
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		capabilities, err := client.GetServerCapabilities(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "3.10.0", capabilities.Version)
+		assert.True(t, capabilities.Supports(actions.ServerFeatureRunnerGroupCreation))
+	})
+
+	t.Run("Hosted servers support every feature", func(t *testing.T) {
+		client, err := actions.NewClient("https://github.com/my-org", auth)
+		require.NoError(t, err)
+
+		capabilities, err := client.GetServerCapabilities(ctx)
+		require.NoError(t, err)
+		assert.True(t, capabilities.IsHosted)
+		assert.True(t, capabilities.Supports(actions.ServerFeatureRunnerScaleSets))
+	})
+}
+
+func TestUnsupportedServerVersion(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	t.Run("Not found on an old server is reported as unsupported", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/meta") {
+				w.Write([]byte(`{"installed_version":"3.8.4"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		_, err = client.GetRunnerScaleSet(ctx, "my-scale-set")
+		require.Error(t, err)
+
+		var unsupportedErr *actions.UnsupportedServerVersionError
+		require.True(t, errors.As(err, &unsupportedErr))
+		assert.Equal(t, "3.8.4", unsupportedErr.Version)
+		assert.Equal(t, actions.ServerFeatureRunnerScaleSets, unsupportedErr.Feature)
+		assert.Equal(t, "3.9", unsupportedErr.MinimumVersion)
+	})
+
+	t.Run("Not found on a supported server is returned as is", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/meta") {
+				w.Write([]byte(`{"installed_version":"3.9.0"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		_, err = client.GetRunnerScaleSet(ctx, "my-scale-set")
+		require.Error(t, err)
+
+		var actionsErr *actions.ActionsError
+		require.True(t, errors.As(err, &actionsErr))
+		assert.Equal(t, http.StatusNotFound, actionsErr.StatusCode)
+	})
+}
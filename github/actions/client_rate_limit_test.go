@@ -0,0 +1,114 @@
+package actions_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedRequests(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	t.Run("Secondary rate limit is retried after Retry-After", func(t *testing.T) {
+		calls := 0
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"id":1,"name":"ScaleSet"}`))
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth, actions.WithRetryWaitMax(1*time.Millisecond))
+		require.NoError(t, err)
+
+		got, err := client.GetRunnerScaleSetById(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Id)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Forbidden without rate limit headers is not retried", func(t *testing.T) {
+		calls := 0
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusForbidden)
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth, actions.WithRetryWaitMax(1*time.Millisecond))
+		require.NoError(t, err)
+
+		_, err = client.GetRunnerScaleSetById(ctx, 1)
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Requests are delayed until the rate limit resets", func(t *testing.T) {
+		reset := time.Now().Add(2 * time.Second)
+		calls := 0
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"id":1,"name":"ScaleSet"}`))
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth, actions.WithRetryMax(0))
+		require.NoError(t, err)
+
+		_, err = client.GetRunnerScaleSetById(ctx, 1)
+		require.Error(t, err)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = client.GetRunnerScaleSetById(timeoutCtx, 1)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, calls, "request should be delayed until the rate limit resets")
+
+		_, err = client.GetRunnerScaleSetById(ctx, 1)
+		require.NoError(t, err)
+		assert.False(t, time.Now().Before(reset.Truncate(time.Second)))
+	})
+}
+
+func TestRateLimitedClientFailsFast(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	calls := 0
+	server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	client, err := actions.NewClient(server.configURLForOrg("my-org"), auth, actions.WithRetryMax(0))
+	require.NoError(t, err)
+
+	_, err = client.GetRunnerScaleSetById(ctx, 1)
+	require.Error(t, err)
+
+	_, err = client.GetRunnerScaleSetById(ctx, 1)
+	var rateLimitedErr *actions.RateLimitedError
+	require.True(t, errors.As(err, &rateLimitedErr), "expected a rate limited error, got: %v", err)
+	assert.Equal(t, 1, calls)
+}
@@ -200,6 +200,10 @@ func TestDeleteMessage(t *testing.T) {
 		assert.NotNil(t, err)
 		expectedRetry := retryMax + 1
 		assert.Equalf(t, actualRetry, expectedRetry, "A retry was expected after the first request but got: %v", actualRetry)
+
+		var actionsErr *actions.ActionsError
+		require.True(t, errors.As(err, &actionsErr), "The last response is reported once the retries are exhausted")
+		assert.Equal(t, http.StatusServiceUnavailable, actionsErr.StatusCode)
 	})
 
 	t.Run("No message found", func(t *testing.T) {
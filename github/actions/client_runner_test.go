@@ -2,7 +2,9 @@ package actions_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -216,3 +218,87 @@ func TestGetRunnerGroupByName(t *testing.T) {
 		assert.Nil(t, got)
 	})
 }
+
+func TestCreateRunnerGroup(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	t.Run("Create RunnerGroup", func(t *testing.T) {
+		var runnerGroupName string = "test-runner-group"
+		want := &actions.RunnerGroup{
+			ID:   2,
+			Name: runnerGroupName,
+		}
+		response := []byte(`{"id": 2, "name": "test-runner-group"}`)
+
+		var gotBody actions.RunnerGroupSetting
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.True(t, strings.HasSuffix(r.URL.Path, "/_apis/runtime/runnergroups"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&gotBody))
+			w.Write(response)
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		got, err := client.CreateRunnerGroup(ctx, runnerGroupName)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, runnerGroupName, gotBody.Name)
+	})
+
+	t.Run("Missing runner group is reported with a typed error", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"count": 0, "value": []}`))
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		_, err = client.GetRunnerGroupByName(ctx, "missing")
+		var notFoundErr *actions.RunnerGroupNotFoundError
+		require.ErrorAs(t, err, &notFoundErr)
+		assert.Equal(t, "missing", notFoundErr.Name)
+	})
+}
+
+func TestGetLatestRunnerVersion(t *testing.T) {
+	ctx := context.Background()
+	auth := &actions.ActionsAuth{
+		Token: "token",
+	}
+
+	t.Run("Get latest runner version", func(t *testing.T) {
+		response := []byte(`[
+			{"os": "osx", "architecture": "x64", "filename": "actions-runner-osx-x64-2.303.0.tar.gz"},
+			{"os": "linux", "architecture": "x64", "filename": "actions-runner-linux-x64-2.303.0.tar.gz"}
+		]`)
+
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, strings.HasSuffix(r.URL.Path, "/orgs/my-org/actions/runners/downloads"), "unexpected path %s", r.URL.Path)
+			w.Write(response)
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		got, err := client.GetLatestRunnerVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "2.303.0", got)
+	})
+
+	t.Run("No runner downloads", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[]`))
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth)
+		require.NoError(t, err)
+
+		_, err = client.GetLatestRunnerVersion(ctx)
+		assert.ErrorContains(t, err, "no runner version found")
+	})
+}
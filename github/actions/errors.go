@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type ActionsError struct {
@@ -69,3 +70,58 @@ type HttpClientSideError struct {
 func (e *HttpClientSideError) Error() string {
 	return e.msg
 }
+
+// GitHubAPIError is returned when the GitHub API rejects a request for the tokens of the client,
+// e.g. with 401 when the credentials are bad.
+type GitHubAPIError struct {
+	msg        string
+	StatusCode int
+}
+
+func (e *GitHubAPIError) Error() string {
+	return e.msg
+}
+
+type RunnerGroupNotFoundError struct {
+	Name string
+}
+
+func (e *RunnerGroupNotFoundError) Error() string {
+	return fmt.Sprintf("no runner group found with name '%s'", e.Name)
+}
+
+// UnsupportedServerVersionError is returned when the GitHub Enterprise Server
+// version is too old for the requested feature.
+type UnsupportedServerVersionError struct {
+	Version        string
+	Feature        ServerFeature
+	MinimumVersion string
+}
+
+func (e *UnsupportedServerVersionError) Error() string {
+	return fmt.Sprintf("unsupported server version: GitHub Enterprise Server %s does not support %s, version %s or later is required", e.Version, e.Feature, e.MinimumVersion)
+}
+
+// RateLimitedError is returned instead of sending a request while the client is rate limited.
+type RateLimitedError struct {
+	Until time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited by GitHub until %s", e.Until.Format(time.RFC3339))
+}
+
+// retryErrorHandler reports the last response as an ActionsError once the retries are exhausted,
+// instead of the plain error of retryablehttp, so that callers can tell server side errors apart.
+func retryErrorHandler(resp *http.Response, err error, attempts int) (*http.Response, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("giving up after %d attempt(s): %w", attempts, err)
+	}
+
+	defer resp.Body.Close()
+	return nil, &ActionsError{
+		ExceptionName: "unknown",
+		Message:       fmt.Sprintf("giving up after %d attempt(s): request returned status: %s", attempts, resp.Status),
+		StatusCode:    resp.StatusCode,
+	}
+}
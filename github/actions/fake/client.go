@@ -24,6 +24,13 @@ func WithGetRunnerGroup(runnerGroup *actions.RunnerGroup, err error) Option {
 	}
 }
 
+func WithCreateRunnerGroup(runnerGroup *actions.RunnerGroup, err error) Option {
+	return func(f *FakeClient) {
+		f.createRunnerGroupResult.RunnerGroup = runnerGroup
+		f.createRunnerGroupResult.err = err
+	}
+}
+
 func WithGetRunner(runner *actions.RunnerReference, err error) Option {
 	return func(f *FakeClient) {
 		f.getRunnerResult.RunnerReference = runner
@@ -31,6 +38,41 @@ func WithGetRunner(runner *actions.RunnerReference, err error) Option {
 	}
 }
 
+func WithGetRunnersByScaleSet(runners []actions.RunnerReference, err error) Option {
+	return func(f *FakeClient) {
+		f.getRunnersByScaleSetResult.runners = runners
+		f.getRunnersByScaleSetResult.err = err
+	}
+}
+
+func WithGetLatestRunnerVersion(version string, err error) Option {
+	return func(f *FakeClient) {
+		f.getLatestRunnerVersionResult.version = version
+		f.getLatestRunnerVersionResult.err = err
+	}
+}
+
+func WithGenerateJitRunnerConfig(config *actions.RunnerScaleSetJitRunnerConfig, err error) Option {
+	return func(f *FakeClient) {
+		f.generateJitRunnerConfigResult.RunnerScaleSetJitRunnerConfig = config
+		f.generateJitRunnerConfigResult.err = err
+	}
+}
+
+func WithGetRunnerByName(runner *actions.RunnerReference, err error) Option {
+	return func(f *FakeClient) {
+		f.getRunnerByNameResult.RunnerReference = runner
+		f.getRunnerByNameResult.err = err
+	}
+}
+
+func WithCreateRegistrationToken(token *actions.RegistrationToken, err error) Option {
+	return func(f *FakeClient) {
+		f.createRegistrationTokenResult.RegistrationToken = token
+		f.createRegistrationTokenResult.err = err
+	}
+}
+
 func WithCreateRunnerScaleSet(scaleSet *actions.RunnerScaleSet, err error) Option {
 	return func(f *FakeClient) {
 		f.createRunnerScaleSetResult.RunnerScaleSet = scaleSet
@@ -114,6 +156,14 @@ var defaultRunnerScaleSetJitRunnerConfig = &actions.RunnerScaleSetJitRunnerConfi
 	EncodedJITConfig: "test",
 }
 
+const defaultLatestRunnerVersion = "2.303.0"
+
+var defaultRegistrationTokenValue = "registration-token"
+
+var defaultRegistrationToken = &actions.RegistrationToken{
+	Token: &defaultRegistrationTokenValue,
+}
+
 // FakeClient implements actions service
 type FakeClient struct {
 	getRunnerScaleSetResult struct {
@@ -128,6 +178,10 @@ type FakeClient struct {
 		*actions.RunnerGroup
 		err error
 	}
+	createRunnerGroupResult struct {
+		*actions.RunnerGroup
+		err error
+	}
 
 	createRunnerScaleSetResult struct {
 		*actions.RunnerScaleSet
@@ -178,9 +232,21 @@ type FakeClient struct {
 		*actions.RunnerReference
 		err error
 	}
+	getRunnersByScaleSetResult struct {
+		runners []actions.RunnerReference
+		err     error
+	}
 	removeRunnerResult struct {
 		err error
 	}
+	getLatestRunnerVersionResult struct {
+		version string
+		err     error
+	}
+	createRegistrationTokenResult struct {
+		*actions.RegistrationToken
+		err error
+	}
 }
 
 func NewFakeClient(options ...Option) actions.ActionsService {
@@ -196,6 +262,7 @@ func (f *FakeClient) applyDefaults() {
 	f.getRunnerScaleSetResult.RunnerScaleSet = defaultRunnerScaleSet
 	f.getRunnerScaleSetByIdResult.RunnerScaleSet = defaultRunnerScaleSet
 	f.getRunnerGroupByNameResult.RunnerGroup = defaultRunnerGroup
+	f.createRunnerGroupResult.RunnerGroup = defaultRunnerGroup
 	f.createRunnerScaleSetResult.RunnerScaleSet = defaultRunnerScaleSet
 	f.updateRunnerScaleSetResult.RunnerScaleSet = defaultUpdatedRunnerScaleSet
 	f.createMessageSessionResult.RunnerScaleSetSession = defaultRunnerScaleSetSession
@@ -206,6 +273,8 @@ func (f *FakeClient) applyDefaults() {
 	f.generateJitRunnerConfigResult.RunnerScaleSetJitRunnerConfig = defaultRunnerScaleSetJitRunnerConfig
 	f.getRunnerResult.RunnerReference = defaultRunnerReference
 	f.getRunnerByNameResult.RunnerReference = defaultRunnerReference
+	f.getLatestRunnerVersionResult.version = defaultLatestRunnerVersion
+	f.createRegistrationTokenResult.RegistrationToken = defaultRegistrationToken
 }
 
 func (f *FakeClient) GetRunnerScaleSet(ctx context.Context, runnerScaleSetName string) (*actions.RunnerScaleSet, error) {
@@ -220,6 +289,10 @@ func (f *FakeClient) GetRunnerGroupByName(ctx context.Context, runnerGroup strin
 	return f.getRunnerGroupByNameResult.RunnerGroup, f.getRunnerGroupByNameResult.err
 }
 
+func (f *FakeClient) CreateRunnerGroup(ctx context.Context, runnerGroup string) (*actions.RunnerGroup, error) {
+	return f.createRunnerGroupResult.RunnerGroup, f.createRunnerGroupResult.err
+}
+
 func (f *FakeClient) CreateRunnerScaleSet(ctx context.Context, runnerScaleSet *actions.RunnerScaleSet) (*actions.RunnerScaleSet, error) {
 	return f.createRunnerScaleSetResult.RunnerScaleSet, f.createRunnerScaleSetResult.err
 }
@@ -272,6 +345,18 @@ func (f *FakeClient) GetRunnerByName(ctx context.Context, runnerName string) (*a
 	return f.getRunnerByNameResult.RunnerReference, f.getRunnerByNameResult.err
 }
 
+func (f *FakeClient) GetRunnersByScaleSet(ctx context.Context, runnerScaleSetId int) ([]actions.RunnerReference, error) {
+	return f.getRunnersByScaleSetResult.runners, f.getRunnersByScaleSetResult.err
+}
+
 func (f *FakeClient) RemoveRunner(ctx context.Context, runnerId int64) error {
 	return f.removeRunnerResult.err
 }
+
+func (f *FakeClient) GetLatestRunnerVersion(ctx context.Context) (string, error) {
+	return f.getLatestRunnerVersionResult.version, f.getLatestRunnerVersionResult.err
+}
+
+func (f *FakeClient) CreateRegistrationToken(ctx context.Context) (*actions.RegistrationToken, error) {
+	return f.createRegistrationTokenResult.RegistrationToken, f.createRegistrationTokenResult.err
+}
@@ -41,3 +41,8 @@ func (f *fakeMultiClient) GetClientFor(ctx context.Context, githubConfigURL stri
 func (f *fakeMultiClient) GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secretData actions.KubernetesSecretData, options ...actions.ClientOption) (actions.ActionsService, error) {
 	return f.defaultClient, f.defaultErr
 }
+
+func (f *fakeMultiClient) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -1,11 +1,15 @@
 package actions_test
 
 import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http/httpproxy"
 )
 
 func TestClient_Identifier(t *testing.T) {
@@ -108,4 +112,49 @@ func TestClient_Identifier(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("proxy and TLS settings change", func(t *testing.T) {
+		configURL := "https://github.com/org/repo"
+		defaultCreds := &actions.ActionsAuth{
+			Token: "token",
+		}
+
+		cert, err := os.ReadFile(filepath.Join("testdata", "rootCA.crt"))
+		require.NoError(t, err)
+		rootCAs := x509.NewCertPool()
+		require.True(t, rootCAs.AppendCertsFromPEM(cert))
+
+		scenarios := []struct {
+			name    string
+			options []actions.ClientOption
+		}{
+			{
+				name:    "proxy",
+				options: []actions.ClientOption{actions.WithProxy(&httpproxy.Config{HTTPSProxy: "http://proxy.example.com:3128"})},
+			},
+			{
+				name:    "root CAs",
+				options: []actions.ClientOption{actions.WithRootCAs(rootCAs)},
+			},
+			{
+				name:    "TLS verification",
+				options: []actions.ClientOption{actions.WithoutTLSVerify()},
+			},
+		}
+
+		oldClient, err := actions.NewClient(configURL, defaultCreds)
+		require.NoError(t, err)
+
+		for _, scenario := range scenarios {
+			t.Run(scenario.name, func(t *testing.T) {
+				newClient, err := actions.NewClient(configURL, defaultCreds, scenario.options...)
+				require.NoError(t, err)
+				assert.NotEqual(t, oldClient.Identifier(), newClient.Identifier())
+
+				sameClient, err := actions.NewClient(configURL, defaultCreds, scenario.options...)
+				require.NoError(t, err)
+				assert.Equal(t, newClient.Identifier(), sameClient.Identifier())
+			})
+		}
+	})
 }
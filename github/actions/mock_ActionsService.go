@@ -60,6 +60,52 @@ func (_m *MockActionsService) CreateMessageSession(ctx context.Context, runnerSc
 	return r0, r1
 }
 
+// CreateRegistrationToken provides a mock function with given fields: ctx
+func (_m *MockActionsService) CreateRegistrationToken(ctx context.Context) (*RegistrationToken, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *RegistrationToken
+	if rf, ok := ret.Get(0).(func(context.Context) *RegistrationToken); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RegistrationToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateRunnerGroup provides a mock function with given fields: ctx, runnerGroup
+func (_m *MockActionsService) CreateRunnerGroup(ctx context.Context, runnerGroup string) (*RunnerGroup, error) {
+	ret := _m.Called(ctx, runnerGroup)
+
+	var r0 *RunnerGroup
+	if rf, ok := ret.Get(0).(func(context.Context, string) *RunnerGroup); ok {
+		r0 = rf(ctx, runnerGroup)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RunnerGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, runnerGroup)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateRunnerScaleSet provides a mock function with given fields: ctx, runnerScaleSet
 func (_m *MockActionsService) CreateRunnerScaleSet(ctx context.Context, runnerScaleSet *RunnerScaleSet) (*RunnerScaleSet, error) {
 	ret := _m.Called(ctx, runnerScaleSet)
@@ -171,6 +217,27 @@ func (_m *MockActionsService) GetAcquirableJobs(ctx context.Context, runnerScale
 	return r0, r1
 }
 
+// GetLatestRunnerVersion provides a mock function with given fields: ctx
+func (_m *MockActionsService) GetLatestRunnerVersion(ctx context.Context) (string, error) {
+	ret := _m.Called(ctx)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetMessage provides a mock function with given fields: ctx, messageQueueUrl, messageQueueAccessToken, lastMessageId
 func (_m *MockActionsService) GetMessage(ctx context.Context, messageQueueUrl string, messageQueueAccessToken string, lastMessageId int64) (*RunnerScaleSetMessage, error) {
 	ret := _m.Called(ctx, messageQueueUrl, messageQueueAccessToken, lastMessageId)
@@ -263,6 +330,29 @@ func (_m *MockActionsService) GetRunnerGroupByName(ctx context.Context, runnerGr
 	return r0, r1
 }
 
+// GetRunnersByScaleSet provides a mock function with given fields: ctx, runnerScaleSetId
+func (_m *MockActionsService) GetRunnersByScaleSet(ctx context.Context, runnerScaleSetId int) ([]RunnerReference, error) {
+	ret := _m.Called(ctx, runnerScaleSetId)
+
+	var r0 []RunnerReference
+	if rf, ok := ret.Get(0).(func(context.Context, int) []RunnerReference); ok {
+		r0 = rf(ctx, runnerScaleSetId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]RunnerReference)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, runnerScaleSetId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRunnerScaleSet provides a mock function with given fields: ctx, runnerScaleSetName
 func (_m *MockActionsService) GetRunnerScaleSet(ctx context.Context, runnerScaleSetName string) (*RunnerScaleSet, error) {
 	ret := _m.Called(ctx, runnerScaleSetName)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 )
@@ -13,15 +14,31 @@ import (
 type MultiClient interface {
 	GetClientFor(ctx context.Context, githubConfigURL string, creds ActionsAuth, namespace string, options ...ClientOption) (ActionsService, error)
 	GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secretData KubernetesSecretData, options ...ClientOption) (ActionsService, error)
+	// Start refreshes the GitHub App installation access tokens of the clients ahead of their expiry,
+	// until ctx is done.
+	Start(ctx context.Context) error
 }
 
+// clientIdleTimeout is how long a client is kept after it was last requested.
+const clientIdleTimeout = time.Hour
+
 type multiClient struct {
 	// To lock adding and removing of individual clients.
 	mu      sync.Mutex
 	clients map[ActionsClientKey]*Client
+	// when each client was last requested, to evict clients that are no longer used
+	lastUsed map[ActionsClientKey]time.Time
+	now      func() time.Time
+
+	// tokens of the clients, by client key
+	tokens       *tokenCache
+	accessTokens *accessTokenCache
 
 	logger    logr.Logger
 	userAgent string
+
+	// options applied to every client, before the ones of the caller
+	options []ClientOption
 }
 
 type GitHubAppAuth struct {
@@ -38,20 +55,36 @@ type ActionsAuth struct {
 	Token string
 }
 
+// ActionsClientKey identifies a client of the MultiClient.
+// The identifier covers the config URL, credentials, proxy and TLS settings of the client.
+// The admin tokens and JIT configs the MultiClient caches are scoped by the whole key, so clients
+// never share connections, admin tokens or JIT configs across namespaces or configurations.
+// Installation access tokens are cached by GitHub App installation, since any client with the
+// credentials of the app can mint them.
 type ActionsClientKey struct {
 	Identifier string
 	Namespace  string
 }
 
-func NewMultiClient(userAgent string, logger logr.Logger) MultiClient {
+func NewMultiClient(userAgent string, logger logr.Logger, options ...ClientOption) MultiClient {
 	return &multiClient{
-		mu:        sync.Mutex{},
-		clients:   make(map[ActionsClientKey]*Client),
-		logger:    logger,
-		userAgent: userAgent,
+		mu:           sync.Mutex{},
+		clients:      make(map[ActionsClientKey]*Client),
+		lastUsed:     make(map[ActionsClientKey]time.Time),
+		now:          time.Now,
+		tokens:       newTokenCache(),
+		accessTokens: newAccessTokenCache(),
+		logger:       logger,
+		userAgent:    userAgent,
+		options:      options,
 	}
 }
 
+func (m *multiClient) Start(ctx context.Context) error {
+	m.accessTokens.refreshLoop(ctx, accessTokenRefreshInterval, m.logger)
+	return nil
+}
+
 func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string, creds ActionsAuth, namespace string, options ...ClientOption) (ActionsService, error) {
 	m.logger.Info("retrieve actions client", "githubConfigURL", githubConfigURL, "namespace", namespace)
 
@@ -66,10 +99,12 @@ func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string,
 	client, err := NewClient(
 		githubConfigURL,
 		&creds,
-		append([]ClientOption{
+		append(append([]ClientOption{
 			WithUserAgent(m.userAgent),
 			WithLogger(m.logger),
-		}, options...)...,
+			withTokenCache(m.tokens, namespace),
+			withAccessTokenCache(m.accessTokens),
+		}, m.options...), options...)...,
 	)
 	if err != nil {
 		return nil, err
@@ -83,6 +118,9 @@ func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string,
 		Namespace:  namespace,
 	}
 
+	m.lastUsed[key] = m.now()
+	m.evictClients(key)
+
 	cachedClient, has := m.clients[key]
 	if has {
 		m.logger.Info("using cache client", "githubConfigURL", githubConfigURL, "namespace", namespace)
@@ -98,6 +136,33 @@ func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string,
 	return client, nil
 }
 
+// evictClients removes the clients that have not been requested for a while, such as the
+// clients of deleted scale sets or of credentials, proxy or TLS settings that were changed.
+// Must be called with m.mu held.
+func (m *multiClient) evictClients(requested ActionsClientKey) {
+	now := m.now()
+	for key, client := range m.clients {
+		if key == requested {
+			continue
+		}
+
+		lastUsed, ok := m.lastUsed[key]
+		if !ok {
+			m.lastUsed[key] = now
+			lastUsed = now
+		}
+
+		if now.Sub(lastUsed) <= clientIdleTimeout {
+			continue
+		}
+
+		m.logger.Info("removing idle client", "githubConfigURL", client.config.ConfigURL.String(), "namespace", key.Namespace)
+		client.CloseIdleConnections()
+		delete(m.clients, key)
+		delete(m.lastUsed, key)
+	}
+}
+
 type KubernetesSecretData map[string][]byte
 
 func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL, namespace string, secretData KubernetesSecretData, options ...ClientOption) (ActionsService, error) {
@@ -111,7 +176,8 @@ func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL,
 	appID := string(secretData["github_app_id"])
 	appInstallationID := string(secretData["github_app_installation_id"])
 	appPrivateKey := string(secretData["github_app_private_key"])
-	hasGitHubAppAuth := len(appID) > 0 && len(appInstallationID) > 0 && len(appPrivateKey) > 0
+	// The installation is discovered from the config URL when its ID is not set.
+	hasGitHubAppAuth := len(appID) > 0 && len(appPrivateKey) > 0
 
 	if hasToken && hasGitHubAppAuth {
 		return nil, fmt.Errorf("must provide secret with only PAT or GitHub App Auth to avoid ambiguity in client behavior")
@@ -125,7 +191,7 @@ func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL,
 
 	if hasToken {
 		auth.Token = token
-		return m.GetClientFor(ctx, githubConfigURL, auth, namespace)
+		return m.GetClientFor(ctx, githubConfigURL, auth, namespace, options...)
 	}
 
 	parsedAppID, err := strconv.ParseInt(appID, 10, 64)
@@ -133,13 +199,16 @@ func (m *multiClient) GetClientFromSecret(ctx context.Context, githubConfigURL,
 		return nil, err
 	}
 
-	parsedAppInstallationID, err := strconv.ParseInt(appInstallationID, 10, 64)
-	if err != nil {
-		return nil, err
+	var parsedAppInstallationID int64
+	if len(appInstallationID) > 0 {
+		parsedAppInstallationID, err = strconv.ParseInt(appInstallationID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	auth.AppCreds = &GitHubAppAuth{AppID: parsedAppID, AppInstallationID: parsedAppInstallationID, AppPrivateKey: appPrivateKey}
-	return m.GetClientFor(ctx, githubConfigURL, auth, namespace)
+	return m.GetClientFor(ctx, githubConfigURL, auth, namespace, options...)
 }
 
 func RootCAsFromConfigMap(configMapData map[string][]byte) (*x509.CertPool, error) {
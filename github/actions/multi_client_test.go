@@ -3,8 +3,11 @@ package actions
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/actions/actions-runner-controller/github/actions/testserver"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -102,3 +105,84 @@ jQ97eJWiWtDcsMUhcZgoB5ydHcFlrBIn6oBcpge5
 	}
 	fmt.Println(jwt)
 }
+
+func TestMultiClientIsolatesTokensAcrossNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	jitConfigCalls := 0
+	server := testserver.New(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		jitConfigCalls++
+		w.Write([]byte(`{"runner":{"id":1,"name":"runner"},"encodedJITConfig":"config"}`))
+	}))
+
+	multiClient := NewMultiClient("test-user-agent", logr.Discard()).(*multiClient)
+	creds := ActionsAuth{Token: "token"}
+
+	service, err := multiClient.GetClientFor(ctx, server.ConfigURLForOrg("my-org"), creds, "default")
+	require.NoError(t, err)
+	client := service.(*Client)
+
+	jitSetting := &RunnerScaleSetJitRunnerSetting{Name: "runner"}
+	_, err = client.GenerateJitRunnerConfig(ctx, jitSetting, 1)
+	require.NoError(t, err)
+
+	// A client for another namespace, with the same config and credentials, mints its own admin token
+	// and JIT config.
+	otherService, err := multiClient.GetClientFor(ctx, server.ConfigURLForOrg("my-org"), creds, "other")
+	require.NoError(t, err)
+	otherClient := otherService.(*Client)
+	otherKey := ActionsClientKey{Identifier: client.Identifier(), Namespace: "other"}
+
+	_, ok := multiClient.tokens.adminConnection(otherKey)
+	assert.False(t, ok, "The admin token of the default namespace must not be cached for the other namespace")
+
+	_, err = otherClient.GenerateJitRunnerConfig(ctx, jitSetting, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, jitConfigCalls, "The JIT config of the default namespace must not be handed out to the other namespace")
+	_, ok = multiClient.tokens.adminConnection(otherKey)
+	assert.True(t, ok)
+
+	// Removing the runner in the other namespace leaves the JIT config of the default namespace alone.
+	require.NoError(t, otherClient.RemoveRunner(ctx, 1))
+	_, err = client.GenerateJitRunnerConfig(ctx, jitSetting, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, jitConfigCalls)
+
+	// Removing the runner invalidates its JIT config.
+	require.NoError(t, client.RemoveRunner(ctx, 1))
+	_, err = client.GenerateJitRunnerConfig(ctx, jitSetting, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, jitConfigCalls)
+}
+
+func TestMultiClientEvictsIdleClients(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	multiClient := NewMultiClient("test-user-agent", logr.Discard()).(*multiClient)
+	multiClient.now = func() time.Time { return now }
+
+	creds := ActionsAuth{Token: "token"}
+	_, err := multiClient.GetClientFor(ctx, "https://github.com/org/repo", creds, "default")
+	require.NoError(t, err)
+
+	now = now.Add(30 * time.Minute)
+	_, err = multiClient.GetClientFor(ctx, "https://github.com/org/other-repo", creds, "default")
+	require.NoError(t, err)
+	assert.Len(t, multiClient.clients, 2)
+
+	// The first client is idle for longer than the timeout, the second one is not.
+	now = now.Add(clientIdleTimeout)
+	_, err = multiClient.GetClientFor(ctx, "https://github.com/org/other-repo", creds, "default")
+	require.NoError(t, err)
+	assert.Len(t, multiClient.clients, 1)
+
+	for key := range multiClient.clients {
+		assert.Equal(t, "default", key.Namespace)
+		assert.Equal(t, "https://github.com/org/other-repo", multiClient.clients[key].config.ConfigURL.String())
+	}
+}
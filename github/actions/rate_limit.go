@@ -0,0 +1,227 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
+const (
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRateLimitResource  = "X-RateLimit-Resource"
+
+	// The Actions service delays requests of a client that uses too many resources,
+	// and reports the delay in seconds with this header.
+	headerRateLimitDelay = "X-RateLimit-Delay"
+
+	defaultRateLimitResource = "core"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		metricRateLimitLimit,
+		metricRateLimitRemaining,
+		metricRateLimitReset,
+		metricRateLimitedResponses,
+		metricThrottledResponses,
+		metricThrottleDelay,
+	)
+}
+
+var (
+	metricRateLimitLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_client_rate_limit",
+			Help: "The maximum number of requests the actions client is permitted to make in the current rate limit window",
+		},
+		[]string{"host", "resource"},
+	)
+	metricRateLimitRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_client_rate_limit_remaining",
+			Help: "The number of requests remaining for the actions client in the current rate limit window",
+		},
+		[]string{"host", "resource"},
+	)
+	metricRateLimitReset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_client_rate_limit_reset_timestamp_seconds",
+			Help: "The time at which the current rate limit window of the actions client resets, in seconds since the epoch",
+		},
+		[]string{"host", "resource"},
+	)
+	metricRateLimitedResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_actions_client_rate_limited_responses_total",
+			Help: "The number of responses rejected by GitHub because of a primary or secondary rate limit",
+		},
+		[]string{"host"},
+	)
+	metricThrottledResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_actions_client_throttled_responses_total",
+			Help: "The number of responses the Actions service delayed because the actions client used too many resources",
+		},
+		[]string{"host"},
+	)
+	metricThrottleDelay = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_actions_client_throttle_delay_seconds_total",
+			Help: "The total delay the Actions service added to the responses of the actions client because of throttling",
+		},
+		[]string{"host"},
+	)
+)
+
+// isRateLimited reports whether the response was rejected because of a rate limit.
+// GitHub responds with 403 or 429 for both primary and secondary rate limits,
+// and the Actions service with 429 when it throttles the client.
+func isRateLimited(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	if resp.Header.Get(headerRetryAfter) != "" {
+		return true
+	}
+	return resp.Header.Get(headerRateLimitRemaining) == "0"
+}
+
+// rateLimitDelay returns how long to wait before sending requests again
+// after a rate limited response.
+func rateLimitDelay(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if retryAfter := resp.Header.Get(headerRetryAfter); retryAfter != "" {
+		if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(retryAfter); err == nil {
+			return at.Sub(now), true
+		}
+	}
+
+	if resp.Header.Get(headerRateLimitRemaining) == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64); err == nil {
+			return time.Unix(reset, 0).Sub(now), true
+		}
+	}
+
+	return 0, false
+}
+
+// checkRetry retries rate limited responses in addition to the cases
+// handled by the default retry policy.
+func checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() == nil && err == nil && isRateLimited(resp) {
+		return true, nil
+	}
+	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// backoff waits as long as the server asks for on rate limited responses,
+// up to max, and falls back to the default exponential backoff otherwise.
+func backoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if isRateLimited(resp) {
+		if delay, ok := rateLimitDelay(resp, time.Now()); ok {
+			if delay < 0 {
+				return 0
+			}
+			if delay > max {
+				return max
+			}
+			return delay
+		}
+	}
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+}
+
+// observeRateLimit updates the rate limit metrics from the response and
+// delays further requests of the client when the response was rate limited.
+// The metrics are labeled with the host the request was sent to, since the
+// GitHub API and the Actions service have rate limits of their own.
+func (c *Client) observeRateLimit(resp *http.Response) {
+	host := c.config.ConfigURL.Host
+	if resp.Request != nil && resp.Request.URL != nil {
+		host = resp.Request.URL.Host
+	}
+
+	resource := resp.Header.Get(headerRateLimitResource)
+	if resource == "" {
+		resource = defaultRateLimitResource
+	}
+	if limit, err := strconv.Atoi(resp.Header.Get(headerRateLimitLimit)); err == nil {
+		metricRateLimitLimit.WithLabelValues(host, resource).Set(float64(limit))
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get(headerRateLimitRemaining)); err == nil {
+		metricRateLimitRemaining.WithLabelValues(host, resource).Set(float64(remaining))
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64); err == nil {
+		metricRateLimitReset.WithLabelValues(host, resource).Set(float64(reset))
+	}
+	if delay, err := strconv.ParseFloat(resp.Header.Get(headerRateLimitDelay), 64); err == nil && delay > 0 {
+		metricThrottledResponses.WithLabelValues(host).Inc()
+		metricThrottleDelay.WithLabelValues(host).Add(delay)
+	}
+
+	if !isRateLimited(resp) {
+		return
+	}
+
+	metricRateLimitedResponses.WithLabelValues(host).Inc()
+
+	now := time.Now()
+	delay, ok := rateLimitDelay(resp, now)
+	if !ok || delay <= 0 {
+		return
+	}
+
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+
+	until := now.Add(delay)
+	if until.After(c.rateLimitedUntil) {
+		c.logger.Info("rate limited by GitHub, delaying requests", "status", resp.StatusCode, "until", until)
+		c.rateLimitedUntil = until
+	}
+}
+
+// waitForRateLimit blocks until the rate limit reported by a previous response
+// has passed, so that requests are delayed instead of failing in bursts.
+// Waits longer than the maximum retry wait fail right away instead, so that a
+// rate limited client doesn't hold up the callers serving other clients.
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.rateLimitMu.Lock()
+	until := c.rateLimitedUntil
+	c.rateLimitMu.Unlock()
+
+	delay := time.Until(until)
+	if delay <= 0 {
+		return nil
+	}
+	if delay > c.retryWaitMax {
+		return &RateLimitedError{Until: until}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
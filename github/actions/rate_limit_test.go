@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveRateLimit(t *testing.T) {
+	c := &Client{
+		config: &GitHubConfig{ConfigURL: &url.URL{Scheme: "https", Host: "github.example.com"}},
+		logger: logr.Discard(),
+	}
+	newResponse := func(host string, status int, headers map[string]string) *http.Response {
+		resp := &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Request:    &http.Request{URL: &url.URL{Scheme: "https", Host: host}},
+		}
+		for k, v := range headers {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+
+	t.Run("GitHub API rate limit", func(t *testing.T) {
+		c.observeRateLimit(newResponse("api.github.example.com", http.StatusOK, map[string]string{
+			"X-RateLimit-Limit":     "5000",
+			"X-RateLimit-Remaining": "4200",
+			"X-RateLimit-Reset":     "1700000000",
+		}))
+
+		assert.Equal(t, 5000.0, testutil.ToFloat64(metricRateLimitLimit.WithLabelValues("api.github.example.com", "core")))
+		assert.Equal(t, 4200.0, testutil.ToFloat64(metricRateLimitRemaining.WithLabelValues("api.github.example.com", "core")))
+		assert.Equal(t, 1700000000.0, testutil.ToFloat64(metricRateLimitReset.WithLabelValues("api.github.example.com", "core")))
+	})
+
+	t.Run("Actions service throttling", func(t *testing.T) {
+		host := "pipelines.actions.example.com"
+		c.observeRateLimit(newResponse(host, http.StatusOK, map[string]string{"X-RateLimit-Delay": "1.5"}))
+		c.observeRateLimit(newResponse(host, http.StatusTooManyRequests, map[string]string{"X-RateLimit-Delay": "0.5"}))
+
+		assert.Equal(t, 2.0, testutil.ToFloat64(metricThrottledResponses.WithLabelValues(host)))
+		assert.Equal(t, 2.0, testutil.ToFloat64(metricThrottleDelay.WithLabelValues(host)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metricRateLimitedResponses.WithLabelValues(host)), "Too many requests is rate limited without a Retry-After")
+	})
+}
@@ -0,0 +1,118 @@
+package actions
+
+import (
+	"sync"
+	"time"
+)
+
+// jitConfigCacheTTL is how long a generated JIT config is handed out again for the
+// same runner. It only has to cover reconciles that failed to persist the config.
+const jitConfigCacheTTL = 10 * time.Minute
+
+// tokenCache keeps the tokens of the clients of a MultiClient, so that a client re-created
+// for the same key, e.g. after it was idle, doesn't mint its own tokens.
+// Entries are keyed by the ActionsClientKey of the client, so they are never shared across namespaces.
+type tokenCache struct {
+	mu                sync.Mutex
+	adminConnections  map[ActionsClientKey]cachedAdminConnection
+	jitRunnerConfigs  map[jitConfigCacheKey]cachedJitRunnerConfig
+	now               func() time.Time
+	jitConfigCacheTTL time.Duration
+}
+
+type cachedAdminConnection struct {
+	actionsServiceURL string
+	adminToken        string
+	expiresAt         time.Time
+}
+
+type jitConfigCacheKey struct {
+	client     ActionsClientKey
+	scaleSetId int
+	runnerName string
+}
+
+type cachedJitRunnerConfig struct {
+	config    *RunnerScaleSetJitRunnerConfig
+	expiresAt time.Time
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		adminConnections:  make(map[ActionsClientKey]cachedAdminConnection),
+		jitRunnerConfigs:  make(map[jitConfigCacheKey]cachedJitRunnerConfig),
+		now:               time.Now,
+		jitConfigCacheTTL: jitConfigCacheTTL,
+	}
+}
+
+// adminConnection returns the cached admin connection unless it is about to expire.
+func (tc *tokenCache) adminConnection(client ActionsClientKey) (cachedAdminConnection, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	conn, ok := tc.adminConnections[client]
+	if !ok || tc.now().Add(60*time.Second).After(conn.expiresAt) {
+		return cachedAdminConnection{}, false
+	}
+	return conn, true
+}
+
+func (tc *tokenCache) setAdminConnection(client ActionsClientKey, conn cachedAdminConnection) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.adminConnections[client] = conn
+}
+
+// removeAdminConnection drops the cached admin connection when it still holds the given token,
+// leaving alone one another client already re-issued.
+func (tc *tokenCache) removeAdminConnection(client ActionsClientKey, adminToken string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if conn, ok := tc.adminConnections[client]; ok && conn.adminToken == adminToken {
+		delete(tc.adminConnections, client)
+	}
+}
+
+func (tc *tokenCache) jitRunnerConfig(key jitConfigCacheKey) (*RunnerScaleSetJitRunnerConfig, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	cached, ok := tc.jitRunnerConfigs[key]
+	if !ok || !tc.now().Before(cached.expiresAt) {
+		return nil, false
+	}
+	return cached.config, true
+}
+
+func (tc *tokenCache) setJitRunnerConfig(key jitConfigCacheKey, config *RunnerScaleSetJitRunnerConfig) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	now := tc.now()
+	for k, cached := range tc.jitRunnerConfigs {
+		if !now.Before(cached.expiresAt) {
+			delete(tc.jitRunnerConfigs, k)
+		}
+	}
+
+	tc.jitRunnerConfigs[key] = cachedJitRunnerConfig{
+		config:    config,
+		expiresAt: now.Add(tc.jitConfigCacheTTL),
+	}
+}
+
+// removeRunner drops the JIT config of a removed runner, so that a new
+// registration is created when a runner with the same name is requested again.
+func (tc *tokenCache) removeRunner(client ActionsClientKey, runnerId int64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for k, cached := range tc.jitRunnerConfigs {
+		if k.client == client && cached.config.Runner != nil && int64(cached.config.Runner.Id) == runnerId {
+			delete(tc.jitRunnerConfigs, k)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCache(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTokenCache()
+	cache.now = func() time.Time { return now }
+	client := ActionsClientKey{Identifier: "id", Namespace: "default"}
+	otherNamespace := ActionsClientKey{Identifier: "id", Namespace: "other"}
+
+	t.Run("Admin connections about to expire are not returned", func(t *testing.T) {
+		cache.setAdminConnection(client, cachedAdminConnection{
+			actionsServiceURL: "https://pipelines.actions.githubusercontent.com/abc",
+			adminToken:        "token",
+			expiresAt:         now.Add(5 * time.Minute),
+		})
+
+		conn, ok := cache.adminConnection(client)
+		assert.True(t, ok)
+		assert.Equal(t, "token", conn.adminToken)
+
+		_, ok = cache.adminConnection(ActionsClientKey{Identifier: "other", Namespace: "default"})
+		assert.False(t, ok)
+
+		_, ok = cache.adminConnection(otherNamespace)
+		assert.False(t, ok, "admin connections should not be shared across namespaces")
+
+		cache.setAdminConnection(client, cachedAdminConnection{adminToken: "token", expiresAt: now.Add(30 * time.Second)})
+		_, ok = cache.adminConnection(client)
+		assert.False(t, ok)
+	})
+
+	t.Run("JIT configs expire and are dropped with their runner", func(t *testing.T) {
+		key := jitConfigCacheKey{client: client, scaleSetId: 1, runnerName: "runner"}
+		cache.setJitRunnerConfig(key, &RunnerScaleSetJitRunnerConfig{Runner: &RunnerReference{Id: 10}, EncodedJITConfig: "config"})
+
+		config, ok := cache.jitRunnerConfig(key)
+		assert.True(t, ok)
+		assert.Equal(t, "config", config.EncodedJITConfig)
+
+		_, ok = cache.jitRunnerConfig(jitConfigCacheKey{client: otherNamespace, scaleSetId: 1, runnerName: "runner"})
+		assert.False(t, ok, "JIT configs should not be shared across namespaces")
+
+		cache.removeRunner(otherNamespace, 10)
+		_, ok = cache.jitRunnerConfig(key)
+		assert.True(t, ok, "runners of other clients should not be affected")
+
+		cache.removeRunner(client, 10)
+		_, ok = cache.jitRunnerConfig(key)
+		assert.False(t, ok)
+
+		cache.setJitRunnerConfig(key, &RunnerScaleSetJitRunnerConfig{Runner: &RunnerReference{Id: 10}})
+		now = now.Add(jitConfigCacheTTL)
+		_, ok = cache.jitRunnerConfig(key)
+		assert.False(t, ok)
+	})
+}
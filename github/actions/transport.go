@@ -0,0 +1,167 @@
+package actions
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// TransportSettings tunes the connection pool of the HTTP transport of the client.
+// Zero values keep the defaults of the transport.
+type TransportSettings struct {
+	// MaxIdleConns is the maximum number of idle connections across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept per host.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept before it is closed.
+	IdleConnTimeout time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake of new connections.
+	TLSHandshakeTimeout time.Duration
+}
+
+// WithTransportSettings tunes the connection pool of the client.
+func WithTransportSettings(settings TransportSettings) ClientOption {
+	return func(c *Client) {
+		c.transportSettings = settings
+	}
+}
+
+func (s TransportSettings) apply(transport *http.Transport) {
+	if s.MaxIdleConns > 0 {
+		transport.MaxIdleConns = s.MaxIdleConns
+	}
+	if s.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = s.IdleConnTimeout
+	}
+	if s.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = s.TLSHandshakeTimeout
+	}
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		metricDNSDuration,
+		metricConnectDuration,
+		metricTLSHandshakeDuration,
+		metricTimeToFirstByte,
+		metricConnections,
+	)
+}
+
+var (
+	metricDNSDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "github_actions_client_dns_duration_seconds",
+			Help:    "The duration of the DNS lookups of new connections of the actions client",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"host"},
+	)
+	metricConnectDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "github_actions_client_connect_duration_seconds",
+			Help:    "The duration of the TCP connection establishment of new connections of the actions client",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"host"},
+	)
+	metricTLSHandshakeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "github_actions_client_tls_handshake_duration_seconds",
+			Help:    "The duration of the TLS handshakes of new connections of the actions client",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"host"},
+	)
+	metricTimeToFirstByte = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "github_actions_client_time_to_first_byte_seconds",
+			Help:    "The duration from sending a request of the actions client to the first byte of its response, connection included",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		},
+		[]string{"host"},
+	)
+	metricConnections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_actions_client_connections_total",
+			Help: "The number of connections used by the requests of the actions client, by whether they were reused from the pool",
+		},
+		[]string{"host", "reused"},
+	)
+)
+
+// metricsTransport observes the phases of every round trip: DNS lookup, connection, TLS handshake
+// and time to first byte, and whether the connection was reused.
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	start := time.Now()
+
+	// Connections may be dialed concurrently, e.g. to several addresses of the host.
+	var (
+		mu           sync.Mutex
+		dnsStart     time.Time
+		connectStart time.Time
+		tlsStart     time.Time
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if info.Err == nil && !dnsStart.IsZero() {
+				metricDNSDuration.WithLabelValues(host).Observe(time.Since(dnsStart).Seconds())
+			}
+		},
+		ConnectStart: func(_, _ string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && !connectStart.IsZero() {
+				metricConnectDuration.WithLabelValues(host).Observe(time.Since(connectStart).Seconds())
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && !tlsStart.IsZero() {
+				metricTLSHandshakeDuration.WithLabelValues(host).Observe(time.Since(tlsStart).Seconds())
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			metricConnections.WithLabelValues(host, strconv.FormatBool(info.Reused)).Inc()
+		},
+		GotFirstResponseByte: func() {
+			metricTimeToFirstByte.WithLabelValues(host).Observe(time.Since(start).Seconds())
+		},
+	}
+
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
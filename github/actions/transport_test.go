@@ -0,0 +1,53 @@
+package actions
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportSettings(t *testing.T) {
+	transport := &http.Transport{MaxIdleConns: 100, IdleConnTimeout: 90 * time.Second, TLSHandshakeTimeout: 10 * time.Second}
+
+	TransportSettings{MaxIdleConnsPerHost: 50, IdleConnTimeout: 5 * time.Minute}.apply(transport)
+
+	assert.Equal(t, 100, transport.MaxIdleConns, "Zero settings keep the defaults")
+	assert.Equal(t, 50, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Minute, transport.IdleConnTimeout)
+	assert.Equal(t, 10*time.Second, transport.TLSHandshakeTimeout)
+}
+
+func TestMetricsTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host := u.Host
+
+	tlsSeries := testutil.CollectAndCount(metricTLSHandshakeDuration)
+	ttfbSeries := testutil.CollectAndCount(metricTimeToFirstByte)
+
+	client := &http.Client{Transport: &metricsTransport{next: server.Client().Transport}}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricConnections.WithLabelValues(host, "false")), "The first request opens a connection")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metricConnections.WithLabelValues(host, "true")), "The next requests reuse it")
+	assert.Equal(t, tlsSeries+1, testutil.CollectAndCount(metricTLSHandshakeDuration), "The TLS handshake of the host is observed")
+	assert.Equal(t, ttfbSeries+1, testutil.CollectAndCount(metricTimeToFirstByte), "The time to first byte of the host is observed")
+}
@@ -77,6 +77,10 @@ type RunnerGroup struct {
 	IsDefault bool   `json:"isDefaultGroup"`
 }
 
+type RunnerGroupSetting struct {
+	Name string `json:"name"`
+}
+
 type RunnerGroupList struct {
 	Count        int           `json:"count"`
 	RunnerGroups []RunnerGroup `json:"value"`
@@ -141,6 +145,15 @@ type RunnerReferenceList struct {
 	RunnerReferences []RunnerReference `json:"value"`
 }
 
+// RunnerApplication is a runner release served for download by the GitHub instance.
+// Format: https://docs.github.com/en/rest/actions/self-hosted-runners#list-runner-applications-for-an-organization
+type RunnerApplication struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	DownloadURL  string `json:"download_url"`
+	Filename     string `json:"filename"`
+}
+
 type RunnerReference struct {
 	Id               int    `json:"id"`
 	Name             string `json:"name"`
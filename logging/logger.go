@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -88,3 +89,29 @@ func validLogFormat(logFormat string) bool {
 	}
 	return false
 }
+
+// ParseLevels parses the log levels of named loggers given in the name=level format,
+// e.g. AutoscalingRunnerSet=info,EphemeralRunner=debug.
+func ParseLevels(values []string) (map[string]string, error) {
+	levels := make(map[string]string, len(values))
+	for _, v := range values {
+		name, level, ok := strings.Cut(v, "=")
+		if !ok || name == "" || level == "" {
+			return nil, fmt.Errorf("invalid log level %q: expected name=level", v)
+		}
+		if !validLogLevel(level) {
+			return nil, fmt.Errorf("invalid log level %q of %s", level, name)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+func validLogLevel(logLevel string) bool {
+	switch logLevel {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+		return true
+	}
+	_, err := strconv.ParseInt(logLevel, 10, 8)
+	return err == nil
+}
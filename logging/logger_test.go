@@ -0,0 +1,23 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels([]string{"AutoscalingRunnerSet=info", "EphemeralRunner=-2", "AutoscalingListener=error"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"AutoscalingRunnerSet": LogLevelInfo,
+		"EphemeralRunner":      "-2",
+		"AutoscalingListener":  LogLevelError,
+	}, levels)
+
+	for _, invalid := range []string{"AutoscalingRunnerSet", "=info", "EphemeralRunner=", "EphemeralRunner=verbose"} {
+		_, err := ParseLevels([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	githubv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	githubv1beta1 "github.com/actions/actions-runner-controller/apis/actions.github.com/v1beta1"
 	summerwindv1alpha1 "github.com/actions/actions-runner-controller/apis/actions.summerwind.net/v1alpha1"
 	"github.com/actions/actions-runner-controller/build"
 	actionsgithubcom "github.com/actions/actions-runner-controller/controllers/actions.github.com"
@@ -32,13 +33,19 @@ import (
 	"github.com/actions/actions-runner-controller/github"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
+	"github.com/actions/actions-runner-controller/pkg/objectstore"
+	"github.com/go-logr/logr"
 	"github.com/kelseyhightower/envconfig"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -54,6 +61,7 @@ var (
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = githubv1alpha1.AddToScheme(scheme)
+	_ = githubv1beta1.AddToScheme(scheme)
 	_ = summerwindv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
@@ -76,6 +84,10 @@ func main() {
 		metricsAddr              string
 		autoScalingRunnerSetOnly bool
 		enableLeaderElection     bool
+		leaderElectionNamespace  string
+		leaseDuration            time.Duration
+		renewDeadline            time.Duration
+		retryPeriod              time.Duration
 		disableAdmissionWebhook  bool
 		runnerStatusUpdateHook   bool
 		leaderElectionId         string
@@ -93,9 +105,45 @@ func main() {
 		logLevel             string
 		logFormat            string
 
-		autoScalerImagePullSecrets stringSlice
-
-		commonRunnerLabels commaSeparatedStringSlice
+		autoScalerImagePullSecrets    stringSlice
+		orphanedRunnerSweepInterval   time.Duration
+		workVolumeClaimSweepInterval  time.Duration
+		adminAPIAddr                  string
+		externalMetricsAddr           string
+		externalMetricsCertDir        string
+		preemptionTaints              string
+		globalMaxRunners              int
+		actionsAuditLog               bool
+		enableConversionWebhook       bool
+		workflowJobWebhookAddr        string
+		workflowJobWebhookSecretToken string
+		remoteCleanupTimeout          time.Duration
+		runnerVersionCheckInterval    time.Duration
+		enableListenerNodeFailover    bool
+		listenerNodeFailoverTimeout   time.Duration
+
+		commonRunnerLabels  commaSeparatedStringSlice
+		watchNamespaces     commaSeparatedStringSlice
+		controllerLogLevels commaSeparatedStringSlice
+
+		listenerLogLevel  string
+		listenerLogFormat string
+
+		controllerConfigMap string
+
+		actionsClientTransport actions.TransportSettings
+
+		defaultGitHubServerTLSConfigMap    string
+		defaultGitHubServerTLSConfigMapKey string
+
+		runnerDiagnosticsURL     string
+		runnerDiagnosticsTimeout time.Duration
+
+		dindNativeSidecar string
+
+		defaultPodSecurityContext       string
+		defaultContainerSecurityContext string
+		podSecurityAdmissionConfigFile  string
 	)
 	var c github.Config
 	err = envconfig.Process("github", &c)
@@ -108,6 +156,10 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&leaderElectionId, "leader-election-id", "actions-runner-controller", "Controller id for leader election.")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "", "The namespace of the leader election lease. Defaults to the namespace of the controller.")
+	flag.DurationVar(&leaseDuration, "leader-election-lease-duration", 15*time.Second, "How long non-leader controllers wait before taking over the lease of a leader that stopped renewing it.")
+	flag.DurationVar(&renewDeadline, "leader-election-renew-deadline", 10*time.Second, "How long the leader retries renewing the lease before giving up leadership. Must be shorter than the lease duration.")
+	flag.DurationVar(&retryPeriod, "leader-election-retry-period", 2*time.Second, "How long controllers wait between attempts to acquire or renew the lease.")
 	flag.StringVar(&runnerImage, "runner-image", defaultRunnerImage, "The image name of self-hosted runner container to use by default if one isn't defined in yaml.")
 	flag.StringVar(&dockerImage, "docker-image", defaultDockerImage, "The image name of docker sidecar container to use by default if one isn't defined in yaml.")
 	flag.Var(&runnerImagePullSecrets, "runner-image-pull-secret", "The default image-pull secret name for self-hosted runner container.")
@@ -128,10 +180,42 @@ func main() {
 	flag.DurationVar(&syncPeriod, "sync-period", 1*time.Minute, "Determines the minimum frequency at which K8s resources managed by this controller are reconciled.")
 	flag.Var(&commonRunnerLabels, "common-runner-labels", "Runner labels in the K1=V1,K2=V2,... format that are inherited all the runners created by the controller. See https://github.com/actions/actions-runner-controller/issues/321 for more information")
 	flag.StringVar(&namespace, "watch-namespace", "", "The namespace to watch for custom resources. Set to empty for letting it watch for all namespaces.")
+	flag.Var(&watchNamespaces, "watch-namespaces", "Comma-separated namespaces to watch for custom resources, in addition to the namespace of the controller. AutoscalingRunnerSets of these namespaces are isolated from each other: their listeners can't reference secrets or config maps. Can't be combined with --watch-namespace.")
 	flag.StringVar(&logLevel, "log-level", logging.LogLevelDebug, `The verbosity of the logging. Valid values are "debug", "info", "warn", "error". Defaults to "debug".`)
 	flag.StringVar(&logFormat, "log-format", "text", `The log format. Valid options are "text" and "json". Defaults to "text"`)
+	flag.Var(&controllerLogLevels, "controller-log-levels", `Comma-separated log levels of the AutoscalingRunnerSet, EphemeralRunnerSet, EphemeralRunner and AutoscalingListener controllers in the name=level format, e.g. "AutoscalingRunnerSet=info,EphemeralRunner=debug". Controllers not listed log at --log-level.`)
+	flag.StringVar(&listenerLogLevel, "listener-log-level", logging.LogLevelDebug, `The verbosity of the logging of the listeners. Valid values are "debug", "info", "warn", "error". Defaults to "debug".`)
+	flag.StringVar(&listenerLogFormat, "listener-log-format", logging.LogFormatText, `The log format of the listeners. Valid options are "text" and "json". Defaults to "text"`)
 	flag.BoolVar(&autoScalingRunnerSetOnly, "auto-scaling-runner-set-only", false, "Make controller only reconcile AutoRunnerScaleSet object.")
 	flag.Var(&autoScalerImagePullSecrets, "auto-scaler-image-pull-secrets", "The default image-pull secret name for auto-scaler listener container.")
+	flag.DurationVar(&orphanedRunnerSweepInterval, "orphaned-runner-sweep-interval", 0, "How often runners registered to a runner scale set are checked for a matching EphemeralRunner, removing the ones that have none. Scale sets that are retained or shared by several AutoscalingRunnerSets are not swept. Disabled by default.")
+	flag.DurationVar(&workVolumeClaimSweepInterval, "work-volume-claim-sweep-interval", 10*time.Minute, "How often the work volume claims of runners are checked for a matching EphemeralRunner, deleting the ones that have none. Set to 0 to disable.")
+	flag.StringVar(&adminAPIAddr, "admin-api-addr", "", "The address the read-only admin API serving the status of runner scale sets binds to. Set to empty to disable.")
+	flag.StringVar(&externalMetricsAddr, "external-metrics-addr", "", "The address the external metrics API serving the job statistics of runner scale sets binds to. Set to empty to disable.")
+	flag.StringVar(&externalMetricsCertDir, "external-metrics-cert-dir", "/tmp/k8s-external-metrics-server/serving-certs", "The directory holding the tls.crt and tls.key files of the external metrics API.")
+	flag.StringVar(&preemptionTaints, "node-preemption-taints", "", "Comma-separated keys of the taints marking nodes about to be preempted, e.g. "+strings.Join(actionsgithubcom.KnownPreemptionTaints, ",")+". Ephemeral runners on these nodes are replaced ahead of the preemption. Disabled by default.")
+	flag.IntVar(&globalMaxRunners, "global-max-runners", 0, "The maximum number of runners across all runner scale sets. Above it, runners are distributed across scale sets by their fair share weight. Set to 0 to disable.")
+	flag.BoolVar(&actionsAuditLog, "actions-audit-log", false, "Write every call to the GitHub and Actions service APIs of runner scale sets as a line of JSON audit record to stdout, in the controller and the listeners.")
+	flag.DurationVar(&runnerVersionCheckInterval, "runner-version-check-interval", actionsgithubcom.DefaultRunnerVersionCheckInterval, "How often the latest runner version is fetched for the autoscaling runner sets tracking their runner version.")
+	flag.BoolVar(&enableListenerNodeFailover, "enable-listener-node-failover", false, "Force delete the listener pods of nodes not ready for longer than --listener-node-failover-timeout, and re-create them on another node, instead of waiting for the pods to be evicted. A partitioned node can keep running the old listener meanwhile. Disabled by default.")
+	flag.DurationVar(&listenerNodeFailoverTimeout, "listener-node-failover-timeout", actionsgithubcom.DefaultListenerNodeFailoverTimeout, "How long the node of a listener pod can be not ready before the pod is force deleted, with --enable-listener-node-failover.")
+	flag.DurationVar(&remoteCleanupTimeout, "remote-cleanup-timeout", actionsgithubcom.DefaultRemoteCleanupTimeout, "How long deleted runner scale sets and runners retry cleaning up the Actions service before they are force deleted, leaving the runner scale set and runners there. Set to 0 to retry forever.")
+	flag.BoolVar(&enableConversionWebhook, "enable-conversion-webhook", false, "Serve the conversion webhook of the actions.github.com CRDs on the webhook port, for clients of the v1beta1 API. The CRDs have to be configured to call it.")
+	flag.StringVar(&workflowJobWebhookAddr, "workflow-job-webhook-addr", "", "The address the receiver of workflow_job webhook events binds to. Queued jobs add a runner to the runner scale sets they run on ahead of the listeners. Set to empty to disable.")
+	flag.StringVar(&workflowJobWebhookSecretToken, "workflow-job-webhook-secret-token", os.Getenv("GITHUB_WEBHOOK_SECRET_TOKEN"), "The secret validating the workflow_job webhook events, required with --workflow-job-webhook-addr. Defaults to the GITHUB_WEBHOOK_SECRET_TOKEN environment variable.")
+	flag.StringVar(&controllerConfigMap, "controller-config-map", "", "The name of a config map in the namespace of the controller whose settings override the flags of the runner scale set controllers, and are applied without restarting the controller. Set to empty to disable.")
+	flag.IntVar(&actionsClientTransport.MaxIdleConns, "actions-client-max-idle-conns", 0, "The maximum number of idle connections of the clients of GitHub and the Actions service, across all hosts. Set to 0 for the default of 100.")
+	flag.IntVar(&actionsClientTransport.MaxIdleConnsPerHost, "actions-client-max-idle-conns-per-host", 0, "The maximum number of idle connections of the clients of GitHub and the Actions service kept per host. Set to 0 for the default of the number of CPUs plus one.")
+	flag.DurationVar(&actionsClientTransport.IdleConnTimeout, "actions-client-idle-conn-timeout", 0, "How long the idle connections of the clients of GitHub and the Actions service are kept open. Set to 0 for the default of 90s.")
+	flag.DurationVar(&actionsClientTransport.TLSHandshakeTimeout, "actions-client-tls-handshake-timeout", 0, "The timeout of the TLS handshakes of the clients of GitHub and the Actions service. Set to 0 for the default of 10s.")
+	flag.StringVar(&defaultGitHubServerTLSConfigMap, "default-github-server-tls-config-map", "", "The name of a config map in the namespace of the controller holding the CA bundle of the GitHub server, used by the runner scale sets that don't set their own githubServerTLS. Set to empty to disable.")
+	flag.StringVar(&defaultGitHubServerTLSConfigMapKey, "default-github-server-tls-config-map-key", actionsgithubcom.DefaultGitHubServerTLSCertKey, "The key of the CA bundle in the config map of --default-github-server-tls-config-map.")
+	flag.StringVar(&runnerDiagnosticsURL, "runner-diagnostics-url", "", "The URL of the object store the diagnostics of the failed runner pods of the runner scale sets are uploaded to, e.g. s3://bucket/prefix?region=us-east-1, gs://bucket/prefix or azblob://account/container/prefix. Set to empty to disable.")
+	flag.DurationVar(&runnerDiagnosticsTimeout, "runner-diagnostics-timeout", actionsgithubcom.DefaultRunnerDiagnosticsTimeout, "How long collecting and uploading the diagnostics of a failed runner pod can take before the pod is deleted without them.")
+	flag.StringVar(&dindNativeSidecar, "dind-native-sidecar", actionsgithubcom.NativeSidecarAuto, `Whether the dind containers of the runner pods of the runner scale sets run as native sidecars, completing the pods once the runners exit. Valid values are "auto", "enabled" and "disabled". "auto" enables them on Kubernetes 1.29 and later.`)
+	flag.StringVar(&defaultPodSecurityContext, "default-pod-security-context", "", `The security context merged into the runner and listener pods of the runner scale sets, in JSON, e.g. {"runAsNonRoot":true,"seccompProfile":{"type":"RuntimeDefault"}}. The fields set by the pods are kept.`)
+	flag.StringVar(&defaultContainerSecurityContext, "default-container-security-context", "", `The security context merged into the containers of the runner and listener pods of the runner scale sets, in JSON, e.g. {"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]}}. The fields set by the containers are kept, and privileged containers are left as they are.`)
+	flag.StringVar(&podSecurityAdmissionConfigFile, "pod-security-admission-config", "", "The file holding the defaults and exemptions of the Pod Security admission of the cluster the runner pods are checked with: the AdmissionConfiguration of the API server, configuring the PodSecurity plugin inline, or its PodSecurityConfiguration. Without it, namespaces without Pod Security labels are privileged.")
 	flag.Parse()
 
 	log, err := logging.NewLogger(logLevel, logFormat)
@@ -141,6 +225,29 @@ func main() {
 	}
 	c.Log = &log
 
+	logLevels, err := logging.ParseLevels(controllerLogLevels)
+	if err != nil {
+		log.Error(err, "invalid --controller-log-levels")
+		os.Exit(1)
+	}
+	if _, err := logging.NewLogger(listenerLogLevel, listenerLogFormat); err != nil {
+		log.Error(err, "invalid listener log flags")
+		os.Exit(1)
+	}
+	// controllerLog returns the logger of the controller, at its own log level when one is given.
+	controllerLog := func(name string) logr.Logger {
+		level, ok := logLevels[name]
+		if !ok {
+			return log.WithName(name)
+		}
+		l, err := logging.NewLogger(level, logFormat)
+		if err != nil {
+			log.Error(err, "unable to create logger", "controller", name)
+			os.Exit(1)
+		}
+		return l.WithName(name)
+	}
+
 	if !autoScalingRunnerSetOnly {
 		ghClient, err = c.NewClient()
 		if err != nil {
@@ -156,14 +263,37 @@ func main() {
 		metricsAddr = "0"
 	}
 
+	if !enableListenerNodeFailover {
+		listenerNodeFailoverTimeout = 0
+	} else if listenerNodeFailoverTimeout <= 0 {
+		log.Error(fmt.Errorf("--listener-node-failover-timeout must be positive with --enable-listener-node-failover"), "invalid flags")
+		os.Exit(1)
+	}
+
+	var newCache cache.NewCacheFunc
+	if len(watchNamespaces) > 0 {
+		if namespace != "" {
+			log.Error(fmt.Errorf("--watch-namespace and --watch-namespaces are mutually exclusive"), "invalid flags")
+			os.Exit(1)
+		}
+		// Listeners are created in the namespace of the controller.
+		namespaces := append([]string{os.Getenv("CONTROLLER_MANAGER_POD_NAMESPACE")}, watchNamespaces...)
+		newCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   leaderElectionId,
-		Port:               port,
-		SyncPeriod:         &syncPeriod,
-		Namespace:          namespace,
+		Scheme:                  scheme,
+		MetricsBindAddress:      metricsAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        leaderElectionId,
+		LeaderElectionNamespace: leaderElectionNamespace,
+		LeaseDuration:           &leaseDuration,
+		RenewDeadline:           &renewDeadline,
+		RetryPeriod:             &retryPeriod,
+		Port:                    port,
+		SyncPeriod:              &syncPeriod,
+		Namespace:               namespace,
+		NewCache:                newCache,
 	})
 	if err != nil {
 		log.Error(err, "unable to start manager")
@@ -175,10 +305,19 @@ func main() {
 		ghClient,
 	)
 
+	actionsClientOptions := []actions.ClientOption{actions.WithTransportSettings(actionsClientTransport)}
+	if actionsAuditLog {
+		actionsClientOptions = append(actionsClientOptions, actions.WithAuditLog(os.Stdout))
+	}
 	actionsMultiClient := actions.NewMultiClient(
 		"actions-runner-controller/"+build.Version,
 		log.WithName("actions-clients"),
+		actionsClientOptions...,
 	)
+	if err := mgr.Add(actionsMultiClient); err != nil {
+		log.Error(err, "unable to add actions client token refresh")
+		os.Exit(1)
+	}
 
 	if !autoScalingRunnerSetOnly {
 		runnerReconciler := &actionssummerwindnet.RunnerReconciler{
@@ -251,7 +390,9 @@ func main() {
 			"common-runnner-labels", commonRunnerLabels,
 			"leader-election-enabled", enableLeaderElection,
 			"leader-election-id", leaderElectionId,
+			"leader-election-lease-duration", leaseDuration,
 			"watch-namespace", namespace,
+			"watch-namespaces", watchNamespaces,
 		)
 
 		horizontalRunnerAutoscaler := &actionssummerwindnet.HorizontalRunnerAutoscalerReconciler{
@@ -341,13 +482,151 @@ func main() {
 		os.Exit(1)
 	}
 
+	var githubErrors actionsgithubcom.GitHubErrorRecorder
+	if adminAPIAddr != "" {
+		adminServer := &actionsgithubcom.AdminServer{
+			Client: mgr.GetClient(),
+			Log:    log.WithName("AdminAPI"),
+			Addr:   adminAPIAddr,
+		}
+		if err := mgr.Add(adminServer); err != nil {
+			log.Error(err, "unable to add admin API server")
+			os.Exit(1)
+		}
+		githubErrors = adminServer
+	}
+
+	if externalMetricsAddr != "" {
+		externalMetricsServer := &actionsgithubcom.ExternalMetricsServer{
+			Client:  mgr.GetClient(),
+			Log:     log.WithName("ExternalMetrics"),
+			Addr:    externalMetricsAddr,
+			CertDir: externalMetricsCertDir,
+		}
+		if err := externalMetricsServer.DelegateAuth(); err != nil {
+			log.Error(err, "unable to set up the authentication of the external metrics API server")
+			os.Exit(1)
+		}
+		if err := mgr.Add(externalMetricsServer); err != nil {
+			log.Error(err, "unable to add external metrics API server")
+			os.Exit(1)
+		}
+	}
+
+	if workflowJobWebhookAddr != "" {
+		if workflowJobWebhookSecretToken == "" {
+			log.Error(fmt.Errorf("--workflow-job-webhook-secret-token is required with --workflow-job-webhook-addr to validate the webhook events"), "invalid flags")
+			os.Exit(1)
+		}
+		workflowJobWebhookServer := &actionsgithubcom.WorkflowJobWebhookServer{
+			Client:    mgr.GetClient(),
+			Log:       log.WithName("WorkflowJobWebhook"),
+			Addr:      workflowJobWebhookAddr,
+			SecretKey: []byte(workflowJobWebhookSecretToken),
+		}
+		if err := mgr.Add(workflowJobWebhookServer); err != nil {
+			log.Error(err, "unable to add workflow job webhook server")
+			os.Exit(1)
+		}
+	}
+
+	var defaultGitHubServerTLS *actionsgithubcom.DefaultGitHubServerTLS
+	if defaultGitHubServerTLSConfigMap != "" {
+		defaultGitHubServerTLS = &actionsgithubcom.DefaultGitHubServerTLS{
+			Namespace: mgrPodNamespace,
+			GitHubServerTLSConfig: githubv1alpha1.GitHubServerTLSConfig{
+				RootCAsConfigMapRef: defaultGitHubServerTLSConfigMap,
+				RootCAsConfigMapKey: defaultGitHubServerTLSConfigMapKey,
+			},
+		}
+	}
+
+	var runnerDiagnostics *actionsgithubcom.RunnerDiagnostics
+	if runnerDiagnosticsURL != "" {
+		store, err := objectstore.New(runnerDiagnosticsURL)
+		if err != nil {
+			log.Error(err, "unable to create runner diagnostics object store")
+			os.Exit(1)
+		}
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			log.Error(err, "unable to create clientset for runner diagnostics")
+			os.Exit(1)
+		}
+		runnerDiagnostics = &actionsgithubcom.RunnerDiagnostics{
+			Store:     store,
+			Clientset: clientset,
+			Timeout:   runnerDiagnosticsTimeout,
+		}
+	}
+
+	defaultSecurityContext, err := actionsgithubcom.ParseDefaultSecurityContext(defaultPodSecurityContext, defaultContainerSecurityContext)
+	if err != nil {
+		log.Error(err, "invalid default security context flags")
+		os.Exit(1)
+	}
+
+	var podSecurityAdmissionConfig []byte
+	if podSecurityAdmissionConfigFile != "" {
+		podSecurityAdmissionConfig, err = os.ReadFile(podSecurityAdmissionConfigFile)
+		if err != nil {
+			log.Error(err, "unable to read --pod-security-admission-config")
+			os.Exit(1)
+		}
+	}
+	podSecurityAdmission, err := actionsgithubcom.ParsePodSecurityAdmissionConfig(string(podSecurityAdmissionConfig))
+	if err != nil {
+		log.Error(err, "invalid --pod-security-admission-config")
+		os.Exit(1)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		log.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	nativeSidecars, err := actionsgithubcom.ResolveNativeSidecar(dindNativeSidecar, discoveryClient.ServerVersion)
+	if err != nil {
+		log.Error(err, "unable to resolve --dind-native-sidecar")
+		os.Exit(1)
+	}
+	log.Info("Resolved dind native sidecars", "mode", dindNativeSidecar, "enabled", nativeSidecars)
+
+	var controllerConfig *actionsgithubcom.ControllerConfig
+	if controllerConfigMap != "" {
+		controllerConfig = new(actionsgithubcom.ControllerConfig)
+		configReconciler := &actionsgithubcom.ControllerConfigReconciler{
+			Log:       log.WithName("ControllerConfig"),
+			ConfigMap: types.NamespacedName{Namespace: mgrPodNamespace, Name: controllerConfigMap},
+			Config:    controllerConfig,
+		}
+		// Apply the settings before the controllers start, the reconciler applies their changes afterwards.
+		if err := configReconciler.Load(context.Background(), mgr.GetAPIReader()); err != nil {
+			log.Error(err, "unable to load controller config map")
+			os.Exit(1)
+		}
+		if err := configReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "ControllerConfig")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&actionsgithubcom.AutoscalingRunnerSetReconciler{
 		Client:                             mgr.GetClient(),
-		Log:                                log.WithName("AutoscalingRunnerSet"),
+		Log:                                controllerLog("AutoscalingRunnerSet"),
 		Scheme:                             mgr.GetScheme(),
+		APIReader:                          mgr.GetAPIReader(),
 		ControllerNamespace:                mgrPodNamespace,
 		DefaultRunnerScaleSetListenerImage: mgrContainer.Image,
 		ActionsClient:                      actionsMultiClient,
+		GitHubErrors:                       githubErrors,
+		RemoteCleanupTimeout:               remoteCleanupTimeout,
+		RunnerVersionCheckInterval:         runnerVersionCheckInterval,
+		IsolateNamespaces:                  len(watchNamespaces) > 0,
+		Config:                             controllerConfig,
+		DefaultGitHubServerTLS:             defaultGitHubServerTLS,
+		DefaultSecurityContext:             defaultSecurityContext,
+		PodSecurityAdmissionConfig:         podSecurityAdmission,
 		DefaultRunnerScaleSetListenerImagePullSecrets: autoScalerImagePullSecrets,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "AutoscalingRunnerSet")
@@ -355,32 +634,79 @@ func main() {
 	}
 
 	if err = (&actionsgithubcom.EphemeralRunnerReconciler{
-		Client:        mgr.GetClient(),
-		Log:           log.WithName("EphemeralRunner"),
-		Scheme:        mgr.GetScheme(),
-		ActionsClient: actionsMultiClient,
+		Client:                 mgr.GetClient(),
+		Log:                    controllerLog("EphemeralRunner"),
+		Scheme:                 mgr.GetScheme(),
+		ActionsClient:          actionsMultiClient,
+		PreemptionTaints:       splitCommaSeparated(preemptionTaints),
+		RemoteCleanupTimeout:   remoteCleanupTimeout,
+		Config:                 controllerConfig,
+		DefaultGitHubServerTLS: defaultGitHubServerTLS,
+		Diagnostics:            runnerDiagnostics,
+		NativeSidecars:         nativeSidecars,
+		DefaultSecurityContext: defaultSecurityContext,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "EphemeralRunner")
 		os.Exit(1)
 	}
 
 	if err = (&actionsgithubcom.EphemeralRunnerSetReconciler{
-		Client:        mgr.GetClient(),
-		Log:           log.WithName("EphemeralRunnerSet"),
-		Scheme:        mgr.GetScheme(),
-		ActionsClient: actionsMultiClient,
+		Client:                       mgr.GetClient(),
+		Log:                          controllerLog("EphemeralRunnerSet"),
+		Scheme:                       mgr.GetScheme(),
+		ActionsClient:                actionsMultiClient,
+		OrphanedRunnerSweepInterval:  orphanedRunnerSweepInterval,
+		WorkVolumeClaimSweepInterval: workVolumeClaimSweepInterval,
+		RemoteCleanupTimeout:         remoteCleanupTimeout,
+		Config:                       controllerConfig,
+		DefaultGitHubServerTLS:       defaultGitHubServerTLS,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "EphemeralRunnerSet")
 		os.Exit(1)
 	}
 	if err = (&actionsgithubcom.AutoscalingListenerReconciler{
-		Client: mgr.GetClient(),
-		Log:    log.WithName("AutoscalingListener"),
-		Scheme: mgr.GetScheme(),
+		Client:                      mgr.GetClient(),
+		Log:                         controllerLog("AutoscalingListener"),
+		Scheme:                      mgr.GetScheme(),
+		ListenerAuditLog:            actionsAuditLog,
+		ControllerNamespace:         mgrPodNamespace,
+		ListenerNodeFailoverTimeout: listenerNodeFailoverTimeout,
+		ListenerLogLevel:            listenerLogLevel,
+		ListenerLogFormat:           listenerLogFormat,
+		DefaultGitHubServerTLS:      defaultGitHubServerTLS,
+		DefaultSecurityContext:      defaultSecurityContext,
+		Config:                      controllerConfig,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "AutoscalingListener")
 		os.Exit(1)
 	}
+	runnerBudgetReconciler := &actionsgithubcom.RunnerBudgetReconciler{
+		Client:     mgr.GetClient(),
+		Log:        log.WithName("RunnerBudget"),
+		MaxRunners: globalMaxRunners,
+		Config:     controllerConfig,
+	}
+	if runnerBudgetReconciler.Enabled() {
+		if err = runnerBudgetReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "RunnerBudget")
+			os.Exit(1)
+		}
+	} else if err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		// Leftover budgets only hold back the runner sets, so failing to remove them doesn't stop the controller.
+		if err := runnerBudgetReconciler.ClearBudgets(ctx); err != nil {
+			log.Error(err, "unable to remove the runner budgets")
+		}
+		return nil
+	})); err != nil {
+		log.Error(err, "unable to add runner budget cleanup")
+		os.Exit(1)
+	}
+	if enableConversionWebhook {
+		if err = (&githubv1alpha1.AutoscalingRunnerSet{}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "AutoscalingRunnerSet")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if !disableAdmissionWebhook && !autoScalingRunnerSetOnly {
@@ -418,3 +744,9 @@ func (s *commaSeparatedStringSlice) Set(value string) error {
 	}
 	return nil
 }
+
+func splitCommaSeparated(value string) []string {
+	var s commaSeparatedStringSlice
+	_ = s.Set(value)
+	return s
+}
@@ -0,0 +1,129 @@
+// Package imagedigest resolves the tags of container images to the digests of their manifests
+// with go-containerregistry, so that pods can be pinned to the image a tag pointed to, and
+// verifies the cosign signatures of the images.
+package imagedigest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Credentials authenticate to a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Keychain maps the registry hosts to their credentials. Docker Hub is index.docker.io.
+type Keychain map[string]Credentials
+
+var _ authn.Keychain = Keychain(nil)
+
+// Resolve implements authn.Keychain. Registries without credentials are accessed anonymously.
+func (k Keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	credentials, ok := k[target.RegistryStr()]
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return &authn.Basic{Username: credentials.Username, Password: credentials.Password}, nil
+}
+
+// Resolver resolves image tags to digests.
+type Resolver struct {
+	// Transport sends the requests to the registries. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Resolve returns the digest of the manifest the tag of the image points to, authenticating
+// to the registry with the credentials of the keychain when it requires it.
+// The digest of images referenced by digest is returned as it is.
+func (r *Resolver) Resolve(ctx context.Context, image string, keychain Keychain) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	if digest, ok := ref.(name.Digest); ok {
+		return digest.DigestStr(), nil
+	}
+
+	// Multi-arch images resolve to the digest of their index rather than the one of a platform.
+	desc, err := remote.Head(ref, r.options(ctx, keychain)...)
+	if err != nil {
+		// Some registries only return the digest of the manifest in the response to a GET.
+		d, getErr := remote.Get(ref, r.options(ctx, keychain)...)
+		if getErr != nil {
+			return "", fmt.Errorf("failed to get the manifest of %s: %w", image, getErr)
+		}
+		desc = &d.Descriptor
+	}
+	return desc.Digest.String(), nil
+}
+
+func (r *Resolver) options(ctx context.Context, keychain Keychain) []remote.Option {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(keychain),
+		remote.WithTransport(transport),
+	}
+}
+
+// KeychainFromDockerConfigJSON reads the credentials of the .dockerconfigjson of an image pull secret.
+func KeychainFromDockerConfigJSON(data []byte) (Keychain, error) {
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode docker config: %w", err)
+	}
+
+	keychain := make(Keychain, len(config.Auths))
+	for server, auth := range config.Auths {
+		credentials := Credentials{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of registry %s: %w", server, err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid auth of registry %s: expected username:password", server)
+			}
+			credentials = Credentials{Username: username, Password: password}
+		}
+		keychain[registryHost(server)] = credentials
+	}
+	return keychain, nil
+}
+
+// registryHost returns the registry of the server of a docker config, which may be a URL
+// such as https://index.docker.io/v1/, as go-containerregistry names it.
+func registryHost(server string) string {
+	host := server
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	registry, err := name.NewRegistry(host)
+	if err != nil {
+		return host
+	}
+	return registry.RegistryStr()
+}
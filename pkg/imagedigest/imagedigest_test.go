@@ -0,0 +1,85 @@
+package imagedigest
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry serves the handler as the registry.example.com registry, which the transport of the returned
+// resolver dials. The registry isn't named after the loopback address of the server, as go-containerregistry
+// talks plain HTTP to loopback registries.
+func testRegistry(t *testing.T, handler http.Handler) (*Resolver, string) {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return &Resolver{Transport: transport}, "registry.example.com"
+}
+
+func TestResolve(t *testing.T) {
+	const digest = "sha256:4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b"
+
+	resolver, registry := testRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://registry.example.com/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/token":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:actions/runner:pull", r.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token":"t0ken"}`)
+		case "/v2/actions/runner/manifests/latest":
+			if r.Header.Get("Authorization") != "Bearer t0ken" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Content-Length", "2")
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	ctx := context.Background()
+
+	got, err := resolver.Resolve(ctx, registry+"/actions/runner:latest", Keychain{registry: {Username: "user", Password: "pass"}})
+	require.NoError(t, err)
+	assert.Equal(t, digest, got)
+
+	_, err = resolver.Resolve(ctx, registry+"/actions/runner:latest", nil)
+	assert.Error(t, err, "The token service rejects anonymous requests")
+
+	_, err = resolver.Resolve(ctx, registry+"/actions/missing:latest", nil)
+	assert.Error(t, err)
+
+	got, err = resolver.Resolve(ctx, "unreachable.example.com/runner@"+digest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, digest, got, "Images referenced by digest are not resolved")
+}
+
+func TestKeychainFromDockerConfigJSON(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("octocat:secret"))
+	keychain, err := KeychainFromDockerConfigJSON([]byte(fmt.Sprintf(`{"auths":{
+		"https://index.docker.io/v1/": {"auth": %q},
+		"ghcr.io": {"username": "bot", "password": "token"}
+	}}`, auth)))
+	require.NoError(t, err)
+	assert.Equal(t, Keychain{
+		"index.docker.io": {Username: "octocat", Password: "secret"},
+		"ghcr.io":         {Username: "bot", Password: "token"},
+	}, keychain)
+}
@@ -0,0 +1,435 @@
+package imagedigest
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Annotations of the layers of the signature manifests pushed by cosign.
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// Extensions of the Fulcio certificates holding the OIDC issuer of the signer.
+var (
+	fulcioIssuerV1OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	fulcioIssuerV2OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// ErrUnverifiedSignature is wrapped by the errors of VerifySignature when the image has no cosign signature
+// satisfying the policy, as opposed to failing to reach the registry.
+var ErrUnverifiedSignature = errors.New("no verified cosign signature")
+
+// SignaturePolicy is what a cosign signature of an image has to satisfy. Exactly one of PublicKey and Keyless is set.
+type SignaturePolicy struct {
+	// PublicKey verifies the signatures made with a key pair, as by cosign sign --key.
+	PublicKey crypto.PublicKey
+	// RekorPublicKey is the public key of the Rekor transparency log the signatures made with PublicKey are logged to.
+	RekorPublicKey crypto.PublicKey
+	// Keyless verifies the signatures made with short-lived certificates issued by Fulcio, as by cosign keyless signing.
+	Keyless *KeylessPolicy
+}
+
+// KeylessPolicy verifies the certificate of a keyless signature and the identity of its signer.
+// The signing time is the integration time of the signature in the Rekor transparency log,
+// trusted after checking the signed entry timestamp of the bundle of the signature.
+type KeylessPolicy struct {
+	// Roots are the root certificates of Fulcio.
+	Roots *x509.CertPool
+	// Intermediates are the intermediate certificates of Fulcio, in addition to the chain of the signature.
+	Intermediates *x509.CertPool
+	// Issuer is the OIDC issuer of the signer, e.g. https://token.actions.githubusercontent.com.
+	Issuer string
+	// Subject matches the whole email or URI of the signer, e.g. the workflow signing the image.
+	Subject *regexp.Regexp
+	// RekorPublicKey is the public key of the Rekor transparency log.
+	RekorPublicKey crypto.PublicKey
+}
+
+// ParsePublicKey parses a PEM encoded public key, as written by cosign generate-key-pair.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return key, nil
+}
+
+// ParseCertificates parses the PEM encoded certificates of a chain, splitting the self-signed roots
+// from the intermediates.
+func ParseCertificates(data []byte) (roots, intermediates *x509.CertPool, err error) {
+	roots, intermediates = x509.NewCertPool(), x509.NewCertPool()
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(certs) == 0 {
+		return nil, nil, errors.New("no PEM encoded certificate")
+	}
+	hasRoot := false
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+			roots.AddCert(cert)
+			hasRoot = true
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	if !hasRoot {
+		return nil, nil, errors.New("no root certificate")
+	}
+	return roots, intermediates, nil
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// simpleSigningPayload is the payload signed by cosign.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// rekorBundle is the proof of inclusion of a signature in the Rekor transparency log, attached by cosign.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte             `json:"SignedEntryTimestamp"`
+	Payload              rekorBundlePayload `json:"Payload"`
+}
+
+// rekorBundlePayload is signed by Rekor in its canonical JSON encoding, with the fields in this order.
+type rekorBundlePayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// hashedRekord is the entry of a signature in the Rekor transparency log.
+type hashedRekord struct {
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// VerifySignature verifies that the image has a cosign signature satisfying the policy, and returns the digest
+// of the image the signature covers. Tags are resolved to the digest they point to first.
+func (r *Resolver) VerifySignature(ctx context.Context, image string, policy *SignaturePolicy, keychain Keychain) (string, error) {
+	digest, err := r.Resolve(ctx, image, keychain)
+	if err != nil {
+		return "", err
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+
+	// cosign pushes the signatures of an image to the sha256-<hex>.sig tag of its repository.
+	signatureTag := ref.Context().Tag(strings.Replace(digest, ":", "-", 1) + ".sig")
+	signatures, err := remote.Image(signatureTag, r.options(ctx, keychain)...)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return "", fmt.Errorf("%w: %s is not signed", ErrUnverifiedSignature, image)
+		}
+		return "", fmt.Errorf("failed to get the signatures of %s: %w", image, err)
+	}
+	manifest, err := signatures.Manifest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the signatures of %s: %w", image, err)
+	}
+
+	var failures []string
+	for _, layer := range manifest.Layers {
+		if layer.Annotations[cosignSignatureAnnotation] == "" {
+			continue
+		}
+		payload, err := signaturePayload(signatures, layer.Digest)
+		if err != nil {
+			return "", err
+		}
+		if err := verifyCosignSignature(policy, digest, payload, layer.Annotations); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		return digest, nil
+	}
+	if len(failures) == 0 {
+		return "", fmt.Errorf("%w: %s is not signed", ErrUnverifiedSignature, image)
+	}
+	return "", fmt.Errorf("%w: %s: %s", ErrUnverifiedSignature, image, strings.Join(failures, "; "))
+}
+
+// signaturePayload returns the payload of a signature layer, checked against its digest.
+func signaturePayload(signatures v1.Image, digest v1.Hash) ([]byte, error) {
+	layer, err := signatures.LayerByDigest(digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s: %w", digest, err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s: %w", digest, err)
+	}
+	defer rc.Close()
+	// Signature payloads are small JSON documents.
+	data, err := io.ReadAll(io.LimitReader(rc, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", digest, err)
+	}
+	if got := fmt.Sprintf("sha256:%x", sha256.Sum256(data)); got != digest.String() {
+		return nil, fmt.Errorf("blob %s has digest %s", digest, got)
+	}
+	return data, nil
+}
+
+// verifyCosignSignature verifies a signature layer of the signature manifest of the image with the given digest.
+func verifyCosignSignature(policy *SignaturePolicy, digest string, payload []byte, annotations map[string]string) error {
+	signature, err := base64.StdEncoding.DecodeString(annotations[cosignSignatureAnnotation])
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	var p simpleSigningPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("invalid signature payload: %v", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature of digest %s", p.Critical.Image.DockerManifestDigest)
+	}
+
+	if policy.PublicKey != nil {
+		signer, err := x509.MarshalPKIXPublicKey(policy.PublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %v", err)
+		}
+		if _, err := verifyRekorBundle(policy.RekorPublicKey, payload, annotations, signer); err != nil {
+			return err
+		}
+		return verifySignature(policy.PublicKey, payload, signature)
+	}
+	if policy.Keyless == nil {
+		return errors.New("no public key or keyless policy")
+	}
+	return verifyKeylessSignature(policy.Keyless, payload, signature, annotations)
+}
+
+func verifyKeylessSignature(policy *KeylessPolicy, payload, signature []byte, annotations map[string]string) error {
+	certs, err := parseCertificates([]byte(annotations[cosignCertificateAnnotation]))
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return errors.New("no certificate, the signature was made with a key")
+	}
+	cert := certs[0]
+
+	integratedTime, err := verifyRekorBundle(policy.RekorPublicKey, payload, annotations, cert.Raw)
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	if policy.Intermediates != nil {
+		intermediates = policy.Intermediates.Clone()
+	}
+	chain, err := parseCertificates([]byte(annotations[cosignChainAnnotation]))
+	if err != nil {
+		return err
+	}
+	for _, c := range chain {
+		intermediates.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         policy.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(integratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("untrusted certificate: %v", err)
+	}
+
+	issuer := certificateIssuer(cert)
+	if issuer != policy.Issuer {
+		return fmt.Errorf("signed by an identity of issuer %q", issuer)
+	}
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		subjects = append(subjects, u.String())
+	}
+	matched := false
+	for _, s := range subjects {
+		if policy.Subject != nil && policy.Subject.MatchString(s) {
+			matched = true
+		}
+	}
+	if !matched {
+		return fmt.Errorf("signed by %s", strings.Join(subjects, ", "))
+	}
+
+	return verifySignature(cert.PublicKey, payload, signature)
+}
+
+// verifyRekorBundle checks that the bundle of the signature was signed by the Rekor transparency log of the key,
+// and that its entry records the signature of the payload by the signer, the DER encoded public key or certificate
+// the signature is verified with. It returns the integration time of the entry in the log.
+func verifyRekorBundle(rekorKey crypto.PublicKey, payload []byte, annotations map[string]string, signer []byte) (int64, error) {
+	if rekorKey == nil {
+		return 0, errors.New("no Rekor public key")
+	}
+	if annotations[cosignBundleAnnotation] == "" {
+		return 0, errors.New("no transparency log bundle")
+	}
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(annotations[cosignBundleAnnotation]), &bundle); err != nil {
+		return 0, fmt.Errorf("invalid transparency log bundle: %v", err)
+	}
+
+	// The ID of a Rekor log is the SHA-256 digest of its DER encoded public key.
+	der, err := x509.MarshalPKIXPublicKey(rekorKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Rekor public key: %v", err)
+	}
+	logID := sha256.Sum256(der)
+	if bundle.Payload.LogID != hex.EncodeToString(logID[:]) {
+		return 0, fmt.Errorf("invalid transparency log bundle: logged to %s, not to the Rekor log of the public key", bundle.Payload.LogID)
+	}
+
+	signed, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return 0, err
+	}
+	if err := verifySignature(rekorKey, signed, bundle.SignedEntryTimestamp); err != nil {
+		return 0, fmt.Errorf("invalid transparency log bundle: %v", err)
+	}
+	if err := verifyRekorEntry(bundle.Payload.Body, payload, annotations[cosignSignatureAnnotation], signer); err != nil {
+		return 0, err
+	}
+	return bundle.Payload.IntegratedTime, nil
+}
+
+// verifyRekorEntry checks that the transparency log entry records the signature of the payload by the signer.
+func verifyRekorEntry(body string, payload []byte, signature string, signer []byte) error {
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+	hash := sha256.Sum256(payload)
+	if entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(hash[:]) || entry.Spec.Signature.Content != signature {
+		return errors.New("the transparency log entry is not the one of the signature")
+	}
+
+	// The entry holds the PEM encoded public key or certificate of the signer.
+	logged, err := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+	block, _ := pem.Decode(logged)
+	if block == nil || !bytes.Equal(block.Bytes, signer) {
+		return errors.New("the transparency log entry is not signed by the signer of the signature")
+	}
+	return nil
+}
+
+// certificateIssuer returns the OIDC issuer of the signer of a Fulcio certificate.
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(fulcioIssuerV2OID):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(fulcioIssuerV1OID):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+// verifySignature verifies the signature of the SHA-256 digest of the data, or of the data itself for Ed25519.
+func verifySignature(key crypto.PublicKey, data, signature []byte) error {
+	hash := sha256.Sum256(data)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(key, hash[:], signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	case nil:
+		return errors.New("no public key")
+	default:
+		return fmt.Errorf("unsupported public key %T", key)
+	}
+	return errors.New("invalid signature")
+}
@@ -0,0 +1,254 @@
+package imagedigest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	signedDigest   = "sha256:4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b"
+	unsignedDigest = "sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+	signatureTag   = "sha256-4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b4a5b.sig"
+)
+
+// signatureRegistry serves the tags of the runner image, and the signature manifests and blobs given.
+func signatureRegistry(t *testing.T, signatures map[string][]byte, blobs map[string][]byte) (*Resolver, string) {
+	return testRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+		case r.URL.Path == "/v2/actions/runner/manifests/signed" || r.URL.Path == "/v2/actions/runner/manifests/unsigned":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Content-Length", "2")
+			w.Header().Set("Docker-Content-Digest", signedDigest)
+			if strings.HasSuffix(r.URL.Path, "unsigned") {
+				w.Header().Set("Docker-Content-Digest", unsignedDigest)
+			}
+		case strings.HasPrefix(r.URL.Path, "/v2/actions/runner/manifests/sha256-"):
+			manifest, ok := signatures[strings.TrimPrefix(r.URL.Path, "/v2/actions/runner/manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write(manifest)
+		case strings.HasPrefix(r.URL.Path, "/v2/actions/runner/blobs/"):
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/actions/runner/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func signedPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"registry/actions/runner"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, digest))
+}
+
+func signatureManifestOf(t *testing.T, payload []byte, annotations map[string]string) ([]byte, string) {
+	blobDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(payload))
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]interface{}{{
+			"mediaType":   "application/vnd.dev.cosign.simplesigning.v1+json",
+			"digest":      blobDigest,
+			"size":        len(payload),
+			"annotations": annotations,
+		}},
+	})
+	require.NoError(t, err)
+	return manifest, blobDigest
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	hash := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	return signature
+}
+
+// rekorBundleOf returns the bundle of the signature of the payload by the PEM encoded signer, logged to the Rekor log of the key.
+func rekorBundleOf(t *testing.T, rekorKey *ecdsa.PrivateKey, payload []byte, signature string, signer []byte, integratedTime time.Time) string {
+	payloadHash := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])}},
+			"signature": map[string]interface{}{
+				"content":   signature,
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(signer)},
+			},
+		},
+	})
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	require.NoError(t, err)
+	logID := sha256.Sum256(der)
+	entry := rekorBundlePayload{Body: base64.StdEncoding.EncodeToString(body), IntegratedTime: integratedTime.Unix(), LogID: hex.EncodeToString(logID[:]), LogIndex: 42}
+	canonical, err := json.Marshal(entry)
+	require.NoError(t, err)
+	bundle, err := json.Marshal(rekorBundle{SignedEntryTimestamp: sign(t, rekorKey, canonical), Payload: entry})
+	require.NoError(t, err)
+	return string(bundle)
+}
+
+func TestVerifySignature_PublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	publicKey, err := ParsePublicKey(publicKeyPEM)
+	require.NoError(t, err)
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	payload := signedPayload(signedDigest)
+	signature := base64.StdEncoding.EncodeToString(sign(t, key, payload))
+	manifest, blobDigest := signatureManifestOf(t, payload, map[string]string{
+		cosignSignatureAnnotation: signature,
+		cosignBundleAnnotation:    rekorBundleOf(t, rekorKey, payload, signature, publicKeyPEM, time.Now()),
+	})
+	resolver, registry := signatureRegistry(t, map[string][]byte{signatureTag: manifest}, map[string][]byte{blobDigest: payload})
+	ctx := context.Background()
+	policy := &SignaturePolicy{PublicKey: publicKey, RekorPublicKey: &rekorKey.PublicKey}
+
+	digest, err := resolver.VerifySignature(ctx, registry+"/actions/runner:signed", policy, nil)
+	require.NoError(t, err)
+	assert.Equal(t, signedDigest, digest)
+
+	_, err = resolver.VerifySignature(ctx, registry+"/actions/runner:unsigned", policy, nil)
+	assert.ErrorIs(t, err, ErrUnverifiedSignature)
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = resolver.VerifySignature(ctx, registry+"/actions/runner:signed", &SignaturePolicy{PublicKey: &other.PublicKey, RekorPublicKey: &rekorKey.PublicKey}, nil)
+	assert.ErrorIs(t, err, ErrUnverifiedSignature)
+	assert.ErrorContains(t, err, "not signed by the signer of the signature")
+
+	_, err = resolver.VerifySignature(ctx, registry+"/actions/runner:signed", &SignaturePolicy{PublicKey: publicKey, RekorPublicKey: &other.PublicKey}, nil)
+	assert.ErrorIs(t, err, ErrUnverifiedSignature)
+	assert.ErrorContains(t, err, "not to the Rekor log of the public key")
+}
+
+func TestVerifySignature_PublicKeyNotLogged(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	payload := signedPayload(signedDigest)
+	manifest, blobDigest := signatureManifestOf(t, payload, map[string]string{
+		cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sign(t, key, payload)),
+	})
+	resolver, registry := signatureRegistry(t, map[string][]byte{signatureTag: manifest}, map[string][]byte{blobDigest: payload})
+
+	_, err = resolver.VerifySignature(context.Background(), registry+"/actions/runner:signed", &SignaturePolicy{PublicKey: &key.PublicKey, RekorPublicKey: &rekorKey.PublicKey}, nil)
+	assert.ErrorIs(t, err, ErrUnverifiedSignature)
+	assert.ErrorContains(t, err, "no transparency log bundle")
+}
+
+func TestVerifySignature_Keyless(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, root, root, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err = x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+	roots, intermediates, err := ParseCertificates(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}))
+	require.NoError(t, err)
+
+	signedAt := time.Now().Add(-30 * time.Minute)
+	signerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuer, err := asn1.Marshal("https://token.actions.githubusercontent.com")
+	require.NoError(t, err)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signedAt.Add(-time.Minute),
+		NotAfter:        signedAt.Add(9 * time.Minute),
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		EmailAddresses:  []string{"release@example.com"},
+		ExtraExtensions: []pkix.Extension{{Id: fulcioIssuerV2OID, Value: issuer}},
+	}, root, &signerKey.PublicKey, rootKey)
+	require.NoError(t, err)
+
+	payload := signedPayload(signedDigest)
+	signature := base64.StdEncoding.EncodeToString(sign(t, signerKey, payload))
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	bundle := rekorBundleOf(t, rekorKey, payload, signature, leafPEM, signedAt)
+
+	manifest, blobDigest := signatureManifestOf(t, payload, map[string]string{
+		cosignSignatureAnnotation:   signature,
+		cosignCertificateAnnotation: string(leafPEM),
+		cosignBundleAnnotation:      bundle,
+	})
+	resolver, registry := signatureRegistry(t, map[string][]byte{signatureTag: manifest}, map[string][]byte{blobDigest: payload})
+	ctx := context.Background()
+
+	policy := &SignaturePolicy{Keyless: &KeylessPolicy{
+		Roots:          roots,
+		Intermediates:  intermediates,
+		Issuer:         "https://token.actions.githubusercontent.com",
+		Subject:        regexp.MustCompile(`^release@example\.com$`),
+		RekorPublicKey: &rekorKey.PublicKey,
+	}}
+	// The certificate expired since, but was valid when the signature was logged.
+	digest, err := resolver.VerifySignature(ctx, registry+"/actions/runner:signed", policy, nil)
+	require.NoError(t, err)
+	assert.Equal(t, signedDigest, digest)
+
+	policy.Keyless.Subject = regexp.MustCompile(`^attacker@example\.com$`)
+	_, err = resolver.VerifySignature(ctx, registry+"/actions/runner:signed", policy, nil)
+	assert.ErrorIs(t, err, ErrUnverifiedSignature)
+	assert.ErrorContains(t, err, "signed by release@example.com")
+
+	policy.Keyless.Subject = regexp.MustCompile(`^release@example\.com$`)
+	policy.Keyless.Issuer = "https://accounts.google.com"
+	_, err = resolver.VerifySignature(ctx, registry+"/actions/runner:signed", policy, nil)
+	assert.ErrorContains(t, err, "issuer")
+
+	otherRekor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	policy.Keyless.Issuer = "https://token.actions.githubusercontent.com"
+	policy.Keyless.RekorPublicKey = &otherRekor.PublicKey
+	_, err = resolver.VerifySignature(ctx, registry+"/actions/runner:signed", policy, nil)
+	assert.ErrorContains(t, err, "invalid transparency log bundle")
+}
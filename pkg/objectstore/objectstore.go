@@ -0,0 +1,259 @@
+// Package objectstore uploads objects to S3, Google Cloud Storage and Azure Blob Storage
+// with their HTTP APIs.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store stores objects by key.
+type Store interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// New returns the Store of the URL, reading the credentials from the environment:
+//
+//   - s3://bucket/prefix?region=us-east-1 uploads to S3 with AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+//     and AWS_SESSION_TOKEN. The endpoint parameter selects an S3 compatible service, e.g. MinIO.
+//   - gs://bucket/prefix uploads to Google Cloud Storage with the HMAC key GCS_HMAC_ACCESS_KEY_ID and GCS_HMAC_SECRET.
+//   - azblob://account/container/prefix uploads to Azure Blob Storage with the SAS token AZURE_STORAGE_SAS_TOKEN.
+//     The endpoint parameter selects another endpoint than https://<account>.blob.core.windows.net.
+func New(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object store URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid object store URL %q: missing bucket", rawURL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	query := u.Query()
+
+	switch u.Scheme {
+	case "s3":
+		region := query.Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			return nil, fmt.Errorf("invalid object store URL %q: missing region", rawURL)
+		}
+		return &S3Store{
+			Endpoint:        query.Get("endpoint"),
+			Bucket:          u.Host,
+			Prefix:          prefix,
+			Region:          region,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	case "gs":
+		// Cloud Storage implements the S3 API with HMAC keys.
+		return &S3Store{
+			Endpoint:        "https://storage.googleapis.com",
+			Bucket:          u.Host,
+			Prefix:          prefix,
+			Region:          "auto",
+			AccessKeyID:     os.Getenv("GCS_HMAC_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("GCS_HMAC_SECRET"),
+		}, nil
+	case "azblob":
+		container, prefix, _ := strings.Cut(prefix, "/")
+		if container == "" {
+			return nil, fmt.Errorf("invalid object store URL %q: missing container", rawURL)
+		}
+		endpoint := query.Get("endpoint")
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", u.Host)
+		}
+		return &AzureBlobStore{
+			Endpoint:  endpoint,
+			Container: container,
+			Prefix:    prefix,
+			SASToken:  os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid object store URL %q: unsupported scheme %q, expected s3, gs or azblob", rawURL, u.Scheme)
+	}
+}
+
+// S3Store uploads objects to an S3 bucket, or a bucket of an S3 compatible service.
+type S3Store struct {
+	// Endpoint is the URL of an S3 compatible service, whose buckets are addressed by path.
+	// Buckets of AWS are addressed by host when it's empty.
+	Endpoint        string
+	Bucket          string
+	Prefix          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	now func() time.Time
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	var objectURL string
+	if s.Endpoint != "" {
+		objectURL = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.Endpoint, "/"), s.Bucket, objectKey(s.Prefix, key))
+	} else {
+		objectURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, objectKey(s.Prefix, key))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.sign(req, body, now().UTC())
+
+	return send(s.Client, req)
+}
+
+// sign signs the request with AWS Signature Version 4, with all of its headers.
+func (s *S3Store) sign(req *http.Request, body []byte, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// AzureBlobStore uploads objects as block blobs to an Azure Blob Storage container.
+type AzureBlobStore struct {
+	Endpoint  string
+	Container string
+	Prefix    string
+	// SASToken grants the permission to create blobs in the container.
+	SASToken string
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (s *AzureBlobStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	blobURL := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.Endpoint, "/"), s.Container, objectKey(s.Prefix, key))
+	if s.SASToken != "" {
+		blobURL += "?" + strings.TrimPrefix(s.SASToken, "?")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, blobURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2021-08-06")
+
+	return send(s.Client, req)
+}
+
+func send(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload object: object store responded with %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// objectKey joins the prefix and the key, escaping each segment for the URL path. All characters but the
+// unreserved ones are escaped, as the canonical request of AWS Signature Version 4 requires.
+func objectKey(prefix, key string) string {
+	segments := strings.Split(strings.Trim(prefix+"/"+key, "/"), "/")
+	for i, segment := range segments {
+		var escaped strings.Builder
+		for _, b := range []byte(segment) {
+			if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' {
+				escaped.WriteByte(b)
+			} else {
+				fmt.Fprintf(&escaped, "%%%02X", b)
+			}
+		}
+		segments[i] = escaped.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}